@@ -35,9 +35,10 @@ var batchCmd = &cobra.Command{
 		p := pool.New().WithErrors().WithMaxGoroutines(workers)
 		for _, in := range files {
 			in := in
-			out := strings.TrimSuffix(in, filepath.Ext(in)) + "." + lang + ".srt"
+			ext := filepath.Ext(in)
+			out := strings.TrimSuffix(in, ext) + "." + lang + ext
 			p.Go(func() error {
-				err := subtitles.TranslateFileToSRT(in, out, lang, service, gKey, gptKey, grpcAddr)
+				err := subtitles.TranslateFile(in, out, lang, service, gKey, gptKey, grpcAddr)
 				if err == nil {
 					progress.Update(in)
 				}
@@ -8,7 +8,9 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 
@@ -20,8 +22,15 @@ import (
 
 var convertCmd = &cobra.Command{
 	Use:   "convert [input] [output]",
-	Short: "Convert subtitle to SRT",
-	Args:  cobra.ExactArgs(2),
+	Short: "Convert subtitle between formats",
+	Long: `Convert a subtitle file to another format.
+
+The target format is taken from --to, or from the extension of the output
+file when --to is not set, and defaults to SRT. Supported formats are srt,
+ass, ssa, vtt, ttml, stl and sub (MicroDVD). MicroDVD timings are frame
+based; the frame rate is taken from --fps, probed from --video, read from
+the file header or defaults to 23.976.`,
+	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Set the short description with i18n after initialization
 		cmd.Short = i18n.T("cli.convert.short")
@@ -35,9 +44,31 @@ var convertCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		data, err := subtitles.ConvertToSRT(string(in))
+		to, _ := cmd.Flags().GetString("to")
+		if to == "" {
+			to = filepath.Ext(string(out))
+		}
+		target, err := subtitles.ParseFormat(to)
 		if err != nil {
-			return err
+			if cmd.Flags().Changed("to") {
+				return err
+			}
+			target = subtitles.FormatSRT
+		}
+		fps, _ := cmd.Flags().GetFloat64("fps")
+		video, _ := cmd.Flags().GetString("video")
+		plain, _ := cmd.Flags().GetBool("plain")
+		opts := subtitles.ConvertOptions{FPS: fps, PlainText: plain}
+		if video != "" {
+			v, err := security.SanitizePath(video)
+			if err != nil {
+				return err
+			}
+			opts.VideoPath = string(v)
+		}
+		data, err := subtitles.Convert(string(in), target, opts)
+		if err != nil {
+			return fmt.Errorf("convert to %s: %w", target, err)
 		}
 		f, err := os.Create(string(out))
 		if err != nil {
@@ -51,3 +82,10 @@ var convertCmd = &cobra.Command{
 		return nil
 	},
 }
+
+func init() {
+	convertCmd.Flags().String("to", "", "target format: srt, ass, ssa, vtt, ttml, stl or sub (default from output extension)")
+	convertCmd.Flags().Float64("fps", 0, "frame rate for MicroDVD input or output")
+	convertCmd.Flags().String("video", "", "video file probed for the MicroDVD frame rate")
+	convertCmd.Flags().Bool("plain", false, "drop styling instead of mapping it between ASS and SRT/WebVTT")
+}
@@ -1,63 +1,35 @@
 package cmd
 
 import (
-	"os"
+	"context"
+	"path/filepath"
 
-	"github.com/asticode/go-astisub"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
-	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
-	"github.com/jdfalk/subtitle-manager/pkg/security"
-	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
 )
 
+// extractCmd extracts embedded subtitles. Local paths are used as given
+// rather than being limited to the allowed media directories.
 var extractCmd = &cobra.Command{
 	Use:   "extract [media] [output]",
 	Short: "Extract subtitles from media",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := logging.GetLogger("extract")
-		media, err := security.SanitizePath(args[0])
+		media, err := filepath.Abs(args[0])
 		if err != nil {
 			return err
 		}
-		out, err := security.SanitizePath(args[1])
+		out, err := filepath.Abs(args[1])
 		if err != nil {
 			return err
 		}
-		if ff := viper.GetString("ffmpeg_path"); ff != "" {
-			subtitles.SetFFmpegPath(ff)
-		}
-		items, err := subtitles.ExtractFromMedia(string(media))
-		if err != nil {
-			return err
-		}
-		sub := astisub.NewSubtitles()
-		sub.Items = items
-		f, err := os.Create(string(out))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		if err := sub.WriteToSRT(f); err != nil {
+		if err := jobs.Extract(context.Background(), media, out); err != nil {
 			return err
 		}
-		if dbPath := viper.GetString("db_path"); dbPath != "" {
-			backend := viper.GetString("db_backend")
-			if store, err := database.OpenStore(dbPath, backend); err == nil {
-				_ = store.InsertSubtitle(&database.SubtitleRecord{
-					File:      string(out),
-					VideoFile: string(media),
-					Service:   "extract",
-					Embedded:  true,
-				})
-				store.Close()
-			} else {
-				logger.Warnf("db open: %v", err)
-			}
-		}
 		logger.Infof("extracted subtitles from %s to %s", media, out)
 		return nil
 	},
@@ -26,6 +26,7 @@ func TestExtractCmdStoresRecord(t *testing.T) {
 		t.Fatalf("write script: %v", err)
 	}
 	out := filepath.Join(dir, "out.srt")
+	media := filepath.Join(dir, "video.mkv")
 	dbPath := filepath.Join(dir, "test.db")
 
 	origPath := os.Getenv("PATH")
@@ -41,7 +42,7 @@ func TestExtractCmdStoresRecord(t *testing.T) {
 		viper.Set("db_backend", origBackend)
 	}()
 
-	if err := extractCmd.RunE(extractCmd, []string{"video.mkv", out}); err != nil {
+	if err := extractCmd.RunE(extractCmd, []string{media, out}); err != nil {
 		t.Fatalf("run: %v", err)
 	}
 
@@ -58,7 +59,7 @@ func TestExtractCmdStoresRecord(t *testing.T) {
 		t.Fatalf("expected 1 record, got %d", len(recs))
 	}
 	r := recs[0]
-	if r.File != out || r.VideoFile != "video.mkv" || !r.Embedded {
+	if r.File != out || r.VideoFile != media || !r.Embedded {
 		t.Fatalf("unexpected record %+v", r)
 	}
 }
@@ -4,14 +4,17 @@ package cmd
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/scanner"
 	"github.com/jdfalk/subtitle-manager/pkg/tagging"
 )
 
@@ -63,20 +66,35 @@ uses language preferences from the media item's assigned language profile.`,
 				actualLang = lang
 			}
 		} else {
-			// Standard fetch without profiles or tags
-			data, name, err = providers.FetchFromAll(context.Background(), media, lang, key)
-			actualLang = lang
+			// Standard fetch without profiles or tags records the
+			// download itself.
+			mediaPath, err := filepath.Abs(media)
+			if err != nil {
+				return err
+			}
+			outPath, err := filepath.Abs(out)
+			if err != nil {
+				return err
+			}
+			if err := jobs.Download(context.Background(), mediaPath, lang, "", outPath); err != nil {
+				return err
+			}
+			logger.Infof("downloaded subtitle to %s", outPath)
+			return nil
 		}
 		if err != nil {
 			return err
 		}
+		data, enc := scanner.NormalizeEncoding(data, actualLang)
 		if err := os.WriteFile(out, data, 0644); err != nil {
 			return err
 		}
 		if dbPath := viper.GetString("db_path"); dbPath != "" {
 			backend := viper.GetString("db_backend")
 			if store, err := database.OpenStore(dbPath, backend); err == nil {
-				_ = store.InsertDownload(&database.DownloadRecord{File: out, VideoFile: media, Provider: name, Language: actualLang})
+				score := scanner.NormalizedScore(scanner.FileScore(name, "", "", int64(len(data)), media))
+				_ = store.InsertDownload(&database.DownloadRecord{File: out, VideoFile: media, Provider: name, Language: actualLang, MatchScore: &score})
+				scanner.RecordSubtitle(store, out, media, actualLang, name, enc)
 				store.Close()
 			} else {
 				logger.Warnf("db open: %v", err)
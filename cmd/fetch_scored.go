@@ -1,19 +1,23 @@
 // file: cmd/fetch_scored.go
-// version: 1.0.0
+// version: 1.3.1
 // guid: fedcba98-7654-3210-fedc-ba9876543210
 package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/jdfalk/subtitle-manager/pkg/archive"
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
-	"github.com/jdfalk/subtitle-manager/pkg/providers/opensubtitles"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/scanner"
 	"github.com/jdfalk/subtitle-manager/pkg/scoring"
 )
 
@@ -43,32 +47,38 @@ This command evaluates subtitles based on:
 		media, lang, out := args[0], args[1], args[2]
 		key := viper.GetString("opensubtitles.api_key")
 
-		if key == "" {
-			return fmt.Errorf("opensubtitles.api_key is required for scored fetching")
-		}
-
 		logger.Infof("searching for subtitles for %s (language: %s)", media, lang)
 
-		// Create OpenSubtitles client
-		client := opensubtitles.New(key)
-
-		// Search for subtitles
+		// Collect structured candidates from every provider that supports search
 		ctx := context.Background()
-		results, err := client.SearchWithResults(ctx, media, lang)
-		if err != nil {
-			return fmt.Errorf("search failed: %w", err)
+		var candidates []providers.Candidate
+		for _, name := range providers.All() {
+			p, err := providers.Get(name, key)
+			if err != nil {
+				continue
+			}
+			c, cancel := context.WithTimeout(ctx, 15*time.Second)
+			found, err := providers.SearchCandidates(c, p, name, media, lang)
+			cancel()
+			if err != nil {
+				if !errors.Is(err, providers.ErrSearchUnsupported) {
+					logger.Debugf("%s search failed: %v", name, err)
+				}
+				continue
+			}
+			candidates = append(candidates, found...)
 		}
 
-		if len(results) == 0 {
+		if len(candidates) == 0 {
 			return fmt.Errorf("no subtitles found")
 		}
 
-		logger.Infof("found %d subtitle candidates", len(results))
+		logger.Infof("found %d subtitle candidates", len(candidates))
 
-		// Convert search results to scoring format
-		subtitles := make([]scoring.Subtitle, len(results))
-		for i, result := range results {
-			subtitles[i] = scoring.FromOpenSubtitlesResult(result, "opensubtitles")
+		// Convert candidates to scoring format
+		subtitles := make([]scoring.Subtitle, len(candidates))
+		for i, c := range candidates {
+			subtitles[i] = scoring.FromCandidate(c)
 		}
 
 		// Extract media information from path
@@ -115,28 +125,33 @@ This command evaluates subtitles based on:
 		logger.Infof("selected subtitle with score %d (provider: %d, release: %d, format: %d, metadata: %d)",
 			score.Total, score.ProviderScore, score.ReleaseScore, score.FormatScore, score.MetadataScore)
 
-		// Find corresponding search result for download
-		var selectedResult *opensubtitles.SearchResult
+		// Find corresponding candidate for download
+		var selected *providers.Candidate
 		for i, subtitle := range subtitles {
-			if subtitle.Release == best.Release &&
-				subtitle.DownloadCount == best.DownloadCount &&
-				subtitle.Rating == best.Rating {
-				selectedResult = &results[i]
+			if subtitle == *best {
+				selected = &candidates[i]
 				break
 			}
 		}
 
-		if selectedResult == nil {
+		if selected == nil {
 			return fmt.Errorf("failed to find corresponding search result")
 		}
 
-		// Download the selected subtitle using the client's Fetch method
-		// Note: The client's Fetch method will automatically use the first search result
-		// In practice, we would enhance this to download the specific selected subtitle
-		data, err := client.Fetch(ctx, media, lang)
+		p, err := providers.Get(selected.Provider, key)
+		if err != nil {
+			return err
+		}
+		data, err := providers.DownloadCandidate(ctx, p, *selected)
 		if err != nil {
 			return fmt.Errorf("download failed: %w", err)
 		}
+		data, _, err = archive.Extract(data, media)
+		if err != nil {
+			return fmt.Errorf("unpack failed: %w", err)
+		}
+
+		data, enc := scanner.NormalizeEncoding(data, lang)
 
 		// Write to output file
 		if err := os.WriteFile(out, data, 0644); err != nil {
@@ -147,12 +162,15 @@ This command evaluates subtitles based on:
 		if dbPath := viper.GetString("db_path"); dbPath != "" {
 			backend := viper.GetString("db_backend")
 			if store, err := database.OpenStore(dbPath, backend); err == nil {
+				matchScore := scanner.NormalizedScore(score.Total)
 				_ = store.InsertDownload(&database.DownloadRecord{
-					File:      out,
-					VideoFile: media,
-					Provider:  "opensubtitles",
-					Language:  lang,
+					File:       out,
+					VideoFile:  media,
+					Provider:   selected.Provider,
+					Language:   lang,
+					MatchScore: &matchScore,
 				})
+				scanner.RecordSubtitle(store, out, media, lang, selected.Provider, enc)
 				store.Close()
 			} else {
 				logger.Warnf("db open: %v", err)
@@ -0,0 +1,130 @@
+// file: cmd/fixencoding.go
+// version: 1.0.1
+// guid: 6b2d9e41-7c3a-4f58-a1e6-0d8f5b3c2a97
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jdfalk/subtitle-manager/pkg/charset"
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/security"
+)
+
+// subtitleExts lists the text subtitle formats fix-encoding inspects. .sub
+// files may also be VobSub images, see isVobSub.
+var subtitleExts = map[string]bool{".srt": true, ".ass": true, ".ssa": true, ".vtt": true, ".sub": true, ".txt": true}
+
+// isVobSub reports whether path is the image half of a VobSub pair, which
+// comes with an .idx file of the same name.
+func isVobSub(path string) bool {
+	ext := filepath.Ext(path)
+	if !strings.EqualFold(ext, ".sub") {
+		return false
+	}
+	_, err := os.Stat(strings.TrimSuffix(path, ext) + ".idx")
+	return err == nil
+}
+
+var fixEncodingCmd = &cobra.Command{
+	Use:   "fix-encoding [path...]",
+	Short: "Convert subtitle files to UTF-8",
+	Long: `Detect the character encoding of subtitle files and rewrite files using
+legacy encodings such as Windows-1251, ISO-8859-7 or GB18030 as UTF-8.
+
+Paths may be files or directories, which are walked recursively. The
+language code in names like movie.el.srt is used as a detection hint unless
+--lang is given. Binary files, such as VobSub .sub images, are skipped.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := logging.GetLogger("fix-encoding")
+		lang, _ := cmd.Flags().GetString("lang")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		var checked, fixed, failed int
+		fix := func(path string) {
+			if isVobSub(path) {
+				return
+			}
+			checked++
+			hint := lang
+			if hint == "" {
+				hint = langFromName(path)
+			}
+			res, changed, err := charset.FixFile(path, hint, dryRun)
+			switch {
+			case errors.Is(err, charset.ErrBinary):
+				checked--
+				logger.Debugf("skipping binary file %s", path)
+			case err != nil:
+				failed++
+				logger.Warnf("%s: %v", path, err)
+			case changed && dryRun:
+				fixed++
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: %s (%.0f%%)\n", path, res.Encoding, res.Confidence*100)
+			case changed:
+				fixed++
+				logger.Infof("converted %s from %s", path, res.Encoding)
+			}
+		}
+		for _, arg := range args {
+			abs, err := filepath.Abs(arg)
+			if err != nil {
+				return err
+			}
+			root, err := security.ValidateAndSanitizePath(abs)
+			if err != nil {
+				return err
+			}
+			err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && subtitleExts[strings.ToLower(filepath.Ext(path))] {
+					fix(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		verb := "converted"
+		if dryRun {
+			verb = "need conversion"
+		}
+		logger.Infof("checked %d subtitle files, %d %s, %d failed", checked, fixed, verb, failed)
+		if failed > 0 {
+			return fmt.Errorf("%d files could not be converted", failed)
+		}
+		return nil
+	},
+}
+
+// langFromName returns the language code in a subtitle name such as
+// movie.el.srt or movie.pt-BR.forced.srt, or an empty string.
+func langFromName(path string) string {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		p := parts[i]
+		if base, _, _ := strings.Cut(p, "-"); len(base) == 2 || len(base) == 3 {
+			if security.ValidateLanguageCode(p) == nil {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func init() {
+	fixEncodingCmd.Flags().String("lang", "", "language code used as detection hint (default from file names)")
+	fixEncodingCmd.Flags().Bool("dry-run", false, "only list files that would be converted")
+	rootCmd.AddCommand(fixEncodingCmd)
+}
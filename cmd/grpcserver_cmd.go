@@ -3,9 +3,17 @@ package cmd
 import (
 	"net"
 
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	enginev1 "github.com/jdfalk/subtitle-manager/pkg/engine/v1"
+	filev1 "github.com/jdfalk/subtitle-manager/pkg/file/v1"
 	"github.com/jdfalk/subtitle-manager/pkg/grpcserver"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/services"
 	pb "github.com/jdfalk/subtitle-manager/pkg/subtitle/translator/v1"
+	webv1 "github.com/jdfalk/subtitle-manager/pkg/web/v1"
 	"github.com/jdfalk/subtitle-manager/pkg/webserver"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -15,11 +23,39 @@ import (
 var grpcAddr string
 
 // grpcServerCmd runs a gRPC translation server using the configured API keys.
+// The engine service for transcription, translation and conversion jobs and
+// the file service for the configured storage backend are served on the same
+// listener. Queued jobs are persisted in the configured store. Calls to the
+// web, engine and file services must carry a session or API key, so these
+// services are only served when the auth database can be opened.
 var grpcServerCmd = &cobra.Command{
 	Use:   "grpc-server",
 	Short: "Run translation gRPC server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		s := grpc.NewServer()
+		logger := logging.GetLogger("grpc-server")
+		db, err := webserver.OpenAuthDatabase()
+		var opts []grpc.ServerOption
+		if err != nil {
+			logger.Warnf("web, engine and file services disabled: authentication unavailable: %v", err)
+		} else {
+			defer db.Close()
+			opts = services.NewAuthInterceptor(db).ServerOptions()
+		}
+		s := grpc.NewServer(opts...)
+
+		// Persist queued jobs in the configured store so they resume after a restart
+		if store, err := database.OpenStoreWithConfig(); err != nil {
+			logger.Warnf("persistent job queue disabled: %v", err)
+		} else {
+			defer store.Close()
+			jobs.SetStore(store)
+			mediahash.SetStore(store)
+			if q, err := queue.StartPersistent(store); err != nil {
+				logger.Warnf("persistent job queue disabled: %v", err)
+			} else {
+				defer q.Stop()
+			}
+		}
 
 		// Create server with persistent config enabled (uses Viper)
 		server := grpcserver.NewServer(
@@ -30,6 +66,13 @@ var grpcServerCmd = &cobra.Command{
 		)
 
 		pb.RegisterTranslatorServiceServer(s, server)
+		// These services write files and start jobs; never serve them
+		// without the auth interceptor.
+		if db != nil {
+			enginev1.RegisterEngineServiceServer(s, services.NewEngineServiceWithConfig(services.EngineConfig{DB: db}))
+			filev1.RegisterFileServiceServer(s, services.NewFileService())
+			webv1.RegisterWebServiceServer(s, services.NewWebServiceWithConfig(services.WebConfig{DB: db}))
+		}
 
 		if err := webserver.InitializeHealth(""); err == nil {
 			if provider := webserver.GetHealthProvider(); provider != nil {
@@ -40,7 +83,7 @@ var grpcServerCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		logging.GetLogger("grpc-server").Infof("listening on %s", grpcAddr)
+		logger.Infof("listening on %s", grpcAddr)
 		return s.Serve(lis)
 	},
 }
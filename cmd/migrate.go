@@ -1,24 +1,36 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/spf13/cobra"
 )
 
-// migrateCmd migrates subtitle history between database backends.
+// migrateCmd migrates every stored entity between database backends.
 var migrateCmd = &cobra.Command{
 	Use:   "migrate <src-backend> <src-path> <dest-backend> <dest-path>",
-	Short: "Migrate subtitles between database backends",
-	Args:  cobra.RangeArgs(2, 4),
+	Short: "Migrate all data between database backends",
+	Long: `Copy subtitle history, downloads, media items, users with their sessions,
+API keys and login tokens, tags, language profiles, monitored items, subtitle
+sources, scores, queue jobs and dashboard layouts from one backend to another.
+
+Record IDs are preserved where the destination allows. Afterwards the record
+counts of both stores are compared and a verification report is printed.
+With --dry-run only the counts are compared and nothing is written.
+
+With two arguments the source is a SQLite file and the destination a Pebble
+directory.`,
+	Args: cobra.RangeArgs(2, 4),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) == 2 {
-			// Backwards compatibility: sqlite to pebble
-			return database.MigrateToPebble(args[0], args[1])
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if len(args) == 3 {
+			return fmt.Errorf("expected 2 or 4 arguments, got 3")
+		}
+		srcBackend, srcPath, destBackend, destPath := "sqlite", args[0], "pebble", args[1]
+		if len(args) == 4 {
+			srcBackend, srcPath, destBackend, destPath = args[0], args[1], args[2], args[3]
 		}
-		srcBackend := args[0]
-		srcPath := args[1]
-		destBackend := args[2]
-		destPath := args[3]
 
 		src, err := database.OpenStore(srcPath, srcBackend)
 		if err != nil {
@@ -32,13 +44,24 @@ var migrateCmd = &cobra.Command{
 		}
 		defer dest.Close()
 
-		if err := database.Migrate(src, dest); err != nil {
+		report, err := database.MigrateWithReport(src, dest, database.MigrateOptions{DryRun: dryRun})
+		if report != nil {
+			cmd.Print(report.String())
+		}
+		if err != nil {
 			return err
 		}
 
+		if dryRun {
+			cmd.Printf("Dry run: nothing was written to %s:%s\n", destBackend, destPath)
+			return nil
+		}
 		cmd.Printf("Migrated data from %s:%s to %s:%s\n", srcBackend, srcPath, destBackend, destPath)
 		return nil
 	},
 }
 
-func init() { rootCmd.AddCommand(migrateCmd) }
+func init() {
+	migrateCmd.Flags().Bool("dry-run", false, "compare record counts without writing")
+	rootCmd.AddCommand(migrateCmd)
+}
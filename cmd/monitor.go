@@ -1,5 +1,5 @@
 // file: cmd/monitor.go
-// version: 1.1.0
+// version: 1.3.1
 // guid: 12345678-1234-1234-1234-123456789014
 
 package cmd
@@ -7,6 +7,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -14,8 +15,10 @@ import (
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/monitoring"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
 	"github.com/jdfalk/subtitle-manager/pkg/radarr"
 	"github.com/jdfalk/subtitle-manager/pkg/sonarr"
+	"github.com/jdfalk/subtitle-manager/pkg/tagging"
 )
 
 var monitorCmd = &cobra.Command{
@@ -90,6 +93,8 @@ var (
 	monitorQualityCheck bool
 	monitorForceRefresh bool
 	monitorSource       string
+	monitorProviderTags []string
+	monitorWorkers      int
 )
 
 func init() {
@@ -109,6 +114,8 @@ func init() {
 	monitorStartCmd.Flags().StringVar(&monitorInterval, "interval", "1h", "Monitoring interval (e.g. 30m, 1h, 2h)")
 	monitorStartCmd.Flags().IntVar(&monitorMaxRetries, "max-retries", 3, "Maximum retry attempts per item")
 	monitorStartCmd.Flags().BoolVar(&monitorQualityCheck, "quality-check", true, "Enable quality upgrade monitoring")
+	monitorStartCmd.Flags().IntVar(&monitorWorkers, "workers", 0, "Items processed concurrently (default monitor.workers)")
+	monitorStartCmd.Flags().StringSliceVar(&monitorProviderTags, "tags", nil, "Only search provider instances with all these tags")
 
 	// Sync command flags
 	monitorSyncCmd.Flags().StringSliceVar(&monitorLanguages, "languages", []string{"en"}, "Languages to monitor (comma-separated)")
@@ -139,6 +146,7 @@ func runMonitorStart(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
 	defer store.Close()
+	mediahash.SetStore(store)
 
 	// Create Sonarr client if configured
 	var sonarrClient *sonarr.Client
@@ -161,6 +169,17 @@ func runMonitorStart(cmd *cobra.Command, args []string) error {
 		monitorMaxRetries,
 		monitorQualityCheck,
 	)
+	if len(monitorProviderTags) > 0 {
+		sqlStore, ok := store.(*database.SQLStore)
+		if !ok {
+			return fmt.Errorf("provider tags require the sqlite backend")
+		}
+		monitor.SetProviderTags(tagging.NewTagManager(sqlStore.DB()), monitorProviderTags)
+	}
+	configureMonitorCycles(monitor)
+	if monitorWorkers > 0 {
+		monitor.SetWorkers(monitorWorkers)
+	}
 
 	fmt.Printf("Starting monitoring daemon (interval: %v)\n", interval)
 
@@ -176,6 +195,7 @@ func runMonitorSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
 	defer store.Close()
+	mediahash.SetStore(store)
 
 	// Create Sonarr client if configured
 	var sonarrClient *sonarr.Client
@@ -289,6 +309,7 @@ func runMonitorStatus(cmd *cobra.Command, args []string) error {
 		store,
 		0, false, // Not used for status
 	)
+	configureMonitorCycles(monitor)
 
 	// Get stats
 	stats, err := monitor.GetMonitoringStats()
@@ -304,6 +325,18 @@ func runMonitorStatus(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Found:          %d\n", stats.Found)
 	fmt.Printf("  Failed:         %d\n", stats.Failed)
 	fmt.Printf("  Blacklisted:    %d\n", stats.Blacklisted)
+	if stats.CycleTotal > 0 {
+		state := "running"
+		if stats.CycleComplete {
+			state = "complete"
+		}
+		fmt.Printf("\nCycle (%s, started %s):\n", state, stats.CycleStarted.Format(time.RFC3339))
+		fmt.Printf("  Processed:      %d/%d\n", stats.CycleProcessed, stats.CycleTotal)
+		fmt.Printf("  Throughput:     %.1f items/min\n", stats.Throughput)
+		if !stats.CycleComplete {
+			fmt.Printf("  ETA:            %v\n", stats.ETA)
+		}
+	}
 
 	return nil
 }
@@ -412,3 +445,27 @@ func runMonitorBlacklistRemove(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Removed item %s from blacklist\n", itemID)
 	return nil
 }
+
+// configureMonitorCycles applies the worker count, provider limits and
+// cycle state file from the configuration to monitor.
+func configureMonitorCycles(monitor *monitoring.EpisodeMonitor) {
+	monitor.SetWorkers(viper.GetInt("monitor.workers"))
+
+	defaults := monitoring.ProviderLimits{
+		Concurrency: viper.GetInt("monitor.provider_concurrency"),
+		Rate:        viper.GetFloat64("monitor.provider_rate"),
+		Burst:       viper.GetInt("monitor.provider_burst"),
+	}
+	var overrides map[string]monitoring.ProviderLimits
+	if err := viper.UnmarshalKey("monitor.provider_limits", &overrides); err != nil {
+		fmt.Printf("Ignoring invalid monitor.provider_limits: %v\n", err)
+		overrides = nil
+	}
+	monitor.SetProviderLimits(defaults, overrides)
+
+	stateFile := viper.GetString("monitor.state_file")
+	if stateFile == "" {
+		stateFile = filepath.Join(filepath.Dir(viper.GetString("db_path")), "monitor_state.json")
+	}
+	monitor.SetStateFile(stateFile)
+}
@@ -1,5 +1,5 @@
 // file: cmd/root.go
-// version: 1.0.2
+// version: 1.0.7
 // guid: 537af48f-4b60-44b5-a4a1-76a2616b9ccb
 // Package cmd implements the CLI commands for subtitle-manager.
 // It provides the root command and subcommands for all user-facing operations.
@@ -36,8 +36,10 @@ import (
 	gconfig "github.com/jdfalk/subtitle-manager/pkg/gcommon/config"
 	"github.com/jdfalk/subtitle-manager/pkg/i18n"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/monitoring"
 	"github.com/jdfalk/subtitle-manager/pkg/transcriber"
 	"github.com/jdfalk/subtitle-manager/pkg/translator"
+	"github.com/jdfalk/subtitle-manager/pkg/watcher"
 )
 
 var cfgFile string
@@ -144,6 +146,10 @@ func init() {
 		rootCmd.PersistentFlags().String("anticaptcha-api-url", "", "Anti-Captcha API base URL")
 		viper.BindPFlag("anticaptcha.api_url", rootCmd.PersistentFlags().Lookup("anticaptcha-api-url"))
 
+		// FlareSolverr instance solving Cloudflare challenges for scraping providers
+		rootCmd.PersistentFlags().String("flaresolverr-url", "", "FlareSolverr URL for Cloudflare-protected providers")
+		viper.BindPFlag("flaresolverr.url", rootCmd.PersistentFlags().Lookup("flaresolverr-url"))
+
 		// Cache configuration flags
 		// These are kept as persistent flags so all subcommands
 		// share the same cache settings.
@@ -244,8 +250,20 @@ func initConfig() {
 	viper.SetDefault("ffmpeg_path", "ffmpeg")
 	viper.SetDefault("batch_workers", 4)
 	viper.SetDefault("scan_workers", 4)
+	viper.SetDefault("scoring.upgrade_margin", 5)
 	viper.SetDefault("queue.provider", "memory")
 	viper.SetDefault("queue.workers", 3)
+	viper.SetDefault("monitor.workers", monitoring.DefaultWorkers)
+	viper.SetDefault("monitor.provider_concurrency", monitoring.DefaultProviderLimits.Concurrency)
+	viper.SetDefault("monitor.provider_rate", monitoring.DefaultProviderLimits.Rate)
+	viper.SetDefault("monitor.provider_burst", monitoring.DefaultProviderLimits.Burst)
+	viper.SetDefault("monitor.state_file", "")
+	viper.SetDefault("watch.stable_period", watcher.DefaultStablePeriod)
+	viper.SetDefault("watch.mode", watcher.ModeAuto)
+	viper.SetDefault("watch.poll_interval", watcher.DefaultPollInterval)
+	viper.SetDefault("embedded.enabled", true)
+	viper.SetDefault("embedded.ignore_image", false)
+	viper.SetDefault("embedded.extract", false)
 	viper.SetDefault("google_api_url", "https://translation.googleapis.com/language/translate/v2")
 	viper.SetDefault("openai_model", "gpt-3.5-turbo")
 	viper.SetDefault("openai_api_url", "https://api.openai.com/v1")
@@ -253,6 +271,8 @@ func initConfig() {
 	viper.SetDefault("opensubtitles.user_agent", "github.com/jdfalk/subtitle-manager/0.1")
 	viper.SetDefault("anticaptcha.api_key", "")
 	viper.SetDefault("anticaptcha.api_url", "https://api.anti-captcha.com")
+	viper.SetDefault("flaresolverr.url", "")
+	viper.SetDefault("flaresolverr.timeout", "60s")
 	viper.SetDefault("providers.generic.api_url", "")
 	viper.SetDefault("providers.generic.username", "")
 	viper.SetDefault("providers.generic.password", "")
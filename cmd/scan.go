@@ -2,22 +2,27 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/jdfalk/subtitle-manager/pkg/cli"
 	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
 	"github.com/jdfalk/subtitle-manager/pkg/i18n"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
 	"github.com/jdfalk/subtitle-manager/pkg/scanner"
 	"github.com/jdfalk/subtitle-manager/pkg/security"
 )
 
-var upgrade bool
+var (
+	upgrade  bool
+	fullScan bool
+)
 
 // scanCmd scans a directory for video files and downloads subtitles.
 var scanCmd = &cobra.Command{
@@ -50,6 +55,7 @@ var scanCmd = &cobra.Command{
 			if s, err := database.OpenStore(dbPath, backend); err == nil {
 				store = s
 				defer s.Close()
+				mediahash.SetStore(s)
 			} else {
 				logger.Warnf(i18n.T("common.error.db_open"), err)
 			}
@@ -57,23 +63,12 @@ var scanCmd = &cobra.Command{
 		workers := viper.GetInt("scan_workers")
 
 		// Count video files for progress tracking
-		videoExtensions := []string{".mkv", ".mp4", ".avi", ".mov"}
-		isVideoFile := func(path string) bool {
-			ext := strings.ToLower(filepath.Ext(path))
-			for _, e := range videoExtensions {
-				if ext == e {
-					return true
-				}
-			}
-			return false
-		}
-
 		var videoFiles []string
 		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
-			if !info.IsDir() && isVideoFile(path) {
+			if !info.IsDir() && fileindex.IsVideo(path) {
 				videoFiles = append(videoFiles, path)
 			}
 			return nil
@@ -91,11 +86,20 @@ var scanCmd = &cobra.Command{
 			progress.Update(file)
 		}
 
-		return scanner.ScanDirectoryProgress(ctx, dir, lang, "", nil, upgrade, workers, store, progressCallback)
+		summary, err := scanner.ScanDirectoryIndexed(ctx, dir, lang, "", nil, upgrade, fullScan, workers, store, progressCallback)
+		if err != nil {
+			return err
+		}
+		logger.Infof("scan complete: %s", summary)
+		if summary.Failed > 0 {
+			return fmt.Errorf("%d of the scanned files failed", summary.Failed)
+		}
+		return nil
 	},
 }
 
 func init() {
 	scanCmd.Flags().BoolVarP(&upgrade, "upgrade", "u", false, "replace existing subtitles")
+	scanCmd.Flags().BoolVar(&fullScan, "full", false, "re-evaluate files unchanged since the last scan")
 	rootCmd.AddCommand(scanCmd)
 }
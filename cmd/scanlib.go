@@ -59,10 +59,17 @@ var scanLibCmd = &cobra.Command{
 			progress.Update(file)
 		}
 
-		return metadata.ScanLibraryProgress(context.Background(), dir, store, progressCallback)
+		full, _ := cmd.Flags().GetBool("full")
+		summary, err := metadata.ScanLibraryIndexed(context.Background(), dir, store, full, progressCallback)
+		if err != nil {
+			return err
+		}
+		logger.Infof("scan complete: %s", summary)
+		return nil
 	},
 }
 
 func init() {
+	scanLibCmd.Flags().Bool("full", false, "re-parse files unchanged since the last scan")
 	rootCmd.AddCommand(scanLibCmd)
 }
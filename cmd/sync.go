@@ -22,6 +22,12 @@ var (
 	syncTranslate        bool
 	syncTranslateLang    string
 	syncTranslateService string
+	syncAudioMethod      string
+	syncMaxOffset        time.Duration
+	syncMode             string
+	syncMatchTolerance   time.Duration
+	syncSplitPenalty     float64
+	syncMaxSegments      int
 )
 
 // syncCmd aligns an external subtitle file with a media file.
@@ -32,9 +38,14 @@ var syncCmd = &cobra.Command{
 
 The sync command can use multiple reference sources for timing alignment:
 - Audio transcription via Whisper API (--use-audio)
+- Offline voice activity detection, no API key needed (--use-audio --audio-method vad)
 - Embedded subtitle tracks from the media file (--use-embedded)
 - Combination of both with weighted averaging (--audio-weight)
 
+By default a single constant offset is applied. Use --mode piecewise to align
+every cue and correct framerate drift or cut-scene edits with a
+piecewise-linear time map.
+
 Examples:
   # Sync using embedded subtitles only
   subtitle-manager sync movie.mkv subs.srt output.srt --use-embedded
@@ -42,9 +53,15 @@ Examples:
   # Sync using audio transcription only
   subtitle-manager sync movie.mkv subs.srt output.srt --use-audio
 
+  # Sync offline against detected speech (works on air-gapped servers)
+  subtitle-manager sync movie.mkv subs.srt output.srt --use-audio --audio-method vad
+
   # Sync using both with 70% audio, 30% embedded weighting
   subtitle-manager sync movie.mkv subs.srt output.srt --use-audio --use-embedded --audio-weight 0.7
 
+  # Correct PAL/NTSC drift and split points against an embedded track
+  subtitle-manager sync movie.mkv subs.srt output.srt --use-embedded --mode piecewise
+
   # Sync with translation to Spanish
   subtitle-manager sync movie.mkv subs.srt output.srt --use-audio --translate --translate-lang es`,
 	Args: cobra.ExactArgs(3),
@@ -73,6 +90,8 @@ Examples:
 			UseAudio:         syncUseAudio,
 			UseEmbedded:      syncUseEmbedded,
 			AudioTrack:       syncAudioTrack,
+			AudioMethod:      syncAudioMethod,
+			MaxOffset:        syncMaxOffset,
 			SubtitleTracks:   syncSubtitleTracks,
 			WhisperKey:       viper.GetString("openai_api_key"),
 			AudioWeight:      syncAudioWeight,
@@ -86,11 +105,17 @@ Examples:
 			Service:          viper.GetString("translate_service"),
 			GoogleKey:        viper.GetString("google_api_key"),
 			GPTKey:           viper.GetString("openai_api_key"),
+			Mode:             syncMode,
+			MatchTolerance:   syncMatchTolerance,
+			SplitPenalty:     syncSplitPenalty,
+			MaxSegments:      syncMaxSegments,
 		}
 
 		// Log sync method configuration
 		if opts.UseAudio && opts.UseEmbedded {
 			logger.Infof("sync method: combined (audio + embedded, weight=%.1f)", opts.AudioWeight)
+		} else if opts.UseAudio && opts.AudioMethod == syncer.AudioMethodVAD {
+			logger.Infof("sync method: voice activity detection only")
 		} else if opts.UseAudio {
 			logger.Infof("sync method: audio transcription only")
 		} else if opts.UseEmbedded {
@@ -108,7 +133,7 @@ Examples:
 		}
 
 		start := time.Now()
-		items, err := syncer.Sync(string(media), string(subPath), opts)
+		items, report, err := syncer.SyncWithReport(string(media), string(subPath), opts)
 		if err != nil {
 			logger.Errorf("synchronization failed: %v", err)
 			return err
@@ -140,6 +165,19 @@ Examples:
 			logger.Infof("✅ synchronized %s -> %s", subPath, out)
 		}
 		logger.Infof("subtitle items: %d", len(items))
+		if report.Mode == syncer.ModePiecewise {
+			logger.Infof("reference: %s", report.Reference)
+			logger.Infof("segments: %d", len(report.Segments))
+			for i, seg := range report.Segments {
+				logger.Infof("  [%d] cues %d-%d (%v-%v): scale %.5f, offset %v, matched %d",
+					i+1, seg.FirstCue+1, seg.LastCue+1, seg.Start, seg.End, seg.Scale, seg.Offset, seg.Matched)
+			}
+		} else {
+			if report.Scale != 1 {
+				logger.Infof("framerate scale: %.5f", report.Scale)
+			}
+			logger.Infof("offset: %v", report.Offset)
+		}
 		logger.Infof("sync duration: %v", syncDuration)
 		logger.Infof("total duration: %v", totalDuration)
 		return nil
@@ -150,6 +188,8 @@ func init() {
 	// Audio transcription options
 	syncCmd.Flags().BoolVar(&syncUseAudio, "use-audio", false, "Use audio transcription for sync reference")
 	syncCmd.Flags().IntVar(&syncAudioTrack, "audio-track", 0, "Audio track index to use for transcription (default: 0)")
+	syncCmd.Flags().StringVar(&syncAudioMethod, "audio-method", syncer.AudioMethodWhisper, "Audio alignment method ('whisper' or 'vad')")
+	syncCmd.Flags().DurationVar(&syncMaxOffset, "max-offset", 0, "Maximum offset searched by the vad audio method (default 60s)")
 
 	// Embedded subtitle options
 	syncCmd.Flags().BoolVar(&syncUseEmbedded, "use-embedded", false, "Use embedded subtitles for sync reference")
@@ -158,6 +198,12 @@ func init() {
 	// Weighting and advanced options
 	syncCmd.Flags().Float64Var(&syncAudioWeight, "audio-weight", 0.7, "Weight for audio transcription vs embedded subtitles (0.0-1.0)")
 
+	// Alignment mode options
+	syncCmd.Flags().StringVar(&syncMode, "mode", syncer.ModeOffset, "Alignment mode ('offset' or 'piecewise')")
+	syncCmd.Flags().DurationVar(&syncMatchTolerance, "match-tolerance", 0, "Maximum cue distance counted as a match in piecewise mode (default 1s)")
+	syncCmd.Flags().Float64Var(&syncSplitPenalty, "split-penalty", 0, "Cost of starting a new segment in piecewise mode (default 4)")
+	syncCmd.Flags().IntVar(&syncMaxSegments, "max-segments", 0, "Maximum number of segments in piecewise mode (default 8)")
+
 	// Translation integration
 	syncCmd.Flags().BoolVar(&syncTranslate, "translate", false, "Translate subtitles after synchronization")
 	syncCmd.Flags().StringVar(&syncTranslateLang, "translate-lang", "", "Target language for translation (e.g., 'es', 'fr', 'de')")
@@ -1,29 +1,35 @@
 package cmd
 
 import (
-	"os"
+	"context"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/transcriber"
 )
 
-// transcribeCmd generates subtitles from audio using the Whisper API.
+// transcribeCmd generates subtitles from audio using the Whisper API. Local
+// paths are used as given rather than being limited to the allowed media
+// directories.
 var transcribeCmd = &cobra.Command{
 	Use:   "transcribe [media] [output] [lang]",
 	Short: "Transcribe media to subtitles",
 	Args:  cobra.ExactArgs(3),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := logging.GetLogger("transcribe")
-		media, out, lang := args[0], args[1], args[2]
-		key := viper.GetString("openai_api_key")
-		data, err := transcriber.WhisperTranscribe(media, lang, key)
+		media, err := filepath.Abs(args[0])
 		if err != nil {
 			return err
 		}
-		if err := os.WriteFile(out, data, 0644); err != nil {
+		out, err := filepath.Abs(args[1])
+		if err != nil {
+			return err
+		}
+		lang := args[2]
+		if err := jobs.Transcribe(context.Background(), transcriber.MethodOpenAI, media, out, lang); err != nil {
 			return err
 		}
 		logger.Infof("transcribed %s to %s", media, out)
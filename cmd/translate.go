@@ -45,7 +45,7 @@ var translateCmd = &cobra.Command{
 				defer q.Stop() // Clean up when command finishes
 			}
 
-			job := queue.NewSingleFileJob(in, out, lang, service, gKey, gptKey, grpcAddr)
+			job := queue.NewSingleFileJob(in, out, lang, service, grpcAddr)
 			taskID, err := q.Add(job)
 			if err != nil {
 				return fmt.Errorf("failed to queue translation job: %w", err)
@@ -83,7 +83,7 @@ var translateCmd = &cobra.Command{
 		}
 
 		// Synchronous execution (existing behavior)
-		if err := subtitles.TranslateFileToSRT(in, out, lang, service, gKey, gptKey, grpcAddr); err != nil {
+		if err := subtitles.TranslateFile(in, out, lang, service, gKey, gptKey, grpcAddr); err != nil {
 			return err
 		}
 		if dbPath := viper.GetString("db_path"); dbPath != "" {
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
@@ -41,5 +42,9 @@ var watchCmd = &cobra.Command{
 
 func init() {
 	watchCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "watch directories recursively")
+	watchCmd.Flags().String("mode", watcher.ModeAuto, "watch mode: auto polls network and FUSE mounts, fsnotify or poll")
+	watchCmd.Flags().Duration("poll-interval", watcher.DefaultPollInterval, "interval between directory listings when polling")
+	viper.BindPFlag("watch.mode", watchCmd.Flags().Lookup("mode"))
+	viper.BindPFlag("watch.poll_interval", watchCmd.Flags().Lookup("poll-interval"))
 	rootCmd.AddCommand(watchCmd)
 }
@@ -0,0 +1,359 @@
+// file: pkg/archive/archive.go
+// version: 1.0.0
+// guid: 9c4e2a71-5b8d-4f36-a0e7-3d1f6b8c2e54
+
+// Package archive unpacks subtitle archives served by providers.
+//
+// Many providers deliver ZIP, RAR or 7z archives, often whole season packs,
+// instead of a single subtitle file. Extract detects such archives, lists
+// their entries and returns the subtitle that best matches the media file's
+// season, episode and release. Archives are processed in memory: entry
+// names are never used as file system paths, entries escaping the archive
+// root are ignored and decompressed sizes are capped to defuse zip bombs.
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/nwaples/rardecode/v2"
+
+	"github.com/jdfalk/subtitle-manager/pkg/scoring"
+)
+
+// Format identifies an archive container.
+type Format string
+
+const (
+	// Zip is a PKZIP archive.
+	Zip Format = "zip"
+	// RAR is a RAR 1.5-5.0 archive.
+	RAR Format = "rar"
+	// SevenZip is a 7z archive.
+	SevenZip Format = "7z"
+)
+
+const (
+	// MaxEntries is the largest number of entries read from an archive.
+	MaxEntries = 1000
+	// MaxEntrySize is the largest decompressed subtitle accepted. Real
+	// subtitle files are well below a megabyte.
+	MaxEntrySize = 16 << 20
+	// maxRatio is the largest compression ratio accepted for entries over
+	// a megabyte. Text subtitles compress roughly 3-10x.
+	maxRatio = 100
+)
+
+var (
+	// ErrNoSubtitles is returned for archives without subtitle entries.
+	ErrNoSubtitles = errors.New("archive contains no subtitle files")
+	// ErrNoMatch is returned when an archive only holds subtitles for
+	// other episodes.
+	ErrNoMatch = errors.New("archive contains no subtitle for this episode")
+	// ErrTooLarge is returned when an archive exceeds MaxEntries or an entry
+	// exceeds MaxEntrySize or the compression ratio limit.
+	ErrTooLarge = errors.New("archive exceeds size limits")
+)
+
+var magic = []struct {
+	format Format
+	prefix []byte
+}{
+	{Zip, []byte("PK\x03\x04")},
+	{Zip, []byte("PK\x05\x06")},
+	{RAR, []byte("Rar!\x1a\x07")},
+	{SevenZip, []byte("7z\xbc\xaf\x27\x1c")},
+}
+
+// subtitleExts ranks the subtitle formats that may be picked from an
+// archive; higher values win ties between otherwise equal entries.
+var subtitleExts = map[string]int{
+	".srt":  4,
+	".ass":  3,
+	".ssa":  3,
+	".vtt":  2,
+	".ttml": 1,
+	".dfxp": 1,
+	".sub":  1,
+	".smi":  1,
+}
+
+// Entry describes a file inside an archive.
+type Entry struct {
+	// Name is the slash separated path inside the archive.
+	Name string
+	// Size is the decompressed size in bytes, or -1 when unknown.
+	Size int64
+	// Packed is the compressed size in bytes, or -1 when unknown.
+	Packed int64
+}
+
+// Detect returns the archive format of data, or an empty Format when data is
+// not a supported archive.
+func Detect(data []byte) Format {
+	for _, m := range magic {
+		if bytes.HasPrefix(data, m.prefix) {
+			return m.format
+		}
+	}
+	return ""
+}
+
+// List returns the regular files in the archive data. Entries whose names
+// are absolute or climb out of the archive root are omitted.
+func List(data []byte) ([]Entry, error) {
+	var entries []Entry
+	err := walk(data, func(e Entry, _ func() (io.Reader, error)) (bool, error) {
+		entries = append(entries, e)
+		return false, nil
+	})
+	return entries, err
+}
+
+// Extract returns the subtitle in data that best matches mediaPath along
+// with its name inside the archive. Data that is not an archive is returned
+// unchanged with an empty name.
+func Extract(data []byte, mediaPath string) ([]byte, string, error) {
+	if Detect(data) == "" {
+		return data, "", nil
+	}
+	entries, err := List(data)
+	if err != nil {
+		return nil, "", err
+	}
+	best, err := Select(entries, mediaPath)
+	if err != nil {
+		return nil, "", err
+	}
+	var out []byte
+	err = walk(data, func(e Entry, open func() (io.Reader, error)) (bool, error) {
+		if e.Name != best.Name {
+			return false, nil
+		}
+		r, err := open()
+		if err != nil {
+			return true, err
+		}
+		out, err = readLimited(r)
+		return true, err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("extract %s: %w", best.Name, err)
+	}
+	return out, best.Name, nil
+}
+
+// Select picks the subtitle entry best matching mediaPath. For episodes,
+// entries naming a different season or episode are never chosen. Remaining
+// entries are ranked by episode match, release group, source, resolution
+// and codec, then by subtitle format; ties keep archive order.
+func Select(entries []Entry, mediaPath string) (Entry, error) {
+	media := scoring.FromMediaPath(mediaPath)
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[strings.ToLower(e.Name)] = true
+	}
+
+	type ranked struct {
+		entry Entry
+		rank  int
+	}
+	var subs int
+	var cands []ranked
+	for _, e := range entries {
+		lower := strings.ToLower(e.Name)
+		ext := path.Ext(lower)
+		weight, ok := subtitleExts[ext]
+		if !ok {
+			continue
+		}
+		if ext == ".sub" && names[strings.TrimSuffix(lower, ext)+".idx"] {
+			// VobSub bitmap subtitles cannot be used as text.
+			continue
+		}
+		subs++
+		info := scoring.FromMediaPath(path.Base(e.Name))
+		rank := weight
+		if media.Season > 0 && info.Season > 0 {
+			if info.Season != media.Season || info.Episode != media.Episode {
+				continue
+			}
+			rank += 100
+		}
+		rank += matchRank(media, info)
+		cands = append(cands, ranked{e, rank})
+	}
+	if len(cands) == 0 {
+		if subs > 0 {
+			return Entry{}, fmt.Errorf("%w: S%02dE%02d", ErrNoMatch, media.Season, media.Episode)
+		}
+		return Entry{}, ErrNoSubtitles
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].rank > cands[j].rank })
+	return cands[0].entry, nil
+}
+
+// matchRank scores how closely the release information parsed from an entry
+// name matches the media file.
+func matchRank(media, info scoring.MediaItem) int {
+	rank := 0
+	if media.ReleaseGroup != "" && strings.EqualFold(media.ReleaseGroup, info.ReleaseGroup) {
+		rank += 40
+	}
+	if media.Source != "" && media.Source == info.Source {
+		rank += 20
+	}
+	if media.Resolution != "" && media.Resolution == info.Resolution {
+		rank += 10
+	}
+	if media.Codec != "" && media.Codec == info.Codec {
+		rank += 5
+	}
+	return rank
+}
+
+// walk calls fn for every safe regular file in the archive until fn returns
+// true. open returns a reader for the entry's contents and is only valid
+// during the call.
+func walk(data []byte, fn func(e Entry, open func() (io.Reader, error)) (bool, error)) error {
+	var count int
+	visit := func(e Entry, open func() (io.Reader, error)) (bool, error) {
+		if count++; count > MaxEntries {
+			return true, fmt.Errorf("%w: more than %d entries", ErrTooLarge, MaxEntries)
+		}
+		name, ok := safeName(e.Name)
+		if !ok {
+			return false, nil
+		}
+		e.Name = name
+		return fn(e, open)
+	}
+
+	switch Detect(data) {
+	case Zip:
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			e := Entry{Name: f.Name, Size: int64(f.UncompressedSize64), Packed: int64(f.CompressedSize64)}
+			done, err := visit(e, func() (io.Reader, error) {
+				if err := checkSize(e); err != nil {
+					return nil, err
+				}
+				return f.Open()
+			})
+			if done || err != nil {
+				return err
+			}
+		}
+		return nil
+	case RAR:
+		rr, err := rardecode.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for {
+			h, err := rr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if h.IsDir {
+				continue
+			}
+			e := Entry{Name: h.Name, Size: h.UnPackedSize, Packed: h.PackedSize}
+			if h.UnKnownSize {
+				e.Size = -1
+			}
+			done, err := visit(e, func() (io.Reader, error) {
+				if err := checkSize(e); err != nil {
+					return nil, err
+				}
+				return rr, nil
+			})
+			if done || err != nil {
+				return err
+			}
+		}
+	case SevenZip:
+		zr, err := sevenzip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			e := Entry{Name: f.Name, Size: int64(f.UncompressedSize), Packed: -1}
+			var rc io.ReadCloser
+			done, err := visit(e, func() (io.Reader, error) {
+				if err := checkSize(e); err != nil {
+					return nil, err
+				}
+				var err error
+				rc, err = f.Open()
+				return rc, err
+			})
+			if rc != nil {
+				rc.Close()
+			}
+			if done || err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("not a supported archive")
+}
+
+// safeName normalizes an entry name to a slash separated relative path and
+// reports whether it stays inside the archive root.
+func safeName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, ":") {
+		return "", false
+	}
+	clean := path.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return clean, true
+}
+
+// checkSize rejects entries whose declared size or compression ratio is
+// implausible for a subtitle.
+func checkSize(e Entry) error {
+	if e.Size > MaxEntrySize {
+		return fmt.Errorf("%w: %s is %d bytes", ErrTooLarge, e.Name, e.Size)
+	}
+	if e.Size > 1<<20 && e.Packed > 0 && e.Size/e.Packed > maxRatio {
+		return fmt.Errorf("%w: %s compression ratio %d", ErrTooLarge, e.Name, e.Size/e.Packed)
+	}
+	return nil
+}
+
+// readLimited reads r up to MaxEntrySize bytes, failing when the entry turns
+// out to be larger than declared.
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxEntrySize {
+		return nil, fmt.Errorf("%w: entry exceeds %d bytes", ErrTooLarge, MaxEntrySize)
+	}
+	return data, nil
+}
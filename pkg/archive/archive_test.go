@@ -0,0 +1,108 @@
+// file: pkg/archive/archive_test.go
+// version: 1.0.0
+// guid: 1e7b3d95-8c2a-4f60-b4d1-6a9e0c5f2b83
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// makeZip builds a ZIP archive holding files in the given order.
+func makeZip(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	cases := map[string]Format{
+		"PK\x03\x04rest":         Zip,
+		"Rar!\x1a\x07\x01\x00":   RAR,
+		"7z\xbc\xaf\x27\x1cdata": SevenZip,
+		"1\n00:00:01,000 --> ":   "",
+	}
+	for data, want := range cases {
+		if got := Detect([]byte(data)); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
+
+func TestExtractPlainSubtitle(t *testing.T) {
+	data := []byte("1\n00:00:01,000 --> 00:00:02,000\nHi\n")
+	out, name, err := Extract(data, "/tv/Show.S01E02.mkv")
+	if err != nil || name != "" || !bytes.Equal(out, data) {
+		t.Fatalf("got %q %q %v", out, name, err)
+	}
+}
+
+func TestExtractSeasonPack(t *testing.T) {
+	data := makeZip(t,
+		"readme.txt", "downloaded from example.org",
+		"Show.S01E01.720p.HDTV.x264-AAA.srt", "e1",
+		"Show.S01E02.720p.HDTV.x264-AAA.srt", "e2 hdtv",
+		"Show.S01E02.1080p.WEB-DL.x264-BBB.srt", "e2 web",
+		"Show.S01E03.720p.HDTV.x264-AAA.srt", "e3",
+	)
+	out, name, err := Extract(data, "/tv/Show/Show.S01E02.1080p.WEB-DL.x264-BBB.mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Show.S01E02.1080p.WEB-DL.x264-BBB.srt" || string(out) != "e2 web" {
+		t.Fatalf("got %s: %q", name, out)
+	}
+
+	out, _, err = Extract(data, "/tv/Show/Show.2x05.mkv")
+	if !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %q %v", out, err)
+	}
+}
+
+func TestExtractSingleMovieSubtitle(t *testing.T) {
+	data := makeZip(t,
+		"Movie.2020.BluRay/Movie.2020.BluRay.idx", "idx",
+		"Movie.2020.BluRay/Movie.2020.BluRay.sub", "vobsub",
+		"Movie.2020.BluRay/Movie.2020.BluRay.ass", "[Script Info]",
+	)
+	out, name, err := Extract(data, "/movies/Movie.2020.1080p.BluRay.mkv")
+	if err != nil || name != "Movie.2020.BluRay/Movie.2020.BluRay.ass" || string(out) != "[Script Info]" {
+		t.Fatalf("got %s %q %v", name, out, err)
+	}
+}
+
+func TestExtractRejectsArchives(t *testing.T) {
+	if _, _, err := Extract(makeZip(t, "info.nfo", "x", "cover.jpg", "y"), "/m/Movie.mkv"); !errors.Is(err, ErrNoSubtitles) {
+		t.Fatalf("expected ErrNoSubtitles, got %v", err)
+	}
+
+	traversal := makeZip(t, "../../etc/evil.srt", "x", "/abs/evil.srt", "y", "C:/win/evil.srt", "z")
+	if entries, err := List(traversal); err != nil || len(entries) != 0 {
+		t.Fatalf("unsafe entries listed: %v %v", entries, err)
+	}
+	if _, _, err := Extract(traversal, "/m/Movie.mkv"); !errors.Is(err, ErrNoSubtitles) {
+		t.Fatalf("expected ErrNoSubtitles, got %v", err)
+	}
+
+	bomb := makeZip(t, "Movie.srt", strings.Repeat("0", 4<<20))
+	if _, _, err := Extract(bomb, "/m/Movie.mkv"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// file: pkg/audio/vad.go
+
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// DefaultVADFrame is the analysis frame length used when VADOptions.Frame is
+// zero.
+const DefaultVADFrame = 10 * time.Millisecond
+
+// VADOptions tunes voice activity detection.
+type VADOptions struct {
+	// Frame is the duration of each analysis frame. When zero,
+	// DefaultVADFrame is used.
+	Frame time.Duration
+	// Threshold places the speech threshold between the noise floor and the
+	// speech level, from 0 (floor) to 1 (level). When zero, 0.35 is used.
+	Threshold float64
+	// Hangover is the number of frames speech is held after energy drops
+	// below the threshold. When zero, 8 frames are used.
+	Hangover int
+	// MinSpeech is the shortest run of speech frames kept. Shorter bursts
+	// such as clicks are discarded. When zero, 3 frames are used.
+	MinSpeech int
+}
+
+func (o VADOptions) withDefaults() VADOptions {
+	if o.Frame <= 0 {
+		o.Frame = DefaultVADFrame
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = 0.35
+	}
+	if o.Hangover <= 0 {
+		o.Hangover = 8
+	}
+	if o.MinSpeech <= 0 {
+		o.MinSpeech = 3
+	}
+	return o
+}
+
+// SpeechActivity extracts the audio track at index track from mediaPath with
+// ExtractTrack and returns one voice activity flag per analysis frame along
+// with the frame duration. No external service is contacted.
+func SpeechActivity(mediaPath string, track int, opts VADOptions) ([]bool, time.Duration, error) {
+	opts = opts.withDefaults()
+	wav, err := ExtractTrack(mediaPath, track)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.Remove(wav)
+
+	f, err := os.Open(wav)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	energies, err := FrameEnergies(f, opts.Frame)
+	if err != nil {
+		return nil, 0, err
+	}
+	return DetectVoiceActivity(energies, opts), opts.Frame, nil
+}
+
+// FrameEnergies streams 16-bit PCM WAV data from r and returns the log energy
+// of each frame of the given duration. Multi-channel audio is downmixed and a
+// pre-emphasis filter is applied to reduce low-frequency rumble.
+func FrameEnergies(r io.Reader, frame time.Duration) ([]float64, error) {
+	if frame <= 0 {
+		frame = DefaultVADFrame
+	}
+	br := bufio.NewReader(r)
+	format, dataLen, err := readWAVHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	perFrame := int(float64(format.sampleRate) * frame.Seconds())
+	if perFrame < 1 {
+		perFrame = 1
+	}
+	var energies []float64
+	var sum float64
+	var n int
+	var prev float64
+	buf := make([]byte, 2*format.channels)
+	data := io.LimitReader(br, dataLen)
+	for {
+		if _, err := io.ReadFull(data, buf); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, err
+		}
+		var mix float64
+		for c := 0; c < format.channels; c++ {
+			mix += float64(int16(binary.LittleEndian.Uint16(buf[2*c:])))
+		}
+		mix /= float64(format.channels)
+		s := mix - 0.97*prev
+		prev = mix
+		sum += s * s
+		n++
+		if n == perFrame {
+			energies = append(energies, 10*math.Log10(sum/float64(n)+1))
+			sum, n = 0, 0
+		}
+	}
+	if n > 0 {
+		energies = append(energies, 10*math.Log10(sum/float64(n)+1))
+	}
+	return energies, nil
+}
+
+// DetectVoiceActivity classifies frame energies as speech or non-speech. The
+// threshold adapts to the recording by interpolating between the noise floor
+// (10th percentile) and the speech level (90th percentile), then short bursts
+// are removed and speech is held for a hangover period.
+func DetectVoiceActivity(energies []float64, opts VADOptions) []bool {
+	opts = opts.withDefaults()
+	out := make([]bool, len(energies))
+	if len(energies) == 0 {
+		return out
+	}
+	sorted := append([]float64(nil), energies...)
+	sort.Float64s(sorted)
+	floor := sorted[len(sorted)/10]
+	level := sorted[len(sorted)*9/10]
+	if level-floor < 3 {
+		// Less than 3 dB of dynamic range: treat as silence throughout.
+		return out
+	}
+	threshold := floor + (level-floor)*opts.Threshold
+
+	for i, e := range energies {
+		out[i] = e > threshold
+	}
+
+	// Drop bursts shorter than MinSpeech.
+	for i := 0; i < len(out); {
+		if !out[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(out) && out[j] {
+			j++
+		}
+		if j-i < opts.MinSpeech {
+			for k := i; k < j; k++ {
+				out[k] = false
+			}
+		}
+		i = j
+	}
+
+	// Hold speech through short pauses.
+	hold := 0
+	for i := range out {
+		if out[i] {
+			hold = opts.Hangover
+			continue
+		}
+		if hold > 0 {
+			out[i] = true
+			hold--
+		}
+	}
+	return out
+}
+
+type wavFormat struct {
+	channels   int
+	sampleRate int
+}
+
+// readWAVHeader parses RIFF chunks up to the data chunk and returns the PCM
+// format along with the length of the sample data.
+func readWAVHeader(r io.Reader) (wavFormat, int64, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return wavFormat{}, 0, fmt.Errorf("read WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return wavFormat{}, 0, errors.New("not a WAV file")
+	}
+
+	var format wavFormat
+	var haveFormat bool
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return wavFormat{}, 0, fmt.Errorf("read WAV chunk: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(hdr[4:]))
+		switch string(hdr[0:4]) {
+		case "fmt ":
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return wavFormat{}, 0, fmt.Errorf("read WAV format: %w", err)
+			}
+			if size < 16 {
+				return wavFormat{}, 0, errors.New("invalid WAV format chunk")
+			}
+			audioFormat := binary.LittleEndian.Uint16(body[0:])
+			bits := binary.LittleEndian.Uint16(body[14:])
+			if (audioFormat != 1 && audioFormat != 0xFFFE) || bits != 16 {
+				return wavFormat{}, 0, fmt.Errorf("unsupported WAV encoding (format %d, %d bits)", audioFormat, bits)
+			}
+			format.channels = int(binary.LittleEndian.Uint16(body[2:]))
+			format.sampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			if format.channels < 1 || format.sampleRate < 1 {
+				return wavFormat{}, 0, errors.New("invalid WAV format chunk")
+			}
+			haveFormat = true
+			if size%2 == 1 {
+				if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+					return wavFormat{}, 0, err
+				}
+			}
+		case "data":
+			if !haveFormat {
+				return wavFormat{}, 0, errors.New("WAV data before format chunk")
+			}
+			// ffmpeg writes 0xFFFFFFFF when streaming; read until EOF then.
+			if size == 0xFFFFFFFF {
+				size = math.MaxInt64
+			}
+			return format, size, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return wavFormat{}, 0, fmt.Errorf("skip WAV chunk: %w", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// buildWAV encodes mono 16-bit samples as a WAV file.
+func buildWAV(samples []int16, rate int) []byte {
+	var buf bytes.Buffer
+	dataLen := len(samples) * 2
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataLen))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint32(rate))
+	binary.Write(&buf, binary.LittleEndian, uint32(rate*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataLen))
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+// TestDetectVoiceActivity verifies tone bursts over low noise are detected as speech.
+func TestDetectVoiceActivity(t *testing.T) {
+	const rate = 16000
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]int16, 6*rate)
+	for i := range samples {
+		samples[i] = int16(rng.NormFloat64() * 30)
+		sec := i / rate
+		if sec == 1 || sec == 2 || sec == 4 {
+			samples[i] += int16(8000 * math.Sin(2*math.Pi*440*float64(i)/rate))
+		}
+	}
+
+	energies, err := FrameEnergies(bytes.NewReader(buildWAV(samples, rate)), 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("frame energies: %v", err)
+	}
+	if len(energies) != 600 {
+		t.Fatalf("expected 600 frames, got %d", len(energies))
+	}
+
+	speech := DetectVoiceActivity(energies, VADOptions{})
+	for _, tc := range []struct {
+		frame int
+		want  bool
+	}{{50, false}, {150, true}, {250, true}, {350, false}, {450, true}, {550, false}} {
+		if speech[tc.frame] != tc.want {
+			t.Errorf("frame %d: expected %v", tc.frame, tc.want)
+		}
+	}
+}
+
+// TestDetectVoiceActivitySilence verifies flat input yields no speech.
+func TestDetectVoiceActivitySilence(t *testing.T) {
+	energies := make([]float64, 100)
+	for _, s := range DetectVoiceActivity(energies, VADOptions{}) {
+		if s {
+			t.Fatal("expected no speech in silence")
+		}
+	}
+}
+
+// TestFrameEnergiesInvalid verifies non-WAV input is rejected.
+func TestFrameEnergiesInvalid(t *testing.T) {
+	if _, err := FrameEnergies(bytes.NewReader([]byte("not a wav file at all")), 0); err == nil {
+		t.Fatal("expected error for invalid input")
+	}
+}
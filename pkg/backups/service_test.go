@@ -1,5 +1,5 @@
 // file: pkg/backups/service_test.go
-// version: 1.0.0
+// version: 1.0.1
 // guid: 123e4567-e89b-12d3-a456-426614174012
 
 package backups
@@ -147,11 +147,19 @@ func (m *mockSubtitleStore) SetMediaReleaseGroup(path, group string) error
 func (m *mockSubtitleStore) SetMediaAltTitles(path string, titles []string) error { return nil }
 func (m *mockSubtitleStore) SetMediaFieldLocks(path, locks string) error          { return nil }
 func (m *mockSubtitleStore) SetMediaTitle(path, title string) error               { return nil }
-func (m *mockSubtitleStore) GetMediaReleaseGroup(path string) (string, error)     { return "", nil }
-func (m *mockSubtitleStore) GetMediaAltTitles(path string) ([]string, error)      { return []string{}, nil }
-func (m *mockSubtitleStore) GetMediaFieldLocks(path string) (string, error)       { return "", nil }
-func (m *mockSubtitleStore) Close() error                                         { return nil }
-func (m *mockSubtitleStore) CleanupExpiredSessions() error                        { return nil }
+func (m *mockSubtitleStore) MoveMediaPath(oldPath, newPath string) error          { return nil }
+func (m *mockSubtitleStore) ListFileIndexEntries(dir string) ([]database.FileIndexEntry, error) {
+	return nil, nil
+}
+func (m *mockSubtitleStore) UpsertFileIndexEntry(e *database.FileIndexEntry) error      { return nil }
+func (m *mockSubtitleStore) DeleteFileIndexEntry(path string) error                     { return nil }
+func (m *mockSubtitleStore) GetMediaReleaseGroup(path string) (string, error)           { return "", nil }
+func (m *mockSubtitleStore) GetMediaAltTitles(path string) ([]string, error)            { return []string{}, nil }
+func (m *mockSubtitleStore) GetMediaFieldLocks(path string) (string, error)             { return "", nil }
+func (m *mockSubtitleStore) SetMediaHashes(path string, hashes map[string]string) error { return nil }
+func (m *mockSubtitleStore) GetMediaHashes(path string) (map[string]string, error)      { return nil, nil }
+func (m *mockSubtitleStore) Close() error                                               { return nil }
+func (m *mockSubtitleStore) CleanupExpiredSessions() error                              { return nil }
 
 func TestService_CreateDatabaseBackup(t *testing.T) {
 	store := newMockSubtitleStore()
@@ -337,6 +345,19 @@ func (m *mockSubtitleStore) DeleteMonitoredItem(id string) error
 func (m *mockSubtitleStore) GetMonitoredItemsToCheck(interval time.Duration) ([]database.MonitoredItem, error) {
 	return []database.MonitoredItem{}, nil
 }
+func (m *mockSubtitleStore) InsertQueueJob(rec *database.QueueJob) error { return nil }
+func (m *mockSubtitleStore) UpdateQueueJob(rec *database.QueueJob) error { return nil }
+func (m *mockSubtitleStore) GetQueueJob(id string) (*database.QueueJob, error) {
+	return nil, nil
+}
+func (m *mockSubtitleStore) ListQueueJobs(status string) ([]database.QueueJob, error) {
+	return []database.QueueJob{}, nil
+}
+func (m *mockSubtitleStore) ClaimQueueJob(now time.Time) (*database.QueueJob, error) {
+	return nil, nil
+}
+func (m *mockSubtitleStore) CancelQueueJob(id string) (bool, error) { return false, nil }
+func (m *mockSubtitleStore) DeleteQueueJob(id string) error         { return nil }
 
 // User authentication and session management methods
 func (m *mockSubtitleStore) CreateUser(username, passwordHash, email, role string) (string, error) {
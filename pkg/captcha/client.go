@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/spf13/viper"
 )
 
 // apiURL is the Anti-Captcha endpoint used by the client.
@@ -31,6 +33,21 @@ func New(apiKey string) *Client {
 	return &Client{APIKey: apiKey, HTTPClient: &http.Client{Timeout: 30 * time.Second}}
 }
 
+// Default returns the solver configured with "anticaptcha.api_key", or nil
+// when no key is configured.
+func Default() Solver {
+	key := viper.GetString("anticaptcha.api_key")
+	if key == "" {
+		return nil
+	}
+	return New(key)
+}
+
+// Solve implements Solver by solving a reCAPTCHA v2 challenge.
+func (c *Client) Solve(ctx context.Context, siteKey, pageURL string) (string, error) {
+	return c.SolveRecaptchaV2(ctx, pageURL, siteKey)
+}
+
 type createResp struct {
 	ErrorID          int    `json:"errorId"`
 	TaskID           int    `json:"taskId"`
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 // TestSolveImage verifies that the client solves image captchas
@@ -72,3 +74,16 @@ func TestSolveRecaptchaV2(t *testing.T) {
 		t.Fatalf("expected token, got %s", tok)
 	}
 }
+
+// TestDefault verifies the default solver follows the configured API key.
+func TestDefault(t *testing.T) {
+	defer viper.Reset()
+	if Default() != nil {
+		t.Fatal("expected no solver without an API key")
+	}
+	viper.Set("anticaptcha.api_key", "key")
+	c, ok := Default().(*Client)
+	if !ok || c.APIKey != "key" {
+		t.Fatalf("unexpected solver %#v", Default())
+	}
+}
@@ -0,0 +1,404 @@
+// file: pkg/charset/charset.go
+// version: 1.1.0
+// guid: 4f1c8e26-9b3d-4a75-8e02-d6a7b3c5f918
+
+// Package charset detects the character encoding of subtitle files and
+// transcodes them to UTF-8.
+//
+// Detection first looks for a byte order mark and valid UTF-8. Other data is
+// decoded with the legacy encodings used for the requested language
+// (Windows-125x, ISO-8859, KOI8, Big5, GB18030, Shift_JIS, EUC-KR, ...) and
+// each decoding is scored on how plausible the resulting text is: undefined
+// and control characters, symbols where letters are expected, words made
+// only of accented Latin letters and mid-word capitals all count against an
+// encoding.
+package charset
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// UTF8 is the name reported for UTF-8 data.
+const UTF8 = "utf-8"
+
+// ErrBinary is returned by FixFile for files that are not text, such as
+// VobSub .sub images.
+var ErrBinary = errors.New("not a text file")
+
+// Result describes the detected encoding of some data.
+type Result struct {
+	// Encoding is the lower-case WHATWG name of the encoding, e.g. "utf-8",
+	// "windows-1251" or "gb18030".
+	Encoding string `json:"encoding"`
+	// Confidence is between 0 and 1.
+	Confidence float64 `json:"confidence"`
+	// BOM is true when the encoding was identified by a byte order mark.
+	BOM bool `json:"bom,omitempty"`
+}
+
+// candidate is a legacy encoding and the script of the text it encodes.
+type candidate struct {
+	name    string
+	enc     encoding.Encoding
+	scripts []*unicode.RangeTable
+}
+
+var (
+	latin    = []*unicode.RangeTable{unicode.Latin}
+	cyrillic = []*unicode.RangeTable{unicode.Cyrillic}
+	greek    = []*unicode.RangeTable{unicode.Greek}
+	hebrew   = []*unicode.RangeTable{unicode.Hebrew}
+	arabic   = []*unicode.RangeTable{unicode.Arabic}
+	thai     = []*unicode.RangeTable{unicode.Thai}
+	chinese  = []*unicode.RangeTable{unicode.Han}
+	japan    = []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana}
+	hangul   = []*unicode.RangeTable{unicode.Hangul, unicode.Han}
+)
+
+var (
+	windows1250 = candidate{"windows-1250", charmap.Windows1250, latin}
+	windows1251 = candidate{"windows-1251", charmap.Windows1251, cyrillic}
+	windows1252 = candidate{"windows-1252", charmap.Windows1252, latin}
+	windows1253 = candidate{"windows-1253", charmap.Windows1253, greek}
+	windows1254 = candidate{"windows-1254", charmap.Windows1254, latin}
+	windows1255 = candidate{"windows-1255", charmap.Windows1255, hebrew}
+	windows1256 = candidate{"windows-1256", charmap.Windows1256, arabic}
+	windows1257 = candidate{"windows-1257", charmap.Windows1257, latin}
+	windows1258 = candidate{"windows-1258", charmap.Windows1258, latin}
+	windows874  = candidate{"windows-874", charmap.Windows874, thai}
+	iso88592    = candidate{"iso-8859-2", charmap.ISO8859_2, latin}
+	iso88595    = candidate{"iso-8859-5", charmap.ISO8859_5, cyrillic}
+	iso88596    = candidate{"iso-8859-6", charmap.ISO8859_6, arabic}
+	iso88597    = candidate{"iso-8859-7", charmap.ISO8859_7, greek}
+	iso88598    = candidate{"iso-8859-8", charmap.ISO8859_8, hebrew}
+	iso88599    = candidate{"iso-8859-9", charmap.ISO8859_9, latin}
+	iso885913   = candidate{"iso-8859-13", charmap.ISO8859_13, latin}
+	iso885915   = candidate{"iso-8859-15", charmap.ISO8859_15, latin}
+	koi8r       = candidate{"koi8-r", charmap.KOI8R, cyrillic}
+	koi8u       = candidate{"koi8-u", charmap.KOI8U, cyrillic}
+	ibm866      = candidate{"ibm866", charmap.CodePage866, cyrillic}
+	gb18030     = candidate{"gb18030", simplifiedchinese.GB18030, chinese}
+	big5        = candidate{"big5", traditionalchinese.Big5, chinese}
+	shiftJIS    = candidate{"shift_jis", japanese.ShiftJIS, japan}
+	eucJP       = candidate{"euc-jp", japanese.EUCJP, japan}
+	eucKR       = candidate{"euc-kr", korean.EUCKR, hangul}
+)
+
+// byLanguage lists the candidate encodings for ISO 639-1 language codes in
+// order of preference.
+var byLanguage = map[string][]candidate{
+	"el": {windows1253, iso88597},
+	"ru": {windows1251, koi8r, iso88595, ibm866},
+	"uk": {windows1251, koi8u, iso88595},
+	"be": {windows1251, iso88595},
+	"bg": {windows1251, iso88595},
+	"mk": {windows1251, iso88595},
+	"sr": {windows1251, windows1250, iso88595, iso88592},
+	"he": {windows1255, iso88598},
+	"yi": {windows1255, iso88598},
+	"ar": {windows1256, iso88596},
+	"fa": {windows1256},
+	"ur": {windows1256},
+	"tr": {windows1254, iso88599},
+	"az": {windows1254, iso88599},
+	"pl": {windows1250, iso88592},
+	"cs": {windows1250, iso88592},
+	"sk": {windows1250, iso88592},
+	"hu": {windows1250, iso88592},
+	"ro": {windows1250, iso88592},
+	"hr": {windows1250, iso88592},
+	"sl": {windows1250, iso88592},
+	"bs": {windows1250, iso88592},
+	"sq": {windows1250, iso88592},
+	"lt": {windows1257, iso885913},
+	"lv": {windows1257, iso885913},
+	"et": {windows1257, iso885913},
+	"th": {windows874},
+	"vi": {windows1258},
+	"zh": {gb18030, big5},
+	"ja": {shiftJIS, eucJP},
+	"ko": {eucKR},
+}
+
+// traditional lists language tags written in traditional Chinese.
+var traditional = map[string]bool{"tw": true, "hk": true, "mo": true, "hant": true, "zht": true}
+
+// alpha3 maps ISO 639-2 codes of the languages above to ISO 639-1.
+var alpha3 = map[string]string{
+	"ell": "el", "gre": "el", "rus": "ru", "ukr": "uk", "bel": "be",
+	"bul": "bg", "mkd": "mk", "mac": "mk", "srp": "sr", "scc": "sr",
+	"heb": "he", "yid": "yi", "ara": "ar", "fas": "fa", "per": "fa",
+	"urd": "ur", "tur": "tr", "aze": "az", "pol": "pl", "ces": "cs",
+	"cze": "cs", "slk": "sk", "slo": "sk", "hun": "hu", "ron": "ro",
+	"rum": "ro", "hrv": "hr", "scr": "hr", "slv": "sl", "bos": "bs",
+	"sqi": "sq", "alb": "sq", "lit": "lt", "lav": "lv", "est": "et",
+	"tha": "th", "vie": "vi", "zho": "zh", "chi": "zh", "jpn": "ja",
+	"kor": "ko", "zht": "zh", "zhs": "zh", "chs": "zh", "cht": "zh",
+}
+
+// western is tried for every language and for unknown ones.
+var western = []candidate{windows1252, iso885915}
+
+// all is tried when no language is given.
+var all = []candidate{
+	windows1252, windows1250, windows1251, windows1253, windows1254,
+	windows1255, windows1256, windows1257, windows874, koi8r,
+	gb18030, big5, shiftJIS, eucKR,
+}
+
+// candidates returns the encodings to try for the language code lang.
+func candidates(lang string) []candidate {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		return all
+	}
+	parts := strings.FieldsFunc(lang, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return all
+	}
+	base := parts[0]
+	if v, ok := alpha3[base]; ok {
+		base = v
+	}
+	list := byLanguage[base]
+	if base == "zh" {
+		trad := traditional[parts[0]] || parts[0] == "cht"
+		for _, p := range parts[1:] {
+			trad = trad || traditional[p]
+		}
+		if trad {
+			list = []candidate{big5, gb18030}
+		}
+	}
+	out := make([]candidate, 0, len(list)+len(western))
+	out = append(out, list...)
+	return append(out, western...)
+}
+
+// Detect returns the encoding of data. lang is the language code the
+// subtitle was requested in and narrows the legacy encodings considered; it
+// may be empty.
+func Detect(data []byte, lang string) Result {
+	if res, ok := detectUnicode(data); ok {
+		return res
+	}
+	best, _ := detectLegacy(data, lang)
+	return best
+}
+
+// ToUTF8 transcodes data to UTF-8 and returns it with the detected encoding.
+// A UTF-8 byte order mark is removed. Data that is already UTF-8 is returned
+// unchanged apart from the BOM.
+func ToUTF8(data []byte, lang string) ([]byte, Result, error) {
+	if res, ok := detectUnicode(data); ok {
+		switch res.Encoding {
+		case UTF8:
+			return bytes.TrimPrefix(data, utf8BOM), res, nil
+		case "utf-16le":
+			out, err := xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM).NewDecoder().Bytes(data)
+			return out, res, err
+		default:
+			out, err := xunicode.UTF16(xunicode.BigEndian, xunicode.UseBOM).NewDecoder().Bytes(data)
+			return out, res, err
+		}
+	}
+	res, out := detectLegacy(data, lang)
+	return out, res, nil
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// detectUnicode recognises byte order marks, valid UTF-8 and BOM-less
+// UTF-16 text.
+func detectUnicode(data []byte) (Result, bool) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return Result{Encoding: UTF8, Confidence: 1, BOM: true}, true
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return Result{Encoding: "utf-16le", Confidence: 1, BOM: true}, true
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return Result{Encoding: "utf-16be", Confidence: 1, BOM: true}, true
+	}
+	if enc := utf16Guess(data); enc != "" {
+		return Result{Encoding: enc, Confidence: 0.9}, true
+	}
+	if utf8.Valid(data) {
+		return Result{Encoding: UTF8, Confidence: 1}, true
+	}
+	return Result{}, false
+}
+
+// utf16Guess recognises BOM-less UTF-16 by the zero bytes of ASCII
+// characters, which subtitle timings are full of.
+func utf16Guess(data []byte) string {
+	if len(data) < 16 || len(data)%2 != 0 {
+		return ""
+	}
+	var even, odd int
+	for i := 0; i < len(data); i += 2 {
+		if data[i] == 0 {
+			even++
+		}
+		if data[i+1] == 0 {
+			odd++
+		}
+	}
+	half := len(data) / 2
+	switch {
+	case odd > half*3/5 && even < half/10:
+		return "utf-16le"
+	case even > half*3/5 && odd < half/10:
+		return "utf-16be"
+	}
+	return ""
+}
+
+// detectLegacy decodes data with each candidate encoding for lang and
+// returns the most plausible result along with the decoded text.
+func detectLegacy(data []byte, lang string) (Result, []byte) {
+	var best Result
+	var bestOut []byte
+	bestScore := -1e9
+	for _, c := range candidates(lang) {
+		out, err := c.enc.NewDecoder().Bytes(data)
+		if err != nil {
+			continue
+		}
+		s := score(out, c.scripts)
+		if s > bestScore {
+			bestScore = s
+			best = Result{Encoding: c.name}
+			bestOut = out
+		}
+	}
+	if bestOut == nil {
+		// Every decoder failed; fall back to Windows-1252, which maps every
+		// byte.
+		bestOut, _ = charmap.Windows1252.NewDecoder().Bytes(data)
+		return Result{Encoding: windows1252.name}, bestOut
+	}
+	best.Confidence = clamp(bestScore)
+	return best, bestOut
+}
+
+func clamp(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// punctuation lists the non-ASCII symbols common in subtitles.
+const punctuation = "«»‹›–—―…‘’‚“”„•·°€£¥©®™¡¿§¶×÷ ♪♫¨´¸"
+
+// commonHan lists the most frequent Chinese characters in both simplified
+// and traditional form. Chinese text decoded with the wrong double-byte
+// encoding still yields ideographs, but rarely these.
+const commonHan = "的一是不了人我在有他这這个個们們中来來上大为為和你说說到要就出会會也时時那好看没沒道什么麼她吗嗎过過"
+
+// score rates how plausible text is for an encoding whose letters belong to
+// scripts. It returns the share of non-ASCII characters that look right,
+// minus penalties for characters that look wrong.
+func score(text []byte, scripts []*unicode.RangeTable) float64 {
+	var nonASCII, good, bad float64
+	var prev rune
+	wordLen, wordForeign := 0, 0
+	endWord := func() {
+		// Runs of accented Latin letters are what other scripts look like
+		// when decoded as Latin.
+		if wordLen >= 3 && wordForeign == wordLen && scripts[0] == unicode.Latin {
+			bad += float64(wordLen)
+		}
+		wordLen, wordForeign = 0, 0
+	}
+	for _, r := range string(text) {
+		switch {
+		case r == utf8.RuneError:
+			bad += 2
+		case r < 0x20 && r != '\n' && r != '\r' && r != '\t':
+			bad += 2
+		case r >= 0x80 && r <= 0x9F:
+			bad += 2
+		}
+		if r >= 0x80 {
+			nonASCII++
+			switch {
+			case unicode.IsLetter(r) && unicode.In(r, scripts...):
+				good++
+				if strings.ContainsRune(commonHan, r) {
+					good++
+				}
+			case unicode.IsLetter(r):
+				bad++
+			case unicode.IsMark(r) || unicode.IsSpace(r) || strings.ContainsRune(punctuation, r):
+			case r != utf8.RuneError && !(r >= 0x80 && r <= 0x9F):
+				bad++
+			}
+		}
+		if unicode.IsLetter(r) {
+			wordLen++
+			if r >= 0x80 {
+				wordForeign++
+			}
+			// Capitals inside lower-case words show swapped cases, e.g.
+			// KOI8-R decoded as Windows-1251.
+			if unicode.IsUpper(r) && unicode.IsLower(prev) && r >= 0x80 {
+				bad++
+			}
+		} else {
+			endWord()
+		}
+		prev = r
+	}
+	endWord()
+	if nonASCII == 0 {
+		return 1
+	}
+	return (good - bad) / nonASCII
+}
+
+// FixFile transcodes the subtitle file at path to UTF-8 in place when it
+// uses another encoding. lang is the language hint passed to Detect. When
+// dryRun is set the file is only inspected. The detected encoding is
+// returned along with whether the file needed conversion. Files whose text
+// would contain NUL characters are left alone and reported with ErrBinary.
+func FixFile(path, lang string, dryRun bool) (Result, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, false, err
+	}
+	out, res, err := ToUTF8(data, lang)
+	if err != nil {
+		return res, false, err
+	}
+	if bytes.IndexByte(out, 0) >= 0 {
+		return res, false, ErrBinary
+	}
+	if res.Encoding == UTF8 {
+		return res, false, nil
+	}
+	if dryRun {
+		return res, true, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return res, true, err
+	}
+	return res, true, os.WriteFile(path, out, info.Mode().Perm())
+}
@@ -0,0 +1,140 @@
+// file: pkg/charset/charset_test.go
+// version: 1.2.0
+// guid: 8a3e5d17-2c9f-4b60-91d4-e7f0b6a2c385
+
+package charset
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+const cue = "1\n00:00:01,000 --> 00:00:04,000\n"
+
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	out, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return out
+}
+
+func TestToUTF8(t *testing.T) {
+	cases := []struct {
+		name string
+		enc  encoding.Encoding
+		lang string
+		text string
+		want string
+	}{
+		{"greek", charmap.Windows1253, "el", "Καλημέρα, τι κάνεις σήμερα;", "windows-1253"},
+		{"russian", charmap.Windows1251, "ru", "Привет, как дела? Всё хорошо.", "windows-1251"},
+		{"russian koi8", charmap.KOI8R, "rus", "Привет, как дела? Всё хорошо.", "koi8-r"},
+		{"russian without hint", charmap.Windows1251, "", "Привет, как дела? Всё хорошо.", "windows-1251"},
+		{"hebrew", charmap.Windows1255, "he", "שלום, מה שלומך היום?", "windows-1255"},
+		{"turkish", charmap.Windows1254, "tr", "Günaydın, bugün nasılsın? Şimdi gidiyoruz.", "windows-1254"},
+		{"polish", charmap.Windows1250, "pl", "Zażółć gęślą jaźń, proszę pana.", "windows-1250"},
+		{"french", charmap.Windows1252, "fr", "Où est la bibliothèque? Très bien, merci.", "windows-1252"},
+		{"simplified chinese", simplifiedchinese.GB18030, "zh", "你好，我们现在去哪里？这是我的朋友。", "gb18030"},
+		{"traditional chinese", traditionalchinese.Big5, "zh-TW", "你好，我們現在去哪裡？這是我的朋友。", "big5"},
+		{"traditional chinese without region", traditionalchinese.Big5, "zh", "你好，我們現在去哪裡？這是我的朋友。", "big5"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := encode(t, c.enc, cue+c.text+"\n")
+			out, res, err := ToUTF8(data, c.lang)
+			if err != nil {
+				t.Fatalf("to utf-8: %v", err)
+			}
+			if res.Encoding != c.want {
+				t.Fatalf("detected %s, want %s", res.Encoding, c.want)
+			}
+			if string(out) != cue+c.text+"\n" {
+				t.Fatalf("unexpected text %q", out)
+			}
+			if got := Detect(data, c.lang); got.Encoding != c.want {
+				t.Fatalf("Detect returned %s", got.Encoding)
+			}
+		})
+	}
+}
+
+func TestToUTF8Unicode(t *testing.T) {
+	text := cue + "Grüße ♪\n"
+
+	out, res, err := ToUTF8(append([]byte{0xEF, 0xBB, 0xBF}, text...), "de")
+	if err != nil || string(out) != text || res.Encoding != UTF8 || !res.BOM {
+		t.Fatalf("utf-8 bom: %q %+v %v", out, res, err)
+	}
+
+	out, res, err = ToUTF8([]byte(text), "de")
+	if err != nil || !bytes.Equal(out, []byte(text)) || res.Encoding != UTF8 || res.BOM {
+		t.Fatalf("utf-8: %q %+v %v", out, res, err)
+	}
+
+	le := encode(t, xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM), text)
+	out, res, err = ToUTF8(le, "de")
+	if err != nil || string(out) != text || res.Encoding != "utf-16le" {
+		t.Fatalf("utf-16le: %q %+v %v", out, res, err)
+	}
+
+	be := encode(t, xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM), text)
+	out, res, err = ToUTF8(be, "")
+	if err != nil || string(out) != text || res.Encoding != "utf-16be" {
+		t.Fatalf("utf-16be without bom: %q %+v %v", out, res, err)
+	}
+}
+
+func TestFixFile(t *testing.T) {
+	text := cue + "Привет, как дела?\n"
+	path := filepath.Join(t.TempDir(), "movie.ru.srt")
+	if err := os.WriteFile(path, encode(t, charmap.Windows1251, text), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	res, changed, err := FixFile(path, "ru", true)
+	if err != nil || !changed || res.Encoding != "windows-1251" {
+		t.Fatalf("dry run: %+v %v %v", res, changed, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) == text {
+		t.Fatal("dry run rewrote file")
+	}
+
+	if _, changed, err = FixFile(path, "ru", false); err != nil || !changed {
+		t.Fatalf("fix: %v %v", changed, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != text {
+		t.Fatalf("got %q", data)
+	}
+
+	if _, changed, err = FixFile(path, "ru", false); err != nil || changed {
+		t.Fatalf("second fix: %v %v", changed, err)
+	}
+}
+
+// TestFixFileBinary verifies binary files such as VobSub images are left
+// untouched.
+func TestFixFileBinary(t *testing.T) {
+	// An MPEG program stream pack header followed by image data.
+	data := append([]byte{0x00, 0x00, 0x01, 0xBA, 0x44, 0x00, 0x04, 0x00}, bytes.Repeat([]byte{0xC3, 0x00, 0x9F, 0xE8}, 64)...)
+	path := filepath.Join(t.TempDir(), "movie.sub")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, changed, err := FixFile(path, "", false); !errors.Is(err, ErrBinary) || changed {
+		t.Fatalf("expected ErrBinary, got %v %v", changed, err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+		t.Fatal("binary file rewritten")
+	}
+}
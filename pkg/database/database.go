@@ -50,7 +50,9 @@ import (
 	"fmt"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jdfalk/gcommon/sdks/go/v1/common"
 	"github.com/spf13/viper"
@@ -114,6 +116,15 @@ type DownloadRecord struct {
 	CreatedAt        time.Time
 }
 
+// Score returns the match score of r on a 0-100 scale and whether one was
+// recorded. A nil record has no score.
+func (r *DownloadRecord) Score() (int, bool) {
+	if r == nil || r.MatchScore == nil {
+		return 0, false
+	}
+	return int(*r.MatchScore*100 + 0.5), true
+}
+
 // SubtitleSource represents metadata about subtitle sources and provider performance.
 // Tracks where subtitles come from and how well providers perform over time.
 type SubtitleSource struct {
@@ -144,7 +155,10 @@ type MediaItem struct {
 	ReleaseGroup string
 	AltTitles    string
 	FieldLocks   string
-	CreatedAt    time.Time
+	// Hashes holds the JSON encoded content hashes providers search by,
+	// keyed by algorithm. See GetMediaHashes.
+	Hashes    string
+	CreatedAt time.Time
 }
 
 // Tag represents a universal tag that can be associated with any entity type.
@@ -193,6 +207,35 @@ type MonitoredItem struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// QueueJob represents a job persisted by the durable job queue.
+// Payload holds the serialized gcommon QueueMessage describing the job.
+type QueueJob struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Payload     []byte    `json:"payload"`
+	Priority    int       `json:"priority"`
+	Status      string    `json:"status"` // pending, running, completed, cancelled, dead
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	RunAt       time.Time `json:"run_at"`
+	LastError   string    `json:"last_error"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// FileIndexEntry records what the last library scan saw for a video file so
+// later scans can skip files that have not changed. Results holds the
+// outcome of the last evaluation per scan kind, such as "satisfied" under
+// "subtitles:en", and is cleared when the file changes.
+type FileIndexEntry struct {
+	Path      string            `json:"path"`
+	Size      int64             `json:"size"`
+	ModTime   time.Time         `json:"mod_time"`
+	MediaHash string            `json:"media_hash"`
+	Results   map[string]string `json:"results"`
+	ScannedAt time.Time         `json:"scanned_at"`
+}
+
 // SQLStore implements SubtitleStore using an SQLite database.
 type SQLStore struct {
 	db *sql.DB
@@ -291,8 +334,18 @@ func (s *SQLStore) DeleteDownload(file string) error {
 
 // InsertDownload stores a download record using a raw *sql.DB.
 func InsertDownload(db *sql.DB, file, video, provider, lang string) error {
-	_, err := db.Exec(`INSERT INTO downloads (file, video_file, provider, language, search_query, match_score, download_attempts, error_message, response_time_ms, created_at) VALUES (?, ?, ?, ?, '', NULL, 1, '', NULL, ?)`,
-		file, video, provider, lang, time.Now())
+	return insertDownload(db, file, video, provider, lang, nil)
+}
+
+// InsertScoredDownload stores a download record with its 0-1 match score
+// using a raw *sql.DB.
+func InsertScoredDownload(db *sql.DB, file, video, provider, lang string, score float64) error {
+	return insertDownload(db, file, video, provider, lang, &score)
+}
+
+func insertDownload(db *sql.DB, file, video, provider, lang string, score *float64) error {
+	_, err := db.Exec(`INSERT INTO downloads (file, video_file, provider, language, search_query, match_score, download_attempts, error_message, response_time_ms, created_at) VALUES (?, ?, ?, ?, '', ?, 1, '', NULL, ?)`,
+		file, video, provider, lang, score, time.Now())
 	return err
 }
 
@@ -376,14 +429,14 @@ func DeleteDownload(db *sql.DB, file string) error {
 
 // InsertMediaItem stores a media library record.
 func (s *SQLStore) InsertMediaItem(rec *MediaItem) error {
-	_, err := s.db.Exec(`INSERT INTO media_items (path, title, season, episode, release_group, alt_titles, field_locks, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		rec.Path, rec.Title, rec.Season, rec.Episode, rec.ReleaseGroup, rec.AltTitles, rec.FieldLocks, time.Now())
+	_, err := s.db.Exec(`INSERT INTO media_items (path, title, season, episode, release_group, alt_titles, field_locks, hashes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.Path, rec.Title, rec.Season, rec.Episode, rec.ReleaseGroup, rec.AltTitles, rec.FieldLocks, rec.Hashes, time.Now())
 	return err
 }
 
 // ListMediaItems retrieves all media items sorted by creation time.
 func (s *SQLStore) ListMediaItems() ([]MediaItem, error) {
-	rows, err := s.db.Query(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, created_at FROM media_items ORDER BY id DESC`)
+	rows, err := s.db.Query(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, hashes, created_at FROM media_items ORDER BY id DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -392,7 +445,7 @@ func (s *SQLStore) ListMediaItems() ([]MediaItem, error) {
 	for rows.Next() {
 		var r MediaItem
 		var id int64
-		if err := rows.Scan(&id, &r.Path, &r.Title, &r.Season, &r.Episode, &r.ReleaseGroup, &r.AltTitles, &r.FieldLocks, &r.CreatedAt); err != nil {
+		if err := rows.Scan(&id, &r.Path, &r.Title, &r.Season, &r.Episode, &r.ReleaseGroup, &r.AltTitles, &r.FieldLocks, &r.Hashes, &r.CreatedAt); err != nil {
 			return nil, err
 		}
 		r.ID = strconv.FormatInt(id, 10)
@@ -409,10 +462,10 @@ func (s *SQLStore) DeleteMediaItem(path string) error {
 
 // GetMediaItem retrieves a media item by path. Returns nil if not found.
 func (s *SQLStore) GetMediaItem(path string) (*MediaItem, error) {
-	row := s.db.QueryRow(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, created_at FROM media_items WHERE path = ?`, path)
+	row := s.db.QueryRow(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, hashes, created_at FROM media_items WHERE path = ?`, path)
 	var it MediaItem
 	var id int64
-	if err := row.Scan(&id, &it.Path, &it.Title, &it.Season, &it.Episode, &it.ReleaseGroup, &it.AltTitles, &it.FieldLocks, &it.CreatedAt); err != nil {
+	if err := row.Scan(&id, &it.Path, &it.Title, &it.Season, &it.Episode, &it.ReleaseGroup, &it.AltTitles, &it.FieldLocks, &it.Hashes, &it.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -514,12 +567,113 @@ func (s *SQLStore) GetMediaFieldLocks(path string) (string, error) {
 	return locks, nil
 }
 
+// SetMediaHashes stores the content hashes of a media item keyed by
+// algorithm.
+func (s *SQLStore) SetMediaHashes(path string, hashes map[string]string) error {
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE media_items SET hashes = ? WHERE path = ?`, string(data), path)
+	return err
+}
+
+// GetMediaHashes retrieves the content hashes of a media item.
+func (s *SQLStore) GetMediaHashes(path string) (map[string]string, error) {
+	row := s.db.QueryRow(`SELECT hashes FROM media_items WHERE path = ?`, path)
+	var data sql.NullString
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeMediaHashes(data.String)
+}
+
+// decodeMediaHashes parses the JSON stored in the hashes column.
+func decodeMediaHashes(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var hashes map[string]string
+	if err := json.Unmarshal([]byte(data), &hashes); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
 // SetMediaTitle updates the title for a media item.
 func (s *SQLStore) SetMediaTitle(path, title string) error {
 	_, err := s.db.Exec(`UPDATE media_items SET title = ? WHERE path = ?`, title, path)
 	return err
 }
 
+// MoveMediaPath points the records of a video moved from oldPath to newPath
+// at the new location.
+func (s *SQLStore) MoveMediaPath(oldPath, newPath string) error {
+	return moveMediaPathSQL(sqlSnapshot{db: s.db}, oldPath, newPath)
+}
+
+// MovedSidecarPath returns the name of subtitle file after its video moved
+// from oldVideo to newVideo. Files named after the old video, such as
+// "Show.S01E01.en.srt" next to "Show.S01E01.mkv", keep their suffix next to
+// the new video. Other files are returned unchanged.
+func MovedSidecarPath(file, oldVideo, newVideo string) string {
+	oldBase := strings.TrimSuffix(oldVideo, filepath.Ext(oldVideo)) + "."
+	if !strings.HasPrefix(file, oldBase) {
+		return file
+	}
+	return strings.TrimSuffix(newVideo, filepath.Ext(newVideo)) + "." + strings.TrimPrefix(file, oldBase)
+}
+
+// moveMediaPathSQL implements MoveMediaPath for the SQLite and PostgreSQL
+// schemas in one transaction.
+func moveMediaPathSQL(s sqlSnapshot, oldPath, newPath string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		`UPDATE media_items SET path = ? WHERE path = ?`,
+		`UPDATE monitored_items SET path = ? WHERE path = ?`,
+		`UPDATE file_index SET path = ? WHERE path = ?`,
+	} {
+		if _, err := tx.Exec(s.rebind(q), newPath, oldPath); err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"subtitles", "downloads"} {
+		rows, err := tx.Query(s.rebind(`SELECT id, file FROM `+table+` WHERE video_file = ?`), oldPath)
+		if err != nil {
+			return err
+		}
+		files := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var file string
+			if err := rows.Scan(&id, &file); err != nil {
+				rows.Close()
+				return err
+			}
+			files[id] = file
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, file := range files {
+			if _, err := tx.Exec(s.rebind(`UPDATE `+table+` SET file = ?, video_file = ? WHERE id = ?`),
+				MovedSidecarPath(file, oldPath, newPath), newPath, id); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
 // DB returns the underlying *sql.DB for compatibility with existing code.
 func (s *SQLStore) DB() *sql.DB {
 	return s.db
@@ -1561,6 +1715,204 @@ func (s *SQLStore) GetMonitoredItemsToCheck(interval time.Duration) ([]Monitored
 	return recs, rows.Err()
 }
 
+// queueJobColumns lists the queue_jobs columns in scan order.
+const queueJobColumns = `id, type, payload, priority, status, attempts, max_attempts, run_at, last_error, created_at, updated_at`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanQueueJob reads a queue_jobs row selected with queueJobColumns.
+func scanQueueJob(row rowScanner) (*QueueJob, error) {
+	var r QueueJob
+	if err := row.Scan(&r.ID, &r.Type, &r.Payload, &r.Priority, &r.Status, &r.Attempts, &r.MaxAttempts, &r.RunAt, &r.LastError, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// InsertQueueJob stores a new queue job record.
+func (s *SQLStore) InsertQueueJob(rec *QueueJob) error {
+	now := time.Now().UTC()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	if rec.RunAt.IsZero() {
+		rec.RunAt = now
+	}
+	rec.UpdatedAt = now
+	_, err := s.db.Exec(`INSERT INTO queue_jobs (`+queueJobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, rec.Type, rec.Payload, rec.Priority, rec.Status, rec.Attempts, rec.MaxAttempts, rec.RunAt.UTC(), rec.LastError, rec.CreatedAt.UTC(), rec.UpdatedAt)
+	return err
+}
+
+// UpdateQueueJob persists changes to an existing queue job.
+func (s *SQLStore) UpdateQueueJob(rec *QueueJob) error {
+	rec.UpdatedAt = time.Now().UTC()
+	_, err := s.db.Exec(`UPDATE queue_jobs SET priority = ?, status = ?, attempts = ?, max_attempts = ?, run_at = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+		rec.Priority, rec.Status, rec.Attempts, rec.MaxAttempts, rec.RunAt.UTC(), rec.LastError, rec.UpdatedAt, rec.ID)
+	return err
+}
+
+// GetQueueJob retrieves a queue job by ID. Returns nil if not found.
+func (s *SQLStore) GetQueueJob(id string) (*QueueJob, error) {
+	rec, err := scanQueueJob(s.db.QueryRow(`SELECT `+queueJobColumns+` FROM queue_jobs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return rec, err
+}
+
+// ListQueueJobs returns jobs with the given status, or all jobs when status is empty.
+func (s *SQLStore) ListQueueJobs(status string) ([]QueueJob, error) {
+	rows, err := s.db.Query(`SELECT `+queueJobColumns+` FROM queue_jobs WHERE ? = '' OR status = ? ORDER BY priority DESC, run_at ASC, created_at ASC`, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recs []QueueJob
+	for rows.Next() {
+		rec, err := scanQueueJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, rows.Err()
+}
+
+// ClaimQueueJob marks the highest priority pending job due at or before now as
+// running, incrementing its attempt counter. Returns nil when none is ready.
+func (s *SQLStore) ClaimQueueJob(now time.Time) (*QueueJob, error) {
+	for {
+		var id string
+		err := s.db.QueryRow(`SELECT id FROM queue_jobs WHERE status = 'pending' AND run_at <= ? ORDER BY priority DESC, run_at ASC, created_at ASC LIMIT 1`, now.UTC()).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res, err := s.db.Exec(`UPDATE queue_jobs SET status = 'running', attempts = attempts + 1, updated_at = ? WHERE id = ? AND status = 'pending'`, time.Now().UTC(), id)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 0 {
+			// Another worker claimed the job first; look for the next one.
+			continue
+		}
+		return s.GetQueueJob(id)
+	}
+}
+
+// CancelQueueJob marks the job id cancelled if it is still pending and
+// reports whether it did.
+func (s *SQLStore) CancelQueueJob(id string) (bool, error) {
+	res, err := s.db.Exec(`UPDATE queue_jobs SET status = 'cancelled', updated_at = ? WHERE id = ? AND status = 'pending'`, time.Now().UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteQueueJob removes a queue job by ID.
+func (s *SQLStore) DeleteQueueJob(id string) error {
+	_, err := s.db.Exec(`DELETE FROM queue_jobs WHERE id = ?`, id)
+	return err
+}
+
+// fileIndexColumns lists the file_index columns in scan order.
+const fileIndexColumns = `path, size, mod_time, media_hash, results, scanned_at`
+
+// scanFileIndexEntry reads a file_index row selected with fileIndexColumns.
+func scanFileIndexEntry(row rowScanner) (FileIndexEntry, error) {
+	var e FileIndexEntry
+	var results string
+	if err := row.Scan(&e.Path, &e.Size, &e.ModTime, &e.MediaHash, &results, &e.ScannedAt); err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal([]byte(results), &e.Results); err != nil {
+		return e, fmt.Errorf("file index %s: %w", e.Path, err)
+	}
+	return e, nil
+}
+
+// ListFileIndexEntries returns the index entries of files below dir, or every
+// entry when dir is empty.
+func (s *SQLStore) ListFileIndexEntries(dir string) ([]FileIndexEntry, error) {
+	return listFileIndexSQL(sqlSnapshot{db: s.db}, dir)
+}
+
+// UpsertFileIndexEntry stores the index entry for a file.
+func (s *SQLStore) UpsertFileIndexEntry(e *FileIndexEntry) error {
+	return upsertFileIndexSQL(sqlSnapshot{db: s.db}, e)
+}
+
+// DeleteFileIndexEntry removes the index entry for path.
+func (s *SQLStore) DeleteFileIndexEntry(path string) error {
+	_, err := s.db.Exec(`DELETE FROM file_index WHERE path = ?`, path)
+	return err
+}
+
+// fileIndexPrefix returns the path prefix of files below dir.
+func fileIndexPrefix(dir string) string {
+	dir = filepath.Clean(dir)
+	if strings.HasSuffix(dir, string(filepath.Separator)) {
+		return dir
+	}
+	return dir + string(filepath.Separator)
+}
+
+// listFileIndexSQL implements ListFileIndexEntries for the SQLite and
+// PostgreSQL schemas.
+func listFileIndexSQL(s sqlSnapshot, dir string) ([]FileIndexEntry, error) {
+	query := `SELECT ` + fileIndexColumns + ` FROM file_index`
+	var args []any
+	if dir != "" {
+		prefix := fileIndexPrefix(dir)
+		// substr counts characters in both databases.
+		query += ` WHERE substr(path, 1, ?) = ?`
+		args = append(args, utf8.RuneCountInString(prefix), prefix)
+	}
+	rows, err := s.db.Query(s.rebind(query+` ORDER BY path`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []FileIndexEntry
+	for rows.Next() {
+		e, err := scanFileIndexEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// upsertFileIndexSQL implements UpsertFileIndexEntry for the SQLite and
+// PostgreSQL schemas.
+func upsertFileIndexSQL(s sqlSnapshot, e *FileIndexEntry) error {
+	if e.ScannedAt.IsZero() {
+		e.ScannedAt = time.Now()
+	}
+	if e.Results == nil {
+		e.Results = map[string]string{}
+	}
+	results, err := json.Marshal(e.Results)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`INSERT INTO file_index (`+fileIndexColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET size = excluded.size, mod_time = excluded.mod_time, media_hash = excluded.media_hash, results = excluded.results, scanned_at = excluded.scanned_at`),
+		e.Path, e.Size, e.ModTime.UTC(), e.MediaHash, string(results), e.ScannedAt.UTC())
+	return err
+}
+
 // Authentication methods for SQLStore
 
 // CreateUser creates a new user with hashed password and returns user ID (placeholder - delegates to gcommonauth).
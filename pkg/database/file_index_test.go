@@ -0,0 +1,64 @@
+// file: pkg/database/file_index_test.go
+// version: 1.0.0
+// guid: 5e2c8a14-7b3f-4d96-a1e0-9c6f3b8d2a57
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFileIndex exercises file index persistence on every available backend.
+func TestFileIndex(t *testing.T) {
+	backends := []string{"pebble"}
+	if HasSQLite() {
+		backends = append(backends, "sqlite")
+	}
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			path := t.TempDir()
+			if backend == "sqlite" {
+				path = filepath.Join(path, "index.db")
+			}
+			store, err := OpenStore(path, backend)
+			require.NoError(t, err)
+			defer store.Close()
+			testFileIndex(t, store)
+		})
+	}
+}
+
+func testFileIndex(t *testing.T, store SubtitleStore) {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, p := range []string{"/tv/Show/a.mkv", "/tv/Show/b.mkv", "/tv/Showcase/c.mkv", "/movies/d.mkv"} {
+		require.NoError(t, store.UpsertFileIndexEntry(&FileIndexEntry{Path: p, Size: 1, ModTime: mtime}))
+	}
+	require.NoError(t, store.UpsertFileIndexEntry(&FileIndexEntry{
+		Path: "/tv/Show/a.mkv", Size: 2, ModTime: mtime, MediaHash: "0123456789abcdef",
+		Results: map[string]string{"subtitles:en": "satisfied"},
+	}))
+
+	entries, err := store.ListFileIndexEntries("/tv/Show")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/tv/Show/a.mkv", entries[0].Path)
+	assert.Equal(t, int64(2), entries[0].Size)
+	assert.True(t, entries[0].ModTime.Equal(mtime))
+	assert.Equal(t, "0123456789abcdef", entries[0].MediaHash)
+	assert.Equal(t, "satisfied", entries[0].Results["subtitles:en"])
+	assert.False(t, entries[0].ScannedAt.IsZero())
+
+	all, err := store.ListFileIndexEntries("")
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	require.NoError(t, store.DeleteFileIndexEntry("/tv/Show/b.mkv"))
+	entries, err = store.ListFileIndexEntries("/tv/Show/")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
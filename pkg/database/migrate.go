@@ -1,41 +1,126 @@
+// file: pkg/database/migrate.go
+// version: 1.1.0
+// guid: 8c3e5a17-2b9d-4f64-9e01-7a6d4c2b8f35
+
 package database
 
-// Migrate copies subtitle history and download records from src to dest.
-func Migrate(src, dest SubtitleStore) error {
-	recs, err := src.ListSubtitles()
-	if err != nil {
-		return err
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrVerificationFailed is returned by Migrate when the destination holds
+// fewer records of some entity than were copied from the source.
+var ErrVerificationFailed = errors.New("migration verification failed")
+
+// MigrateOptions controls MigrateWithReport.
+type MigrateOptions struct {
+	// DryRun compares source and destination record counts without writing.
+	DryRun bool
+}
+
+// EntityReport compares the record counts of one entity.
+type EntityReport struct {
+	Entity string
+	// Source is the number of records read from the source store.
+	Source int
+	// Target is the number of records in the destination after migrating,
+	// or before when dry running.
+	Target int
+	// Skipped is the number of source records the destination could not
+	// store, such as sessions of users missing from the source.
+	Skipped int
+}
+
+// OK reports whether the destination holds every record that could be
+// stored. Destinations may hold more records than the source when they
+// already contained data.
+func (e EntityReport) OK() bool { return e.Target >= e.Source-e.Skipped }
+
+// MigrationReport summarizes a migration or dry run per entity.
+type MigrationReport struct {
+	DryRun   bool
+	Entities []EntityReport
+}
+
+// Verified reports whether every entity passed verification.
+func (r *MigrationReport) Verified() bool {
+	for _, e := range r.Entities {
+		if !e.OK() {
+			return false
+		}
 	}
-	downloads, err := src.ListDownloads()
-	if err != nil {
-		return err
+	return true
+}
+
+// String formats the report as a table.
+func (r *MigrationReport) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENTITY\tSOURCE\tTARGET\tSKIPPED\tSTATUS")
+	for _, e := range r.Entities {
+		status := "ok"
+		switch {
+		case r.DryRun && e.Target > 0:
+			status = "target not empty"
+		case r.DryRun:
+			status = "-"
+		case !e.OK():
+			status = "MISMATCH"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", e.Entity, e.Source, e.Target, e.Skipped, status)
 	}
-	media, err := src.ListMediaItems()
+	w.Flush()
+	return b.String()
+}
+
+// Migrate copies every entity from src to dest and verifies the record
+// counts afterwards. IDs are preserved where the destination allows; see
+// SnapshotStore.
+func Migrate(src, dest SubtitleStore) error {
+	_, err := MigrateWithReport(src, dest, MigrateOptions{})
+	return err
+}
+
+// MigrateWithReport copies every entity from src to dest, or only compares
+// record counts when opts.DryRun is set, and returns a per entity report.
+// ErrVerificationFailed is returned along with the report when dest holds
+// fewer records than were copied.
+func MigrateWithReport(src, dest SubtitleStore, opts MigrateOptions) (*MigrationReport, error) {
+	snap, err := ExportSnapshot(src)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("export source: %w", err)
 	}
-	for _, r := range recs {
-		rec := r
-		if err := dest.InsertSubtitle(&rec); err != nil {
-			return err
+	var skipped map[string]int
+	if !opts.DryRun {
+		if skipped, err = ImportSnapshot(dest, snap); err != nil {
+			return nil, fmt.Errorf("import destination: %w", err)
 		}
 	}
-	for _, d := range downloads {
-		dr := d
-		if err := dest.InsertDownload(&dr); err != nil {
-			return err
-		}
+	after, err := ExportSnapshot(dest)
+	if err != nil {
+		return nil, fmt.Errorf("export destination: %w", err)
 	}
-	for _, m := range media {
-		mr := m
-		if err := dest.InsertMediaItem(&mr); err != nil {
-			return err
-		}
+
+	report := &MigrationReport{DryRun: opts.DryRun}
+	srcCounts, destCounts := snap.Counts(), after.Counts()
+	for _, name := range snapshotEntities {
+		report.Entities = append(report.Entities, EntityReport{
+			Entity:  name,
+			Source:  srcCounts[name],
+			Target:  destCounts[name],
+			Skipped: skipped[name],
+		})
+	}
+	if !opts.DryRun && !report.Verified() {
+		return report, ErrVerificationFailed
 	}
-	return nil
+	return report, nil
 }
 
-// MigrateToPebble copies subtitle history from a SQLite database into a Pebble store.
+// MigrateToPebble copies every entity from a SQLite database into a Pebble store.
 func MigrateToPebble(sqlitePath, pebblePath string) error {
 	sqlStore, err := OpenSQLStore(sqlitePath)
 	if err != nil {
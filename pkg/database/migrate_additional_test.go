@@ -1,5 +1,5 @@
 // file: pkg/database/migrate_additional_test.go
-// version: 1.0.0
+// version: 1.2.0
 // guid: b0bf3918-666d-47a5-990c-fb8f6eecc709
 
 package database_test
@@ -14,6 +14,17 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// expectEmptyExtras allows the listings Migrate reads beyond subtitles,
+// downloads and media items, returning no records.
+func expectEmptyExtras(store *mocks.MockSubtitleStore) {
+	store.EXPECT().ListTags().Return(nil, nil).Maybe()
+	store.EXPECT().ListLanguageProfiles().Return(nil, nil).Maybe()
+	store.EXPECT().ListSubtitleSources("", 0).Return(nil, nil).Maybe()
+	store.EXPECT().ListMonitoredItems().Return(nil, nil).Maybe()
+	store.EXPECT().ListQueueJobs("").Return(nil, nil).Maybe()
+	store.EXPECT().ListFileIndexEntries("").Return(nil, nil).Maybe()
+}
+
 func TestMigrate_Success_CopiesRecords(t *testing.T) {
 	// Arrange
 	src := mocks.NewMockSubtitleStore(t)
@@ -33,6 +44,7 @@ func TestMigrate_Success_CopiesRecords(t *testing.T) {
 	src.EXPECT().ListSubtitles().Return(subtitles, nil).Once()
 	src.EXPECT().ListDownloads().Return(downloads, nil).Once()
 	src.EXPECT().ListMediaItems().Return(media, nil).Once()
+	expectEmptyExtras(src)
 
 	dest.EXPECT().InsertSubtitle(mock.MatchedBy(func(rec *database.SubtitleRecord) bool {
 		return rec.File == subtitles[0].File && rec.VideoFile == subtitles[0].VideoFile
@@ -46,6 +58,10 @@ func TestMigrate_Success_CopiesRecords(t *testing.T) {
 	dest.EXPECT().InsertMediaItem(mock.MatchedBy(func(rec *database.MediaItem) bool {
 		return rec.Path == media[0].Path && rec.Title == media[0].Title
 	})).Return(nil).Once()
+	dest.EXPECT().ListSubtitles().Return(subtitles, nil).Once()
+	dest.EXPECT().ListDownloads().Return(downloads, nil).Once()
+	dest.EXPECT().ListMediaItems().Return(media, nil).Once()
+	expectEmptyExtras(dest)
 
 	// Act
 	err := database.Migrate(src, dest)
@@ -116,6 +132,7 @@ func TestMigrate_InsertSubtitleError_ReturnsError(t *testing.T) {
 	src.EXPECT().ListSubtitles().Return(subtitles, nil).Once()
 	src.EXPECT().ListDownloads().Return([]database.DownloadRecord{}, nil).Once()
 	src.EXPECT().ListMediaItems().Return([]database.MediaItem{}, nil).Once()
+	expectEmptyExtras(src)
 
 	dest.EXPECT().InsertSubtitle(mock.MatchedBy(func(rec *database.SubtitleRecord) bool {
 		return rec.File == subtitles[0].File && rec.VideoFile == subtitles[0].VideoFile
@@ -141,6 +158,7 @@ func TestMigrate_InsertDownloadError_ReturnsError(t *testing.T) {
 	src.EXPECT().ListSubtitles().Return([]database.SubtitleRecord{}, nil).Once()
 	src.EXPECT().ListDownloads().Return(downloads, nil).Once()
 	src.EXPECT().ListMediaItems().Return([]database.MediaItem{}, nil).Once()
+	expectEmptyExtras(src)
 
 	dest.EXPECT().InsertDownload(mock.MatchedBy(func(rec *database.DownloadRecord) bool {
 		return rec.File == downloads[0].File && rec.VideoFile == downloads[0].VideoFile
@@ -167,6 +185,7 @@ func TestMigrate_InsertMediaItemError_ReturnsError(t *testing.T) {
 	src.EXPECT().ListSubtitles().Return([]database.SubtitleRecord{}, nil).Once()
 	src.EXPECT().ListDownloads().Return([]database.DownloadRecord{}, nil).Once()
 	src.EXPECT().ListMediaItems().Return(media, nil).Once()
+	expectEmptyExtras(src)
 
 	dest.EXPECT().InsertMediaItem(mock.MatchedBy(func(rec *database.MediaItem) bool {
 		return rec.Path == media[0].Path && rec.Title == media[0].Title
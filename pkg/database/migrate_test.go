@@ -1,6 +1,10 @@
 package database
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestMigrateToPebble(t *testing.T) {
 	sqlitePath := t.TempDir() + "/test.db"
@@ -53,3 +57,160 @@ func TestMigrateToPebble(t *testing.T) {
 		t.Fatalf("unexpected download records %+v", dRecs)
 	}
 }
+
+func TestMigrateAllEntitiesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src, err := OpenSQLStore(dir + "/src.db")
+	if err != nil {
+		t.Skip("SQLite not available, skipping migration test:", err)
+	}
+	defer src.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := src.db.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	exec(`INSERT INTO users (id, username, password_hash, email, role, created_at) VALUES (7, 'alice', 'hash', 'a@example.com', 'admin', ?)`, now)
+	exec(`INSERT INTO sessions (user_id, token, expires_at, created_at) VALUES (7, 'sess', ?, ?)`, now.Add(time.Hour), now)
+	exec(`INSERT INTO api_keys (user_id, key, created_at) VALUES (7, 'key', ?)`, now)
+	exec(`INSERT INTO api_keys (user_id, key, created_at) VALUES (99, 'orphan', ?)`, now)
+	exec(`INSERT INTO dashboard_prefs (user_id, layout, updated_at) VALUES (7, '{"a":1}', ?)`, now)
+	if err := src.InsertMediaItem(&MediaItem{Path: "/tv/a.mkv", Title: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetMediaHashes("/tv/a.mkv", map[string]string{"napiprojekt": "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertSubtitle(&SubtitleRecord{File: "a.srt", VideoFile: "/tv/a.mkv", Language: "en", Service: "os"}); err != nil {
+		t.Fatal(err)
+	}
+	parent := "1"
+	if err := src.InsertSubtitle(&SubtitleRecord{File: "a.fr.srt", VideoFile: "/tv/a.mkv", Language: "fr", Service: "translate", ParentID: &parent}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertSubtitleScore(&SubtitleScore{SubtitleID: "1", ProviderName: "os", TotalScore: 0.9, ScoreVersion: "1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertSubtitleSource(&SubtitleSource{SourceHash: "h", OriginalURL: "http://x", Provider: "os", LastSeen: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertTag("favorite"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AssignTagToUser(7, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AssignTagToMedia(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AssignProfileToMedia("1", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertMonitoredItem(&MonitoredItem{MediaID: "1", Path: "/tv/a.mkv", Languages: `["en"]`, Status: "pending", LastChecked: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertQueueJob(&QueueJob{ID: "job-1", Type: "scan", Status: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.UpsertFileIndexEntry(&FileIndexEntry{Path: "/tv/a.mkv", Size: 10, ModTime: now, MediaHash: "abc", Results: map[string]string{"subtitles:en": "satisfied"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pebble keeps every source ID; the empty SQLite target keeps numeric IDs.
+	mid, err := OpenPebble(dir + "/pebble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mid.Close()
+	report, err := MigrateWithReport(src, mid, MigrateOptions{})
+	if err != nil {
+		t.Fatalf("migrate to pebble: %v\n%s", err, report)
+	}
+	for _, e := range report.Entities {
+		if e.Entity == entityAPIKeys && (e.Source != 2 || e.Target != 1 || e.Skipped != 1) {
+			t.Errorf("orphaned api key: %+v", e)
+		}
+	}
+	dest, err := OpenSQLStore(dir + "/dest.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dest.Close()
+	if report, err = MigrateWithReport(mid, dest, MigrateOptions{}); err != nil {
+		t.Fatalf("migrate to sqlite: %v\n%s", err, report)
+	}
+
+	want := map[string]int{
+		entityUsers: 1, entitySessions: 1, entityAPIKeys: 1, entityDashboardPrefs: 1,
+		entityMediaItems: 1, entitySubtitles: 2, entitySubtitleScores: 1, entitySubtitleSources: 1,
+		entityTags: 1, entityTagAssociations: 2, entityMediaProfiles: 1, entityMonitoredItems: 1,
+		entityQueueJobs: 1, entityLanguageProfiles: 1, entityFileIndex: 1,
+	}
+	for _, e := range report.Entities {
+		if e.Source != want[e.Entity] {
+			t.Errorf("%s: source %d, want %d", e.Entity, e.Source, want[e.Entity])
+		}
+	}
+
+	snap, err := dest.ExportSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := snap.Users[0]; u.ID != "7" || u.PasswordHash != "hash" || !u.CreatedAt.Equal(now) {
+		t.Errorf("user not preserved: %+v", u)
+	}
+	if s := snap.Sessions[0]; s.UserID != "7" || s.Token != "sess" {
+		t.Errorf("session not preserved: %+v", s)
+	}
+	if hashes, _ := dest.GetMediaHashes("/tv/a.mkv"); hashes["napiprojekt"] != "abc" {
+		t.Errorf("media hashes not preserved: %v", hashes)
+	}
+	if r := snap.Subtitles[1]; r.ParentID == nil || *r.ParentID != snap.Subtitles[0].ID {
+		t.Errorf("subtitle parent not preserved: %+v", r)
+	}
+	if tags, _ := dest.ListTagsForUser(7); len(tags) != 1 || tags[0].Name != "favorite" {
+		t.Errorf("user tags = %+v", tags)
+	}
+	if tags, _ := dest.ListTagsForMedia(1); len(tags) != 1 {
+		t.Errorf("media tags = %+v", tags)
+	}
+	if layout, _ := GetDashboardLayout(dest.db, 7); layout != `{"a":1}` {
+		t.Errorf("dashboard layout = %q", layout)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	dir := t.TempDir()
+	src, err := OpenSQLStore(dir + "/src.db")
+	if err != nil {
+		t.Skip("SQLite not available, skipping migration test:", err)
+	}
+	defer src.Close()
+	if err := src.InsertSubtitle(&SubtitleRecord{File: "a.srt", VideoFile: "a.mkv", Language: "en", Service: "g"}); err != nil {
+		t.Fatal(err)
+	}
+	dest, err := OpenPebble(dir + "/pebble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dest.Close()
+
+	report, err := MigrateWithReport(src, dest, MigrateOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := dest.CountSubtitles(); n != 0 {
+		t.Fatalf("dry run wrote %d subtitles", n)
+	}
+	for _, e := range report.Entities {
+		if e.Entity == entitySubtitles && (e.Source != 1 || e.Target != 0) {
+			t.Fatalf("subtitles = %+v", e)
+		}
+	}
+	if !strings.Contains(report.String(), "subtitles") {
+		t.Fatalf("report missing entity:\n%s", report)
+	}
+}
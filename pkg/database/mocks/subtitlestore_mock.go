@@ -210,6 +210,130 @@ func (_c *MockSubtitleStore_AssignTagToUser_Call) RunAndReturn(run func(userID i
 	return _c
 }
 
+// CancelQueueJob provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) CancelQueueJob(id string) (bool, error) {
+	ret := _mock.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelQueueJob")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (bool, error)); ok {
+		return returnFunc(id)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = returnFunc(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(bool)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockSubtitleStore_CancelQueueJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CancelQueueJob'
+type MockSubtitleStore_CancelQueueJob_Call struct {
+	*mock.Call
+}
+
+// CancelQueueJob is a helper method to define mock.On call
+//   - id string
+func (_e *MockSubtitleStore_Expecter) CancelQueueJob(id interface{}) *MockSubtitleStore_CancelQueueJob_Call {
+	return &MockSubtitleStore_CancelQueueJob_Call{Call: _e.mock.On("CancelQueueJob", id)}
+}
+
+func (_c *MockSubtitleStore_CancelQueueJob_Call) Run(run func(id string)) *MockSubtitleStore_CancelQueueJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_CancelQueueJob_Call) Return(b bool, err error) *MockSubtitleStore_CancelQueueJob_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_CancelQueueJob_Call) RunAndReturn(run func(id string) (bool, error)) *MockSubtitleStore_CancelQueueJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ClaimQueueJob provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) ClaimQueueJob(now time.Time) (*database.QueueJob, error) {
+	ret := _mock.Called(now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimQueueJob")
+	}
+
+	var r0 *database.QueueJob
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(time.Time) (*database.QueueJob, error)); ok {
+		return returnFunc(now)
+	}
+	if returnFunc, ok := ret.Get(0).(func(time.Time) *database.QueueJob); ok {
+		r0 = returnFunc(now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*database.QueueJob)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = returnFunc(now)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockSubtitleStore_ClaimQueueJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ClaimQueueJob'
+type MockSubtitleStore_ClaimQueueJob_Call struct {
+	*mock.Call
+}
+
+// ClaimQueueJob is a helper method to define mock.On call
+//   - now time.Time
+func (_e *MockSubtitleStore_Expecter) ClaimQueueJob(now interface{}) *MockSubtitleStore_ClaimQueueJob_Call {
+	return &MockSubtitleStore_ClaimQueueJob_Call{Call: _e.mock.On("ClaimQueueJob", now)}
+}
+
+func (_c *MockSubtitleStore_ClaimQueueJob_Call) Run(run func(now time.Time)) *MockSubtitleStore_ClaimQueueJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Time
+		if args[0] != nil {
+			arg0 = args[0].(time.Time)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_ClaimQueueJob_Call) Return(queueJob *database.QueueJob, err error) *MockSubtitleStore_ClaimQueueJob_Call {
+	_c.Call.Return(queueJob, err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_ClaimQueueJob_Call) RunAndReturn(run func(now time.Time) (*database.QueueJob, error)) *MockSubtitleStore_ClaimQueueJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // CleanupExpiredSessions provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) CleanupExpiredSessions() error {
 	ret := _mock.Called()
@@ -820,6 +944,57 @@ func (_c *MockSubtitleStore_DeleteDownload_Call) RunAndReturn(run func(file stri
 	return _c
 }
 
+// DeleteFileIndexEntry provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) DeleteFileIndexEntry(path string) error {
+	ret := _mock.Called(path)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteFileIndexEntry")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string) error); ok {
+		r0 = returnFunc(path)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockSubtitleStore_DeleteFileIndexEntry_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteFileIndexEntry'
+type MockSubtitleStore_DeleteFileIndexEntry_Call struct {
+	*mock.Call
+}
+
+// DeleteFileIndexEntry is a helper method to define mock.On call
+//   - path string
+func (_e *MockSubtitleStore_Expecter) DeleteFileIndexEntry(path interface{}) *MockSubtitleStore_DeleteFileIndexEntry_Call {
+	return &MockSubtitleStore_DeleteFileIndexEntry_Call{Call: _e.mock.On("DeleteFileIndexEntry", path)}
+}
+
+func (_c *MockSubtitleStore_DeleteFileIndexEntry_Call) Run(run func(path string)) *MockSubtitleStore_DeleteFileIndexEntry_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_DeleteFileIndexEntry_Call) Return(err error) *MockSubtitleStore_DeleteFileIndexEntry_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_DeleteFileIndexEntry_Call) RunAndReturn(run func(path string) error) *MockSubtitleStore_DeleteFileIndexEntry_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DeleteLanguageProfile provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) DeleteLanguageProfile(id string) error {
 	ret := _mock.Called(id)
@@ -973,6 +1148,57 @@ func (_c *MockSubtitleStore_DeleteMonitoredItem_Call) RunAndReturn(run func(id s
 	return _c
 }
 
+// DeleteQueueJob provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) DeleteQueueJob(id string) error {
+	ret := _mock.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteQueueJob")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string) error); ok {
+		r0 = returnFunc(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockSubtitleStore_DeleteQueueJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteQueueJob'
+type MockSubtitleStore_DeleteQueueJob_Call struct {
+	*mock.Call
+}
+
+// DeleteQueueJob is a helper method to define mock.On call
+//   - id string
+func (_e *MockSubtitleStore_Expecter) DeleteQueueJob(id interface{}) *MockSubtitleStore_DeleteQueueJob_Call {
+	return &MockSubtitleStore_DeleteQueueJob_Call{Call: _e.mock.On("DeleteQueueJob", id)}
+}
+
+func (_c *MockSubtitleStore_DeleteQueueJob_Call) Run(run func(id string)) *MockSubtitleStore_DeleteQueueJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_DeleteQueueJob_Call) Return(err error) *MockSubtitleStore_DeleteQueueJob_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_DeleteQueueJob_Call) RunAndReturn(run func(id string) error) *MockSubtitleStore_DeleteQueueJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DeleteSubtitle provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) DeleteSubtitle(file string) error {
 	ret := _mock.Called(file)
@@ -1425,6 +1651,68 @@ func (_c *MockSubtitleStore_GetMediaFieldLocks_Call) RunAndReturn(run func(path
 	return _c
 }
 
+// GetMediaHashes provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) GetMediaHashes(path string) (map[string]string, error) {
+	ret := _mock.Called(path)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMediaHashes")
+	}
+
+	var r0 map[string]string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (map[string]string, error)); ok {
+		return returnFunc(path)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) map[string]string); ok {
+		r0 = returnFunc(path)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(path)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockSubtitleStore_GetMediaHashes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetMediaHashes'
+type MockSubtitleStore_GetMediaHashes_Call struct {
+	*mock.Call
+}
+
+// GetMediaHashes is a helper method to define mock.On call
+//   - path string
+func (_e *MockSubtitleStore_Expecter) GetMediaHashes(path interface{}) *MockSubtitleStore_GetMediaHashes_Call {
+	return &MockSubtitleStore_GetMediaHashes_Call{Call: _e.mock.On("GetMediaHashes", path)}
+}
+
+func (_c *MockSubtitleStore_GetMediaHashes_Call) Run(run func(path string)) *MockSubtitleStore_GetMediaHashes_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_GetMediaHashes_Call) Return(stringToString map[string]string, err error) *MockSubtitleStore_GetMediaHashes_Call {
+	_c.Call.Return(stringToString, err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_GetMediaHashes_Call) RunAndReturn(run func(path string) (map[string]string, error)) *MockSubtitleStore_GetMediaHashes_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetMediaItem provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) GetMediaItem(path string) (*database.MediaItem, error) {
 	ret := _mock.Called(path)
@@ -1671,6 +1959,68 @@ func (_c *MockSubtitleStore_GetMonitoredItemsToCheck_Call) RunAndReturn(run func
 	return _c
 }
 
+// GetQueueJob provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) GetQueueJob(id string) (*database.QueueJob, error) {
+	ret := _mock.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetQueueJob")
+	}
+
+	var r0 *database.QueueJob
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (*database.QueueJob, error)); ok {
+		return returnFunc(id)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) *database.QueueJob); ok {
+		r0 = returnFunc(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*database.QueueJob)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockSubtitleStore_GetQueueJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetQueueJob'
+type MockSubtitleStore_GetQueueJob_Call struct {
+	*mock.Call
+}
+
+// GetQueueJob is a helper method to define mock.On call
+//   - id string
+func (_e *MockSubtitleStore_Expecter) GetQueueJob(id interface{}) *MockSubtitleStore_GetQueueJob_Call {
+	return &MockSubtitleStore_GetQueueJob_Call{Call: _e.mock.On("GetQueueJob", id)}
+}
+
+func (_c *MockSubtitleStore_GetQueueJob_Call) Run(run func(id string)) *MockSubtitleStore_GetQueueJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_GetQueueJob_Call) Return(queueJob *database.QueueJob, err error) *MockSubtitleStore_GetQueueJob_Call {
+	_c.Call.Return(queueJob, err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_GetQueueJob_Call) RunAndReturn(run func(id string) (*database.QueueJob, error)) *MockSubtitleStore_GetQueueJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetSubtitleSource provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) GetSubtitleSource(sourceHash string) (*database.SubtitleSource, error) {
 	ret := _mock.Called(sourceHash)
@@ -2072,6 +2422,57 @@ func (_c *MockSubtitleStore_InsertMonitoredItem_Call) RunAndReturn(run func(rec
 	return _c
 }
 
+// InsertQueueJob provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) InsertQueueJob(rec *database.QueueJob) error {
+	ret := _mock.Called(rec)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertQueueJob")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(*database.QueueJob) error); ok {
+		r0 = returnFunc(rec)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockSubtitleStore_InsertQueueJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'InsertQueueJob'
+type MockSubtitleStore_InsertQueueJob_Call struct {
+	*mock.Call
+}
+
+// InsertQueueJob is a helper method to define mock.On call
+//   - rec *database.QueueJob
+func (_e *MockSubtitleStore_Expecter) InsertQueueJob(rec interface{}) *MockSubtitleStore_InsertQueueJob_Call {
+	return &MockSubtitleStore_InsertQueueJob_Call{Call: _e.mock.On("InsertQueueJob", rec)}
+}
+
+func (_c *MockSubtitleStore_InsertQueueJob_Call) Run(run func(rec *database.QueueJob)) *MockSubtitleStore_InsertQueueJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 *database.QueueJob
+		if args[0] != nil {
+			arg0 = args[0].(*database.QueueJob)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_InsertQueueJob_Call) Return(err error) *MockSubtitleStore_InsertQueueJob_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_InsertQueueJob_Call) RunAndReturn(run func(rec *database.QueueJob) error) *MockSubtitleStore_InsertQueueJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // InsertSubtitle provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) InsertSubtitle(rec *database.SubtitleRecord) error {
 	ret := _mock.Called(rec)
@@ -2444,6 +2845,68 @@ func (_c *MockSubtitleStore_ListDownloadsByVideo_Call) RunAndReturn(run func(vid
 	return _c
 }
 
+// ListFileIndexEntries provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) ListFileIndexEntries(dir string) ([]database.FileIndexEntry, error) {
+	ret := _mock.Called(dir)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListFileIndexEntries")
+	}
+
+	var r0 []database.FileIndexEntry
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) ([]database.FileIndexEntry, error)); ok {
+		return returnFunc(dir)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) []database.FileIndexEntry); ok {
+		r0 = returnFunc(dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]database.FileIndexEntry)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockSubtitleStore_ListFileIndexEntries_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListFileIndexEntries'
+type MockSubtitleStore_ListFileIndexEntries_Call struct {
+	*mock.Call
+}
+
+// ListFileIndexEntries is a helper method to define mock.On call
+//   - dir string
+func (_e *MockSubtitleStore_Expecter) ListFileIndexEntries(dir interface{}) *MockSubtitleStore_ListFileIndexEntries_Call {
+	return &MockSubtitleStore_ListFileIndexEntries_Call{Call: _e.mock.On("ListFileIndexEntries", dir)}
+}
+
+func (_c *MockSubtitleStore_ListFileIndexEntries_Call) Run(run func(dir string)) *MockSubtitleStore_ListFileIndexEntries_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_ListFileIndexEntries_Call) Return(fileIndexEntrys []database.FileIndexEntry, err error) *MockSubtitleStore_ListFileIndexEntries_Call {
+	_c.Call.Return(fileIndexEntrys, err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_ListFileIndexEntries_Call) RunAndReturn(run func(dir string) ([]database.FileIndexEntry, error)) *MockSubtitleStore_ListFileIndexEntries_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ListLanguageProfiles provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) ListLanguageProfiles() ([]database.LanguageProfile, error) {
 	ret := _mock.Called()
@@ -2609,6 +3072,68 @@ func (_c *MockSubtitleStore_ListMonitoredItems_Call) RunAndReturn(run func() ([]
 	return _c
 }
 
+// ListQueueJobs provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) ListQueueJobs(status string) ([]database.QueueJob, error) {
+	ret := _mock.Called(status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListQueueJobs")
+	}
+
+	var r0 []database.QueueJob
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) ([]database.QueueJob, error)); ok {
+		return returnFunc(status)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) []database.QueueJob); ok {
+		r0 = returnFunc(status)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]database.QueueJob)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(status)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockSubtitleStore_ListQueueJobs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListQueueJobs'
+type MockSubtitleStore_ListQueueJobs_Call struct {
+	*mock.Call
+}
+
+// ListQueueJobs is a helper method to define mock.On call
+//   - status string
+func (_e *MockSubtitleStore_Expecter) ListQueueJobs(status interface{}) *MockSubtitleStore_ListQueueJobs_Call {
+	return &MockSubtitleStore_ListQueueJobs_Call{Call: _e.mock.On("ListQueueJobs", status)}
+}
+
+func (_c *MockSubtitleStore_ListQueueJobs_Call) Run(run func(status string)) *MockSubtitleStore_ListQueueJobs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_ListQueueJobs_Call) Return(queueJobs []database.QueueJob, err error) *MockSubtitleStore_ListQueueJobs_Call {
+	_c.Call.Return(queueJobs, err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_ListQueueJobs_Call) RunAndReturn(run func(status string) ([]database.QueueJob, error)) *MockSubtitleStore_ListQueueJobs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ListSubtitleSources provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) ListSubtitleSources(provider string, limit int) ([]database.SubtitleSource, error) {
 	ret := _mock.Called(provider, limit)
@@ -3028,6 +3553,63 @@ func (_c *MockSubtitleStore_ListUsers_Call) RunAndReturn(run func() ([]*common.U
 	return _c
 }
 
+// MoveMediaPath provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) MoveMediaPath(oldPath string, newPath string) error {
+	ret := _mock.Called(oldPath, newPath)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MoveMediaPath")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = returnFunc(oldPath, newPath)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockSubtitleStore_MoveMediaPath_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MoveMediaPath'
+type MockSubtitleStore_MoveMediaPath_Call struct {
+	*mock.Call
+}
+
+// MoveMediaPath is a helper method to define mock.On call
+//   - oldPath string
+//   - newPath string
+func (_e *MockSubtitleStore_Expecter) MoveMediaPath(oldPath interface{}, newPath interface{}) *MockSubtitleStore_MoveMediaPath_Call {
+	return &MockSubtitleStore_MoveMediaPath_Call{Call: _e.mock.On("MoveMediaPath", oldPath, newPath)}
+}
+
+func (_c *MockSubtitleStore_MoveMediaPath_Call) Run(run func(oldPath string, newPath string)) *MockSubtitleStore_MoveMediaPath_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_MoveMediaPath_Call) Return(err error) *MockSubtitleStore_MoveMediaPath_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_MoveMediaPath_Call) RunAndReturn(run func(oldPath string, newPath string) error) *MockSubtitleStore_MoveMediaPath_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // RemoveProfileFromMedia provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) RemoveProfileFromMedia(mediaID string) error {
 	ret := _mock.Called(mediaID)
@@ -3415,6 +3997,63 @@ func (_c *MockSubtitleStore_SetMediaFieldLocks_Call) RunAndReturn(run func(path
 	return _c
 }
 
+// SetMediaHashes provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) SetMediaHashes(path string, hashes map[string]string) error {
+	ret := _mock.Called(path, hashes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetMediaHashes")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, map[string]string) error); ok {
+		r0 = returnFunc(path, hashes)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockSubtitleStore_SetMediaHashes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetMediaHashes'
+type MockSubtitleStore_SetMediaHashes_Call struct {
+	*mock.Call
+}
+
+// SetMediaHashes is a helper method to define mock.On call
+//   - path string
+//   - hashes map[string]string
+func (_e *MockSubtitleStore_Expecter) SetMediaHashes(path interface{}, hashes interface{}) *MockSubtitleStore_SetMediaHashes_Call {
+	return &MockSubtitleStore_SetMediaHashes_Call{Call: _e.mock.On("SetMediaHashes", path, hashes)}
+}
+
+func (_c *MockSubtitleStore_SetMediaHashes_Call) Run(run func(path string, hashes map[string]string)) *MockSubtitleStore_SetMediaHashes_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 map[string]string
+		if args[1] != nil {
+			arg1 = args[1].(map[string]string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_SetMediaHashes_Call) Return(err error) *MockSubtitleStore_SetMediaHashes_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_SetMediaHashes_Call) RunAndReturn(run func(path string, hashes map[string]string) error) *MockSubtitleStore_SetMediaHashes_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetMediaReleaseGroup provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) SetMediaReleaseGroup(path string, group string) error {
 	ret := _mock.Called(path, group)
@@ -3631,6 +4270,57 @@ func (_c *MockSubtitleStore_UpdateMonitoredItem_Call) RunAndReturn(run func(rec
 	return _c
 }
 
+// UpdateQueueJob provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) UpdateQueueJob(rec *database.QueueJob) error {
+	ret := _mock.Called(rec)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateQueueJob")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(*database.QueueJob) error); ok {
+		r0 = returnFunc(rec)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockSubtitleStore_UpdateQueueJob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateQueueJob'
+type MockSubtitleStore_UpdateQueueJob_Call struct {
+	*mock.Call
+}
+
+// UpdateQueueJob is a helper method to define mock.On call
+//   - rec *database.QueueJob
+func (_e *MockSubtitleStore_Expecter) UpdateQueueJob(rec interface{}) *MockSubtitleStore_UpdateQueueJob_Call {
+	return &MockSubtitleStore_UpdateQueueJob_Call{Call: _e.mock.On("UpdateQueueJob", rec)}
+}
+
+func (_c *MockSubtitleStore_UpdateQueueJob_Call) Run(run func(rec *database.QueueJob)) *MockSubtitleStore_UpdateQueueJob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 *database.QueueJob
+		if args[0] != nil {
+			arg0 = args[0].(*database.QueueJob)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_UpdateQueueJob_Call) Return(err error) *MockSubtitleStore_UpdateQueueJob_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_UpdateQueueJob_Call) RunAndReturn(run func(rec *database.QueueJob) error) *MockSubtitleStore_UpdateQueueJob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // UpdateSubtitleSourceStats provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) UpdateSubtitleSourceStats(sourceHash string, downloadCount int, successCount int, avgRating *float64) error {
 	ret := _mock.Called(sourceHash, downloadCount, successCount, avgRating)
@@ -3871,6 +4561,57 @@ func (_c *MockSubtitleStore_UpdateUserRole_Call) RunAndReturn(run func(username
 	return _c
 }
 
+// UpsertFileIndexEntry provides a mock function for the type MockSubtitleStore
+func (_mock *MockSubtitleStore) UpsertFileIndexEntry(e *database.FileIndexEntry) error {
+	ret := _mock.Called(e)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertFileIndexEntry")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(*database.FileIndexEntry) error); ok {
+		r0 = returnFunc(e)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockSubtitleStore_UpsertFileIndexEntry_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpsertFileIndexEntry'
+type MockSubtitleStore_UpsertFileIndexEntry_Call struct {
+	*mock.Call
+}
+
+// UpsertFileIndexEntry is a helper method to define mock.On call
+//   - e *database.FileIndexEntry
+func (_e *MockSubtitleStore_Expecter) UpsertFileIndexEntry(e interface{}) *MockSubtitleStore_UpsertFileIndexEntry_Call {
+	return &MockSubtitleStore_UpsertFileIndexEntry_Call{Call: _e.mock.On("UpsertFileIndexEntry", e)}
+}
+
+func (_c *MockSubtitleStore_UpsertFileIndexEntry_Call) Run(run func(e *database.FileIndexEntry)) *MockSubtitleStore_UpsertFileIndexEntry_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 *database.FileIndexEntry
+		if args[0] != nil {
+			arg0 = args[0].(*database.FileIndexEntry)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSubtitleStore_UpsertFileIndexEntry_Call) Return(err error) *MockSubtitleStore_UpsertFileIndexEntry_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockSubtitleStore_UpsertFileIndexEntry_Call) RunAndReturn(run func(e *database.FileIndexEntry) error) *MockSubtitleStore_UpsertFileIndexEntry_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ValidateAPIKey provides a mock function for the type MockSubtitleStore
 func (_mock *MockSubtitleStore) ValidateAPIKey(key string) (string, error) {
 	ret := _mock.Called(key)
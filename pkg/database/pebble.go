@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/pebble"
@@ -22,6 +23,8 @@ import (
 // Values are stored as JSON encoded SubtitleRecord structures.
 type PebbleStore struct {
 	db *pebble.DB
+	// queueMu serializes queue job claims and updates.
+	queueMu sync.Mutex
 }
 
 // InitPebbleStore seeds default permissions and language profile if missing.
@@ -89,7 +92,12 @@ func OpenPebble(path string) (*PebbleStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &PebbleStore{db: db}, nil
+	store := &PebbleStore{db: db}
+	if err := store.indexQueueJobs(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
 }
 
 // Close closes the underlying Pebble database.
@@ -454,6 +462,29 @@ func (p *PebbleStore) GetMediaFieldLocks(path string) (string, error) {
 	return item.FieldLocks, nil
 }
 
+// SetMediaHashes stores the content hashes in the media item record.
+func (p *PebbleStore) SetMediaHashes(path string, hashes map[string]string) error {
+	item, _, err := p.getMediaByPath(path)
+	if err != nil || item == nil {
+		return err
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	item.Hashes = string(data)
+	return p.InsertMediaItem(item)
+}
+
+// GetMediaHashes retrieves the content hashes of a media item.
+func (p *PebbleStore) GetMediaHashes(path string) (map[string]string, error) {
+	item, _, err := p.getMediaByPath(path)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	return decodeMediaHashes(item.Hashes)
+}
+
 // SetMediaTitle updates the title in the media item record.
 func (p *PebbleStore) SetMediaTitle(path, title string) error {
 	item, _, err := p.getMediaByPath(path)
@@ -464,6 +495,98 @@ func (p *PebbleStore) SetMediaTitle(path, title string) error {
 	return p.InsertMediaItem(item)
 }
 
+// MoveMediaPath points the records of a video moved from oldPath to newPath
+// at the new location.
+func (p *PebbleStore) MoveMediaPath(oldPath, newPath string) error {
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	item, id, err := p.getMediaByPath(oldPath)
+	if err != nil {
+		return err
+	}
+	if item != nil {
+		item.Path = newPath
+		b, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if err := batch.Set(mediaKey(id), b, nil); err != nil {
+			return err
+		}
+		if err := batch.Delete(mediaPathKey(oldPath), nil); err != nil {
+			return err
+		}
+		if err := batch.Set(mediaPathKey(newPath), []byte(id), nil); err != nil {
+			return err
+		}
+	}
+
+	iter, err := p.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		prefix, _, _ := strings.Cut(string(key), ":")
+		var v any
+		switch prefix {
+		case "subtitle":
+			var rec SubtitleRecord
+			if json.Unmarshal(iter.Value(), &rec) != nil || rec.VideoFile != oldPath {
+				continue
+			}
+			rec.File, rec.VideoFile = MovedSidecarPath(rec.File, oldPath, newPath), newPath
+			v = rec
+		case "download":
+			var rec DownloadRecord
+			if json.Unmarshal(iter.Value(), &rec) != nil || rec.VideoFile != oldPath {
+				continue
+			}
+			rec.File, rec.VideoFile = MovedSidecarPath(rec.File, oldPath, newPath), newPath
+			v = rec
+		case "monitored":
+			var rec MonitoredItem
+			if json.Unmarshal(iter.Value(), &rec) != nil || rec.Path != oldPath {
+				continue
+			}
+			rec.Path = newPath
+			v = rec
+		case "file_index":
+			var rec FileIndexEntry
+			if json.Unmarshal(iter.Value(), &rec) != nil || rec.Path != oldPath {
+				continue
+			}
+			rec.Path = newPath
+			b, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := batch.Delete(append([]byte(nil), key...), nil); err != nil {
+				return err
+			}
+			if err := batch.Set(fileIndexKey(newPath), b, nil); err != nil {
+				return err
+			}
+			continue
+		default:
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := batch.Set(append([]byte(nil), key...), b, nil); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.Sync)
+}
+
 // GetMediaItem retrieves a media item by path. Returns nil if not found.
 func (p *PebbleStore) GetMediaItem(path string) (*MediaItem, error) {
 	item, _, err := p.getMediaByPath(path)
@@ -1851,3 +1974,305 @@ func (p *PebbleStore) GetMonitoredItemsToCheck(interval time.Duration) ([]Monito
 	}
 	return items, iter.Error()
 }
+
+// ==================== QUEUE FUNCTIONS ====================
+
+func queueJobKey(id string) []byte {
+	return []byte("queue_job:" + id)
+}
+
+// queueStatusPrefix is the prefix of the index keys of jobs in status. The
+// index lets workers and status reports read the jobs of one status without
+// decoding the history of finished jobs.
+func queueStatusPrefix(status string) []byte {
+	return []byte("queue_status:" + status + ":")
+}
+
+func queueStatusKey(status, id string) []byte {
+	return append(queueStatusPrefix(status), id...)
+}
+
+// queueIndexedKey marks stores whose queue jobs are indexed by status.
+var queueIndexedKey = []byte("queue_status_indexed")
+
+// indexQueueJobs builds the status index for jobs stored before it existed.
+func (p *PebbleStore) indexQueueJobs() error {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	if _, closer, err := p.db.Get(queueIndexedKey); err == nil {
+		return closer.Close()
+	} else if !errors.Is(err, pebble.ErrNotFound) {
+		return err
+	}
+	recs, err := p.scanQueueJobs()
+	if err != nil {
+		return err
+	}
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	for _, rec := range recs {
+		if err := batch.Set(queueStatusKey(rec.Status, rec.ID), nil, nil); err != nil {
+			return err
+		}
+	}
+	if err := batch.Set(queueIndexedKey, []byte("1"), nil); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// queueJobLess orders jobs by descending priority, then run time and creation time.
+func queueJobLess(a, b *QueueJob) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.RunAt.Equal(b.RunAt) {
+		return a.RunAt.Before(b.RunAt)
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// InsertQueueJob stores a new queue job record.
+func (p *PebbleStore) InsertQueueJob(rec *QueueJob) error {
+	if rec.ID == "" {
+		rec.ID = uuid.NewString()
+	}
+	now := time.Now().UTC()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	if rec.RunAt.IsZero() {
+		rec.RunAt = now
+	}
+	rec.UpdatedAt = now
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	return p.putQueueJob(rec)
+}
+
+// UpdateQueueJob persists changes to an existing queue job.
+func (p *PebbleStore) UpdateQueueJob(rec *QueueJob) error {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	rec.UpdatedAt = time.Now().UTC()
+	return p.putQueueJob(rec)
+}
+
+// putQueueJob writes rec and moves its status index entry. The caller holds
+// queueMu.
+func (p *PebbleStore) putQueueJob(rec *QueueJob) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	old, err := p.GetQueueJob(rec.ID)
+	if err != nil {
+		return err
+	}
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	if old != nil && old.Status != rec.Status {
+		if err := batch.Delete(queueStatusKey(old.Status, rec.ID), nil); err != nil {
+			return err
+		}
+	}
+	if err := batch.Set(queueStatusKey(rec.Status, rec.ID), nil, nil); err != nil {
+		return err
+	}
+	if err := batch.Set(queueJobKey(rec.ID), b, nil); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// GetQueueJob retrieves a queue job by ID. Returns nil if not found.
+func (p *PebbleStore) GetQueueJob(id string) (*QueueJob, error) {
+	val, closer, err := p.db.Get(queueJobKey(id))
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	var rec QueueJob
+	if err := json.Unmarshal(val, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// ListQueueJobs returns jobs with the given status, or all jobs when status is empty.
+func (p *PebbleStore) ListQueueJobs(status string) ([]QueueJob, error) {
+	var (
+		recs []QueueJob
+		err  error
+	)
+	if status == "" {
+		recs, err = p.scanQueueJobs()
+	} else {
+		recs, err = p.queueJobsInStatus(status)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return queueJobLess(&recs[i], &recs[j]) })
+	return recs, nil
+}
+
+// scanQueueJobs decodes every stored queue job.
+func (p *PebbleStore) scanQueueJobs() ([]QueueJob, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte("queue_job:"),
+		UpperBound: []byte("queue_job;"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var recs []QueueJob
+	for iter.First(); iter.Valid(); iter.Next() {
+		var rec QueueJob
+		if err := json.Unmarshal(iter.Value(), &rec); err != nil {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs, iter.Error()
+}
+
+// queueJobsInStatus loads the jobs listed in the index of status.
+func (p *PebbleStore) queueJobsInStatus(status string) ([]QueueJob, error) {
+	prefix := queueStatusPrefix(status)
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: append(prefix[:len(prefix)-1:len(prefix)-1], ';'),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var recs []QueueJob
+	for iter.First(); iter.Valid(); iter.Next() {
+		rec, err := p.GetQueueJob(string(iter.Key()[len(prefix):]))
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil && rec.Status == status {
+			recs = append(recs, *rec)
+		}
+	}
+	return recs, iter.Error()
+}
+
+// ClaimQueueJob marks the highest priority pending job due at or before now as
+// running, incrementing its attempt counter. Returns nil when none is ready.
+func (p *PebbleStore) ClaimQueueJob(now time.Time) (*QueueJob, error) {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+
+	pending, err := p.ListQueueJobs("pending")
+	if err != nil {
+		return nil, err
+	}
+	for i := range pending {
+		rec := pending[i]
+		if rec.RunAt.After(now) {
+			continue
+		}
+		rec.Status = "running"
+		rec.Attempts++
+		rec.UpdatedAt = time.Now().UTC()
+		if err := p.putQueueJob(&rec); err != nil {
+			return nil, err
+		}
+		return &rec, nil
+	}
+	return nil, nil
+}
+
+// CancelQueueJob marks the job id cancelled if it is still pending and
+// reports whether it did. It holds the claim lock so a worker cannot claim
+// the job in between.
+func (p *PebbleStore) CancelQueueJob(id string) (bool, error) {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	rec, err := p.GetQueueJob(id)
+	if err != nil || rec == nil || rec.Status != "pending" {
+		return false, err
+	}
+	rec.Status = "cancelled"
+	rec.UpdatedAt = time.Now().UTC()
+	return true, p.putQueueJob(rec)
+}
+
+// DeleteQueueJob removes a queue job by ID.
+func (p *PebbleStore) DeleteQueueJob(id string) error {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	rec, err := p.GetQueueJob(id)
+	if err != nil || rec == nil {
+		return err
+	}
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	if err := batch.Delete(queueStatusKey(rec.Status, id), nil); err != nil {
+		return err
+	}
+	if err := batch.Delete(queueJobKey(id), nil); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// ==================== FILE INDEX FUNCTIONS ====================
+
+func fileIndexKey(path string) []byte {
+	return []byte("file_index:" + path)
+}
+
+// ListFileIndexEntries returns the index entries of files below dir, or every
+// entry when dir is empty.
+func (p *PebbleStore) ListFileIndexEntries(dir string) ([]FileIndexEntry, error) {
+	lower := fileIndexKey("")
+	if dir != "" {
+		lower = fileIndexKey(fileIndexPrefix(dir))
+	}
+	// The upper bound is the prefix with its last byte incremented.
+	upper := append([]byte(nil), lower...)
+	upper[len(upper)-1]++
+	iter, err := p.db.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var entries []FileIndexEntry
+	for iter.First(); iter.Valid(); iter.Next() {
+		var e FileIndexEntry
+		if err := json.Unmarshal(iter.Value(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, iter.Error()
+}
+
+// UpsertFileIndexEntry stores the index entry for a file.
+func (p *PebbleStore) UpsertFileIndexEntry(e *FileIndexEntry) error {
+	if e.ScannedAt.IsZero() {
+		e.ScannedAt = time.Now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return p.db.Set(fileIndexKey(e.Path), b, pebble.Sync)
+}
+
+// DeleteFileIndexEntry removes the index entry for path.
+func (p *PebbleStore) DeleteFileIndexEntry(path string) error {
+	return p.db.Delete(fileIndexKey(path), pebble.Sync)
+}
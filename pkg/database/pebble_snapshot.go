@@ -0,0 +1,314 @@
+// file: pkg/database/pebble_snapshot.go
+// version: 1.2.0
+// guid: 6a1e8d52-3f7b-4c29-9d46-b0e5c3a8f714
+
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/google/uuid"
+)
+
+func subtitleScoreKey(id string) []byte { return []byte("subtitle_score:" + id) }
+
+// appendJSON decodes data and appends it to dst.
+func appendJSON[T any](dst *[]T, data []byte) error {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*dst = append(*dst, v)
+	return nil
+}
+
+// ExportSnapshot reads every record of the Pebble store. Subtitle scores are
+// only kept by Pebble for migrations, so they survive a round trip through
+// this backend.
+func (p *PebbleStore) ExportSnapshot() (*Snapshot, error) {
+	iter, err := p.db.NewIter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	snap := &Snapshot{}
+	for iter.First(); iter.Valid(); iter.Next() {
+		prefix, _, _ := strings.Cut(string(iter.Key()), ":")
+		value := iter.Value()
+		var err error
+		switch prefix {
+		case "subtitle":
+			err = appendJSON(&snap.Subtitles, value)
+		case "download":
+			err = appendJSON(&snap.Downloads, value)
+		case "media":
+			err = appendJSON(&snap.MediaItems, value)
+		case "tag":
+			err = appendJSON(&snap.Tags, value)
+		case "tag_assoc":
+			err = appendJSON(&snap.TagAssociations, value)
+		case "language_profile":
+			err = appendJSON(&snap.LanguageProfiles, value)
+		case "media_profile":
+			err = appendJSON(&snap.MediaProfiles, value)
+		case "subtitle_source":
+			err = appendJSON(&snap.SubtitleSources, value)
+		case "subtitle_score":
+			err = appendJSON(&snap.SubtitleScores, value)
+		case "monitored":
+			err = appendJSON(&snap.MonitoredItems, value)
+		case "queue_job":
+			err = appendJSON(&snap.QueueJobs, value)
+		case "file_index":
+			err = appendJSON(&snap.FileIndex, value)
+		case "user":
+			err = appendJSON(&snap.Users, value)
+		case "session":
+			err = appendJSON(&snap.Sessions, value)
+		case "api_key":
+			err = appendJSON(&snap.APIKeys, value)
+		case "login_token":
+			err = appendJSON(&snap.LoginTokens, value)
+		case "dashboard":
+			err = appendJSON(&snap.DashboardPrefs, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snap, iter.Error()
+}
+
+// ImportSnapshot writes snap into the Pebble store in one batch. Source IDs
+// are kept, except that users and tags whose name already exists are merged
+// into the existing record.
+func (p *PebbleStore) ImportSnapshot(snap *Snapshot) (map[string]int, error) {
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	set := func(key []byte, v any) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return batch.Set(key, b, nil)
+	}
+	// existing returns the ID stored under an index key, if any.
+	existing := func(key []byte) (string, error) {
+		val, closer, err := p.db.Get(key)
+		if errors.Is(err, pebble.ErrNotFound) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		defer closer.Close()
+		return string(val), nil
+	}
+	newID := func(id string) string {
+		if id == "" {
+			return uuid.NewString()
+		}
+		return id
+	}
+	skipped := make(map[string]int)
+
+	users := make(map[string]string, len(snap.Users))
+	for _, u := range snap.Users {
+		srcID := u.ID
+		id, err := existing(userUsernameKey(u.Username))
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			id = newID(u.ID)
+			u.ID = id
+			if err := set(userKey(id), u); err != nil {
+				return nil, err
+			}
+			if err := batch.Set(userUsernameKey(u.Username), []byte(id), nil); err != nil {
+				return nil, err
+			}
+			if u.Email != "" {
+				if err := batch.Set(userEmailKey(u.Email), []byte(id), nil); err != nil {
+					return nil, err
+				}
+			}
+		}
+		users[srcID] = id
+	}
+	for _, s := range snap.Sessions {
+		uid, ok := users[s.UserID]
+		if !ok {
+			skipped[entitySessions]++
+			continue
+		}
+		s.ID, s.UserID = newID(s.ID), uid
+		if err := set(sessionKey(s.ID), s); err != nil {
+			return nil, err
+		}
+		if err := batch.Set(sessionTokenKey(s.Token), []byte(s.ID), nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range snap.APIKeys {
+		uid, ok := users[k.UserID]
+		if !ok {
+			skipped[entityAPIKeys]++
+			continue
+		}
+		k.ID, k.UserID = newID(k.ID), uid
+		if err := set(apiKeyKey(k.ID), k); err != nil {
+			return nil, err
+		}
+		if err := batch.Set(apiKeyValueKey(k.Key), []byte(uid), nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range snap.LoginTokens {
+		uid, ok := users[t.UserID]
+		if !ok {
+			skipped[entityLoginTokens]++
+			continue
+		}
+		t.ID, t.UserID = newID(t.ID), uid
+		if err := set(loginTokenKey(t.ID), t); err != nil {
+			return nil, err
+		}
+		if err := batch.Set(loginTokenValueKey(t.Token), []byte(t.ID), nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range snap.DashboardPrefs {
+		uid, ok := users[d.UserID]
+		if !ok {
+			skipped[entityDashboardPrefs]++
+			continue
+		}
+		d.UserID = uid
+		if err := set(dashboardKey(uid), d); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range snap.MediaItems {
+		m.ID = newID(m.ID)
+		if err := set(mediaKey(m.ID), m); err != nil {
+			return nil, err
+		}
+		if err := batch.Set(mediaPathKey(m.Path), []byte(m.ID), nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range snap.Subtitles {
+		r.ID = newID(r.ID)
+		if err := set([]byte("subtitle:"+r.ID), r); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range snap.Downloads {
+		d.ID = newID(d.ID)
+		if err := set([]byte("download:"+d.ID), d); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range snap.SubtitleSources {
+		s.ID = newID(s.ID)
+		if err := set(subtitleSourceKey(s.SourceHash), s); err != nil {
+			return nil, err
+		}
+	}
+	for _, sc := range snap.SubtitleScores {
+		sc.ID = newID(sc.ID)
+		if err := set(subtitleScoreKey(sc.ID), sc); err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make(map[string]string, len(snap.Tags))
+	for _, t := range snap.Tags {
+		srcID := t.ID
+		id, err := existing(tagNameKey(t.Name))
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			id = newID(t.ID)
+			t.ID = id
+			if err := set(tagKey(id), t); err != nil {
+				return nil, err
+			}
+			if err := batch.Set(tagNameKey(t.Name), []byte(id), nil); err != nil {
+				return nil, err
+			}
+		}
+		tags[srcID] = id
+	}
+	for _, a := range snap.TagAssociations {
+		tagID, ok := tags[a.TagID]
+		if !ok {
+			skipped[entityTagAssociations]++
+			continue
+		}
+		a.TagID = tagID
+		if a.EntityType == "user" {
+			uid, ok := users[a.EntityID]
+			if !ok {
+				skipped[entityTagAssociations]++
+				continue
+			}
+			a.EntityID = uid
+		}
+		if err := set(tagAssocKey(a.TagID, a.EntityType, a.EntityID), a); err != nil {
+			return nil, err
+		}
+	}
+
+	defaultID := ""
+	for _, lp := range snap.LanguageProfiles {
+		if err := set(languageProfileKey(lp.ID), lp); err != nil {
+			return nil, err
+		}
+		if lp.IsDefault {
+			defaultID = lp.ID
+		}
+	}
+	for _, a := range snap.MediaProfiles {
+		if err := set(mediaProfileKey(a.MediaID), a); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range snap.MonitoredItems {
+		m.ID = newID(m.ID)
+		if err := set([]byte("monitored:"+m.ID), m); err != nil {
+			return nil, err
+		}
+	}
+	for _, j := range snap.QueueJobs {
+		j.ID = newID(j.ID)
+		if err := set(queueJobKey(j.ID), j); err != nil {
+			return nil, err
+		}
+		if err := batch.Set(queueStatusKey(j.Status, j.ID), nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range snap.FileIndex {
+		if err := set(fileIndexKey(e.Path), e); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return nil, err
+	}
+	if defaultID != "" {
+		if err := p.SetDefaultLanguageProfile(defaultID); err != nil {
+			return nil, err
+		}
+	}
+	return skipped, nil
+}
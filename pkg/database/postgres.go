@@ -66,6 +66,7 @@ func initPostgresSchema(db *sql.DB) error {
 			release_group TEXT,
 			alt_titles TEXT,
 			field_locks TEXT,
+			hashes TEXT DEFAULT '',
 			created_at TIMESTAMP NOT NULL
 		)`,
 		`CREATE TABLE IF NOT EXISTS dashboard_prefs (
@@ -94,6 +95,136 @@ func initPostgresSchema(db *sql.DB) error {
 			profile_id TEXT NOT NULL,
 			created_at TIMESTAMP NOT NULL
 		)`,
+		`ALTER TABLE media_items ADD COLUMN IF NOT EXISTS hashes TEXT DEFAULT ''`,
+		`ALTER TABLE subtitles ADD COLUMN IF NOT EXISTS source_url TEXT`,
+		`ALTER TABLE subtitles ADD COLUMN IF NOT EXISTS provider_metadata TEXT`,
+		`ALTER TABLE subtitles ADD COLUMN IF NOT EXISTS confidence_score DOUBLE PRECISION`,
+		`ALTER TABLE subtitles ADD COLUMN IF NOT EXISTS parent_id INTEGER`,
+		`ALTER TABLE subtitles ADD COLUMN IF NOT EXISTS modification_type TEXT`,
+		`ALTER TABLE downloads ADD COLUMN IF NOT EXISTS search_query TEXT`,
+		`ALTER TABLE downloads ADD COLUMN IF NOT EXISTS match_score DOUBLE PRECISION`,
+		`ALTER TABLE downloads ADD COLUMN IF NOT EXISTS download_attempts INTEGER DEFAULT 1`,
+		`ALTER TABLE downloads ADD COLUMN IF NOT EXISTS error_message TEXT`,
+		`ALTER TABLE downloads ADD COLUMN IF NOT EXISTS response_time_ms INTEGER`,
+		`CREATE TABLE IF NOT EXISTS subtitle_sources (
+			id SERIAL PRIMARY KEY,
+			source_hash TEXT UNIQUE NOT NULL,
+			original_url TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			title TEXT,
+			release_info TEXT,
+			file_size INTEGER,
+			download_count INTEGER DEFAULT 0,
+			success_count INTEGER DEFAULT 0,
+			avg_rating DOUBLE PRECISION,
+			last_seen TIMESTAMP,
+			metadata TEXT,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS users (
+			id SERIAL PRIMARY KEY,
+			username TEXT UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			email TEXT,
+			role TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			key TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			token TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS login_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			token TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			used BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			id SERIAL PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			type TEXT NOT NULL DEFAULT 'user',
+			entity_type TEXT DEFAULT 'all',
+			color TEXT,
+			description TEXT,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS tag_associations (
+			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			entity_type TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (tag_id, entity_type, entity_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_tags (
+			user_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			UNIQUE(user_id, tag_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS media_tags (
+			media_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			UNIQUE(media_id, tag_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS monitored_items (
+			id SERIAL PRIMARY KEY,
+			media_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			languages TEXT NOT NULL,
+			last_checked TIMESTAMP,
+			status TEXT NOT NULL DEFAULT 'pending',
+			retry_count INTEGER NOT NULL DEFAULT 0,
+			max_retries INTEGER NOT NULL DEFAULT 3,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS subtitle_scores (
+			id SERIAL PRIMARY KEY,
+			subtitle_id TEXT NOT NULL,
+			provider_name TEXT NOT NULL,
+			language_match DOUBLE PRECISION NOT NULL DEFAULT 0,
+			provider_rank DOUBLE PRECISION NOT NULL DEFAULT 0,
+			release_match DOUBLE PRECISION NOT NULL DEFAULT 0,
+			format_match DOUBLE PRECISION NOT NULL DEFAULT 0,
+			user_rating DOUBLE PRECISION NOT NULL DEFAULT 0,
+			download_count INTEGER NOT NULL DEFAULT 0,
+			total_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+			score_version TEXT NOT NULL DEFAULT '1.0',
+			metadata TEXT NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS file_index (
+			path TEXT PRIMARY KEY,
+			size BIGINT NOT NULL DEFAULT 0,
+			mod_time TIMESTAMP NOT NULL,
+			media_hash TEXT NOT NULL DEFAULT '',
+			results TEXT NOT NULL DEFAULT '{}',
+			scanned_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS queue_jobs (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			payload BYTEA,
+			priority INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL DEFAULT 3,
+			run_at TIMESTAMP NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
 	}
 	for _, s := range stmts {
 		if _, err := db.Exec(s); err != nil {
@@ -112,6 +243,8 @@ func initPostgresSchema(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_media_profiles_media ON media_profiles(media_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_media_profiles_profile ON media_profiles(profile_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_monitored_items_status_checked ON monitored_items(status, last_checked)`,
+		`CREATE INDEX IF NOT EXISTS idx_queue_jobs_status_run ON queue_jobs(status, priority, run_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_subtitle_scores_subtitle_id ON subtitle_scores(subtitle_id)`,
 	}
 	for _, s := range idxStmts {
 		if _, err := db.Exec(s); err != nil {
@@ -324,14 +457,14 @@ func (p *PostgresStore) DeleteDownload(file string) error {
 
 // InsertMediaItem stores a media library record.
 func (p *PostgresStore) InsertMediaItem(rec *MediaItem) error {
-	_, err := p.db.Exec(`INSERT INTO media_items (path, title, season, episode, release_group, alt_titles, field_locks, created_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
-		rec.Path, rec.Title, rec.Season, rec.Episode, rec.ReleaseGroup, rec.AltTitles, rec.FieldLocks, time.Now())
+	_, err := p.db.Exec(`INSERT INTO media_items (path, title, season, episode, release_group, alt_titles, field_locks, hashes, created_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)`,
+		rec.Path, rec.Title, rec.Season, rec.Episode, rec.ReleaseGroup, rec.AltTitles, rec.FieldLocks, rec.Hashes, time.Now())
 	return err
 }
 
 // ListMediaItems retrieves media items ordered by most recent.
 func (p *PostgresStore) ListMediaItems() ([]MediaItem, error) {
-	rows, err := p.db.Query(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, created_at FROM media_items ORDER BY id DESC`)
+	rows, err := p.db.Query(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, hashes, created_at FROM media_items ORDER BY id DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -340,7 +473,7 @@ func (p *PostgresStore) ListMediaItems() ([]MediaItem, error) {
 	for rows.Next() {
 		var r MediaItem
 		var id int64
-		if err := rows.Scan(&id, &r.Path, &r.Title, &r.Season, &r.Episode, &r.ReleaseGroup, &r.AltTitles, &r.FieldLocks, &r.CreatedAt); err != nil {
+		if err := rows.Scan(&id, &r.Path, &r.Title, &r.Season, &r.Episode, &r.ReleaseGroup, &r.AltTitles, &r.FieldLocks, &r.Hashes, &r.CreatedAt); err != nil {
 			return nil, err
 		}
 		r.ID = strconv.FormatInt(id, 10)
@@ -447,18 +580,48 @@ func (p *PostgresStore) GetMediaFieldLocks(path string) (string, error) {
 	return locks, nil
 }
 
+// SetMediaHashes stores the content hashes of a media item keyed by
+// algorithm.
+func (p *PostgresStore) SetMediaHashes(path string, hashes map[string]string) error {
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(`UPDATE media_items SET hashes = $1 WHERE path = $2`, string(data), path)
+	return err
+}
+
+// GetMediaHashes retrieves the content hashes of a media item.
+func (p *PostgresStore) GetMediaHashes(path string) (map[string]string, error) {
+	row := p.db.QueryRow(`SELECT hashes FROM media_items WHERE path = $1`, path)
+	var data sql.NullString
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeMediaHashes(data.String)
+}
+
 // SetMediaTitle updates the title for a media item.
 func (p *PostgresStore) SetMediaTitle(path, title string) error {
 	_, err := p.db.Exec(`UPDATE media_items SET title = $1 WHERE path = $2`, title, path)
 	return err
 }
 
+// MoveMediaPath points the records of a video moved from oldPath to newPath
+// at the new location.
+func (p *PostgresStore) MoveMediaPath(oldPath, newPath string) error {
+	return moveMediaPathSQL(sqlSnapshot{db: p.db, postgres: true}, oldPath, newPath)
+}
+
 // GetMediaItem retrieves a media item by path. Returns nil if not found.
 func (p *PostgresStore) GetMediaItem(path string) (*MediaItem, error) {
-	row := p.db.QueryRow(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, created_at FROM media_items WHERE path = $1`, path)
+	row := p.db.QueryRow(`SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, hashes, created_at FROM media_items WHERE path = $1`, path)
 	var it MediaItem
 	var id int64
-	if err := row.Scan(&id, &it.Path, &it.Title, &it.Season, &it.Episode, &it.ReleaseGroup, &it.AltTitles, &it.FieldLocks, &it.CreatedAt); err != nil {
+	if err := row.Scan(&id, &it.Path, &it.Title, &it.Season, &it.Episode, &it.ReleaseGroup, &it.AltTitles, &it.FieldLocks, &it.Hashes, &it.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -919,3 +1082,100 @@ func (p *PostgresStore) GetMonitoredItemsToCheck(interval time.Duration) ([]Moni
 	}
 	return recs, rows.Err()
 }
+
+// InsertQueueJob stores a new queue job record.
+func (p *PostgresStore) InsertQueueJob(rec *QueueJob) error {
+	now := time.Now().UTC()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	if rec.RunAt.IsZero() {
+		rec.RunAt = now
+	}
+	rec.UpdatedAt = now
+	_, err := p.db.Exec(`INSERT INTO queue_jobs (`+queueJobColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		rec.ID, rec.Type, rec.Payload, rec.Priority, rec.Status, rec.Attempts, rec.MaxAttempts, rec.RunAt.UTC(), rec.LastError, rec.CreatedAt.UTC(), rec.UpdatedAt)
+	return err
+}
+
+// UpdateQueueJob persists changes to an existing queue job.
+func (p *PostgresStore) UpdateQueueJob(rec *QueueJob) error {
+	rec.UpdatedAt = time.Now().UTC()
+	_, err := p.db.Exec(`UPDATE queue_jobs SET priority = $2, status = $3, attempts = $4, max_attempts = $5, run_at = $6, last_error = $7, updated_at = $8 WHERE id = $1`,
+		rec.ID, rec.Priority, rec.Status, rec.Attempts, rec.MaxAttempts, rec.RunAt.UTC(), rec.LastError, rec.UpdatedAt)
+	return err
+}
+
+// GetQueueJob retrieves a queue job by ID. Returns nil if not found.
+func (p *PostgresStore) GetQueueJob(id string) (*QueueJob, error) {
+	rec, err := scanQueueJob(p.db.QueryRow(`SELECT `+queueJobColumns+` FROM queue_jobs WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return rec, err
+}
+
+// ListQueueJobs returns jobs with the given status, or all jobs when status is empty.
+func (p *PostgresStore) ListQueueJobs(status string) ([]QueueJob, error) {
+	rows, err := p.db.Query(`SELECT `+queueJobColumns+` FROM queue_jobs WHERE $1 = '' OR status = $1 ORDER BY priority DESC, run_at ASC, created_at ASC`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recs []QueueJob
+	for rows.Next() {
+		rec, err := scanQueueJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, rows.Err()
+}
+
+// ClaimQueueJob marks the highest priority pending job due at or before now as
+// running, incrementing its attempt counter. Concurrent claimers skip rows
+// locked by each other. Returns nil when none is ready.
+func (p *PostgresStore) ClaimQueueJob(now time.Time) (*QueueJob, error) {
+	rec, err := scanQueueJob(p.db.QueryRow(`UPDATE queue_jobs SET status = 'running', attempts = attempts + 1, updated_at = $2
+		WHERE id = (SELECT id FROM queue_jobs WHERE status = 'pending' AND run_at <= $1 ORDER BY priority DESC, run_at ASC, created_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING `+queueJobColumns, now.UTC(), time.Now().UTC()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return rec, err
+}
+
+// CancelQueueJob marks the job id cancelled if it is still pending and
+// reports whether it did.
+func (p *PostgresStore) CancelQueueJob(id string) (bool, error) {
+	res, err := p.db.Exec(`UPDATE queue_jobs SET status = 'cancelled', updated_at = $2 WHERE id = $1 AND status = 'pending'`, id, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteQueueJob removes a queue job by ID.
+func (p *PostgresStore) DeleteQueueJob(id string) error {
+	_, err := p.db.Exec(`DELETE FROM queue_jobs WHERE id = $1`, id)
+	return err
+}
+
+// ListFileIndexEntries returns the index entries of files below dir, or every
+// entry when dir is empty.
+func (p *PostgresStore) ListFileIndexEntries(dir string) ([]FileIndexEntry, error) {
+	return listFileIndexSQL(sqlSnapshot{db: p.db, postgres: true}, dir)
+}
+
+// UpsertFileIndexEntry stores the index entry for a file.
+func (p *PostgresStore) UpsertFileIndexEntry(e *FileIndexEntry) error {
+	return upsertFileIndexSQL(sqlSnapshot{db: p.db, postgres: true}, e)
+}
+
+// DeleteFileIndexEntry removes the index entry for path.
+func (p *PostgresStore) DeleteFileIndexEntry(path string) error {
+	_, err := p.db.Exec(`DELETE FROM file_index WHERE path = $1`, path)
+	return err
+}
@@ -0,0 +1,147 @@
+// file: pkg/database/queue_jobs_test.go
+// version: 1.2.0
+// guid: 0e6d2b7c-3f41-4a8e-9c52-7b1d8e4f6a93
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueueJobs exercises queue job persistence on every available backend.
+func TestQueueJobs(t *testing.T) {
+	backends := []string{"pebble"}
+	if HasSQLite() {
+		backends = append(backends, "sqlite")
+	}
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			path := t.TempDir()
+			if backend == "sqlite" {
+				path = filepath.Join(path, "queue.db")
+			}
+			store, err := OpenStore(path, backend)
+			require.NoError(t, err)
+			defer store.Close()
+			testQueueJobs(t, store)
+		})
+	}
+}
+
+func testQueueJobs(t *testing.T, store SubtitleStore) {
+	now := time.Now()
+	jobs := []*QueueJob{
+		{ID: "low", Type: "single_file", Payload: []byte{1, 2}, Priority: 0, Status: "pending", MaxAttempts: 3, RunAt: now.Add(-time.Minute)},
+		{ID: "high", Type: "single_file", Priority: 5, Status: "pending", MaxAttempts: 3, RunAt: now.Add(-time.Second)},
+		{ID: "delayed", Type: "single_file", Priority: 10, Status: "pending", MaxAttempts: 3, RunAt: now.Add(time.Hour)},
+	}
+	for _, job := range jobs {
+		require.NoError(t, store.InsertQueueJob(job))
+	}
+
+	got, err := store.GetQueueJob("low")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, []byte{1, 2}, got.Payload)
+	assert.Equal(t, "single_file", got.Type)
+
+	missing, err := store.GetQueueJob("missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// The delayed job has the highest priority but is not due yet.
+	claimed, err := store.ClaimQueueJob(now)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, "high", claimed.ID)
+	assert.Equal(t, "running", claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+
+	claimed, err = store.ClaimQueueJob(now)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, "low", claimed.ID)
+
+	claimed, err = store.ClaimQueueJob(now)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	claimed, err = store.ClaimQueueJob(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, "delayed", claimed.ID)
+
+	running, err := store.ListQueueJobs("running")
+	require.NoError(t, err)
+	assert.Len(t, running, 3)
+
+	// Only pending jobs can be cancelled.
+	ok, err := store.CancelQueueJob("delayed")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, store.InsertQueueJob(&QueueJob{ID: "unwanted", Type: "scan", Status: "pending", MaxAttempts: 3}))
+	ok, err = store.CancelQueueJob("unwanted")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	got, err = store.GetQueueJob("unwanted")
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", got.Status)
+	claimed2, err := store.ClaimQueueJob(now)
+	require.NoError(t, err)
+	assert.Nil(t, claimed2)
+	require.NoError(t, store.DeleteQueueJob("unwanted"))
+
+	claimed.Status = "dead"
+	claimed.LastError = "boom"
+	require.NoError(t, store.UpdateQueueJob(claimed))
+
+	dead, err := store.ListQueueJobs("dead")
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "boom", dead[0].LastError)
+
+	all, err := store.ListQueueJobs("")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "delayed", all[0].ID)
+
+	require.NoError(t, store.DeleteQueueJob("delayed"))
+	all, err = store.ListQueueJobs("")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+// TestPebbleQueueStatusIndex verifies jobs stored before the status index
+// existed are indexed when the store is opened.
+func TestPebbleQueueStatusIndex(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenPebble(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.InsertQueueJob(&QueueJob{ID: "old", Type: "scan", Status: "pending", MaxAttempts: 3}))
+	require.NoError(t, store.db.Delete(queueStatusKey("pending", "old"), pebble.Sync))
+	require.NoError(t, store.db.Delete(queueIndexedKey, pebble.Sync))
+	require.NoError(t, store.Close())
+
+	store, err = OpenPebble(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	pending, err := store.ListQueueJobs("pending")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "old", pending[0].ID)
+
+	pending[0].Status = "completed"
+	require.NoError(t, store.UpdateQueueJob(&pending[0]))
+	pending, err = store.ListQueueJobs("pending")
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	done, err := store.ListQueueJobs("completed")
+	require.NoError(t, err)
+	assert.Len(t, done, 1)
+}
@@ -0,0 +1,261 @@
+// file: pkg/database/snapshot.go
+// version: 1.1.0
+// guid: 4f7a2c91-6d3e-4b85-a0c8-1e9b5d7f3a62
+
+package database
+
+import "time"
+
+// Entity names used in snapshot counts and migration reports.
+const (
+	entitySubtitles        = "subtitles"
+	entityDownloads        = "downloads"
+	entityMediaItems       = "media_items"
+	entityTags             = "tags"
+	entityTagAssociations  = "tag_associations"
+	entityLanguageProfiles = "language_profiles"
+	entityMediaProfiles    = "media_profiles"
+	entitySubtitleSources  = "subtitle_sources"
+	entitySubtitleScores   = "subtitle_scores"
+	entityMonitoredItems   = "monitored_items"
+	entityQueueJobs        = "queue_jobs"
+	entityFileIndex        = "file_index"
+	entityUsers            = "users"
+	entitySessions         = "sessions"
+	entityAPIKeys          = "api_keys"
+	entityLoginTokens      = "login_tokens"
+	entityDashboardPrefs   = "dashboard_prefs"
+)
+
+// snapshotEntities lists the entities of a Snapshot in report order.
+var snapshotEntities = []string{
+	entityUsers, entitySessions, entityAPIKeys, entityLoginTokens, entityDashboardPrefs,
+	entityMediaItems, entitySubtitles, entityDownloads, entitySubtitleSources, entitySubtitleScores,
+	entityTags, entityTagAssociations, entityLanguageProfiles, entityMediaProfiles,
+	entityMonitoredItems, entityQueueJobs, entityFileIndex,
+}
+
+// Snapshot holds every entity of a store. Records keep their original IDs,
+// timestamps and cross references so importers can preserve or remap them.
+type Snapshot struct {
+	Subtitles        []SubtitleRecord
+	Downloads        []DownloadRecord
+	MediaItems       []MediaItem
+	Tags             []Tag
+	TagAssociations  []TagAssociation
+	LanguageProfiles []LanguageProfile
+	MediaProfiles    []LanguageProfileAssignment
+	SubtitleSources  []SubtitleSource
+	SubtitleScores   []SubtitleScore
+	MonitoredItems   []MonitoredItem
+	QueueJobs        []QueueJob
+	FileIndex        []FileIndexEntry
+	Users            []UserWithPassword
+	Sessions         []Session
+	APIKeys          []ApiKey
+	LoginTokens      []LoginToken
+	DashboardPrefs   []DashboardPref
+}
+
+// Counts returns the number of records per entity.
+func (s *Snapshot) Counts() map[string]int {
+	return map[string]int{
+		entitySubtitles:        len(s.Subtitles),
+		entityDownloads:        len(s.Downloads),
+		entityMediaItems:       len(s.MediaItems),
+		entityTags:             len(s.Tags),
+		entityTagAssociations:  len(s.TagAssociations),
+		entityLanguageProfiles: len(s.LanguageProfiles),
+		entityMediaProfiles:    len(s.MediaProfiles),
+		entitySubtitleSources:  len(s.SubtitleSources),
+		entitySubtitleScores:   len(s.SubtitleScores),
+		entityMonitoredItems:   len(s.MonitoredItems),
+		entityQueueJobs:        len(s.QueueJobs),
+		entityFileIndex:        len(s.FileIndex),
+		entityUsers:            len(s.Users),
+		entitySessions:         len(s.Sessions),
+		entityAPIKeys:          len(s.APIKeys),
+		entityLoginTokens:      len(s.LoginTokens),
+		entityDashboardPrefs:   len(s.DashboardPrefs),
+	}
+}
+
+// SnapshotStore is implemented by stores that read and write complete
+// snapshots, including password hashes and associations SubtitleStore does
+// not expose. ImportSnapshot returns the number of records per entity that
+// could not be stored, such as sessions of users missing from the snapshot.
+type SnapshotStore interface {
+	ExportSnapshot() (*Snapshot, error)
+	ImportSnapshot(s *Snapshot) (map[string]int, error)
+}
+
+// ExportSnapshot reads every entity from store. Stores that do not implement
+// SnapshotStore are read through SubtitleStore, which omits users and their
+// credentials, tag associations and subtitle scores.
+func ExportSnapshot(store SubtitleStore) (*Snapshot, error) {
+	if ss, ok := store.(SnapshotStore); ok {
+		return ss.ExportSnapshot()
+	}
+	snap := &Snapshot{}
+	var err error
+	if snap.Subtitles, err = store.ListSubtitles(); err != nil {
+		return nil, err
+	}
+	if snap.Downloads, err = store.ListDownloads(); err != nil {
+		return nil, err
+	}
+	if snap.MediaItems, err = store.ListMediaItems(); err != nil {
+		return nil, err
+	}
+	if snap.Tags, err = store.ListTags(); err != nil {
+		return nil, err
+	}
+	if snap.LanguageProfiles, err = store.ListLanguageProfiles(); err != nil {
+		return nil, err
+	}
+	if snap.SubtitleSources, err = store.ListSubtitleSources("", 0); err != nil {
+		return nil, err
+	}
+	if snap.MonitoredItems, err = store.ListMonitoredItems(); err != nil {
+		return nil, err
+	}
+	if snap.QueueJobs, err = store.ListQueueJobs(""); err != nil {
+		return nil, err
+	}
+	if snap.FileIndex, err = store.ListFileIndexEntries(""); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// ImportSnapshot writes snap into store and returns the number of records
+// per entity that could not be stored. Stores that do not implement
+// SnapshotStore are written through SubtitleStore, which assigns new IDs to
+// users and cannot store tag associations or subtitle scores.
+func ImportSnapshot(store SubtitleStore, snap *Snapshot) (map[string]int, error) {
+	if ss, ok := store.(SnapshotStore); ok {
+		return ss.ImportSnapshot(snap)
+	}
+	skipped := map[string]int{
+		entityTagAssociations: len(snap.TagAssociations),
+		entitySubtitleScores:  len(snap.SubtitleScores),
+	}
+	for _, r := range snap.Subtitles {
+		rec := r
+		if err := store.InsertSubtitle(&rec); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range snap.Downloads {
+		dr := d
+		if err := store.InsertDownload(&dr); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range snap.MediaItems {
+		mr := m
+		if err := store.InsertMediaItem(&mr); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range snap.Tags {
+		if err := store.InsertTag(t.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, lp := range snap.LanguageProfiles {
+		profile := lp
+		if err := store.CreateLanguageProfile(&profile); err != nil {
+			if err := store.UpdateLanguageProfile(&profile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, a := range snap.MediaProfiles {
+		if err := store.AssignProfileToMedia(a.MediaID, a.ProfileID); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range snap.SubtitleSources {
+		src := s
+		if err := store.InsertSubtitleSource(&src); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range snap.MonitoredItems {
+		rec := m
+		if err := store.InsertMonitoredItem(&rec); err != nil {
+			return nil, err
+		}
+	}
+	for _, j := range snap.QueueJobs {
+		job := j
+		if err := store.InsertQueueJob(&job); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range snap.FileIndex {
+		entry := e
+		if err := store.UpsertFileIndexEntry(&entry); err != nil {
+			return nil, err
+		}
+	}
+
+	users := make(map[string]string, len(snap.Users))
+	for _, u := range snap.Users {
+		id, err := store.CreateUser(u.Username, u.PasswordHash, u.Email, u.Role)
+		if err != nil {
+			return nil, err
+		}
+		users[u.ID] = id
+	}
+	for _, s := range snap.Sessions {
+		uid, ok := users[s.UserID]
+		if !ok {
+			skipped[entitySessions]++
+			continue
+		}
+		if err := store.CreateSession(uid, s.Token, time.Until(s.ExpiresAt)); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range snap.APIKeys {
+		uid, ok := users[k.UserID]
+		if !ok {
+			skipped[entityAPIKeys]++
+			continue
+		}
+		if err := store.CreateAPIKey(uid, k.Key); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range snap.LoginTokens {
+		uid, ok := users[t.UserID]
+		if !ok {
+			skipped[entityLoginTokens]++
+			continue
+		}
+		if err := store.CreateOneTimeToken(uid, t.Token, time.Until(t.ExpiresAt)); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range snap.DashboardPrefs {
+		uid, ok := users[d.UserID]
+		if !ok {
+			skipped[entityDashboardPrefs]++
+			continue
+		}
+		if err := store.SetDashboardLayout(uid, d.Layout); err != nil {
+			return nil, err
+		}
+	}
+	return skipped, nil
+}
+
+// orNow returns t, or the current time when t is zero.
+func orNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
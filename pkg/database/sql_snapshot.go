@@ -0,0 +1,774 @@
+// file: pkg/database/sql_snapshot.go
+// version: 1.2.0
+// guid: 2d9b6e43-8a1f-4c70-b5e2-9f3c7a1d6e08
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExportSnapshot reads every table of the SQLite database.
+func (s *SQLStore) ExportSnapshot() (*Snapshot, error) {
+	return sqlSnapshot{db: s.db}.export()
+}
+
+// ImportSnapshot writes snap into the SQLite database in one transaction.
+func (s *SQLStore) ImportSnapshot(snap *Snapshot) (map[string]int, error) {
+	return sqlSnapshot{db: s.db}.importSnapshot(snap)
+}
+
+// ExportSnapshot reads every table of the PostgreSQL database.
+func (p *PostgresStore) ExportSnapshot() (*Snapshot, error) {
+	return sqlSnapshot{db: p.db, postgres: true}.export()
+}
+
+// ImportSnapshot writes snap into the PostgreSQL database in one transaction.
+func (p *PostgresStore) ImportSnapshot(snap *Snapshot) (map[string]int, error) {
+	return sqlSnapshot{db: p.db, postgres: true}.importSnapshot(snap)
+}
+
+// serialTables lists the tables with integer IDs assigned by the database.
+var serialTables = []string{
+	"users", "sessions", "api_keys", "login_tokens", "tags", "media_items",
+	"subtitles", "downloads", "subtitle_sources", "subtitle_scores", "monitored_items",
+}
+
+// sqlQuerier is satisfied by *sql.DB and *sql.Tx.
+type sqlQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqlSnapshot exports and imports snapshots for the SQLite and PostgreSQL
+// schemas, which share table and column names. Queries are written with ?
+// placeholders and rebound for PostgreSQL.
+type sqlSnapshot struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// rebind converts ? placeholders to $n for PostgreSQL.
+func (s sqlSnapshot) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// queryAll runs query and scans every row with scan.
+func queryAll[T any](q sqlQuerier, query string, scan func(rowScanner) (T, error)) ([]T, error) {
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []T
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func (s sqlSnapshot) export() (*Snapshot, error) {
+	snap := &Snapshot{}
+	var err error
+	if snap.Users, err = queryAll(s.db, `SELECT id, username, password_hash, email, role, created_at FROM users ORDER BY id`, scanSnapshotUser); err != nil {
+		return nil, fmt.Errorf("users: %w", err)
+	}
+	if snap.Sessions, err = queryAll(s.db, `SELECT id, user_id, token, expires_at, created_at FROM sessions ORDER BY id`, scanSnapshotSession); err != nil {
+		return nil, fmt.Errorf("sessions: %w", err)
+	}
+	if snap.APIKeys, err = queryAll(s.db, `SELECT id, user_id, key, created_at FROM api_keys ORDER BY id`, scanSnapshotAPIKey); err != nil {
+		return nil, fmt.Errorf("api keys: %w", err)
+	}
+	if snap.LoginTokens, err = queryAll(s.db, `SELECT id, user_id, token, expires_at, used, created_at FROM login_tokens ORDER BY id`, scanSnapshotLoginToken); err != nil {
+		return nil, fmt.Errorf("login tokens: %w", err)
+	}
+	if snap.DashboardPrefs, err = queryAll(s.db, `SELECT user_id, layout, updated_at FROM dashboard_prefs ORDER BY user_id`, scanSnapshotDashboardPref); err != nil {
+		return nil, fmt.Errorf("dashboard prefs: %w", err)
+	}
+	if snap.MediaItems, err = queryAll(s.db, `SELECT id, path, title, season, episode, release_group, alt_titles, field_locks, hashes, created_at FROM media_items ORDER BY id`, scanSnapshotMediaItem); err != nil {
+		return nil, fmt.Errorf("media items: %w", err)
+	}
+	if snap.Subtitles, err = queryAll(s.db, `SELECT id, file, video_file, release, language, service, embedded, source_url, provider_metadata, confidence_score, parent_id, modification_type, created_at FROM subtitles ORDER BY id`, scanSnapshotSubtitle); err != nil {
+		return nil, fmt.Errorf("subtitles: %w", err)
+	}
+	if snap.Downloads, err = queryAll(s.db, `SELECT id, file, video_file, provider, language, search_query, match_score, download_attempts, error_message, response_time_ms, created_at FROM downloads ORDER BY id`, scanSnapshotDownload); err != nil {
+		return nil, fmt.Errorf("downloads: %w", err)
+	}
+	if snap.SubtitleSources, err = queryAll(s.db, `SELECT id, source_hash, original_url, provider, title, release_info, file_size, download_count, success_count, avg_rating, last_seen, metadata, created_at FROM subtitle_sources ORDER BY id`, scanSnapshotSubtitleSource); err != nil {
+		return nil, fmt.Errorf("subtitle sources: %w", err)
+	}
+	if snap.SubtitleScores, err = queryAll(s.db, `SELECT id, subtitle_id, provider_name, language_match, provider_rank, release_match, format_match, user_rating, download_count, total_score, score_version, metadata, created_at, updated_at FROM subtitle_scores ORDER BY id`, scanSnapshotSubtitleScore); err != nil {
+		return nil, fmt.Errorf("subtitle scores: %w", err)
+	}
+	if snap.Tags, err = queryAll(s.db, `SELECT id, name, type, entity_type, color, description, created_at FROM tags ORDER BY id`, scanSnapshotTag); err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+	if snap.TagAssociations, err = s.exportTagAssociations(); err != nil {
+		return nil, fmt.Errorf("tag associations: %w", err)
+	}
+	if snap.LanguageProfiles, err = queryAll(s.db, `SELECT id, name, config, cutoff_score, is_default, created_at, updated_at FROM language_profiles ORDER BY id`, scanSnapshotLanguageProfile); err != nil {
+		return nil, fmt.Errorf("language profiles: %w", err)
+	}
+	if snap.MediaProfiles, err = queryAll(s.db, `SELECT media_id, profile_id, created_at FROM media_profiles ORDER BY media_id`, scanSnapshotMediaProfile); err != nil {
+		return nil, fmt.Errorf("media profiles: %w", err)
+	}
+	if snap.MonitoredItems, err = queryAll(s.db, `SELECT id, media_id, path, languages, last_checked, status, retry_count, max_retries, created_at, updated_at FROM monitored_items ORDER BY id`, scanSnapshotMonitoredItem); err != nil {
+		return nil, fmt.Errorf("monitored items: %w", err)
+	}
+	if snap.QueueJobs, err = queryAll(s.db, `SELECT `+queueJobColumns+` FROM queue_jobs ORDER BY created_at, id`, func(row rowScanner) (QueueJob, error) {
+		rec, err := scanQueueJob(row)
+		if err != nil {
+			return QueueJob{}, err
+		}
+		return *rec, nil
+	}); err != nil {
+		return nil, fmt.Errorf("queue jobs: %w", err)
+	}
+	if snap.FileIndex, err = queryAll(s.db, `SELECT `+fileIndexColumns+` FROM file_index ORDER BY path`, scanFileIndexEntry); err != nil {
+		return nil, fmt.Errorf("file index: %w", err)
+	}
+	return snap, nil
+}
+
+// exportTagAssociations merges tag_associations with the legacy user_tags
+// and media_tags tables, which older code paths still write to.
+func (s sqlSnapshot) exportTagAssociations() ([]TagAssociation, error) {
+	assocs, err := queryAll(s.db, `SELECT tag_id, entity_type, entity_id, created_at FROM tag_associations ORDER BY tag_id, entity_type, entity_id`, func(row rowScanner) (TagAssociation, error) {
+		var a TagAssociation
+		var tagID int64
+		err := row.Scan(&tagID, &a.EntityType, &a.EntityID, &a.CreatedAt)
+		a.TagID = strconv.FormatInt(tagID, 10)
+		return a, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[TagAssociation]bool, len(assocs))
+	for _, a := range assocs {
+		seen[TagAssociation{TagID: a.TagID, EntityType: a.EntityType, EntityID: a.EntityID}] = true
+	}
+	for _, legacy := range []struct{ table, column, entityType string }{
+		{"user_tags", "user_id", "user"},
+		{"media_tags", "media_id", "media"},
+	} {
+		rows, err := queryAll(s.db, `SELECT `+legacy.column+`, tag_id FROM `+legacy.table+` ORDER BY tag_id, `+legacy.column, func(row rowScanner) (TagAssociation, error) {
+			var entityID, tagID int64
+			err := row.Scan(&entityID, &tagID)
+			return TagAssociation{
+				TagID:      strconv.FormatInt(tagID, 10),
+				EntityType: legacy.entityType,
+				EntityID:   strconv.FormatInt(entityID, 10),
+			}, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range rows {
+			if !seen[a] {
+				seen[a] = true
+				assocs = append(assocs, a)
+			}
+		}
+	}
+	return assocs, nil
+}
+
+func scanSnapshotUser(row rowScanner) (UserWithPassword, error) {
+	var u UserWithPassword
+	var id int64
+	var email sql.NullString
+	err := row.Scan(&id, &u.Username, &u.PasswordHash, &email, &u.Role, &u.CreatedAt)
+	u.ID = strconv.FormatInt(id, 10)
+	u.Email = email.String
+	return u, err
+}
+
+func scanSnapshotSession(row rowScanner) (Session, error) {
+	var s Session
+	var id, userID int64
+	err := row.Scan(&id, &userID, &s.Token, &s.ExpiresAt, &s.CreatedAt)
+	s.ID = strconv.FormatInt(id, 10)
+	s.UserID = strconv.FormatInt(userID, 10)
+	return s, err
+}
+
+func scanSnapshotAPIKey(row rowScanner) (ApiKey, error) {
+	var k ApiKey
+	var id, userID int64
+	err := row.Scan(&id, &userID, &k.Key, &k.CreatedAt)
+	k.ID = strconv.FormatInt(id, 10)
+	k.UserID = strconv.FormatInt(userID, 10)
+	return k, err
+}
+
+func scanSnapshotLoginToken(row rowScanner) (LoginToken, error) {
+	var t LoginToken
+	var id, userID int64
+	err := row.Scan(&id, &userID, &t.Token, &t.ExpiresAt, &t.Used, &t.CreatedAt)
+	t.ID = strconv.FormatInt(id, 10)
+	t.UserID = strconv.FormatInt(userID, 10)
+	return t, err
+}
+
+func scanSnapshotDashboardPref(row rowScanner) (DashboardPref, error) {
+	var d DashboardPref
+	var userID int64
+	err := row.Scan(&userID, &d.Layout, &d.UpdatedAt)
+	d.UserID = strconv.FormatInt(userID, 10)
+	return d, err
+}
+
+func scanSnapshotMediaItem(row rowScanner) (MediaItem, error) {
+	var m MediaItem
+	var id int64
+	var season, episode sql.NullInt64
+	var group, alt, locks, hashes sql.NullString
+	err := row.Scan(&id, &m.Path, &m.Title, &season, &episode, &group, &alt, &locks, &hashes, &m.CreatedAt)
+	m.ID = strconv.FormatInt(id, 10)
+	m.Season, m.Episode = int(season.Int64), int(episode.Int64)
+	m.ReleaseGroup, m.AltTitles, m.FieldLocks, m.Hashes = group.String, alt.String, locks.String, hashes.String
+	return m, err
+}
+
+func scanSnapshotSubtitle(row rowScanner) (SubtitleRecord, error) {
+	var r SubtitleRecord
+	var id int64
+	var video, release, sourceURL, metadata, parentID, modType sql.NullString
+	var confidence sql.NullFloat64
+	err := row.Scan(&id, &r.File, &video, &release, &r.Language, &r.Service, &r.Embedded, &sourceURL, &metadata, &confidence, &parentID, &modType, &r.CreatedAt)
+	r.ID = strconv.FormatInt(id, 10)
+	r.VideoFile, r.Release, r.SourceURL = video.String, release.String, sourceURL.String
+	r.ProviderMetadata, r.ModificationType = metadata.String, modType.String
+	if confidence.Valid {
+		r.ConfidenceScore = &confidence.Float64
+	}
+	if parentID.Valid {
+		r.ParentID = &parentID.String
+	}
+	return r, err
+}
+
+func scanSnapshotDownload(row rowScanner) (DownloadRecord, error) {
+	var d DownloadRecord
+	var id int64
+	var query, errMsg sql.NullString
+	var score sql.NullFloat64
+	var attempts, responseTime sql.NullInt64
+	err := row.Scan(&id, &d.File, &d.VideoFile, &d.Provider, &d.Language, &query, &score, &attempts, &errMsg, &responseTime, &d.CreatedAt)
+	d.ID = strconv.FormatInt(id, 10)
+	d.SearchQuery, d.ErrorMessage = query.String, errMsg.String
+	d.DownloadAttempts = int(attempts.Int64)
+	if score.Valid {
+		d.MatchScore = &score.Float64
+	}
+	if responseTime.Valid {
+		ms := int(responseTime.Int64)
+		d.ResponseTimeMs = &ms
+	}
+	return d, err
+}
+
+func scanSnapshotSubtitleSource(row rowScanner) (SubtitleSource, error) {
+	var src SubtitleSource
+	var id int64
+	var title, releaseInfo, metadata sql.NullString
+	var fileSize, downloads, successes sql.NullInt64
+	var rating sql.NullFloat64
+	var lastSeen sql.NullTime
+	err := row.Scan(&id, &src.SourceHash, &src.OriginalURL, &src.Provider, &title, &releaseInfo, &fileSize, &downloads, &successes, &rating, &lastSeen, &metadata, &src.CreatedAt)
+	src.ID = strconv.FormatInt(id, 10)
+	src.Title, src.ReleaseInfo, src.Metadata = title.String, releaseInfo.String, metadata.String
+	src.DownloadCount, src.SuccessCount = int(downloads.Int64), int(successes.Int64)
+	src.LastSeen = lastSeen.Time
+	if fileSize.Valid {
+		size := int(fileSize.Int64)
+		src.FileSize = &size
+	}
+	if rating.Valid {
+		src.AvgRating = &rating.Float64
+	}
+	return src, err
+}
+
+func scanSnapshotSubtitleScore(row rowScanner) (SubtitleScore, error) {
+	var sc SubtitleScore
+	var id int64
+	var metadata string
+	err := row.Scan(&id, &sc.SubtitleID, &sc.ProviderName, &sc.LanguageMatch, &sc.ProviderRank, &sc.ReleaseMatch, &sc.FormatMatch, &sc.UserRating, &sc.DownloadCount, &sc.TotalScore, &sc.ScoreVersion, &metadata, &sc.CreatedAt, &sc.UpdatedAt)
+	if err != nil {
+		return sc, err
+	}
+	sc.ID = strconv.FormatInt(id, 10)
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &sc.Metadata); err != nil {
+			return sc, fmt.Errorf("score %d metadata: %w", id, err)
+		}
+	}
+	return sc, nil
+}
+
+func scanSnapshotTag(row rowScanner) (Tag, error) {
+	var t Tag
+	var id int64
+	var entityType, color, description sql.NullString
+	err := row.Scan(&id, &t.Name, &t.Type, &entityType, &color, &description, &t.CreatedAt)
+	t.ID = strconv.FormatInt(id, 10)
+	t.EntityType, t.Color, t.Description = entityType.String, color.String, description.String
+	return t, err
+}
+
+func scanSnapshotLanguageProfile(row rowScanner) (LanguageProfile, error) {
+	var lp LanguageProfile
+	var config string
+	var cutoff sql.NullInt64
+	var isDefault sql.NullBool
+	if err := row.Scan(&lp.ID, &lp.Name, &config, &cutoff, &isDefault, &lp.CreatedAt, &lp.UpdatedAt); err != nil {
+		return lp, err
+	}
+	lp.CutoffScore, lp.IsDefault = int(cutoff.Int64), isDefault.Bool
+	if err := lp.UnmarshalConfig([]byte(config)); err != nil {
+		return lp, fmt.Errorf("profile %s config: %w", lp.ID, err)
+	}
+	return lp, nil
+}
+
+func scanSnapshotMediaProfile(row rowScanner) (LanguageProfileAssignment, error) {
+	var a LanguageProfileAssignment
+	err := row.Scan(&a.MediaID, &a.ProfileID, &a.CreatedAt)
+	return a, err
+}
+
+func scanSnapshotMonitoredItem(row rowScanner) (MonitoredItem, error) {
+	var m MonitoredItem
+	var id int64
+	var lastChecked sql.NullTime
+	err := row.Scan(&id, &m.MediaID, &m.Path, &m.Languages, &lastChecked, &m.Status, &m.RetryCount, &m.MaxRetries, &m.CreatedAt, &m.UpdatedAt)
+	m.ID = strconv.FormatInt(id, 10)
+	m.LastChecked = lastChecked.Time
+	return m, err
+}
+
+// sqlImporter writes a snapshot inside one transaction and remaps the
+// integer IDs assigned by the database in every reference.
+type sqlImporter struct {
+	sqlSnapshot
+	tx sqlQuerier
+	// ids maps source IDs to target IDs per table.
+	ids map[string]map[string]string
+	// preserve records the tables that were empty before the import and
+	// therefore keep numeric source IDs.
+	preserve map[string]bool
+	skipped  map[string]int
+}
+
+func (s sqlSnapshot) importSnapshot(snap *Snapshot) (map[string]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	im := &sqlImporter{
+		sqlSnapshot: s,
+		tx:          tx,
+		ids:         make(map[string]map[string]string),
+		preserve:    make(map[string]bool),
+		skipped:     make(map[string]int),
+	}
+	if err := im.run(snap); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return im.skipped, nil
+}
+
+func (im *sqlImporter) run(snap *Snapshot) error {
+	for _, table := range serialTables {
+		var n int
+		if err := im.tx.QueryRow(`SELECT COUNT(1) FROM ` + table).Scan(&n); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		im.preserve[table] = n == 0
+		im.ids[table] = make(map[string]string)
+	}
+	steps := []func(*Snapshot) error{
+		im.users, im.credentials, im.mediaItems, im.subtitles, im.downloads,
+		im.subtitleSources, im.subtitleScores, im.tags, im.tagAssociations,
+		im.languageProfiles, im.mediaProfiles, im.monitoredItems, im.queueJobs,
+		im.fileIndex,
+	}
+	for _, step := range steps {
+		if err := step(snap); err != nil {
+			return err
+		}
+	}
+	if im.postgres {
+		// Move sequences past explicitly inserted IDs.
+		for _, table := range serialTables {
+			if _, err := im.tx.Exec(`SELECT setval(pg_get_serial_sequence('` + table + `', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM ` + table); err != nil {
+				return fmt.Errorf("reset %s sequence: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// insert adds a row to table and records the ID it received. The source ID
+// is kept when it is numeric and the table was empty before the import.
+// conflict is an optional ON CONFLICT clause that must update the row so
+// its ID is returned.
+func (im *sqlImporter) insert(table, srcID string, cols []string, conflict string, args ...any) (string, error) {
+	if id, err := strconv.ParseInt(srcID, 10, 64); err == nil && im.preserve[table] {
+		cols = append([]string{"id"}, cols...)
+		args = append([]any{id}, args...)
+	}
+	query := `INSERT INTO ` + table + ` (` + strings.Join(cols, ", ") + `) VALUES (` +
+		strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + `)` + conflict + ` RETURNING id`
+	var id int64
+	if err := im.tx.QueryRow(im.rebind(query), args...).Scan(&id); err != nil {
+		return "", fmt.Errorf("insert into %s: %w", table, err)
+	}
+	newID := strconv.FormatInt(id, 10)
+	if srcID != "" {
+		im.ids[table][srcID] = newID
+	}
+	return newID, nil
+}
+
+// exec runs query after rebinding its placeholders.
+func (im *sqlImporter) exec(query string, args ...any) (sql.Result, error) {
+	return im.tx.Exec(im.rebind(query), args...)
+}
+
+// ref returns the target ID of a record imported into table.
+func (im *sqlImporter) ref(table, srcID string) (int64, bool) {
+	id, ok := im.ids[table][srcID]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	return n, err == nil
+}
+
+func (im *sqlImporter) users(snap *Snapshot) error {
+	for _, u := range snap.Users {
+		// Existing accounts with the same username are reused.
+		if _, err := im.insert("users", u.ID, []string{"username", "password_hash", "email", "role", "created_at"},
+			` ON CONFLICT (username) DO UPDATE SET username = excluded.username`,
+			u.Username, u.PasswordHash, u.Email, u.Role, orNow(u.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// credentials imports sessions, API keys, login tokens and dashboard
+// layouts, which all reference users.
+func (im *sqlImporter) credentials(snap *Snapshot) error {
+	for _, s := range snap.Sessions {
+		uid, ok := im.ref("users", s.UserID)
+		if !ok {
+			im.skipped[entitySessions]++
+			continue
+		}
+		if _, err := im.insert("sessions", s.ID, []string{"user_id", "token", "expires_at", "created_at"},
+			` ON CONFLICT (token) DO UPDATE SET token = excluded.token`,
+			uid, s.Token, s.ExpiresAt, orNow(s.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	for _, k := range snap.APIKeys {
+		uid, ok := im.ref("users", k.UserID)
+		if !ok {
+			im.skipped[entityAPIKeys]++
+			continue
+		}
+		if _, err := im.insert("api_keys", k.ID, []string{"user_id", "key", "created_at"},
+			` ON CONFLICT (key) DO UPDATE SET key = excluded.key`,
+			uid, k.Key, orNow(k.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	for _, t := range snap.LoginTokens {
+		uid, ok := im.ref("users", t.UserID)
+		if !ok {
+			im.skipped[entityLoginTokens]++
+			continue
+		}
+		if _, err := im.insert("login_tokens", t.ID, []string{"user_id", "token", "expires_at", "used", "created_at"},
+			` ON CONFLICT (token) DO UPDATE SET token = excluded.token`,
+			uid, t.Token, t.ExpiresAt, t.Used, orNow(t.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	for _, d := range snap.DashboardPrefs {
+		uid, ok := im.ref("users", d.UserID)
+		if !ok {
+			im.skipped[entityDashboardPrefs]++
+			continue
+		}
+		if _, err := im.exec(`INSERT INTO dashboard_prefs (user_id, layout, updated_at) VALUES (?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE SET layout = excluded.layout, updated_at = excluded.updated_at`,
+			uid, d.Layout, orNow(d.UpdatedAt)); err != nil {
+			return fmt.Errorf("insert into dashboard_prefs: %w", err)
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) mediaItems(snap *Snapshot) error {
+	for _, m := range snap.MediaItems {
+		if _, err := im.insert("media_items", m.ID, []string{"path", "title", "season", "episode", "release_group", "alt_titles", "field_locks", "hashes", "created_at"}, "",
+			m.Path, m.Title, m.Season, m.Episode, m.ReleaseGroup, m.AltTitles, m.FieldLocks, m.Hashes, orNow(m.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subtitles inserts subtitle records, then links modified subtitles to
+// their parents once every parent has its target ID.
+func (im *sqlImporter) subtitles(snap *Snapshot) error {
+	for _, r := range snap.Subtitles {
+		if _, err := im.insert("subtitles", r.ID, []string{"file", "video_file", "release", "language", "service", "embedded", "source_url", "provider_metadata", "confidence_score", "modification_type", "created_at"}, "",
+			r.File, r.VideoFile, r.Release, r.Language, r.Service, r.Embedded, r.SourceURL, r.ProviderMetadata, r.ConfidenceScore, r.ModificationType, orNow(r.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	for _, r := range snap.Subtitles {
+		if r.ParentID == nil {
+			continue
+		}
+		parent, ok := im.ref("subtitles", *r.ParentID)
+		if !ok {
+			continue
+		}
+		id, _ := im.ref("subtitles", r.ID)
+		if _, err := im.exec(`UPDATE subtitles SET parent_id = ? WHERE id = ?`, parent, id); err != nil {
+			return fmt.Errorf("link subtitle parent: %w", err)
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) downloads(snap *Snapshot) error {
+	for _, d := range snap.Downloads {
+		if _, err := im.insert("downloads", d.ID, []string{"file", "video_file", "provider", "language", "search_query", "match_score", "download_attempts", "error_message", "response_time_ms", "created_at"}, "",
+			d.File, d.VideoFile, d.Provider, d.Language, d.SearchQuery, d.MatchScore, d.DownloadAttempts, d.ErrorMessage, d.ResponseTimeMs, orNow(d.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) subtitleSources(snap *Snapshot) error {
+	for _, s := range snap.SubtitleSources {
+		var lastSeen any
+		if !s.LastSeen.IsZero() {
+			lastSeen = s.LastSeen
+		}
+		if _, err := im.insert("subtitle_sources", s.ID, []string{"source_hash", "original_url", "provider", "title", "release_info", "file_size", "download_count", "success_count", "avg_rating", "last_seen", "metadata", "created_at"},
+			` ON CONFLICT (source_hash) DO UPDATE SET source_hash = excluded.source_hash`,
+			s.SourceHash, s.OriginalURL, s.Provider, s.Title, s.ReleaseInfo, s.FileSize, s.DownloadCount, s.SuccessCount, s.AvgRating, lastSeen, s.Metadata, orNow(s.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) subtitleScores(snap *Snapshot) error {
+	for _, sc := range snap.SubtitleScores {
+		subtitleID := sc.SubtitleID
+		if id, ok := im.ids["subtitles"][subtitleID]; ok {
+			subtitleID = id
+		}
+		metadata := []byte("{}")
+		if sc.Metadata != nil {
+			var err error
+			if metadata, err = json.Marshal(sc.Metadata); err != nil {
+				return err
+			}
+		}
+		if _, err := im.insert("subtitle_scores", sc.ID, []string{"subtitle_id", "provider_name", "language_match", "provider_rank", "release_match", "format_match", "user_rating", "download_count", "total_score", "score_version", "metadata", "created_at", "updated_at"}, "",
+			subtitleID, sc.ProviderName, sc.LanguageMatch, sc.ProviderRank, sc.ReleaseMatch, sc.FormatMatch, sc.UserRating, sc.DownloadCount, sc.TotalScore, sc.ScoreVersion, string(metadata), orNow(sc.CreatedAt), orNow(sc.UpdatedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) tags(snap *Snapshot) error {
+	for _, t := range snap.Tags {
+		tagType, entityType := t.Type, t.EntityType
+		if tagType == "" {
+			tagType = "user"
+		}
+		if entityType == "" {
+			entityType = "all"
+		}
+		// Existing tags with the same name are reused.
+		if _, err := im.insert("tags", t.ID, []string{"name", "type", "entity_type", "color", "description", "created_at"},
+			` ON CONFLICT (name) DO UPDATE SET name = excluded.name`,
+			t.Name, tagType, entityType, t.Color, t.Description, orNow(t.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagAssociations writes associations to tag_associations and, for users
+// and media, to the legacy tables read by the int64 tag API.
+func (im *sqlImporter) tagAssociations(snap *Snapshot) error {
+	for _, a := range snap.TagAssociations {
+		tagID, ok := im.ref("tags", a.TagID)
+		if !ok {
+			im.skipped[entityTagAssociations]++
+			continue
+		}
+		entityID := a.EntityID
+		var legacy string
+		switch a.EntityType {
+		case "user":
+			legacy = `INSERT INTO user_tags (user_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
+			id, ok := im.ref("users", a.EntityID)
+			if !ok {
+				im.skipped[entityTagAssociations]++
+				continue
+			}
+			entityID = strconv.FormatInt(id, 10)
+		case "media":
+			legacy = `INSERT INTO media_tags (media_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
+			id, ok := im.ref("media_items", a.EntityID)
+			if !ok {
+				im.skipped[entityTagAssociations]++
+				continue
+			}
+			entityID = strconv.FormatInt(id, 10)
+		}
+		if _, err := im.exec(`INSERT INTO tag_associations (tag_id, entity_type, entity_id, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			tagID, a.EntityType, entityID, orNow(a.CreatedAt)); err != nil {
+			return fmt.Errorf("insert into tag_associations: %w", err)
+		}
+		if legacy != "" {
+			id, _ := strconv.ParseInt(entityID, 10, 64)
+			if _, err := im.exec(legacy, id, tagID); err != nil {
+				return fmt.Errorf("insert legacy tag association: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// languageProfiles upserts profiles by ID, so the seeded default profile is
+// replaced by the source's version.
+func (im *sqlImporter) languageProfiles(snap *Snapshot) error {
+	defaultID := ""
+	for _, lp := range snap.LanguageProfiles {
+		config, err := lp.MarshalConfig()
+		if err != nil {
+			return err
+		}
+		if _, err := im.exec(`INSERT INTO language_profiles (id, name, config, cutoff_score, is_default, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, config = excluded.config, cutoff_score = excluded.cutoff_score, is_default = excluded.is_default, updated_at = excluded.updated_at`,
+			lp.ID, lp.Name, string(config), lp.CutoffScore, lp.IsDefault, orNow(lp.CreatedAt), orNow(lp.UpdatedAt)); err != nil {
+			return fmt.Errorf("insert into language_profiles: %w", err)
+		}
+		if lp.IsDefault {
+			defaultID = lp.ID
+		}
+	}
+	if defaultID != "" {
+		if _, err := im.exec(`UPDATE language_profiles SET is_default = ? WHERE id <> ?`, false, defaultID); err != nil {
+			return fmt.Errorf("reset default language profile: %w", err)
+		}
+	}
+	return nil
+}
+
+// mediaProfiles assigns profiles to media. Media IDs are remapped when they
+// refer to imported media items; paths are kept as they are.
+func (im *sqlImporter) mediaProfiles(snap *Snapshot) error {
+	for _, a := range snap.MediaProfiles {
+		mediaID := a.MediaID
+		if id, ok := im.ids["media_items"][mediaID]; ok {
+			mediaID = id
+		}
+		res, err := im.exec(`INSERT INTO media_profiles (media_id, profile_id, created_at)
+SELECT ?, ?, ? WHERE EXISTS (SELECT 1 FROM language_profiles WHERE id = ?)
+ON CONFLICT (media_id) DO UPDATE SET profile_id = excluded.profile_id`,
+			mediaID, a.ProfileID, orNow(a.CreatedAt), a.ProfileID)
+		if err != nil {
+			return fmt.Errorf("insert into media_profiles: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			im.skipped[entityMediaProfiles]++
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) monitoredItems(snap *Snapshot) error {
+	for _, m := range snap.MonitoredItems {
+		mediaID := m.MediaID
+		if id, ok := im.ids["media_items"][mediaID]; ok {
+			mediaID = id
+		}
+		var lastChecked any
+		if !m.LastChecked.IsZero() {
+			lastChecked = m.LastChecked
+		}
+		if _, err := im.insert("monitored_items", m.ID, []string{"media_id", "path", "languages", "last_checked", "status", "retry_count", "max_retries", "created_at", "updated_at"}, "",
+			mediaID, m.Path, m.Languages, lastChecked, m.Status, m.RetryCount, m.MaxRetries, orNow(m.CreatedAt), orNow(m.UpdatedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) queueJobs(snap *Snapshot) error {
+	for _, j := range snap.QueueJobs {
+		runAt := orNow(j.RunAt)
+		if _, err := im.exec(`INSERT INTO queue_jobs (`+queueJobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+			j.ID, j.Type, j.Payload, j.Priority, j.Status, j.Attempts, j.MaxAttempts, runAt.UTC(), j.LastError, orNow(j.CreatedAt).UTC(), orNow(j.UpdatedAt).UTC()); err != nil {
+			return fmt.Errorf("insert into queue_jobs: %w", err)
+		}
+	}
+	return nil
+}
+
+func (im *sqlImporter) fileIndex(snap *Snapshot) error {
+	for _, e := range snap.FileIndex {
+		results, err := json.Marshal(e.Results)
+		if err != nil || e.Results == nil {
+			results = []byte("{}")
+		}
+		if _, err := im.exec(`INSERT INTO file_index (`+fileIndexColumns+`) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (path) DO NOTHING`,
+			e.Path, e.Size, e.ModTime.UTC(), e.MediaHash, string(results), orNow(e.ScannedAt).UTC()); err != nil {
+			return fmt.Errorf("insert into file_index: %w", err)
+		}
+	}
+	return nil
+}
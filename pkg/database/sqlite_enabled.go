@@ -2,7 +2,7 @@
 // +build sqlite
 
 // file: pkg/database/sqlite_enabled.go
-// version: 1.0.0
+// version: 1.2.0
 // guid: 7e6f5a4b-3c2d-8e7f-1a0b-4c3d2e1f0a9b
 
 package database
@@ -89,6 +89,7 @@ func initSchema(db *sql.DB) error {
 		release_group TEXT,
 		alt_titles TEXT,
 		field_locks TEXT,
+		hashes TEXT DEFAULT '',
 		created_at TIMESTAMP NOT NULL
 	)`); err != nil {
 		return err
@@ -136,6 +137,9 @@ func initSchema(db *sql.DB) error {
 	if err := addColumnIfNotExists(db, "media_items", "field_locks", "TEXT"); err != nil {
 		return fmt.Errorf("failed to add column 'field_locks' to 'media_items': %w", err)
 	}
+	if err := addColumnIfNotExists(db, "media_items", "hashes", "TEXT DEFAULT ''"); err != nil {
+		return fmt.Errorf("failed to add column 'hashes' to 'media_items': %w", err)
+	}
 
 	// Subtitle sources table for tracking provider performance and metadata
 	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS subtitle_sources (
@@ -242,6 +246,34 @@ func initSchema(db *sql.DB) error {
 		return err
 	}
 
+	// Queue jobs table backing the durable job queue
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS queue_jobs (
+		id TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		payload BLOB,
+		priority INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 3,
+		run_at TIMESTAMP NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS file_index (
+		path TEXT PRIMARY KEY,
+		size INTEGER NOT NULL DEFAULT 0,
+		mod_time TIMESTAMP NOT NULL,
+		media_hash TEXT NOT NULL DEFAULT '',
+		results TEXT NOT NULL DEFAULT '{}',
+		scanned_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return err
+	}
+
 	// Universal tag associations table for polymorphic relationships
 	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS tag_associations (
 		tag_id INTEGER NOT NULL,
@@ -397,6 +429,9 @@ func initSchema(db *sql.DB) error {
 	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_monitored_items_status_checked ON monitored_items(status, last_checked)`); err != nil {
 		return err
 	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_queue_jobs_status_run ON queue_jobs(status, priority, run_at)`); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -75,8 +75,17 @@ type SubtitleStore interface {
 	GetMediaAltTitles(path string) ([]string, error)
 	// GetMediaFieldLocks retrieves locked fields for a media item.
 	GetMediaFieldLocks(path string) (string, error)
+	// SetMediaHashes stores the content hashes of a media file keyed by
+	// algorithm.
+	SetMediaHashes(path string, hashes map[string]string) error
+	// GetMediaHashes retrieves the content hashes of a media file.
+	GetMediaHashes(path string) (map[string]string, error)
 	// SetMediaTitle updates the title for a media item.
 	SetMediaTitle(path, title string) error
+	// MoveMediaPath points the media item, monitored items, subtitle and
+	// download records of a video moved from oldPath to newPath at the new
+	// location. Subtitle files are renamed with MovedSidecarPath.
+	MoveMediaPath(oldPath, newPath string) error
 	// CreateLanguageProfile stores a new language profile.
 	CreateLanguageProfile(profile *LanguageProfile) error
 	// GetLanguageProfile retrieves a language profile by ID.
@@ -115,6 +124,35 @@ type SubtitleStore interface {
 	// GetMonitoredItemsToCheck returns items that need monitoring.
 	GetMonitoredItemsToCheck(interval time.Duration) ([]MonitoredItem, error)
 
+	// Durable job queue
+	// InsertQueueJob stores a new queue job record.
+	InsertQueueJob(rec *QueueJob) error
+	// UpdateQueueJob persists changes to an existing queue job.
+	UpdateQueueJob(rec *QueueJob) error
+	// GetQueueJob retrieves a queue job by ID. Returns nil if not found.
+	GetQueueJob(id string) (*QueueJob, error)
+	// ListQueueJobs returns jobs with the given status, or all jobs when status
+	// is empty, ordered by priority and scheduled run time.
+	ListQueueJobs(status string) ([]QueueJob, error)
+	// ClaimQueueJob atomically marks the highest priority pending job due at
+	// or before now as running and returns it. Returns nil when none is ready.
+	ClaimQueueJob(now time.Time) (*QueueJob, error)
+	// CancelQueueJob atomically marks the job id cancelled if it is still
+	// pending and reports whether it did.
+	CancelQueueJob(id string) (bool, error)
+	// DeleteQueueJob removes a queue job by ID.
+	DeleteQueueJob(id string) error
+
+	// File index operations
+	// ListFileIndexEntries returns the index entries of files below dir, or
+	// every entry when dir is empty.
+	ListFileIndexEntries(dir string) ([]FileIndexEntry, error)
+	// UpsertFileIndexEntry stores the index entry for a file, replacing any
+	// entry with the same path.
+	UpsertFileIndexEntry(e *FileIndexEntry) error
+	// DeleteFileIndexEntry removes the index entry for path.
+	DeleteFileIndexEntry(path string) error
+
 	// User authentication and session management
 	// CreateUser creates a new user with hashed password and returns user ID.
 	CreateUser(username, passwordHash, email, role string) (string, error)
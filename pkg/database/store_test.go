@@ -1,5 +1,5 @@
 // file: pkg/database/store_test.go
-// version: 1.3.0
+// version: 1.5.0
 // guid: 8c7d6e5f-4a3b-2c1d-0e9f-8a7b6c5d4e3f
 
 package database
@@ -224,6 +224,19 @@ func testMediaOperations(t *testing.T, store SubtitleStore) {
 	require.NoError(t, err, "GetMediaAltTitles should succeed")
 	assert.Equal(t, altTitles, retrievedTitles)
 
+	// Test content hashes
+	hashes := map[string]string{"opensubtitles": "000000000000000a", "stamp": "8:1"}
+	err = store.SetMediaHashes("/videos/movie.mkv", hashes)
+	require.NoError(t, err, "SetMediaHashes should succeed")
+
+	retrievedHashes, err := store.GetMediaHashes("/videos/movie.mkv")
+	require.NoError(t, err, "GetMediaHashes should succeed")
+	assert.Equal(t, hashes, retrievedHashes)
+
+	missing, err := store.GetMediaHashes("/videos/missing.mkv")
+	require.NoError(t, err, "GetMediaHashes for unknown media should succeed")
+	assert.Nil(t, missing)
+
 	// Delete media item
 	err = store.DeleteMediaItem("/videos/movie.mkv")
 	require.NoError(t, err, "DeleteMediaItem should succeed")
@@ -433,3 +446,58 @@ func floatPtr(f float64) *float64 {
 func intPtr(i int) *int {
 	return &i
 }
+
+// TestMoveMediaPath verifies that moving a video carries its media item,
+// subtitle, download and monitoring records to the new path.
+func TestMoveMediaPath(t *testing.T) {
+	store := getTestStoreForInterface(t)
+	defer store.Close()
+
+	oldVideo, newVideo := "/downloads/Show.S01E01.mkv", "/tv/Show/Season 1/Show.S01E01.mkv"
+	require.NoError(t, store.InsertMediaItem(&MediaItem{Path: oldVideo, Title: "Show"}))
+	require.NoError(t, store.InsertSubtitle(&SubtitleRecord{File: "/downloads/Show.S01E01.en.srt", VideoFile: oldVideo, Language: "en", Service: "test"}))
+	require.NoError(t, store.InsertDownload(&DownloadRecord{File: "/downloads/Show.S01E01.en.srt", VideoFile: oldVideo, Language: "en", Provider: "test"}))
+	require.NoError(t, store.InsertDownload(&DownloadRecord{File: "/subs/other.srt", VideoFile: oldVideo, Language: "fr", Provider: "test"}))
+	require.NoError(t, store.InsertMonitoredItem(&MonitoredItem{Path: oldVideo, Languages: `["en"]`, Status: "pending"}))
+	require.NoError(t, store.InsertDownload(&DownloadRecord{File: "/downloads/Other.en.srt", VideoFile: "/downloads/Other.mkv", Language: "en", Provider: "test"}))
+
+	require.NoError(t, store.MoveMediaPath(oldVideo, newVideo))
+
+	item, err := store.GetMediaItem(newVideo)
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "Show", item.Title)
+	item, err = store.GetMediaItem(oldVideo)
+	require.NoError(t, err)
+	assert.Nil(t, item)
+
+	subs, err := store.ListSubtitlesByVideo(newVideo)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "/tv/Show/Season 1/Show.S01E01.en.srt", subs[0].File)
+
+	downloads, err := store.ListDownloadsByVideo(newVideo)
+	require.NoError(t, err)
+	var files []string
+	for _, d := range downloads {
+		files = append(files, d.File)
+	}
+	assert.ElementsMatch(t, []string{"/tv/Show/Season 1/Show.S01E01.en.srt", "/subs/other.srt"}, files)
+	downloads, err = store.ListDownloadsByVideo(oldVideo)
+	require.NoError(t, err)
+	assert.Empty(t, downloads)
+	downloads, err = store.ListDownloadsByVideo("/downloads/Other.mkv")
+	require.NoError(t, err)
+	assert.Len(t, downloads, 1)
+
+	items, err := store.ListMonitoredItems()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, newVideo, items[0].Path)
+}
+
+func TestMovedSidecarPath(t *testing.T) {
+	assert.Equal(t, "/tv/B.en.forced.srt", MovedSidecarPath("/dl/A.en.forced.srt", "/dl/A.mkv", "/tv/B.mkv"))
+	assert.Equal(t, "/subs/A.srt", MovedSidecarPath("/subs/A.srt", "/dl/A.mkv", "/tv/B.mkv"))
+	assert.Equal(t, "/dl/AB.en.srt", MovedSidecarPath("/dl/AB.en.srt", "/dl/A.mkv", "/tv/B.mkv"))
+}
@@ -0,0 +1,113 @@
+// file: pkg/embedded/embedded.go
+// version: 1.0.0
+// guid: 5c8e2a17-9d4b-4f63-b1a0-7e6d3c9f2b58
+
+// Package embedded decides whether subtitle tracks embedded in a video file
+// already provide a wanted subtitle, so scans and monitoring can skip the
+// provider search. Text tracks can optionally be extracted to sidecar files
+// instead of being used in place.
+package embedded
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	"github.com/jdfalk/subtitle-manager/pkg/video"
+)
+
+// Enabled reports whether embedded tracks are probed before searching
+// providers.
+func Enabled() bool {
+	if viper.IsSet("embedded.enabled") {
+		return viper.GetBool("embedded.enabled")
+	}
+	return true
+}
+
+// IgnoreImage reports whether image based tracks such as PGS and VobSub are
+// disregarded, so the wanted language is still searched for.
+func IgnoreImage() bool {
+	return viper.GetBool("embedded.ignore_image")
+}
+
+// Extract reports whether matching text tracks are extracted to sidecar
+// subtitle files rather than used in place.
+func Extract() bool {
+	return viper.GetBool("embedded.extract")
+}
+
+// Tracks returns the subtitle tracks embedded in videoPath, or none when
+// probing is disabled.
+func Tracks(videoPath string) ([]video.SubtitleTrack, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	return video.ProbeSubtitleTracks(videoPath)
+}
+
+// Find returns the track best providing a subtitle in lang with the given
+// forced and hearing impaired requirements, or nil. As with downloaded
+// subtitles, forced tracks only satisfy forced targets and hearing impaired
+// tracks satisfy regular targets too. Text tracks are preferred over image
+// tracks, which are not considered at all when ignoreImage is set.
+func Find(tracks []video.SubtitleTrack, lang string, forced, hi, ignoreImage bool) *video.SubtitleTrack {
+	var image *video.SubtitleTrack
+	for i := range tracks {
+		t := &tracks[i]
+		if !t.MatchesLanguage(lang) || t.Forced != forced || (hi && !t.HearingImpaired) {
+			continue
+		}
+		if t.IsText() {
+			return t
+		}
+		if image == nil && !ignoreImage {
+			image = t
+		}
+	}
+	return image
+}
+
+// Match is an embedded track providing a wanted subtitle.
+type Match struct {
+	Track video.SubtitleTrack
+	// Sidecar is the file the track was extracted to. It is empty when the
+	// track is used in place.
+	Sidecar string
+}
+
+// Satisfy returns the track of videoPath providing the wanted subtitle, or
+// nil when the providers must be searched. With Extract set a matching text
+// track is written to sidecar in SRT format unless sidecar is empty.
+func Satisfy(videoPath string, tracks []video.SubtitleTrack, lang string, forced, hi bool, sidecar string) (*Match, error) {
+	t := Find(tracks, lang, forced, hi, IgnoreImage())
+	if t == nil {
+		return nil, nil
+	}
+	m := &Match{Track: *t}
+	if !Extract() || !t.IsText() || sidecar == "" {
+		return m, nil
+	}
+	items, err := subtitles.ExtractTrack(videoPath, t.Position)
+	if err != nil {
+		return nil, fmt.Errorf("extract track %d: %w", t.Index, err)
+	}
+	sub := astisub.NewSubtitles()
+	sub.Items = items
+	f, err := os.Create(sidecar)
+	if err != nil {
+		return nil, err
+	}
+	if err := sub.WriteToSRT(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	m.Sidecar = sidecar
+	return m, nil
+}
@@ -0,0 +1,76 @@
+// file: pkg/embedded/embedded_test.go
+// version: 1.0.0
+// guid: 6f1d9b34-2c8e-4a57-b0e3-8d4a2f6c1e79
+
+package embedded
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	"github.com/jdfalk/subtitle-manager/pkg/video"
+)
+
+var tracks = []video.SubtitleTrack{
+	{Index: 2, Position: 0, Codec: "hdmv_pgs_subtitle", Language: "eng"},
+	{Index: 3, Position: 1, Codec: "subrip", Language: "eng", HearingImpaired: true},
+	{Index: 4, Position: 2, Codec: "subrip", Language: "fre", Forced: true},
+	{Index: 5, Position: 3, Codec: "dvd_subtitle", Language: "spa"},
+}
+
+func TestFind(t *testing.T) {
+	// Text tracks win over image tracks, HI tracks satisfy regular targets.
+	assert.Equal(t, 3, Find(tracks, "en", false, false, false).Index)
+	assert.Equal(t, 3, Find(tracks, "en", false, true, false).Index)
+	// Forced tracks only satisfy forced targets.
+	assert.Nil(t, Find(tracks, "fr", false, false, false))
+	assert.Equal(t, 4, Find(tracks, "fr", true, false, false).Index)
+	// Image tracks count unless ignored.
+	assert.Equal(t, 5, Find(tracks, "es", false, false, false).Index)
+	assert.Nil(t, Find(tracks, "es", false, false, true))
+	assert.Nil(t, Find(tracks, "es", false, true, false))
+	assert.Nil(t, Find(tracks, "de", false, false, false))
+}
+
+func TestSatisfyExtractsTextTrack(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "ffmpeg")
+	// Write a subtitle to the output file, the last argument.
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nfor last; do :; done\nprintf '1\\n00:00:01,000 --> 00:00:02,000\\nHello\\n\\n' > \"$last\"\n"), 0755))
+	subtitles.SetFFmpegPath(script)
+	defer subtitles.SetFFmpegPath("ffmpeg")
+	defer viper.Reset()
+
+	sidecar := filepath.Join(dir, "movie.en.srt")
+	m, err := Satisfy("movie.mkv", tracks, "en", false, false, sidecar)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Empty(t, m.Sidecar, "tracks are used in place by default")
+	assert.NoFileExists(t, sidecar)
+
+	viper.Set("embedded.extract", true)
+	m, err = Satisfy("movie.mkv", tracks, "en", false, false, sidecar)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, sidecar, m.Sidecar)
+	data, err := os.ReadFile(sidecar)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Hello")
+
+	// Image tracks cannot be extracted and are used in place.
+	m, err = Satisfy("movie.mkv", tracks, "es", false, false, filepath.Join(dir, "movie.es.srt"))
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Empty(t, m.Sidecar)
+
+	viper.Set("embedded.ignore_image", true)
+	m, err = Satisfy("movie.mkv", tracks, "es", false, false, "")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+}
@@ -0,0 +1,198 @@
+// file: pkg/fileindex/fileindex.go
+// version: 1.1.0
+// guid: 8b4e1f27-6c3a-4d95-b0e8-2f7a9c5d1e63
+
+// Package fileindex compares the video files found by a library scan with
+// the file index kept in the subtitle store, so scans can skip files that
+// have not changed since they were last evaluated.
+package fileindex
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
+)
+
+// VideoExtensions are the extensions of the video files library scans index.
+// Scans sharing an index must agree on them, as Finish removes the entries
+// of files a scan did not see.
+var VideoExtensions = []string{".mkv", ".mp4", ".avi", ".mov", ".wmv", ".flv", ".webm", ".m4v"}
+
+// IsVideo reports whether path has one of VideoExtensions, ignoring case.
+func IsVideo(path string) bool {
+	return slices.Contains(VideoExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// Summary counts the files seen by an incremental scan.
+type Summary struct {
+	// New files had no index entry.
+	New int `json:"new"`
+	// Changed files differ in size, modification time and media hash from
+	// their index entry.
+	Changed int `json:"changed"`
+	// Removed files had an index entry but were not found.
+	Removed int `json:"removed"`
+	// Skipped files were unchanged and needed no work.
+	Skipped int `json:"skipped"`
+	// Rechecked files were unchanged but evaluated again because their last
+	// result did not satisfy the scan or a full scan was requested.
+	Rechecked int `json:"rechecked"`
+	// Failed files could not be evaluated.
+	Failed int `json:"failed"`
+}
+
+// String formats the counts for log messages.
+func (s Summary) String() string {
+	return fmt.Sprintf("%d new, %d changed, %d removed, %d skipped, %d rechecked, %d failed",
+		s.New, s.Changed, s.Removed, s.Skipped, s.Rechecked, s.Failed)
+}
+
+// Index tracks the files of one scan below a directory. It is safe for
+// concurrent use by scan workers.
+type Index struct {
+	store database.SubtitleStore
+	full  bool
+
+	mu      sync.Mutex
+	entries map[string]database.FileIndexEntry
+	seen    map[string]bool
+	summary Summary
+}
+
+// Open loads the index entries of the files below dir. With full set every
+// file is evaluated regardless of its previous result. A nil store yields
+// an index that evaluates every file and persists nothing.
+func Open(store database.SubtitleStore, dir string, full bool) (*Index, error) {
+	ix := &Index{store: store, full: full, entries: make(map[string]database.FileIndexEntry), seen: make(map[string]bool)}
+	if store == nil {
+		return ix, nil
+	}
+	entries, err := store.ListFileIndexEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		ix.entries[e.Path] = e
+	}
+	return ix, nil
+}
+
+// Check compares the file at path with its index entry and returns the entry
+// to pass to Record, and whether the file must be evaluated. An unchanged
+// file is skipped when satisfied reports that its previous result for key
+// needs no further work. Files whose size or modification time changed but
+// whose media hash did not, such as files touched by a copy, count as
+// unchanged.
+func (ix *Index) Check(path string, info fs.FileInfo, key string, satisfied func(result string) bool) (*database.FileIndexEntry, bool) {
+	ix.mu.Lock()
+	ix.seen[path] = true
+	prev, known := ix.entries[path]
+	ix.mu.Unlock()
+
+	entry := &database.FileIndexEntry{Path: path, Size: info.Size(), ModTime: info.ModTime(), Results: map[string]string{}}
+	stampChanged := known && (prev.Size != entry.Size || !prev.ModTime.Equal(entry.ModTime))
+	if !known || stampChanged {
+		hash, err := MediaHash(path)
+		if err == nil {
+			entry.MediaHash = hash
+		}
+		if !known || hash == "" || hash != prev.MediaHash {
+			ix.count(func(s *Summary) {
+				if known {
+					s.Changed++
+				} else {
+					s.New++
+				}
+			})
+			return entry, true
+		}
+	} else {
+		entry.MediaHash = prev.MediaHash
+	}
+	for k, v := range prev.Results {
+		entry.Results[k] = v
+	}
+
+	if !ix.full && satisfied(prev.Results[key]) {
+		ix.count(func(s *Summary) { s.Skipped++ })
+		if stampChanged {
+			ix.save(entry)
+		}
+		return entry, false
+	}
+	ix.count(func(s *Summary) { s.Rechecked++ })
+	return entry, true
+}
+
+// Record stores result as the outcome of evaluating entry for key. Results
+// of failed evaluations are counted in the summary.
+func (ix *Index) Record(entry *database.FileIndexEntry, key, result string, failed bool) error {
+	if failed {
+		ix.count(func(s *Summary) { s.Failed++ })
+	}
+	entry.Results[key] = result
+	entry.ScannedAt = time.Now()
+	return ix.save(entry)
+}
+
+// Finish removes the index entries of files below the scanned directory that
+// were not seen by Check and returns the summary of the scan. It must only
+// be called after the whole directory was walked.
+func (ix *Index) Finish() (Summary, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for path := range ix.entries {
+		if ix.seen[path] {
+			continue
+		}
+		if ix.store != nil {
+			if err := ix.store.DeleteFileIndexEntry(path); err != nil {
+				return ix.summary, err
+			}
+		}
+		delete(ix.entries, path)
+		ix.summary.Removed++
+	}
+	return ix.summary, nil
+}
+
+// Summary returns the counts so far.
+func (ix *Index) Summary() Summary {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.summary
+}
+
+func (ix *Index) count(fn func(*Summary)) {
+	ix.mu.Lock()
+	fn(&ix.summary)
+	ix.mu.Unlock()
+}
+
+func (ix *Index) save(entry *database.FileIndexEntry) error {
+	if ix.store == nil {
+		return nil
+	}
+	if err := ix.store.UpsertFileIndexEntry(entry); err != nil {
+		return err
+	}
+	ix.mu.Lock()
+	ix.entries[entry.Path] = *entry
+	ix.mu.Unlock()
+	return nil
+}
+
+// MediaHash returns the OpenSubtitles hash of the file at path as 16
+// hexadecimal digits. The hash is shared with the providers through the
+// mediahash cache, so a scan does not read the file twice.
+func MediaHash(path string) (string, error) {
+	hash, _, err := mediahash.Hash(path, mediahash.OpenSubtitles)
+	return hash, err
+}
@@ -0,0 +1,82 @@
+// file: pkg/fileindex/fileindex_test.go
+// version: 1.0.0
+// guid: 2d9a6c41-8e7f-4b35-a1c0-5f3e8b7d2a94
+
+package fileindex
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+)
+
+func TestMediaHash(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.mkv")
+	require.NoError(t, os.WriteFile(small, []byte{1, 0, 0, 0, 0, 0, 0, 0}, 0644))
+	h, err := MediaHash(small)
+	require.NoError(t, err)
+	// size 8 plus the word 1 read from both the head and the tail block.
+	assert.Equal(t, "000000000000000a", h)
+
+	large := filepath.Join(dir, "large.mkv")
+	data := make([]byte, 3*64*1024)
+	require.NoError(t, os.WriteFile(large, data, 0644))
+	before, err := MediaHash(large)
+	require.NoError(t, err)
+	// The middle of the file is not part of the hash.
+	data[64*1024+8] = 0xff
+	require.NoError(t, os.WriteFile(large, data, 0644))
+	after, err := MediaHash(large)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	_, err = MediaHash(filepath.Join(dir, "missing.mkv"))
+	assert.Error(t, err)
+}
+
+func TestIndexTouchedFileIsUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "movie.mkv")
+	require.NoError(t, os.WriteFile(video, []byte("video"), 0644))
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	done := func(result string) bool { return result == "done" }
+
+	ix, err := Open(store, dir, false)
+	require.NoError(t, err)
+	info, err := os.Stat(video)
+	require.NoError(t, err)
+	entry, evaluate := ix.Check(video, info, "test", done)
+	require.True(t, evaluate)
+	require.NoError(t, ix.Record(entry, "test", "done", false))
+	summary, err := ix.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, Summary{New: 1}, summary)
+
+	// A new modification time with the same content keeps the previous result.
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(video, later, later))
+	ix, err = Open(store, dir, false)
+	require.NoError(t, err)
+	info, err = os.Stat(video)
+	require.NoError(t, err)
+	_, evaluate = ix.Check(video, info, "test", done)
+	assert.False(t, evaluate)
+	summary, err = ix.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Skipped: 1}, summary)
+
+	entries, err := store.ListFileIndexEntries(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, entries[0].ModTime.Equal(info.ModTime()))
+	assert.Equal(t, "done", entries[0].Results["test"])
+}
@@ -0,0 +1,356 @@
+// file: pkg/flaresolverr/flaresolverr.go
+// version: 1.1.1
+// guid: 2e84e929-138b-4d04-8afd-56cf82066a17
+
+// Package flaresolverr gets HTTP clients past Cloudflare challenge pages with
+// a FlareSolverr instance. FlareSolverr loads the challenged page in a real
+// browser and returns the clearance cookies and the user agent they are bound
+// to. Transport detects challenge pages, asks FlareSolverr for a clearance
+// and repeats the request with it. Clearances are cached per domain and proxy
+// until their cookies expire, so later requests go straight through, and
+// concurrent challenges for one domain are solved once.
+package flaresolverr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultTimeout is how long FlareSolverr may take to solve a challenge.
+const DefaultTimeout = 60 * time.Second
+
+// defaultTTL is how long a clearance without an expiring cookie is reused.
+const defaultTTL = 30 * time.Minute
+
+// Clearance is a solved challenge: the cookies and user agent that pass the
+// challenge of a domain until Expires.
+type Clearance struct {
+	Cookies   []*http.Cookie
+	UserAgent string
+	Expires   time.Time
+}
+
+// Client talks to the FlareSolverr HTTP API.
+type Client struct {
+	// URL is the FlareSolverr endpoint, such as http://localhost:8191/v1.
+	URL string
+	// Timeout bounds the time FlareSolverr spends on a challenge.
+	Timeout time.Duration
+	// HTTPClient is used to call FlareSolverr. Its timeout must exceed
+	// Timeout.
+	HTTPClient *http.Client
+	// Proxy is the proxy URL the FlareSolverr browser loads pages through.
+	// Cloudflare binds clearances to the IP address and user agent that
+	// solved the challenge, so it must match the proxy of the requests
+	// using them.
+	Proxy string
+}
+
+// New returns a Client for the FlareSolverr instance at url that allows
+// timeout per challenge, or DefaultTimeout when timeout is not positive. The
+// "/v1" API path is appended when missing.
+func New(url string, timeout time.Duration) *Client {
+	url = strings.TrimRight(url, "/")
+	if !strings.HasSuffix(url, "/v1") {
+		url += "/v1"
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{
+		URL:        url,
+		Timeout:    timeout,
+		HTTPClient: &http.Client{Timeout: timeout + 10*time.Second},
+	}
+}
+
+type solveRequest struct {
+	Cmd        string      `json:"cmd"`
+	URL        string      `json:"url"`
+	MaxTimeout int64       `json:"maxTimeout"`
+	Proxy      *solveProxy `json:"proxy,omitempty"`
+}
+
+type solveProxy struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// proxy returns the proxy settings sent to FlareSolverr, which takes the
+// credentials of proxy URLs in separate fields.
+func (c *Client) proxy() (*solveProxy, error) {
+	if c.Proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(c.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("flaresolverr: proxy: %w", err)
+	}
+	p := &solveProxy{}
+	if u.User != nil {
+		p.Username = u.User.Username()
+		p.Password, _ = u.User.Password()
+		u.User = nil
+	}
+	p.URL = u.String()
+	return p, nil
+}
+
+type solveResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Solution struct {
+		Status  int `json:"status"`
+		Cookies []struct {
+			Name     string  `json:"name"`
+			Value    string  `json:"value"`
+			Domain   string  `json:"domain"`
+			Path     string  `json:"path"`
+			Expires  float64 `json:"expires"`
+			HTTPOnly bool    `json:"httpOnly"`
+			Secure   bool    `json:"secure"`
+		} `json:"cookies"`
+		UserAgent string `json:"userAgent"`
+	} `json:"solution"`
+}
+
+// Solve loads pageURL through FlareSolverr and returns the clearance it
+// obtained. The clearance expires with the earliest expiring cookie, or
+// after 30 minutes when all cookies last for the session.
+func (c *Client) Solve(ctx context.Context, pageURL string) (*Clearance, error) {
+	proxy, err := c.proxy()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(solveRequest{Cmd: "request.get", URL: pageURL, MaxTimeout: c.Timeout.Milliseconds(), Proxy: proxy})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var sr solveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return nil, fmt.Errorf("flaresolverr: status %d: %w", resp.StatusCode, err)
+	}
+	if sr.Status != "ok" {
+		return nil, fmt.Errorf("flaresolverr: %s", sr.Message)
+	}
+	now := time.Now()
+	cl := &Clearance{UserAgent: sr.Solution.UserAgent, Expires: now.Add(defaultTTL)}
+	for _, ck := range sr.Solution.Cookies {
+		cookie := &http.Cookie{Name: ck.Name, Value: ck.Value, Domain: ck.Domain, Path: ck.Path, HttpOnly: ck.HTTPOnly, Secure: ck.Secure}
+		if ck.Expires > 0 {
+			cookie.Expires = time.Unix(int64(ck.Expires), 0)
+			if cookie.Expires.Before(cl.Expires) {
+				cl.Expires = cookie.Expires
+			}
+		}
+		cl.Cookies = append(cl.Cookies, cookie)
+	}
+	return cl, nil
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]*Clearance{}
+
+	// solves shares one solve among concurrent challenges for a cache key.
+	solves singleflight.Group
+)
+
+// cacheKey identifies the clearances of host solved through proxy. A
+// clearance is bound to the IP address that solved it, so clients behind
+// different proxies never share one.
+func cacheKey(host, proxy string) string {
+	return proxy + "|" + host
+}
+
+// lookup returns the unexpired clearance cached for key.
+func lookup(key string) *Clearance {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cl := cache[key]
+	if cl == nil || !time.Now().Before(cl.Expires) {
+		delete(cache, key)
+		return nil
+	}
+	return cl
+}
+
+// remember caches cl for key.
+func remember(key string, cl *Clearance) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cache[key] = cl
+}
+
+// drop removes cl from the cache if it is still the clearance of key.
+func drop(key string, cl *Clearance) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if cl != nil && cache[key] == cl {
+		delete(cache, key)
+	}
+}
+
+// Reset drops all cached clearances.
+func Reset() {
+	cacheMu.Lock()
+	cache = map[string]*Clearance{}
+	cacheMu.Unlock()
+}
+
+// Transport is an http.RoundTripper that passes Cloudflare challenges with
+// Solver. Requests to domains with a cached clearance carry its cookies and
+// user agent. When a response is a challenge page, the page is solved and
+// the request repeated once with the new clearance; requests whose body
+// cannot be replayed return the challenge response as is.
+type Transport struct {
+	// Base performs the requests. http.DefaultTransport is used when nil.
+	Base http.RoundTripper
+	// Solver solves challenge pages.
+	Solver *Client
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	key := cacheKey(host, t.Solver.Proxy)
+	first := req
+	used := lookup(key)
+	if used != nil {
+		first = withClearance(req, used)
+	}
+	resp, err := t.base().RoundTrip(first)
+	if err != nil || !IsChallenge(resp) {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	cl, err := t.solve(req, key, used)
+	if err != nil {
+		return nil, fmt.Errorf("cloudflare challenge on %s: %w", host, err)
+	}
+	retry := withClearance(req, cl)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	resp, err = t.base().RoundTrip(retry)
+	if err == nil && IsChallenge(resp) {
+		drop(key, cl)
+	}
+	return resp, err
+}
+
+// solve returns a clearance for the cache key other than the rejected one.
+// Concurrent challenges for key share one solve, whose clearance is cached at once so
+// that later requests use it; a clearance cached by another request in the
+// meantime is reused. The solve runs until done even when req is cancelled,
+// since other requests may be waiting for it.
+func (t *Transport) solve(req *http.Request, key string, rejected *Clearance) (*Clearance, error) {
+	ch := solves.DoChan(key, func() (any, error) {
+		if cl := lookup(key); cl != nil && cl != rejected {
+			return cl, nil
+		}
+		drop(key, rejected)
+		cl, err := t.Solver.Solve(context.WithoutCancel(req.Context()), req.URL.String())
+		if err != nil {
+			return nil, err
+		}
+		remember(key, cl)
+		return cl, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*Clearance), nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+// withClearance returns a copy of req carrying the clearance cookies and
+// user agent.
+func withClearance(req *http.Request, cl *Clearance) *http.Request {
+	r := req.Clone(req.Context())
+	if cl.UserAgent != "" {
+		r.Header.Set("User-Agent", cl.UserAgent)
+	}
+	for _, ck := range cl.Cookies {
+		r.AddCookie(&http.Cookie{Name: ck.Name, Value: ck.Value})
+	}
+	return r
+}
+
+// challengeMarkers appear in the HTML of Cloudflare challenge pages.
+var challengeMarkers = []string{
+	"cf-browser-verification",
+	"cf_chl_opt",
+	"/cdn-cgi/challenge-platform/",
+	"<title>Just a moment...</title>",
+}
+
+// peekSize bounds how much of a suspect response is inspected.
+const peekSize = 64 * 1024
+
+// IsChallenge reports whether resp is a Cloudflare challenge page: a 403 or
+// 503 response flagged by the cf-mitigated header or served by Cloudflare
+// with challenge markup. The body of inspected responses stays readable.
+func IsChallenge(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+	if resp.Header.Get("Cf-Mitigated") == "challenge" {
+		return true
+	}
+	if !strings.EqualFold(resp.Header.Get("Server"), "cloudflare") {
+		return false
+	}
+	peek, err := io.ReadAll(io.LimitReader(resp.Body, peekSize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	if err != nil {
+		return false
+	}
+	for _, m := range challengeMarkers {
+		if bytes.Contains(peek, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,5 @@
 // file: pkg/i18n/i18n.go
-// version: 1.0.0
+// version: 1.1.0
 // guid: 123e4567-e89b-12d3-a456-426614174001
 
 package i18n
@@ -125,7 +125,7 @@ func (l *Localizer) loadEmbeddedTranslations() {
 		"cli.scan.short":        "Scan directory and download subtitles",
 		"cli.scan.scanning":     "scanning %s",
 		"cli.scan.flag.upgrade": "replace existing subtitles",
-		"cli.convert.short":     "Convert subtitle to SRT",
+		"cli.convert.short":     "Convert subtitle between formats",
 		"cli.convert.converted": "Converted %s to %s",
 		"cli.merge.short":       "Merge subtitle files",
 		"cli.translate.short":   "Translate subtitle file",
@@ -166,7 +166,7 @@ func (l *Localizer) loadEmbeddedTranslations() {
 		"cli.scan.short":        "Escanear directorio y descargar subtítulos",
 		"cli.scan.scanning":     "escaneando %s",
 		"cli.scan.flag.upgrade": "reemplazar subtítulos existentes",
-		"cli.convert.short":     "Convertir subtítulo a SRT",
+		"cli.convert.short":     "Convertir subtítulo entre formatos",
 		"cli.convert.converted": "Convertido %s a %s",
 		"cli.merge.short":       "Fusionar archivos de subtítulos",
 		"cli.translate.short":   "Traducir archivo de subtítulos",
@@ -207,7 +207,7 @@ func (l *Localizer) loadEmbeddedTranslations() {
 		"cli.scan.short":        "Analyser le répertoire et télécharger les sous-titres",
 		"cli.scan.scanning":     "analyse de %s",
 		"cli.scan.flag.upgrade": "remplacer les sous-titres existants",
-		"cli.convert.short":     "Convertir le sous-titre en SRT",
+		"cli.convert.short":     "Convertir le sous-titre dans un autre format",
 		"cli.convert.converted": "Converti %s en %s",
 		"cli.merge.short":       "Fusionner les fichiers de sous-titres",
 		"cli.translate.short":   "Traduire le fichier de sous-titres",
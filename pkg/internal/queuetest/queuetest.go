@@ -0,0 +1,69 @@
+// file: pkg/internal/queuetest/queuetest.go
+// version: 1.0.0
+// guid: d1d5a056-7ccd-4792-8167-974010b3b19b
+
+// Package queuetest provides helpers for tests that wait on jobs run by the
+// persistent job queue.
+package queuetest
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+)
+
+// Timeout is how long the helpers wait before failing the test.
+const Timeout = 5 * time.Second
+
+// pollInterval is how often the helpers check again.
+const pollInterval = 10 * time.Millisecond
+
+// Jobs looks up the persisted record of a job. *queue.Queue implements it.
+type Jobs interface {
+	Job(id string) (*database.QueueJob, error)
+}
+
+// WaitForStatus polls q until job id reaches the want status and returns its
+// record. The test fails when the lookup fails or Timeout passes first.
+func WaitForStatus(t testing.TB, q Jobs, id, want string) *database.QueueJob {
+	t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		rec, err := q.Job(id)
+		if err != nil {
+			t.Fatalf("job %s: %v", id, err)
+		}
+		if rec != nil && rec.Status == want {
+			return rec
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not reach %s: %+v", id, want, rec)
+			return nil
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// WaitWhile polls get while it returns one of the pending statuses and
+// returns the first other status. The test fails when get fails or Timeout
+// passes first.
+func WaitWhile(t testing.TB, get func() (string, error), pending ...string) string {
+	t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		st, err := get()
+		if err != nil {
+			t.Fatalf("job status: %v", err)
+		}
+		if !slices.Contains(pending, st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s after %s", st, Timeout)
+			return ""
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,535 @@
+// file: pkg/jobs/jobs.go
+// version: 1.6.2
+// guid: 3d8e1f64-7a2b-4c59-9e06-b4f2c8a1d357
+
+// Package jobs registers queue handlers for long running operations: scans,
+// library syncs, transcriptions, extractions, provider searches and subtitle
+// upgrades. Importing the package makes these job types available to
+// queue.NewJob and lets persisted jobs of these types resume after a restart.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/metadata"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/radarr"
+	"github.com/jdfalk/subtitle-manager/pkg/scanner"
+	"github.com/jdfalk/subtitle-manager/pkg/security"
+	"github.com/jdfalk/subtitle-manager/pkg/sonarr"
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	"github.com/jdfalk/subtitle-manager/pkg/transcriber"
+)
+
+// ScanPayload describes a directory scan downloading missing subtitles.
+type ScanPayload struct {
+	Directory string `json:"directory"`
+	Language  string `json:"language"`
+	Provider  string `json:"provider,omitempty"`
+	Upgrade   bool   `json:"upgrade,omitempty"`
+	Workers   int    `json:"workers,omitempty"`
+	// Full re-evaluates files the file index records as unchanged.
+	Full bool `json:"full,omitempty"`
+}
+
+// SyncPayload describes a library metadata scan followed by Radarr and
+// Sonarr syncs for the integrations enabled in the configuration.
+type SyncPayload struct {
+	// Path is the library directory to scan. Empty skips the metadata scan.
+	Path string `json:"path,omitempty"`
+	// Full re-parses files the file index records as unchanged.
+	Full bool `json:"full,omitempty"`
+}
+
+// TranscribePayload describes a Whisper transcription of a media file.
+type TranscribePayload struct {
+	Media    string `json:"media"`
+	Output   string `json:"output,omitempty"`
+	Language string `json:"language,omitempty"`
+	// Method selects the transcription backend. Empty uses the OpenAI API.
+	Method transcriber.TranscriptionMethod `json:"method,omitempty"`
+}
+
+// ExtractPayload describes extracting embedded subtitles from a media file.
+type ExtractPayload struct {
+	Media  string `json:"media"`
+	Output string `json:"output,omitempty"`
+}
+
+// SearchDownloadPayload describes a provider search and download for one
+// media file. Upgrade payloads use the same fields and may name a directory.
+type SearchDownloadPayload struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Provider string `json:"provider,omitempty"`
+	// Output writes the subtitle to this path instead of next to the media
+	// file, replacing any subtitle already there.
+	Output string `json:"output,omitempty"`
+}
+
+func init() {
+	queue.Register(queue.JobTypeScan, func(p ScanPayload) string {
+		return fmt.Sprintf("Scan %s for %s subtitles", p.Directory, p.Language)
+	}, runScan)
+	queue.Register(queue.JobTypeSync, func(p SyncPayload) string {
+		if p.Path == "" {
+			return "Sync Radarr and Sonarr libraries"
+		}
+		return fmt.Sprintf("Scan library %s and sync Radarr and Sonarr", p.Path)
+	}, runSync)
+	queue.Register(queue.JobTypeTranscribe, func(p TranscribePayload) string {
+		return fmt.Sprintf("Transcribe %s", p.Media)
+	}, runTranscribe)
+	queue.Register(queue.JobTypeExtract, func(p ExtractPayload) string {
+		return fmt.Sprintf("Extract subtitles from %s", p.Media)
+	}, runExtract)
+	queue.Register(queue.JobTypeSearchDownload, func(p SearchDownloadPayload) string {
+		return fmt.Sprintf("Download %s subtitles for %s", p.Language, p.Path)
+	}, runSearchDownload)
+	queue.Register(queue.JobTypeUpgrade, func(p SearchDownloadPayload) string {
+		return fmt.Sprintf("Upgrade %s subtitles for %s", p.Language, p.Path)
+	}, runUpgrade)
+}
+
+// Enqueue adds a job of type t with payload to the global queue, starting the
+// queue if it is not running, and returns the job ID.
+func Enqueue(t queue.JobType, payload any, opts queue.JobOptions) (string, error) {
+	job, err := queue.NewJob(t, payload)
+	if err != nil {
+		return "", err
+	}
+	q := queue.GetQueue()
+	if !q.IsRunning() {
+		if err := q.Start(); err != nil && !q.IsRunning() {
+			return "", err
+		}
+	}
+	return q.AddWithOptions(job, opts)
+}
+
+// Run adds a job like Enqueue with a single attempt and waits until it
+// finishes. It returns the error the job failed with. Cancelling ctx cancels
+// the job.
+func Run(ctx context.Context, t queue.JobType, payload any) error {
+	id, err := Enqueue(t, payload, queue.JobOptions{MaxAttempts: 1})
+	if err != nil {
+		return err
+	}
+	q := queue.GetQueue()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		rec, err := q.Job(id)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return fmt.Errorf("job %s not found", id)
+		}
+		switch rec.Status {
+		case queue.StatusCompleted:
+			return nil
+		case queue.StatusCancelled:
+			return fmt.Errorf("job %s cancelled", id)
+		case queue.StatusDead:
+			return errors.New(rec.LastError)
+		}
+		select {
+		case <-ctx.Done():
+			_, _ = q.Cancel(id)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// FileEvent reports a file processed by a scan, upgrade or sync job.
+type FileEvent struct {
+	JobID string
+	Type  queue.JobType
+	File  string
+}
+
+var (
+	mu          sync.RWMutex
+	sharedStore database.SubtitleStore
+	observers   []func(FileEvent)
+)
+
+// SetStore makes handlers use store instead of opening the configured store
+// for every job. Pebble allows a single open handle per process, so servers
+// that already hold the store must share it. A nil store restores the default.
+func SetStore(store database.SubtitleStore) {
+	mu.Lock()
+	defer mu.Unlock()
+	sharedStore = store
+}
+
+// ObserveFiles registers fn to be called for every file processed by scan,
+// upgrade and sync jobs.
+func ObserveFiles(fn func(FileEvent)) {
+	mu.Lock()
+	defer mu.Unlock()
+	observers = append(observers, fn)
+}
+
+// fileReporter returns a progress callback publishing FileEvents and, when
+// total is positive, the job progress percentage.
+func fileReporter(ctx context.Context, t queue.JobType, total int) func(string) {
+	id, _ := queue.JobIDFromContext(ctx)
+	mu.RLock()
+	fns := append([]func(FileEvent){}, observers...)
+	mu.RUnlock()
+	var pmu sync.Mutex
+	processed := 0
+	return func(file string) {
+		for _, fn := range fns {
+			fn(FileEvent{JobID: id, Type: t, File: file})
+		}
+		if total <= 0 {
+			return
+		}
+		pmu.Lock()
+		processed++
+		pct := processed * 100 / total
+		pmu.Unlock()
+		queue.ReportProgress(ctx, pct)
+	}
+}
+
+// openStore returns the shared store or opens the configured one. The
+// release function closes stores opened here. A nil store is returned when
+// no database is configured.
+func openStore() (database.SubtitleStore, func()) {
+	mu.RLock()
+	s := sharedStore
+	mu.RUnlock()
+	if s != nil {
+		return s, func() {}
+	}
+	dbPath := viper.GetString("db_path")
+	if dbPath == "" {
+		return nil, func() {}
+	}
+	store, err := database.OpenStore(dbPath, viper.GetString("db_backend"))
+	if err != nil {
+		logging.GetLogger("jobs").Warnf("db open: %v", err)
+		return nil, func() {}
+	}
+	return store, func() { store.Close() }
+}
+
+// provider resolves an optional provider or provider instance name.
+func provider(name string) (providers.Provider, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if inst, ok := providers.GetInstance(name); ok {
+		return providers.Get(inst.Name, "")
+	}
+	return providers.Get(name, "")
+}
+
+// countVideoFiles counts video files below dir for progress reporting.
+func countVideoFiles(dir string) (int, error) {
+	count := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && fileindex.IsVideo(path) {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func runScan(ctx context.Context, p ScanPayload) error {
+	return scanDirectory(ctx, queue.JobTypeScan, p)
+}
+
+func scanDirectory(ctx context.Context, t queue.JobType, p ScanPayload) error {
+	dir, err := security.ValidateAndSanitizePath(p.Directory)
+	if err != nil {
+		return err
+	}
+	p.Directory = dir
+	if err := security.ValidateLanguageCode(p.Language); err != nil {
+		return err
+	}
+	prov, err := provider(p.Provider)
+	if err != nil {
+		return err
+	}
+	total, err := countVideoFiles(p.Directory)
+	if err != nil {
+		return err
+	}
+	workers := p.Workers
+	if workers <= 0 {
+		workers = 2
+	}
+	store, release := openStore()
+	defer release()
+	summary, err := scanner.ScanDirectoryIndexed(ctx, p.Directory, p.Language, p.Provider, prov, p.Upgrade, p.Full, workers, store, fileReporter(ctx, t, total))
+	if err != nil {
+		return err
+	}
+	logging.GetLogger("jobs").Infof("scanned %s: %s", p.Directory, summary)
+	return nil
+}
+
+func runSync(ctx context.Context, p SyncPayload) error {
+	store, release := openStore()
+	defer release()
+	if store == nil {
+		return fmt.Errorf("database not configured")
+	}
+	if p.Path != "" {
+		path, err := security.ValidateAndSanitizePath(p.Path)
+		if err != nil {
+			return err
+		}
+		p.Path = path
+		cb := fileReporter(ctx, queue.JobTypeSync, 0)
+		summary, err := metadata.ScanLibraryIndexed(ctx, p.Path, store, p.Full, metadata.ProgressFunc(cb))
+		if err != nil {
+			return err
+		}
+		logging.GetLogger("jobs").Infof("scanned library %s: %s", p.Path, summary)
+	}
+	if viper.GetBool("integrations.radarr.enabled") {
+		if err := radarr.Sync(ctx, radarr.NewClient(integrationURL("radarr"), viper.GetString("integrations.radarr.api_key")), store); err != nil {
+			return fmt.Errorf("radarr sync: %w", err)
+		}
+	}
+	if viper.GetBool("integrations.sonarr.enabled") {
+		if err := sonarr.Sync(ctx, sonarr.NewClient(integrationURL("sonarr"), viper.GetString("integrations.sonarr.api_key")), store); err != nil {
+			return fmt.Errorf("sonarr sync: %w", err)
+		}
+	}
+	return nil
+}
+
+// integrationURL builds the base URL of the named integration from its
+// host, port, ssl and base_url settings.
+func integrationURL(name string) string {
+	prefix := "integrations." + name + "."
+	scheme := "http"
+	if viper.GetBool(prefix + "ssl") {
+		scheme = "https"
+	}
+	base := strings.Trim(viper.GetString(prefix+"base_url"), "/")
+	return fmt.Sprintf("%s://%s:%s/%s", scheme, viper.GetString(prefix+"host"), viper.GetString(prefix+"port"), base)
+}
+
+// outputPath validates out, or the SRT path next to media for lang when out
+// is empty, against the allowed media directories.
+func outputPath(media, out, lang string) (string, error) {
+	if out == "" {
+		suffix := ".srt"
+		if lang != "" {
+			suffix = "." + lang + ".srt"
+		}
+		out = strings.TrimSuffix(media, filepath.Ext(media)) + suffix
+	}
+	return security.ValidateAndSanitizePath(out)
+}
+
+func runTranscribe(ctx context.Context, p TranscribePayload) error {
+	media, err := security.ValidateAndSanitizePath(p.Media)
+	if err != nil {
+		return err
+	}
+	if p.Language != "" {
+		if err := security.ValidateLanguageCode(p.Language); err != nil {
+			return err
+		}
+	}
+	out, err := outputPath(media, p.Output, p.Language)
+	if err != nil {
+		return err
+	}
+	method := p.Method
+	if method == "" {
+		method = transcriber.MethodOpenAI
+	}
+	return Transcribe(ctx, method, media, out, p.Language)
+}
+
+// Transcribe writes a Whisper transcription of media made with method to out
+// and records it in the subtitle history. Paths are used as given; the queued
+// transcribe job validates them against the allowed media directories first.
+func Transcribe(ctx context.Context, method transcriber.TranscriptionMethod, media, out, lang string) error {
+	data, err := transcriber.TranscribeWithMethod(ctx, method, media, lang, viper.GetString("openai_api_key"), nil)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+	recordSubtitle(&database.SubtitleRecord{File: out, VideoFile: media, Language: lang, Service: "whisper"})
+	return nil
+}
+
+func runExtract(ctx context.Context, p ExtractPayload) error {
+	media, err := security.ValidateAndSanitizePath(p.Media)
+	if err != nil {
+		return err
+	}
+	out, err := outputPath(media, p.Output, "")
+	if err != nil {
+		return err
+	}
+	return Extract(ctx, media, out)
+}
+
+// Extract writes the embedded subtitles of media to out as SRT and records
+// them in the subtitle history. Paths are used as given; the queued extract
+// job validates them against the allowed media directories first.
+func Extract(ctx context.Context, media, out string) error {
+	if ff := viper.GetString("ffmpeg_path"); ff != "" {
+		subtitles.SetFFmpegPath(ff)
+	}
+	items, err := subtitles.ExtractFromMedia(media)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sub := astisub.NewSubtitles()
+	sub.Items = items
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := sub.WriteToSRT(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	recordSubtitle(&database.SubtitleRecord{File: out, VideoFile: media, Service: "extract", Embedded: true})
+	return nil
+}
+
+// recordSubtitle stores rec in the subtitle history when a database is configured.
+func recordSubtitle(rec *database.SubtitleRecord) {
+	store, release := openStore()
+	defer release()
+	if store == nil {
+		return
+	}
+	if err := store.InsertSubtitle(rec); err != nil {
+		logging.GetLogger("jobs").Warnf("record subtitle %s: %v", rec.File, err)
+	}
+}
+
+func runSearchDownload(ctx context.Context, p SearchDownloadPayload) error {
+	media, err := security.ValidateAndSanitizePath(p.Path)
+	if err != nil {
+		return err
+	}
+	p.Path = media
+	if p.Output != "" {
+		return downloadTo(ctx, p)
+	}
+	prov, err := provider(p.Provider)
+	if err != nil {
+		return err
+	}
+	store, release := openStore()
+	defer release()
+	return scanner.ProcessFile(ctx, p.Path, p.Language, p.Provider, prov, false, store)
+}
+
+// downloadTo validates p.Language and p.Output and downloads a subtitle for
+// the validated p.Path to p.Output.
+func downloadTo(ctx context.Context, p SearchDownloadPayload) error {
+	if err := security.ValidateLanguageCode(p.Language); err != nil {
+		return err
+	}
+	out, err := outputPath(p.Path, p.Output, p.Language)
+	if err != nil {
+		return err
+	}
+	return Download(ctx, p.Path, p.Language, p.Provider, out)
+}
+
+// Download fetches a subtitle for media from the named provider, or from
+// every provider when name is empty, unpacks archives and writes it to out as
+// UTF-8. The download is recorded with its score. Paths are used as given;
+// the queued search_download job validates them against the allowed media
+// directories first.
+func Download(ctx context.Context, media, lang, name, out string) error {
+	prov, err := provider(name)
+	if err != nil {
+		return err
+	}
+	fetched, err := scanner.FetchScored(ctx, media, lang, viper.GetString("opensubtitles.api_key"), name, prov)
+	if err != nil {
+		return err
+	}
+	data, enc := scanner.NormalizeEncoding(fetched.Data, lang)
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+	store, release := openStore()
+	defer release()
+	if store != nil {
+		score := scanner.NormalizedScore(fetched.Score)
+		if err := store.InsertDownload(&database.DownloadRecord{File: out, VideoFile: media, Provider: fetched.Provider, Language: lang, MatchScore: &score}); err != nil {
+			logging.GetLogger("jobs").Warnf("record download %s: %v", out, err)
+		}
+		scanner.RecordSubtitle(store, out, media, lang, fetched.Provider, enc)
+	}
+	return nil
+}
+
+// runUpgrade re-checks the subtitles of a file or, when Path is a directory,
+// of every video below it, replacing them when a better match is found.
+func runUpgrade(ctx context.Context, p SearchDownloadPayload) error {
+	path, err := security.ValidateAndSanitizePath(p.Path)
+	if err != nil {
+		return err
+	}
+	p.Path = path
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return scanDirectory(ctx, queue.JobTypeUpgrade, ScanPayload{Directory: p.Path, Language: p.Language, Provider: p.Provider, Upgrade: true})
+	}
+	prov, err := provider(p.Provider)
+	if err != nil {
+		return err
+	}
+	store, release := openStore()
+	defer release()
+	if err := scanner.ProcessFile(ctx, p.Path, p.Language, p.Provider, prov, true, store); err != nil {
+		return err
+	}
+	fileReporter(ctx, queue.JobTypeUpgrade, 1)(p.Path)
+	return nil
+}
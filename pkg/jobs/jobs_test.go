@@ -0,0 +1,239 @@
+// file: pkg/jobs/jobs_test.go
+// version: 1.5.0
+// guid: 7b2f9d41-5e6a-4c38-8f1b-2a9d0e7c3b65
+
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/internal/queuetest"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/scanner"
+	"github.com/jdfalk/subtitle-manager/pkg/transcriber"
+)
+
+type staticProvider struct{}
+
+func (staticProvider) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	return []byte("1\n00:00:01,000 --> 00:00:02,000\nhello\n"), nil
+}
+
+// zipProvider serves its subtitle packed in a ZIP archive.
+type zipProvider struct{}
+
+func (zipProvider) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("movie.en.srt")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte("1\n00:00:01,000 --> 00:00:02,000\nzipped\n")); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// startQueue installs a fresh global queue for the test.
+func startQueue(t *testing.T) *queue.Queue {
+	t.Helper()
+	q := queue.NewQueue(1)
+	require.NoError(t, q.Start())
+	queue.SetQueue(q)
+	t.Cleanup(func() { _ = q.Stop() })
+	return q
+}
+
+func TestRegisteredTypes(t *testing.T) {
+	types := queue.RegisteredTypes()
+	for _, jt := range []queue.JobType{
+		queue.JobTypeScan, queue.JobTypeSync, queue.JobTypeTranscribe,
+		queue.JobTypeExtract, queue.JobTypeSearchDownload, queue.JobTypeUpgrade,
+	} {
+		assert.Contains(t, types, jt)
+	}
+}
+
+func TestScanJob(t *testing.T) {
+	providers.RegisterFactory("jobstest", func() providers.Provider { return staticProvider{} })
+	viper.Set("db_path", "")
+	defer viper.Set("db_path", nil)
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.mkv", "b.mp4", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644))
+	}
+
+	var mu sync.Mutex
+	var files []string
+	ObserveFiles(func(ev FileEvent) {
+		if ev.Type != queue.JobTypeScan {
+			return
+		}
+		mu.Lock()
+		files = append(files, filepath.Base(ev.File))
+		mu.Unlock()
+	})
+
+	q := startQueue(t)
+	id, err := Enqueue(queue.JobTypeScan, ScanPayload{Directory: dir, Language: "en", Provider: "jobstest"}, queue.JobOptions{MaxAttempts: 1})
+	require.NoError(t, err)
+	queuetest.WaitForStatus(t, q, id, queue.StatusCompleted)
+
+	mu.Lock()
+	sort.Strings(files)
+	assert.Equal(t, []string{"a.mkv", "b.mp4"}, files)
+	mu.Unlock()
+	assert.FileExists(t, filepath.Join(dir, "a.en.srt"))
+	assert.FileExists(t, filepath.Join(dir, "b.en.srt"))
+}
+
+func TestTranscribeJob(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "1\n00:00:00,000 --> 00:00:01,000\ntext\n")
+	}))
+	defer srv.Close()
+	transcriber.SetBaseURL(srv.URL + "/v1")
+	defer transcriber.SetBaseURL("https://api.openai.com/v1")
+	viper.Set("openai_api_key", "k")
+	defer viper.Set("openai_api_key", nil)
+	viper.Set("db_path", "")
+	defer viper.Set("db_path", nil)
+
+	dir := t.TempDir()
+	media := filepath.Join(dir, "movie.wav")
+	require.NoError(t, os.WriteFile(media, []byte("data"), 0644))
+
+	q := startQueue(t)
+	id, err := Enqueue(queue.JobTypeTranscribe, TranscribePayload{Media: media, Language: "en"}, queue.JobOptions{})
+	require.NoError(t, err)
+	queuetest.WaitForStatus(t, q, id, queue.StatusCompleted)
+
+	data, err := os.ReadFile(filepath.Join(dir, "movie.en.srt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "text")
+}
+
+// TestTranscribeJobUsesPayloadMethod verifies the queued job transcribes with
+// the method carried in its payload.
+func TestTranscribeJobUsesPayloadMethod(t *testing.T) {
+	media := filepath.Join(t.TempDir(), "movie.wav")
+	require.NoError(t, os.WriteFile(media, []byte("data"), 0644))
+
+	q := startQueue(t)
+	id, err := Enqueue(queue.JobTypeTranscribe, TranscribePayload{Media: media, Language: "en", Method: "bogus"}, queue.JobOptions{MaxAttempts: 1})
+	require.NoError(t, err)
+	rec := queuetest.WaitForStatus(t, q, id, queue.StatusDead)
+	assert.Contains(t, rec.LastError, "unsupported transcription method: bogus")
+}
+
+func TestFailingJobIsBuried(t *testing.T) {
+	q := startQueue(t)
+	id, err := Enqueue(queue.JobTypeSearchDownload, SearchDownloadPayload{Path: "/missing/movie.mkv", Language: "en", Provider: "unknown-provider"}, queue.JobOptions{MaxAttempts: 1})
+	require.NoError(t, err)
+	rec := queuetest.WaitForStatus(t, q, id, queue.StatusDead)
+	assert.NotEmpty(t, rec.LastError)
+}
+
+// TestJobsRejectDisallowedPaths verifies jobs refuse paths outside the
+// allowed media directories before touching the filesystem.
+func TestJobsRejectDisallowedPaths(t *testing.T) {
+	media := filepath.Join(t.TempDir(), "movie.mkv")
+	require.NoError(t, os.WriteFile(media, []byte("x"), 0644))
+	q := startQueue(t)
+	for _, tc := range []struct {
+		typ     queue.JobType
+		payload any
+	}{
+		{queue.JobTypeScan, ScanPayload{Directory: "/etc", Language: "en", Provider: "jobstest"}},
+		{queue.JobTypeSearchDownload, SearchDownloadPayload{Path: "/etc/passwd", Language: "en", Provider: "jobstest"}},
+		{queue.JobTypeSearchDownload, SearchDownloadPayload{Path: "/etc/passwd", Language: "en", Output: "/etc/passwd.srt"}},
+		{queue.JobTypeUpgrade, SearchDownloadPayload{Path: "/etc", Language: "en", Provider: "jobstest"}},
+		{queue.JobTypeScan, ScanPayload{Directory: "relative/dir", Language: "en", Provider: "jobstest"}},
+		{queue.JobTypeSearchDownload, SearchDownloadPayload{Path: media, Language: "en", Provider: "jobstest", Output: "/etc/cron.d/movie.srt"}},
+		{queue.JobTypeTranscribe, TranscribePayload{Media: media, Language: "en", Output: "/etc/cron.d/movie.srt"}},
+		{queue.JobTypeExtract, ExtractPayload{Media: media, Output: "/etc/cron.d/movie.srt"}},
+	} {
+		id, err := Enqueue(tc.typ, tc.payload, queue.JobOptions{MaxAttempts: 1})
+		require.NoError(t, err)
+		rec := queuetest.WaitForStatus(t, q, id, queue.StatusDead)
+		assert.Regexp(t, "not in allowed directories|must be absolute", rec.LastError, "%s %+v", tc.typ, tc.payload)
+	}
+}
+
+// TestScanJobRejectsInvalidLanguage verifies scans validate the language
+// before searching providers.
+func TestScanJobRejectsInvalidLanguage(t *testing.T) {
+	q := startQueue(t)
+	id, err := Enqueue(queue.JobTypeScan, ScanPayload{Directory: t.TempDir(), Language: "../en", Provider: "jobstest"}, queue.JobOptions{MaxAttempts: 1})
+	require.NoError(t, err)
+	rec := queuetest.WaitForStatus(t, q, id, queue.StatusDead)
+	assert.Contains(t, rec.LastError, "invalid character in language code")
+}
+
+// TestDownloadUnpacksArchives verifies subtitles from a named provider are
+// unpacked before they are written.
+func TestDownloadUnpacksArchives(t *testing.T) {
+	providers.RegisterFactory("jobszip", func() providers.Provider { return zipProvider{} })
+	viper.Set("db_path", "")
+	defer viper.Set("db_path", nil)
+
+	dir := t.TempDir()
+	media := filepath.Join(dir, "movie.mkv")
+	require.NoError(t, os.WriteFile(media, []byte("x"), 0644))
+	out := filepath.Join(dir, "out.srt")
+
+	q := startQueue(t)
+	id, err := Enqueue(queue.JobTypeSearchDownload, SearchDownloadPayload{Path: media, Language: "en", Provider: "jobszip", Output: out}, queue.JobOptions{MaxAttempts: 1})
+	require.NoError(t, err)
+	queuetest.WaitForStatus(t, q, id, queue.StatusCompleted)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "zipped")
+}
+
+// TestDownloadRecordsScore verifies downloads are recorded with their score.
+func TestDownloadRecordsScore(t *testing.T) {
+	providers.RegisterFactory("jobszip", func() providers.Provider { return zipProvider{} })
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+	SetStore(store)
+	defer SetStore(nil)
+
+	dir := t.TempDir()
+	media := filepath.Join(dir, "movie.mkv")
+	require.NoError(t, os.WriteFile(media, []byte("x"), 0644))
+	out := filepath.Join(dir, "movie.en.srt")
+	require.NoError(t, Download(context.Background(), media, "en", "jobszip", out))
+
+	recs, err := store.ListDownloadsByVideo(media)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	score, ok := recs[0].Score()
+	require.True(t, ok, "download recorded without a score")
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	assert.Equal(t, scanner.FileScore("jobszip", "movie.en.srt", "movie.en.srt", info.Size(), media), score)
+}
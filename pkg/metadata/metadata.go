@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
 	"github.com/jdfalk/subtitle-manager/pkg/scoring"
 	"github.com/jdfalk/subtitle-manager/pkg/security"
 )
@@ -33,12 +34,6 @@ func SetTMDBAPIBase(u string) { tmdbAPIBase = u }
 // SetOMDBAPIBase overrides the default OMDb API base URL. Primarily used for testing.
 func SetOMDBAPIBase(u string) { omdbAPIBase = u }
 
-// Supported video file extensions used for library scanning.
-var videoExts = map[string]bool{
-	".mp4": true, ".mkv": true, ".avi": true, ".mov": true,
-	".wmv": true, ".flv": true, ".webm": true, ".m4v": true,
-}
-
 // FetchMovieMetadataFunc allows tests to override FetchMovieMetadata.
 var FetchMovieMetadataFunc = FetchMovieMetadata
 
@@ -478,17 +473,37 @@ func fetchOMDBInfo(ctx context.Context, q url.Values) ([]string, float64, error)
 
 // ScanLibrary walks a directory tree and inserts video files into the media database.
 // It parses filenames to extract metadata and stores the results using the provided store.
+// Files already in the media database that are unchanged since the previous
+// scan are skipped.
 func ScanLibrary(ctx context.Context, dir string, store database.SubtitleStore) error {
-	return scanLibrary(ctx, dir, store, nil)
+	_, err := scanLibrary(ctx, dir, store, false, nil)
+	return err
 }
 
-func scanLibrary(ctx context.Context, dir string, store database.SubtitleStore, cb ProgressFunc) error {
+// libraryKey is the file index result key of library scans.
+const libraryKey = "library"
+
+func scanLibrary(ctx context.Context, dir string, store database.SubtitleStore, full bool, cb ProgressFunc) (fileindex.Summary, error) {
 	sanitizedDir, err := security.ValidateAndSanitizePath(dir)
 	if err != nil {
-		return err
+		return fileindex.Summary{}, err
+	}
+	index, err := fileindex.Open(store, sanitizedDir, full)
+	if err != nil {
+		return fileindex.Summary{}, err
+	}
+	// Unchanged files are skipped only while their media item still exists.
+	indexed := func(path string) func(string) bool {
+		return func(result string) bool {
+			if result != "indexed" {
+				return false
+			}
+			item, err := store.GetMediaItem(path)
+			return err == nil && item != nil
+		}
 	}
 
-	return filepath.Walk(sanitizedDir, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(sanitizedDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -498,8 +513,15 @@ func scanLibrary(ctx context.Context, dir string, store database.SubtitleStore,
 			return nil
 		}
 
-		ext := strings.ToLower(filepath.Ext(path))
-		if !videoExts[ext] {
+		if !fileindex.IsVideo(path) {
+			return nil
+		}
+
+		entry, evaluate := index.Check(path, info, libraryKey, indexed(path))
+		if !evaluate {
+			if cb != nil {
+				cb(path)
+			}
 			return nil
 		}
 
@@ -528,6 +550,9 @@ func scanLibrary(ctx context.Context, dir string, store database.SubtitleStore,
 		if err := store.InsertMediaItem(item); err != nil {
 			return err
 		}
+		if err := index.Record(entry, libraryKey, "indexed", false); err != nil {
+			return err
+		}
 
 		if cb != nil {
 			cb(path)
@@ -535,6 +560,10 @@ func scanLibrary(ctx context.Context, dir string, store database.SubtitleStore,
 
 		return nil
 	})
+	if err != nil {
+		return index.Summary(), err
+	}
+	return index.Finish()
 }
 
 // ProgressFunc is called with each processed video file path during scanning.
@@ -542,5 +571,13 @@ type ProgressFunc func(file string)
 
 // ScanLibraryProgress performs ScanLibrary and invokes cb for each processed file.
 func ScanLibraryProgress(ctx context.Context, dir string, store database.SubtitleStore, cb ProgressFunc) error {
-	return scanLibrary(ctx, dir, store, cb)
+	_, err := scanLibrary(ctx, dir, store, false, cb)
+	return err
+}
+
+// ScanLibraryIndexed performs ScanLibraryProgress and returns how many files
+// were new, changed, removed or skipped since the previous scan. With full
+// set every file is parsed and stored again.
+func ScanLibraryIndexed(ctx context.Context, dir string, store database.SubtitleStore, full bool, cb ProgressFunc) (fileindex.Summary, error) {
+	return scanLibrary(ctx, dir, store, full, cb)
 }
@@ -0,0 +1,232 @@
+// file: pkg/monitoring/cycle.go
+// version: 1.0.0
+// guid: ca800624-8193-48d5-8c24-512aefad740b
+
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sourcegraph/conc/pool"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+)
+
+// DefaultWorkers is the number of items processed concurrently during a
+// monitoring cycle unless SetWorkers is called.
+const DefaultWorkers = 4
+
+// cycleState records the order of a monitoring cycle so a cycle interrupted
+// by a restart resumes with the items it had not reached yet.
+type cycleState struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Order    []string  `json:"order"`
+}
+
+// SetWorkers sets the number of items processed concurrently. Values below
+// one process a single item at a time.
+func (m *EpisodeMonitor) SetWorkers(n int) {
+	m.workers = max(n, 1)
+}
+
+// SetProviderLimits sets the concurrency and rate limits applied to every
+// provider, with overrides keyed by provider name.
+func (m *EpisodeMonitor) SetProviderLimits(defaults ProviderLimits, overrides map[string]ProviderLimits) {
+	m.gates = newProviderGates(defaults, overrides)
+}
+
+// SetStateFile sets the file the progress of the current cycle is kept in.
+// Without a state file an interrupted cycle starts over in priority order.
+func (m *EpisodeMonitor) SetStateFile(path string) {
+	m.cycleMu.Lock()
+	defer m.cycleMu.Unlock()
+	m.stateFile = path
+	m.cycle = nil
+}
+
+// checkForSubtitles performs a single monitoring cycle. Items are processed
+// by a bounded worker pool, newest first, and an interrupted cycle resumes
+// with the items it had not reached.
+func (m *EpisodeMonitor) checkForSubtitles(ctx context.Context) error {
+	// Get monitored items that need checking
+	items, err := m.getItemsToCheck()
+	if err != nil {
+		return err
+	}
+	items = m.startCycle(items)
+
+	m.logger.Debugf("Checking %d monitored items with %d workers", len(items), m.workers)
+
+	work := pool.New().WithMaxGoroutines(m.workers)
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		work.Go(func() {
+			if err := m.processItem(ctx, item); err != nil && ctx.Err() == nil {
+				m.logger.Warnf("Failed to process item %s: %v", item.Path, err)
+			}
+		})
+	}
+	work.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.finishCycle()
+	return nil
+}
+
+// startCycle orders items for a new cycle and records the order. When the
+// previous cycle was interrupted, its unprocessed items come first in their
+// original order.
+func (m *EpisodeMonitor) startCycle(items []*MonitoredItem) []*MonitoredItem {
+	m.cycleMu.Lock()
+	defer m.cycleMu.Unlock()
+
+	prioritize(items)
+	prev := m.loadCycle()
+	started := time.Now()
+	if prev != nil && prev.Finished.IsZero() {
+		pos := make(map[string]int, len(prev.Order))
+		for i, id := range prev.Order {
+			pos[id] = i
+		}
+		var resumed, fresh []*MonitoredItem
+		for _, item := range items {
+			if _, ok := pos[item.ID]; ok && item.LastChecked.Before(prev.Started) {
+				resumed = append(resumed, item)
+			} else {
+				fresh = append(fresh, item)
+			}
+		}
+		if len(resumed) > 0 {
+			sort.SliceStable(resumed, func(i, j int) bool { return pos[resumed[i].ID] < pos[resumed[j].ID] })
+			m.logger.Infof("Resuming monitoring cycle from %s with %d of %d items left", prev.Started.Format(time.RFC3339), len(resumed), len(prev.Order))
+			items = append(resumed, fresh...)
+			started = prev.Started
+		}
+	}
+
+	state := &cycleState{Started: started, Order: make([]string, 0, len(items))}
+	for _, item := range items {
+		state.Order = append(state.Order, item.ID)
+	}
+	m.cycle = state
+	m.saveCycle()
+	return items
+}
+
+// finishCycle marks the current cycle as complete.
+func (m *EpisodeMonitor) finishCycle() {
+	m.cycleMu.Lock()
+	defer m.cycleMu.Unlock()
+	if m.cycle == nil {
+		return
+	}
+	m.cycle.Finished = time.Now()
+	m.saveCycle()
+}
+
+// loadCycle returns the state of the current or last cycle, reading the
+// state file when no cycle ran in this process. The caller holds cycleMu.
+func (m *EpisodeMonitor) loadCycle() *cycleState {
+	if m.cycle != nil || m.stateFile == "" {
+		return m.cycle
+	}
+	data, err := os.ReadFile(m.stateFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			m.logger.Warnf("Failed to read monitoring state %s: %v", m.stateFile, err)
+		}
+		return nil
+	}
+	var state cycleState
+	if err := json.Unmarshal(data, &state); err != nil {
+		m.logger.Warnf("Ignoring invalid monitoring state %s: %v", m.stateFile, err)
+		return nil
+	}
+	m.cycle = &state
+	return m.cycle
+}
+
+// saveCycle writes the current cycle to the state file. The caller holds
+// cycleMu.
+func (m *EpisodeMonitor) saveCycle() {
+	if m.stateFile == "" || m.cycle == nil {
+		return
+	}
+	data, err := json.Marshal(m.cycle)
+	if err == nil {
+		tmp := m.stateFile + ".tmp"
+		if err = os.MkdirAll(filepath.Dir(m.stateFile), 0755); err == nil {
+			if err = os.WriteFile(tmp, data, 0644); err == nil {
+				err = os.Rename(tmp, m.stateFile)
+			}
+		}
+	}
+	if err != nil {
+		m.logger.Warnf("Failed to save monitoring state %s: %v", m.stateFile, err)
+	}
+}
+
+// prioritize orders items newest first. Recency is the modification time of
+// the media file, which follows the air or release date for freshly
+// imported media, or when the item was added to monitoring if the file
+// cannot be read.
+func prioritize(items []*MonitoredItem) {
+	recency := make(map[*MonitoredItem]time.Time, len(items))
+	for _, item := range items {
+		t := item.CreatedAt
+		if fi, err := os.Stat(item.Path); err == nil {
+			t = fi.ModTime()
+		}
+		recency[item] = t
+	}
+	sort.SliceStable(items, func(i, j int) bool { return recency[items[i]].After(recency[items[j]]) })
+}
+
+// cycleStats fills the cycle progress of stats from items, the current
+// records of every monitored item.
+func (m *EpisodeMonitor) cycleStats(stats *MonitoringStats, items []database.MonitoredItem) {
+	m.cycleMu.Lock()
+	state := m.loadCycle()
+	m.cycleMu.Unlock()
+	if state == nil {
+		return
+	}
+
+	inCycle := make(map[string]bool, len(state.Order))
+	for _, id := range state.Order {
+		inCycle[id] = true
+	}
+	processed := 0
+	for _, item := range items {
+		if inCycle[item.ID] && !item.LastChecked.Before(state.Started) {
+			processed++
+		}
+	}
+
+	end := time.Now()
+	if !state.Finished.IsZero() {
+		end = state.Finished
+		processed = len(state.Order)
+	}
+	stats.CycleStarted = state.Started
+	stats.CycleComplete = !state.Finished.IsZero()
+	stats.CycleTotal = len(state.Order)
+	stats.CycleProcessed = processed
+	if elapsed := end.Sub(state.Started); elapsed > 0 && processed > 0 {
+		stats.Throughput = float64(processed) / elapsed.Minutes()
+		if remaining := stats.CycleTotal - processed; remaining > 0 && !stats.CycleComplete {
+			stats.ETA = time.Duration(float64(remaining) / stats.Throughput * float64(time.Minute)).Round(time.Second)
+		}
+	}
+}
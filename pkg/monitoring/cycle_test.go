@@ -0,0 +1,202 @@
+// file: pkg/monitoring/cycle_test.go
+// version: 1.0.0
+// guid: cf9b8a27-5c08-4e06-874b-21b3c427f7e5
+
+package monitoring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+)
+
+// blockingProvider records how many searches run at the same time.
+type blockingProvider struct {
+	candidateProvider
+	running, peak atomic.Int32
+	delay         time.Duration
+}
+
+func (b *blockingProvider) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]providers.Candidate, error) {
+	n := b.running.Add(1)
+	defer b.running.Add(-1)
+	for {
+		p := b.peak.Load()
+		if n <= p || b.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(b.delay)
+	return nil, nil
+}
+
+func TestProviderGatesLimitConcurrencyAndRate(t *testing.T) {
+	gates := newProviderGates(ProviderLimits{Concurrency: 1}, map[string]ProviderLimits{
+		"fast": {Concurrency: 3, Rate: 20, Burst: 1},
+	})
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := gates.call(context.Background(), "slow", func(ctx context.Context) error {
+				if n := running.Add(1); n > peak.Load() {
+					peak.Store(n)
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), peak.Load())
+
+	// Three requests at 20/s with a burst of one take at least 100ms.
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, gates.call(context.Background(), "fast", func(ctx context.Context) error { return nil }))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, gates.call(ctx, "fast", func(ctx context.Context) error { return nil }))
+}
+
+func TestPrioritizeNewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	var items []*MonitoredItem
+	for i, name := range []string{"old.mkv", "new.mkv", "mid.mkv"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+		age := map[string]time.Duration{"old.mkv": 72 * time.Hour, "new.mkv": time.Hour, "mid.mkv": 24 * time.Hour}[name]
+		require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		items = append(items, &MonitoredItem{ID: string(rune('a' + i)), Path: path})
+	}
+	// Missing files fall back to when the item was added.
+	items = append(items, &MonitoredItem{ID: "d", Path: filepath.Join(dir, "gone.mkv"), CreatedAt: now.Add(-48 * time.Hour)})
+
+	prioritize(items)
+
+	var order []string
+	for _, item := range items {
+		order = append(order, filepath.Base(item.Path))
+	}
+	assert.Equal(t, []string{"new.mkv", "mid.mkv", "gone.mkv", "old.mkv"}, order)
+}
+
+func TestStartCycleResumesInterruptedCycle(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state", "monitor_state.json")
+	started := time.Now().Add(-time.Hour)
+
+	first := NewEpisodeMonitor(time.Hour, nil, nil, &MockSubtitleStore{}, 3, false)
+	first.SetStateFile(stateFile)
+	first.cycle = &cycleState{Started: started, Order: []string{"c", "a", "b"}}
+	first.saveCycle()
+
+	// A restarted monitor continues with a and b, which were not reached,
+	// before the newly due item d. Item c was processed before the restart.
+	m := NewEpisodeMonitor(time.Hour, nil, nil, &MockSubtitleStore{}, 3, false)
+	m.SetStateFile(stateFile)
+	items := []*MonitoredItem{
+		{ID: "d", Path: "/missing/d.mkv", CreatedAt: time.Now()},
+		{ID: "b", Path: "/missing/b.mkv"},
+		{ID: "a", Path: "/missing/a.mkv"},
+	}
+	items = m.startCycle(items)
+
+	var order []string
+	for _, item := range items {
+		order = append(order, item.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "d"}, order)
+	assert.True(t, m.cycle.Started.Equal(started))
+
+	m.finishCycle()
+	// A finished cycle starts over in priority order.
+	next := NewEpisodeMonitor(time.Hour, nil, nil, &MockSubtitleStore{}, 3, false)
+	next.SetStateFile(stateFile)
+	items = next.startCycle([]*MonitoredItem{{ID: "a"}, {ID: "d", CreatedAt: time.Now()}})
+	assert.Equal(t, "d", items[0].ID)
+	assert.True(t, next.cycle.Started.After(started))
+}
+
+func TestGetMonitoringStatsReportsCycleProgress(t *testing.T) {
+	store := &MockSubtitleStore{}
+	started := time.Now().Add(-10 * time.Minute)
+	store.On("ListMonitoredItems").Return([]database.MonitoredItem{
+		{ID: "1", Status: "found", LastChecked: started.Add(time.Minute)},
+		{ID: "2", Status: "monitoring", LastChecked: started.Add(2 * time.Minute)},
+		{ID: "3", Status: "pending"},
+		{ID: "4", Status: "pending"},
+	}, nil)
+
+	m := NewEpisodeMonitor(time.Hour, nil, nil, store, 3, false)
+	m.cycle = &cycleState{Started: started, Order: []string{"1", "2", "3", "4"}}
+
+	stats, err := m.GetMonitoringStats()
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.CycleTotal)
+	assert.Equal(t, 2, stats.CycleProcessed)
+	assert.False(t, stats.CycleComplete)
+	assert.InDelta(t, 0.2, stats.Throughput, 0.01)
+	assert.InDelta(t, (10 * time.Minute).Seconds(), stats.ETA.Seconds(), 5)
+}
+
+func TestCheckForSubtitlesProcessesItemsConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	p := &blockingProvider{delay: 50 * time.Millisecond}
+	providers.RegisterFactory("monitorblock", func() providers.Provider { return p })
+	inst := providers.Instance{ID: "monitorblock", Name: "monitorblock", Enabled: true}
+	providers.RegisterInstance(inst)
+	t.Cleanup(func() {
+		inst.Enabled = false
+		providers.RegisterInstance(inst)
+	})
+
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	for _, name := range []string{"a.mkv", "b.mkv", "c.mkv", "d.mkv"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+		require.NoError(t, store.InsertMonitoredItem(&database.MonitoredItem{
+			Path: path, Languages: `["en"]`, Status: "pending", MaxRetries: 3,
+		}))
+	}
+
+	m := NewEpisodeMonitor(time.Hour, nil, nil, store, 3, false)
+	m.SetWorkers(4)
+	m.SetProviderLimits(ProviderLimits{Concurrency: 2}, nil)
+	m.SetStateFile(filepath.Join(dir, "monitor_state.json"))
+
+	require.NoError(t, m.checkForSubtitles(context.Background()))
+	assert.Equal(t, int32(2), p.peak.Load(), "provider concurrency limit")
+
+	stats, err := m.GetMonitoringStats()
+	require.NoError(t, err)
+	assert.True(t, stats.CycleComplete)
+	assert.Equal(t, 4, stats.CycleProcessed)
+	assert.FileExists(t, filepath.Join(dir, "monitor_state.json"))
+
+	items, err := store.ListMonitoredItems()
+	require.NoError(t, err)
+	for _, item := range items {
+		assert.Equal(t, 1, item.RetryCount, item.Path)
+	}
+}
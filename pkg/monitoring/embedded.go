@@ -0,0 +1,73 @@
+// file: pkg/monitoring/embedded.go
+// version: 1.0.0
+// guid: 0b7f4c93-6e2a-4d15-9a8c-3f5e1d7b2c60
+
+package monitoring
+
+import (
+	"os"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/embedded"
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	"github.com/jdfalk/subtitle-manager/pkg/video"
+)
+
+// embeddedTracks returns a function probing the subtitle tracks embedded in
+// item's video on first use, so items whose wanted subtitles are all
+// installed are never probed.
+func (m *EpisodeMonitor) embeddedTracks(item *MonitoredItem) func() []video.SubtitleTrack {
+	var tracks []video.SubtitleTrack
+	probed := false
+	return func() []video.SubtitleTrack {
+		if !probed {
+			probed = true
+			var err error
+			if tracks, err = embedded.Tracks(item.Path); err != nil {
+				m.logger.Debugf("Probe embedded subtitles of %s: %v", item.Path, err)
+			}
+		}
+		return tracks
+	}
+}
+
+// embeddedSubtitle reports whether a track embedded in item's video provides
+// want. With embedded.extract enabled a text track is extracted next to the
+// video and recorded in the subtitle history, unless extract is false or the
+// subtitle file already exists.
+func (m *EpisodeMonitor) embeddedSubtitle(item *MonitoredItem, want wantedSubtitle, tracks []video.SubtitleTrack, extract bool) bool {
+	sidecar := ""
+	if extract {
+		sidecar = want.path(item.Path, subtitles.FormatSRT)
+		if _, err := os.Stat(sidecar); err == nil {
+			sidecar = ""
+		}
+	}
+	match, err := embedded.Satisfy(item.Path, tracks, want.Language, want.Forced, want.HI, sidecar)
+	if err != nil {
+		m.logger.Warnf("Embedded %s subtitle of %s: %v", want, item.Path, err)
+		return false
+	}
+	if match == nil {
+		return false
+	}
+	if match.Sidecar == "" {
+		m.logger.Debugf("Subtitle %s for %s provided by embedded track %d", want, item.Path, match.Track.Index)
+		return true
+	}
+	m.logger.Infof("Extracted embedded %s subtitle %s", want, match.Sidecar)
+	rec, err := database.CreateSubtitleRecord(match.Sidecar, item.Path, want.Language, "embedded", &database.ProviderMetadata{
+		Encoding:   "utf-8",
+		Format:     string(subtitles.FormatSRT),
+		Language:   want.Language,
+		SourceName: "embedded",
+	})
+	if err == nil {
+		rec.Embedded = true
+		err = m.store.InsertSubtitle(rec)
+	}
+	if err != nil {
+		m.logger.Warnf("Failed to record extracted subtitle %s: %v", match.Sidecar, err)
+	}
+	return true
+}
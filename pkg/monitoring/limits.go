@@ -0,0 +1,104 @@
+// file: pkg/monitoring/limits.go
+// version: 1.0.0
+// guid: b8563fc7-2436-4221-b86d-2ee885533ef9
+
+package monitoring
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// providerTimeout bounds a single search, download or fetch request.
+const providerTimeout = 15 * time.Second
+
+// ProviderLimits bounds the requests the monitor sends to one provider.
+type ProviderLimits struct {
+	// Concurrency is the number of simultaneous requests. Values below one
+	// allow a single request at a time.
+	Concurrency int `json:"concurrency"`
+	// Rate is the sustained number of requests per second. Zero disables
+	// rate limiting.
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests allowed above Rate at once. Values
+	// below one allow a single request.
+	Burst int `json:"burst"`
+}
+
+// DefaultProviderLimits allows two simultaneous requests and one request
+// per second to each provider.
+var DefaultProviderLimits = ProviderLimits{Concurrency: 2, Rate: 1, Burst: 2}
+
+// providerGate enforces the limits of one provider.
+type providerGate struct {
+	slots   chan struct{}
+	limiter *rate.Limiter
+}
+
+// providerGates hands out per-provider gates shared by all workers.
+type providerGates struct {
+	mu        sync.Mutex
+	defaults  ProviderLimits
+	overrides map[string]ProviderLimits
+	gates     map[string]*providerGate
+}
+
+// newProviderGates returns gates using defaults for every provider not
+// listed in overrides.
+func newProviderGates(defaults ProviderLimits, overrides map[string]ProviderLimits) *providerGates {
+	return &providerGates{defaults: defaults, overrides: overrides, gates: make(map[string]*providerGate)}
+}
+
+// gate returns the gate of provider name, creating it on first use.
+func (g *providerGates) gate(name string) *providerGate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if pg, ok := g.gates[name]; ok {
+		return pg
+	}
+	limits, ok := g.overrides[name]
+	if !ok {
+		limits = g.defaults
+	}
+	pg := &providerGate{slots: make(chan struct{}, max(limits.Concurrency, 1))}
+	if limits.Rate > 0 {
+		pg.limiter = rate.NewLimiter(rate.Limit(limits.Rate), max(limits.Burst, 1))
+	}
+	g.gates[name] = pg
+	return pg
+}
+
+// acquire waits for a free slot and a rate token of provider name. The
+// returned function releases the slot and must be called once the request
+// has finished.
+func (g *providerGates) acquire(ctx context.Context, name string) (func(), error) {
+	pg := g.gate(name)
+	select {
+	case pg.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-pg.slots }
+	if pg.limiter != nil {
+		if err := pg.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// call runs fn for provider name within its limits and providerTimeout.
+func (g *providerGates) call(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	release, err := g.acquire(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+	return fn(ctx)
+}
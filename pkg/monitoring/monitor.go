@@ -1,5 +1,5 @@
 // file: pkg/monitoring/monitor.go
-// version: 1.0.0
+// version: 1.5.1
 // guid: 12345678-1234-1234-1234-123456789012
 
 package monitoring
@@ -8,17 +8,17 @@ import (
 	"context"
 	"encoding/json"
 	"os"
-	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
-	"github.com/jdfalk/subtitle-manager/pkg/providers"
 	"github.com/jdfalk/subtitle-manager/pkg/radarr"
+	"github.com/jdfalk/subtitle-manager/pkg/scanner"
 	"github.com/jdfalk/subtitle-manager/pkg/sonarr"
+	"github.com/jdfalk/subtitle-manager/pkg/video"
 )
 
 // MonitorStatus represents the current monitoring state of a media item.
@@ -55,6 +55,20 @@ type EpisodeMonitor struct {
 	maxRetries   int
 	qualityCheck bool
 	logger       *logrus.Entry
+	tagFilter    ProviderTagFilter
+	providerTags []string
+	workers      int
+	gates        *providerGates
+	stateFile    string
+
+	cycleMu sync.Mutex
+	cycle   *cycleState
+}
+
+// ProviderTagFilter resolves the IDs of entities carrying all given tags.
+// *tagging.TagManager implements it.
+type ProviderTagFilter interface {
+	FilterByTags(entityType string, tagNames []string) ([]string, error)
 }
 
 // NewEpisodeMonitor creates a new episode monitoring instance.
@@ -74,9 +88,18 @@ func NewEpisodeMonitor(
 		maxRetries:   maxRetries,
 		qualityCheck: qualityCheck,
 		logger:       logging.GetLogger("monitoring"),
+		workers:      DefaultWorkers,
+		gates:        newProviderGates(DefaultProviderLimits, nil),
 	}
 }
 
+// SetProviderTags limits subtitle searches to provider instances carrying
+// all tags, as resolved by tm.
+func (m *EpisodeMonitor) SetProviderTags(tm ProviderTagFilter, tags []string) {
+	m.tagFilter = tm
+	m.providerTags = tags
+}
+
 // Start begins the monitoring process, running until the context is cancelled.
 func (m *EpisodeMonitor) Start(ctx context.Context) error {
 	m.logger.Info("Starting episode monitoring")
@@ -102,25 +125,6 @@ func (m *EpisodeMonitor) Start(ctx context.Context) error {
 	}
 }
 
-// checkForSubtitles performs a single monitoring cycle.
-func (m *EpisodeMonitor) checkForSubtitles(ctx context.Context) error {
-	// Get monitored items that need checking
-	items, err := m.getItemsToCheck()
-	if err != nil {
-		return err
-	}
-
-	m.logger.Debugf("Checking %d monitored items", len(items))
-
-	for _, item := range items {
-		if err := m.processItem(ctx, item); err != nil {
-			m.logger.Warnf("Failed to process item %s: %v", item.Path, err)
-		}
-	}
-
-	return nil
-}
-
 // processItem processes a single monitored item.
 func (m *EpisodeMonitor) processItem(ctx context.Context, item *MonitoredItem) error {
 	// Skip if item is blacklisted
@@ -132,18 +136,38 @@ func (m *EpisodeMonitor) processItem(ctx context.Context, item *MonitoredItem) e
 	// Update last checked time
 	item.LastChecked = time.Now()
 
-	// Check each requested language
-	foundAny := false
-	for _, lang := range item.Languages {
-		if err := m.checkLanguage(ctx, item, lang); err != nil {
-			m.logger.Debugf("Language %s failed for %s: %v", lang, item.Path, err)
-			continue
+	// Check each subtitle wanted by the item's language profile
+	plan := m.resolvePlan(item)
+	tracks := m.embeddedTracks(item)
+	met, downloaded := 0, false
+	for _, want := range plan.Wanted {
+		ok, got, err := m.checkWanted(ctx, item, want, plan.Cutoff, tracks)
+		if err != nil {
+			m.logger.Debugf("Subtitle %s failed for %s: %v", want, item.Path, err)
 		}
-		foundAny = true
+		if got {
+			downloaded = true
+		}
+		if ok {
+			met++
+		}
+	}
+
+	// Leave interrupted items due so the next cycle picks them up again
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// Update retry count and status
-	if !foundAny {
+	// Update retry count and status. Items stay monitored until every
+	// wanted subtitle reaches the profile cutoff score.
+	switch {
+	case len(plan.Wanted) > 0 && met == len(plan.Wanted):
+		item.Status = StatusFound
+		m.logger.Infof("Found subtitles for %s", item.Path)
+	case downloaded:
+		item.Status = StatusMonitoring
+		m.logger.Infof("Subtitles for %s below cutoff %d, %d of %s met", item.Path, plan.Cutoff, met, describeWanted(plan.Wanted))
+	default:
 		item.RetryCount++
 		if item.RetryCount >= item.MaxRetries {
 			item.Status = StatusFailed
@@ -156,25 +180,48 @@ func (m *EpisodeMonitor) processItem(ctx context.Context, item *MonitoredItem) e
 		} else {
 			item.Status = StatusMonitoring
 		}
-	} else {
-		item.Status = StatusFound
-		m.logger.Infof("Found subtitles for %s", item.Path)
 	}
 
 	// Update item status in database
 	return m.updateMonitoredItem(item)
 }
 
-// checkLanguage attempts to download subtitles for a specific language.
-func (m *EpisodeMonitor) checkLanguage(ctx context.Context, item *MonitoredItem, lang string) error {
-	// Use the existing provider system to fetch subtitles
-	data, providerID, err := providers.FetchFromAll(ctx, item.Path, lang, "")
+// checkWanted makes sure item has the subtitle described by want. An
+// installed subtitle reaching cutoff is kept, and a matching track embedded
+// in the video, as returned by tracks, counts as met. Otherwise the providers
+// are searched and the best candidate replaces the installed subtitle when it
+// scores higher. met reports whether the subtitle now reaches cutoff and
+// downloaded whether a new subtitle was stored.
+func (m *EpisodeMonitor) checkWanted(ctx context.Context, item *MonitoredItem, want wantedSubtitle, cutoff int, tracks func() []video.SubtitleTrack) (met, downloaded bool, err error) {
+	installed := m.installedSubtitle(item.Path, want)
+	installedScore, _ := installed.Score()
+	if installed != nil && installedScore >= cutoff {
+		return true, false, nil
+	}
+	if m.embeddedSubtitle(item, want, tracks(), installed == nil) {
+		return true, false, nil
+	}
+
+	best, err := m.findBest(ctx, item, want)
 	if err != nil {
-		return err
+		return false, false, err
+	}
+	if installed != nil && best.Score <= installedScore {
+		m.logger.Debugf("Best %s subtitle for %s scores %d, not above installed %d", want, item.Path, best.Score, installedScore)
+		return false, false, nil
 	}
 
-	// Store the subtitle and mark as found
-	return m.storeSubtitle(item, lang, data, providerID)
+	path, err := m.storeSubtitle(item, want, best)
+	if err != nil {
+		return false, false, err
+	}
+	// A better subtitle in another format replaces the old file.
+	if installed != nil && installed.File != path {
+		if err := os.Remove(installed.File); err != nil && !os.IsNotExist(err) {
+			m.logger.Warnf("Failed to remove replaced subtitle %s: %v", installed.File, err)
+		}
+	}
+	return best.Score >= cutoff, true, nil
 }
 
 // getItemsToCheck retrieves monitored items that need checking.
@@ -235,26 +282,50 @@ func (m *EpisodeMonitor) updateMonitoredItem(item *MonitoredItem) error {
 	return m.store.UpdateMonitoredItem(dbItem)
 }
 
-// storeSubtitle saves the downloaded subtitle to disk and database.
-func (m *EpisodeMonitor) storeSubtitle(item *MonitoredItem, lang string, data []byte, providerID string) error {
-	// Generate subtitle file path
-	ext := filepath.Ext(item.Path)
-	base := strings.TrimSuffix(item.Path, ext)
-	subtitlePath := base + "." + lang + ".srt"
+// storeSubtitle saves the downloaded subtitle to disk and database and
+// returns its path. The file is named after the video with the language
+// code, a ".forced" or ".hi" tag and the extension of the subtitle's own
+// format. The subtitle is transcoded to UTF-8 first and the detected source
+// encoding is kept in the provider metadata of its subtitle record. Once
+// the file is written, failures to record it in the database are logged.
+func (m *EpisodeMonitor) storeSubtitle(item *MonitoredItem, want wantedSubtitle, sub *scoredSubtitle) (string, error) {
+	lang := want.Language
+	subtitlePath := want.path(item.Path, sub.Format)
+
+	data, enc := scanner.NormalizeEncoding(sub.Data, lang)
 
 	// Write subtitle to disk
 	if err := os.WriteFile(subtitlePath, data, 0644); err != nil {
-		return err
+		return "", err
 	}
 
 	// Record download in database
+	score := float64(sub.Score) / 100
 	downloadRec := &database.DownloadRecord{
-		File:      subtitlePath,
-		VideoFile: item.Path,
-		Provider:  providerID,
-		Language:  lang,
-		CreatedAt: time.Now(),
+		File:       subtitlePath,
+		VideoFile:  item.Path,
+		Provider:   sub.Provider,
+		Language:   lang,
+		MatchScore: &score,
+		CreatedAt:  time.Now(),
+	}
+	if err := m.store.InsertDownload(downloadRec); err != nil {
+		m.logger.Warnf("record download %s: %v", subtitlePath, err)
 	}
 
-	return m.store.InsertDownload(downloadRec)
+	subRec, err := database.CreateSubtitleRecord(subtitlePath, item.Path, lang, sub.Provider, &database.ProviderMetadata{
+		Encoding:   enc.Encoding,
+		Format:     string(sub.Format),
+		Language:   lang,
+		Release:    sub.Release,
+		SourceName: sub.Provider,
+	})
+	if err != nil {
+		m.logger.Warnf("record subtitle %s: %v", subtitlePath, err)
+		return subtitlePath, nil
+	}
+	if err := m.store.InsertSubtitle(subRec); err != nil {
+		m.logger.Warnf("record subtitle %s: %v", subtitlePath, err)
+	}
+	return subtitlePath, nil
 }
@@ -1,11 +1,14 @@
 // file: pkg/monitoring/monitor_test.go
-// version: 1.0.0
+// version: 1.1.0
 // guid: 12345678-1234-1234-1234-123456789015
 
 package monitoring
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 
 	gcommon "github.com/jdfalk/gcommon/sdks/go/v1/common"
 	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
 )
 
 // MockSubtitleStore implements database.SubtitleStore for testing
@@ -169,9 +173,27 @@ func (m *MockSubtitleStore) SetMediaTitle(path, title string) error {
 	args := m.Called(path, title)
 	return args.Error(0)
 }
-func (m *MockSubtitleStore) GetMediaReleaseGroup(path string) (string, error) { return "", nil }
-func (m *MockSubtitleStore) GetMediaAltTitles(path string) ([]string, error)  { return []string{}, nil }
-func (m *MockSubtitleStore) GetMediaFieldLocks(path string) (string, error)   { return "", nil }
+func (m *MockSubtitleStore) MoveMediaPath(oldPath, newPath string) error {
+	args := m.Called(oldPath, newPath)
+	return args.Error(0)
+}
+func (m *MockSubtitleStore) ListFileIndexEntries(dir string) ([]database.FileIndexEntry, error) {
+	args := m.Called(dir)
+	return args.Get(0).([]database.FileIndexEntry), args.Error(1)
+}
+func (m *MockSubtitleStore) UpsertFileIndexEntry(e *database.FileIndexEntry) error {
+	args := m.Called(e)
+	return args.Error(0)
+}
+func (m *MockSubtitleStore) DeleteFileIndexEntry(path string) error {
+	args := m.Called(path)
+	return args.Error(0)
+}
+func (m *MockSubtitleStore) GetMediaReleaseGroup(path string) (string, error)           { return "", nil }
+func (m *MockSubtitleStore) GetMediaAltTitles(path string) ([]string, error)            { return []string{}, nil }
+func (m *MockSubtitleStore) GetMediaFieldLocks(path string) (string, error)             { return "", nil }
+func (m *MockSubtitleStore) SetMediaHashes(path string, hashes map[string]string) error { return nil }
+func (m *MockSubtitleStore) GetMediaHashes(path string) (map[string]string, error)      { return nil, nil }
 
 func (m *MockSubtitleStore) InsertMonitoredItem(rec *database.MonitoredItem) error {
 	args := m.Called(rec)
@@ -198,6 +220,47 @@ func (m *MockSubtitleStore) GetMonitoredItemsToCheck(interval time.Duration) ([]
 	return args.Get(0).([]database.MonitoredItem), args.Error(1)
 }
 
+func (m *MockSubtitleStore) InsertQueueJob(rec *database.QueueJob) error {
+	args := m.Called(rec)
+	return args.Error(0)
+}
+
+func (m *MockSubtitleStore) UpdateQueueJob(rec *database.QueueJob) error {
+	args := m.Called(rec)
+	return args.Error(0)
+}
+
+func (m *MockSubtitleStore) GetQueueJob(id string) (*database.QueueJob, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.QueueJob), args.Error(1)
+}
+
+func (m *MockSubtitleStore) ListQueueJobs(status string) ([]database.QueueJob, error) {
+	args := m.Called(status)
+	return args.Get(0).([]database.QueueJob), args.Error(1)
+}
+
+func (m *MockSubtitleStore) ClaimQueueJob(now time.Time) (*database.QueueJob, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.QueueJob), args.Error(1)
+}
+
+func (m *MockSubtitleStore) CancelQueueJob(id string) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSubtitleStore) DeleteQueueJob(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
 func (m *MockSubtitleStore) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -305,6 +368,27 @@ func TestEpisodeMonitor_CheckForSubtitles(t *testing.T) {
 	store.AssertExpectations(t)
 }
 
+// TestEpisodeMonitor_StoreSubtitleIgnoresRecordErrors verifies a subtitle
+// written to disk is reported as stored even when recording it fails.
+func TestEpisodeMonitor_StoreSubtitleIgnoresRecordErrors(t *testing.T) {
+	store := &MockSubtitleStore{}
+	store.On("InsertDownload", mock.Anything).Return(errors.New("db down"))
+	store.On("InsertSubtitle", mock.Anything).Return(errors.New("db down"))
+
+	monitor := NewEpisodeMonitor(time.Hour, nil, nil, store, 3, false)
+	item := &MonitoredItem{Path: filepath.Join(t.TempDir(), "show.mkv")}
+	sub := &scoredSubtitle{Data: []byte("1\n00:00:01,000 --> 00:00:02,000\nhello\n"), Provider: "test", Format: subtitles.FormatSRT, Score: 90}
+
+	path, err := monitor.storeSubtitle(item, wantedSubtitle{Language: "en"}, sub)
+
+	assert.NoError(t, err)
+	assert.FileExists(t, path)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "hello")
+	store.AssertExpectations(t)
+}
+
 func (m *MockSubtitleStore) AssignProfileToMedia(mediaID, profileID string) error { return nil }
 func (m *MockSubtitleStore) RemoveProfileFromMedia(mediaID string) error          { return nil }
 func (m *MockSubtitleStore) GetMediaProfile(mediaID string) (*database.LanguageProfile, error) {
@@ -0,0 +1,365 @@
+// file: pkg/monitoring/profile.go
+// version: 1.2.1
+// guid: bafc923d-6b29-428b-ab81-6e4c410a830f
+
+package monitoring
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jdfalk/subtitle-manager/pkg/archive"
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/profiles"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/scoring"
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+)
+
+// wantedSubtitle is one subtitle a monitored item should have: a language
+// together with the forced and hearing impaired requirements of its profile.
+type wantedSubtitle struct {
+	Language string
+	Forced   bool
+	HI       bool
+}
+
+// String returns the language code followed by the forced or HI tag.
+func (w wantedSubtitle) String() string {
+	return w.Language + w.suffix()
+}
+
+// suffix returns the tag inserted between the language code and the
+// extension of the subtitle file name.
+func (w wantedSubtitle) suffix() string {
+	switch {
+	case w.Forced:
+		return ".forced"
+	case w.HI:
+		return ".hi"
+	}
+	return ""
+}
+
+// matches reports whether c satisfies the forced and HI requirements. Forced
+// subtitles only cover foreign dialogue, so they are accepted only when
+// wanted. HI subtitles are accepted for regular targets and required for HI
+// targets.
+func (w wantedSubtitle) matches(c providers.Candidate) bool {
+	if c.Forced != w.Forced {
+		return false
+	}
+	return !w.HI || c.HearingImpaired
+}
+
+// path returns the subtitle file name for video in format f.
+func (w wantedSubtitle) path(video string, f subtitles.Format) string {
+	base := strings.TrimSuffix(video, filepath.Ext(video))
+	return base + "." + w.Language + w.suffix() + f.Ext()
+}
+
+// searchPlan lists the subtitles wanted for an item in priority order and
+// the score each must reach before monitoring stops.
+type searchPlan struct {
+	Wanted []wantedSubtitle
+	Cutoff int
+}
+
+// resolvePlan returns the search plan for item. The language profile
+// assigned to the item's media, or the default profile, decides the
+// languages, their order and the cutoff score. Items without a usable
+// profile fall back to their own language list without a cutoff.
+func (m *EpisodeMonitor) resolvePlan(item *MonitoredItem) searchPlan {
+	profile := m.itemProfile(item)
+	if profile == nil || len(profile.Languages) == 0 {
+		plan := searchPlan{}
+		for _, lang := range item.Languages {
+			plan.Wanted = append(plan.Wanted, wantedSubtitle{Language: lang})
+		}
+		return plan
+	}
+
+	langs := append([]profiles.LanguageConfig(nil), profile.Languages...)
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].Priority < langs[j].Priority })
+	plan := searchPlan{Cutoff: profile.CutoffScore}
+	for _, l := range langs {
+		plan.Wanted = append(plan.Wanted, wantedSubtitle{Language: l.Language, Forced: l.Forced, HI: l.HI})
+	}
+	return plan
+}
+
+// itemProfile returns the language profile assigned to the item's media,
+// the default profile when none is assigned, or nil.
+func (m *EpisodeMonitor) itemProfile(item *MonitoredItem) *database.LanguageProfile {
+	if item.MediaID != "" {
+		if profile, err := m.store.GetMediaProfile(item.MediaID); err == nil && profile != nil && len(profile.Languages) > 0 {
+			return profile
+		}
+	}
+	profile, err := m.store.GetDefaultLanguageProfile()
+	if err != nil {
+		return nil
+	}
+	return profile
+}
+
+// scoredSubtitle is a downloaded subtitle together with its quality score.
+type scoredSubtitle struct {
+	Data     []byte
+	Provider string
+	Format   subtitles.Format
+	Release  string
+	Score    int
+}
+
+// errNoCandidates is returned when no provider offers a subtitle matching
+// the wanted language, forced and HI requirements.
+var errNoCandidates = errors.New("no matching subtitle found")
+
+// providerRef names a provider and the instance ID it is recorded under.
+type providerRef struct {
+	ID   string
+	Name string
+}
+
+// searchProviders returns the providers to query. Configured instances are
+// limited to the provider tags set with SetProviderTags; without instances
+// every registered provider is used.
+func (m *EpisodeMonitor) searchProviders() ([]providerRef, error) {
+	insts, err := providers.InstancesByTags(m.tagFilter, m.providerTags)
+	if err != nil {
+		return nil, err
+	}
+	var refs []providerRef
+	for _, inst := range insts {
+		if !providers.IsInBackoff(inst.ID) {
+			refs = append(refs, providerRef{ID: inst.ID, Name: inst.Name})
+		}
+	}
+	if len(insts) == 0 && (m.tagFilter == nil || len(m.providerTags) == 0) {
+		for _, name := range providers.All() {
+			if !providers.IsInBackoff(name) {
+				refs = append(refs, providerRef{ID: name, Name: name})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// rankedCandidate is a search result with its provider and score.
+type rankedCandidate struct {
+	ref   providerRef
+	p     providers.Provider
+	cand  providers.Candidate
+	score int
+}
+
+// findBest searches every provider for want and downloads the highest
+// scoring candidate that meets its forced and HI requirements. Providers are
+// searched concurrently within their limits. Candidates that fail to
+// download are skipped in favour of the next best one. When no provider
+// offers a candidate, regular targets fall back to fetching from each
+// provider in turn, scored without release metadata.
+func (m *EpisodeMonitor) findBest(ctx context.Context, item *MonitoredItem, want wantedSubtitle) (*scoredSubtitle, error) {
+	refs, err := m.searchProviders()
+	if err != nil {
+		return nil, err
+	}
+	media := scoring.FromMediaPath(item.Path)
+	profile := scoring.LoadProfileFromConfig()
+	profile.PreferForced = want.Forced
+	profile.PreferHI = want.HI
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		ranked []rankedCandidate
+	)
+	for _, ref := range refs {
+		p, err := providers.Get(ref.Name, "")
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var cands []providers.Candidate
+			err := m.gates.call(ctx, ref.Name, func(ctx context.Context) error {
+				var err error
+				cands, err = providers.SearchCandidates(ctx, p, ref.Name, item.Path, want.Language)
+				return err
+			})
+			if err != nil {
+				if !errors.Is(err, providers.ErrSearchUnsupported) && ctx.Err() == nil {
+					providers.BackoffOnQuota(ref.ID, err)
+					m.logger.Debugf("search %s with %s: %v", item.Path, ref.ID, err)
+				}
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, c := range cands {
+				if !want.matches(c) {
+					continue
+				}
+				score := scoring.CalculateScore(scoring.FromCandidate(c), media, profile).Total
+				ranked = append(ranked, rankedCandidate{ref: ref, p: p, cand: c, score: score})
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	// Ties keep the provider order of refs.
+	order := make(map[string]int, len(refs))
+	for i, ref := range refs {
+		order[ref.ID] = i
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return order[ranked[i].ref.ID] < order[ranked[j].ref.ID]
+	})
+
+	for _, r := range ranked {
+		var data []byte
+		var entry string
+		err := m.gates.call(ctx, r.ref.Name, func(ctx context.Context) error {
+			var err error
+			if data, err = providers.DownloadCandidate(ctx, r.p, r.cand); err != nil {
+				return err
+			}
+			data, entry, err = archive.Extract(data, item.Path)
+			return err
+		})
+		if err != nil {
+			m.logger.Debugf("download candidate %s from %s: %v", r.cand.ID, r.ref.ID, err)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			providers.BackoffOnQuota(r.ref.ID, err)
+			continue
+		}
+		return &scoredSubtitle{
+			Data:     data,
+			Provider: r.ref.ID,
+			Format:   subtitleFormat(data, entry, r.cand.Format, r.cand.FileName),
+			Release:  r.cand.Release,
+			Score:    r.score,
+		}, nil
+	}
+
+	if len(ranked) > 0 || want.Forced || want.HI {
+		return nil, errNoCandidates
+	}
+	return m.fetchFirst(ctx, refs, item, want, media, profile)
+}
+
+// fetchFirst fetches want from each provider in turn and returns the first
+// subtitle found, scored from the provider name and file size alone.
+func (m *EpisodeMonitor) fetchFirst(ctx context.Context, refs []providerRef, item *MonitoredItem, want wantedSubtitle, media scoring.MediaItem, profile scoring.Profile) (*scoredSubtitle, error) {
+	for _, ref := range refs {
+		p, err := providers.Get(ref.Name, "")
+		if err != nil {
+			continue
+		}
+		var data []byte
+		err = m.gates.call(ctx, ref.Name, func(ctx context.Context) error {
+			var err error
+			data, err = p.Fetch(ctx, item.Path, want.Language)
+			providers.RecordQuota(ref.Name, p)
+			if err != nil {
+				return err
+			}
+			data, _, err = archive.Extract(data, item.Path)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			providers.BackoffOnQuota(ref.ID, err)
+			continue
+		}
+		f := subtitleFormat(data)
+		sub := scoring.Subtitle{ProviderName: ref.ID, Format: string(f), FileSize: int64(len(data))}
+		return &scoredSubtitle{
+			Data:     data,
+			Provider: ref.ID,
+			Format:   f,
+			Score:    scoring.CalculateScore(sub, media, profile).Total,
+		}, nil
+	}
+	return nil, errNoCandidates
+}
+
+// subtitleFormat returns the format of a downloaded subtitle. The given
+// hints, such as an archive entry name, a provider format or a file name,
+// are tried in order before the content is sniffed. SubRip is assumed when
+// nothing else matches.
+func subtitleFormat(data []byte, hints ...string) subtitles.Format {
+	for _, h := range hints {
+		if h == "" {
+			continue
+		}
+		if ext := filepath.Ext(h); ext != "" {
+			h = ext
+		}
+		if f, err := subtitles.ParseFormat(h); err == nil {
+			return f
+		}
+	}
+	head := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(head, []byte("WEBVTT")):
+		return subtitles.FormatWebVTT
+	case bytes.HasPrefix(head, []byte("[Script Info]")):
+		if bytes.Contains(head, []byte("[V4+ Styles]")) {
+			return subtitles.FormatASS
+		}
+		return subtitles.FormatSSA
+	}
+	return subtitles.FormatSRT
+}
+
+// installedSubtitle returns the most recent download of want for video, or
+// nil when none is recorded.
+func (m *EpisodeMonitor) installedSubtitle(video string, want wantedSubtitle) *database.DownloadRecord {
+	recs, err := m.store.ListDownloadsByVideo(video)
+	if err != nil {
+		return nil
+	}
+	prefix := strings.TrimSuffix(video, filepath.Ext(video)) + "." + want.Language + want.suffix() + "."
+	var latest *database.DownloadRecord
+	for i := range recs {
+		r := &recs[i]
+		if r.Language != want.Language || !strings.HasPrefix(r.File, prefix) {
+			continue
+		}
+		// A plain target must not match a ".forced" or ".hi" file.
+		if strings.Contains(strings.TrimPrefix(r.File, prefix), ".") {
+			continue
+		}
+		if latest == nil || r.CreatedAt.After(latest.CreatedAt) {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// describeWanted formats the wanted subtitles of a plan for log messages.
+func describeWanted(wanted []wantedSubtitle) string {
+	names := make([]string, len(wanted))
+	for i, w := range wanted {
+		names[i] = w.String()
+	}
+	return fmt.Sprintf("[%s]", strings.Join(names, ", "))
+}
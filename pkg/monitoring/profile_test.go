@@ -0,0 +1,245 @@
+// file: pkg/monitoring/profile_test.go
+// version: 1.2.0
+// guid: 5d8e2b47-91c3-4f6a-8e0d-3a7b6c1f9e24
+
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/profiles"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	pkgvideo "github.com/jdfalk/subtitle-manager/pkg/video"
+)
+
+// candidateProvider serves fixed search results per language.
+type candidateProvider struct {
+	cands map[string][]providers.Candidate
+	data  map[string][]byte
+}
+
+func (c *candidateProvider) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	return nil, errors.New("fetch not supported")
+}
+
+func (c *candidateProvider) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]providers.Candidate, error) {
+	return c.cands[lang], nil
+}
+
+func (c *candidateProvider) Download(ctx context.Context, cand providers.Candidate) ([]byte, error) {
+	return c.data[cand.ID], nil
+}
+
+const assData = "[Script Info]\nScriptType: v4.00+\n\n[V4+ Styles]\n"
+
+// registerCandidateProvider makes p the only enabled provider instance.
+func registerCandidateProvider(t *testing.T, p *candidateProvider) {
+	t.Helper()
+	providers.RegisterFactory("monitortest", func() providers.Provider { return p })
+	inst := providers.Instance{ID: "monitortest", Name: "monitortest", Enabled: true}
+	providers.RegisterInstance(inst)
+	t.Cleanup(func() {
+		inst.Enabled = false
+		providers.RegisterInstance(inst)
+	})
+}
+
+// newProfileMonitor returns a monitor over a Pebble store holding a media
+// item for video with profile assigned, and the monitored item for it.
+func newProfileMonitor(t *testing.T, video string, profile *database.LanguageProfile) (*EpisodeMonitor, *database.PebbleStore, *MonitoredItem) {
+	t.Helper()
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	require.NoError(t, store.CreateLanguageProfile(profile))
+	require.NoError(t, store.AssignProfileToMedia("media-1", profile.ID))
+	rec := &database.MonitoredItem{MediaID: "media-1", Path: video, Languages: `["en"]`, Status: "pending", MaxRetries: 3}
+	require.NoError(t, store.InsertMonitoredItem(rec))
+
+	m := NewEpisodeMonitor(time.Hour, nil, nil, store, 3, false)
+	m.SetProviderLimits(ProviderLimits{Concurrency: 4}, nil)
+	item := &MonitoredItem{ID: rec.ID, MediaID: "media-1", Path: video, Languages: []string{"en"}, MaxRetries: 3}
+	return m, store, item
+}
+
+func TestWantedSubtitleNamingAndMatching(t *testing.T) {
+	plain := wantedSubtitle{Language: "en"}
+	forced := wantedSubtitle{Language: "en", Forced: true}
+	hi := wantedSubtitle{Language: "en", HI: true}
+
+	assert.Equal(t, "/tv/show.en.srt", plain.path("/tv/show.mkv", subtitles.FormatSRT))
+	assert.Equal(t, "/tv/show.en.forced.ass", forced.path("/tv/show.mkv", subtitles.FormatASS))
+	assert.Equal(t, "/tv/show.en.hi.vtt", hi.path("/tv/show.mkv", subtitles.FormatWebVTT))
+
+	assert.True(t, plain.matches(providers.Candidate{}))
+	assert.True(t, plain.matches(providers.Candidate{HearingImpaired: true}))
+	assert.False(t, plain.matches(providers.Candidate{Forced: true}))
+	assert.True(t, forced.matches(providers.Candidate{Forced: true}))
+	assert.False(t, forced.matches(providers.Candidate{}))
+	assert.True(t, hi.matches(providers.Candidate{HearingImpaired: true}))
+	assert.False(t, hi.matches(providers.Candidate{}))
+}
+
+func TestSubtitleFormat(t *testing.T) {
+	assert.Equal(t, subtitles.FormatASS, subtitleFormat(nil, "pack/ep1.ass", "srt"))
+	assert.Equal(t, subtitles.FormatWebVTT, subtitleFormat(nil, "", "vtt"))
+	assert.Equal(t, subtitles.FormatSSA, subtitleFormat(nil, "", "", "Show.ssa"))
+	assert.Equal(t, subtitles.FormatWebVTT, subtitleFormat([]byte("\xef\xbb\xbfWEBVTT\n\n")))
+	assert.Equal(t, subtitles.FormatASS, subtitleFormat([]byte(assData)))
+	assert.Equal(t, subtitles.FormatSRT, subtitleFormat([]byte("1\n00:00:01,000 --> 00:00:02,000\nhi\n")))
+}
+
+func TestResolvePlanFallsBackToItemLanguages(t *testing.T) {
+	m := NewEpisodeMonitor(time.Hour, nil, nil, &MockSubtitleStore{}, 3, false)
+	plan := m.resolvePlan(&MonitoredItem{MediaID: "1", Languages: []string{"en", "fr"}})
+	assert.Equal(t, []wantedSubtitle{{Language: "en"}, {Language: "fr"}}, plan.Wanted)
+	assert.Zero(t, plan.Cutoff)
+}
+
+func TestProcessItemFollowsLanguageProfile(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "Show.S01E01.mkv")
+	registerCandidateProvider(t, &candidateProvider{
+		cands: map[string][]providers.Candidate{
+			"en": {{ID: "en-1", Release: "Show.S01E01", Format: "ass"}},
+			"es": {
+				{ID: "es-full", Release: "Show.S01E01", Format: "srt", HashMatch: true},
+				{ID: "es-forced", Release: "Show.S01E01", Format: "srt", Forced: true},
+			},
+			"fr": {{ID: "fr-1", Release: "Show.S01E01", Format: "srt"}},
+		},
+		data: map[string][]byte{
+			"en-1":      []byte(assData),
+			"es-full":   []byte("1\n00:00:01,000 --> 00:00:02,000\nfull\n"),
+			"es-forced": []byte("1\n00:00:01,000 --> 00:00:02,000\nforced\n"),
+		},
+	})
+	m, store, item := newProfileMonitor(t, video, &database.LanguageProfile{
+		ID:   "p1",
+		Name: "English and Spanish forced",
+		Languages: []profiles.LanguageConfig{
+			{Language: "es", Priority: 2, Forced: true},
+			{Language: "en", Priority: 1},
+		},
+		CutoffScore: 1,
+	})
+
+	require.NoError(t, m.processItem(context.Background(), item))
+
+	assert.Equal(t, StatusFound, item.Status)
+	assert.FileExists(t, filepath.Join(dir, "Show.S01E01.en.ass"))
+	forced, err := os.ReadFile(filepath.Join(dir, "Show.S01E01.es.forced.srt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(forced), "forced")
+	assert.NoFileExists(t, filepath.Join(dir, "Show.S01E01.fr.srt"))
+	assert.NoFileExists(t, filepath.Join(dir, "Show.S01E01.es.srt"))
+
+	downloads, err := store.ListDownloadsByVideo(video)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+	for _, d := range downloads {
+		require.NotNil(t, d.MatchScore)
+		assert.Equal(t, "monitortest", d.Provider)
+	}
+}
+
+func TestProcessItemUsesEmbeddedTracks(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "Show.S01E02.mkv")
+	bin := t.TempDir()
+	ffprobe := filepath.Join(bin, "ffprobe")
+	require.NoError(t, os.WriteFile(ffprobe, []byte(`#!/bin/sh
+echo '{"streams":[
+ {"index":2,"codec_type":"subtitle","codec_name":"subrip","tags":{"language":"eng"}},
+ {"index":3,"codec_type":"subtitle","codec_name":"hdmv_pgs_subtitle","tags":{"language":"spa","title":"Forced"}}]}'
+`), 0755))
+	ffmpeg := filepath.Join(bin, "ffmpeg")
+	require.NoError(t, os.WriteFile(ffmpeg, []byte("#!/bin/sh\nfor last; do :; done\nprintf '1\\n00:00:01,000 --> 00:00:02,000\\nembedded\\n\\n' > \"$last\"\n"), 0755))
+	pkgvideo.SetFFprobePath(ffprobe)
+	subtitles.SetFFmpegPath(ffmpeg)
+	t.Cleanup(func() {
+		pkgvideo.SetFFprobePath("ffprobe")
+		subtitles.SetFFmpegPath("ffmpeg")
+		viper.Reset()
+	})
+	viper.Set("embedded.extract", true)
+
+	// The provider has nothing, so only embedded tracks can satisfy the plan.
+	registerCandidateProvider(t, &candidateProvider{})
+	m, store, item := newProfileMonitor(t, video, &database.LanguageProfile{
+		ID:   "p1",
+		Name: "English and Spanish forced",
+		Languages: []profiles.LanguageConfig{
+			{Language: "en", Priority: 1},
+			{Language: "es", Priority: 2, Forced: true},
+		},
+		CutoffScore: 90,
+	})
+
+	require.NoError(t, m.processItem(context.Background(), item))
+
+	assert.Equal(t, StatusFound, item.Status)
+	data, err := os.ReadFile(filepath.Join(dir, "Show.S01E02.en.srt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "embedded")
+	// Image tracks cannot be extracted and are used in place.
+	assert.NoFileExists(t, filepath.Join(dir, "Show.S01E02.es.forced.srt"))
+
+	subs, err := store.ListSubtitlesByVideo(video)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "embedded", subs[0].Service)
+	assert.True(t, subs[0].Embedded)
+}
+
+func TestProcessItemKeepsMonitoringBelowCutoff(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "Movie.2020.mkv")
+	p := &candidateProvider{
+		cands: map[string][]providers.Candidate{
+			"en": {{ID: "en-1", Release: "Movie.2020", Format: "srt"}},
+		},
+		data: map[string][]byte{"en-1": []byte("1\n00:00:01,000 --> 00:00:02,000\nfirst\n")},
+	}
+	registerCandidateProvider(t, p)
+	m, store, item := newProfileMonitor(t, video, &database.LanguageProfile{
+		ID:          "p1",
+		Name:        "Strict",
+		Languages:   []profiles.LanguageConfig{{Language: "en", Priority: 1}},
+		CutoffScore: 101,
+	})
+
+	// The first subtitle is stored even though it misses the cutoff.
+	require.NoError(t, m.processItem(context.Background(), item))
+	assert.Equal(t, StatusMonitoring, item.Status)
+	assert.Zero(t, item.RetryCount)
+	assert.FileExists(t, filepath.Join(dir, "Movie.2020.en.srt"))
+
+	// Nothing better is offered, so the next check counts as a retry.
+	require.NoError(t, m.processItem(context.Background(), item))
+	assert.Equal(t, StatusMonitoring, item.Status)
+	assert.Equal(t, 1, item.RetryCount)
+	downloads, err := store.ListDownloadsByVideo(video)
+	require.NoError(t, err)
+	assert.Len(t, downloads, 1)
+
+	// A better scoring subtitle in another format replaces the old file.
+	p.cands["en"] = append(p.cands["en"], providers.Candidate{ID: "en-2", Release: "Movie.2020", Format: "ass", HashMatch: true, Trusted: true})
+	p.data["en-2"] = []byte(assData)
+	require.NoError(t, m.processItem(context.Background(), item))
+	assert.FileExists(t, filepath.Join(dir, "Movie.2020.en.ass"))
+	assert.NoFileExists(t, filepath.Join(dir, "Movie.2020.en.srt"))
+	assert.Equal(t, StatusMonitoring, item.Status)
+}
@@ -1,5 +1,5 @@
 // file: pkg/monitoring/sync.go
-// version: 1.0.0
+// version: 1.1.0
 // guid: 12345678-1234-1234-1234-123456789013
 
 package monitoring
@@ -191,11 +191,14 @@ func (m *EpisodeMonitor) GetMonitoringStats() (*MonitoringStats, error) {
 			stats.Blacklisted++
 		}
 	}
+	m.cycleStats(stats, items)
 
 	return stats, nil
 }
 
-// MonitoringStats contains statistics about the monitoring system.
+// MonitoringStats contains statistics about the monitoring system. The
+// cycle fields describe the current or last monitoring cycle; Throughput is
+// in items per minute and ETA estimates the time left in a running cycle.
 type MonitoringStats struct {
 	Total       int `json:"total"`
 	Pending     int `json:"pending"`
@@ -203,6 +206,13 @@ type MonitoringStats struct {
 	Found       int `json:"found"`
 	Failed      int `json:"failed"`
 	Blacklisted int `json:"blacklisted"`
+
+	CycleStarted   time.Time     `json:"cycle_started,omitempty"`
+	CycleComplete  bool          `json:"cycle_complete"`
+	CycleTotal     int           `json:"cycle_total"`
+	CycleProcessed int           `json:"cycle_processed"`
+	Throughput     float64       `json:"throughput"`
+	ETA            time.Duration `json:"eta"`
 }
 
 // containsString checks if a slice contains a string.
@@ -2,47 +2,437 @@
 package addic7ed
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"github.com/jdfalk/subtitle-manager/pkg/captcha"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/quota"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
-// Client implements the providers.Provider interface for Addic7ed.
-// It performs a simple HTTP GET to download subtitles.
+// Client implements the providers.Provider and providers.CandidateSearcher
+// interfaces for Addic7ed by scraping its web pages. Shows are looked up by
+// name, each release version of an episode becomes a candidate and
+// downloads are sent with the episode page as Referer. With a username and
+// password the client logs in first, solving the login reCAPTCHA with Solver.
 type Client struct {
-	// APIURL is the base URL of the Addic7ed API.
+	// APIURL is the base URL of the Addic7ed site.
 	APIURL string
-	// HTTPClient is used to make requests.
+	// HTTPClient is used to make requests. Its cookie jar is replaced by the
+	// session of the configured account.
 	HTTPClient *http.Client
+	// Solver solves the reCAPTCHA of the login page. It defaults to the
+	// configured Anti-Captcha account.
+	Solver captcha.Solver
+
+	username string
+	password string
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
 	return &Client{
-		APIURL:     "https://api.addic7ed.com",
+		APIURL:     "https://www.addic7ed.com",
 		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+		Solver:     captcha.Default(),
 	}
 }
 
-// Fetch downloads the subtitle for mediaPath in lang.
-// It returns the subtitle bytes or an error.
-func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
-	name := filepath.Base(mediaPath)
-	url := fmt.Sprintf("%s/subtitles/%s/%s", c.APIURL, name, lang)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := s.ApplyHTTP(&c.APIURL, &c.HTTPClient); err != nil {
+		return err
+	}
+	c.username = s.Username
+	c.password = s.Password
+	return nil
+}
+
+// account holds the state shared by all clients of one account: the login
+// cookies, the show IDs and whether the daily download cap was reached. mu
+// guards the fields but is never held during requests; flight collapses
+// concurrent logins and show list loads into one request.
+type account struct {
+	mu       sync.Mutex
+	jar      http.CookieJar
+	loggedIn bool
+	shows    map[string]int
+	limit    quota.Quota
+	flight   singleflight.Group
+}
+
+// once runs fn for key unless a call for key is already in flight, in which
+// case it waits for that call's result or for ctx to end. fn gets ctx
+// without its cancellation, so a caller giving up does not fail the call for
+// the others waiting on it.
+func (a *account) once(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	shared := context.WithoutCancel(ctx)
+	ch := a.flight.DoChan(key, func() (any, error) {
+		return fn(shared)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+var (
+	accountsMu sync.Mutex
+	accounts   = map[string]*account{}
+)
+
+// account returns the shared state of the client's account.
+func (c *Client) account() *account {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+	key := c.APIURL + "|" + c.username
+	a, ok := accounts[key]
+	if !ok {
+		jar, _ := cookiejar.New(nil)
+		a = &account{jar: jar}
+		accounts[key] = a
+	}
+	return a
+}
+
+// do sends a GET or form POST request within the account session and
+// returns the response body. referer is sent when not empty.
+func (c *Client) do(ctx context.Context, a *account, method, path string, form url.Values, referer string) (*http.Response, []byte, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.APIURL+path, body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; subtitle-manager)")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
-	resp, err := c.HTTPClient.Do(req)
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	hc := *c.HTTPClient
+	hc.Jar = a.jar
+	resp, err := hc.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("%s: status %d", path, resp.StatusCode)
+	}
+	return resp, data, nil
+}
+
+// login signs the account in once, solving the reCAPTCHA of the login page
+// when it has one. Concurrent callers share a single login. Clients without
+// credentials stay anonymous.
+func (c *Client) login(ctx context.Context, a *account) error {
+	if c.username == "" || c.password == "" {
+		return nil
+	}
+	a.mu.Lock()
+	loggedIn := a.loggedIn
+	a.mu.Unlock()
+	if loggedIn {
+		return nil
+	}
+	_, err := a.once(ctx, "login", func(ctx context.Context) (any, error) {
+		return nil, c.signIn(ctx, a)
+	})
+	return err
+}
+
+// signIn performs the login requests and marks the account logged in.
+func (c *Client) signIn(ctx context.Context, a *account) error {
+	_, page, err := c.do(ctx, a, http.MethodGet, "/login.php", nil, "")
+	if err != nil {
+		return fmt.Errorf("login page: %w", err)
+	}
+	form := url.Values{
+		"username": {c.username},
+		"password": {c.password},
+		"remember": {"true"},
+		"url":      {""},
+		"Submit":   {"Log in"},
+	}
+	siteKey, err := parseSiteKey(bytes.NewReader(page))
+	if err != nil {
+		return fmt.Errorf("login page: %w", err)
+	}
+	if siteKey != "" {
+		if c.Solver == nil {
+			return fmt.Errorf("login requires a captcha but no captcha solver is configured")
+		}
+		token, err := c.Solver.Solve(ctx, siteKey, c.APIURL+"/login.php")
+		if err != nil {
+			return fmt.Errorf("solve login captcha: %w", err)
+		}
+		form.Set("recaptcha_response", token)
+	}
+	if _, _, err := c.do(ctx, a, http.MethodPost, "/dologin.php", form, c.APIURL+"/login.php"); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	u, _ := url.Parse(c.APIURL)
+	for _, ck := range a.jar.Cookies(u) {
+		if ck.Name == "wikisubtitlesuser" {
+			a.mu.Lock()
+			a.loggedIn = true
+			a.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("login failed for %s", c.username)
+}
+
+// showID returns the ID of the show named name, trying the name without a
+// trailing year when the listing has no such show.
+func (c *Client) showID(ctx context.Context, a *account, name string) (int, error) {
+	shows, err := c.shows(ctx, a)
+	if err != nil {
+		return 0, err
+	}
+	name = normalize(name)
+	if id, ok := shows[name]; ok {
+		return id, nil
+	}
+	if m := trailingYear.FindStringIndex(name); m != nil {
+		if id, ok := shows[strings.TrimSpace(name[:m[0]])]; ok {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("show %q not found", name)
+}
+
+// shows returns the show IDs by normalized name, loading the show list on
+// first use.
+func (c *Client) shows(ctx context.Context, a *account) (map[string]int, error) {
+	a.mu.Lock()
+	shows := a.shows
+	a.mu.Unlock()
+	if shows != nil {
+		return shows, nil
+	}
+	v, err := a.once(ctx, "shows", func(ctx context.Context) (any, error) {
+		_, page, err := c.do(ctx, a, http.MethodGet, "/shows.php", nil, "")
+		if err != nil {
+			return nil, fmt.Errorf("show list: %w", err)
+		}
+		shows, err := parseShows(bytes.NewReader(page))
+		if err != nil {
+			return nil, fmt.Errorf("show list: %w", err)
+		}
+		a.mu.Lock()
+		a.shows = shows
+		a.mu.Unlock()
+		return shows, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]int), nil
+}
+
+var (
+	episodePattern = regexp.MustCompile(`(?i)^(.*?)[ ._-]*s(\d{1,2})e(\d{1,3})`)
+	trailingYear   = regexp.MustCompile(` (19|20)\d\d$`)
+)
+
+// parseEpisode returns the show name, season and episode of an episode
+// file name such as "Show.Name.2019.S01E02.720p.mkv".
+func parseEpisode(mediaPath string) (string, int, int, bool) {
+	m := episodePattern.FindStringSubmatch(filepath.Base(mediaPath))
+	if m == nil || m[1] == "" {
+		return "", 0, 0, false
+	}
+	season, _ := strconv.Atoi(m[2])
+	episode, _ := strconv.Atoi(m[3])
+	return m[1], season, episode, true
+}
+
+// languageNames maps language codes to the names used on Addic7ed where
+// they differ from the English display name.
+var languageNames = map[string]string{
+	"es":    "Spanish",
+	"es-es": "Spanish (Spain)",
+	"es-mx": "Spanish (Latin America)",
+	"pt-br": "Portuguese (Brazilian)",
+	"sr":    "Serbian (Latin)",
+	"zh":    "Chinese (Simplified)",
+	"zh-cn": "Chinese (Simplified)",
+	"zh-tw": "Chinese (Traditional)",
+	"ca":    "Català",
+	"eu":    "Euskera",
+	"gl":    "Galego",
+}
+
+// languageName returns the Addic7ed name of lang.
+func languageName(lang string) string {
+	lang = strings.ToLower(lang)
+	if n, ok := languageNames[lang]; ok {
+		return n
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return lang
+	}
+	return display.English.Languages().Name(tag)
+}
+
+// SearchCandidates returns one candidate per completed release version of
+// the episode mediaPath in lang. The download token holds the download path
+// and the episode page separated by "|".
+func (c *Client) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]candidate.Candidate, error) {
+	show, season, episode, ok := parseEpisode(mediaPath)
+	if !ok {
+		return nil, fmt.Errorf("addic7ed only offers episodes: %s", filepath.Base(mediaPath))
+	}
+	a := c.account()
+	if err := c.login(ctx, a); err != nil {
+		return nil, err
+	}
+	id, err := c.showID(ctx, a, show)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/ajax_loadShow.php?show=%d&season=%d&langs=&hd=undefined&hi=undefined", id, season)
+	_, page, err := c.do(ctx, a, http.MethodGet, path, nil, fmt.Sprintf("%s/show/%d", c.APIURL, id))
+	if err != nil {
+		return nil, fmt.Errorf("season listing: %w", err)
+	}
+	versions, err := parseVersions(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("season listing: %w", err)
+	}
+	name := languageName(lang)
+	release := strings.Join(strings.Fields(normalize(show)), ".")
+	var cands []candidate.Candidate
+	for _, v := range versions {
+		if v.Episode != episode || !v.Completed || !strings.EqualFold(v.Language, name) {
+			continue
+		}
+		cands = append(cands, candidate.Candidate{
+			Provider:        "addic7ed",
+			ID:              v.Download,
+			Release:         fmt.Sprintf("%s.S%02dE%02d.%s", release, v.Season, v.Episode, v.Version),
+			FileName:        fmt.Sprintf("%s - %02dx%02d - %s.%s.srt", show, v.Season, v.Episode, v.Title, v.Version),
+			Language:        lang,
+			HearingImpaired: v.HI,
+			Format:          "srt",
+			Trusted:         v.Corrected,
+			DownloadToken:   v.Download + "|" + v.Page,
+		})
+	}
+	return cands, nil
+}
+
+// Quota reports the daily download cap once it has been reached.
+func (c *Client) Quota() (quota.Quota, bool) {
+	a := c.account()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit, !a.limit.UpdatedAt.IsZero()
+}
+
+// nextReset returns when the daily download counter resets, at midnight UTC.
+func nextReset(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// Download returns the subtitle of a candidate. Addic7ed answers with an
+// HTML page instead of the file once the daily download cap is reached, in
+// which case a quota.ExceededError is returned until the cap resets.
+func (c *Client) Download(ctx context.Context, cand candidate.Candidate) ([]byte, error) {
+	path, page, _ := strings.Cut(cand.DownloadToken, "|")
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid download token %q", cand.DownloadToken)
+	}
+	a := c.account()
+	a.mu.Lock()
+	limit := a.limit
+	a.mu.Unlock()
+	if limit.Exhausted(time.Now()) {
+		return nil, &quota.ExceededError{Provider: "addic7ed", ResetAt: limit.ResetAt}
+	}
+	if err := c.login(ctx, a); err != nil {
+		return nil, err
+	}
+	if page != "" && strings.HasPrefix(page, "/") {
+		page = c.APIURL + page
+	}
+	resp, data, err := c.do(ctx, a, http.MethodGet, path, nil, page)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		if strings.Contains(resp.Request.URL.Path, "downloadexceeded") || bytes.Contains(data, []byte("Daily Download count exceeded")) {
+			now := time.Now()
+			limit := quota.Quota{Remaining: 0, ResetAt: nextReset(now), UpdatedAt: now}
+			a.mu.Lock()
+			a.limit = limit
+			a.mu.Unlock()
+			return nil, &quota.ExceededError{Provider: "addic7ed", ResetAt: limit.ResetAt, Message: "daily download count exceeded"}
+		}
+		return nil, fmt.Errorf("download %s: got an HTML page instead of a subtitle", path)
+	}
+	return data, nil
+}
+
+// Fetch downloads the subtitle for mediaPath in lang, preferring corrected
+// versions whose release name shares the most words with the file name.
+func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	cands, err := c.SearchCandidates(ctx, mediaPath, lang)
+	if err != nil {
+		return nil, err
+	}
+	if len(cands) == 0 {
+		return nil, fmt.Errorf("no subtitles found")
+	}
+	words := strings.Fields(normalize(filepath.Base(mediaPath)))
+	best, bestScore := cands[0], -1
+	for _, cand := range cands {
+		score := 0
+		version := " " + normalize(cand.Release) + " "
+		for _, w := range words {
+			if strings.Contains(version, " "+w+" ") {
+				score += 2
+			}
+		}
+		if cand.Trusted {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = cand, score
+		}
 	}
-	return io.ReadAll(resp.Body)
+	return c.Download(ctx, best)
 }
@@ -2,31 +2,291 @@ package addic7ed
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/quota"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
-// TestClientFetch verifies that the client downloads subtitles using the expected URL.
-func TestClientFetch(t *testing.T) {
-	var gotURL string
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		gotURL = r.URL.String()
-		fmt.Fprint(w, "data")
-	}))
-	defer srv.Close()
+const episodePage = "/serie/The_Big_Bang_Theory/3/1/The_Electric_Can_Opener_Fluctuation"
+
+// solver records the site keys it was asked to solve.
+type solver struct{ siteKey string }
+
+func (s *solver) Solve(_ context.Context, siteKey, pageURL string) (string, error) {
+	s.siteKey = siteKey
+	return "solved", nil
+}
+
+// site serves the recorded pages in testdata like addic7ed.com does.
+type site struct {
+	*httptest.Server
+	downloads atomic.Int32
+}
 
+func serveFixture(t *testing.T, w http.ResponseWriter, name string) {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Errorf("fixture %s: %v", name, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	_, _ = w.Write(data)
+}
+
+func newSite(t *testing.T) *site {
+	t.Helper()
+	s := &site{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /shows.php", func(w http.ResponseWriter, r *http.Request) { serveFixture(t, w, "shows.html") })
+	mux.HandleFunc("GET /login.php", func(w http.ResponseWriter, r *http.Request) { serveFixture(t, w, "login.html") })
+	mux.HandleFunc("GET /downloadexceeded.php", func(w http.ResponseWriter, r *http.Request) { serveFixture(t, w, "downloadexceeded.html") })
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /dologin.php", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("username") != "user" || r.FormValue("recaptcha_response") != "solved" {
+			http.Redirect(w, r, "/login.php", http.StatusFound)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "wikisubtitlesuser", Value: "42", Path: "/"})
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	mux.HandleFunc("GET /ajax_loadShow.php", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("show") != "126" || r.URL.Query().Get("season") != "3" {
+			http.NotFound(w, r)
+			return
+		}
+		serveFixture(t, w, "season.html")
+	})
+	mux.HandleFunc("GET /updated/1/34568/0", func(w http.ResponseWriter, r *http.Request) {
+		// Downloads without the episode page as Referer are refused.
+		if r.Referer() != s.URL+episodePage {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		s.downloads.Add(1)
+		w.Header().Set("Content-Type", "text/srt")
+		_, _ = w.Write([]byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n"))
+	})
+	mux.HandleFunc("GET /original/34568/1", func(w http.ResponseWriter, r *http.Request) {
+		s.downloads.Add(1)
+		http.Redirect(w, r, "/downloadexceeded.php", http.StatusFound)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	t.Cleanup(func() {
+		accountsMu.Lock()
+		accounts = map[string]*account{}
+		accountsMu.Unlock()
+	})
+	return s
+}
+
+func (s *site) client(t *testing.T, user string) *Client {
+	t.Helper()
 	c := New()
-	c.APIURL = srv.URL
-	b, err := c.Fetch(context.Background(), "/path/movie.mkv", "en")
+	c.Solver = nil
+	if err := c.Configure(settings.Settings{APIURL: s.URL, Username: user, Password: "pass"}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	return c
+}
+
+// TestParseVersions verifies the season listing is split into versions.
+func TestParseVersions(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "season.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	versions, err := parseVersions(f)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(versions) != 5 {
+		t.Fatalf("expected 5 versions, got %d", len(versions))
+	}
+	want := version{
+		Season: 3, Episode: 1, Title: "The Electric Can Opener Fluctuation", Language: "English",
+		Version: "720p.HDTV.CTU", Completed: true, HI: true, HD: true,
+		Page: episodePage, Download: "/original/34568/1",
+	}
+	if versions[1] != want {
+		t.Fatalf("unexpected version %+v", versions[1])
+	}
+	if !versions[0].Corrected || versions[0].HI || versions[2].Completed {
+		t.Fatalf("flags not parsed: %+v", versions[:3])
+	}
+}
+
+// TestSearchCandidatesWithCaptchaLogin verifies the login solves the
+// captcha and that each completed version becomes a candidate.
+func TestSearchCandidatesWithCaptchaLogin(t *testing.T) {
+	s := newSite(t)
+	c := s.client(t, "user")
+	sv := &solver{}
+	c.Solver = sv
+
+	cands, err := c.SearchCandidates(context.Background(), "/tv/The.Big.Bang.Theory.S03E01.720p.HDTV.x264-CTU.mkv", "en")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if sv.siteKey != "6LeIxAcTAAAAAJcZVRqyHh71UMIEGNQ_MXjiZKhI" {
+		t.Fatalf("captcha not solved, site key %q", sv.siteKey)
+	}
+	if len(cands) != 2 {
+		t.Fatalf("expected 2 candidates, got %+v", cands)
+	}
+	if cands[0].Release != "the.big.bang.theory.S03E01.DIMENSION" || !cands[0].Trusted || cands[0].HearingImpaired {
+		t.Fatalf("unexpected candidate %+v", cands[0])
+	}
+	if cands[1].Release != "the.big.bang.theory.S03E01.720p.HDTV.CTU" || !cands[1].HearingImpaired {
+		t.Fatalf("unexpected candidate %+v", cands[1])
+	}
+
+	br, err := c.SearchCandidates(context.Background(), "The.Big.Bang.Theory.S03E01.mkv", "pt-BR")
+	if err != nil || len(br) != 1 {
+		t.Fatalf("expected the Brazilian version, got %+v, %v", br, err)
+	}
+
+	if _, err := s.client(t, "other").SearchCandidates(context.Background(), "The.Big.Bang.Theory.S03E01.mkv", "en"); err == nil || !strings.Contains(err.Error(), "captcha") {
+		t.Fatalf("expected captcha error without solver, got %v", err)
+	}
+	if _, err := c.SearchCandidates(context.Background(), "/movies/Movie.2020.mkv", "en"); err == nil {
+		t.Fatal("expected error for a movie")
+	}
+}
+
+// TestConcurrentSearchesShareLogin verifies concurrent searches of one
+// account log in and load the show list once.
+func TestConcurrentSearchesShareLogin(t *testing.T) {
+	s := newSite(t)
+	var logins, showLists atomic.Int32
+	inner := s.Config.Handler
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dologin.php":
+			logins.Add(1)
+			time.Sleep(20 * time.Millisecond)
+		case "/shows.php":
+			showLists.Add(1)
+			time.Sleep(20 * time.Millisecond)
+		}
+		inner.ServeHTTP(w, r)
+	})
+	c := s.client(t, "user")
+	c.Solver = &solver{}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.SearchCandidates(context.Background(), "The.Big.Bang.Theory.S03E01.mkv", "en")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+	}
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("expected one login, got %d", n)
+	}
+	if n := showLists.Load(); n != 1 {
+		t.Fatalf("expected one show list request, got %d", n)
+	}
+}
+
+// TestSharedLoginOutlivesCaller verifies a caller giving up does not fail
+// the login shared with the callers waiting on it.
+func TestSharedLoginOutlivesCaller(t *testing.T) {
+	s := newSite(t)
+	var logins atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	inner := s.Config.Handler
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dologin.php" {
+			if logins.Add(1) == 1 {
+				close(started)
+			}
+			<-release
+		}
+		inner.ServeHTTP(w, r)
+	})
+	c := s.client(t, "user")
+	c.Solver = &solver{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.SearchCandidates(ctx, "The.Big.Bang.Theory.S03E01.mkv", "en")
+		first <- err
+	}()
+	<-started
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.SearchCandidates(context.Background(), "The.Big.Bang.Theory.S03E01.mkv", "en")
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the first search to be cancelled, got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("expected one login, got %d", n)
+	}
+}
+
+// TestFetchSendsReferer verifies Fetch picks the version matching the file
+// name and downloads it with the episode page as Referer.
+func TestFetchSendsReferer(t *testing.T) {
+	s := newSite(t)
+	data, err := s.client(t, "").Fetch(context.Background(), "The.Big.Bang.Theory.S03E01.HDTV.XviD-DIMENSION.avi", "en")
 	if err != nil {
 		t.Fatalf("fetch: %v", err)
 	}
-	if string(b) != "data" {
-		t.Fatalf("unexpected body: %s", b)
+	if !strings.Contains(string(data), "Hello") {
+		t.Fatalf("unexpected body %q", data)
+	}
+}
+
+// TestDownloadCap verifies the daily download cap is reported and respected.
+func TestDownloadCap(t *testing.T) {
+	s := newSite(t)
+	c := s.client(t, "")
+	cand := candidate.Candidate{DownloadToken: "/original/34568/1|" + episodePage}
+
+	_, err := c.Download(context.Background(), cand)
+	var qe *quota.ExceededError
+	if !errors.As(err, &qe) || !qe.ResetAt.After(time.Now()) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if q, ok := c.Quota(); !ok || !q.Exhausted(time.Now()) {
+		t.Fatalf("quota not recorded: %+v", q)
+	}
+	if _, err := c.Download(context.Background(), cand); !errors.As(err, &qe) {
+		t.Fatalf("expected quota error, got %v", err)
 	}
-	if gotURL != "/subtitles/movie.mkv/en" {
-		t.Fatalf("unexpected url: %s", gotURL)
+	if n := s.downloads.Load(); n != 1 {
+		t.Fatalf("expected one download request, got %d", n)
 	}
 }
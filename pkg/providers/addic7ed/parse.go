@@ -0,0 +1,202 @@
+// file: pkg/providers/addic7ed/parse.go
+package addic7ed
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// version is one row of a season listing: a subtitle made for one release
+// version of an episode.
+type version struct {
+	Season    int
+	Episode   int
+	Title     string
+	Language  string
+	Version   string
+	Completed bool
+	HI        bool
+	Corrected bool
+	HD        bool
+	// Page is the episode page, sent as Referer when downloading.
+	Page string
+	// Download is the path of the subtitle file.
+	Download string
+}
+
+var showHref = regexp.MustCompile(`^/show/(\d+)$`)
+
+// parseShows returns the show IDs listed on the shows page keyed by
+// normalized show name.
+func parseShows(r io.Reader) (map[string]int, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	shows := make(map[string]int)
+	for _, a := range findAll(doc, "a") {
+		m := showHref.FindStringSubmatch(attr(a, "href"))
+		if m == nil {
+			continue
+		}
+		id, _ := strconv.Atoi(m[1])
+		if name := normalize(text(a)); name != "" {
+			shows[name] = id
+		}
+	}
+	return shows, nil
+}
+
+// parseVersions returns the rows of a season listing as loaded from
+// ajax_loadShow.php. The columns are season, episode, title, language,
+// version, completion, hearing impaired, corrected, HD and download link.
+func parseVersions(r io.Reader) ([]version, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	var out []version
+	for _, tr := range findAll(doc, "tr") {
+		if !strings.Contains(attr(tr, "class"), "epeven") {
+			continue
+		}
+		cells := children(tr, "td")
+		if len(cells) < 10 {
+			continue
+		}
+		season, err1 := strconv.Atoi(text(cells[0]))
+		episode, err2 := strconv.Atoi(text(cells[1]))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		v := version{
+			Season:    season,
+			Episode:   episode,
+			Title:     text(cells[2]),
+			Language:  text(cells[3]),
+			Version:   text(cells[4]),
+			Completed: strings.EqualFold(text(cells[5]), "Completed"),
+			HI:        flagged(cells[6]),
+			Corrected: flagged(cells[7]),
+			HD:        flagged(cells[8]),
+		}
+		if a := first(cells[2], "a"); a != nil {
+			v.Page = attr(a, "href")
+		}
+		if a := first(cells[9], "a"); a != nil {
+			v.Download = attr(a, "href")
+		}
+		if v.Download != "" {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// parseSiteKey returns the reCAPTCHA site key of a page, or "" when the page
+// has no captcha.
+func parseSiteKey(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	var key string
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode {
+			key = attr(n, "data-sitekey")
+		}
+		return key == ""
+	})
+	return key, nil
+}
+
+// flagged reports whether a yes/no cell is set, either by text or an icon.
+func flagged(n *html.Node) bool {
+	return text(n) != "" || first(n, "img") != nil
+}
+
+// nonWord matches the characters ignored when comparing show names.
+var nonWord = regexp.MustCompile(`[^\pL\pN]+`)
+
+// normalize lower-cases s and reduces punctuation to single spaces, so
+// "Marvel's Agents of S.H.I.E.L.D. (2013)" and
+// "marvels agents of s h i e l d 2013" compare equal.
+func normalize(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "'", "")
+	return strings.TrimSpace(nonWord.ReplaceAllString(s, " "))
+}
+
+// walk visits n and its descendants depth first until fn returns false.
+func walk(n *html.Node, fn func(*html.Node) bool) bool {
+	if !fn(n) {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !walk(c, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// findAll returns the descendants of n with the given tag.
+func findAll(n *html.Node, tag string) []*html.Node {
+	var out []*html.Node
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.Data == tag {
+			out = append(out, c)
+		}
+		return true
+	})
+	return out
+}
+
+// first returns the first descendant of n with the given tag.
+func first(n *html.Node, tag string) *html.Node {
+	var out *html.Node
+	walk(n, func(c *html.Node) bool {
+		if c != n && c.Type == html.ElementNode && c.Data == tag {
+			out = c
+		}
+		return out == nil
+	})
+	return out
+}
+
+// children returns the direct children of n with the given tag.
+func children(n *html.Node, tag string) []*html.Node {
+	var out []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// attr returns the value of attribute key of n.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// text returns the whitespace-collapsed text content of n.
+func text(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+			b.WriteByte(' ')
+		}
+		return true
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
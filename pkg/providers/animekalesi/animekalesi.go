@@ -7,24 +7,19 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
-	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // Client implements the providers.Provider interface for Animekalesi.
 // It performs a simple HTTP GET to download subtitles.
 type Client struct {
-	// APIURL is the base URL of the Animekalesi API.
-	APIURL string
-	// HTTPClient is used to make requests.
-	HTTPClient *http.Client
+	settings.HTTP
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
-	return &Client{
-		APIURL:     "https://api.animekalesi.com",
-		HTTPClient: &http.Client{Timeout: 15 * time.Second},
-	}
+	return &Client{HTTP: settings.NewHTTP("https://api.animekalesi.com")}
 }
 
 // Fetch downloads the subtitle for mediaPath in lang.
@@ -2,33 +2,216 @@
 package bsplayer
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"golang.org/x/text/language"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
+const (
+	// userAgent identifies the client as BSPlayer, which the API requires.
+	userAgent = "BSPlayer/2.x (1022.12360)"
+	// appID is sent when logging in.
+	appID = "BSPlayer v2.67"
+	// soapNamespace prefixes the SOAPAction of every call.
+	soapNamespace = "http://api.bsplayer-subtitles.com/v1.php"
+)
+
+// Client implements the providers.Provider and providers.CandidateSearcher
+// interfaces for the BSPlayer subtitle service. Its SOAP API looks
+// subtitles up by the OpenSubtitles hash and size of the video; each search
+// runs in its own session.
 type Client struct {
-	APIURL     string
+	// APIURL is the SOAP endpoint of the BSPlayer API.
+	APIURL string
+	// HTTPClient is used to make requests.
 	HTTPClient *http.Client
+
+	username string
+	password string
 }
 
+// New returns a Client configured with reasonable defaults.
 func New() *Client {
 	return &Client{
-		APIURL:     "https://api.bsplayer.com",
+		APIURL:     "http://s1.api.bsplayer-subtitles.com/v1.php",
 		HTTPClient: &http.Client{Timeout: 15 * time.Second},
 	}
 }
 
-func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
-	name := filepath.Base(mediaPath)
-	url := fmt.Sprintf("%s/subtitles/%s/%s", c.APIURL, name, lang)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := s.ApplyHTTP(&c.APIURL, &c.HTTPClient); err != nil {
+		return err
+	}
+	c.username = s.Username
+	c.password = s.Password
+	return nil
+}
+
+// item is a subtitle in a searchSubtitles response.
+type item struct {
+	ID     string `xml:"subID"`
+	Name   string `xml:"subName"`
+	Format string `xml:"subFormat"`
+	Link   string `xml:"subDownloadLink"`
+	Rating string `xml:"subRating"`
+}
+
+// response is the return value shared by all API calls. The data element
+// holds the session handle after logging in and the subtitles after a
+// search.
+type response struct {
+	Body struct {
+		Call struct {
+			Return struct {
+				Result struct {
+					Status string `xml:"status"`
+					Result string `xml:"result"`
+				} `xml:"result"`
+				Data struct {
+					Text  string `xml:",chardata"`
+					Items []item `xml:"item"`
+				} `xml:"data"`
+			} `xml:"return"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+// call invokes action with the given parameters, which are XML escaped.
+func (c *Client) call(ctx context.Context, action string, params [][2]string) (*response, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&body, `<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://schemas.xmlsoap.org/soap/envelope/" xmlns:SOAP-ENC="http://schemas.xmlsoap.org/soap/encoding/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:ns1="%s">`, soapNamespace)
+	fmt.Fprintf(&body, `<SOAP-ENV:Body SOAP-ENV:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><ns1:%s>`, action)
+	for _, p := range params {
+		fmt.Fprintf(&body, "<%s>", p[0])
+		if err := xml.EscapeText(&body, []byte(p[1])); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&body, "</%s>", p[0])
+	}
+	fmt.Fprintf(&body, "</ns1:%s></SOAP-ENV:Body></SOAP-ENV:Envelope>", action)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.APIURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", fmt.Sprintf("%q", soapNamespace+"#"+action))
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d", action, resp.StatusCode)
+	}
+	var r response
+	if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("%s: %w", action, err)
+	}
+	return &r, nil
+}
+
+// login opens a session and returns its handle.
+func (c *Client) login(ctx context.Context) (string, error) {
+	r, err := c.call(ctx, "logIn", [][2]string{{"username", c.username}, {"password", c.password}, {"AppID", appID}})
+	if err != nil {
+		return "", err
+	}
+	ret := r.Body.Call.Return
+	if ret.Result.Status != "OK" || strings.TrimSpace(ret.Data.Text) == "" {
+		return "", fmt.Errorf("login failed: result %s", ret.Result.Result)
+	}
+	return strings.TrimSpace(ret.Data.Text), nil
+}
+
+// SearchCandidates lists the subtitles matching the hash of mediaPath in
+// lang. Every result is a hash match.
+func (c *Client) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]candidate.Candidate, error) {
+	hash, size, err := mediahash.Hash(mediaPath, mediahash.OpenSubtitles)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := c.login(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		_, _ = c.call(context.WithoutCancel(ctx), "logOut", [][2]string{{"handle", handle}})
+	}()
+	r, err := c.call(ctx, "searchSubtitles", [][2]string{
+		{"handle", handle},
+		{"movieHash", hash},
+		{"movieSize", strconv.FormatInt(size, 10)},
+		{"languageId", languageID(lang)},
+		{"imdbId", "*"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Searches without a match report a failed status.
+	ret := r.Body.Call.Return
+	if ret.Result.Status != "OK" {
+		return nil, nil
+	}
+	var out []candidate.Candidate
+	for _, it := range ret.Data.Items {
+		rating, _ := strconv.ParseFloat(it.Rating, 64)
+		out = append(out, candidate.Candidate{
+			Provider:      "bsplayer",
+			ID:            it.ID,
+			Release:       strings.TrimSuffix(it.Name, filepath.Ext(it.Name)),
+			FileName:      it.Name,
+			Language:      lang,
+			Format:        strings.ToLower(it.Format),
+			Rating:        rating,
+			HashMatch:     true,
+			DownloadToken: it.Link,
+		})
+	}
+	return out, nil
+}
+
+// Download retrieves a candidate returned by SearchCandidates. Subtitles
+// are served gzip compressed. The download link must point to the BSPlayer
+// site of APIURL.
+func (c *Client) Download(ctx context.Context, cand candidate.Candidate) ([]byte, error) {
+	if cand.DownloadToken == "" {
+		return nil, fmt.Errorf("missing download link")
+	}
+	link, err := c.downloadURL(cand.DownloadToken)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -37,5 +220,76 @@ func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, err
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("status %d", resp.StatusCode)
 	}
-	return io.ReadAll(resp.Body)
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected download response: %w", err)
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// downloadURL checks that token is an HTTP link to the host of APIURL or
+// another host of the same site, such as a different API server.
+func (c *Client) downloadURL(token string) (string, error) {
+	api, err := url.Parse(c.APIURL)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(token)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil || !sameSite(u.Hostname(), api.Hostname()) {
+		return "", fmt.Errorf("invalid download token %q", token)
+	}
+	return u.String(), nil
+}
+
+// sameSite reports whether host is apiHost or, when apiHost is a domain name
+// below a registered domain, another host below that domain.
+func sameSite(host, apiHost string) bool {
+	host, apiHost = strings.ToLower(host), strings.ToLower(apiHost)
+	if host == apiHost {
+		return true
+	}
+	labels := strings.Split(apiHost, ".")
+	if net.ParseIP(apiHost) != nil || len(labels) < 3 {
+		return false
+	}
+	return strings.HasSuffix(host, "."+strings.Join(labels[len(labels)-2:], "."))
+}
+
+// Fetch downloads the best rated subtitle for mediaPath in lang.
+// It returns the subtitle bytes or an error.
+func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	cands, err := c.SearchCandidates(ctx, mediaPath, lang)
+	if err != nil {
+		return nil, err
+	}
+	if len(cands) == 0 {
+		return nil, fmt.Errorf("no subtitles found")
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].Rating > cands[j].Rating })
+	return c.Download(ctx, cands[0])
+}
+
+// bibliographic lists the ISO 639-2/B codes BSPlayer uses where they differ
+// from the terminology codes.
+var bibliographic = map[string]string{
+	"ces": "cze", "deu": "ger", "ell": "gre", "eus": "baq", "fas": "per",
+	"fra": "fre", "hye": "arm", "isl": "ice", "kat": "geo", "mkd": "mac",
+	"msa": "may", "mya": "bur", "nld": "dut", "ron": "rum", "slk": "slo",
+	"sqi": "alb", "zho": "chi",
+}
+
+// languageID returns the three letter code BSPlayer uses for lang, with
+// "pob" for Brazilian Portuguese.
+func languageID(lang string) string {
+	tag := language.Make(lang)
+	base, _ := tag.Base()
+	if region, conf := tag.Region(); base.String() == "pt" && conf == language.Exact && region.String() == "BR" {
+		return "pob"
+	}
+	code := base.ISO3()
+	if b, ok := bibliographic[code]; ok {
+		return b
+	}
+	return code
 }
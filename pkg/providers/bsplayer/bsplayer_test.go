@@ -1,31 +1,138 @@
 package bsplayer
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 )
 
-func TestClientFetch(t *testing.T) {
-	var gotURL string
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		gotURL = r.URL.String()
-		fmt.Fprint(w, "data")
+// envelope wraps a SOAP return value like the BSPlayer API does.
+func envelope(action, ret string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ns1="http://api.bsplayer-subtitles.com/v1.php">` +
+		`<SOAP-ENV:Body><ns1:` + action + `Response><return>` + ret + `</return></ns1:` + action + `Response></SOAP-ENV:Body></SOAP-ENV:Envelope>`
+}
+
+// newStandIn emulates the SOAP endpoint and the gzip compressed downloads.
+// It records the searchSubtitles request body and counts open sessions.
+func newStandIn(t *testing.T, search *string, sessions *atomic.Int32) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			fmt.Fprintf(zw, "subtitle %s", strings.TrimPrefix(r.URL.Path, "/dl/"))
+			zw.Close()
+			_, _ = w.Write(buf.Bytes())
+			return
+		}
+		if r.Header.Get("User-Agent") != userAgent {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		action := strings.TrimSuffix(strings.TrimPrefix(r.Header.Get("SOAPAction"), `"`+soapNamespace+"#"), `"`)
+		switch action {
+		case "logIn":
+			sessions.Add(1)
+			fmt.Fprint(w, envelope(action, `<result><result>200</result><status>OK</status></result><data>handle-1</data>`))
+		case "searchSubtitles":
+			*search = string(body)
+			if !strings.Contains(*search, "<languageId>eng</languageId>") {
+				fmt.Fprint(w, envelope(action, `<result><result>402</result><status>Failed</status></result><data></data>`))
+				return
+			}
+			fmt.Fprint(w, envelope(action, fmt.Sprintf(`<result><result>200</result><status>OK</status></result><data>`+
+				`<item><subID>1</subID><subName>Movie.2020.720p.srt</subName><subLang>eng</subLang><subFormat>SRT</subFormat><subDownloadLink>%[1]s/dl/1</subDownloadLink><subRating>4</subRating></item>`+
+				`<item><subID>2</subID><subName>Movie.2020.1080p.srt</subName><subLang>eng</subLang><subFormat>SRT</subFormat><subDownloadLink>%[1]s/dl/2</subDownloadLink><subRating>9</subRating></item>`+
+				`</data>`, srv.URL)))
+		case "logOut":
+			sessions.Add(-1)
+			fmt.Fprint(w, envelope(action, `<result><result>200</result><status>OK</status></result>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
 	}))
-	defer srv.Close()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestClientFetch verifies the hash search over SOAP, the session handling
+// and the gzip compressed download of the best rated subtitle.
+func TestClientFetch(t *testing.T) {
+	var search string
+	var sessions atomic.Int32
+	srv := newStandIn(t, &search, &sessions)
 
+	media := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.WriteFile(media, []byte{1, 0, 0, 0, 0, 0, 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
 	c := New()
 	c.APIURL = srv.URL
-	b, err := c.Fetch(context.Background(), "/path/movie.mkv", "en")
+	b, err := c.Fetch(context.Background(), media, "en")
 	if err != nil {
 		t.Fatalf("fetch: %v", err)
 	}
-	if string(b) != "data" {
+	if string(b) != "subtitle 2" {
 		t.Fatalf("unexpected body: %s", b)
 	}
-	if gotURL != "/subtitles/movie.mkv/en" {
-		t.Fatalf("unexpected url: %s", gotURL)
+	for _, want := range []string{"<handle>handle-1</handle>", "<movieHash>000000000000000a</movieHash>", "<movieSize>8</movieSize>"} {
+		if !strings.Contains(search, want) {
+			t.Fatalf("search request lacks %s: %s", want, search)
+		}
+	}
+	if n := sessions.Load(); n != 0 {
+		t.Fatalf("expected sessions to be closed, %d open", n)
+	}
+
+	cands, err := c.SearchCandidates(context.Background(), media, "de")
+	if err != nil || len(cands) != 0 {
+		t.Fatalf("expected no candidates, got %+v, %v", cands, err)
+	}
+	if !strings.Contains(search, "<languageId>ger</languageId>") {
+		t.Fatalf("unexpected language: %s", search)
+	}
+}
+
+// TestLanguageID verifies the language codes sent to the API.
+func TestLanguageID(t *testing.T) {
+	for lang, want := range map[string]string{"en": "eng", "fr": "fre", "pt": "por", "pt-BR": "pob", "zh": "chi"} {
+		if got := languageID(lang); got != want {
+			t.Errorf("languageID(%s) = %s, want %s", lang, got, want)
+		}
+	}
+}
+
+// TestDownloadRejectsForeignLinks verifies only links to the BSPlayer site
+// are downloaded.
+func TestDownloadRejectsForeignLinks(t *testing.T) {
+	c := New()
+	for _, token := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost:8080/admin",
+		"http://bsplayer-subtitles.com.evil.example/dl/1",
+		"http://user@s2.api.bsplayer-subtitles.com/dl/1",
+		"file:///etc/passwd",
+		"/dl/1",
+	} {
+		if _, err := c.downloadURL(token); err == nil {
+			t.Errorf("download link %q accepted", token)
+		}
+	}
+	for _, token := range []string{"http://s1.api.bsplayer-subtitles.com/dl/1", "https://s7.api.bsplayer-subtitles.com/dl/1"} {
+		if _, err := c.downloadURL(token); err != nil {
+			t.Errorf("download link %q rejected: %v", token, err)
+		}
 	}
 }
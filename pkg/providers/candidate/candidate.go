@@ -0,0 +1,47 @@
+// file: pkg/providers/candidate/candidate.go
+
+// Package candidate defines the structured subtitle search result shared by
+// provider implementations. It has no dependencies on other provider
+// packages so individual providers can import it without creating cycles
+// with the provider registry.
+package candidate
+
+import "time"
+
+// Candidate describes a single subtitle offered by a provider. Providers fill
+// whatever metadata their API exposes and leave the rest zero.
+type Candidate struct {
+	// Provider is the registry name of the provider that produced the result.
+	Provider string `json:"provider"`
+	// ID is the provider's identifier for the subtitle, if any.
+	ID string `json:"id,omitempty"`
+	// Release is the release name the subtitle was made for.
+	Release string `json:"release"`
+	// FileName is the subtitle file name as reported by the provider.
+	FileName string `json:"fileName,omitempty"`
+	// Language is the subtitle language code.
+	Language string `json:"language"`
+	// HearingImpaired marks SDH/HI subtitles.
+	HearingImpaired bool `json:"hearingImpaired"`
+	// Forced marks subtitles that only cover foreign parts.
+	Forced bool `json:"forced"`
+	// Format is the subtitle format, for example "srt" or "ass".
+	Format string `json:"format"`
+	// UploadDate is when the subtitle was published.
+	UploadDate time.Time `json:"uploadDate,omitempty"`
+	// Downloads is the provider's download counter.
+	Downloads int `json:"downloads"`
+	// Rating is the provider rating on a 0-10 scale.
+	Rating float64 `json:"rating"`
+	// Votes is the number of ratings behind Rating.
+	Votes int `json:"votes"`
+	// HashMatch reports that the provider matched the media file hash.
+	HashMatch bool `json:"hashMatch"`
+	// Trusted marks results from trusted uploaders.
+	Trusted bool `json:"trusted"`
+	// MachineTranslated marks automatic or machine translations.
+	MachineTranslated bool `json:"machineTranslated"`
+	// DownloadToken is an opaque value interpreted only by the provider that
+	// produced the candidate when downloading it.
+	DownloadToken string `json:"downloadToken"`
+}
@@ -0,0 +1,107 @@
+// file: pkg/providers/candidates.go
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrSearchUnsupported is returned when a provider exposes neither
+// CandidateSearcher nor Searcher.
+var ErrSearchUnsupported = errors.New("provider does not support search")
+
+// candidateHTTPClient downloads candidates produced from plain URL searches.
+var candidateHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// SearchCandidates returns structured search results from p. Providers that
+// implement CandidateSearcher are queried directly. URL-only Searchers are
+// wrapped so each link becomes a candidate whose release name and format are
+// derived from the URL and whose download token is the URL itself.
+func SearchCandidates(ctx context.Context, p Provider, name, mediaPath, lang string) ([]Candidate, error) {
+	if cs, ok := p.(CandidateSearcher); ok {
+		cands, err := cs.SearchCandidates(ctx, mediaPath, lang)
+		if err != nil {
+			return nil, err
+		}
+		for i := range cands {
+			if cands[i].Provider == "" {
+				cands[i].Provider = name
+			}
+			if cands[i].Language == "" {
+				cands[i].Language = lang
+			}
+		}
+		return cands, nil
+	}
+	s, ok := p.(Searcher)
+	if !ok {
+		return nil, ErrSearchUnsupported
+	}
+	urls, err := s.Search(ctx, mediaPath, lang)
+	if err != nil {
+		return nil, err
+	}
+	cands := make([]Candidate, 0, len(urls))
+	for _, u := range urls {
+		if u == "" {
+			continue
+		}
+		file := urlFileName(u)
+		ext := strings.TrimPrefix(strings.ToLower(path.Ext(file)), ".")
+		cands = append(cands, Candidate{
+			Provider:      name,
+			Release:       strings.TrimSuffix(file, path.Ext(file)),
+			FileName:      file,
+			Language:      lang,
+			Format:        ext,
+			DownloadToken: u,
+		})
+	}
+	return cands, nil
+}
+
+// DownloadCandidate returns the subtitle bytes for c using p. Candidates from
+// CandidateSearcher providers are passed back to the provider, whose quota is
+// then recorded in the status of c.Provider; candidates wrapped from URL
+// searches are fetched with a plain GET request.
+func DownloadCandidate(ctx context.Context, p Provider, c Candidate) ([]byte, error) {
+	if cs, ok := p.(CandidateSearcher); ok {
+		data, err := cs.Download(ctx, c)
+		RecordQuota(c.Provider, p)
+		return data, err
+	}
+	if c.DownloadToken == "" {
+		return nil, fmt.Errorf("candidate from %s has no download token", c.Provider)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.DownloadToken, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := candidateHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// urlFileName returns the last path element of raw without query parameters.
+func urlFileName(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	return path.Base(raw)
+}
@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// urlProvider is a Provider that only implements the URL Searcher interface.
+type urlProvider struct{ urls []string }
+
+func (urlProvider) Fetch(context.Context, string, string) ([]byte, error) { return nil, nil }
+
+func (p urlProvider) Search(context.Context, string, string) ([]string, error) { return p.urls, nil }
+
+// structuredProvider implements CandidateSearcher.
+type structuredProvider struct{}
+
+func (structuredProvider) Fetch(context.Context, string, string) ([]byte, error) { return nil, nil }
+
+func (structuredProvider) SearchCandidates(context.Context, string, string) ([]Candidate, error) {
+	return []Candidate{{Release: "Show.S01E01.WEB", DownloadToken: "42"}}, nil
+}
+
+func (structuredProvider) Download(_ context.Context, c Candidate) ([]byte, error) {
+	return []byte("token " + c.DownloadToken), nil
+}
+
+// fetchOnlyProvider implements neither search interface.
+type fetchOnlyProvider struct{}
+
+func (fetchOnlyProvider) Fetch(context.Context, string, string) ([]byte, error) { return nil, nil }
+
+func TestSearchCandidatesWrapsURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "subtitle data")
+	}))
+	defer srv.Close()
+
+	p := urlProvider{urls: []string{srv.URL + "/subs/Movie.2020.1080p.ass?x=1"}}
+	cands, err := SearchCandidates(context.Background(), p, "plain", "movie.mkv", "en")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(cands) != 1 {
+		t.Fatalf("expected 1 candidate, got %d", len(cands))
+	}
+	c := cands[0]
+	if c.Provider != "plain" || c.Language != "en" || c.Release != "Movie.2020.1080p" || c.Format != "ass" {
+		t.Fatalf("unexpected candidate %+v", c)
+	}
+
+	data, err := DownloadCandidate(context.Background(), p, c)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if string(data) != "subtitle data" {
+		t.Fatalf("unexpected data %q", data)
+	}
+}
+
+func TestSearchCandidatesStructured(t *testing.T) {
+	p := structuredProvider{}
+	cands, err := SearchCandidates(context.Background(), p, "structured", "movie.mkv", "fr")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(cands) != 1 || cands[0].Provider != "structured" || cands[0].Language != "fr" {
+		t.Fatalf("unexpected candidates %+v", cands)
+	}
+	data, err := DownloadCandidate(context.Background(), p, cands[0])
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if string(data) != "token 42" {
+		t.Fatalf("unexpected data %q", data)
+	}
+}
+
+func TestSearchCandidatesUnsupported(t *testing.T) {
+	_, err := SearchCandidates(context.Background(), fetchOnlyProvider{}, "x", "movie.mkv", "en")
+	if !errors.Is(err, ErrSearchUnsupported) {
+		t.Fatalf("expected ErrSearchUnsupported, got %v", err)
+	}
+}
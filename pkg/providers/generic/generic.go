@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // Client implements the providers.Provider interface using a configurable
@@ -36,6 +38,29 @@ func New() *Client {
 	}
 }
 
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password, settings.APIKey).Required(settings.KeyAPIURL)
+}
+
+// Configure applies the provider settings. Empty credentials keep the
+// values read by New.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := s.ApplyHTTP(&c.APIURL, &c.HTTPClient); err != nil {
+		return err
+	}
+	if s.Username != "" {
+		c.Username = s.Username
+	}
+	if s.Password != "" {
+		c.Password = s.Password
+	}
+	if s.APIKey != "" {
+		c.APIKey = s.APIKey
+	}
+	return nil
+}
+
 // Fetch retrieves a subtitle using the configured endpoint. Query parameters
 // include the file name, language and optional credentials.
 func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -48,12 +50,12 @@ func TestClientFetch(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := &Client{
+		client := &Client{HTTP: settings.HTTP{
 			APIURL: server.URL,
 			HTTPClient: &http.Client{
 				Transport: server.Client().Transport,
 			},
-		}
+		}}
 
 		data, err := client.Fetch(ctx, "/media/movie.mkv", "en")
 		if err != nil {
@@ -66,10 +68,7 @@ func TestClientFetch(t *testing.T) {
 	})
 
 	t.Run("invalid base url", func(t *testing.T) {
-		client := &Client{
-			APIURL:     "http://[::1",
-			HTTPClient: &http.Client{},
-		}
+		client := &Client{HTTP: settings.HTTP{APIURL: "http://[::1", HTTPClient: &http.Client{}}}
 
 		_, err := client.Fetch(ctx, "/media/movie.mkv", "en")
 		if err == nil {
@@ -79,14 +78,14 @@ func TestClientFetch(t *testing.T) {
 
 	t.Run("transport error", func(t *testing.T) {
 		transportErr := errors.New("transport failure")
-		client := &Client{
+		client := &Client{HTTP: settings.HTTP{
 			APIURL: "https://example.invalid",
 			HTTPClient: &http.Client{
 				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
 					return nil, transportErr
 				}),
 			},
-		}
+		}}
 
 		_, err := client.Fetch(ctx, "/media/movie.mkv", "en")
 		if !errors.Is(err, transportErr) {
@@ -101,12 +100,12 @@ func TestClientFetch(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := &Client{
+		client := &Client{HTTP: settings.HTTP{
 			APIURL: server.URL,
 			HTTPClient: &http.Client{
 				Transport: server.Client().Transport,
 			},
-		}
+		}}
 
 		_, err := client.Fetch(ctx, "/media/movie.mkv", "en")
 		if err == nil {
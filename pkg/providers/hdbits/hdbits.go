@@ -7,24 +7,41 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
-	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // Client implements the providers.Provider interface for Hdbits.
 // It performs a simple HTTP GET to download subtitles.
 type Client struct {
-	// APIURL is the base URL of the Hdbits API.
-	APIURL string
-	// HTTPClient is used to make requests.
-	HTTPClient *http.Client
+	settings.HTTP
+
+	username string
+	passkey  string
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
-	return &Client{
-		APIURL:     "https://api.hdbits.com",
-		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	return &Client{HTTP: settings.NewHTTP("https://api.hdbits.com")}
+}
+
+// passkey is the HDBits passkey, which authenticates API requests in place
+// of the account password.
+var passkey = settings.Field{Key: settings.KeyAPIKey, Label: "Passkey", Type: settings.TypeSecret, Help: "Shown on the HDBits profile page"}
+
+// SettingsSchema declares the settings the provider accepts. HDBits only
+// serves members, so the username and passkey are required.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, passkey).Required(settings.KeyUsername, settings.KeyAPIKey)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := c.HTTP.Configure(s); err != nil {
+		return err
 	}
+	c.username, c.passkey = s.Username, s.APIKey
+	return nil
 }
 
 // Fetch downloads the subtitle for mediaPath in lang.
@@ -36,6 +53,12 @@ func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, err
 	if err != nil {
 		return nil, err
 	}
+	if c.username != "" {
+		q := req.URL.Query()
+		q.Set("username", c.username)
+		q.Set("passkey", c.passkey)
+		req.URL.RawQuery = q.Encode()
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -9,6 +9,7 @@ import (
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/mocks"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/quota"
 	"github.com/jdfalk/subtitle-manager/pkg/tagging"
 	"github.com/jdfalk/subtitle-manager/pkg/testutil"
 	"github.com/stretchr/testify/mock"
@@ -228,3 +229,49 @@ func TestFetchFromTagged(t *testing.T) {
 		t.Fatalf("unexpected result %s %s", data, id)
 	}
 }
+
+// quotaProvider reports a fixed download quota.
+type quotaProvider struct {
+	q   quota.Quota
+	err error
+}
+
+func (p quotaProvider) Fetch(context.Context, string, string) ([]byte, error) { return nil, p.err }
+func (p quotaProvider) Quota() (quota.Quota, bool)                            { return p.q, true }
+
+// TestQuotaBackoff verifies an exceeded quota backs the instance off until
+// the reset time and is surfaced in the provider status.
+func TestQuotaBackoff(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	p := quotaProvider{
+		q:   quota.Quota{Allowed: 20, Remaining: 0, ResetAt: reset, UpdatedAt: time.Now()},
+		err: &quota.ExceededError{Provider: "quota", ResetAt: reset},
+	}
+	RegisterFactory("quota", func() Provider { return p })
+	RegisterInstance(Instance{ID: "quota1", Name: "quota", Enabled: true})
+	t.Cleanup(func() {
+		delete(factories, "quota")
+		instancesMu.Lock()
+		delete(instances, "quota1")
+		instancesMu.Unlock()
+		ClearBackoff("quota1")
+		Reset()
+	})
+
+	if _, _, err := FetchFromAll(context.Background(), "movie.mkv", "en", ""); err == nil {
+		t.Fatal("expected error")
+	}
+	backoffMu.RLock()
+	until := backoffMap["quota1"]
+	backoffMu.RUnlock()
+	if until.Before(reset.Add(-time.Minute)) {
+		t.Fatalf("backoff until %v, want quota reset %v", until, reset)
+	}
+	st := List()["quota"]
+	if st.Available || st.Quota == nil || st.Quota.Allowed != 20 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	if BackoffOnQuota("quota1", errors.New("other")) {
+		t.Fatal("plain errors are not quota errors")
+	}
+}
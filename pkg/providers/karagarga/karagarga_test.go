@@ -1,5 +1,5 @@
 // file: pkg/providers/karagarga/karagarga_test.go
-// version: 1.0.1
+// version: 1.1.0
 // guid: 2b8c087d-3a55-4e1b-bcb7-3f03ef68698b
 package karagarga
 
@@ -11,6 +11,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 type roundTripFunc func(req *http.Request) (*http.Response, error)
@@ -36,10 +38,7 @@ func TestFetch_Success_ReturnsBody(t *testing.T) {
 	}))
 	t.Cleanup(server.Close)
 
-	client := &Client{
-		APIURL:     server.URL,
-		HTTPClient: server.Client(),
-	}
+	client := &Client{HTTP: settings.HTTP{APIURL: server.URL, HTTPClient: server.Client()}}
 
 	// Act
 	body, err := client.Fetch(context.Background(), mediaPath, lang)
@@ -60,10 +59,7 @@ func TestFetch_StatusNotOK_ReturnsError(t *testing.T) {
 	}))
 	t.Cleanup(server.Close)
 
-	client := &Client{
-		APIURL:     server.URL,
-		HTTPClient: server.Client(),
-	}
+	client := &Client{HTTP: settings.HTTP{APIURL: server.URL, HTTPClient: server.Client()}}
 
 	// Act
 	_, err := client.Fetch(context.Background(), "movie.mkv", "en")
@@ -79,10 +75,7 @@ func TestFetch_StatusNotOK_ReturnsError(t *testing.T) {
 
 func TestFetch_RequestCreationFails_ReturnsError(t *testing.T) {
 	// Arrange
-	client := &Client{
-		APIURL:     "http://[::1",
-		HTTPClient: &http.Client{},
-	}
+	client := &Client{HTTP: settings.HTTP{APIURL: "http://[::1", HTTPClient: &http.Client{}}}
 
 	// Act
 	_, err := client.Fetch(context.Background(), "movie.mkv", "en")
@@ -95,14 +88,14 @@ func TestFetch_RequestCreationFails_ReturnsError(t *testing.T) {
 
 func TestFetch_HTTPClientFails_ReturnsError(t *testing.T) {
 	// Arrange
-	client := &Client{
+	client := &Client{HTTP: settings.HTTP{
 		APIURL: "https://example.test",
 		HTTPClient: &http.Client{
 			Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
 				return nil, errors.New("transport failure")
 			}),
 		},
-	}
+	}}
 
 	// Act
 	_, err := client.Fetch(context.Background(), "movie.mkv", "en")
@@ -115,3 +108,26 @@ func TestFetch_HTTPClientFails_ReturnsError(t *testing.T) {
 		t.Fatalf("expected transport failure, got %v", err)
 	}
 }
+
+func TestConfigure_SendsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "member" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, "subtitle")
+	}))
+	t.Cleanup(server.Close)
+
+	client := New()
+	if err := client.SettingsSchema().Validate(map[string]any{"username": "member"}, true); err == nil {
+		t.Fatal("expected the password to be required")
+	}
+	if err := client.Configure(settings.Settings{APIURL: server.URL, Username: "member", Password: "secret"}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	body, err := client.Fetch(context.Background(), "movie.mkv", "en")
+	if err != nil || string(body) != "subtitle" {
+		t.Fatalf("unexpected result %q, %v", body, err)
+	}
+}
@@ -7,24 +7,37 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
-	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // Client implements the providers.Provider interface for Ktuvit.
 // It performs a simple HTTP GET to download subtitles.
 type Client struct {
-	// APIURL is the base URL of the Ktuvit API.
-	APIURL string
-	// HTTPClient is used to make requests.
-	HTTPClient *http.Client
+	settings.HTTP
+
+	username string
+	password string
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
-	return &Client{
-		APIURL:     "https://api.ktuvit.com",
-		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	return &Client{HTTP: settings.NewHTTP("https://api.ktuvit.com")}
+}
+
+// SettingsSchema declares the settings the provider accepts. Ktuvit only
+// serves members, so the username and password are required.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password).Required(settings.KeyUsername, settings.KeyPassword)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := c.HTTP.Configure(s); err != nil {
+		return err
 	}
+	c.username, c.password = s.Username, s.Password
+	return nil
 }
 
 // Fetch downloads the subtitle for mediaPath in lang.
@@ -36,6 +49,9 @@ func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, err
 	if err != nil {
 		return nil, err
 	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
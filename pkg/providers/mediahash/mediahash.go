@@ -0,0 +1,215 @@
+// file: pkg/providers/mediahash/mediahash.go
+// version: 1.1.1
+// guid: e9d2bd38-ce05-4fc7-94e2-66210226cd05
+
+// Package mediahash computes the content hashes that hash based subtitle
+// providers search by. Reading a video is expensive, so each hash is
+// computed once per file: results are cached in memory for the most recently
+// hashed files and, when a Store is set, in the media record, and reused
+// until the file size or modification time changes.
+package mediahash
+
+import (
+	"container/list"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	// OpenSubtitles is the 64-bit OpenSubtitles hash as 16 hexadecimal
+	// digits: the file size plus the little endian words of the first and
+	// last 64 KiB. OpenSubtitles, BSPlayer and Napisy24 search by it.
+	OpenSubtitles = "opensubtitles"
+	// NapiProjekt is the MD5 of the first 10 MiB as 32 hexadecimal digits.
+	NapiProjekt = "napiprojekt"
+)
+
+const (
+	// chunk is the size of the head and tail blocks of the OpenSubtitles hash.
+	chunk = 64 * 1024
+	// napiSize is the length of the prefix NapiProjekt hashes.
+	napiSize = 10 * 1024 * 1024
+	// stampKey holds the size and modification time the stored hashes were
+	// computed for.
+	stampKey = "stamp"
+	// cacheSize bounds the number of files whose hashes are kept in memory.
+	cacheSize = 1024
+)
+
+// Store persists hashes in the media record. database.SubtitleStore
+// satisfies it.
+type Store interface {
+	GetMediaHashes(path string) (map[string]string, error)
+	SetMediaHashes(path string, hashes map[string]string) error
+}
+
+// cached is the in-memory cache entry of a file.
+type cached struct {
+	path   string
+	hashes map[string]string
+}
+
+var (
+	mu    sync.Mutex
+	store Store
+	// cache indexes the elements of recent, which holds the cached entries
+	// from the most to the least recently used.
+	cache  = map[string]*list.Element{}
+	recent = list.New()
+)
+
+// SetStore sets the store hashes are persisted in. A nil store keeps them in
+// memory only.
+func SetStore(s Store) {
+	mu.Lock()
+	store = s
+	mu.Unlock()
+}
+
+// Reset drops the hashes cached in memory.
+func Reset() {
+	mu.Lock()
+	cache = map[string]*list.Element{}
+	recent.Init()
+	mu.Unlock()
+}
+
+// lookup returns the hashes cached for path and marks them recently used.
+// mu must be held.
+func lookup(path string) map[string]string {
+	e, ok := cache[path]
+	if !ok {
+		return nil
+	}
+	recent.MoveToFront(e)
+	return e.Value.(*cached).hashes
+}
+
+// keep caches the hashes of path, evicting the least recently used entry
+// when the cache is full. mu must be held.
+func keep(path string, hashes map[string]string) {
+	if e, ok := cache[path]; ok {
+		e.Value.(*cached).hashes = hashes
+		recent.MoveToFront(e)
+		return
+	}
+	cache[path] = recent.PushFront(&cached{path: path, hashes: hashes})
+	if recent.Len() > cacheSize {
+		oldest := recent.Back()
+		recent.Remove(oldest)
+		delete(cache, oldest.Value.(*cached).path)
+	}
+}
+
+// Hash returns the algo hash and the size of the file at path. The hash is
+// taken from the cache or the media record when the file is unchanged and
+// computed and recorded otherwise.
+func Hash(path, algo string) (string, int64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	size := fi.Size()
+	stamp := strconv.FormatInt(size, 10) + ":" + strconv.FormatInt(fi.ModTime().UnixNano(), 10)
+
+	mu.Lock()
+	hashes := lookup(path)
+	s := store
+	mu.Unlock()
+	if hashes[stampKey] != stamp && s != nil {
+		if stored, err := s.GetMediaHashes(path); err == nil {
+			hashes = stored
+		}
+	}
+	if hashes[stampKey] != stamp {
+		hashes = nil
+	}
+	if h, ok := hashes[algo]; ok {
+		mu.Lock()
+		keep(path, hashes)
+		mu.Unlock()
+		return h, size, nil
+	}
+
+	h, err := Compute(path, algo)
+	if err != nil {
+		return "", 0, err
+	}
+	updated := map[string]string{stampKey: stamp, algo: h}
+	for k, v := range hashes {
+		if _, ok := updated[k]; !ok {
+			updated[k] = v
+		}
+	}
+	mu.Lock()
+	keep(path, updated)
+	mu.Unlock()
+	if s != nil {
+		// The media record may not exist yet; the in-memory cache still
+		// spares the next lookup.
+		_ = s.SetMediaHashes(path, updated)
+	}
+	return h, size, nil
+}
+
+// Compute calculates the algo hash of the file at path without consulting
+// the cache.
+func Compute(path, algo string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	switch algo {
+	case OpenSubtitles:
+		return openSubtitles(f)
+	case NapiProjekt:
+		return napiProjekt(f)
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %q", algo)
+	}
+}
+
+// openSubtitles computes the OpenSubtitles hash. Files shorter than a block
+// are zero padded.
+func openSubtitles(f *os.File) (string, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := fi.Size()
+	h := uint64(size)
+	buf := make([]byte, chunk)
+	sum := func(off int64) error {
+		clear(buf)
+		if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+			return err
+		}
+		for i := 0; i < chunk; i += 8 {
+			h += binary.LittleEndian.Uint64(buf[i:])
+		}
+		return nil
+	}
+	if err := sum(0); err != nil {
+		return "", err
+	}
+	if err := sum(max(size-chunk, 0)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%016x", h), nil
+}
+
+// napiProjekt computes the MD5 of the first 10 MiB of f.
+func napiProjekt(f *os.File) (string, error) {
+	h := md5.New()
+	if _, err := io.CopyN(h, f, napiSize); err != nil && err != io.EOF {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,122 @@
+package mediahash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// memStore records the hashes stored per path.
+type memStore struct {
+	hashes map[string]map[string]string
+	sets   int
+}
+
+func (m *memStore) GetMediaHashes(path string) (map[string]string, error) {
+	return m.hashes[path], nil
+}
+
+func (m *memStore) SetMediaHashes(path string, hashes map[string]string) error {
+	m.hashes[path] = hashes
+	m.sets++
+	return nil
+}
+
+// TestCompute verifies both algorithms against known values.
+func TestCompute(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.mkv")
+	if err := os.WriteFile(small, []byte{1, 0, 0, 0, 0, 0, 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := Compute(small, OpenSubtitles); err != nil || h != "000000000000000a" {
+		t.Fatalf("opensubtitles hash %q, %v", h, err)
+	}
+	if h, err := Compute(small, NapiProjekt); err != nil || h != "33cdeccccebe80329f1fdbee7f5874cb" {
+		t.Fatalf("napiprojekt hash %q, %v", h, err)
+	}
+
+	// Only the first 10 MiB count for NapiProjekt.
+	large := filepath.Join(dir, "large.mkv")
+	if err := os.WriteFile(large, make([]byte, 11*1024*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := Compute(large, NapiProjekt); err != nil || h != "f1c9645dbc14efddc7d8a322685f26eb" {
+		t.Fatalf("napiprojekt hash %q, %v", h, err)
+	}
+	if _, err := Compute(small, "crc"); err == nil {
+		t.Fatal("expected error for unknown algorithm")
+	}
+}
+
+// TestHashCachedInStore verifies a hash is computed once, reused from the
+// media record by later runs and recomputed when the file changes.
+func TestHashCachedInStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.WriteFile(path, []byte{1, 0, 0, 0, 0, 0, 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	st := &memStore{hashes: map[string]map[string]string{}}
+	SetStore(st)
+	t.Cleanup(func() { SetStore(nil); Reset() })
+
+	h, size, err := Hash(path, OpenSubtitles)
+	if err != nil || h != "000000000000000a" || size != 8 {
+		t.Fatalf("hash %q size %d, %v", h, size, err)
+	}
+	if _, _, err := Hash(path, NapiProjekt); err != nil {
+		t.Fatal(err)
+	}
+	if st.sets != 2 || len(st.hashes[path]) != 3 {
+		t.Fatalf("unexpected stored hashes %v after %d sets", st.hashes[path], st.sets)
+	}
+
+	// A new process starts with an empty cache and reads the media record.
+	Reset()
+	st.hashes[path][OpenSubtitles] = "from-store"
+	if h, _, _ := Hash(path, OpenSubtitles); h != "from-store" || st.sets != 2 {
+		t.Fatalf("expected the stored hash, got %q", h)
+	}
+
+	// Rewriting the file invalidates every stored hash.
+	if err := os.WriteFile(path, []byte{2, 0, 0, 0, 0, 0, 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if h, _, _ := Hash(path, OpenSubtitles); h != "000000000000000c" {
+		t.Fatalf("expected a recomputed hash, got %q", h)
+	}
+	if _, ok := st.hashes[path][NapiProjekt]; ok {
+		t.Fatalf("stale hash kept: %v", st.hashes[path])
+	}
+}
+
+// TestCacheBounded verifies the in-memory cache keeps only the most
+// recently used files.
+func TestCacheBounded(t *testing.T) {
+	t.Cleanup(Reset)
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range cacheSize {
+		keep(fmt.Sprintf("/media/%d.mkv", i), map[string]string{stampKey: "1:1"})
+	}
+	// Using the oldest entry spares it from eviction.
+	if lookup("/media/0.mkv") == nil {
+		t.Fatal("oldest entry missing")
+	}
+	keep("/media/new.mkv", map[string]string{stampKey: "1:1"})
+	if len(cache) != cacheSize || recent.Len() != cacheSize {
+		t.Fatalf("cache holds %d entries, want %d", len(cache), cacheSize)
+	}
+	if lookup("/media/1.mkv") != nil {
+		t.Fatal("least recently used entry not evicted")
+	}
+	if lookup("/media/0.mkv") == nil || lookup("/media/new.mkv") == nil {
+		t.Fatal("recently used entries evicted")
+	}
+}
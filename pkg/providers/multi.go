@@ -5,32 +5,47 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jdfalk/subtitle-manager/pkg/archive"
 	"github.com/jdfalk/subtitle-manager/pkg/events"
 )
 
+// FetchFunc obtains a subtitle from provider p, registered under name.
+type FetchFunc func(ctx context.Context, p Provider, name string) ([]byte, error)
+
 // FetchFromAll tries each known provider in order until one returns a subtitle.
 // It uses an increasing delay between provider attempts to avoid rapid retries.
 // The provider API key is reused when applicable. The name of the provider that
-// succeeded is returned along with the subtitle bytes.
+// succeeded is returned along with the subtitle bytes. Archives are unpacked
+// to the entry matching mediaPath.
 func FetchFromAll(ctx context.Context, mediaPath, lang, key string) ([]byte, string, error) {
+	return FetchFromAllWith(ctx, mediaPath, lang, key, func(ctx context.Context, p Provider, name string) ([]byte, error) {
+		return fetchOne(ctx, p, name, mediaPath, lang)
+	})
+}
+
+// FetchFromAllWith works like FetchFromAll but obtains the subtitle from each
+// provider with fetch, letting callers search and score candidates.
+func FetchFromAllWith(ctx context.Context, mediaPath, lang, key string, fetch FetchFunc) ([]byte, string, error) {
 	insts := Instances()
 	if len(insts) == 0 {
 		names := All()
 		delay := time.Second
 		for i, name := range names {
+			if IsInBackoff(name) {
+				continue
+			}
 			p, err := Get(name, key)
 			if err != nil {
 				continue
 			}
-			c, cancel := context.WithTimeout(ctx, 15*time.Second)
-			data, err := p.Fetch(c, mediaPath, lang)
-			cancel()
+			data, err := fetch(ctx, p, name)
 			if err == nil {
 				return data, name, nil
 			}
 			if ctx.Err() != nil {
 				return nil, "", ctx.Err()
 			}
+			BackoffOnQuota(name, err)
 			time.Sleep(time.Duration(i+1) * delay)
 		}
 		return nil, "", fmt.Errorf("no subtitle found")
@@ -46,9 +61,7 @@ func FetchFromAll(ctx context.Context, mediaPath, lang, key string) ([]byte, str
 		if err != nil {
 			continue
 		}
-		c, cancel := context.WithTimeout(ctx, 15*time.Second)
-		data, err := p.Fetch(c, mediaPath, lang)
-		cancel()
+		data, err := fetch(ctx, p, inst.Name)
 		if err == nil {
 			SetBackoff(inst.ID, 0)
 			return data, inst.ID, nil
@@ -57,7 +70,9 @@ func FetchFromAll(ctx context.Context, mediaPath, lang, key string) ([]byte, str
 		if ctx.Err() != nil {
 			return nil, "", ctx.Err()
 		}
-		SetBackoff(inst.ID, time.Duration(i+1)*delay)
+		if !BackoffOnQuota(inst.ID, err) {
+			SetBackoff(inst.ID, time.Duration(i+1)*delay)
+		}
 		select {
 		case <-time.After(time.Duration(i+1) * delay):
 		case <-ctx.Done():
@@ -104,9 +119,7 @@ func fetchFromInstances(ctx context.Context, insts []Instance, mediaPath, lang,
 		if err != nil {
 			continue
 		}
-		c, cancel := context.WithTimeout(ctx, 15*time.Second)
-		data, err := p.Fetch(c, mediaPath, lang)
-		cancel()
+		data, err := fetchOne(ctx, p, inst.Name, mediaPath, lang)
 		if err == nil {
 			SetBackoff(inst.ID, 0)
 			return data, inst.ID, nil
@@ -114,7 +127,9 @@ func fetchFromInstances(ctx context.Context, insts []Instance, mediaPath, lang,
 		if ctx.Err() != nil {
 			return nil, "", ctx.Err()
 		}
-		SetBackoff(inst.ID, time.Duration(i+1)*delay)
+		if !BackoffOnQuota(inst.ID, err) {
+			SetBackoff(inst.ID, time.Duration(i+1)*delay)
+		}
 		select {
 		case <-time.After(time.Duration(i+1) * delay):
 		case <-ctx.Done():
@@ -123,3 +138,18 @@ func fetchFromInstances(ctx context.Context, insts []Instance, mediaPath, lang,
 	}
 	return nil, "", fmt.Errorf("no subtitle found")
 }
+
+// fetchOne downloads a subtitle from p with a per-provider timeout. Archives
+// are unpacked with archive.Extract so callers always receive a single
+// subtitle file. The provider quota is recorded under name.
+func fetchOne(ctx context.Context, p Provider, name, mediaPath, lang string) ([]byte, error) {
+	c, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	data, err := p.Fetch(c, mediaPath, lang)
+	RecordQuota(name, p)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err = archive.Extract(data, mediaPath)
+	return data, err
+}
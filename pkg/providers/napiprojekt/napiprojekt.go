@@ -2,37 +2,79 @@
 package napiprojekt
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"golang.org/x/text/language"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
-// Client implements the providers.Provider interface for Napiprojekt.
-// It performs a simple HTTP GET to download subtitles.
+// notFound starts the response for files NapiProjekt has no subtitle for.
+var notFound = []byte("NPc0")
+
+// Client implements the providers.Provider interface for NapiProjekt.
+// Subtitles are looked up by the MD5 of the first 10 MiB of the video,
+// signed with the token NapiProjekt derives from it.
 type Client struct {
-	// APIURL is the base URL of the Napiprojekt API.
+	// APIURL is the base URL of the NapiProjekt site.
 	APIURL string
 	// HTTPClient is used to make requests.
 	HTTPClient *http.Client
+
+	username string
+	password string
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
 	return &Client{
-		APIURL:     "https://api.napiprojekt.com",
+		APIURL:     "http://napiprojekt.pl",
 		HTTPClient: &http.Client{Timeout: 15 * time.Second},
 	}
 }
 
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := s.ApplyHTTP(&c.APIURL, &c.HTTPClient); err != nil {
+		return err
+	}
+	c.username = s.Username
+	c.password = s.Password
+	return nil
+}
+
 // Fetch downloads the subtitle for mediaPath in lang.
 // It returns the subtitle bytes or an error.
 func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
-	name := filepath.Base(mediaPath)
-	url := fmt.Sprintf("%s/subtitles/%s/%s", c.APIURL, name, lang)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	hash, _, err := mediahash.Hash(mediaPath, mediahash.NapiProjekt)
+	if err != nil {
+		return nil, err
+	}
+	base, _ := language.Make(lang).Base()
+	q := url.Values{}
+	q.Set("v", "dreambox")
+	q.Set("kolejka", "false")
+	q.Set("nick", c.username)
+	q.Set("pass", c.password)
+	q.Set("napios", "Linux")
+	q.Set("l", strings.ToUpper(base.String()))
+	q.Set("f", hash)
+	q.Set("t", subHash(hash))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.APIURL+"/unit_napisy/dl.php?"+q.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -44,5 +86,32 @@ func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, err
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("status %d", resp.StatusCode)
 	}
-	return io.ReadAll(resp.Body)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || bytes.HasPrefix(data, notFound) {
+		return nil, fmt.Errorf("no subtitles found")
+	}
+	return data, nil
+}
+
+// subHash derives the request token from the MD5 hash: for each of five
+// positions a hexadecimal digit of the hash selects a two digit number,
+// whose product with a fixed factor contributes its last hexadecimal digit.
+func subHash(hash string) string {
+	idx := []int{0xe, 0x3, 0x6, 0x8, 0x2}
+	mul := []int64{2, 2, 5, 4, 3}
+	add := []int{0, 0xd, 0x10, 0xb, 0x5}
+	var b strings.Builder
+	for i := range idx {
+		d, _ := strconv.ParseInt(hash[idx[i]:idx[i]+1], 16, 64)
+		t := add[i] + int(d)
+		// Near the end of the hash only one digit is left, as in the
+		// reference implementation.
+		v, _ := strconv.ParseInt(hash[t:min(t+2, len(hash))], 16, 64)
+		s := strconv.FormatInt(v*mul[i], 16)
+		b.WriteByte(s[len(s)-1])
+	}
+	return b.String()
 }
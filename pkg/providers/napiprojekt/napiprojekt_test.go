@@ -5,28 +5,64 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
-// TestClientFetch verifies that the client downloads subtitles using the expected URL.
+// TestSubHash verifies the token against values of the reference
+// implementation.
+func TestSubHash(t *testing.T) {
+	for hash, want := range map[string]string{
+		"876fe7b536ab94b3b77caf5bc373ee61": "2c6cb",
+		"ffffffffffffffffffffffffffffffff": "eebcd",
+	} {
+		if got := subHash(hash); got != want {
+			t.Errorf("subHash(%s) = %s, want %s", hash, got, want)
+		}
+	}
+}
+
+// TestClientFetch verifies the hash lookup against a stand-in of the
+// NapiProjekt download endpoint.
 func TestClientFetch(t *testing.T) {
-	var gotURL string
+	var query string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		gotURL = r.URL.String()
-		fmt.Fprint(w, "data")
+		if r.URL.Path != "/unit_napisy/dl.php" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		if r.URL.Query().Get("l") != "PL" {
+			fmt.Fprint(w, "NPc0")
+			return
+		}
+		fmt.Fprint(w, "{1}{50}Witaj")
 	}))
 	defer srv.Close()
 
+	media := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.WriteFile(media, []byte("movie data"), 0644); err != nil {
+		t.Fatal(err)
+	}
 	c := New()
-	c.APIURL = srv.URL
-	b, err := c.Fetch(context.Background(), "/path/movie.mkv", "en")
+	if err := c.Configure(settings.Settings{APIURL: srv.URL, Username: "nick", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Fetch(context.Background(), media, "pl")
 	if err != nil {
 		t.Fatalf("fetch: %v", err)
 	}
-	if string(b) != "data" {
+	if string(b) != "{1}{50}Witaj" {
 		t.Fatalf("unexpected body: %s", b)
 	}
-	if gotURL != "/subtitles/movie.mkv/en" {
-		t.Fatalf("unexpected url: %s", gotURL)
+	want := "f=876fe7b536ab94b3b77caf5bc373ee61&kolejka=false&l=PL&napios=Linux&nick=nick&pass=secret&t=2c6cb&v=dreambox"
+	if query != want {
+		t.Fatalf("unexpected query: %s", query)
+	}
+	if _, err := c.Fetch(context.Background(), media, "en"); err == nil {
+		t.Fatal("expected error when no subtitle exists")
 	}
 }
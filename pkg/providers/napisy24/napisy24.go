@@ -2,40 +2,95 @@
 package napisy24
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"golang.org/x/text/language"
+
+	"github.com/jdfalk/subtitle-manager/pkg/archive"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
+)
+
+const (
+	// agentUser and agentPassword are the public agent account used when no
+	// credentials are configured.
+	agentUser     = "subliminal"
+	agentPassword = "lanimilbus"
 )
 
 // Client implements the providers.Provider interface for Napisy24.
-// It performs a simple HTTP GET to download subtitles.
+// Subtitles are looked up by the OpenSubtitles hash, size and name of the
+// video through the CheckSub agent endpoint, which answers with a status
+// line followed by a zip archive. Napisy24 only offers Polish subtitles.
 type Client struct {
-	// APIURL is the base URL of the Napisy24 API.
+	// APIURL is the base URL of the Napisy24 site.
 	APIURL string
 	// HTTPClient is used to make requests.
 	HTTPClient *http.Client
+
+	username string
+	password string
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
 	return &Client{
-		APIURL:     "https://api.napisy24.com",
+		APIURL:     "http://napisy24.pl",
 		HTTPClient: &http.Client{Timeout: 15 * time.Second},
 	}
 }
 
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := s.ApplyHTTP(&c.APIURL, &c.HTTPClient); err != nil {
+		return err
+	}
+	c.username = s.Username
+	c.password = s.Password
+	return nil
+}
+
 // Fetch downloads the subtitle for mediaPath in lang.
 // It returns the subtitle bytes or an error.
 func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
-	name := filepath.Base(mediaPath)
-	url := fmt.Sprintf("%s/subtitles/%s/%s", c.APIURL, name, lang)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if base, _ := language.Make(lang).Base(); base.String() != "pl" {
+		return nil, fmt.Errorf("napisy24 only offers Polish subtitles")
+	}
+	hash, size, err := mediahash.Hash(mediaPath, mediahash.OpenSubtitles)
 	if err != nil {
 		return nil, err
 	}
+	user, pass := c.username, c.password
+	if user == "" || pass == "" {
+		user, pass = agentUser, agentPassword
+	}
+	form := url.Values{}
+	form.Set("postAction", "CheckSub")
+	form.Set("ua", user)
+	form.Set("ap", pass)
+	form.Set("fs", strconv.FormatInt(size, 10))
+	form.Set("fh", hash)
+	form.Set("fn", filepath.Base(mediaPath))
+	form.Set("n24pref", "1")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.APIURL+"/run/CheckSubAgent.php", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -44,5 +99,31 @@ func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, err
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("status %d", resp.StatusCode)
 	}
-	return io.ReadAll(resp.Body)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	status, content, _ := bytes.Cut(data, []byte("||"))
+	switch s := string(status); {
+	case strings.HasPrefix(s, "login error"):
+		return nil, fmt.Errorf("napisy24 login failed")
+	case !strings.HasPrefix(s, "OK"):
+		return nil, fmt.Errorf("unexpected response %q", truncate(s))
+	case !strings.HasPrefix(s, "OK-2"):
+		// OK-0 and OK-1 report no subtitle, OK-3 one not from the
+		// Napisy24 database.
+		return nil, fmt.Errorf("no subtitles found")
+	case len(content) == 0:
+		return nil, fmt.Errorf("response lacks the subtitle archive")
+	}
+	sub, _, err := archive.Extract(content, mediaPath)
+	return sub, err
+}
+
+// truncate shortens unexpected responses for error messages.
+func truncate(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
 }
@@ -1,32 +1,84 @@
 package napisy24
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
-// TestClientFetch verifies that the client downloads subtitles using the expected URL.
+// subtitleZip builds the archive Napisy24 appends to a match.
+func subtitleZip(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("movie.srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(w, "1\n00:00:01,000 --> 00:00:02,000\nCześć\n")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestClientFetch verifies the CheckSub request and the extraction of the
+// archive from the response against a stand-in of the agent endpoint.
 func TestClientFetch(t *testing.T) {
-	var gotURL string
+	archive := subtitleZip(t)
+	var form map[string]string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		gotURL = r.URL.String()
-		fmt.Fprint(w, "data")
+		if r.Method != http.MethodPost || r.URL.Path != "/run/CheckSubAgent.php" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = r.ParseForm()
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		if form["fh"] != "000000000000000a" {
+			fmt.Fprint(w, "OK-0")
+			return
+		}
+		fmt.Fprint(w, "OK-2|napisId:42|imdb:0133093||")
+		_, _ = w.Write(archive)
 	}))
 	defer srv.Close()
 
+	dir := t.TempDir()
+	media := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(media, []byte{1, 0, 0, 0, 0, 0, 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
 	c := New()
 	c.APIURL = srv.URL
-	b, err := c.Fetch(context.Background(), "/path/movie.mkv", "en")
+	b, err := c.Fetch(context.Background(), media, "pl")
 	if err != nil {
 		t.Fatalf("fetch: %v", err)
 	}
-	if string(b) != "data" {
+	if !bytes.Contains(b, []byte("Cześć")) {
 		t.Fatalf("unexpected body: %s", b)
 	}
-	if gotURL != "/subtitles/movie.mkv/en" {
-		t.Fatalf("unexpected url: %s", gotURL)
+	want := map[string]string{"postAction": "CheckSub", "ua": "subliminal", "ap": "lanimilbus", "fs": "8", "fh": "000000000000000a", "fn": "movie.mkv", "n24pref": "1"}
+	if fmt.Sprint(form) != fmt.Sprint(want) {
+		t.Fatalf("unexpected form %v", form)
+	}
+
+	other := filepath.Join(dir, "other.mkv")
+	if err := os.WriteFile(other, []byte{2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Fetch(context.Background(), other, "pl"); err == nil {
+		t.Fatal("expected error when no subtitle exists")
+	}
+	if _, err := c.Fetch(context.Background(), media, "en"); err == nil {
+		t.Fatal("expected error for a language other than Polish")
 	}
 }
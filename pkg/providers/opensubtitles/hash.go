@@ -14,6 +14,13 @@ import (
 // It is defined as a variable to allow tests to replace it.
 var fileHashFunc = realFileHash
 
+// FileHash returns the OpenSubtitles hash and the size of the file at path.
+// Other providers searching by the same hash, such as the OpenSubtitles.com
+// REST API, use it as well.
+func FileHash(path string) (uint64, int64, error) {
+	return realFileHash(path)
+}
+
 // realFileHash calculates the OpenSubtitles file hash.
 // The provided path is validated to ensure it doesn't contain path traversal attempts.
 func realFileHash(path string) (uint64, int64, error) {
@@ -8,10 +8,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // LoginResponse represents the response from the OpenSubtitles login API
@@ -109,6 +115,7 @@ type Client struct {
 	// Authentication
 	username string
 	password string
+	apiKey   string
 	token    string
 	tokenMu  sync.RWMutex
 	tokenExp time.Time
@@ -127,6 +134,7 @@ func New(_ string) *Client {
 
 	username := viper.GetString("opensubtitles.username")
 	password := viper.GetString("opensubtitles.password")
+	apiKey := viper.GetString("opensubtitles.api_key")
 
 	return &Client{
 		APIURL:     apiURL,
@@ -134,7 +142,38 @@ func New(_ string) *Client {
 		HTTPClient: &http.Client{Timeout: 15 * time.Second},
 		username:   username,
 		password:   password,
+		apiKey:     apiKey,
+	}
+}
+
+// vipAPIURL is the REST endpoint for VIP accounts.
+const vipAPIURL = "https://vip-api.opensubtitles.com/api/v1"
+
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password, settings.APIKey, settings.VIP)
+}
+
+// Configure applies the provider settings. Credentials left empty keep the
+// values read from the "opensubtitles" configuration keys, and VIP accounts
+// use the VIP endpoint unless an API URL is configured.
+func (c *Client) Configure(s settings.Settings) error {
+	if s.VIP && s.APIURL == "" && !viper.IsSet("opensubtitles.api_url") {
+		c.APIURL = vipAPIURL
+	}
+	if err := s.ApplyHTTP(&c.APIURL, &c.HTTPClient); err != nil {
+		return err
+	}
+	if s.Username != "" {
+		c.username = s.Username
 	}
+	if s.Password != "" {
+		c.password = s.Password
+	}
+	if s.APIKey != "" {
+		c.apiKey = s.APIKey
+	}
+	return nil
 }
 
 // login authenticates with OpenSubtitles and stores the session token
@@ -160,7 +199,7 @@ func (c *Client) login(ctx context.Context) error {
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", c.UserAgent)
-	req.Header.Set("Api-Key", "") // Empty API key for username/password login
+	req.Header.Set("Api-Key", c.apiKey)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -211,8 +250,9 @@ func (c *Client) getToken(ctx context.Context) (string, error) {
 	return token, nil
 }
 
-// Search returns download URLs for matching subtitles without downloading them.
-func (c *Client) Search(ctx context.Context, mediaPath, lang string) ([]string, error) {
+// searchRaw performs an authenticated hash search and returns the raw
+// response body. An expired token triggers one re-login and retry.
+func (c *Client) searchRaw(ctx context.Context, mediaPath, lang string) ([]byte, error) {
 	token, err := c.getToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("authentication failed: %w", err)
@@ -232,6 +272,9 @@ func (c *Client) Search(ctx context.Context, mediaPath, lang string) ([]string,
 
 	req.Header.Set("User-Agent", c.UserAgent)
 	req.Header.Set("Authorization", "Bearer "+token)
+	if c.apiKey != "" {
+		req.Header.Set("Api-Key", c.apiKey)
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTPClient.Do(req)
@@ -267,6 +310,15 @@ func (c *Client) Search(ctx context.Context, mediaPath, lang string) ([]string,
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("search failed with status %d: %s", resp.StatusCode, string(body))
 	}
+	return body, nil
+}
+
+// Search returns download URLs for matching subtitles without downloading them.
+func (c *Client) Search(ctx context.Context, mediaPath, lang string) ([]string, error) {
+	body, err := c.searchRaw(ctx, mediaPath, lang)
+	if err != nil {
+		return nil, err
+	}
 
 	var searchResp SearchResponse
 	if err := json.Unmarshal(body, &searchResp); err == nil && len(searchResp.Data) > 0 {
@@ -296,25 +348,99 @@ func (c *Client) Search(ctx context.Context, mediaPath, lang string) ([]string,
 
 // SearchWithResults returns detailed search results for scoring.
 func (c *Client) SearchWithResults(ctx context.Context, mediaPath, lang string) ([]SearchResult, error) {
+	body, err := c.searchRaw(ctx, mediaPath, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var searchResp SearchResponse
+	if err := json.Unmarshal(body, &searchResp); err == nil {
+		return searchResp.Data, nil
+	}
+
+	return nil, fmt.Errorf("failed to decode search response")
+}
+
+// SearchCandidates returns structured search results. The download token of
+// each candidate is the numeric file ID expected by the download endpoint.
+func (c *Client) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]candidate.Candidate, error) {
+	body, err := c.searchRaw(ctx, mediaPath, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var searchResp SearchResponse
+	if err := json.Unmarshal(body, &searchResp); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+	// The hash match flag is not part of SearchResult, decode it separately.
+	var hashResp struct {
+		Data []struct {
+			Attributes struct {
+				MovieHashMatch bool `json:"moviehash_match"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(body, &hashResp)
+
+	cands := make([]candidate.Candidate, 0, len(searchResp.Data))
+	for i, r := range searchResp.Data {
+		a := r.Attributes
+		if len(a.Files) == 0 {
+			continue
+		}
+		cand := candidate.Candidate{
+			Provider:          "opensubtitles",
+			ID:                a.SubtitleID,
+			Release:           a.Release,
+			FileName:          a.Files[0].FileName,
+			Language:          a.Language,
+			HearingImpaired:   a.HearingImpaired,
+			Forced:            a.ForeignPartsOnly,
+			Format:            formatFromName(a.Files[0].FileName),
+			Downloads:         a.DownloadCount,
+			Rating:            a.Ratings,
+			Votes:             a.Votes,
+			Trusted:           a.FromTrusted,
+			MachineTranslated: a.MachineTranslated || a.AutoTranslated,
+			DownloadToken:     strconv.Itoa(a.Files[0].FileID),
+		}
+		if i < len(hashResp.Data) {
+			cand.HashMatch = hashResp.Data[i].Attributes.MovieHashMatch
+		}
+		if t, err := time.Parse(time.RFC3339, a.UploadDate); err == nil {
+			cand.UploadDate = t
+		}
+		cands = append(cands, cand)
+	}
+	return cands, nil
+}
+
+// Download requests a temporary link for the candidate's file ID and returns
+// the subtitle bytes.
+func (c *Client) Download(ctx context.Context, cand candidate.Candidate) ([]byte, error) {
+	fileID, err := strconv.Atoi(cand.DownloadToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download token %q", cand.DownloadToken)
+	}
 	token, err := c.getToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
 
-	hash, size, err := fileHashFunc(mediaPath)
+	payload, err := json.Marshal(map[string]int{"file_id": fileID})
 	if err != nil {
 		return nil, err
 	}
-
-	// Use the new REST API v1 endpoint
-	url := fmt.Sprintf("%s/subtitles?moviehash=%x&moviebytesize=%d&languages=%s", c.APIURL, hash, size, lang)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.APIURL+"/download", bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
 	}
-
 	req.Header.Set("User-Agent", c.UserAgent)
 	req.Header.Set("Authorization", "Bearer "+token)
+	if c.apiKey != "" {
+		req.Header.Set("Api-Key", c.apiKey)
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTPClient.Do(req)
@@ -322,41 +448,41 @@ func (c *Client) SearchWithResults(ctx context.Context, mediaPath, lang string)
 		return nil, err
 	}
 	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		// Token might be expired, try to login again
-		c.tokenMu.Lock()
-		c.token = ""
-		c.tokenMu.Unlock()
-
-		token, err = c.getToken(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("re-authentication failed: %w", err)
-		}
-
-		// Retry the request with new token
-		req.Header.Set("Authorization", "Bearer "+token)
-		resp, err = c.HTTPClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("download request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	var dl DownloadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dl); err != nil {
+		return nil, fmt.Errorf("failed to decode download response: %w", err)
+	}
+	if dl.Link == "" {
+		return nil, fmt.Errorf("download link missing: %s", dl.Message)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	fileReq, err := http.NewRequestWithContext(ctx, http.MethodGet, dl.Link, nil)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search failed with status %d: %s", resp.StatusCode, string(body))
+	fileReq.Header.Set("User-Agent", c.UserAgent)
+	fileResp, err := c.HTTPClient.Do(fileReq)
+	if err != nil {
+		return nil, err
 	}
-
-	var searchResp SearchResponse
-	if err := json.Unmarshal(body, &searchResp); err == nil {
-		return searchResp.Data, nil
+	defer fileResp.Body.Close()
+	if fileResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("file download failed with status %d", fileResp.StatusCode)
 	}
+	return io.ReadAll(fileResp.Body)
+}
 
-	return nil, fmt.Errorf("failed to decode search response")
+// formatFromName returns the lower-case extension of name without the dot,
+// defaulting to "srt".
+func formatFromName(name string) string {
+	if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), "."); ext != "" {
+		return ext
+	}
+	return "srt"
 }
 
 // Fetch downloads the first matching subtitle for mediaPath in lang.
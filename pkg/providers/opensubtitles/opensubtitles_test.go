@@ -2,6 +2,7 @@ package opensubtitles
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -105,3 +106,59 @@ func TestNewUsesConfig(t *testing.T) {
 		t.Fatalf("expected user_agent ua, got %s", c.UserAgent)
 	}
 }
+
+// TestSearchCandidatesAndDownload verifies structured results and the two-step download.
+func TestSearchCandidatesAndDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/subtitles":
+			fmt.Fprint(w, `{"data":[{"attributes":{"subtitle_id":"9","language":"en","release":"Movie.2020.BluRay",
+				"hearing_impaired":true,"download_count":120,"ratings":8.5,"votes":4,"from_trusted":true,
+				"moviehash_match":true,"upload_date":"2024-01-02T03:04:05.000Z","files":[{"file_id":77,"file_name":"Movie.2020.BluRay.srt"}]}}]}`)
+		case "/download":
+			var body struct {
+				FileID int `json:"file_id"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.FileID != 77 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"link":"http://%s/file.srt","remaining":10}`, r.Host)
+		case "/file.srt":
+			fmt.Fprint(w, "sub data")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := New("")
+	c.token = "t"
+	c.tokenExp = time.Now().Add(time.Hour)
+	c.APIURL = srv.URL
+	c.HTTPClient = srv.Client()
+	orig := fileHashFunc
+	fileHashFunc = func(string) (uint64, int64, error) { return 1, 1, nil }
+	defer func() { fileHashFunc = orig }()
+
+	cands, err := c.SearchCandidates(context.Background(), "dummy.mkv", "en")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(cands) != 1 {
+		t.Fatalf("expected 1 candidate, got %d", len(cands))
+	}
+	cand := cands[0]
+	if !cand.HashMatch || !cand.HearingImpaired || !cand.Trusted || cand.Downloads != 120 ||
+		cand.Format != "srt" || cand.DownloadToken != "77" || cand.UploadDate.IsZero() {
+		t.Fatalf("unexpected candidate %+v", cand)
+	}
+
+	data, err := c.Download(context.Background(), cand)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if string(data) != "sub data" {
+		t.Fatalf("unexpected data: %s", data)
+	}
+}
@@ -2,47 +2,476 @@
 package opensubtitlescom
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/quota"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
-// Client implements the providers.Provider interface for Opensubtitlescom.
-// It performs a simple HTTP GET to download subtitles.
+// defaultAPIURL is the public REST endpoint.
+const defaultAPIURL = "https://api.opensubtitles.com/api/v1"
+
+// Client implements the providers.Provider and providers.CandidateSearcher
+// interfaces for the OpenSubtitles.com REST API. Requests carry the Api-Key
+// header; with a username and password the client logs in and sends the
+// returned JWT as well. Tokens and download quotas are shared by all clients
+// of the same account.
 type Client struct {
-	// APIURL is the base URL of the Opensubtitlescom API.
+	// APIURL is the base URL of the REST API.
 	APIURL string
+	// UserAgent identifies this application to the API.
+	UserAgent string
 	// HTTPClient is used to make requests.
 	HTTPClient *http.Client
+
+	username string
+	password string
+	apiKey   string
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
 	return &Client{
-		APIURL:     "https://api.opensubtitlescom.com",
+		APIURL:     defaultAPIURL,
+		UserAgent:  "subtitle-manager v1.0",
 		HTTPClient: &http.Client{Timeout: 15 * time.Second},
 	}
 }
 
-// Fetch downloads the subtitle for mediaPath in lang.
-// It returns the subtitle bytes or an error.
-func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
-	name := filepath.Base(mediaPath)
-	url := fmt.Sprintf("%s/subtitles/%s/%s", c.APIURL, name, lang)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password, settings.APIKey).Required(settings.KeyAPIKey)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := s.ApplyHTTP(&c.APIURL, &c.HTTPClient); err != nil {
+		return err
+	}
+	c.username = s.Username
+	c.password = s.Password
+	c.apiKey = s.APIKey
+	return nil
+}
+
+// session is a cached login of one account.
+type session struct {
+	token   string
+	baseURL string
+	expires time.Time
+}
+
+var (
+	cacheMu  sync.Mutex
+	sessions = map[string]session{}
+	quotas   = map[string]quota.Quota{}
+	// fileHash returns the movie hash and size. Tests replace it.
+	fileHash = func(path string) (string, int64, error) {
+		return mediahash.Hash(path, mediahash.OpenSubtitles)
+	}
+)
+
+// account identifies the cache entries of the client's account. Anonymous
+// clients share the quota of their API key.
+func (c *Client) account() string {
+	return c.APIURL + "|" + c.apiKey + "|" + c.username
+}
+
+// Quota returns the download quota last reported for the account.
+func (c *Client) Quota() (quota.Quota, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	q, ok := quotas[c.account()]
+	return q, ok && !q.UpdatedAt.IsZero()
+}
+
+// loginResponse is the body returned by POST /login.
+type loginResponse struct {
+	User struct {
+		AllowedDownloads int  `json:"allowed_downloads"`
+		VIP              bool `json:"vip"`
+	} `json:"user"`
+	BaseURL string `json:"base_url"`
+	Token   string `json:"token"`
+}
+
+// session returns the cached login of the account, logging in when there is
+// none. Clients without credentials use no session.
+func (c *Client) session(ctx context.Context) (session, error) {
+	if c.username == "" || c.password == "" {
+		return session{}, nil
+	}
+	cacheMu.Lock()
+	s, ok := sessions[c.account()]
+	cacheMu.Unlock()
+	if ok && time.Now().Before(s.expires) {
+		return s, nil
+	}
+
+	payload, err := json.Marshal(map[string]string{"username": c.username, "password": c.password})
+	if err != nil {
+		return session{}, err
+	}
+	body, err := c.send(ctx, session{}, http.MethodPost, "/login", nil, payload)
+	if err != nil {
+		return session{}, fmt.Errorf("login: %w", err)
+	}
+	var lr loginResponse
+	if err := json.Unmarshal(body, &lr); err != nil {
+		return session{}, fmt.Errorf("decode login response: %w", err)
+	}
+	if lr.Token == "" {
+		return session{}, fmt.Errorf("login: no token returned")
+	}
+	s = session{token: lr.Token, expires: tokenExpiry(lr.Token, time.Now())}
+	// Accounts may be assigned another host, such as the VIP endpoint, which
+	// only applies when the default endpoint is configured.
+	if lr.BaseURL != "" && c.APIURL == defaultAPIURL {
+		s.baseURL = "https://" + lr.BaseURL + "/api/v1"
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	sessions[c.account()] = s
+	if lr.User.AllowedDownloads > 0 {
+		q := quotas[c.account()]
+		q.Allowed = lr.User.AllowedDownloads
+		quotas[c.account()] = q
+	}
+	return s, nil
+}
+
+// tokenExpiry returns when the JWT token expires, one minute early to allow
+// for clock skew. Tokens whose expiry cannot be read are kept for a day.
+func tokenExpiry(token string, now time.Time) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		if data, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+			var claims struct {
+				Exp int64 `json:"exp"`
+			}
+			if json.Unmarshal(data, &claims) == nil && claims.Exp > 0 {
+				return time.Unix(claims.Exp, 0).Add(-time.Minute)
+			}
+		}
+	}
+	return now.Add(24 * time.Hour)
+}
+
+// statusError is a response with an unexpected status code.
+type statusError struct {
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.code, bytes.TrimSpace(e.body))
+}
+
+// send performs one API request and returns the response body. Rate limited
+// requests fail with a quota.ExceededError.
+func (c *Client) send(ctx context.Context, s session, method, path string, query url.Values, payload []byte) ([]byte, error) {
+	base := c.APIURL
+	if s.baseURL != "" {
+		base = s.baseURL
+	}
+	u := base + path
+	if len(query) > 0 {
+		// The API expects sorted parameters, which Encode guarantees.
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("Api-Key", c.apiKey)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return data, nil
+	case http.StatusTooManyRequests:
+		// Rate limits reset within seconds, unlike the daily quota.
+		wait := time.Minute
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(secs) * time.Second
+		}
+		return nil, &quota.ExceededError{Provider: "opensubtitlescom", ResetAt: time.Now().Add(wait), Message: "rate limited"}
+	}
+	return nil, &statusError{code: resp.StatusCode, body: data}
+}
+
+// call performs an authenticated request. An expired token is dropped and
+// the request retried once after logging in again.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, payload []byte) ([]byte, error) {
+	s, err := c.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.send(ctx, s, method, path, query, payload)
+	if se, ok := err.(*statusError); ok && se.code == http.StatusUnauthorized && s.token != "" {
+		cacheMu.Lock()
+		delete(sessions, c.account())
+		cacheMu.Unlock()
+		if s, err = c.session(ctx); err != nil {
+			return nil, err
+		}
+		data, err = c.send(ctx, s, method, path, query, payload)
+	}
+	return data, err
+}
+
+// searchResponse is the body returned by GET /subtitles.
+type searchResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			SubtitleID        string  `json:"subtitle_id"`
+			Language          string  `json:"language"`
+			DownloadCount     int     `json:"download_count"`
+			HearingImpaired   bool    `json:"hearing_impaired"`
+			Votes             int     `json:"votes"`
+			Ratings           float64 `json:"ratings"`
+			FromTrusted       bool    `json:"from_trusted"`
+			ForeignPartsOnly  bool    `json:"foreign_parts_only"`
+			AITranslated      bool    `json:"ai_translated"`
+			MachineTranslated bool    `json:"machine_translated"`
+			UploadDate        string  `json:"upload_date"`
+			Release           string  `json:"release"`
+			MovieHashMatch    bool    `json:"moviehash_match"`
+			Files             []struct {
+				FileID   int    `json:"file_id"`
+				FileName string `json:"file_name"`
+			} `json:"files"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+var (
+	imdbPattern    = regexp.MustCompile(`\btt0*(\d{5,})\b`)
+	tmdbPattern    = regexp.MustCompile(`(?i)\btmdb(?:id)?[-=](\d+)\b`)
+	episodePattern = regexp.MustCompile(`(?i)\bs(\d{1,2})e(\d{1,3})\b`)
+	// titleEnd marks where the title ends in a release name.
+	titleEnd = regexp.MustCompile(`(?i)\b(s\d{1,2}e\d{1,3}|(19|20)\d\d|\d{3,4}p)\b`)
+)
+
+// searchQuery returns the search parameters for mediaPath in lang: the movie
+// hash when the file can be read, the IMDb or TMDB ID found in the path
+// (as in "Movie (2020) {imdb-tt0123456}/" or "tmdbid-603"), otherwise the
+// title from the file name, and the season and episode numbers.
+func searchQuery(mediaPath, lang string) url.Values {
+	q := url.Values{}
+	q.Set("languages", strings.ToLower(lang))
+	if hash, size, err := fileHash(mediaPath); err == nil {
+		q.Set("moviehash", hash)
+		q.Set("moviebytesize", strconv.FormatInt(size, 10))
+	}
+	name := strings.TrimSuffix(filepath.Base(mediaPath), filepath.Ext(mediaPath))
+	if m := episodePattern.FindStringSubmatch(name); m != nil {
+		season, _ := strconv.Atoi(m[1])
+		episode, _ := strconv.Atoi(m[2])
+		q.Set("season_number", strconv.Itoa(season))
+		q.Set("episode_number", strconv.Itoa(episode))
+	}
+	switch {
+	case imdbPattern.MatchString(mediaPath):
+		q.Set("imdb_id", imdbPattern.FindStringSubmatch(mediaPath)[1])
+	case tmdbPattern.MatchString(mediaPath):
+		q.Set("tmdb_id", tmdbPattern.FindStringSubmatch(mediaPath)[1])
+	default:
+		if loc := titleEnd.FindStringIndex(name); loc != nil && loc[0] > 0 {
+			name = name[:loc[0]]
+		}
+		title := strings.Join(strings.Fields(strings.NewReplacer(".", " ", "_", " ", "(", " ", ")", " ").Replace(name)), " ")
+		if title != "" {
+			q.Set("query", strings.ToLower(title))
+		}
+	}
+	return q
+}
+
+// SearchCandidates returns the subtitles offered for mediaPath in lang. The
+// download token of each candidate is the file ID expected by the download
+// endpoint.
+func (c *Client) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]candidate.Candidate, error) {
+	body, err := c.call(ctx, http.MethodGet, "/subtitles", searchQuery(mediaPath, lang), nil)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	var sr searchResponse
+	if err := json.Unmarshal(body, &sr); err != nil {
+		return nil, fmt.Errorf("decode search response: %w", err)
+	}
+	cands := make([]candidate.Candidate, 0, len(sr.Data))
+	for _, r := range sr.Data {
+		a := r.Attributes
+		if len(a.Files) == 0 {
+			continue
+		}
+		id := a.SubtitleID
+		if id == "" {
+			id = r.ID
+		}
+		cand := candidate.Candidate{
+			Provider:          "opensubtitlescom",
+			ID:                id,
+			Release:           a.Release,
+			FileName:          a.Files[0].FileName,
+			Language:          a.Language,
+			HearingImpaired:   a.HearingImpaired,
+			Forced:            a.ForeignPartsOnly,
+			Format:            "srt",
+			Downloads:         a.DownloadCount,
+			Rating:            a.Ratings,
+			Votes:             a.Votes,
+			HashMatch:         a.MovieHashMatch,
+			Trusted:           a.FromTrusted,
+			MachineTranslated: a.MachineTranslated || a.AITranslated,
+			DownloadToken:     strconv.Itoa(a.Files[0].FileID),
+		}
+		if t, err := time.Parse(time.RFC3339, a.UploadDate); err == nil {
+			cand.UploadDate = t
+		}
+		cands = append(cands, cand)
+	}
+	return cands, nil
+}
+
+// downloadResponse is the body returned by POST /download, also when the
+// quota is exhausted.
+type downloadResponse struct {
+	Link         string `json:"link"`
+	FileName     string `json:"file_name"`
+	Requests     int    `json:"requests"`
+	Remaining    int    `json:"remaining"`
+	Message      string `json:"message"`
+	ResetTimeUTC string `json:"reset_time_utc"`
+}
+
+// recordQuota stores the quota reported by a download response.
+func (c *Client) recordQuota(dr downloadResponse, now time.Time) quota.Quota {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	q := quotas[c.account()]
+	q.Remaining = dr.Remaining
+	q.UpdatedAt = now
+	if t, err := time.Parse(time.RFC3339, dr.ResetTimeUTC); err == nil {
+		q.ResetAt = t
+	}
+	if q.Allowed == 0 && dr.Requests+dr.Remaining > 0 {
+		q.Allowed = dr.Requests + dr.Remaining
+	}
+	quotas[c.account()] = q
+	return q
+}
+
+// Download requests a temporary link for the candidate's file and returns
+// the subtitle bytes. Once the daily quota is used up it fails with a
+// quota.ExceededError without contacting the API until the quota resets.
+func (c *Client) Download(ctx context.Context, cand candidate.Candidate) ([]byte, error) {
+	fileID, err := strconv.Atoi(cand.DownloadToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download token %q", cand.DownloadToken)
+	}
+	if q, ok := c.Quota(); ok && q.Exhausted(time.Now()) {
+		return nil, &quota.ExceededError{Provider: "opensubtitlescom", ResetAt: q.ResetAt}
+	}
+	payload, err := json.Marshal(map[string]int{"file_id": fileID})
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.call(ctx, http.MethodPost, "/download", nil, payload)
+	if se, ok := err.(*statusError); ok && se.code == http.StatusNotAcceptable {
+		// The quota is exhausted; the body carries the reset time.
+		var dr downloadResponse
+		_ = json.Unmarshal(se.body, &dr)
+		dr.Remaining = 0
+		q := c.recordQuota(dr, time.Now())
+		return nil, &quota.ExceededError{Provider: "opensubtitlescom", ResetAt: q.ResetAt, Message: dr.Message}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	var dr downloadResponse
+	if err := json.Unmarshal(body, &dr); err != nil {
+		return nil, fmt.Errorf("decode download response: %w", err)
+	}
+	c.recordQuota(dr, time.Now())
+	if dr.Link == "" {
+		return nil, fmt.Errorf("download link missing: %s", dr.Message)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dr.Link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %d", resp.StatusCode)
+		return nil, fmt.Errorf("file download failed with status %d", resp.StatusCode)
 	}
 	return io.ReadAll(resp.Body)
 }
+
+// Fetch downloads the best subtitle for mediaPath in lang, preferring hash
+// matches and then the most downloaded subtitle.
+func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	cands, err := c.SearchCandidates(ctx, mediaPath, lang)
+	if err != nil {
+		return nil, err
+	}
+	if len(cands) == 0 {
+		return nil, fmt.Errorf("no subtitles found")
+	}
+	best := cands[0]
+	for _, cand := range cands[1:] {
+		if cand.HashMatch != best.HashMatch {
+			if cand.HashMatch {
+				best = cand
+			}
+			continue
+		}
+		if cand.Downloads > best.Downloads {
+			best = cand
+		}
+	}
+	return c.Download(ctx, best)
+}
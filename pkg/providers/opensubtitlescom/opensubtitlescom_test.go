@@ -2,31 +2,174 @@ package opensubtitlescom
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/quota"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
-// TestClientFetch verifies that the client downloads subtitles using the expected URL.
-func TestClientFetch(t *testing.T) {
-	var gotURL string
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		gotURL = r.URL.String()
-		fmt.Fprint(w, "data")
-	}))
-	defer srv.Close()
+// standIn emulates the login, search and two-step download endpoints. The
+// account may download remaining more subtitles.
+type standIn struct {
+	*httptest.Server
+	logins    atomic.Int32
+	remaining atomic.Int32
+	query     atomic.Value
+}
+
+func newStandIn(t *testing.T, remaining int32) *standIn {
+	t.Helper()
+	s := &standIn{}
+	s.remaining.Store(remaining)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Api-Key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		s.logins.Add(1)
+		fmt.Fprint(w, `{"user":{"allowed_downloads":20},"token":"jwt","status":200}`)
+	})
+	mux.HandleFunc("GET /subtitles", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.query.Store(r.URL.RawQuery)
+		fmt.Fprint(w, `{"data":[
+			{"id":"1","attributes":{"subtitle_id":"1","language":"en","download_count":900,"release":"Movie.2020.720p","files":[{"file_id":11,"file_name":"a.srt"}]}},
+			{"id":"2","attributes":{"subtitle_id":"2","language":"en","download_count":10,"moviehash_match":true,"hearing_impaired":true,"release":"Movie.2020.1080p.WEB","upload_date":"2021-02-03T04:05:06Z","files":[{"file_id":22,"file_name":"b.srt"}]}}
+		]}`)
+	})
+	mux.HandleFunc("POST /download", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			FileID int `json:"file_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		reset := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+		if s.remaining.Load() <= 0 {
+			w.WriteHeader(http.StatusNotAcceptable)
+			fmt.Fprintf(w, `{"requests":20,"remaining":0,"message":"daily limit reached","reset_time_utc":%q}`, reset)
+			return
+		}
+		left := s.remaining.Add(-1)
+		fmt.Fprintf(w, `{"link":"%s/file/%d","requests":%d,"remaining":%d,"reset_time_utc":%q}`, s.URL, req.FileID, 20-left, left, reset)
+	})
+	mux.HandleFunc("GET /file/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "subtitle %s", r.PathValue("id"))
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	t.Cleanup(func() {
+		cacheMu.Lock()
+		sessions = map[string]session{}
+		quotas = map[string]quota.Quota{}
+		cacheMu.Unlock()
+	})
+	return s
+}
 
+func (s *standIn) client(t *testing.T) *Client {
+	t.Helper()
 	c := New()
-	c.APIURL = srv.URL
-	b, err := c.Fetch(context.Background(), "/path/movie.mkv", "en")
+	if err := c.Configure(settings.Settings{APIURL: s.URL, Username: "user", Password: "pass", APIKey: "key"}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	return c
+}
+
+// TestSearchAndDownload verifies the hash search, candidate mapping and the
+// two-step download, and that the login token is shared between clients.
+func TestSearchAndDownload(t *testing.T) {
+	defer func(orig func(string) (string, int64, error)) { fileHash = orig }(fileHash)
+	fileHash = func(string) (string, int64, error) { return "0000000000001234", 2048, nil }
+	s := newStandIn(t, 5)
+
+	cands, err := s.client(t).SearchCandidates(context.Background(), "/tv/Show.Name.S01E02.720p.mkv", "EN")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	want := "episode_number=2&languages=en&moviebytesize=2048&moviehash=0000000000001234&query=show+name&season_number=1"
+	if got := s.query.Load(); got != want {
+		t.Fatalf("query %v, want %s", got, want)
+	}
+	if len(cands) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(cands))
+	}
+	c := cands[1]
+	if c.Provider != "opensubtitlescom" || !c.HashMatch || !c.HearingImpaired || c.DownloadToken != "22" || c.UploadDate.IsZero() {
+		t.Fatalf("unexpected candidate %+v", c)
+	}
+
+	// Fetch prefers the hash match over the more popular subtitle.
+	data, err := s.client(t).Fetch(context.Background(), "/movies/Movie (2020) {imdb-tt0012345}/movie.mkv", "en")
 	if err != nil {
 		t.Fatalf("fetch: %v", err)
 	}
-	if string(b) != "data" {
-		t.Fatalf("unexpected body: %s", b)
+	if string(data) != "subtitle 22" {
+		t.Fatalf("unexpected body %q", data)
+	}
+	if got := s.query.Load(); got != "imdb_id=12345&languages=en&moviebytesize=2048&moviehash=0000000000001234" {
+		t.Fatalf("unexpected query %v", got)
+	}
+	if n := s.logins.Load(); n != 1 {
+		t.Fatalf("expected one login, got %d", n)
+	}
+	q, ok := s.client(t).Quota()
+	if !ok || q.Remaining != 4 || q.Allowed != 20 || q.ResetAt.IsZero() {
+		t.Fatalf("unexpected quota %+v", q)
+	}
+}
+
+// TestDownloadQuotaExceeded verifies an exhausted quota is reported and that
+// further downloads are refused until it resets.
+func TestDownloadQuotaExceeded(t *testing.T) {
+	s := newStandIn(t, 0)
+	c := s.client(t)
+	cand := candidate.Candidate{DownloadToken: "11"}
+
+	_, err := c.Download(context.Background(), cand)
+	var qe *quota.ExceededError
+	if !errors.As(err, &qe) || qe.ResetAt.Before(time.Now().Add(time.Hour)) {
+		t.Fatalf("expected quota error with reset time, got %v", err)
+	}
+	q, ok := c.Quota()
+	if !ok || !q.Exhausted(time.Now()) {
+		t.Fatalf("quota not exhausted: %+v", q)
+	}
+
+	// The next download fails without contacting the API.
+	s.remaining.Store(5)
+	if _, err := c.Download(context.Background(), cand); !errors.As(err, &qe) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if s.remaining.Load() != 5 {
+		t.Fatal("download requested despite exhausted quota")
+	}
+}
+
+// TestExpiredTokenRelogin verifies a rejected token triggers a new login.
+func TestExpiredTokenRelogin(t *testing.T) {
+	defer func(orig func(string) (string, int64, error)) { fileHash = orig }(fileHash)
+	fileHash = func(string) (string, int64, error) { return "", 0, errors.New("no file") }
+	s := newStandIn(t, 5)
+	c := s.client(t)
+	cacheMu.Lock()
+	sessions[c.account()] = session{token: "stale", expires: time.Now().Add(time.Hour)}
+	cacheMu.Unlock()
+
+	if _, err := c.SearchCandidates(context.Background(), "movie.mkv", "en"); err != nil {
+		t.Fatalf("search: %v", err)
 	}
-	if gotURL != "/subtitles/movie.mkv/en" {
-		t.Fatalf("unexpected url: %s", gotURL)
+	if n := s.logins.Load(); n != 1 {
+		t.Fatalf("expected one login, got %d", n)
 	}
 }
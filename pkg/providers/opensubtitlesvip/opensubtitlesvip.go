@@ -7,12 +7,17 @@ import (
 	"io"
 
 	"github.com/oz/osdb"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // Client implements the providers.Provider interface for Opensubtitlesvip.
 // It uses the osdb SDK to search and download subtitles.
 type Client struct {
 	api api
+
+	username string
+	password string
 }
 
 type api interface {
@@ -31,13 +36,25 @@ func New() *Client {
 	return &Client{api: c}
 }
 
+// SettingsSchema declares the settings the provider accepts.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Schema{settings.Username, settings.Password}
+}
+
+// Configure applies the account credentials. Without them the client logs
+// in anonymously.
+func (c *Client) Configure(s settings.Settings) error {
+	c.username, c.password = s.Username, s.Password
+	return nil
+}
+
 // Fetch downloads the subtitle for mediaPath in lang.
 // It returns the subtitle bytes or an error.
 func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
 	if c.api == nil {
 		return nil, fmt.Errorf("client not initialized")
 	}
-	if err := c.api.LogIn("", "", lang); err != nil {
+	if err := c.api.LogIn(c.username, c.password, lang); err != nil {
 		return nil, err
 	}
 	subs, err := c.api.FileSearch(mediaPath, []string{lang})
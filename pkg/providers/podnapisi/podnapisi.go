@@ -7,19 +7,16 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
-	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 type Client struct {
-	APIURL     string
-	HTTPClient *http.Client
+	settings.HTTP
 }
 
 func New() *Client {
-	return &Client{
-		APIURL:     "https://api.podnapisi.com",
-		HTTPClient: &http.Client{Timeout: 15 * time.Second},
-	}
+	return &Client{HTTP: settings.NewHTTP("https://api.podnapisi.com")}
 }
 
 func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
@@ -1,7 +1,11 @@
 // file: pkg/providers/provider.go
 package providers
 
-import "context"
+import (
+	"context"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
+)
 
 // Provider downloads subtitles for a media file in the given language.
 type Provider interface {
@@ -16,3 +20,16 @@ type Searcher interface {
 	// Search returns download URLs for matching subtitles without fetching them.
 	Search(ctx context.Context, mediaPath, lang string) ([]string, error)
 }
+
+// Candidate is a structured subtitle search result.
+type Candidate = candidate.Candidate
+
+// CandidateSearcher optionally exposes structured search results that carry
+// enough metadata for scoring, along with a way to download a chosen result.
+type CandidateSearcher interface {
+	// SearchCandidates returns matching subtitles without downloading them.
+	SearchCandidates(ctx context.Context, mediaPath, lang string) ([]Candidate, error)
+	// Download returns the subtitle bytes for a candidate previously
+	// returned by SearchCandidates.
+	Download(ctx context.Context, c Candidate) ([]byte, error)
+}
@@ -0,0 +1,69 @@
+// file: pkg/providers/quota/quota.go
+// version: 1.0.1
+// guid: 33344d21-c84e-4e37-b281-a5ddaf6c83b6
+
+// Package quota describes provider download quotas. Providers that know
+// their account allowance report it as a Quota and fail with an
+// ExceededError once it is used up; Wait turns that error into the time the
+// provider should be left alone.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Quota is the download allowance of a provider account.
+type Quota struct {
+	// Allowed is the number of downloads per period, zero when unknown.
+	Allowed int `json:"allowed,omitempty"`
+	// Remaining is the number of downloads left in the current period.
+	Remaining int `json:"remaining"`
+	// ResetAt is when the allowance is restored, zero when unknown.
+	ResetAt time.Time `json:"reset_at,omitempty"`
+	// UpdatedAt is when the provider last reported the quota.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Exhausted reports whether no downloads are left at now.
+func (q Quota) Exhausted(now time.Time) bool {
+	return !q.UpdatedAt.IsZero() && q.Remaining <= 0 && now.Before(q.ResetAt)
+}
+
+// ExceededError is returned by providers that refuse requests until their
+// quota or rate limit resets.
+type ExceededError struct {
+	Provider string
+	// ResetAt is when requests are accepted again, zero when unknown.
+	ResetAt time.Time
+	Message string
+}
+
+func (e *ExceededError) Error() string {
+	msg := fmt.Sprintf("%s quota exceeded", e.Provider)
+	if !e.ResetAt.IsZero() {
+		msg += " until " + e.ResetAt.UTC().Format(time.RFC3339)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// DefaultWait is how long a provider is backed off when it does not report
+// when its quota resets.
+const DefaultWait = time.Hour
+
+// Wait returns how long to back off after err, and whether err reports an
+// exceeded quota at all.
+func Wait(err error, now time.Time) (time.Duration, bool) {
+	var e *ExceededError
+	if !errors.As(err, &e) {
+		return 0, false
+	}
+	if d := e.ResetAt.Sub(now); d > 0 {
+		return d, true
+	}
+	return DefaultWait, true
+}
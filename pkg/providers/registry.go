@@ -30,6 +30,7 @@ import (
 	"github.com/jdfalk/subtitle-manager/pkg/providers/opensubtitlesvip"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/podnapisi"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/regielive"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/soustitres"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/subdivx"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/subf2m"
@@ -118,15 +119,53 @@ func RegisterFactory(name string, f func() Provider) {
 	factories[name] = f
 }
 
-// Get returns a provider by name.
-func Get(name, _ string) (Provider, error) {
+// Configurable is implemented by providers that accept settings such as
+// credentials, an API URL override, a timeout or a proxy.
+type Configurable interface {
+	// SettingsSchema declares the settings the provider accepts.
+	SettingsSchema() settings.Schema
+	// Configure applies settings loaded for the provider.
+	Configure(settings.Settings) error
+}
+
+// Get returns a provider by name configured with its settings from
+// "providers.<name>". A non-empty key overrides the configured API key.
+func Get(name, key string) (Provider, error) {
+	var p Provider
+	if name == "opensubtitles" {
+		p = opensubtitles.New("")
+	} else if f, ok := factories[name]; ok {
+		p = f()
+	} else {
+		return nil, fmt.Errorf("unknown provider %s", name)
+	}
+	if c, ok := p.(Configurable); ok {
+		s := settings.Load(name, c.SettingsSchema())
+		if key != "" {
+			s.APIKey = key
+		}
+		if err := c.Configure(s); err != nil {
+			return nil, fmt.Errorf("configure provider %s: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+// Schema returns the settings schema of the named provider. Providers that
+// accept no settings have an empty schema.
+func Schema(name string) (settings.Schema, error) {
+	var p Provider
 	if name == "opensubtitles" {
-		return opensubtitles.New(""), nil
+		p = opensubtitles.New("")
+	} else if f, ok := factories[name]; ok {
+		p = f()
+	} else {
+		return nil, fmt.Errorf("unknown provider %s", name)
 	}
-	if f, ok := factories[name]; ok {
-		return f(), nil
+	if c, ok := p.(Configurable); ok {
+		return c.SettingsSchema(), nil
 	}
-	return nil, fmt.Errorf("unknown provider %s", name)
+	return nil, nil
 }
 
 // All returns the list of known provider names in alphabetical order.
@@ -3,8 +3,13 @@ package providers
 import (
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/generic"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/mocks"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 func TestRegisterFactoryAndGet(t *testing.T) {
@@ -54,3 +59,46 @@ func TestAllIncludesRegisteredSorted(t *testing.T) {
 		t.Fatalf("registered names missing: %v", names)
 	}
 }
+
+func TestGetInjectsSettings(t *testing.T) {
+	viper.Set("providers.generic.config.api_url", "http://subs.example")
+	viper.Set("providers.generic.config.timeout", "5s")
+	viper.Set("providers.generic.config.api_key", "stored")
+	t.Cleanup(viper.Reset)
+
+	p, err := Get("generic", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := p.(*generic.Client)
+	if c.APIURL != "http://subs.example" || c.APIKey != "stored" || c.HTTPClient.Timeout != 5*time.Second {
+		t.Fatalf("settings not applied: %+v", c)
+	}
+
+	// The key argument overrides the configured API key.
+	p, err = Get("generic", "override")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.(*generic.Client).APIKey != "override" {
+		t.Fatal("key argument ignored")
+	}
+
+	viper.Set("providers.generic.config.proxy", "::bad")
+	if _, err := Get("generic", ""); err == nil {
+		t.Fatal("expected error for invalid proxy")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s, err := Schema("opensubtitles")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := s.Field(settings.KeyVIP); !ok {
+		t.Fatalf("opensubtitles schema lacks vip: %+v", s)
+	}
+	if _, err := Schema("unknown-provider"); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+}
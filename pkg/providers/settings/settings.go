@@ -0,0 +1,421 @@
+// file: pkg/providers/settings/settings.go
+// version: 1.2.2
+// guid: 3a7c9e14-5b2d-4f86-a0e1-9d4b6c8f2e57
+
+// Package settings defines the per-provider settings schema: the credentials,
+// base URL override, VIP flag, timeout, proxy and Cloudflare challenge
+// solving a provider accepts. Providers return their Schema from
+// SettingsSchema; Load reads the matching values from the providers.<name>
+// configuration, and the web UI renders its provider forms from the schema.
+package settings
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/flaresolverr"
+)
+
+// Type is the kind of value a field holds.
+type Type string
+
+// Field types.
+const (
+	TypeString   Type = "string"
+	TypeSecret   Type = "secret"
+	TypeBool     Type = "bool"
+	TypeURL      Type = "url"
+	TypeDuration Type = "duration"
+)
+
+// Keys of the settings providers can declare.
+const (
+	KeyUsername = "username"
+	KeyPassword = "password"
+	KeyAPIKey   = "api_key"
+	KeyAPIURL   = "api_url"
+	KeyVIP      = "vip"
+	KeyTimeout  = "timeout"
+	KeyProxy    = "proxy"
+	// KeyFlareSolverr turns Cloudflare challenge solving off for a provider
+	// when false. KeyFlareSolverrURL overrides the global "flaresolverr.url".
+	KeyFlareSolverr    = "flaresolverr"
+	KeyFlareSolverrURL = "flaresolverr_url"
+)
+
+// Redacted replaces the values of secret fields returned to clients. Saving
+// it back leaves the stored secret unchanged.
+const Redacted = "********"
+
+// Field describes one setting for the web UI and validation.
+type Field struct {
+	Key      string `json:"key"`
+	Label    string `json:"label"`
+	Type     Type   `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	Help     string `json:"help,omitempty"`
+}
+
+// The fields providers combine into their schema.
+var (
+	Username = Field{Key: KeyUsername, Label: "Username", Type: TypeString}
+	Password = Field{Key: KeyPassword, Label: "Password", Type: TypeSecret}
+	APIKey   = Field{Key: KeyAPIKey, Label: "API key", Type: TypeSecret}
+	APIURL   = Field{Key: KeyAPIURL, Label: "API URL", Type: TypeURL, Help: "Overrides the provider's default base URL"}
+	VIP      = Field{Key: KeyVIP, Label: "VIP account", Type: TypeBool}
+	Timeout  = Field{Key: KeyTimeout, Label: "Timeout", Type: TypeDuration, Help: "Request timeout such as 30s"}
+	Proxy    = Field{Key: KeyProxy, Label: "Proxy", Type: TypeURL, Help: "HTTP or SOCKS5 proxy URL"}
+
+	FlareSolverr    = Field{Key: KeyFlareSolverr, Label: "Solve Cloudflare challenges", Type: TypeBool, Help: "Uses FlareSolverr when flaresolverr.url or the URL below is set; on by default"}
+	FlareSolverrURL = Field{Key: KeyFlareSolverrURL, Label: "FlareSolverr URL", Type: TypeURL, Help: "Overrides the global flaresolverr.url"}
+)
+
+// Connection is the schema of providers that only talk to an HTTP API
+// without credentials.
+var Connection = Schema{APIURL, Timeout, Proxy}
+
+// Challenged is the schema of providers scraping sites behind Cloudflare
+// challenges.
+var Challenged = Connection.With(FlareSolverr, FlareSolverrURL)
+
+// Schema lists the settings a provider accepts.
+type Schema []Field
+
+// With returns the schema extended by fields, which replace existing fields
+// with the same key.
+func (s Schema) With(fields ...Field) Schema {
+	out := make(Schema, 0, len(s)+len(fields))
+	for _, f := range s {
+		replaced := false
+		for _, g := range fields {
+			replaced = replaced || g.Key == f.Key
+		}
+		if !replaced {
+			out = append(out, f)
+		}
+	}
+	return append(out, fields...)
+}
+
+// Required returns s with the fields named by keys marked required.
+func (s Schema) Required(keys ...string) Schema {
+	out := append(Schema(nil), s...)
+	for i := range out {
+		for _, k := range keys {
+			if out[i].Key == k {
+				out[i].Required = true
+			}
+		}
+	}
+	return out
+}
+
+// Field returns the field with key.
+func (s Schema) Field(key string) (Field, bool) {
+	for _, f := range s {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Validate checks config, as submitted by a client, against the schema.
+// Unknown keys, values of the wrong type and, when enabled is set, missing
+// required values are rejected.
+func (s Schema) Validate(config map[string]any, enabled bool) error {
+	for key, v := range config {
+		f, ok := s.Field(key)
+		if !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		if err := f.check(v); err != nil {
+			return fmt.Errorf("%s: %w", f.Label, err)
+		}
+	}
+	if enabled {
+		for _, f := range s {
+			if f.Required && isEmpty(config[f.Key]) {
+				return fmt.Errorf("%s is required", f.Label)
+			}
+		}
+	}
+	return nil
+}
+
+// Redact returns a copy of config with the values of secret fields replaced
+// by Redacted.
+func (s Schema) Redact(config map[string]any) map[string]any {
+	out := make(map[string]any, len(config))
+	for k, v := range config {
+		if f, ok := s.Field(k); ok && f.Type == TypeSecret && !isEmpty(v) {
+			v = Redacted
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Merge returns config with secret values that are still Redacted replaced
+// by their value in stored.
+func (s Schema) Merge(config, stored map[string]any) map[string]any {
+	out := make(map[string]any, len(config))
+	for k, v := range config {
+		if f, ok := s.Field(k); ok && f.Type == TypeSecret && v == Redacted {
+			v = stored[k]
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// check validates a single submitted value.
+func (f Field) check(v any) error {
+	if isEmpty(v) {
+		return nil
+	}
+	switch f.Type {
+	case TypeBool:
+		switch b := v.(type) {
+		case bool:
+		case string:
+			if _, err := strconv.ParseBool(b); err != nil {
+				return fmt.Errorf("invalid boolean %q", b)
+			}
+		default:
+			return fmt.Errorf("expected a boolean")
+		}
+	case TypeDuration:
+		switch d := v.(type) {
+		case float64, int:
+		case string:
+			if _, err := parseDuration(d); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("expected a duration")
+		}
+	case TypeURL:
+		u, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected a URL")
+		}
+		if _, err := parseURL(u); err != nil {
+			return err
+		}
+	default:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("expected a string")
+		}
+	}
+	return nil
+}
+
+// Settings are the values of one provider's settings. Zero values mean the
+// provider's own default applies.
+type Settings struct {
+	Username string
+	Password string
+	APIKey   string
+	APIURL   string
+	VIP      bool
+	Timeout  time.Duration
+	Proxy    string
+	// FlareSolverr is the FlareSolverr instance solving Cloudflare
+	// challenges, or empty when challenges are not solved.
+	FlareSolverr string
+	// FlareSolverrTimeout bounds the time spent on one challenge.
+	FlareSolverrTimeout time.Duration
+}
+
+// Load reads the settings of provider name from the configuration. Values
+// under "providers.<name>.config", where the web UI stores them, take
+// precedence over values directly under "providers.<name>". Only the keys in
+// schema are read. Providers declaring the FlareSolverr fields use the
+// global "flaresolverr.url" and "flaresolverr.timeout" unless overridden or
+// turned off.
+func Load(name string, schema Schema) Settings {
+	get := func(key string) string {
+		for _, k := range []string{"providers." + name + ".config." + key, "providers." + name + "." + key} {
+			if viper.IsSet(k) {
+				return viper.GetString(k)
+			}
+		}
+		return ""
+	}
+	var s Settings
+	solve, off := false, false
+	for _, f := range schema {
+		v := get(f.Key)
+		switch f.Key {
+		case KeyUsername:
+			s.Username = v
+		case KeyPassword:
+			s.Password = v
+		case KeyAPIKey:
+			s.APIKey = v
+		case KeyAPIURL:
+			s.APIURL = v
+		case KeyVIP:
+			s.VIP, _ = strconv.ParseBool(v)
+		case KeyTimeout:
+			s.Timeout, _ = parseDuration(v)
+		case KeyProxy:
+			s.Proxy = v
+		case KeyFlareSolverr:
+			solve = true
+			if b, err := strconv.ParseBool(v); err == nil {
+				off = !b
+			}
+		case KeyFlareSolverrURL:
+			solve = true
+			s.FlareSolverr = v
+		}
+	}
+	if solve && !off {
+		if s.FlareSolverr == "" {
+			s.FlareSolverr = viper.GetString("flaresolverr.url")
+		}
+		s.FlareSolverrTimeout, _ = parseDuration(viper.GetString("flaresolverr.timeout"))
+	} else {
+		s.FlareSolverr = ""
+	}
+	return s
+}
+
+// HTTPClient returns base adjusted to the timeout, proxy and FlareSolverr
+// settings. base itself is left unchanged; a nil base stands for a client
+// with a 15 second timeout. With FlareSolverr, challenges are solved by the
+// client's transport, through the proxy when one is set, and the timeout is
+// extended by the time one challenge may take.
+func (s Settings) HTTPClient(base *http.Client) (*http.Client, error) {
+	c := &http.Client{Timeout: 15 * time.Second}
+	if base != nil {
+		cp := *base
+		c = &cp
+	}
+	if s.Timeout > 0 {
+		c.Timeout = s.Timeout
+	}
+	if s.Proxy != "" {
+		u, err := parseURL(s.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		t, ok := c.Transport.(*http.Transport)
+		if !ok || t == nil {
+			t = http.DefaultTransport.(*http.Transport)
+		}
+		t = t.Clone()
+		t.Proxy = http.ProxyURL(u)
+		c.Transport = t
+	}
+	if s.FlareSolverr != "" {
+		if _, err := parseURL(s.FlareSolverr); err != nil {
+			return nil, fmt.Errorf("flaresolverr: %w", err)
+		}
+		solver := flaresolverr.New(s.FlareSolverr, s.FlareSolverrTimeout)
+		solver.Proxy = s.Proxy
+		base := c.Transport
+		if ft, ok := base.(*flaresolverr.Transport); ok {
+			base = ft.Base
+		}
+		c.Transport = &flaresolverr.Transport{Base: base, Solver: solver}
+		if c.Timeout > 0 {
+			c.Timeout += solver.Timeout
+		}
+	}
+	return c, nil
+}
+
+// HTTP holds the base URL and HTTP client of a provider. Providers that
+// embed it accept the Connection settings through its SettingsSchema and
+// Configure methods; providers taking credentials or other settings
+// declare their own.
+type HTTP struct {
+	// APIURL is the base URL of the provider's site or API.
+	APIURL string
+	// HTTPClient is used to make requests.
+	HTTPClient *http.Client
+}
+
+// NewHTTP returns an HTTP for apiURL whose client times out after 15
+// seconds.
+func NewHTTP(apiURL string) HTTP {
+	return HTTP{APIURL: apiURL, HTTPClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// SettingsSchema declares the Connection settings.
+func (h *HTTP) SettingsSchema() Schema {
+	return Connection
+}
+
+// Configure applies the API URL, timeout, proxy and FlareSolverr settings.
+func (h *HTTP) Configure(s Settings) error {
+	return s.ApplyHTTP(&h.APIURL, &h.HTTPClient)
+}
+
+// ChallengedHTTP is the HTTP of a provider scraping a site behind
+// Cloudflare challenges. It declares the Challenged settings.
+type ChallengedHTTP struct {
+	HTTP
+}
+
+// NewChallengedHTTP returns a ChallengedHTTP for apiURL whose client times
+// out after 15 seconds.
+func NewChallengedHTTP(apiURL string) ChallengedHTTP {
+	return ChallengedHTTP{NewHTTP(apiURL)}
+}
+
+// SettingsSchema declares the Challenged settings.
+func (h *ChallengedHTTP) SettingsSchema() Schema {
+	return Challenged
+}
+
+// ApplyHTTP applies the base URL, timeout, proxy and FlareSolverr settings
+// to the API URL and HTTP client of a provider.
+func (s Settings) ApplyHTTP(apiURL *string, client **http.Client) error {
+	if s.APIURL != "" {
+		if _, err := parseURL(s.APIURL); err != nil {
+			return fmt.Errorf("api url: %w", err)
+		}
+		*apiURL = s.APIURL
+	}
+	c, err := s.HTTPClient(*client)
+	if err != nil {
+		return err
+	}
+	*client = c
+	return nil
+}
+
+// parseDuration accepts Go durations such as "30s" and plain numbers of
+// seconds.
+func parseDuration(v string) (time.Duration, error) {
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return d, nil
+}
+
+// parseURL accepts absolute URLs with a host.
+func parseURL(v string) (*url.URL, error) {
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", v)
+	}
+	return u, nil
+}
+
+func isEmpty(v any) bool {
+	return v == nil || v == ""
+}
@@ -0,0 +1,114 @@
+// file: pkg/providers/settings/settings_test.go
+// version: 1.2.1
+// guid: 8e2b5d47-1c9a-4f30-b6e8-3a7d9c1f5b62
+
+package settings
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/flaresolverr"
+)
+
+var schema = Connection.With(Username, Password, VIP).Required(KeyUsername)
+
+func TestLoadPrefersUIConfig(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("providers.test.username", "direct")
+	viper.Set("providers.test.password", "secret")
+	viper.Set("providers.test.config.username", "ui")
+	viper.Set("providers.test.config.vip", true)
+	viper.Set("providers.test.config.timeout", 20)
+	viper.Set("providers.test.config.api_key", "ignored")
+
+	s := Load("test", schema)
+	assert.Equal(t, Settings{Username: "ui", Password: "secret", VIP: true, Timeout: 20 * time.Second}, s)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, schema.Validate(map[string]any{"username": "u", "timeout": "1m", "vip": true}, true))
+	assert.NoError(t, schema.Validate(map[string]any{}, false))
+	assert.ErrorContains(t, schema.Validate(map[string]any{}, true), "Username is required")
+	assert.ErrorContains(t, schema.Validate(map[string]any{"api_key": "k"}, false), "unknown setting")
+	assert.Error(t, schema.Validate(map[string]any{"timeout": "soon"}, false))
+	assert.Error(t, schema.Validate(map[string]any{"proxy": "localhost"}, false))
+	assert.Error(t, schema.Validate(map[string]any{"vip": "maybe"}, false))
+}
+
+func TestRedactAndMerge(t *testing.T) {
+	stored := map[string]any{"username": "u", "password": "secret"}
+	redacted := schema.Redact(stored)
+	assert.Equal(t, map[string]any{"username": "u", "password": Redacted}, redacted)
+
+	// Saving the redacted value back keeps the stored secret.
+	assert.Equal(t, stored, schema.Merge(redacted, stored))
+	changed := map[string]any{"username": "u", "password": "new"}
+	assert.Equal(t, changed, schema.Merge(changed, stored))
+}
+
+func TestApplyHTTP(t *testing.T) {
+	base := &http.Client{Timeout: 15 * time.Second}
+	client := base
+	apiURL := "https://default.example"
+	s := Settings{APIURL: "http://override.example", Timeout: time.Minute, Proxy: "socks5://127.0.0.1:1080"}
+	require.NoError(t, s.ApplyHTTP(&apiURL, &client))
+	assert.Equal(t, "http://override.example", apiURL)
+	assert.Equal(t, time.Minute, client.Timeout)
+	assert.Equal(t, 15*time.Second, base.Timeout, "base client is not modified")
+
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	require.NoError(t, err)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, "socks5://127.0.0.1:1080", proxy.String())
+
+	assert.Error(t, Settings{APIURL: "not a url"}.ApplyHTTP(&apiURL, &client))
+}
+
+func TestLoadFlareSolverr(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("flaresolverr.url", "http://solver:8191")
+	viper.Set("flaresolverr.timeout", "90s")
+	viper.Set("providers.custom.flaresolverr_url", "http://other:8191")
+	viper.Set("providers.off.config.flaresolverr", false)
+
+	s := Load("plain", Challenged)
+	assert.Equal(t, "http://solver:8191", s.FlareSolverr)
+	assert.Equal(t, 90*time.Second, s.FlareSolverrTimeout)
+	assert.Equal(t, "http://other:8191", Load("custom", Challenged).FlareSolverr)
+	assert.Empty(t, Load("off", Challenged).FlareSolverr)
+	// Providers without the fields never use the solver.
+	assert.Empty(t, Load("plain", Connection).FlareSolverr)
+
+	c, err := s.HTTPClient(nil)
+	require.NoError(t, err)
+	assert.IsType(t, &flaresolverr.Transport{}, c.Transport)
+	assert.Equal(t, 15*time.Second+90*time.Second, c.Timeout)
+
+	// The solver's browser uses the provider proxy.
+	s.Proxy = "http://proxy:3128"
+	c, err = s.HTTPClient(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy:3128", c.Transport.(*flaresolverr.Transport).Solver.Proxy)
+}
+
+// TestEmbeddedHTTP verifies the schema and Configure method providers get
+// by embedding HTTP or ChallengedHTTP.
+func TestEmbeddedHTTP(t *testing.T) {
+	h := NewHTTP("https://example.com")
+	assert.Equal(t, Connection, h.SettingsSchema())
+	require.NoError(t, h.Configure(Settings{APIURL: "https://override.example", Timeout: time.Minute}))
+	assert.Equal(t, "https://override.example", h.APIURL)
+	assert.Equal(t, time.Minute, h.HTTPClient.Timeout)
+
+	c := NewChallengedHTTP("https://example.com")
+	assert.Equal(t, Challenged, c.SettingsSchema())
+	require.NoError(t, c.Configure(Settings{FlareSolverr: "http://localhost:8191"}))
+	assert.IsType(t, &flaresolverr.Transport{}, c.HTTPClient.Transport)
+}
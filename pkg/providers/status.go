@@ -5,6 +5,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/quota"
 )
 
 // Status represents the availability of a provider.
@@ -12,6 +14,8 @@ type Status struct {
 	Name      string    `json:"name"`
 	Available bool      `json:"available"`
 	CheckedAt time.Time `json:"checked_at"`
+	// Quota is the last download quota reported by the provider.
+	Quota *quota.Quota `json:"quota,omitempty"`
 }
 
 var (
@@ -24,8 +28,41 @@ func Refresh(ctx context.Context, names []string) {
 	statusMu.Lock()
 	defer statusMu.Unlock()
 	for _, n := range names {
-		statusMap[n] = Status{Name: n, Available: true, CheckedAt: time.Now()}
+		statusMap[n] = Status{Name: n, Available: true, CheckedAt: time.Now(), Quota: statusMap[n].Quota}
+	}
+}
+
+// QuotaReporter is implemented by providers with a download quota.
+type QuotaReporter interface {
+	// Quota returns the last quota reported by the provider, if any.
+	Quota() (quota.Quota, bool)
+}
+
+// RecordQuota stores the quota of p, when it reports one, in the status of
+// provider name. A provider with no downloads left is marked unavailable.
+func RecordQuota(name string, p Provider) {
+	qr, ok := p.(QuotaReporter)
+	if !ok {
+		return
+	}
+	q, ok := qr.Quota()
+	if !ok {
+		return
+	}
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	now := time.Now()
+	statusMap[name] = Status{Name: name, Available: !q.Exhausted(now), CheckedAt: now, Quota: &q}
+}
+
+// BackoffOnQuota backs provider instance id off until its quota resets when
+// err reports an exceeded quota, and reports whether it did.
+func BackoffOnQuota(id string, err error) bool {
+	d, ok := quota.Wait(err, time.Now())
+	if ok {
+		SetBackoff(id, d)
 	}
+	return ok
 }
 
 // Reset clears all stored provider status information.
@@ -6,6 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/jdfalk/subtitle-manager/pkg/flaresolverr"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // TestClientFetch verifies that the client downloads subtitles using the expected URL.
@@ -30,3 +33,34 @@ func TestClientFetch(t *testing.T) {
 		t.Fatalf("unexpected url: %s", gotURL)
 	}
 }
+
+// TestClientFetchSolvesChallenge verifies that a configured FlareSolverr
+// instance gets Fetch past a Cloudflare challenge.
+func TestClientFetchSolvesChallenge(t *testing.T) {
+	defer flaresolverr.Reset()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("cf_clearance"); err != nil || c.Value != "cleared" {
+			w.Header().Set("Cf-Mitigated", "challenge")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "data")
+	}))
+	defer site.Close()
+	solver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"ok","solution":{"cookies":[{"name":"cf_clearance","value":"cleared"}],"userAgent":"Mozilla/5.0"}}`)
+	}))
+	defer solver.Close()
+
+	c := New()
+	if err := c.Configure(settings.Settings{APIURL: site.URL, FlareSolverr: solver.URL}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	b, err := c.Fetch(context.Background(), "/path/movie.mkv", "en")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if string(b) != "data" {
+		t.Fatalf("unexpected body: %s", b)
+	}
+}
@@ -6,25 +6,20 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
-	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // Client implements the providers.Provider interface for Subscene.
 // It performs a simple HTTP GET to download subtitles for a given
 // media file and language.
 type Client struct {
-	// APIURL is the base URL of the Subscene API.
-	APIURL string
-	// HTTPClient is used to make requests.
-	HTTPClient *http.Client
+	settings.ChallengedHTTP
 }
 
 // New returns a Client configured with reasonable defaults.
 func New() *Client {
-	return &Client{
-		APIURL:     "https://api.subscene.com",
-		HTTPClient: &http.Client{Timeout: 15 * time.Second},
-	}
+	return &Client{ChallengedHTTP: settings.NewChallengedHTTP("https://api.subscene.com")}
 }
 
 // Fetch downloads the subtitle for mediaPath in lang.
@@ -11,6 +11,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 type roundTripperFunc func(*http.Request) (*http.Response, error)
@@ -56,10 +58,7 @@ func TestClientFetch_Success_ReturnsBody(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := &Client{
-		APIURL:     server.URL,
-		HTTPClient: server.Client(),
-	}
+	client := &Client{HTTP: settings.HTTP{APIURL: server.URL, HTTPClient: server.Client()}}
 
 	// Act
 	data, err := client.Fetch(context.Background(), mediaPath, lang)
@@ -85,10 +84,7 @@ func TestClientFetch_StatusNotOK_ReturnsError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := &Client{
-		APIURL:     server.URL,
-		HTTPClient: server.Client(),
-	}
+	client := &Client{HTTP: settings.HTTP{APIURL: server.URL, HTTPClient: server.Client()}}
 
 	// Act
 	data, err := client.Fetch(context.Background(), "/tmp/video.mp4", "fr")
@@ -106,14 +102,14 @@ func TestClientFetch_StatusNotOK_ReturnsError(t *testing.T) {
 func TestClientFetch_RequestError_ReturnsError(t *testing.T) {
 	// Arrange
 	transportErr := errors.New("transport failure")
-	client := &Client{
+	client := &Client{HTTP: settings.HTTP{
 		APIURL: "https://example.invalid",
 		HTTPClient: &http.Client{
 			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
 				return nil, transportErr
 			}),
 		},
-	}
+	}}
 
 	// Act
 	data, err := client.Fetch(context.Background(), "/tmp/video.mp4", "es")
@@ -133,10 +129,7 @@ func TestClientFetch_RequestError_ReturnsError(t *testing.T) {
 // TestClientFetch_InvalidURL_ReturnsError verifies invalid URL input fails early.
 func TestClientFetch_InvalidURL_ReturnsError(t *testing.T) {
 	// Arrange
-	client := &Client{
-		APIURL:     "http://[::1",
-		HTTPClient: &http.Client{},
-	}
+	client := &Client{HTTP: settings.HTTP{APIURL: "http://[::1", HTTPClient: &http.Client{}}}
 
 	// Act
 	data, err := client.Fetch(context.Background(), "/tmp/video.mp4", "de")
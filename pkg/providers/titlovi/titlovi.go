@@ -7,19 +7,34 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
-	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 type Client struct {
-	APIURL     string
-	HTTPClient *http.Client
+	settings.HTTP
+
+	username string
+	password string
 }
 
 func New() *Client {
-	return &Client{
-		APIURL:     "https://api.titlovi.com",
-		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	return &Client{HTTP: settings.NewHTTP("https://api.titlovi.com")}
+}
+
+// SettingsSchema declares the settings the provider accepts. Titlovi only
+// serves members, so the username and password are required.
+func (c *Client) SettingsSchema() settings.Schema {
+	return settings.Connection.With(settings.Username, settings.Password).Required(settings.KeyUsername, settings.KeyPassword)
+}
+
+// Configure applies the credentials, API URL, timeout and proxy settings.
+func (c *Client) Configure(s settings.Settings) error {
+	if err := c.HTTP.Configure(s); err != nil {
+		return err
 	}
+	c.username, c.password = s.Username, s.Password
+	return nil
 }
 
 func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
@@ -29,6 +44,9 @@ func (c *Client) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, err
 	if err != nil {
 		return nil, err
 	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -1,5 +1,5 @@
 // file: pkg/queue/jobs.go
-// version: 1.0.0
+// version: 1.6.0
 // guid: 123e4567-e89b-12d3-a456-426614174001
 package queue
 
@@ -8,12 +8,15 @@ import (
 	"fmt"
 
 	"github.com/jdfalk/gcommon/sdks/go/v1/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/database"
 	jobpb "github.com/jdfalk/subtitle-manager/pkg/jobpb"
 	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
-// JobType represents the type of translation job.
+// JobType represents the type of a queued job.
 type JobType string
 
 const (
@@ -21,9 +24,29 @@ const (
 	JobTypeSingleFile JobType = "single_file"
 	// JobTypeBatchFiles represents a batch file translation job.
 	JobTypeBatchFiles JobType = "batch_files"
+	// JobTypeScan represents a directory scan downloading missing subtitles.
+	JobTypeScan JobType = "scan"
+	// JobTypeSync represents a library metadata scan and Radarr/Sonarr sync.
+	JobTypeSync JobType = "sync"
+	// JobTypeTranscribe represents a media transcription job.
+	JobTypeTranscribe JobType = "transcribe"
+	// JobTypeExtract represents an embedded subtitle extraction job.
+	JobTypeExtract JobType = "extract"
+	// JobTypeSearchDownload represents a provider search and download for one file.
+	JobTypeSearchDownload JobType = "search_download"
+	// JobTypeUpgrade represents a subtitle upgrade check for a file or directory.
+	JobTypeUpgrade JobType = "upgrade"
+	// JobTypeEngineTranscription represents an engine service transcription
+	// with optional translations of the transcript.
+	JobTypeEngineTranscription JobType = "engine_transcription"
+	// JobTypeEngineTranslation represents an engine service subtitle translation.
+	JobTypeEngineTranslation JobType = "engine_translation"
+	// JobTypeWebTranslation represents a subtitle translation submitted
+	// through the web service.
+	JobTypeWebTranslation JobType = "web_translation"
 )
 
-// Job represents a translation job that can be queued for asynchronous processing.
+// Job represents a job that can be queued for asynchronous processing.
 type Job interface {
 	// ID returns the unique identifier for this job.
 	ID() string
@@ -37,15 +60,15 @@ type Job interface {
 	QueueMessage() (*queue.QueueMessage, error)
 }
 
-// SingleFileJob represents a job to translate a single subtitle file.
+// SingleFileJob represents a job to translate a single subtitle file. The
+// translation API keys are read from the configuration when the job runs so
+// they are never persisted with the job.
 type SingleFileJob struct {
 	JobID      string
 	InputPath  string
 	OutputPath string
 	Language   string
 	Service    string
-	GoogleKey  string
-	GPTKey     string
 	GRPCAddr   string
 }
 
@@ -61,13 +84,13 @@ func (j *SingleFileJob) Type() JobType {
 
 // Execute performs the translation.
 func (j *SingleFileJob) Execute(ctx context.Context) error {
-	return subtitles.TranslateFileToSRT(
+	return subtitles.TranslateFile(
 		j.InputPath,
 		j.OutputPath,
 		j.Language,
 		j.Service,
-		j.GoogleKey,
-		j.GPTKey,
+		viper.GetString("google_api_key"),
+		viper.GetString("openai_api_key"),
 		j.GRPCAddr,
 	)
 }
@@ -84,8 +107,6 @@ func (j *SingleFileJob) QueueMessage() (*queue.QueueMessage, error) {
 	job.SetOutputPath(j.OutputPath)
 	job.SetLanguage(j.Language)
 	job.SetService(j.Service)
-	job.SetGoogleKey(j.GoogleKey)
-	job.SetGptKey(j.GPTKey)
 	job.SetGrpcAddr(j.GRPCAddr)
 	job.SetWorkers(1)
 
@@ -100,14 +121,13 @@ func (j *SingleFileJob) QueueMessage() (*queue.QueueMessage, error) {
 	return queueMsg, nil
 }
 
-// BatchFilesJob represents a job to translate multiple subtitle files.
+// BatchFilesJob represents a job to translate multiple subtitle files. Like
+// SingleFileJob it reads the translation API keys when it runs.
 type BatchFilesJob struct {
 	JobID      string
 	InputPaths []string
 	Language   string
 	Service    string
-	GoogleKey  string
-	GPTKey     string
 	GRPCAddr   string
 	Workers    int
 }
@@ -124,12 +144,12 @@ func (j *BatchFilesJob) Type() JobType {
 
 // Execute performs the batch translation.
 func (j *BatchFilesJob) Execute(ctx context.Context) error {
-	return subtitles.TranslateFilesToSRT(
+	return subtitles.TranslateFiles(
 		j.InputPaths,
 		j.Language,
 		j.Service,
-		j.GoogleKey,
-		j.GPTKey,
+		viper.GetString("google_api_key"),
+		viper.GetString("openai_api_key"),
 		j.GRPCAddr,
 		j.Workers,
 	)
@@ -146,8 +166,6 @@ func (j *BatchFilesJob) QueueMessage() (*queue.QueueMessage, error) {
 	job.SetInputPaths(j.InputPaths)
 	job.SetLanguage(j.Language)
 	job.SetService(j.Service)
-	job.SetGoogleKey(j.GoogleKey)
-	job.SetGptKey(j.GPTKey)
 	job.SetGrpcAddr(j.GRPCAddr)
 	job.SetWorkers(int32(j.Workers))
 
@@ -161,3 +179,74 @@ func (j *BatchFilesJob) QueueMessage() (*queue.QueueMessage, error) {
 	queueMsg.SetBody(anyMsg)
 	return queueMsg, nil
 }
+
+func init() {
+	RegisterDecoder(JobTypeSingleFile, decodeSingleFileJob)
+	RegisterDecoder(JobTypeBatchFiles, decodeBatchFilesJob)
+}
+
+// decodeJob rebuilds a job persisted by a previous process from its stored
+// gcommon queue message using the decoder registered for its type.
+func decodeJob(rec *database.QueueJob) (Job, error) {
+	registryMu.RLock()
+	decode, ok := decoders[JobType(rec.Type)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("decode job %s: unknown job type %q", rec.ID, rec.Type)
+	}
+	msg := &queue.QueueMessage{}
+	if err := proto.Unmarshal(rec.Payload, msg); err != nil {
+		return nil, fmt.Errorf("decode job %s: %w", rec.ID, err)
+	}
+	job, err := decode(rec.ID, msg)
+	if err != nil {
+		return nil, fmt.Errorf("decode job %s: %w", rec.ID, err)
+	}
+	return job, nil
+}
+
+// translationJob unpacks the jobpb.TranslationJob body of msg.
+func translationJob(msg *queue.QueueMessage) (*jobpb.TranslationJob, error) {
+	if msg.GetBody() == nil {
+		return nil, fmt.Errorf("message has no body")
+	}
+	job := &jobpb.TranslationJob{}
+	if err := msg.GetBody().UnmarshalTo(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func decodeSingleFileJob(id string, msg *queue.QueueMessage) (Job, error) {
+	job, err := translationJob(msg)
+	if err != nil {
+		return nil, err
+	}
+	inputs := job.GetInputPaths()
+	if len(inputs) != 1 {
+		return nil, fmt.Errorf("expected one input path, got %d", len(inputs))
+	}
+	return &SingleFileJob{
+		JobID:      id,
+		InputPath:  inputs[0],
+		OutputPath: job.GetOutputPath(),
+		Language:   job.GetLanguage(),
+		Service:    job.GetService(),
+		GRPCAddr:   job.GetGrpcAddr(),
+	}, nil
+}
+
+func decodeBatchFilesJob(id string, msg *queue.QueueMessage) (Job, error) {
+	job, err := translationJob(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &BatchFilesJob{
+		JobID:      id,
+		InputPaths: job.GetInputPaths(),
+		Language:   job.GetLanguage(),
+		Service:    job.GetService(),
+		GRPCAddr:   job.GetGrpcAddr(),
+		Workers:    int(job.GetWorkers()),
+	}, nil
+}
@@ -1,5 +1,5 @@
 // file: pkg/queue/jobs_test.go
-// version: 1.23.0
+// version: 1.24.0
 // guid: cf3906ee-32bb-4288-ba78-d2b0417369f8
 package queue
 
@@ -17,8 +17,6 @@ func TestSingleFileJobQueueMessage(t *testing.T) {
 		OutputPath: "/media/show/episode.en.srt",
 		Language:   "en",
 		Service:    "google",
-		GoogleKey:  "google-key",
-		GPTKey:     "gpt-key",
 		GRPCAddr:   "localhost:9090",
 	}
 
@@ -51,11 +49,8 @@ func TestSingleFileJobQueueMessage(t *testing.T) {
 	if payload.GetService() != job.Service {
 		t.Errorf("payload service = %q, want %q", payload.GetService(), job.Service)
 	}
-	if payload.GetGoogleKey() != job.GoogleKey {
-		t.Errorf("payload google key = %q, want %q", payload.GetGoogleKey(), job.GoogleKey)
-	}
-	if payload.GetGptKey() != job.GPTKey {
-		t.Errorf("payload gpt key = %q, want %q", payload.GetGptKey(), job.GPTKey)
+	if payload.GetGoogleKey() != "" || payload.GetGptKey() != "" {
+		t.Errorf("payload carries API keys: %q, %q", payload.GetGoogleKey(), payload.GetGptKey())
 	}
 	if payload.GetGrpcAddr() != job.GRPCAddr {
 		t.Errorf("payload grpc addr = %q, want %q", payload.GetGrpcAddr(), job.GRPCAddr)
@@ -72,8 +67,6 @@ func TestBatchFilesJobQueueMessage(t *testing.T) {
 		InputPaths: []string{"/media/a.srt", "/media/b.srt"},
 		Language:   "es",
 		Service:    "grpc",
-		GoogleKey:  "unused",
-		GPTKey:     "unused",
 		GRPCAddr:   "localhost:7070",
 		Workers:    3,
 	}
@@ -110,11 +103,8 @@ func TestBatchFilesJobQueueMessage(t *testing.T) {
 	if payload.GetService() != job.Service {
 		t.Errorf("payload service = %q, want %q", payload.GetService(), job.Service)
 	}
-	if payload.GetGoogleKey() != job.GoogleKey {
-		t.Errorf("payload google key = %q, want %q", payload.GetGoogleKey(), job.GoogleKey)
-	}
-	if payload.GetGptKey() != job.GPTKey {
-		t.Errorf("payload gpt key = %q, want %q", payload.GetGptKey(), job.GPTKey)
+	if payload.GetGoogleKey() != "" || payload.GetGptKey() != "" {
+		t.Errorf("payload carries API keys: %q, %q", payload.GetGoogleKey(), payload.GetGptKey())
 	}
 	if payload.GetGrpcAddr() != job.GRPCAddr {
 		t.Errorf("payload grpc addr = %q, want %q", payload.GetGrpcAddr(), job.GRPCAddr)
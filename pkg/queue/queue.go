@@ -1,5 +1,5 @@
 // file: pkg/queue/queue.go
-// version: 1.0.0
+// version: 2.7.1
 // guid: 123e4567-e89b-12d3-a456-426614174002
 package queue
 
@@ -10,39 +10,129 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jdfalk/gcommon/sdks/go/v1/queue"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/tasks"
 )
 
-// Queue manages asynchronous translation jobs using a worker pool.
+const (
+	// DefaultWorkers is the worker count of the global queue.
+	DefaultWorkers = 3
+	// DefaultMaxAttempts is the number of executions before a failing job is
+	// moved to the dead letter state.
+	DefaultMaxAttempts = 3
+	// DefaultRetryBase is the delay before the first retry. Each further
+	// retry doubles it up to DefaultRetryMax.
+	DefaultRetryBase = 30 * time.Second
+	// DefaultRetryMax caps the retry delay.
+	DefaultRetryMax = 30 * time.Minute
+	// DefaultRetention is how long finished jobs are kept before they are
+	// pruned.
+	DefaultRetention = 7 * 24 * time.Hour
+	// defaultPollInterval bounds how long idle workers wait before looking
+	// for delayed or retried jobs that became due.
+	defaultPollInterval = time.Second
+	// defaultPruneInterval is how often finished jobs are pruned.
+	defaultPruneInterval = time.Hour
+	// ownerAttribute is the queue message attribute holding JobOptions.Owner.
+	ownerAttribute = "owner"
+)
+
+// JobOptions controls how a job is scheduled.
+type JobOptions struct {
+	// Priority orders ready jobs; higher values run first.
+	Priority int
+	// Delay postpones the first execution.
+	Delay time.Duration
+	// MaxAttempts limits executions before the job is dead lettered.
+	// Zero uses DefaultMaxAttempts.
+	MaxAttempts int
+	// Owner identifies the user who queued the job. It is stored with the
+	// queue message and reported by Queue.Owner.
+	Owner string
+}
+
+// Queue manages asynchronous jobs using a worker pool. Jobs are recorded in a
+// JobStore and delivered at least once: jobs interrupted by a shutdown or
+// crash are picked up again by the next Start.
 type Queue struct {
-	mu      sync.RWMutex
-	jobs    chan Job
-	workers int
-	running bool
-	logger  *logrus.Entry
-	ctx     context.Context
-	cancel  context.CancelFunc
-	wg      sync.WaitGroup
-	stopped bool // Track if queue has been stopped
-}
-
-// NewQueue creates a new translation queue with the specified number of workers.
+	// lifecycle serializes Start and Stop; mu guards the fields below and is
+	// not held while Stop waits for the workers, which may need it.
+	lifecycle sync.Mutex
+	mu        sync.RWMutex
+	store     JobStore
+	workers   int
+	running   bool
+	logger    *logrus.Entry
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	wake      chan struct{}
+
+	// live holds jobs added by this process so they run as the original
+	// values; jobs left by a previous process are decoded from their payload.
+	liveMu sync.Mutex
+	live   map[string]Job
+
+	retryBase     time.Duration
+	retryMax      time.Duration
+	pollInterval  time.Duration
+	retention     time.Duration
+	pruneInterval time.Duration
+}
+
+// NewQueue creates a queue with the specified number of workers whose jobs
+// are kept in memory only.
 func NewQueue(workers int) *Queue {
+	return NewPersistentQueue(workers, newMemoryStore())
+}
+
+// NewPersistentQueue creates a queue with the specified number of workers
+// whose jobs are persisted in store and resumed when the queue starts.
+func NewPersistentQueue(workers int, store JobStore) *Queue {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Queue{
-		jobs:    make(chan Job, 100), // Buffer of 100 jobs
-		workers: workers,
-		logger:  logging.GetLogger("queue"),
-		ctx:     ctx,
-		cancel:  cancel,
+		store:         store,
+		workers:       workers,
+		logger:        logging.GetLogger("queue"),
+		ctx:           ctx,
+		cancel:        cancel,
+		wake:          make(chan struct{}, workers),
+		live:          make(map[string]Job),
+		retryBase:     DefaultRetryBase,
+		retryMax:      DefaultRetryMax,
+		pollInterval:  defaultPollInterval,
+		retention:     DefaultRetention,
+		pruneInterval: defaultPruneInterval,
 	}
 }
 
-// Start begins processing jobs in the queue with the configured number of workers.
+// SetRetryBackoff configures the exponential retry delay of failed jobs.
+func (q *Queue) SetRetryBackoff(base, max time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.retryBase = base
+	q.retryMax = max
+}
+
+// SetRetention configures how long finished jobs are kept. Zero keeps them
+// until Prune is called.
+func (q *Queue) SetRetention(retention time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.retention = retention
+}
+
+// Start begins processing jobs in the queue with the configured number of
+// workers. Jobs left running by a previous process are made pending again,
+// and finished jobs older than the retention are pruned periodically.
 func (q *Queue) Start() error {
+	q.lifecycle.Lock()
+	defer q.lifecycle.Unlock()
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -50,42 +140,99 @@ func (q *Queue) Start() error {
 		return fmt.Errorf("queue is already running")
 	}
 
-	// Reinitialize context and jobs channel if they were closed from a previous Stop()
-	if q.stopped {
+	if err := q.recoverInterrupted(); err != nil {
+		return fmt.Errorf("resume jobs: %w", err)
+	}
+
+	// Reinitialize the context if it was cancelled by a previous Stop()
+	if q.ctx.Err() != nil {
 		q.ctx, q.cancel = context.WithCancel(context.Background())
-		q.jobs = make(chan Job, 100) // Buffer of 100 jobs
-		q.stopped = false
 	}
 
-	q.logger.Infof("Starting translation queue with %d workers", q.workers)
+	q.logger.Infof("Starting job queue with %d workers", q.workers)
 	q.running = true
 
 	// Start worker goroutines
 	for i := 0; i < q.workers; i++ {
 		q.wg.Add(1)
-		go q.worker(i)
+		go q.worker(q.ctx, i)
+	}
+	if q.retention > 0 {
+		q.wg.Add(1)
+		go q.pruner(q.ctx, q.retention, q.pruneInterval)
 	}
 
 	return nil
 }
 
-// Stop gracefully shuts down the queue, waiting for current jobs to complete.
+// pruner removes finished jobs older than retention every interval until ctx
+// is cancelled.
+func (q *Queue) pruner(ctx context.Context, retention, interval time.Duration) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if removed, err := q.Prune(time.Now().Add(-retention)); err != nil {
+			q.logger.Warnf("Failed to prune finished jobs: %v", err)
+		} else if removed > 0 {
+			q.logger.Infof("Pruned %d finished jobs", removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverInterrupted returns jobs marked running to the pending state. A job
+// that already used all of its attempts is dead lettered instead so a job
+// which crashes the process cannot loop forever.
+func (q *Queue) recoverInterrupted() error {
+	recs, err := q.store.ListQueueJobs(StatusRunning)
+	if err != nil {
+		return err
+	}
+	for i := range recs {
+		rec := &recs[i]
+		if rec.Attempts >= rec.MaxAttempts {
+			rec.Status = StatusDead
+			rec.LastError = "interrupted"
+		} else {
+			rec.Status = StatusPending
+			rec.RunAt = time.Now()
+		}
+		if err := q.store.UpdateQueueJob(rec); err != nil {
+			return err
+		}
+	}
+	if len(recs) > 0 {
+		q.logger.Infof("Recovered %d interrupted jobs", len(recs))
+	}
+	return nil
+}
+
+// Stop gracefully shuts down the queue. It cancels running jobs and waits
+// for them to return; jobs interrupted this way are returned to the pending
+// state and resume on the next Start.
 func (q *Queue) Stop() error {
-	q.mu.Lock()
-	defer q.mu.Unlock()
+	q.lifecycle.Lock()
+	defer q.lifecycle.Unlock()
 
+	q.mu.Lock()
 	if !q.running {
+		q.mu.Unlock()
 		return fmt.Errorf("queue is not running")
 	}
-
-	q.logger.Info("Stopping translation queue")
+	q.logger.Info("Stopping job queue")
 	q.running = false
-	q.stopped = true
-	q.cancel()
-	close(q.jobs)
+	cancel := q.cancel
+	q.mu.Unlock()
+
+	cancel()
 	q.wg.Wait()
 
-	q.logger.Info("Translation queue stopped")
+	q.logger.Info("Job queue stopped")
 	return nil
 }
 
@@ -96,8 +243,14 @@ func (q *Queue) IsRunning() bool {
 	return q.running
 }
 
-// Add queues a job for asynchronous processing and returns the task ID for tracking.
+// Add queues a job with default options and returns the task ID for tracking.
 func (q *Queue) Add(job Job) (string, error) {
+	return q.AddWithOptions(job, JobOptions{})
+}
+
+// AddWithOptions persists a job for asynchronous processing and returns the
+// task ID for tracking.
+func (q *Queue) AddWithOptions(job Job, opts JobOptions) (string, error) {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 
@@ -109,69 +262,274 @@ func (q *Queue) Add(job Job) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("marshal job: %w", err)
 	}
+	msg.SetPriority(int32(opts.Priority))
+	if opts.Owner != "" {
+		attrs := make(map[string]string, len(msg.GetAttributes())+1)
+		for k, v := range msg.GetAttributes() {
+			attrs[k] = v
+		}
+		attrs[ownerAttribute] = opts.Owner
+		msg.SetAttributes(attrs)
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("marshal job: %w", err)
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	rec := &database.QueueJob{
+		ID:          job.ID(),
+		Type:        string(job.Type()),
+		Payload:     payload,
+		Priority:    opts.Priority,
+		Status:      StatusPending,
+		MaxAttempts: maxAttempts,
+		RunAt:       time.Now().Add(opts.Delay),
+	}
+
+	q.liveMu.Lock()
+	q.live[rec.ID] = job
+	q.liveMu.Unlock()
+	if err := q.store.InsertQueueJob(rec); err != nil {
+		q.forget(rec.ID)
+		return "", fmt.Errorf("persist job: %w", err)
+	}
 
 	select {
-	case q.jobs <- job:
-		q.logger.WithField("queue_message", msg).Infof("Queued job %s: %s", job.ID(), job.Description())
-		return job.ID(), nil
+	case q.wake <- struct{}{}:
 	default:
-		return "", fmt.Errorf("queue is full")
 	}
+	q.logger.WithField("queue_message", msg).Infof("Queued job %s: %s", job.ID(), job.Description())
+	return job.ID(), nil
+}
+
+// Job returns the stored record for the job id or nil when it is unknown.
+func (q *Queue) Job(id string) (*database.QueueJob, error) {
+	return q.store.GetQueueJob(id)
+}
+
+// Decode returns the job stored in rec, decoding it from the persisted queue
+// message when it was not added by this process.
+func (q *Queue) Decode(rec *database.QueueJob) (Job, error) {
+	return q.resolveJob(rec)
+}
+
+// Describe returns the description of the job stored in rec, or its type when
+// the job cannot be decoded.
+func (q *Queue) Describe(rec *database.QueueJob) string {
+	job, err := q.resolveJob(rec)
+	if err != nil {
+		return rec.Type
+	}
+	return job.Description()
 }
 
-// worker processes jobs from the queue.
-func (q *Queue) worker(id int) {
+// Owner returns the JobOptions.Owner the job in rec was added with, or an
+// empty string when it has no owner or its message cannot be decoded.
+func (q *Queue) Owner(rec *database.QueueJob) string {
+	msg := &queue.QueueMessage{}
+	if err := proto.Unmarshal(rec.Payload, msg); err != nil {
+		return ""
+	}
+	return msg.GetAttributes()[ownerAttribute]
+}
+
+// History returns jobs in the given status, or all jobs when status is empty.
+func (q *Queue) History(status string) ([]database.QueueJob, error) {
+	return q.store.ListQueueJobs(status)
+}
+
+// Cancel stops the job id. Pending jobs are marked cancelled in the store
+// only while still pending, so a worker claiming the job at the same time
+// either runs it or sees it cancelled. Running jobs have their task
+// cancelled. It returns false when the job is unknown or has already
+// finished.
+func (q *Queue) Cancel(id string) (bool, error) {
+	ok, err := q.store.CancelQueueJob(id)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		q.forget(id)
+		return true, nil
+	}
+	rec, err := q.store.GetQueueJob(id)
+	if err != nil || rec == nil {
+		return false, err
+	}
+	if rec.Status == StatusRunning {
+		return tasks.Cancel(id), nil
+	}
+	return false, nil
+}
+
+// Prune deletes finished jobs last updated before the cutoff and returns
+// how many were removed.
+func (q *Queue) Prune(before time.Time) (int, error) {
+	removed := 0
+	for _, st := range []string{StatusCompleted, StatusCancelled, StatusDead} {
+		recs, err := q.store.ListQueueJobs(st)
+		if err != nil {
+			return removed, err
+		}
+		for _, rec := range recs {
+			if !rec.UpdatedAt.Before(before) {
+				continue
+			}
+			if err := q.store.DeleteQueueJob(rec.ID); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (q *Queue) forget(id string) {
+	q.liveMu.Lock()
+	delete(q.live, id)
+	q.liveMu.Unlock()
+}
+
+// worker claims due jobs from the store until ctx is cancelled.
+func (q *Queue) worker(ctx context.Context, id int) {
 	defer q.wg.Done()
 	workerLogger := q.logger.WithField("worker", id)
 	workerLogger.Debug("Worker started")
 
 	for {
-		select {
-		case job, ok := <-q.jobs:
-			if !ok {
-				workerLogger.Debug("Worker stopping: jobs channel closed")
-				return
-			}
-			q.processJob(workerLogger, job)
+		if ctx.Err() != nil {
+			workerLogger.Debug("Worker stopping: context cancelled")
+			return
+		}
+		rec, err := q.store.ClaimQueueJob(time.Now())
+		if err != nil {
+			workerLogger.Errorf("Failed to claim job: %v", err)
+		}
+		if rec != nil {
+			q.processJob(ctx, workerLogger, rec)
+			continue
+		}
 
-		case <-q.ctx.Done():
+		select {
+		case <-ctx.Done():
 			workerLogger.Debug("Worker stopping: context cancelled")
 			return
+		case <-q.wake:
+		case <-time.After(q.pollInterval):
 		}
 	}
 }
 
-// processJob executes a single job and tracks its progress.
-func (q *Queue) processJob(logger *logrus.Entry, job Job) {
-	jobID := job.ID()
-	logger = logger.WithField("job", jobID)
-	logger.Infof("Processing job: %s", job.Description())
+// processJob executes a claimed job, tracks its progress and records the
+// outcome in the store.
+func (q *Queue) processJob(ctx context.Context, logger *logrus.Entry, rec *database.QueueJob) {
+	logger = logger.WithField("job", rec.ID)
+
+	job, err := q.resolveJob(rec)
+	if err != nil {
+		logger.Errorf("Cannot run job: %v", err)
+		rec.Status = StatusDead
+		rec.LastError = err.Error()
+		q.save(logger, rec)
+		return
+	}
+	logger.Infof("Processing job (attempt %d/%d): %s", rec.Attempts, rec.MaxAttempts, job.Description())
 
 	// Start tracking the job
-	task := tasks.Start(q.ctx, jobID, func(ctx context.Context) error {
-		return job.Execute(ctx)
+	task := tasks.Start(ctx, rec.ID, func(ctx context.Context) error {
+		return job.Execute(withJobID(ctx, rec.ID))
 	})
 
-	// Monitor job progress
+	// Wait for the task to finish, even during shutdown: Stop cancels its
+	// context and waits for the worker, so the outcome below is final.
 	startTime := time.Now()
-	for {
-		status := task.GetStatus()
-		if status == "completed" {
-			logger.Infof("Job completed successfully in %v", time.Since(startTime))
-			return
-		} else if status == "failed" {
-			logger.Errorf("Job failed: %s", task.GetError())
-			return
-		}
+	for task.GetStatus() == "running" {
+		time.Sleep(100 * time.Millisecond)
+	}
 
-		// Check if context is cancelled
-		select {
-		case <-q.ctx.Done():
-			logger.Warn("Job cancelled due to queue shutdown")
-			return
-		case <-time.After(100 * time.Millisecond):
-			// Continue monitoring
+	status := task.GetStatus()
+	switch {
+	case status == "completed":
+		logger.Infof("Job completed successfully in %v", time.Since(startTime))
+		rec.Status = StatusCompleted
+		rec.LastError = ""
+		q.forget(rec.ID)
+	case status == "cancelled":
+		logger.Info("Job cancelled")
+		rec.Status = StatusCancelled
+		q.forget(rec.ID)
+	case ctx.Err() != nil:
+		// Interrupted by shutdown; the attempt does not count unless the job
+		// was cancelled meanwhile.
+		if cur, err := q.store.GetQueueJob(rec.ID); err == nil && cur != nil && cur.Status == StatusCancelled {
+			logger.Info("Job cancelled during shutdown")
+			rec.Status = StatusCancelled
+			q.forget(rec.ID)
+			break
 		}
+		logger.Warn("Job interrupted by queue shutdown; it will resume on restart")
+		rec.Status = StatusPending
+		rec.Attempts--
+		rec.RunAt = time.Now()
+	default:
+		q.retryOrBury(logger, rec, task.GetError())
+	}
+	q.save(logger, rec)
+}
+
+// retryOrBury schedules a retry with exponential backoff or moves the job to
+// the dead letter state once its attempts are exhausted.
+func (q *Queue) retryOrBury(logger *logrus.Entry, rec *database.QueueJob, errMsg string) {
+	rec.LastError = errMsg
+	if rec.Attempts >= rec.MaxAttempts {
+		logger.Errorf("Job failed permanently after %d attempts: %s", rec.Attempts, errMsg)
+		rec.Status = StatusDead
+		q.forget(rec.ID)
+		return
+	}
+	delay := q.backoff(rec.Attempts)
+	logger.Warnf("Job failed: %s; retrying in %v", errMsg, delay)
+	rec.Status = StatusPending
+	rec.RunAt = time.Now().Add(delay)
+}
+
+// backoff returns the delay before the retry following the given attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	q.mu.RLock()
+	base, max := q.retryBase, q.retryMax
+	q.mu.RUnlock()
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 30 {
+		return max
+	}
+	d := base << (attempt - 1)
+	if d <= 0 || d > max {
+		return max
+	}
+	return d
+}
+
+// resolveJob returns the in-process job for rec or decodes it from the
+// persisted queue message.
+func (q *Queue) resolveJob(rec *database.QueueJob) (Job, error) {
+	q.liveMu.Lock()
+	job, ok := q.live[rec.ID]
+	q.liveMu.Unlock()
+	if ok {
+		return job, nil
+	}
+	return decodeJob(rec)
+}
+
+func (q *Queue) save(logger *logrus.Entry, rec *database.QueueJob) {
+	if err := q.store.UpdateQueueJob(rec); err != nil {
+		logger.Errorf("Failed to record job state: %v", err)
 	}
 }
 
@@ -180,18 +538,33 @@ type QueueStatus struct {
 	Running     bool `json:"running"`
 	Workers     int  `json:"workers"`
 	QueueLength int  `json:"queue_length"`
+	Active      int  `json:"active"`
+	Dead        int  `json:"dead"`
 }
 
-// Status returns the current status of the queue.
+// Status returns the current status of the queue. QueueLength counts pending
+// jobs, including delayed jobs and jobs waiting for a retry.
 func (q *Queue) Status() QueueStatus {
 	q.mu.RLock()
-	defer q.mu.RUnlock()
-
-	return QueueStatus{
-		Running:     q.running,
-		Workers:     q.workers,
-		QueueLength: len(q.jobs),
+	status := QueueStatus{
+		Running: q.running,
+		Workers: q.workers,
+	}
+	q.mu.RUnlock()
+
+	for st, count := range map[string]*int{
+		StatusPending: &status.QueueLength,
+		StatusRunning: &status.Active,
+		StatusDead:    &status.Dead,
+	} {
+		recs, err := q.store.ListQueueJobs(st)
+		if err != nil {
+			q.logger.Warnf("Failed to list %s jobs: %v", st, err)
+			continue
+		}
+		*count = len(recs)
 	}
+	return status
 }
 
 // Global queue instance
@@ -200,13 +573,14 @@ var (
 	globalMu    sync.RWMutex
 )
 
-// GetQueue returns the global queue instance, creating it if necessary.
+// GetQueue returns the global queue instance, creating an in-memory queue if
+// necessary.
 func GetQueue() *Queue {
 	globalMu.Lock()
 	defer globalMu.Unlock()
 
 	if globalQueue == nil {
-		globalQueue = NewQueue(3) // Default to 3 workers
+		globalQueue = NewQueue(DefaultWorkers)
 	}
 	return globalQueue
 }
@@ -218,31 +592,38 @@ func SetQueue(q *Queue) {
 	globalQueue = q
 }
 
+// StartPersistent replaces the global queue with one backed by store and
+// starts it, resuming jobs left by a previous run.
+func StartPersistent(store JobStore) (*Queue, error) {
+	q := NewPersistentQueue(DefaultWorkers, store)
+	if err := q.Start(); err != nil {
+		return nil, err
+	}
+	SetQueue(q)
+	return q, nil
+}
+
 // Helper functions for creating jobs
 
 // NewSingleFileJob creates a new single file translation job with a generated ID.
-func NewSingleFileJob(inputPath, outputPath, language, service, googleKey, gptKey, grpcAddr string) *SingleFileJob {
+func NewSingleFileJob(inputPath, outputPath, language, service, grpcAddr string) *SingleFileJob {
 	return &SingleFileJob{
 		JobID:      uuid.New().String(),
 		InputPath:  inputPath,
 		OutputPath: outputPath,
 		Language:   language,
 		Service:    service,
-		GoogleKey:  googleKey,
-		GPTKey:     gptKey,
 		GRPCAddr:   grpcAddr,
 	}
 }
 
 // NewBatchFilesJob creates a new batch files translation job with a generated ID.
-func NewBatchFilesJob(inputPaths []string, language, service, googleKey, gptKey, grpcAddr string, workers int) *BatchFilesJob {
+func NewBatchFilesJob(inputPaths []string, language, service, grpcAddr string, workers int) *BatchFilesJob {
 	return &BatchFilesJob{
 		JobID:      uuid.New().String(),
 		InputPaths: inputPaths,
 		Language:   language,
 		Service:    service,
-		GoogleKey:  googleKey,
-		GPTKey:     gptKey,
 		GRPCAddr:   grpcAddr,
 		Workers:    workers,
 	}
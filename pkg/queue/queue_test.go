@@ -1,18 +1,30 @@
 // file: pkg/queue/queue_test.go
-// version: 1.1.0
+// version: 1.5.0
 // guid: 123e4567-e89b-12d3-a456-426614174003
 package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	gqueue "github.com/jdfalk/gcommon/sdks/go/v1/queue"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/internal/queuetest"
+	"github.com/jdfalk/subtitle-manager/pkg/translator"
 )
 
 // mockJob implements the Job interface for testing.
@@ -104,13 +116,13 @@ func TestQueueProcessJob(t *testing.T) {
 	require.NoError(t, err)
 	defer q.Stop()
 
-	executed := false
+	var executed atomic.Bool
 	job := &mockJob{
 		id:          "test-job",
 		jobType:     JobTypeSingleFile,
 		description: "test execution",
 		executeFunc: func(ctx context.Context) error {
-			executed = true
+			executed.Store(true)
 			return nil
 		},
 	}
@@ -120,7 +132,7 @@ func TestQueueProcessJob(t *testing.T) {
 
 	// Wait for job to be processed
 	time.Sleep(200 * time.Millisecond)
-	assert.True(t, executed, "job should have been executed")
+	assert.True(t, executed.Load(), "job should have been executed")
 }
 
 func TestQueueStatus(t *testing.T) {
@@ -146,8 +158,6 @@ func TestSingleFileJob(t *testing.T) {
 		"/output.srt",
 		"en",
 		"google",
-		"api-key",
-		"gpt-key",
 		"localhost:8080",
 	)
 
@@ -167,8 +177,6 @@ func TestBatchFilesJob(t *testing.T) {
 		[]string{"/file1.srt", "/file2.srt"},
 		"en",
 		"google",
-		"api-key",
-		"gpt-key",
 		"localhost:8080",
 		2,
 	)
@@ -278,3 +286,286 @@ func TestQueueRestartCycle(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, q.IsRunning())
 }
+
+func TestQueuePriorityOrder(t *testing.T) {
+	q := NewQueue(1)
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	release := make(chan struct{})
+	_, err := q.Add(&mockJob{id: "blocker", jobType: JobTypeSingleFile, executeFunc: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+	require.NoError(t, err)
+	queuetest.WaitForStatus(t, q, "blocker", StatusRunning)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(id string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			return nil
+		}
+	}
+	_, err = q.AddWithOptions(&mockJob{id: "low", jobType: JobTypeSingleFile, executeFunc: record("low")}, JobOptions{Priority: 1})
+	require.NoError(t, err)
+	_, err = q.AddWithOptions(&mockJob{id: "high", jobType: JobTypeSingleFile, executeFunc: record("high")}, JobOptions{Priority: 10})
+	require.NoError(t, err)
+
+	close(release)
+	queuetest.WaitForStatus(t, q, "low", StatusCompleted)
+	queuetest.WaitForStatus(t, q, "high", StatusCompleted)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"high", "low"}, order)
+}
+
+func TestQueueRetryAndDeadLetter(t *testing.T) {
+	q := NewQueue(1)
+	q.SetRetryBackoff(10*time.Millisecond, 20*time.Millisecond)
+	q.pollInterval = 5 * time.Millisecond
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	var mu sync.Mutex
+	runs := 0
+	_, err := q.AddWithOptions(&mockJob{id: "flaky", jobType: JobTypeSingleFile, executeFunc: func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		if runs == 1 {
+			return errors.New("temporary")
+		}
+		return nil
+	}}, JobOptions{MaxAttempts: 3})
+	require.NoError(t, err)
+	rec := queuetest.WaitForStatus(t, q, "flaky", StatusCompleted)
+	assert.Equal(t, 2, rec.Attempts)
+
+	_, err = q.AddWithOptions(&mockJob{id: "broken", jobType: JobTypeSingleFile, executeFunc: func(context.Context) error {
+		return errors.New("permanent")
+	}}, JobOptions{MaxAttempts: 2})
+	require.NoError(t, err)
+	rec = queuetest.WaitForStatus(t, q, "broken", StatusDead)
+	assert.Equal(t, 2, rec.Attempts)
+	assert.Equal(t, "permanent", rec.LastError)
+	assert.Equal(t, 1, q.Status().Dead)
+
+	dead, err := q.History(StatusDead)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "broken", dead[0].ID)
+}
+
+func TestQueueBackoff(t *testing.T) {
+	q := NewQueue(1)
+	q.SetRetryBackoff(time.Second, 5*time.Second)
+	assert.Equal(t, time.Second, q.backoff(1))
+	assert.Equal(t, 2*time.Second, q.backoff(2))
+	assert.Equal(t, 4*time.Second, q.backoff(3))
+	assert.Equal(t, 5*time.Second, q.backoff(4))
+	assert.Equal(t, 5*time.Second, q.backoff(100))
+}
+
+func TestQueueDelayedJobAndCancel(t *testing.T) {
+	q := NewQueue(1)
+	q.pollInterval = 5 * time.Millisecond
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	_, err := q.AddWithOptions(&mockJob{id: "later", jobType: JobTypeSingleFile}, JobOptions{Delay: 200 * time.Millisecond})
+	require.NoError(t, err)
+	_, err = q.AddWithOptions(&mockJob{id: "never", jobType: JobTypeSingleFile}, JobOptions{Delay: time.Hour})
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	rec, err := q.Job("later")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, rec.Status)
+	assert.Equal(t, 2, q.Status().QueueLength)
+	queuetest.WaitForStatus(t, q, "later", StatusCompleted)
+
+	ok, err := q.Cancel("never")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	queuetest.WaitForStatus(t, q, "never", StatusCancelled)
+
+	removed, err := q.Prune(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+}
+
+// TestQueueStopWaitsForJobs verifies Stop returns only after running jobs
+// finish and keeps jobs cancelled while the queue was shutting down.
+func TestQueueStopWaitsForJobs(t *testing.T) {
+	q := NewQueue(2)
+	require.NoError(t, q.Start())
+
+	started := make(chan struct{}, 2)
+	_, err := q.Add(&mockJob{id: "slow", jobType: JobTypeSingleFile, executeFunc: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}})
+	require.NoError(t, err)
+	_, err = q.Add(&mockJob{id: "cancelled", jobType: JobTypeSingleFile, executeFunc: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		// A cancellation recorded in the store while shutting down.
+		rec, err := q.store.GetQueueJob("cancelled")
+		if err != nil {
+			return err
+		}
+		rec.Status = StatusCancelled
+		if err := q.store.UpdateQueueJob(rec); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}})
+	require.NoError(t, err)
+	<-started
+	<-started
+
+	require.NoError(t, q.Stop())
+	rec, err := q.Job("slow")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, rec.Status)
+	rec, err = q.Job("cancelled")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, rec.Status)
+}
+
+// TestQueueStopReleasesLock verifies workers can still use the queue while
+// Stop waits for them, as a failing job does to compute its retry delay.
+func TestQueueStopReleasesLock(t *testing.T) {
+	q := NewQueue(1)
+	require.NoError(t, q.Start())
+
+	started := make(chan struct{})
+	_, err := q.Add(&mockJob{id: "late", jobType: JobTypeSingleFile, executeFunc: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		q.backoff(1)
+		return errors.New("failed during shutdown")
+	}})
+	require.NoError(t, err)
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- q.Stop() }()
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked on a worker waiting for the queue lock")
+	}
+}
+
+// TestQueueJobOwner verifies the owner given in JobOptions is persisted with
+// the job message.
+func TestQueueJobOwner(t *testing.T) {
+	q := NewQueue(1)
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	_, err := q.AddWithOptions(&mockJob{id: "owned", jobType: JobTypeSingleFile}, JobOptions{Delay: time.Hour, Owner: "7"})
+	require.NoError(t, err)
+	_, err = q.AddWithOptions(&mockJob{id: "unowned", jobType: JobTypeSingleFile}, JobOptions{Delay: time.Hour})
+	require.NoError(t, err)
+
+	rec, err := q.Job("owned")
+	require.NoError(t, err)
+	assert.Equal(t, "7", q.Owner(rec))
+	rec, err = q.Job("unowned")
+	require.NoError(t, err)
+	assert.Empty(t, q.Owner(rec))
+}
+
+// TestQueuePrunesFinishedJobs verifies the queue deletes finished jobs once
+// they are older than the retention.
+func TestQueuePrunesFinishedJobs(t *testing.T) {
+	q := NewQueue(1)
+	q.pollInterval = 5 * time.Millisecond
+	q.pruneInterval = 10 * time.Millisecond
+	q.SetRetention(50 * time.Millisecond)
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	_, err := q.Add(&mockJob{id: "done", jobType: JobTypeSingleFile})
+	require.NoError(t, err)
+	queuetest.WaitForStatus(t, q, "done", StatusCompleted)
+	assert.Eventually(t, func() bool {
+		rec, err := q.Job("done")
+		return err == nil && rec == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestDecodeJob(t *testing.T) {
+	single := NewSingleFileJob("/in.srt", "/out.srt", "es", "google", "addr")
+	batch := NewBatchFilesJob([]string{"/a.srt", "/b.srt"}, "fr", "gpt", "addr", 2)
+
+	for _, job := range []Job{single, batch} {
+		msg, err := job.QueueMessage()
+		require.NoError(t, err)
+		payload, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		decoded, err := decodeJob(&database.QueueJob{ID: job.ID(), Type: string(job.Type()), Payload: payload})
+		require.NoError(t, err)
+		assert.Equal(t, job, decoded)
+	}
+
+	_, err := decodeJob(&database.QueueJob{ID: "x", Type: "unknown"})
+	assert.Error(t, err)
+}
+
+// TestPersistentQueueResume simulates a crash during a translation and checks
+// that a new queue on the same store finishes the job.
+func TestPersistentQueueResume(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"translations":[{"translatedText":"hola"}]}}`))
+	}))
+	defer ts.Close()
+	translator.SetGoogleAPIURL(ts.URL)
+	defer translator.SetGoogleAPIURL("https://translation.googleapis.com/language/translate/v2")
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.srt")
+	out := filepath.Join(dir, "out.srt")
+	require.NoError(t, os.WriteFile(in, []byte("1\n00:00:01,000 --> 00:00:02,000\nhello\n"), 0644))
+
+	store, err := database.OpenPebble(filepath.Join(dir, "db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	viper.Set("google_api_key", "key")
+	defer viper.Set("google_api_key", "")
+
+	job := NewSingleFileJob(in, out, "es", "google", "")
+	msg, err := job.QueueMessage()
+	require.NoError(t, err)
+	payload, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	require.NoError(t, store.InsertQueueJob(&database.QueueJob{
+		ID:          job.ID(),
+		Type:        string(job.Type()),
+		Payload:     payload,
+		Status:      StatusRunning,
+		Attempts:    1,
+		MaxAttempts: DefaultMaxAttempts,
+	}))
+
+	q := NewPersistentQueue(1, store)
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	rec := queuetest.WaitForStatus(t, q, job.ID(), StatusCompleted)
+	assert.Equal(t, 2, rec.Attempts)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "hola")
+}
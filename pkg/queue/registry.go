@@ -0,0 +1,220 @@
+// file: pkg/queue/registry.go
+// version: 1.1.0
+// guid: 9a3f6c21-4e8b-4d17-b2a5-6c0e9f1d7b38
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jdfalk/gcommon/sdks/go/v1/queue"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/jdfalk/subtitle-manager/pkg/tasks"
+)
+
+// DecodeFunc rebuilds a job of a registered type from its queue message.
+type DecodeFunc func(id string, msg *queue.QueueMessage) (Job, error)
+
+// handlerEntry describes a job type registered with Register.
+type handlerEntry struct {
+	run      func(ctx context.Context, payload any) error
+	describe func(payload any) string
+	parse    func(data []byte) (any, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	decoders   = map[JobType]DecodeFunc{}
+	handlers   = map[JobType]handlerEntry{}
+)
+
+// RegisterDecoder registers the decoder used to resume persisted jobs of
+// type t. Registering a type twice replaces the previous decoder.
+func RegisterDecoder(t JobType, decode DecodeFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	decoders[t] = decode
+}
+
+// Register registers handler for jobs of type t carrying a payload of type P.
+// Payloads are JSON encoded into the queue message body, so P must survive a
+// JSON round trip. describe returns a human readable job description and may
+// be nil.
+func Register[P any](t JobType, describe func(P) string, handler func(ctx context.Context, payload P) error) {
+	entry := handlerEntry{
+		run: func(ctx context.Context, payload any) error {
+			p, ok := payload.(P)
+			if !ok {
+				return fmt.Errorf("job type %s: unexpected payload %T", t, payload)
+			}
+			return handler(ctx, p)
+		},
+		describe: func(payload any) string {
+			p, ok := payload.(P)
+			if !ok || describe == nil {
+				return string(t)
+			}
+			return describe(p)
+		},
+		parse: func(data []byte) (any, error) {
+			var p P
+			if len(data) > 0 {
+				if err := json.Unmarshal(data, &p); err != nil {
+					return nil, fmt.Errorf("job type %s: %w", t, err)
+				}
+			}
+			return p, nil
+		},
+	}
+	decode := func(id string, msg *queue.QueueMessage) (Job, error) {
+		var p P
+		if err := decodePayload(msg, &p); err != nil {
+			return nil, err
+		}
+		return &PayloadJob{JobID: id, JobType: t, Payload: p}, nil
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	handlers[t] = entry
+	decoders[t] = decode
+}
+
+// RegisteredTypes returns the job types that can be decoded, sorted by name.
+func RegisteredTypes() []JobType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]JobType, 0, len(decoders))
+	for t := range decoders {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func handlerFor(t JobType) (handlerEntry, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	h, ok := handlers[t]
+	return h, ok
+}
+
+// ParsePayload decodes the JSON payload of a job of registered type t, such
+// as the body of an API request. Empty data yields the zero payload.
+func ParsePayload(t JobType, data []byte) (any, error) {
+	h, ok := handlerFor(t)
+	if !ok {
+		return nil, fmt.Errorf("job type %s is not registered", t)
+	}
+	return h.parse(data)
+}
+
+// PayloadJob is a job of a type registered with Register. Its payload is
+// passed to the registered handler when the job runs.
+type PayloadJob struct {
+	JobID   string
+	JobType JobType
+	Payload any
+}
+
+// NewJob creates a job of registered type t with a generated ID.
+func NewJob(t JobType, payload any) (*PayloadJob, error) {
+	if _, ok := handlerFor(t); !ok {
+		return nil, fmt.Errorf("job type %s is not registered", t)
+	}
+	return &PayloadJob{JobID: uuid.NewString(), JobType: t, Payload: payload}, nil
+}
+
+// ID returns the job identifier.
+func (j *PayloadJob) ID() string {
+	return j.JobID
+}
+
+// Type returns the job type.
+func (j *PayloadJob) Type() JobType {
+	return j.JobType
+}
+
+// Execute runs the handler registered for the job type.
+func (j *PayloadJob) Execute(ctx context.Context) error {
+	h, ok := handlerFor(j.JobType)
+	if !ok {
+		return fmt.Errorf("job type %s is not registered", j.JobType)
+	}
+	return h.run(withJobID(ctx, j.JobID), j.Payload)
+}
+
+// Description returns a description of the job.
+func (j *PayloadJob) Description() string {
+	if h, ok := handlerFor(j.JobType); ok {
+		return h.describe(j.Payload)
+	}
+	return string(j.JobType)
+}
+
+// QueueMessage converts the job to a gcommon queue message whose body holds
+// the JSON encoded payload as a google.protobuf.Struct.
+func (j *PayloadJob) QueueMessage() (*queue.QueueMessage, error) {
+	data, err := json.Marshal(j.Payload)
+	if err != nil {
+		return nil, err
+	}
+	body := &structpb.Struct{}
+	if err := protojson.Unmarshal(data, body); err != nil {
+		return nil, fmt.Errorf("payload must encode as a JSON object: %w", err)
+	}
+	anyMsg, err := anypb.New(body)
+	if err != nil {
+		return nil, err
+	}
+
+	queueMsg := &queue.QueueMessage{}
+	queueMsg.SetId(j.JobID)
+	queueMsg.SetBody(anyMsg)
+	queueMsg.SetContentType("application/json")
+	queueMsg.SetAttributes(map[string]string{"job_type": string(j.JobType)})
+	return queueMsg, nil
+}
+
+// decodePayload unpacks the JSON payload stored by PayloadJob.QueueMessage into v.
+func decodePayload(msg *queue.QueueMessage, v any) error {
+	if msg.GetBody() == nil {
+		return fmt.Errorf("message has no body")
+	}
+	body := &structpb.Struct{}
+	if err := msg.GetBody().UnmarshalTo(body); err != nil {
+		return err
+	}
+	data, err := protojson.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type jobIDKey struct{}
+
+func withJobID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, jobIDKey{}, id)
+}
+
+// JobIDFromContext returns the ID of the job running with ctx.
+func JobIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(jobIDKey{}).(string)
+	return id, ok
+}
+
+// ReportProgress records the progress percentage of the job running with ctx.
+// It does nothing outside a job.
+func ReportProgress(ctx context.Context, percent int) {
+	if id, ok := JobIDFromContext(ctx); ok {
+		tasks.Update(id, percent)
+	}
+}
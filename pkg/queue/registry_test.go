@@ -0,0 +1,90 @@
+// file: pkg/queue/registry_test.go
+// version: 1.1.1
+// guid: 4c1e7b93-2d5a-4f86-a0b9-8e3f6d2c1a47
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/internal/queuetest"
+)
+
+type echoPayload struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+const jobTypeEcho JobType = "test_echo"
+
+func TestRegisterRoundTrip(t *testing.T) {
+	got := make(chan echoPayload, 1)
+	Register(jobTypeEcho, func(p echoPayload) string {
+		return "echo " + p.Name
+	}, func(ctx context.Context, p echoPayload) error {
+		id, ok := JobIDFromContext(ctx)
+		if !ok || id == "" {
+			t.Errorf("job ID missing from context")
+		}
+		got <- p
+		return nil
+	})
+	assert.Contains(t, RegisteredTypes(), jobTypeEcho)
+
+	want := echoPayload{Name: "a", Count: 3, Tags: []string{"x", "y"}}
+	job, err := NewJob(jobTypeEcho, want)
+	require.NoError(t, err)
+	assert.Equal(t, "echo a", job.Description())
+
+	msg, err := job.QueueMessage()
+	require.NoError(t, err)
+	assert.Equal(t, string(jobTypeEcho), msg.GetAttributes()["job_type"])
+	payload, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	decoded, err := decodeJob(&database.QueueJob{ID: job.ID(), Type: string(jobTypeEcho), Payload: payload})
+	require.NoError(t, err)
+	assert.Equal(t, job, decoded)
+
+	require.NoError(t, decoded.Execute(context.Background()))
+	assert.Equal(t, want, <-got)
+
+	parsed, err := ParsePayload(jobTypeEcho, []byte(`{"name":"a","count":3,"tags":["x","y"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, want, parsed)
+	_, err = ParsePayload(jobTypeEcho, []byte(`{"count":"three"}`))
+	assert.Error(t, err)
+	_, err = ParsePayload(JobType("test_unknown"), nil)
+	assert.Error(t, err)
+}
+
+func TestRegisteredJobRunsOnQueue(t *testing.T) {
+	done := make(chan string, 1)
+	Register(JobType("test_queue_echo"), nil, func(ctx context.Context, p echoPayload) error {
+		done <- p.Name
+		return nil
+	})
+
+	q := NewQueue(1)
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	job, err := NewJob("test_queue_echo", echoPayload{Name: "queued"})
+	require.NoError(t, err)
+	assert.Equal(t, "test_queue_echo", job.Description())
+	id, err := q.Add(job)
+	require.NoError(t, err)
+	assert.Equal(t, "queued", <-done)
+	queuetest.WaitForStatus(t, q, id, StatusCompleted)
+}
+
+func TestNewJobUnregistered(t *testing.T) {
+	_, err := NewJob("does_not_exist", echoPayload{})
+	assert.Error(t, err)
+}
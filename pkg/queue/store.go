@@ -0,0 +1,144 @@
+// file: pkg/queue/store.go
+// version: 1.1.0
+// guid: 5b7c1e2a-9d4f-4c3b-8a6e-2f1d0c9b8a74
+package queue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+)
+
+// Job status values recorded in the job store.
+const (
+	// StatusPending marks a job waiting for a worker, including retries and delayed jobs.
+	StatusPending = "pending"
+	// StatusRunning marks a job claimed by a worker.
+	StatusRunning = "running"
+	// StatusCompleted marks a job that finished successfully.
+	StatusCompleted = "completed"
+	// StatusCancelled marks a job cancelled before or during execution.
+	StatusCancelled = "cancelled"
+	// StatusDead marks a job that exhausted its attempts (dead letter).
+	StatusDead = "dead"
+)
+
+// JobStore persists queued jobs. Every database.SubtitleStore satisfies it, so
+// the queue can be backed by the configured Pebble, SQLite or Postgres store.
+type JobStore interface {
+	InsertQueueJob(rec *database.QueueJob) error
+	UpdateQueueJob(rec *database.QueueJob) error
+	GetQueueJob(id string) (*database.QueueJob, error)
+	ListQueueJobs(status string) ([]database.QueueJob, error)
+	ClaimQueueJob(now time.Time) (*database.QueueJob, error)
+	CancelQueueJob(id string) (bool, error)
+	DeleteQueueJob(id string) error
+}
+
+// memoryStore is a JobStore kept in process memory. It is used by NewQueue
+// when no persistent store is configured; its jobs do not survive restarts.
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]database.QueueJob
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{jobs: make(map[string]database.QueueJob)}
+}
+
+func (m *memoryStore) InsertQueueJob(rec *database.QueueJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	if rec.RunAt.IsZero() {
+		rec.RunAt = now
+	}
+	rec.UpdatedAt = now
+	m.jobs[rec.ID] = *rec
+	return nil
+}
+
+func (m *memoryStore) UpdateQueueJob(rec *database.QueueJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec.UpdatedAt = time.Now()
+	m.jobs[rec.ID] = *rec
+	return nil
+}
+
+func (m *memoryStore) GetQueueJob(id string) (*database.QueueJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (m *memoryStore) ListQueueJobs(status string) ([]database.QueueJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list(status), nil
+}
+
+func (m *memoryStore) list(status string) []database.QueueJob {
+	var recs []database.QueueJob
+	for _, rec := range m.jobs {
+		if status == "" || rec.Status == status {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		a, b := recs[i], recs[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if !a.RunAt.Equal(b.RunAt) {
+			return a.RunAt.Before(b.RunAt)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return recs
+}
+
+func (m *memoryStore) ClaimQueueJob(now time.Time) (*database.QueueJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rec := range m.list(StatusPending) {
+		if rec.RunAt.After(now) {
+			continue
+		}
+		rec.Status = StatusRunning
+		rec.Attempts++
+		rec.UpdatedAt = time.Now()
+		m.jobs[rec.ID] = rec
+		return &rec, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryStore) CancelQueueJob(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.jobs[id]
+	if !ok || rec.Status != StatusPending {
+		return false, nil
+	}
+	rec.Status = StatusCancelled
+	rec.UpdatedAt = time.Now()
+	m.jobs[id] = rec
+	return true, nil
+}
+
+func (m *memoryStore) DeleteQueueJob(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.jobs, id)
+	return nil
+}
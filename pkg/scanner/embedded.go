@@ -0,0 +1,62 @@
+// file: pkg/scanner/embedded.go
+// version: 1.0.0
+// guid: 9e3b7d52-1a6c-4f80-8d29-c4f1a7e5b036
+package scanner
+
+import (
+	"github.com/jdfalk/subtitle-manager/pkg/charset"
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/embedded"
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+)
+
+// embeddedProvider is the provider name recorded for subtitles extracted
+// from embedded tracks.
+const embeddedProvider = "embedded"
+
+// embeddedSubtitle reports whether video carries an embedded lang track that
+// makes a download unnecessary. When embedded.extract is enabled a text track
+// is extracted to out and recorded in store.
+func embeddedSubtitle(video, lang, out string, store database.SubtitleStore) bool {
+	logger := logging.GetLogger("scanner")
+	tracks, err := embedded.Tracks(video)
+	if err != nil {
+		logger.Debugf("probe embedded subtitles of %s: %v", video, err)
+		return false
+	}
+	m, err := embedded.Satisfy(video, tracks, lang, false, false, out)
+	if err != nil {
+		logger.Warnf("embedded %s subtitle of %s: %v", lang, video, err)
+		return false
+	}
+	if m == nil {
+		return false
+	}
+	if m.Sidecar == "" {
+		logger.Infof("%s has an embedded %s subtitle track (%s), skipping search", video, lang, m.Track.Codec)
+		return true
+	}
+	logger.Infof("extracted embedded %s subtitle %s", lang, m.Sidecar)
+	if store != nil {
+		recordExtracted(store, m.Sidecar, video, lang)
+	}
+	return true
+}
+
+// recordExtracted stores a subtitle extracted from an embedded track in the
+// subtitle history.
+func recordExtracted(store database.SubtitleStore, file, video, lang string) {
+	rec, err := database.CreateSubtitleRecord(file, video, lang, embeddedProvider, &database.ProviderMetadata{
+		Encoding:   charset.UTF8,
+		Format:     "srt",
+		Language:   lang,
+		SourceName: embeddedProvider,
+	})
+	if err == nil {
+		rec.Embedded = true
+		err = store.InsertSubtitle(rec)
+	}
+	if err != nil {
+		logging.GetLogger("scanner").Warnf("record subtitle %s: %v", file, err)
+	}
+}
@@ -1,5 +1,5 @@
 // file: pkg/scanner/scanner.go
-// version: 1.1.0
+// version: 1.8.1
 // guid: ad2ef6ba-8afa-4ced-8508-0c535dbb23fd
 package scanner
 
@@ -14,8 +14,10 @@ import (
 
 	"github.com/sourcegraph/conc/pool"
 
+	"github.com/jdfalk/subtitle-manager/pkg/charset"
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/events"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/metadata"
 	"github.com/jdfalk/subtitle-manager/pkg/providers"
@@ -24,14 +26,58 @@ import (
 
 // ScanDirectory walks through the directory and downloads subtitles for video files
 // using provider p for the given language. providerName is stored in download
-// history. If upgrade is false existing subtitle files are skipped.
+// history. If upgrade is false existing subtitle files are skipped, as are
+// files the file index records as unchanged since a previous scan satisfied
+// them.
 func ScanDirectory(ctx context.Context, dir, lang string, providerName string, p providers.Provider, upgrade bool, workers int, store database.SubtitleStore) error {
+	res, err := scanDirectory(ctx, dir, lang, providerName, p, upgrade, false, workers, store, nil)
+	if err != nil {
+		return err
+	}
+	return res.failed
+}
+
+// ScanDirectoryIndexed scans dir like ScanDirectoryProgress and returns how
+// many video files were new, changed, removed or skipped since the previous
+// scan. Unchanged files whose subtitle is still present are skipped unless
+// full is set or upgrade is requested. Files that fail are counted in the
+// Failed field of the summary rather than returned as an error.
+func ScanDirectoryIndexed(ctx context.Context, dir, lang, providerName string, p providers.Provider, upgrade, full bool, workers int, store database.SubtitleStore, cb ProgressFunc) (fileindex.Summary, error) {
+	res, err := scanDirectory(ctx, dir, lang, providerName, p, upgrade, full, workers, store, cb)
+	return res.summary, err
+}
+
+// File index results of subtitle scans.
+const (
+	resultSatisfied = "satisfied"
+	resultEmbedded  = "embedded"
+	resultMissing   = "missing"
+	resultFailed    = "failed"
+)
+
+// scanResult is the outcome of a directory scan.
+type scanResult struct {
+	summary fileindex.Summary
+	// failed joins the errors of the files that could not be processed.
+	failed error
+}
+
+// scanDirectory walks dir and processes every video file the file index does
+// not skip, calling cb for each skipped or successfully processed file. Files
+// that fail are reported in the result; the returned error is set only when
+// the walk itself stopped.
+func scanDirectory(ctx context.Context, dir, lang, providerName string, p providers.Provider, upgrade, full bool, workers int, store database.SubtitleStore, cb ProgressFunc) (scanResult, error) {
 	logger := logging.GetLogger("scanner")
 	sanitizedDir, err := security.ValidateAndSanitizePath(dir)
 	if err != nil {
 		logger.Warnf("invalid path: %v", err)
-		return err
+		return scanResult{}, err
+	}
+	index, err := fileindex.Open(store, sanitizedDir, full)
+	if err != nil {
+		return scanResult{}, err
 	}
+	key := "subtitles:" + lang
 	work := pool.New().WithErrors().WithMaxGoroutines(workers)
 	err = filepath.WalkDir(sanitizedDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -40,82 +86,138 @@ func ScanDirectory(ctx context.Context, dir, lang string, providerName string, p
 		if d.IsDir() {
 			return nil
 		}
-		if !isVideoFile(path) {
+		if !fileindex.IsVideo(path) {
 			return nil
 		}
 		f := filepath.Clean(path)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		out, _ := security.ValidateSubtitleOutputPath(f, lang)
+		entry, evaluate := index.Check(f, info, key, func(result string) bool {
+			return !upgrade && (result == resultEmbedded || result == resultSatisfied && fileExists(out))
+		})
+		if !evaluate {
+			logger.Debugf("skip unchanged %s", f)
+			if cb != nil {
+				cb(f)
+			}
+			return nil
+		}
 		work.Go(func() error {
 			logger.Debugf("process %s", f)
-			return ProcessFile(ctx, f, lang, providerName, p, upgrade, store)
+			inPlace, err := processFile(ctx, f, lang, providerName, p, upgrade, store)
+			result := resultMissing
+			switch {
+			case err != nil:
+				result = resultFailed
+			case inPlace:
+				result = resultEmbedded
+			case fileExists(out):
+				result = resultSatisfied
+			}
+			if rerr := index.Record(entry, key, result, err != nil); rerr != nil {
+				logger.Warnf("record %s in file index: %v", f, rerr)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", f, err)
+			}
+			if cb != nil {
+				cb(f)
+			}
+			return nil
 		})
 		return nil
 	})
+	res := scanResult{failed: work.Wait()}
 	if err != nil {
-		return err
+		res.summary = index.Summary()
+		return res, err
 	}
-	if err := work.Wait(); err != nil {
-		return err
+	res.summary, err = index.Finish()
+	if err != nil {
+		return res, err
 	}
 	if store != nil {
 		if err := metadata.ScanLibrary(ctx, sanitizedDir, store); err != nil {
 			logger.Warnf("scan library: %v", err)
 		}
 	}
-	return nil
+	return res, nil
+}
+
+// fileExists reports whether path names an existing file.
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 // ProcessFile downloads a subtitle for path using providerName for history
 // tracking. The subtitle is saved next to the media file with the language
 // code appended before the extension. If upgrade is false an existing subtitle
 // file is left untouched. When upgrade is true and a subtitle already exists,
-// the new subtitle replaces it only if the file size is larger, indicating
-// potentially better quality.
+// the new subtitle replaces it only if its score reaches the language
+// profile's cutoff and beats the score recorded for the installed subtitle by
+// at least UpgradeMargin points. An installed subtitle without a recorded
+// score is kept. Scores are stored in the download history.
+// When no subtitle file exists, an embedded track in lang counts as the
+// subtitle, or is extracted next to the media file when embedded.extract is
+// enabled, and no provider is searched.
 func ProcessFile(ctx context.Context, path, lang string, providerName string, p providers.Provider, upgrade bool, store database.SubtitleStore) error {
+	_, err := processFile(ctx, path, lang, providerName, p, upgrade, store)
+	return err
+}
+
+// processFile implements ProcessFile and reports whether the subtitle is
+// provided by a track embedded in the video instead of a subtitle file.
+func processFile(ctx context.Context, path, lang string, providerName string, p providers.Provider, upgrade bool, store database.SubtitleStore) (bool, error) {
 	logger := logging.GetLogger("scanner")
 
 	// Validate and sanitize all user inputs
 	sanitizedPath, err := security.ValidateAndSanitizePath(path)
 	if err != nil {
 		logger.Warnf("invalid path: %v", err)
-		return err
+		return false, err
 	}
 	path = sanitizedPath
 
 	// Validate the language code to prevent path traversal attacks
 	if err := security.ValidateLanguageCode(lang); err != nil {
 		logger.Warnf("invalid language code: %v", err)
-		return err
+		return false, err
 	}
 	// Ensure the language code does not contain any path traversal characters
 	if strings.Contains(lang, "/") || strings.Contains(lang, "\\") || strings.Contains(lang, "..") {
 		logger.Warnf("language code contains invalid characters")
-		return fmt.Errorf("invalid language code")
+		return false, fmt.Errorf("invalid language code")
 	}
 
 	// Validate provider name if provided
 	if err := security.ValidateProviderName(providerName); err != nil {
 		logger.Warnf("invalid provider name: %v", err)
-		return err
+		return false, err
 	}
 
 	// Construct and validate the output path securely
 	validatedOutputPath, err := security.ValidateSubtitleOutputPath(path, lang)
 	if err != nil {
 		logger.Warnf("invalid subtitle output path: %v", err)
-		return err
+		return false, err
 	}
 
-	if !upgrade {
-		if _, err := os.Stat(validatedOutputPath); err == nil {
-			return nil
+	if _, err := os.Stat(validatedOutputPath); err == nil {
+		if !upgrade {
+			return false, nil
 		}
+	} else if embeddedSubtitle(path, lang, validatedOutputPath, store) {
+		return !fileExists(validatedOutputPath), nil
 	}
-	var data []byte
-	if p != nil {
-		data, err = p.Fetch(ctx, path, lang)
-	} else {
-		data, providerName, err = providers.FetchFromAll(ctx, path, lang, "")
-	}
+	fetched, err := FetchScored(ctx, path, lang, "", providerName, p)
+	providerName = fetched.Provider
 	if err != nil {
 		logger.Warnf("fetch %s: %v", path, err)
 
@@ -128,18 +230,26 @@ func ProcessFile(ctx context.Context, path, lang string, providerName string, p
 			Timestamp: time.Now(),
 		})
 
-		return err
+		return false, err
 	}
 	var wasUpgrade bool
+	var oldProvider string
+	var oldScore int
 	if upgrade {
-		if oldData, err := os.ReadFile(validatedOutputPath); err == nil {
-			if len(data) <= len(oldData) {
-				logger.Debugf("existing subtitle %s is higher quality", validatedOutputPath)
-				return nil
+		if _, err := os.Stat(validatedOutputPath); err == nil {
+			old := installedDownload(store, path, validatedOutputPath)
+			oldScore = installedScore(old, path, validatedOutputPath)
+			if !shouldUpgrade(fetched.Score, oldScore, storeCutoff(store, path), UpgradeMargin()) {
+				logger.Debugf("keeping existing subtitle %s scoring %d; new subtitle scores %d", validatedOutputPath, oldScore, fetched.Score)
+				return false, nil
+			}
+			if old != nil {
+				oldProvider = old.Provider
 			}
 			wasUpgrade = true
 		}
 	}
+	data, enc := NormalizeEncoding(fetched.Data, lang)
 	if err := os.WriteFile(validatedOutputPath, data, 0644); err != nil {
 		logger.Warnf("write %s: %v", validatedOutputPath, err)
 
@@ -151,7 +261,7 @@ func ProcessFile(ctx context.Context, path, lang string, providerName string, p
 			Error:     "Failed to write subtitle file: " + err.Error(),
 			Timestamp: time.Now(),
 		})
-		return err
+		return false, err
 	}
 	logger.Infof("downloaded subtitle %s", validatedOutputPath)
 
@@ -165,10 +275,13 @@ func ProcessFile(ctx context.Context, path, lang string, providerName string, p
 	if wasUpgrade {
 		events.PublishSubtitleUpgraded(ctx, events.SubtitleUpgradedData{
 			FilePath:        path,
+			OldSubtitlePath: validatedOutputPath,
 			NewSubtitlePath: validatedOutputPath,
 			Language:        lang,
+			OldProvider:     oldProvider,
 			NewProvider:     providerName,
-			NewScore:        1.0, // Default score, could be enhanced
+			OldScore:        NormalizedScore(oldScore),
+			NewScore:        NormalizedScore(fetched.Score),
 			Timestamp:       time.Now(),
 		})
 	} else {
@@ -177,27 +290,49 @@ func ProcessFile(ctx context.Context, path, lang string, providerName string, p
 			SubtitlePath: validatedOutputPath,
 			Language:     lang,
 			Provider:     providerName,
-			Score:        1.0, // Default score, could be enhanced
+			Score:        NormalizedScore(fetched.Score),
 			Size:         fileSize,
 			Timestamp:    time.Now(),
 		})
 	}
 	if store != nil {
-		_ = store.InsertDownload(&database.DownloadRecord{File: validatedOutputPath, VideoFile: path, Provider: providerName, Language: lang})
+		matchScore := NormalizedScore(fetched.Score)
+		_ = store.InsertDownload(&database.DownloadRecord{File: validatedOutputPath, VideoFile: path, Provider: providerName, Language: lang, MatchScore: &matchScore})
+		RecordSubtitle(store, validatedOutputPath, path, lang, providerName, enc)
 	}
-	return nil
+	return false, nil
 }
 
-var videoExtensions = []string{".mkv", ".mp4", ".avi", ".mov"}
+// NormalizeEncoding transcodes downloaded subtitle data to UTF-8 using lang
+// as a hint for legacy encodings. Data that cannot be transcoded is logged
+// and returned unchanged so the subtitle is still saved.
+func NormalizeEncoding(data []byte, lang string) ([]byte, charset.Result) {
+	out, res, err := charset.ToUTF8(data, lang)
+	if err != nil {
+		logging.GetLogger("scanner").Warnf("transcode %s subtitle to utf-8: %v", res.Encoding, err)
+		return data, res
+	}
+	if res.Encoding != charset.UTF8 {
+		logging.GetLogger("scanner").Debugf("transcoded %s subtitle from %s", lang, res.Encoding)
+	}
+	return out, res
+}
 
-func isVideoFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	for _, e := range videoExtensions {
-		if ext == e {
-			return true
-		}
+// RecordSubtitle stores the downloaded subtitle in the subtitle history with
+// its detected source encoding in the provider metadata.
+func RecordSubtitle(store database.SubtitleStore, file, video, lang, provider string, enc charset.Result) {
+	rec, err := database.CreateSubtitleRecord(file, video, lang, provider, &database.ProviderMetadata{
+		Encoding:   enc.Encoding,
+		Format:     strings.TrimPrefix(filepath.Ext(file), "."),
+		Language:   lang,
+		SourceName: provider,
+	})
+	if err == nil {
+		err = store.InsertSubtitle(rec)
+	}
+	if err != nil {
+		logging.GetLogger("scanner").Warnf("record subtitle %s: %v", file, err)
 	}
-	return false
 }
 
 // ScanDirectoryWithProfiles walks through the directory and downloads subtitles for video files
@@ -217,7 +352,7 @@ func ScanDirectoryWithProfiles(ctx context.Context, dir string, db *sql.DB, upgr
 		if d.IsDir() {
 			return nil
 		}
-		if !isVideoFile(path) {
+		if !fileindex.IsVideo(path) {
 			return nil
 		}
 		f := filepath.Clean(path)
@@ -242,6 +377,8 @@ func ScanDirectoryWithProfiles(ctx context.Context, dir string, db *sql.DB, upgr
 }
 
 // ProcessFileWithProfile downloads subtitles using the language profile assigned to the media file.
+// Upgrades follow the same score rules as ProcessFile using the profile's cutoff score,
+// and embedded subtitle tracks satisfy a language like they do for ProcessFile.
 func ProcessFileWithProfile(ctx context.Context, path string, db *sql.DB, upgrade bool, store database.SubtitleStore) error {
 	logger := logging.GetLogger("scanner")
 
@@ -252,6 +389,25 @@ func ProcessFileWithProfile(ctx context.Context, path string, db *sql.DB, upgrad
 		return err
 	}
 
+	// Like ProcessFile, skip the search when the preferred language is
+	// already present as a subtitle file or an embedded track.
+	langs, _ := providers.GetLanguagesFromProfile(ctx, db, sanitizedPath)
+	if len(langs) > 0 {
+		preferred, err := security.ValidateSubtitleOutputPath(sanitizedPath, langs[0])
+		if err != nil {
+			logger.Warnf("invalid subtitle output path: %v", err)
+			return err
+		}
+		if fileExists(preferred) {
+			if !upgrade {
+				logger.Debugf("subtitle already exists: %s", preferred)
+				return nil
+			}
+		} else if embeddedSubtitle(sanitizedPath, langs[0], preferred, store) {
+			return nil
+		}
+	}
+
 	// Use profile-based fetch to get subtitles
 	data, providerName, actualLang, err := providers.FetchWithProfile(ctx, db, sanitizedPath, "")
 	if err != nil {
@@ -266,29 +422,37 @@ func ProcessFileWithProfile(ctx context.Context, path string, db *sql.DB, upgrad
 		return err
 	}
 
-	if !upgrade {
-		if _, err := os.Stat(out); err == nil {
+	if fileExists(out) {
+		if !upgrade {
 			logger.Debugf("subtitle already exists: %s", out)
 			return nil
 		}
+	} else if (len(langs) == 0 || actualLang != langs[0]) && embeddedSubtitle(sanitizedPath, actualLang, out, store) {
+		// The fallback language found is already embedded.
+		return nil
 	}
 
+	score := FileScore(providerName, "", "", int64(len(data)), sanitizedPath)
 	if upgrade {
-		if oldData, err := os.ReadFile(out); err == nil {
-			if len(data) <= len(oldData) {
-				logger.Debugf("existing subtitle %s is higher quality", out)
+		if _, err := os.Stat(out); err == nil {
+			oldScore := installedScore(installedDownload(store, sanitizedPath, out), sanitizedPath, out)
+			if !shouldUpgrade(score, oldScore, profileCutoff(db, sanitizedPath), UpgradeMargin()) {
+				logger.Debugf("keeping existing subtitle %s scoring %d; new subtitle scores %d", out, oldScore, score)
 				return nil
 			}
 		}
 	}
 
+	data, enc := NormalizeEncoding(data, actualLang)
 	if err := os.WriteFile(out, data, 0644); err != nil {
 		logger.Warnf("write: %v", err)
 		return err
 	}
 	logger.Infof("downloaded %s subtitle %s using profile", actualLang, out)
 	if store != nil {
-		_ = store.InsertDownload(&database.DownloadRecord{File: out, VideoFile: sanitizedPath, Provider: providerName, Language: actualLang})
+		matchScore := NormalizedScore(score)
+		_ = store.InsertDownload(&database.DownloadRecord{File: out, VideoFile: sanitizedPath, Provider: providerName, Language: actualLang, MatchScore: &matchScore})
+		RecordSubtitle(store, out, sanitizedPath, actualLang, providerName, enc)
 	}
 	return nil
 }
@@ -1,16 +1,25 @@
 // file: pkg/scanner/scanner_test.go
-// version: 1.1.0
+// version: 1.8.0
 // guid: 74a6ae1b-741b-4e53-8f4d-2a36279cffd4
 package scanner
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
 	providersmocks "github.com/jdfalk/subtitle-manager/pkg/providers/mocks"
+	"github.com/jdfalk/subtitle-manager/pkg/testutil"
+	"github.com/jdfalk/subtitle-manager/pkg/video"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/mock"
 )
@@ -59,7 +68,7 @@ func TestScanDirectory(t *testing.T) {
 	if string(data) != "a" {
 		t.Fatalf("subtitle overwritten without upgrade")
 	}
-	// scan with upgrade should replace subtitle
+	// scan with upgrade and an equally scored subtitle keeps the existing one
 	m3 := providersmocks.NewMockProvider(t)
 	m3.On("Fetch", mock.Anything, mock.Anything, "en").Return([]byte("cc"), nil)
 	if err := ScanDirectory(context.Background(), dir, "en", "test", m3, true, 2, store); err != nil {
@@ -67,11 +76,259 @@ func TestScanDirectory(t *testing.T) {
 	}
 	m3.AssertExpectations(t)
 	data, _ = os.ReadFile(sub)
-	if string(data) != "cc" {
+	if string(data) != "a" {
+		t.Fatalf("subtitle replaced without better score: %q", data)
+	}
+	// scan with upgrade and a better scored candidate replaces the subtitle
+	p := &candidateProvider{data: map[string][]byte{"1": []byte("dd")}, cands: []providers.Candidate{goodCandidate("1", "movie")}}
+	if err := ScanDirectory(context.Background(), dir, "en", "test", p, true, 2, store); err != nil {
+		t.Fatalf("scan upgrade 2: %v", err)
+	}
+	data, _ = os.ReadFile(sub)
+	if string(data) != "dd" {
 		t.Fatalf("subtitle not upgraded: %q", data)
 	}
 }
 
+// candidateProvider is a providers.CandidateSearcher returning fixed candidates.
+type candidateProvider struct {
+	cands []providers.Candidate
+	data  map[string][]byte
+}
+
+func (c *candidateProvider) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	return nil, errors.New("fetch not supported")
+}
+
+func (c *candidateProvider) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]providers.Candidate, error) {
+	return c.cands, nil
+}
+
+func (c *candidateProvider) Download(ctx context.Context, cand providers.Candidate) ([]byte, error) {
+	return c.data[cand.ID], nil
+}
+
+// goodCandidate returns a trusted, hash-matched candidate for release.
+func goodCandidate(id, release string) providers.Candidate {
+	return providers.Candidate{
+		ID:         id,
+		Release:    release,
+		Format:     "srt",
+		HashMatch:  true,
+		Trusted:    true,
+		Downloads:  5000,
+		Rating:     9,
+		Votes:      50,
+		UploadDate: time.Now(),
+	}
+}
+
+func TestScanDirectoryIndexed(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.mkv"), filepath.Join(dir, "b.mkv")
+	for _, vid := range []string{a, b} {
+		if err := os.WriteFile(vid, []byte("x"), 0644); err != nil {
+			t.Fatalf("create video: %v", err)
+		}
+	}
+	viper.Set("media_directory", dir)
+	defer viper.Reset()
+	store, err := database.OpenPebble(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	scan := func(p providers.Provider, full bool) fileindex.Summary {
+		t.Helper()
+		summary, err := ScanDirectoryIndexed(context.Background(), dir, "en", "test", p, false, full, 2, store, nil)
+		if err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		return summary
+	}
+
+	m := providersmocks.NewMockProvider(t)
+	m.On("Fetch", mock.Anything, mock.Anything, "en").Return([]byte("a"), nil).Twice()
+	if got := scan(m, false); got != (fileindex.Summary{New: 2}) {
+		t.Fatalf("first scan %+v", got)
+	}
+	// unchanged files with subtitles are not evaluated again
+	if got := scan(providersmocks.NewMockProvider(t), false); got != (fileindex.Summary{Skipped: 2}) {
+		t.Fatalf("second scan %+v", got)
+	}
+	// a missing subtitle is searched again, a modified video is re-evaluated
+	if err := os.Remove(filepath.Join(dir, "a.en.srt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m = providersmocks.NewMockProvider(t)
+	m.On("Fetch", mock.Anything, a, "en").Return([]byte("a"), nil).Once()
+	if got := scan(m, false); got != (fileindex.Summary{Changed: 1, Rechecked: 1}) {
+		t.Fatalf("third scan %+v", got)
+	}
+	// deleted videos are dropped from the index
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := scan(providersmocks.NewMockProvider(t), false); got != (fileindex.Summary{Removed: 1, Skipped: 1}) {
+		t.Fatalf("fourth scan %+v", got)
+	}
+	// a full scan evaluates unchanged files
+	if got := scan(providersmocks.NewMockProvider(t), true); got != (fileindex.Summary{Rechecked: 1}) {
+		t.Fatalf("full scan %+v", got)
+	}
+	entries, err := store.ListFileIndexEntries(dir)
+	if err != nil {
+		t.Fatalf("list file index: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != a || entries[0].Results["subtitles:en"] != "satisfied" {
+		t.Fatalf("file index %+v", entries)
+	}
+}
+
+// TestScanDirectoryKeepsLibraryVideos verifies subtitle scans index the same
+// video formats as library scans, so they do not drop each other's entries.
+func TestScanDirectoryKeepsLibraryVideos(t *testing.T) {
+	dir := t.TempDir()
+	vid := filepath.Join(dir, "movie.webm")
+	if err := os.WriteFile(vid, []byte("x"), 0644); err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+	viper.Set("media_directory", dir)
+	defer viper.Reset()
+	store, err := database.OpenPebble(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.UpsertFileIndexEntry(&database.FileIndexEntry{Path: vid, Results: map[string]string{"library": "indexed"}}); err != nil {
+		t.Fatalf("seed file index: %v", err)
+	}
+
+	m := providersmocks.NewMockProvider(t)
+	m.On("Fetch", mock.Anything, vid, "en").Return([]byte("a"), nil).Once()
+	summary, err := ScanDirectoryIndexed(context.Background(), dir, "en", "test", m, false, false, 1, store, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if summary.Removed != 0 {
+		t.Fatalf("scan removed library entries: %+v", summary)
+	}
+	entries, err := store.ListFileIndexEntries(dir)
+	if err != nil {
+		t.Fatalf("list file index: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Results["library"] != "indexed" || entries[0].Results["subtitles:en"] != "satisfied" {
+		t.Fatalf("file index %+v", entries)
+	}
+}
+
+// TestScanDirectoryReportsFailures verifies failed files are counted by
+// ScanDirectoryIndexed and returned with their path by ScanDirectory.
+func TestScanDirectoryReportsFailures(t *testing.T) {
+	dir := t.TempDir()
+	vid := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(vid, []byte("x"), 0644); err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+	viper.Set("media_directory", dir)
+	defer viper.Reset()
+
+	m := providersmocks.NewMockProvider(t)
+	m.On("Fetch", mock.Anything, vid, "en").Return(nil, errors.New("provider down"))
+	summary, err := ScanDirectoryIndexed(context.Background(), dir, "en", "test", m, false, false, 1, nil, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if summary != (fileindex.Summary{New: 1, Failed: 1}) {
+		t.Fatalf("summary %+v", summary)
+	}
+	err = ScanDirectory(context.Background(), dir, "en", "test", m, false, 1, nil)
+	if err == nil || !strings.Contains(err.Error(), vid) {
+		t.Fatalf("expected failure for %s, got %v", vid, err)
+	}
+}
+
+func TestScanDirectoryEmbeddedTrack(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"movie.mkv", "other.mkv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("create video: %v", err)
+		}
+	}
+	// movie.mkv carries an English text track, other.mkv none.
+	ffprobe := filepath.Join(t.TempDir(), "ffprobe")
+	script := `#!/bin/sh
+for last; do :; done
+case "$last" in
+*movie.mkv) echo '{"streams":[{"index":2,"codec_type":"subtitle","codec_name":"subrip","tags":{"language":"eng"}}]}' ;;
+*) echo '{"streams":[]}' ;;
+esac
+`
+	if err := os.WriteFile(ffprobe, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	video.SetFFprobePath(ffprobe)
+	defer video.SetFFprobePath("ffprobe")
+	viper.Set("media_directory", dir)
+	defer viper.Reset()
+	store, err := database.OpenPebble(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	m := providersmocks.NewMockProvider(t)
+	m.On("Fetch", mock.Anything, filepath.Join(dir, "other.mkv"), "en").Return([]byte("a"), nil).Once()
+	summary, err := ScanDirectoryIndexed(context.Background(), dir, "en", "test", m, false, false, 1, store, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if summary != (fileindex.Summary{New: 2}) {
+		t.Fatalf("summary %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "movie.en.srt")); !os.IsNotExist(err) {
+		t.Fatalf("subtitle downloaded despite embedded track: %v", err)
+	}
+	// the embedded track keeps satisfying later scans
+	summary, err = ScanDirectoryIndexed(context.Background(), dir, "en", "test", providersmocks.NewMockProvider(t), false, false, 1, store, nil)
+	if err != nil {
+		t.Fatalf("scan 2: %v", err)
+	}
+	if summary != (fileindex.Summary{Skipped: 2}) {
+		t.Fatalf("summary 2 %+v", summary)
+	}
+}
+
+// TestProcessFileWithProfileEmbeddedTrack verifies profile downloads skip
+// the search when the preferred language is an embedded track.
+func TestProcessFileWithProfileEmbeddedTrack(t *testing.T) {
+	dir := t.TempDir()
+	vid := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(vid, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ffprobe := filepath.Join(t.TempDir(), "ffprobe")
+	script := "#!/bin/sh\necho '{\"streams\":[{\"index\":2,\"codec_type\":\"subtitle\",\"codec_name\":\"subrip\",\"tags\":{\"language\":\"eng\"}}]}'\n"
+	if err := os.WriteFile(ffprobe, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	video.SetFFprobePath(ffprobe)
+	defer video.SetFFprobePath("ffprobe")
+	viper.Set("media_directory", dir)
+	defer viper.Reset()
+
+	// No providers are registered, so any search would fail.
+	if err := ProcessFileWithProfile(context.Background(), vid, testutil.GetTestDB(t), false, nil); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "movie.en.srt")); !os.IsNotExist(err) {
+		t.Fatalf("subtitle written despite embedded track: %v", err)
+	}
+}
+
 func TestScanDirectoryInvalidPath(t *testing.T) {
 	err := ScanDirectory(context.Background(), "../invalid", "en", "test", nil, false, 1, nil)
 	if err == nil {
@@ -87,23 +344,38 @@ func TestProcessFileInvalidPath(t *testing.T) {
 }
 
 // TestProcessFile_UpgradeQuality ensures a subtitle is replaced only when the
-// new version is larger, implying better quality.
+// new version scores higher than the recorded score, regardless of size.
 func TestProcessFile_UpgradeQuality(t *testing.T) {
 	dir := t.TempDir()
 	viper.Set("media_directory", dir)
 	defer viper.Reset()
+	store, err := database.OpenPebble(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
 
-	vid := filepath.Join(dir, "movie.mkv")
+	vid := filepath.Join(dir, "Show.S01E02.1080p.BluRay.x264-GRP.mkv")
 	if err := os.WriteFile(vid, []byte("x"), 0644); err != nil {
 		t.Fatalf("create video: %v", err)
 	}
-	sub := filepath.Join(dir, "movie.en.srt")
-	if err := os.WriteFile(sub, []byte("existing subtitle"), 0644); err != nil {
-		t.Fatalf("create sub: %v", err)
+	sub := filepath.Join(dir, "Show.S01E02.1080p.BluRay.x264-GRP.en.srt")
+	good := &candidateProvider{
+		cands: []providers.Candidate{goodCandidate("1", "Show.S01E02.1080p.BluRay.x264-GRP")},
+		data:  map[string][]byte{"1": []byte("good")},
+	}
+	if err := ProcessFile(context.Background(), vid, "en", "test", good, true, store); err != nil {
+		t.Fatalf("process: %v", err)
 	}
+	recs, err := store.ListDownloadsByVideo(vid)
+	if err != nil || len(recs) != 1 || recs[0].MatchScore == nil {
+		t.Fatalf("expected scored download record, got %+v (%v)", recs, err)
+	}
+
+	// A larger subtitle for the wrong release must not replace it.
 	m := providersmocks.NewMockProvider(t)
-	m.On("Fetch", mock.Anything, mock.Anything, "en").Return([]byte("a"), nil)
-	if err := ProcessFile(context.Background(), vid, "en", "test", m, true, nil); err != nil {
+	m.On("Fetch", mock.Anything, mock.Anything, "en").Return([]byte("a much larger SDH subtitle"), nil)
+	if err := ProcessFile(context.Background(), vid, "en", "test", m, true, store); err != nil {
 		t.Fatalf("process: %v", err)
 	}
 	m.AssertExpectations(t)
@@ -111,11 +383,115 @@ func TestProcessFile_UpgradeQuality(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read subtitle: %v", err)
 	}
-	if string(data) != "existing subtitle" {
+	if string(data) != "good" {
 		t.Fatalf("subtitle replaced with lower quality: %q", data)
 	}
 }
 
+// TestProcessFile_UpgradeCutoff ensures upgrades below the language profile's
+// cutoff score are rejected even without a recorded score.
+func TestProcessFile_UpgradeCutoff(t *testing.T) {
+	dir := t.TempDir()
+	viper.Set("media_directory", dir)
+	defer viper.Reset()
+	store, err := database.OpenPebble(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	vid := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(vid, []byte("x"), 0644); err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+	sub := filepath.Join(dir, "movie.en.srt")
+	if err := os.WriteFile(sub, []byte("manual"), 0644); err != nil {
+		t.Fatalf("create sub: %v", err)
+	}
+	weak := &candidateProvider{
+		cands: []providers.Candidate{{ID: "1", Release: "Other.Movie.720p.HDTV", Format: "srt"}},
+		data:  map[string][]byte{"1": []byte("weak")},
+	}
+	if err := ProcessFile(context.Background(), vid, "en", "test", weak, true, store); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if data, _ := os.ReadFile(sub); string(data) != "manual" {
+		t.Fatalf("subtitle below cutoff installed: %q", data)
+	}
+}
+
+func TestShouldUpgrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		score    int
+		oldScore int
+		cutoff   int
+		margin   int
+		want     bool
+	}{
+		{"below cutoff", 90, 60, 95, 5, false},
+		{"within margin", 83, 80, 80, 5, false},
+		{"beats margin", 85, 80, 80, 5, true},
+		{"lower score", 70, 90, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldUpgrade(tt.score, tt.oldScore, tt.cutoff, tt.margin); got != tt.want {
+				t.Fatalf("shouldUpgrade = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInstalledScore verifies installed subtitles without a recorded score
+// are scored from their file instead of being treated as unbeatable.
+func TestInstalledScore(t *testing.T) {
+	dir := t.TempDir()
+	vid := filepath.Join(dir, "Movie.2020.1080p.BluRay.x264-GRP.mkv")
+	sub := filepath.Join(dir, "Movie.2020.1080p.BluRay.x264-GRP.en.srt")
+	if err := os.WriteFile(sub, []byte("subtitle"), 0644); err != nil {
+		t.Fatalf("create sub: %v", err)
+	}
+	recorded := 0.9
+	if got := installedScore(&database.DownloadRecord{MatchScore: &recorded}, vid, sub); got != 90 {
+		t.Fatalf("recorded score = %d, want 90", got)
+	}
+	unscored := installedScore(nil, vid, sub)
+	if want := FileScore("", filepath.Base(sub), "", 8, vid); unscored != want {
+		t.Fatalf("unscored subtitle = %d, want %d", unscored, want)
+	}
+	if best := CandidateScore(goodCandidate("1", "Movie.2020.1080p.BluRay.x264-GRP"), vid); !shouldUpgrade(best, unscored, 0, DefaultUpgradeMargin) {
+		t.Fatalf("matching candidate scoring %d does not replace unscored subtitle scoring %d", best, unscored)
+	}
+}
+
+// TestFetchScoredAllProviders verifies subtitles found by trying every
+// provider are scored from the provider's search results.
+func TestFetchScoredAllProviders(t *testing.T) {
+	release := "Movie.2020.1080p.BluRay.x264-GRP"
+	good := &candidateProvider{
+		cands: []providers.Candidate{goodCandidate("1", release)},
+		data:  map[string][]byte{"1": []byte("good")},
+	}
+	providers.RegisterFactory("scannerall", func() providers.Provider { return good })
+	inst := providers.Instance{ID: "scannerall-1", Name: "scannerall", Enabled: true}
+	providers.RegisterInstance(inst)
+	t.Cleanup(func() {
+		inst.Enabled = false
+		providers.RegisterInstance(inst)
+	})
+
+	vid := filepath.Join(t.TempDir(), release+".mkv")
+	got, err := FetchScored(context.Background(), vid, "en", "", "", nil)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	want := CandidateScore(goodCandidate("1", release), vid)
+	if string(got.Data) != "good" || got.Provider != inst.ID || got.Score != want {
+		t.Fatalf("got %q from %s scoring %d, want good from %s scoring %d", got.Data, got.Provider, got.Score, inst.ID, want)
+	}
+}
+
 func TestProcessFileInvalidLanguage(t *testing.T) {
 	dir := t.TempDir()
 	viper.Set("media_directory", dir)
@@ -168,7 +544,8 @@ func TestProcessFileInvalidProvider(t *testing.T) {
 	}
 }
 
-// TestProcessFilePathValidation ensures ProcessFile properly validates paths and upgrades subtitles only when appropriate.
+// TestProcessFilePathValidation ensures ProcessFile properly validates paths
+// and scores unscored subtitles from their file before upgrading them.
 func TestProcessFilePathValidation(t *testing.T) {
 	dir := t.TempDir()
 	viper.Set("media_directory", dir)
@@ -186,24 +563,65 @@ func TestProcessFilePathValidation(t *testing.T) {
 		t.Fatalf("create existing subtitle: %v", err)
 	}
 
-	// Mock provider that returns a larger subtitle
+	// A subtitle fetched without metadata scores the same as the unscored
+	// installed one and does not beat the upgrade margin.
 	m := providersmocks.NewMockProvider(t)
 	m.On("Fetch", mock.Anything, mock.Anything, "en").Return([]byte("new larger subtitle content"), nil)
-
-	// Test ProcessFile with upgrade=true, which should replace the subtitle if new content is larger
-	err := ProcessFile(context.Background(), vid, "en", "test", m, true, nil)
-	if err != nil {
+	if err := ProcessFile(context.Background(), vid, "en", "test", m, true, nil); err != nil {
 		t.Fatalf("ProcessFile should succeed with valid paths: %v", err)
 	}
-
-	// Verify the subtitle was updated (since new content is larger)
+	m.AssertExpectations(t)
 	data, err := os.ReadFile(existingSub)
 	if err != nil {
-		t.Fatalf("read updated subtitle: %v", err)
+		t.Fatalf("read subtitle: %v", err)
 	}
-	if string(data) != "new larger subtitle content" {
-		t.Fatalf("subtitle not upgraded: %q", data)
+	if string(data) != "existing subtitle" {
+		t.Fatalf("subtitle replaced by an equal match: %q", data)
 	}
 
-	m.AssertExpectations(t)
+	// A hash-matched candidate scores well above it and replaces it.
+	good := &candidateProvider{
+		cands: []providers.Candidate{goodCandidate("1", "movie")},
+		data:  map[string][]byte{"1": []byte("good")},
+	}
+	if err := ProcessFile(context.Background(), vid, "en", "test", good, true, nil); err != nil {
+		t.Fatalf("ProcessFile should succeed with valid paths: %v", err)
+	}
+	if data, _ := os.ReadFile(existingSub); string(data) != "good" {
+		t.Fatalf("unscored subtitle not upgraded: %q", data)
+	}
+}
+
+func TestProcessFileSeasonPack(t *testing.T) {
+	dir := t.TempDir()
+	viper.Set("media_directory", dir)
+	defer viper.Reset()
+
+	vid := filepath.Join(dir, "Show.S02E03.720p.HDTV.x264-GRP.mkv")
+	if err := os.WriteFile(vid, []byte("x"), 0644); err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"Show.S02E02.720p.HDTV.x264-GRP.srt", "Show.S02E03.720p.HDTV.x264-GRP.srt", "Show.S02E04.720p.HDTV.x264-GRP.srt"} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	m := providersmocks.NewMockProvider(t)
+	m.On("Fetch", mock.Anything, mock.Anything, "en").Return(buf.Bytes(), nil)
+	if err := ProcessFile(context.Background(), vid, "en", "test", m, false, nil); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Show.S02E03.720p.HDTV.x264-GRP.en.srt"))
+	if err != nil {
+		t.Fatalf("read subtitle: %v", err)
+	}
+	if string(data) != "Show.S02E03.720p.HDTV.x264-GRP.srt" {
+		t.Fatalf("wrong archive entry saved: %q", data)
+	}
 }
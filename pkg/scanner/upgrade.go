@@ -0,0 +1,230 @@
+// file: pkg/scanner/upgrade.go
+// version: 1.2.0
+// guid: ca167343-04f2-46a0-aa15-5d64aaab608e
+
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/archive"
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/profiles"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/scoring"
+)
+
+// DefaultUpgradeMargin is the number of score points a new subtitle must gain
+// over the installed one before it replaces it. It is used when
+// scoring.upgrade_margin is not configured.
+const DefaultUpgradeMargin = 5
+
+// UpgradeMargin returns the configured upgrade margin in score points.
+func UpgradeMargin() int {
+	if viper.IsSet("scoring.upgrade_margin") {
+		return viper.GetInt("scoring.upgrade_margin")
+	}
+	return DefaultUpgradeMargin
+}
+
+// Fetched is a downloaded subtitle together with its quality score.
+type Fetched struct {
+	Data     []byte
+	Provider string
+	Score    int
+}
+
+// FetchScored downloads a subtitle for path and scores it with
+// scoring.CalculateScore. When p supports search, every candidate is scored
+// and the best one is downloaded. Otherwise the subtitle is fetched directly
+// and scored from the provider name, file size and, for archives, the name of
+// the extracted entry. A nil p tries every registered provider in turn as
+// providers.FetchFromAll does, scoring each the same way; key then overrides
+// their API keys when set. Archives are unpacked to the entry matching path.
+func FetchScored(ctx context.Context, path, lang, key, providerName string, p providers.Provider) (Fetched, error) {
+	logger := logging.GetLogger("scanner")
+	media := scoring.FromMediaPath(path)
+	profile := scoring.LoadProfileFromConfig()
+
+	if p == nil {
+		var score int
+		data, name, err := providers.FetchFromAllWith(ctx, path, lang, key, func(ctx context.Context, p providers.Provider, name string) ([]byte, error) {
+			ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			defer cancel()
+			f, err := FetchScored(ctx, path, lang, key, name, p)
+			score = f.Score
+			return f.Data, err
+		})
+		if err != nil {
+			return Fetched{Provider: name}, err
+		}
+		return Fetched{Data: data, Provider: name, Score: score}, nil
+	}
+
+	cands, err := providers.SearchCandidates(ctx, p, providerName, path, lang)
+	switch {
+	case err == nil && len(cands) > 0:
+		best, score := bestCandidate(cands, media, profile)
+		data, err := providers.DownloadCandidate(ctx, p, best)
+		if err == nil {
+			data, _, err = archive.Extract(data, path)
+		}
+		if err == nil {
+			return Fetched{Data: data, Provider: providerName, Score: score}, nil
+		}
+		logger.Debugf("download candidate %s from %s: %v", best.ID, providerName, err)
+	case err != nil && !errors.Is(err, providers.ErrSearchUnsupported):
+		logger.Debugf("search %s with %s: %v", path, providerName, err)
+	}
+
+	data, err := p.Fetch(ctx, path, lang)
+	var entry string
+	if err == nil {
+		data, entry, err = archive.Extract(data, path)
+	}
+	if err != nil {
+		return Fetched{Provider: providerName}, err
+	}
+	score := scoring.CalculateScore(fileSubtitle(providerName, entry, entry, int64(len(data))), media, profile).Total
+	return Fetched{Data: data, Provider: providerName, Score: score}, nil
+}
+
+// bestCandidate returns the highest scoring candidate and its total score.
+// Ties keep the provider's original ordering.
+func bestCandidate(cands []providers.Candidate, media scoring.MediaItem, profile scoring.Profile) (providers.Candidate, int) {
+	best, bestScore := 0, -1
+	for i, c := range cands {
+		if s := scoring.CalculateScore(scoring.FromCandidate(c), media, profile).Total; s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	return cands[best], bestScore
+}
+
+// CandidateScore returns the score of search result c for the video at path.
+func CandidateScore(c providers.Candidate, path string) int {
+	return scoring.CalculateScore(scoring.FromCandidate(c), scoring.FromMediaPath(path), scoring.LoadProfileFromConfig()).Total
+}
+
+// FileScore scores a subtitle for the video at path that comes without search
+// metadata, using its provider, file name, release name and size. Name and
+// release may be empty when unknown; the format is taken from the name and
+// defaults to SRT.
+func FileScore(providerName, name, release string, size int64, path string) int {
+	return scoring.CalculateScore(fileSubtitle(providerName, name, release, size), scoring.FromMediaPath(path), scoring.LoadProfileFromConfig()).Total
+}
+
+// fileSubtitle describes a subtitle without search metadata for scoring.
+func fileSubtitle(providerName, name, release string, size int64) scoring.Subtitle {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if format == "" {
+		format = "srt"
+	}
+	return scoring.Subtitle{
+		ProviderName: providerName,
+		Release:      strings.TrimSuffix(release, filepath.Ext(release)),
+		FileName:     name,
+		Format:       format,
+		FileSize:     size,
+	}
+}
+
+// installedDownload returns the most recent download record for subtitle file
+// sub of video, or nil when none exists.
+func installedDownload(store database.SubtitleStore, video, sub string) *database.DownloadRecord {
+	if store == nil {
+		return nil
+	}
+	recs, err := store.ListDownloadsByVideo(video)
+	if err != nil {
+		return nil
+	}
+	var latest *database.DownloadRecord
+	for i := range recs {
+		r := &recs[i]
+		if r.File != sub {
+			continue
+		}
+		if latest == nil || r.CreatedAt.After(latest.CreatedAt) {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// installedScore returns the score of the installed subtitle sub of video
+// described by old, which may be nil. Subtitles without a recorded score are
+// scored from their file. Its name is derived from the video and says nothing
+// about the release it was made for, so only its format and size count.
+func installedScore(old *database.DownloadRecord, video, sub string) int {
+	if score, ok := old.Score(); ok {
+		return score
+	}
+	var provider string
+	if old != nil {
+		provider = old.Provider
+	}
+	var size int64
+	if info, err := os.Stat(sub); err == nil {
+		size = info.Size()
+	}
+	return FileScore(provider, filepath.Base(sub), "", size, video)
+}
+
+// storeCutoff returns the cutoff score of the language profile assigned to
+// video, or the default profile when none is assigned. Zero is returned when
+// no profile is available.
+func storeCutoff(store database.SubtitleStore, video string) int {
+	if store == nil {
+		return 0
+	}
+	mediaID := video
+	if item, err := store.GetMediaItem(video); err == nil && item != nil {
+		mediaID = item.ID
+	}
+	profile, err := store.GetMediaProfile(mediaID)
+	if err != nil || profile == nil {
+		if profile, err = store.GetDefaultLanguageProfile(); err != nil || profile == nil {
+			return 0
+		}
+	}
+	return profile.CutoffScore
+}
+
+// profileCutoff returns the cutoff score of the language profile assigned to
+// path in db, or zero when db is nil or the profile cannot be loaded.
+func profileCutoff(db *sql.DB, path string) int {
+	if db == nil {
+		return 0
+	}
+	profile, err := profiles.NewService(db).GetMediaProfileByPath(path)
+	if err != nil || profile == nil {
+		return 0
+	}
+	return profile.CutoffScore
+}
+
+// shouldUpgrade reports whether a subtitle scoring newScore should replace
+// an installed subtitle scoring oldScore. The new subtitle must reach cutoff
+// and beat the installed score by at least margin.
+func shouldUpgrade(newScore, oldScore, cutoff, margin int) bool {
+	if newScore < cutoff {
+		return false
+	}
+	return newScore >= oldScore+margin
+}
+
+// NormalizedScore converts a 0-100 score to the 0-1 range used by
+// DownloadRecord.MatchScore and subtitle events.
+func NormalizedScore(score int) float64 {
+	return float64(score) / 100
+}
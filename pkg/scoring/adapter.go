@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/opensubtitles"
 )
 
@@ -46,6 +47,29 @@ func FromOpenSubtitlesResult(result opensubtitles.SearchResult, providerName str
 	return subtitle
 }
 
+// FromCandidate converts a structured provider search result to a Subtitle for scoring.
+func FromCandidate(c candidate.Candidate) Subtitle {
+	format := strings.ToLower(c.Format)
+	if format == "" {
+		format = getFormatFromURL(c.FileName)
+	}
+	return Subtitle{
+		ProviderName:      c.Provider,
+		IsTrusted:         c.Trusted,
+		Release:           c.Release,
+		FileName:          c.FileName,
+		HashMatch:         c.HashMatch,
+		Format:            format,
+		HearingImpaired:   c.HearingImpaired,
+		ForcedSubtitle:    c.Forced,
+		UploadDate:        c.UploadDate,
+		DownloadCount:     c.Downloads,
+		Rating:            c.Rating,
+		Votes:             c.Votes,
+		MachineTranslated: c.MachineTranslated,
+	}
+}
+
 // FromMediaPath extracts media information from a file path for scoring context.
 func FromMediaPath(mediaPath string) MediaItem {
 	filename := filepath.Base(mediaPath)
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jdfalk/subtitle-manager/pkg/providers/candidate"
 	"github.com/jdfalk/subtitle-manager/pkg/providers/opensubtitles"
 )
 
@@ -132,6 +133,40 @@ func TestFromOpenSubtitlesResult(t *testing.T) {
 	}
 }
 
+func TestFromCandidate(t *testing.T) {
+	c := candidate.Candidate{
+		Provider:        "podnapisi",
+		Release:         "Movie.2023.1080p.BluRay.x264-GROUP",
+		FileName:        "movie.ass",
+		HearingImpaired: true,
+		Forced:          true,
+		Downloads:       300,
+		Rating:          7,
+		Votes:           3,
+		HashMatch:       true,
+		Trusted:         true,
+	}
+
+	subtitle := FromCandidate(c)
+	if subtitle.ProviderName != "podnapisi" || subtitle.Release != c.Release {
+		t.Errorf("unexpected identity fields: %+v", subtitle)
+	}
+	if subtitle.Format != "ass" {
+		t.Errorf("Expected format derived from file name, got '%s'", subtitle.Format)
+	}
+	if !subtitle.HearingImpaired || !subtitle.ForcedSubtitle || !subtitle.IsTrusted || !subtitle.HashMatch {
+		t.Errorf("flags not copied: %+v", subtitle)
+	}
+	if subtitle.DownloadCount != 300 || subtitle.Rating != 7 || subtitle.Votes != 3 {
+		t.Errorf("counters not copied: %+v", subtitle)
+	}
+
+	score := CalculateScore(subtitle, FromMediaPath("/m/Other.2023.720p.WEB-DL-X.mkv"), DefaultProfile())
+	if score.ReleaseScore != 100 {
+		t.Errorf("Expected hash match to give full release score, got %d", score.ReleaseScore)
+	}
+}
+
 func TestFromMediaPath(t *testing.T) {
 	tests := []struct {
 		name     string
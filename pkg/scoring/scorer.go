@@ -25,8 +25,9 @@ type Subtitle struct {
 	IsTrusted    bool   `json:"isTrusted"`
 
 	// Release information
-	Release  string `json:"release"`
-	FileName string `json:"fileName"`
+	Release   string `json:"release"`
+	FileName  string `json:"fileName"`
+	HashMatch bool   `json:"hashMatch"`
 
 	// Quality metadata
 	Format          string    `json:"format"`
@@ -157,6 +158,11 @@ func calculateReleaseScore(subtitle Subtitle, media MediaItem, profile Profile)
 	mediaSource := strings.ToLower(media.Source)
 	mediaGroup := strings.ToLower(media.ReleaseGroup)
 
+	// A file hash match means the subtitle was made for this exact file
+	if subtitle.HashMatch {
+		return 100
+	}
+
 	// Perfect release group match
 	if mediaGroup != "" && strings.Contains(subtitleRelease, mediaGroup) {
 		score += 40
@@ -0,0 +1,212 @@
+// file: pkg/services/auth_interceptor.go
+// version: 1.1.0
+// guid: 0d7c4e2a-5b91-4f3e-8a6d-2c9e1b7f4a05
+
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	enginev1 "github.com/jdfalk/subtitle-manager/pkg/engine/v1"
+	filev1 "github.com/jdfalk/subtitle-manager/pkg/file/v1"
+	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	webv1 "github.com/jdfalk/subtitle-manager/pkg/web/v1"
+)
+
+// Metadata keys read by the auth interceptor. The authorization header may
+// carry either a session token or an API key as a bearer token.
+const (
+	MetadataAuthorization = "authorization"
+	MetadataSession       = "x-session-id"
+	MetadataAPIKey        = "x-api-key"
+)
+
+// protectedServices lists the services whose methods require credentials.
+// Other services registered on the same server, such as the legacy
+// translator service, are passed through unchanged.
+var protectedServices = []string{
+	webv1.WebService_ServiceDesc.ServiceName,
+	filev1.FileService_ServiceDesc.ServiceName,
+	enginev1.EngineService_ServiceDesc.ServiceName,
+}
+
+// publicMethods can be called without credentials.
+var publicMethods = map[string]bool{
+	webv1.WebService_AuthenticateUser_FullMethodName:  true,
+	webv1.WebService_HealthCheck_FullMethodName:       true,
+	filev1.FileService_HealthCheck_FullMethodName:     true,
+	enginev1.EngineService_HealthCheck_FullMethodName: true,
+}
+
+// methodPermissions maps methods to the gcommonauth permission they need.
+// Methods not listed require "basic".
+var methodPermissions = map[string]string{
+	webv1.WebService_GetUser_FullMethodName:                      "read",
+	webv1.WebService_LogoutUser_FullMethodName:                   "read",
+	webv1.WebService_DownloadSubtitle_FullMethodName:             "read",
+	webv1.WebService_SearchSubtitles_FullMethodName:              "read",
+	webv1.WebService_GetTranslationStatus_FullMethodName:         "read",
+	webv1.WebService_DownloadFile_FullMethodName:                 "read",
+	filev1.FileService_DownloadFile_FullMethodName:               "read",
+	filev1.FileService_GetFileInfo_FullMethodName:                "read",
+	filev1.FileService_ListFiles_FullMethodName:                  "read",
+	filev1.FileService_SearchFiles_FullMethodName:                "read",
+	filev1.FileService_GetStorageInfo_FullMethodName:             "read",
+	filev1.FileService_CleanupFiles_FullMethodName:               "all",
+	enginev1.EngineService_GetTranscriptionStatus_FullMethodName: "read",
+	enginev1.EngineService_GetTranslationProgress_FullMethodName: "read",
+	enginev1.EngineService_GetEngineStatus_FullMethodName:        "read",
+}
+
+// Caller identifies the authenticated user of a gRPC call.
+type Caller struct {
+	UserID int64
+	// Session is the session token used, empty for API key calls.
+	Session string
+}
+
+// owner returns the caller as a queue job owner, in the same form the HTTP
+// API records for the jobs it queues.
+func (c Caller) owner() string {
+	return strconv.FormatInt(c.UserID, 10)
+}
+
+type callerKey struct{}
+
+// CallerFromContext returns the caller stored by the auth interceptor.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	c, ok := ctx.Value(callerKey{}).(Caller)
+	return c, ok
+}
+
+// ContextWithCaller returns ctx carrying c, as the auth interceptor does.
+func ContextWithCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// AuthInterceptor authenticates calls to the web, file and engine services
+// with the same gcommonauth sessions and API keys used by the HTTP API and
+// checks the caller's role permission for the method.
+type AuthInterceptor struct {
+	db *sql.DB
+}
+
+// NewAuthInterceptor creates an interceptor using the auth database db.
+func NewAuthInterceptor(db *sql.DB) *AuthInterceptor {
+	return &AuthInterceptor{db: db}
+}
+
+// ServerOptions returns the unary and stream interceptor options.
+func (a *AuthInterceptor) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(a.Unary()),
+		grpc.ChainStreamInterceptor(a.Stream()),
+	}
+}
+
+// Unary returns the unary server interceptor.
+func (a *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the stream server interceptor.
+func (a *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &callerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// callerStream overrides the context of a server stream.
+type callerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *callerStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize authenticates the caller of method and returns a context
+// carrying it.
+func (a *AuthInterceptor) authorize(ctx context.Context, method string) (context.Context, error) {
+	if !isProtected(method) || publicMethods[method] {
+		return ctx, nil
+	}
+	caller, ok := a.authenticate(ctx)
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "valid session or API key required")
+	}
+	perm := methodPermissions[method]
+	if perm == "" {
+		perm = "basic"
+	}
+	allowed, err := auth.CheckPermission(a.db, caller.UserID, perm)
+	if err != nil || !allowed {
+		return ctx, status.Errorf(codes.PermissionDenied, "%s permission required", perm)
+	}
+	return ContextWithCaller(ctx, caller), nil
+}
+
+// authenticate resolves the credentials in the incoming metadata.
+func (a *AuthInterceptor) authenticate(ctx context.Context) (Caller, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	bearer := first(MetadataAuthorization)
+	if len(bearer) > 7 && strings.EqualFold(bearer[:7], "bearer ") {
+		bearer = strings.TrimSpace(bearer[7:])
+	}
+	for _, token := range []string{first(MetadataSession), bearer} {
+		if token == "" {
+			continue
+		}
+		if s, err := auth.ValidateSession(a.db, token); err == nil {
+			if id, err := strconv.ParseInt(s.GetUserId(), 10, 64); err == nil {
+				return Caller{UserID: id, Session: token}, true
+			}
+		}
+	}
+	for _, key := range []string{first(MetadataAPIKey), bearer} {
+		if key == "" {
+			continue
+		}
+		if k, err := auth.ValidateAPIKey(a.db, key); err == nil {
+			if id, err := strconv.ParseInt(k.GetUserId(), 10, 64); err == nil {
+				return Caller{UserID: id}, true
+			}
+		}
+	}
+	return Caller{}, false
+}
+
+// isProtected reports whether method belongs to a protected service.
+func isProtected(method string) bool {
+	for _, svc := range protectedServices {
+		if strings.HasPrefix(method, "/"+svc+"/") {
+			return true
+		}
+	}
+	return false
+}
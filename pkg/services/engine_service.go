@@ -0,0 +1,685 @@
+// file: pkg/services/engine_service.go
+// version: 1.4.0
+// guid: acc208fa-31dc-49f1-9924-bef4295fcb25
+
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	enginev1 "github.com/jdfalk/subtitle-manager/pkg/engine/v1"
+	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/security"
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	"github.com/jdfalk/subtitle-manager/pkg/tasks"
+	"github.com/jdfalk/subtitle-manager/pkg/transcriber"
+)
+
+// Job types reported by the engine service.
+const (
+	engineJobTranscription = "transcription"
+	engineJobTranslation   = "translation"
+)
+
+// engineJobTypes maps the queue job types of engine jobs to the job types
+// reported by the engine service.
+var engineJobTypes = map[string]string{
+	string(queue.JobTypeEngineTranscription): engineJobTranscription,
+	string(queue.JobTypeEngineTranslation):   engineJobTranslation,
+}
+
+// TranscribeFunc transcribes the media file at path and returns SRT data.
+type TranscribeFunc func(ctx context.Context, path, lang string) ([]byte, error)
+
+// TranslateFunc translates the cues of items into lang in place.
+type TranslateFunc func(ctx context.Context, items []*astisub.Item, lang string) error
+
+// EngineConfig configures the engine service. Zero values fall back to the
+// application configuration.
+type EngineConfig struct {
+	// Transcribe produces subtitles from audio. Defaults to the Whisper API
+	// using openai_api_key.
+	Transcribe TranscribeFunc
+	// Translate translates subtitle cues. Defaults to
+	// subtitles.TranslateItems using translate_service and the configured
+	// API keys.
+	Translate TranslateFunc
+	// Queue runs transcription and translation jobs. Defaults to
+	// queue.GetQueue().
+	Queue *queue.Queue
+	// DB is the auth database. Callers with the all permission in it may
+	// read and cancel jobs queued by other users.
+	DB *sql.DB
+}
+
+// transcriptionPayload is the queue payload of a transcription job.
+type transcriptionPayload struct {
+	Media    string   `json:"media"`
+	Language string   `json:"language,omitempty"`
+	Targets  []string `json:"targets,omitempty"`
+}
+
+// transcript returns the path of the transcript written next to the media.
+func (p transcriptionPayload) transcript() string {
+	suffix := ".srt"
+	if p.Language != "" {
+		suffix = "." + p.Language + ".srt"
+	}
+	return siblingPath(p.Media, suffix)
+}
+
+// results returns the files a completed job has written.
+func (p transcriptionPayload) results() []string {
+	out := []string{p.transcript()}
+	for _, target := range p.Targets {
+		out = append(out, siblingPath(p.Media, "."+target+".srt"))
+	}
+	return out
+}
+
+// translationPayload is the queue payload of a translation job.
+type translationPayload struct {
+	Subtitle string `json:"subtitle"`
+	Language string `json:"language"`
+}
+
+// result returns the file a completed job has written.
+func (p translationPayload) result() string {
+	return siblingPath(p.Subtitle, "."+p.Language+".srt")
+}
+
+func init() {
+	queue.Register(queue.JobTypeEngineTranscription, func(p transcriptionPayload) string {
+		return fmt.Sprintf("Transcribe %s", p.Media)
+	}, runTranscription)
+	queue.Register(queue.JobTypeEngineTranslation, func(p translationPayload) string {
+		return fmt.Sprintf("Translate %s to %s", p.Subtitle, p.Language)
+	}, runTranslation)
+}
+
+// EngineServiceImpl implements the engine gRPC service. File IDs are absolute
+// paths on the engine host and must pass security.ValidateAndSanitizePath.
+// Transcription and translation run as jobs on the persistent queue; their
+// job IDs are queue job IDs and their state is kept in the queue store.
+type EngineServiceImpl struct {
+	config EngineConfig
+}
+
+// NewEngineService creates an engine service using the application configuration.
+func NewEngineService() *EngineServiceImpl {
+	return NewEngineServiceWithConfig(EngineConfig{})
+}
+
+// NewEngineServiceWithConfig creates an engine service using cfg.
+func NewEngineServiceWithConfig(cfg EngineConfig) *EngineServiceImpl {
+	return &EngineServiceImpl{config: cfg}
+}
+
+// defaultTranscribe transcribes with the Whisper API.
+func defaultTranscribe(ctx context.Context, path, lang string) ([]byte, error) {
+	return transcriber.WhisperTranscribe(path, lang, viper.GetString("openai_api_key"))
+}
+
+// defaultTranslate translates with the configured translation service.
+func defaultTranslate(ctx context.Context, items []*astisub.Item, lang string) error {
+	return subtitles.TranslateItems(items, lang, viper.GetString("translate_service"),
+		viper.GetString("google_api_key"), viper.GetString("openai_api_key"), viper.GetString("grpc_addr"))
+}
+
+// engineJob is a queued engine job that runs with the functions of the
+// service that queued it. Jobs resumed after a restart are decoded as plain
+// payload jobs and run with the application configuration.
+type engineJob struct {
+	*queue.PayloadJob
+	funcs EngineConfig
+}
+
+// Execute runs the registered handler with the job's engine functions.
+func (j *engineJob) Execute(ctx context.Context) error {
+	return j.PayloadJob.Execute(context.WithValue(ctx, engineFuncsKey{}, j.funcs))
+}
+
+type engineFuncsKey struct{}
+
+// jobFuncs returns the functions the engine job running with ctx uses.
+func jobFuncs(ctx context.Context) (TranscribeFunc, TranslateFunc) {
+	funcs, _ := ctx.Value(engineFuncsKey{}).(EngineConfig)
+	transcribe, translate := funcs.Transcribe, funcs.Translate
+	if transcribe == nil {
+		transcribe = defaultTranscribe
+	}
+	if translate == nil {
+		translate = defaultTranslate
+	}
+	return transcribe, translate
+}
+
+// runTranscription transcribes p.Media next to the media file and translates
+// the transcript into each target language.
+func runTranscription(ctx context.Context, p transcriptionPayload) error {
+	in, err := security.ValidateAndSanitizePath(p.Media)
+	if err != nil {
+		return err
+	}
+	p.Media = in
+	transcribe, translate := jobFuncs(ctx)
+	data, err := transcribe(ctx, in, p.Language)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	out := p.transcript()
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+	if len(p.Targets) == 0 {
+		return nil
+	}
+	queue.ReportProgress(ctx, 50)
+	for i, target := range p.Targets {
+		if err := translateSubtitleFile(ctx, out, siblingPath(in, "."+target+".srt"), target, translate); err != nil {
+			return err
+		}
+		queue.ReportProgress(ctx, 50+(i+1)*50/len(p.Targets))
+	}
+	return nil
+}
+
+// runTranslation translates p.Subtitle next to the source file.
+func runTranslation(ctx context.Context, p translationPayload) error {
+	in, err := security.ValidateAndSanitizePath(p.Subtitle)
+	if err != nil {
+		return err
+	}
+	p.Subtitle = in
+	_, translate := jobFuncs(ctx)
+	return translateSubtitleFile(ctx, in, p.result(), p.Language, translate)
+}
+
+func (e *EngineServiceImpl) queue() *queue.Queue {
+	if e.config.Queue != nil {
+		return e.config.Queue
+	}
+	return queue.GetQueue()
+}
+
+// enqueue adds a single attempt job of type t owned by the caller of ctx and
+// running with the service's engine functions, starting the queue if it is
+// not running, and returns the job ID.
+func (e *EngineServiceImpl) enqueue(ctx context.Context, t queue.JobType, payload any) (string, error) {
+	pj, err := queue.NewJob(t, payload)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "create job: %v", err)
+	}
+	job := &engineJob{PayloadJob: pj, funcs: EngineConfig{Transcribe: e.config.Transcribe, Translate: e.config.Translate}}
+	q := e.queue()
+	if !q.IsRunning() {
+		if err := q.Start(); err != nil && !q.IsRunning() {
+			return "", status.Errorf(codes.Unavailable, "start queue: %v", err)
+		}
+	}
+	id, err := q.AddWithOptions(job, queue.JobOptions{MaxAttempts: 1, Owner: callerOwner(ctx)})
+	if err != nil {
+		return "", status.Errorf(codes.Unavailable, "queue job: %v", err)
+	}
+	return id, nil
+}
+
+// callerOwner returns the queue job owner for the caller of ctx. It is empty
+// when the service is served without the auth interceptor.
+func callerOwner(ctx context.Context) string {
+	if c, ok := CallerFromContext(ctx); ok {
+		return c.owner()
+	}
+	return ""
+}
+
+// job returns the queue record and payload of job id of type t. Only the
+// caller who queued the job or a caller with the all permission may access
+// it.
+func (e *EngineServiceImpl) job(ctx context.Context, id string, t queue.JobType) (*database.QueueJob, any, error) {
+	rec, err := e.queue().Job(id)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "load job %q: %v", id, err)
+	}
+	if rec == nil || rec.Type != string(t) {
+		return nil, nil, status.Errorf(codes.NotFound, "%s job %q not found", engineJobTypes[string(t)], id)
+	}
+	if e.queue().Owner(rec) != callerOwner(ctx) && !e.hasFullAccess(ctx) {
+		return nil, nil, status.Errorf(codes.PermissionDenied, "%s job %q belongs to another user", engineJobTypes[string(t)], id)
+	}
+	job, err := e.queue().Decode(rec)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "decode job %q: %v", id, err)
+	}
+	switch j := job.(type) {
+	case *engineJob:
+		return rec, j.Payload, nil
+	case *queue.PayloadJob:
+		return rec, j.Payload, nil
+	default:
+		return nil, nil, status.Errorf(codes.Internal, "job %q has unexpected type %T", id, job)
+	}
+}
+
+// hasFullAccess reports whether the caller of ctx holds the all permission.
+func (e *EngineServiceImpl) hasFullAccess(ctx context.Context) bool {
+	c, ok := CallerFromContext(ctx)
+	if !ok || e.config.DB == nil {
+		return false
+	}
+	allowed, err := auth.CheckPermission(e.config.DB, c.UserID, "all")
+	return err == nil && allowed
+}
+
+// jobState returns the engine status and progress of the queue job rec.
+func jobState(rec *database.QueueJob) (string, float32) {
+	switch rec.Status {
+	case queue.StatusPending:
+		return "queued", 0
+	case queue.StatusRunning:
+		if snap, ok := tasks.Get(rec.ID); ok {
+			return "running", float32(snap.Progress)
+		}
+		return "running", 0
+	case queue.StatusCompleted:
+		return "completed", 100
+	case queue.StatusCancelled:
+		return "cancelled", 0
+	default:
+		return "failed", 0
+	}
+}
+
+// cancelJob cancels job id of type t for the caller of ctx and reports
+// whether it was queued or running.
+func (e *EngineServiceImpl) cancelJob(ctx context.Context, id string, t queue.JobType) (bool, error) {
+	if _, _, err := e.job(ctx, id, t); err != nil {
+		return false, err
+	}
+	cancelled, err := e.queue().Cancel(id)
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "cancel job %q: %v", id, err)
+	}
+	return cancelled, nil
+}
+
+// filePath validates a file ID and returns the local path.
+func filePath(id string) (string, error) {
+	if id == "" {
+		return "", status.Error(codes.InvalidArgument, "file id required")
+	}
+	p, err := security.ValidateAndSanitizePath(id)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "invalid file id: %v", err)
+	}
+	return p, nil
+}
+
+// siblingPath returns path with its extension replaced by suffix.
+func siblingPath(path, suffix string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + suffix
+}
+
+// TranscribeAudio starts transcribing the audio file. The transcript is
+// written next to the media file and translated into each target language.
+func (e *EngineServiceImpl) TranscribeAudio(ctx context.Context, req *enginev1.TranscribeAudioRequest) (*enginev1.TranscribeAudioResponse, error) {
+	in, err := filePath(req.GetAudioFileId())
+	if err != nil {
+		return &enginev1.TranscribeAudioResponse{}, err
+	}
+	lang := req.GetSourceLanguage()
+	targets := req.GetTargetLanguages()
+	for _, l := range append([]string{lang}, targets...) {
+		if l == "" {
+			continue
+		}
+		if err := security.ValidateLanguageCode(l); err != nil {
+			return &enginev1.TranscribeAudioResponse{}, status.Errorf(codes.InvalidArgument, "invalid language: %v", err)
+		}
+	}
+	id, err := e.enqueue(ctx, queue.JobTypeEngineTranscription, transcriptionPayload{Media: in, Language: lang, Targets: targets})
+	if err != nil {
+		return &enginev1.TranscribeAudioResponse{}, err
+	}
+
+	resp := &enginev1.TranscribeAudioResponse{}
+	resp.JobId = proto.String(id)
+	resp.Status = proto.String("queued")
+	resp.Success = proto.Bool(true)
+	return resp, nil
+}
+
+// GetTranscriptionStatus reports the progress and results of a transcription
+// job. Callers without the all permission may only read jobs they queued.
+func (e *EngineServiceImpl) GetTranscriptionStatus(ctx context.Context, req *enginev1.GetTranscriptionStatusRequest) (*enginev1.GetTranscriptionStatusResponse, error) {
+	rec, payload, err := e.job(ctx, req.GetJobId(), queue.JobTypeEngineTranscription)
+	if err != nil {
+		return &enginev1.GetTranscriptionStatusResponse{}, err
+	}
+	st, progress := jobState(rec)
+	resp := &enginev1.GetTranscriptionStatusResponse{}
+	resp.JobId = proto.String(rec.ID)
+	resp.Status = proto.String(st)
+	resp.Progress = proto.Float32(progress)
+	resp.Success = proto.Bool(st != "failed")
+	if p, ok := payload.(transcriptionPayload); ok && st == "completed" {
+		resp.ResultFileIds = p.results()
+	}
+	if rec.LastError != "" {
+		resp.ErrorMessage = proto.String(rec.LastError)
+	}
+	return resp, nil
+}
+
+// CancelTranscription cancels a queued or running transcription job. Callers
+// without the all permission may only cancel jobs they queued.
+func (e *EngineServiceImpl) CancelTranscription(ctx context.Context, req *enginev1.CancelTranscriptionRequest) (*enginev1.CancelTranscriptionResponse, error) {
+	cancelled, err := e.cancelJob(ctx, req.GetJobId(), queue.JobTypeEngineTranscription)
+	if err != nil {
+		return &enginev1.CancelTranscriptionResponse{}, err
+	}
+	resp := &enginev1.CancelTranscriptionResponse{}
+	resp.JobId = proto.String(req.GetJobId())
+	resp.Cancelled = proto.Bool(cancelled)
+	resp.Success = proto.Bool(true)
+	if !cancelled {
+		resp.ErrorMessage = proto.String("job is not queued or running")
+	}
+	return resp, nil
+}
+
+// TranslateSubtitle starts translating a subtitle file into the target
+// language. The result is written next to the source as <name>.<lang>.srt.
+func (e *EngineServiceImpl) TranslateSubtitle(ctx context.Context, req *enginev1.TranslateSubtitleRequest) (*enginev1.TranslateSubtitleResponse, error) {
+	in, err := filePath(req.GetSubtitleFileId())
+	if err != nil {
+		return &enginev1.TranslateSubtitleResponse{}, err
+	}
+	lang := req.GetTargetLanguage()
+	if err := security.ValidateLanguageCode(lang); err != nil {
+		return &enginev1.TranslateSubtitleResponse{}, status.Errorf(codes.InvalidArgument, "invalid target language: %v", err)
+	}
+	id, err := e.enqueue(ctx, queue.JobTypeEngineTranslation, translationPayload{Subtitle: in, Language: lang})
+	if err != nil {
+		return &enginev1.TranslateSubtitleResponse{}, err
+	}
+
+	resp := &enginev1.TranslateSubtitleResponse{}
+	resp.JobId = proto.String(id)
+	resp.Status = proto.String("queued")
+	resp.Success = proto.Bool(true)
+	return resp, nil
+}
+
+// GetTranslationProgress reports the progress and result of a translation
+// job. Callers without the all permission may only read jobs they queued.
+func (e *EngineServiceImpl) GetTranslationProgress(ctx context.Context, req *enginev1.GetTranslationProgressRequest) (*enginev1.GetTranslationProgressResponse, error) {
+	rec, payload, err := e.job(ctx, req.GetJobId(), queue.JobTypeEngineTranslation)
+	if err != nil {
+		return &enginev1.GetTranslationProgressResponse{}, err
+	}
+	st, progress := jobState(rec)
+	resp := &enginev1.GetTranslationProgressResponse{}
+	resp.JobId = proto.String(rec.ID)
+	resp.Status = proto.String(st)
+	resp.Progress = proto.Float32(progress)
+	resp.Success = proto.Bool(st != "failed")
+	if p, ok := payload.(translationPayload); ok && st == "completed" {
+		resp.ResultFileId = proto.String(p.result())
+	}
+	if rec.LastError != "" {
+		resp.ErrorMessage = proto.String(rec.LastError)
+	}
+	return resp, nil
+}
+
+// CancelTranslation cancels a queued or running translation job. Callers
+// without the all permission may only cancel jobs they queued.
+func (e *EngineServiceImpl) CancelTranslation(ctx context.Context, req *enginev1.CancelTranslationRequest) (*enginev1.CancelTranslationResponse, error) {
+	cancelled, err := e.cancelJob(ctx, req.GetJobId(), queue.JobTypeEngineTranslation)
+	if err != nil {
+		return &enginev1.CancelTranslationResponse{}, err
+	}
+	resp := &enginev1.CancelTranslationResponse{}
+	resp.JobId = proto.String(req.GetJobId())
+	resp.Cancelled = proto.Bool(cancelled)
+	resp.Success = proto.Bool(true)
+	if !cancelled {
+		resp.ErrorMessage = proto.String("job is not queued or running")
+	}
+	return resp, nil
+}
+
+// ConvertSubtitle converts a subtitle file to the target format. The result
+// is written next to the source with the format as extension.
+func (e *EngineServiceImpl) ConvertSubtitle(ctx context.Context, req *enginev1.ConvertSubtitleRequest) (*enginev1.ConvertSubtitleResponse, error) {
+	in, err := filePath(req.GetSourceFileId())
+	if err != nil {
+		return &enginev1.ConvertSubtitleResponse{}, err
+	}
+	target := subtitles.FormatSRT
+	if req.GetTargetFormat() != "" {
+		if target, err = subtitles.ParseFormat(req.GetTargetFormat()); err != nil {
+			return &enginev1.ConvertSubtitleResponse{}, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+	format := string(target)
+	data, err := subtitles.Convert(in, target, subtitles.ConvertOptions{})
+	if err != nil {
+		return &enginev1.ConvertSubtitleResponse{}, status.Errorf(codes.InvalidArgument, "convert %s: %v", in, err)
+	}
+	out := siblingPath(in, "."+format)
+	if out == in {
+		out = siblingPath(in, ".converted."+format)
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return &enginev1.ConvertSubtitleResponse{}, status.Errorf(codes.Internal, "write %s: %v", out, err)
+	}
+	resp := &enginev1.ConvertSubtitleResponse{}
+	resp.ResultFileId = proto.String(out)
+	resp.Format = proto.String(format)
+	resp.Success = proto.Bool(true)
+	return resp, nil
+}
+
+// ValidateSubtitle checks a subtitle file for parse, timing and encoding
+// problems. All checks run when no options are given. Line numbers in the
+// reported problems are one-based cue numbers.
+func (e *EngineServiceImpl) ValidateSubtitle(ctx context.Context, req *enginev1.ValidateSubtitleRequest) (*enginev1.ValidateSubtitleResponse, error) {
+	in, err := filePath(req.GetFileId())
+	if err != nil {
+		return &enginev1.ValidateSubtitleResponse{}, err
+	}
+	raw, err := os.ReadFile(in)
+	if err != nil {
+		return &enginev1.ValidateSubtitleResponse{}, status.Errorf(codes.NotFound, "read %s: %v", in, err)
+	}
+	opts := req.GetOptions()
+	checkTiming, checkEncoding, checkFormat := true, true, true
+	if opts != nil {
+		checkTiming, checkEncoding, checkFormat = opts.GetCheckTiming(), opts.GetCheckEncoding(), opts.GetCheckFormat()
+	}
+
+	var errs []*enginev1.ValidationError
+	var warns []*enginev1.ValidationWarning
+	addErr := func(code, msg string, line int) {
+		errs = append(errs, &enginev1.ValidationError{Code: proto.String(code), Message: proto.String(msg), LineNumber: proto.Int32(int32(line))})
+	}
+	addWarn := func(code, msg string, line int) {
+		warns = append(warns, &enginev1.ValidationWarning{Code: proto.String(code), Message: proto.String(msg), LineNumber: proto.Int32(int32(line))})
+	}
+
+	if checkEncoding && !utf8.Valid(raw) {
+		addWarn("invalid_encoding", "file is not valid UTF-8", 0)
+	}
+	sub, err := astisub.OpenFile(in)
+	switch {
+	case err != nil:
+		if checkFormat {
+			addErr("parse_error", err.Error(), 0)
+		}
+	case checkFormat && len(sub.Items) == 0:
+		addErr("empty", "subtitle contains no cues", 0)
+	}
+	if err == nil && checkTiming {
+		for i, it := range sub.Items {
+			if it.EndAt <= it.StartAt {
+				addErr("invalid_duration", fmt.Sprintf("cue ends at %v before it starts at %v", it.EndAt, it.StartAt), i+1)
+			}
+			if i > 0 {
+				prev := sub.Items[i-1]
+				if it.StartAt < prev.StartAt {
+					addErr("out_of_order", "cue starts before the previous cue", i+1)
+				} else if it.StartAt < prev.EndAt {
+					addWarn("overlap", "cue overlaps the previous cue", i+1)
+				}
+			}
+		}
+	}
+
+	resp := &enginev1.ValidateSubtitleResponse{}
+	resp.IsValid = proto.Bool(len(errs) == 0)
+	resp.Errors = errs
+	resp.Warnings = warns
+	resp.Success = proto.Bool(true)
+	return resp, nil
+}
+
+// MergeSubtitles interleaves the cues of several subtitle files with
+// subtitles.MergeTracks and writes the result next to the first file.
+func (e *EngineServiceImpl) MergeSubtitles(ctx context.Context, req *enginev1.MergeSubtitlesRequest) (*enginev1.MergeSubtitlesResponse, error) {
+	ids := req.GetFileIds()
+	if len(ids) < 2 {
+		return &enginev1.MergeSubtitlesResponse{}, status.Error(codes.InvalidArgument, "at least two file ids required")
+	}
+	opts := req.GetOptions()
+	if s := opts.GetMergeStrategy(); s != "" && s != "interleave" {
+		return &enginev1.MergeSubtitlesResponse{}, status.Errorf(codes.InvalidArgument, "unsupported merge strategy %q", s)
+	}
+	format := strings.ToLower(strings.TrimPrefix(opts.GetOutputFormat(), "."))
+	if format == "" {
+		format = "srt"
+	}
+
+	var paths []string
+	var items []*astisub.Item
+	for _, id := range ids {
+		p, err := filePath(id)
+		if err != nil {
+			return &enginev1.MergeSubtitlesResponse{}, err
+		}
+		sub, err := astisub.OpenFile(p)
+		if err != nil {
+			return &enginev1.MergeSubtitlesResponse{}, status.Errorf(codes.InvalidArgument, "open %s: %v", p, err)
+		}
+		paths = append(paths, p)
+		items = subtitles.MergeTracks(items, sub.Items)
+	}
+	data, err := encodeSubtitles(&astisub.Subtitles{Items: items}, format)
+	if err != nil {
+		return &enginev1.MergeSubtitlesResponse{}, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	out := siblingPath(paths[0], ".merged."+format)
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return &enginev1.MergeSubtitlesResponse{}, status.Errorf(codes.Internal, "write %s: %v", out, err)
+	}
+	resp := &enginev1.MergeSubtitlesResponse{}
+	resp.ResultFileId = proto.String(out)
+	resp.Success = proto.Bool(true)
+	return resp, nil
+}
+
+// GetEngineStatus reports the configured engines and the running jobs.
+func (e *EngineServiceImpl) GetEngineStatus(ctx context.Context, req *enginev1.GetEngineStatusRequest) (*enginev1.GetEngineStatusResponse, error) {
+	service := viper.GetString("translate_service")
+	if service == "" {
+		service = "google"
+	}
+	running, err := e.queue().History(queue.StatusRunning)
+	if err != nil {
+		return &enginev1.GetEngineStatusResponse{}, status.Errorf(codes.Internal, "list jobs: %v", err)
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].ID < running[j].ID })
+
+	var active []*enginev1.JobStatus
+	for i := range running {
+		rec := &running[i]
+		kind, ok := engineJobTypes[rec.Type]
+		if !ok {
+			continue
+		}
+		st, progress := jobState(rec)
+		active = append(active, &enginev1.JobStatus{
+			JobId:    proto.String(rec.ID),
+			Type:     proto.String(kind),
+			Status:   proto.String(st),
+			Progress: proto.Float32(progress),
+		})
+	}
+
+	resp := &enginev1.GetEngineStatusResponse{}
+	resp.Status = proto.String("ok")
+	resp.Engines = map[string]string{
+		"transcriber": "whisper",
+		"translator":  service,
+		"converter":   "astisub",
+	}
+	resp.ActiveJobs = active
+	resp.Success = proto.Bool(true)
+	return resp, nil
+}
+
+// HealthCheck reports that the engine service is serving.
+func (e *EngineServiceImpl) HealthCheck(ctx context.Context, req *enginev1.HealthCheckRequest) (*enginev1.HealthCheckResponse, error) {
+	resp := &enginev1.HealthCheckResponse{}
+	resp.Status = proto.String("ok")
+	resp.Success = proto.Bool(true)
+	return resp, nil
+}
+
+// translateSubtitleFile translates the cues of the subtitle at in with
+// translate and writes SRT output to out.
+func translateSubtitleFile(ctx context.Context, in, out, lang string, translate TranslateFunc) error {
+	sub, err := astisub.OpenFile(in)
+	if err != nil {
+		return err
+	}
+	if err := translate(ctx, sub.Items, lang); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := encodeSubtitles(sub, "srt")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, data, 0644)
+}
+
+// encodeSubtitles serializes sub in the given format.
+func encodeSubtitles(sub *astisub.Subtitles, format string) ([]byte, error) {
+	f, err := subtitles.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	return subtitles.Encode(sub, f, subtitles.ConvertOptions{})
+}
@@ -0,0 +1,411 @@
+// file: pkg/services/engine_service_test.go
+// version: 1.3.0
+// guid: 90fd025f-19c6-45e9-847e-b4070d4e0c75
+
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	enginev1 "github.com/jdfalk/subtitle-manager/pkg/engine/v1"
+	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/internal/queuetest"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/testutil"
+)
+
+const testSRT = "1\n00:00:01,000 --> 00:00:02,000\nhello\n\n2\n00:00:03,000 --> 00:00:04,000\nworld\n\n"
+
+// startEngineServer serves an engine service with cfg over bufconn.
+func startEngineServer(t *testing.T, cfg EngineConfig, opts ...grpc.ServerOption) enginev1.EngineServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(bufSize)
+	s := grpc.NewServer(opts...)
+	enginev1.RegisterEngineServiceServer(s, NewEngineServiceWithConfig(cfg))
+	go func() { _ = s.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+		lis.Close()
+	})
+	return enginev1.NewEngineServiceClient(conn)
+}
+
+// writeFile creates name in dir with content and returns its path.
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	return p
+}
+
+// upperTranslate is a TranslateFunc that upper-cases the cue text.
+func upperTranslate(ctx context.Context, items []*astisub.Item, lang string) error {
+	for _, it := range items {
+		for i := range it.Lines {
+			for j := range it.Lines[i].Items {
+				it.Lines[i].Items[j].Text = strings.ToUpper(it.Lines[i].Items[j].Text)
+			}
+		}
+	}
+	return nil
+}
+
+func TestEngineService_TranscribeAudio(t *testing.T) {
+	dir := t.TempDir()
+	media := writeFile(t, dir, "movie.mkv", "x")
+	client := startEngineServer(t, EngineConfig{
+		Transcribe: func(ctx context.Context, path, lang string) ([]byte, error) {
+			if path != media {
+				return nil, os.ErrNotExist
+			}
+			return []byte(testSRT), nil
+		},
+		Translate: upperTranslate,
+	})
+	ctx := context.Background()
+
+	resp, err := client.TranscribeAudio(ctx, &enginev1.TranscribeAudioRequest{
+		AudioFileId:     proto.String(media),
+		SourceLanguage:  proto.String("en"),
+		TargetLanguages: []string{"fr"},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.GetSuccess())
+
+	var last *enginev1.GetTranscriptionStatusResponse
+	st := queuetest.WaitWhile(t, func() (string, error) {
+		var err error
+		last, err = client.GetTranscriptionStatus(ctx, &enginev1.GetTranscriptionStatusRequest{JobId: proto.String(resp.GetJobId())})
+		return last.GetStatus(), err
+	}, "queued", "running")
+	require.Equal(t, "completed", st, last.GetErrorMessage())
+	require.Equal(t, float32(100), last.GetProgress())
+	require.Equal(t, []string{filepath.Join(dir, "movie.en.srt"), filepath.Join(dir, "movie.fr.srt")}, last.GetResultFileIds())
+
+	data, err := os.ReadFile(filepath.Join(dir, "movie.fr.srt"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "HELLO")
+
+	// The job is tracked by the queue store rather than in memory.
+	rec, err := queue.GetQueue().Job(resp.GetJobId())
+	require.NoError(t, err)
+	require.Equal(t, string(queue.JobTypeEngineTranscription), rec.Type)
+	require.Equal(t, queue.StatusCompleted, rec.Status)
+}
+
+func TestEngineService_CancelTranscription(t *testing.T) {
+	dir := t.TempDir()
+	media := writeFile(t, dir, "movie.mkv", "x")
+	started := make(chan struct{})
+	client := startEngineServer(t, EngineConfig{
+		Transcribe: func(ctx context.Context, path, lang string) ([]byte, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	ctx := context.Background()
+
+	resp, err := client.TranscribeAudio(ctx, &enginev1.TranscribeAudioRequest{AudioFileId: proto.String(media)})
+	require.NoError(t, err)
+	<-started
+
+	engine, err := client.GetEngineStatus(ctx, &enginev1.GetEngineStatusRequest{})
+	require.NoError(t, err)
+	var found bool
+	for _, j := range engine.GetActiveJobs() {
+		if j.GetJobId() == resp.GetJobId() {
+			found = true
+			require.Equal(t, "transcription", j.GetType())
+		}
+	}
+	require.True(t, found, "running job not reported")
+
+	cancel, err := client.CancelTranscription(ctx, &enginev1.CancelTranscriptionRequest{JobId: proto.String(resp.GetJobId())})
+	require.NoError(t, err)
+	require.True(t, cancel.GetCancelled())
+
+	var last *enginev1.GetTranscriptionStatusResponse
+	st := queuetest.WaitWhile(t, func() (string, error) {
+		var err error
+		last, err = client.GetTranscriptionStatus(ctx, &enginev1.GetTranscriptionStatusRequest{JobId: proto.String(resp.GetJobId())})
+		return last.GetStatus(), err
+	}, "queued", "running")
+	require.Equal(t, "cancelled", st)
+	require.Empty(t, last.GetResultFileIds())
+}
+
+// TestEngineService_JobOwnership verifies jobs belong to the caller who queued
+// them: other users can neither read nor cancel them unless they hold the all
+// permission.
+func TestEngineService_JobOwnership(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	defer db.Close()
+	_, err := db.Exec(`INSERT INTO permissions (role, permission) VALUES ('basic', 'basic')`)
+	require.NoError(t, err)
+	require.NoError(t, auth.CreateUser(db, "admin", "secret", "admin@example.com", "admin"))
+	require.NoError(t, auth.CreateUser(db, "alice", "secret", "alice@example.com", "basic"))
+	require.NoError(t, auth.CreateUser(db, "bob", "secret", "bob@example.com", "basic"))
+	var keys []string
+	for id := int64(1); id <= 3; id++ {
+		k, err := auth.GenerateAPIKey(db, id)
+		require.NoError(t, err)
+		keys = append(keys, k.GetId())
+	}
+	admin, alice, bob := withAPIKey(keys[0]), withAPIKey(keys[1]), withAPIKey(keys[2])
+
+	q := queue.NewQueue(1)
+	t.Cleanup(func() {
+		if q.IsRunning() {
+			_ = q.Stop()
+		}
+	})
+	client := startEngineServer(t, EngineConfig{
+		DB:    db,
+		Queue: q,
+		Transcribe: func(ctx context.Context, path, lang string) ([]byte, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}, NewAuthInterceptor(db).ServerOptions()...)
+	media := writeFile(t, t.TempDir(), "movie.mkv", "x")
+
+	resp, err := client.TranscribeAudio(alice, &enginev1.TranscribeAudioRequest{AudioFileId: proto.String(media)})
+	require.NoError(t, err)
+	id := proto.String(resp.GetJobId())
+	rec, err := q.Job(resp.GetJobId())
+	require.NoError(t, err)
+	require.Equal(t, "2", q.Owner(rec))
+
+	_, err = client.GetTranscriptionStatus(bob, &enginev1.GetTranscriptionStatusRequest{JobId: id})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.CancelTranscription(bob, &enginev1.CancelTranscriptionRequest{JobId: id})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.GetTranscriptionStatus(alice, &enginev1.GetTranscriptionStatusRequest{JobId: id})
+	require.NoError(t, err)
+	cancel, err := client.CancelTranscription(admin, &enginev1.CancelTranscriptionRequest{JobId: id})
+	require.NoError(t, err)
+	require.True(t, cancel.GetCancelled())
+}
+
+// TestEngineService_InstancesKeepTheirFuncs verifies jobs queued by one
+// service run with its functions after another service is created.
+func TestEngineService_InstancesKeepTheirFuncs(t *testing.T) {
+	dir := t.TempDir()
+	in := writeFile(t, dir, "movie.en.srt", testSRT)
+	first := startEngineServer(t, EngineConfig{Translate: upperTranslate})
+	startEngineServer(t, EngineConfig{Translate: func(ctx context.Context, items []*astisub.Item, lang string) error {
+		return errors.New("wrong service")
+	}})
+	ctx := context.Background()
+
+	resp, err := first.TranslateSubtitle(ctx, &enginev1.TranslateSubtitleRequest{
+		SubtitleFileId: proto.String(in),
+		TargetLanguage: proto.String("fr"),
+	})
+	require.NoError(t, err)
+	var last *enginev1.GetTranslationProgressResponse
+	st := queuetest.WaitWhile(t, func() (string, error) {
+		var err error
+		last, err = first.GetTranslationProgress(ctx, &enginev1.GetTranslationProgressRequest{JobId: proto.String(resp.GetJobId())})
+		return last.GetStatus(), err
+	}, "queued", "running")
+	require.Equal(t, "completed", st, last.GetErrorMessage())
+	data, err := os.ReadFile(last.GetResultFileId())
+	require.NoError(t, err)
+	require.Contains(t, string(data), "HELLO")
+}
+
+func TestEngineService_GetEngineStatus(t *testing.T) {
+	viper.Set("translate_service", "gpt")
+	defer viper.Set("translate_service", nil)
+	client := startEngineServer(t, EngineConfig{})
+
+	resp, err := client.GetEngineStatus(context.Background(), &enginev1.GetEngineStatusRequest{})
+	require.NoError(t, err)
+	require.True(t, resp.GetSuccess())
+	require.Equal(t, "ok", resp.GetStatus())
+	require.Equal(t, map[string]string{"transcriber": "whisper", "translator": "gpt", "converter": "astisub"}, resp.GetEngines())
+	for _, j := range resp.GetActiveJobs() {
+		require.Contains(t, []string{"transcription", "translation"}, j.GetType())
+	}
+}
+
+func TestEngineService_TranslateSubtitle(t *testing.T) {
+	dir := t.TempDir()
+	in := writeFile(t, dir, "movie.en.srt", testSRT)
+	client := startEngineServer(t, EngineConfig{Translate: upperTranslate})
+	ctx := context.Background()
+
+	resp, err := client.TranslateSubtitle(ctx, &enginev1.TranslateSubtitleRequest{
+		SubtitleFileId: proto.String(in),
+		TargetLanguage: proto.String("de"),
+	})
+	require.NoError(t, err)
+
+	var last *enginev1.GetTranslationProgressResponse
+	st := queuetest.WaitWhile(t, func() (string, error) {
+		var err error
+		last, err = client.GetTranslationProgress(ctx, &enginev1.GetTranslationProgressRequest{JobId: proto.String(resp.GetJobId())})
+		return last.GetStatus(), err
+	}, "queued", "running")
+	require.Equal(t, "completed", st, last.GetErrorMessage())
+	out := filepath.Join(dir, "movie.en.de.srt")
+	require.Equal(t, out, last.GetResultFileId())
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "WORLD")
+
+	// A finished job can no longer be cancelled.
+	cancel, err := client.CancelTranslation(ctx, &enginev1.CancelTranslationRequest{JobId: proto.String(resp.GetJobId())})
+	require.NoError(t, err)
+	require.False(t, cancel.GetCancelled())
+}
+
+func TestEngineService_CancelTranslation(t *testing.T) {
+	dir := t.TempDir()
+	in := writeFile(t, dir, "movie.en.srt", testSRT)
+	started := make(chan struct{})
+	client := startEngineServer(t, EngineConfig{
+		Translate: func(ctx context.Context, items []*astisub.Item, lang string) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	ctx := context.Background()
+
+	resp, err := client.TranslateSubtitle(ctx, &enginev1.TranslateSubtitleRequest{
+		SubtitleFileId: proto.String(in),
+		TargetLanguage: proto.String("de"),
+	})
+	require.NoError(t, err)
+	<-started
+
+	cancel, err := client.CancelTranslation(ctx, &enginev1.CancelTranslationRequest{JobId: proto.String(resp.GetJobId())})
+	require.NoError(t, err)
+	require.True(t, cancel.GetCancelled())
+
+	st := queuetest.WaitWhile(t, func() (string, error) {
+		r, err := client.GetTranslationProgress(ctx, &enginev1.GetTranslationProgressRequest{JobId: proto.String(resp.GetJobId())})
+		return r.GetStatus(), err
+	}, "queued", "running")
+	require.Equal(t, "cancelled", st)
+	_, err = os.Stat(filepath.Join(dir, "movie.en.de.srt"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestEngineService_UnknownJob(t *testing.T) {
+	client := startEngineServer(t, EngineConfig{})
+	_, err := client.GetTranslationProgress(context.Background(), &enginev1.GetTranslationProgressRequest{JobId: proto.String("missing")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.CancelTranscription(context.Background(), &enginev1.CancelTranscriptionRequest{JobId: proto.String("missing")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestEngineService_ConvertSubtitle(t *testing.T) {
+	dir := t.TempDir()
+	in := writeFile(t, dir, "movie.srt", testSRT)
+	client := startEngineServer(t, EngineConfig{})
+	ctx := context.Background()
+
+	resp, err := client.ConvertSubtitle(ctx, &enginev1.ConvertSubtitleRequest{
+		SourceFileId: proto.String(in),
+		TargetFormat: proto.String("vtt"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "movie.vtt"), resp.GetResultFileId())
+	data, err := os.ReadFile(resp.GetResultFileId())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), "WEBVTT"))
+
+	back, err := client.ConvertSubtitle(ctx, &enginev1.ConvertSubtitleRequest{
+		SourceFileId: proto.String(resp.GetResultFileId()),
+		TargetFormat: proto.String("srt"),
+	})
+	require.NoError(t, err)
+	data, err = os.ReadFile(back.GetResultFileId())
+	require.NoError(t, err)
+	require.Contains(t, string(data), "00:00:03,000 --> 00:00:04,000")
+
+	_, err = client.ConvertSubtitle(ctx, &enginev1.ConvertSubtitleRequest{
+		SourceFileId: proto.String(in),
+		TargetFormat: proto.String("doc"),
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.ConvertSubtitle(ctx, &enginev1.ConvertSubtitleRequest{SourceFileId: proto.String("relative.srt")})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestEngineService_ValidateSubtitle(t *testing.T) {
+	dir := t.TempDir()
+	good := writeFile(t, dir, "good.srt", testSRT)
+	bad := writeFile(t, dir, "bad.srt", "1\n00:00:05,000 --> 00:00:04,000\nbackwards\n\n2\n00:00:04,500 --> 00:00:06,000\noverlap\n\n")
+	client := startEngineServer(t, EngineConfig{})
+	ctx := context.Background()
+
+	resp, err := client.ValidateSubtitle(ctx, &enginev1.ValidateSubtitleRequest{FileId: proto.String(good)})
+	require.NoError(t, err)
+	require.True(t, resp.GetIsValid())
+	require.Empty(t, resp.GetErrors())
+
+	resp, err = client.ValidateSubtitle(ctx, &enginev1.ValidateSubtitleRequest{FileId: proto.String(bad)})
+	require.NoError(t, err)
+	require.False(t, resp.GetIsValid())
+	require.Len(t, resp.GetErrors(), 2)
+	require.Equal(t, "invalid_duration", resp.GetErrors()[0].GetCode())
+	require.Equal(t, int32(1), resp.GetErrors()[0].GetLineNumber())
+	require.Equal(t, "out_of_order", resp.GetErrors()[1].GetCode())
+
+	// Timing checks can be disabled.
+	resp, err = client.ValidateSubtitle(ctx, &enginev1.ValidateSubtitleRequest{
+		FileId:  proto.String(bad),
+		Options: &enginev1.ValidationOptions{CheckFormat: proto.Bool(true)},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.GetIsValid())
+}
+
+func TestEngineService_MergeSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.srt", testSRT)
+	b := writeFile(t, dir, "b.srt", "1\n00:00:02,500 --> 00:00:02,900\nbetween\n\n")
+	client := startEngineServer(t, EngineConfig{})
+	ctx := context.Background()
+
+	resp, err := client.MergeSubtitles(ctx, &enginev1.MergeSubtitlesRequest{FileIds: []string{a, b}})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "a.merged.srt"), resp.GetResultFileId())
+	data, err := os.ReadFile(resp.GetResultFileId())
+	require.NoError(t, err)
+	text := string(data)
+	require.Less(t, strings.Index(text, "hello"), strings.Index(text, "between"))
+	require.Less(t, strings.Index(text, "between"), strings.Index(text, "world"))
+
+	_, err = client.MergeSubtitles(ctx, &enginev1.MergeSubtitlesRequest{FileIds: []string{a}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
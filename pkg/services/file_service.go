@@ -0,0 +1,1149 @@
+// file: pkg/services/file_service.go
+// version: 1.1.0
+// guid: 3f0b7c52-8d1e-4a6b-9c27-5e4d1a80f6b3
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	filev1 "github.com/jdfalk/subtitle-manager/pkg/file/v1"
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/security"
+	"github.com/jdfalk/subtitle-manager/pkg/storage"
+)
+
+// Reserved custom metadata keys understood by UploadFile.
+const (
+	// FileMetaUploadID names a resumable upload. Interrupted uploads with an
+	// ID keep their staged data so a later stream can continue from the
+	// staged size.
+	FileMetaUploadID = "upload_id"
+	// FileMetaSHA256 is the hex SHA-256 of the complete file. Uploads are
+	// rejected when the received data does not match, and downloads report
+	// the stored checksum under the same key.
+	FileMetaSHA256 = "sha256"
+)
+
+const (
+	// fileChunkSize is the size of chunks sent by DownloadFile.
+	fileChunkSize = 64 * 1024
+	// fileMetaPrefix holds the JSON metadata record of each stored file.
+	fileMetaPrefix = ".meta/"
+	// fileTrashPrefix holds files removed without the permanent flag.
+	fileTrashPrefix = ".trash/"
+)
+
+var uploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// activeUploads tracks the staging keys of uploads with an open stream so
+// that two streams cannot write the same staging file.
+var (
+	activeUploadsMu sync.Mutex
+	activeUploads   = map[string]bool{}
+)
+
+// fileRecord is the metadata stored alongside each file.
+type fileRecord struct {
+	Filename    string            `json:"filename"`
+	ContentType string            `json:"content_type"`
+	Size        int64             `json:"size"`
+	SHA256      string            `json:"sha256"`
+	UserID      string            `json:"user_id,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ModifiedAt  time.Time         `json:"modified_at"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// storedFile is a file ID together with its metadata record.
+type storedFile struct {
+	id  string
+	rec fileRecord
+}
+
+// FileServiceImpl implements the file gRPC service on top of a
+// storage.StorageManager, so the same API serves local, S3, Azure and GCS
+// backends. File IDs are storage keys. Metadata and checksums are kept in
+// JSON records under ".meta/" in the same backend, and partially received
+// uploads are staged on local disk until they complete. Authentication is
+// left to the server's interceptors; files are owned by the authenticated
+// caller that uploaded them and hidden from other users.
+type FileServiceImpl struct {
+	storage    *storage.StorageManager
+	stagingDir string
+}
+
+// NewFileService creates a file service using the storage backend from the
+// application configuration. When no storage provider is configured the
+// service answers every file operation with FailedPrecondition.
+func NewFileService() *FileServiceImpl {
+	if viper.GetString("storage.provider") == "" {
+		return &FileServiceImpl{}
+	}
+	sm, err := storage.NewStorageManager(storage.GetConfigFromViper())
+	if err != nil {
+		logging.GetLogger("file-service").Warnf("storage unavailable: %v", err)
+		return &FileServiceImpl{}
+	}
+	return NewFileServiceWithStorage(sm, "")
+}
+
+// NewFileServiceWithStorage creates a file service backed by sm. Partial
+// uploads are staged in stagingDir, or in a directory under os.TempDir when
+// stagingDir is empty.
+func NewFileServiceWithStorage(sm *storage.StorageManager, stagingDir string) *FileServiceImpl {
+	if stagingDir == "" {
+		stagingDir = filepath.Join(os.TempDir(), "subtitle-manager-uploads")
+	}
+	return &FileServiceImpl{storage: sm, stagingDir: stagingDir}
+}
+
+// backend returns the storage manager or FailedPrecondition when none is configured.
+func (f *FileServiceImpl) backend() (*storage.StorageManager, error) {
+	if f.storage == nil {
+		return nil, status.Error(codes.FailedPrecondition, "file storage not configured")
+	}
+	return f.storage, nil
+}
+
+// storageError converts a storage error into a gRPC status error.
+func storageError(id string, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Errorf(codes.NotFound, "file %q not found", id)
+	case errors.Is(err, storage.ErrInvalidKey):
+		return status.Errorf(codes.InvalidArgument, "invalid file id %q", id)
+	default:
+		return status.Errorf(codes.Internal, "storage: %v", err)
+	}
+}
+
+// fileKey validates a client supplied file ID or name and returns the
+// storage key. Internal prefixes are not addressable.
+func fileKey(id string) (string, error) {
+	if id == "" {
+		return "", status.Error(codes.InvalidArgument, "file id required")
+	}
+	key, err := security.SanitizeRelativePath(strings.TrimPrefix(filepath.ToSlash(id), "./"))
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "invalid file id %q: %v", id, err)
+	}
+	if isInternalKey(key) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid file id %q", id)
+	}
+	return key, nil
+}
+
+// isInternalKey reports whether key lies in a directory reserved by the service.
+func isInternalKey(key string) bool {
+	return strings.HasPrefix(key, fileMetaPrefix) || strings.HasPrefix(key, fileTrashPrefix)
+}
+
+func metaKey(id string) string {
+	return fileMetaPrefix + id + ".json"
+}
+
+// loadRecord reads the metadata record of id. Files stored without the
+// service get a record derived from the key alone.
+func (f *FileServiceImpl) loadRecord(ctx context.Context, sm *storage.StorageManager, id string) (fileRecord, error) {
+	rc, err := sm.Retrieve(ctx, metaKey(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		ok, err := sm.Exists(ctx, id)
+		if err != nil {
+			return fileRecord{}, storageError(id, err)
+		}
+		if !ok {
+			return fileRecord{}, status.Errorf(codes.NotFound, "file %q not found", id)
+		}
+		return fileRecord{Filename: path.Base(id), ContentType: contentTypeFor(id)}, nil
+	}
+	if err != nil {
+		return fileRecord{}, storageError(id, err)
+	}
+	defer rc.Close()
+	var rec fileRecord
+	if err := json.NewDecoder(rc).Decode(&rec); err != nil {
+		return fileRecord{}, status.Errorf(codes.Internal, "decode metadata of %q: %v", id, err)
+	}
+	return rec, nil
+}
+
+// saveRecord writes the metadata record of id.
+func (f *FileServiceImpl) saveRecord(ctx context.Context, sm *storage.StorageManager, id string, rec fileRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encode metadata: %v", err)
+	}
+	if err := sm.Store(ctx, metaKey(id), bytes.NewReader(data), "application/json"); err != nil {
+		return storageError(id, err)
+	}
+	return nil
+}
+
+// removeFile deletes id and its metadata record.
+func (f *FileServiceImpl) removeFile(ctx context.Context, sm *storage.StorageManager, id string) error {
+	if err := sm.Delete(ctx, id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return storageError(id, err)
+	}
+	if err := sm.Delete(ctx, metaKey(id)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return storageError(id, err)
+	}
+	return nil
+}
+
+// copyObject copies the content of from to to and stores rec as the
+// metadata of to.
+func (f *FileServiceImpl) copyObject(ctx context.Context, sm *storage.StorageManager, from, to string, rec fileRecord) error {
+	rc, err := sm.Retrieve(ctx, from)
+	if err != nil {
+		return storageError(from, err)
+	}
+	defer rc.Close()
+	if err := sm.Store(ctx, to, rc, rec.ContentType); err != nil {
+		return storageError(to, err)
+	}
+	return f.saveRecord(ctx, sm, to, rec)
+}
+
+// listFiles returns every user visible file under prefix with its record.
+func (f *FileServiceImpl) listFiles(ctx context.Context, sm *storage.StorageManager, prefix string) ([]storedFile, error) {
+	keys, err := sm.List(ctx, prefix)
+	if err != nil {
+		return nil, storageError(prefix, err)
+	}
+	files := make([]storedFile, 0, len(keys))
+	for _, key := range keys {
+		if isInternalKey(key) {
+			continue
+		}
+		rec, err := f.loadRecord(ctx, sm, key)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, storedFile{id: key, rec: rec})
+	}
+	return files, nil
+}
+
+// contentTypeFor guesses the content type of name from its extension.
+func contentTypeFor(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".srt":
+		return "application/x-subrip"
+	case ".vtt":
+		return "text/vtt"
+	case ".ass", ".ssa":
+		return "text/x-ssa"
+	}
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// matchesType reports whether id matches one of types, which may be
+// extensions with or without a dot or content types. An empty list matches
+// everything.
+func matchesType(id string, rec fileRecord, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	ext := strings.TrimPrefix(strings.ToLower(path.Ext(id)), ".")
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if strings.TrimPrefix(t, ".") == ext || t == strings.ToLower(rec.ContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleTo reports whether a file owned by owner is visible to user. Files
+// without an owner, stored before uploads recorded one, are shared.
+func visibleTo(owner, user string) bool {
+	return owner == "" || owner == user
+}
+
+// fileUser returns the ID of the authenticated caller. A user_id given in
+// the request must name the caller.
+func fileUser(ctx context.Context, claimed string) (string, error) {
+	c, err := caller(ctx)
+	if err != nil {
+		return "", err
+	}
+	user := strconv.FormatInt(c.UserID, 10)
+	if claimed != "" && claimed != user {
+		return "", status.Errorf(codes.PermissionDenied, "user_id %q does not match the caller", claimed)
+	}
+	return user, nil
+}
+
+// loadOwned reads the record of id, reporting files hidden from user as not
+// found.
+func (f *FileServiceImpl) loadOwned(ctx context.Context, sm *storage.StorageManager, id, user string) (fileRecord, error) {
+	rec, err := f.loadRecord(ctx, sm, id)
+	if err != nil {
+		return fileRecord{}, err
+	}
+	if !visibleTo(rec.UserID, user) {
+		return fileRecord{}, status.Errorf(codes.NotFound, "file %q not found", id)
+	}
+	return rec, nil
+}
+
+// checkWritable refuses to overwrite id when it belongs to another user.
+func (f *FileServiceImpl) checkWritable(ctx context.Context, sm *storage.StorageManager, id, user string) error {
+	rec, err := f.loadRecord(ctx, sm, id)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !visibleTo(rec.UserID, user) {
+		return status.Errorf(codes.PermissionDenied, "file %q belongs to another user", id)
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func copyMetadata(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func toFileEntry(sf storedFile, withMetadata bool) *filev1.FileEntry {
+	e := &filev1.FileEntry{
+		FileId:      proto.String(sf.id),
+		Filename:    proto.String(sf.rec.Filename),
+		ContentType: proto.String(sf.rec.ContentType),
+		Size:        proto.Int64(sf.rec.Size),
+		CreatedAt:   proto.String(formatTime(sf.rec.CreatedAt)),
+		ModifiedAt:  proto.String(formatTime(sf.rec.ModifiedAt)),
+	}
+	if withMetadata {
+		e.Metadata = copyMetadata(sf.rec.Metadata)
+	}
+	return e
+}
+
+// stagingKey scopes upload id to user, so users cannot resume or inspect
+// each other's uploads.
+func stagingKey(user, id string) string {
+	return user + "_" + id
+}
+
+// stagingPath returns the local staging file of staging key key.
+func (f *FileServiceImpl) stagingPath(key string) string {
+	return filepath.Join(f.stagingDir, key+".part")
+}
+
+// claimUpload marks staging key key as active and returns a release
+// function.
+func claimUpload(key, id string) (func(), error) {
+	activeUploadsMu.Lock()
+	defer activeUploadsMu.Unlock()
+	if activeUploads[key] {
+		return nil, status.Errorf(codes.Aborted, "upload %q already in progress", id)
+	}
+	activeUploads[key] = true
+	return func() {
+		activeUploadsMu.Lock()
+		delete(activeUploads, key)
+		activeUploadsMu.Unlock()
+	}, nil
+}
+
+// UploadFile receives a file as a metadata message followed by chunks. Each
+// chunk must start at or before the number of bytes already staged, which
+// lets a client resume an interrupted upload by reusing its upload_id and
+// sending from the offset reported in the OutOfRange error or by
+// GetFileInfo. When the last chunk arrives the size and SHA-256 checksum are
+// verified before the file is written to storage. The file is owned by the
+// caller, and upload IDs are scoped to the caller.
+func (f *FileServiceImpl) UploadFile(stream filev1.FileService_UploadFileServer) error {
+	sm, err := f.backend()
+	if err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "receive metadata: %v", err)
+	}
+	meta := first.GetMetadata()
+	if meta == nil {
+		return status.Error(codes.InvalidArgument, "first message must carry file metadata")
+	}
+	user, err := fileUser(ctx, meta.GetUserId())
+	if err != nil {
+		return err
+	}
+	id, err := fileKey(meta.GetFilename())
+	if err != nil {
+		return err
+	}
+	if err := f.checkWritable(ctx, sm, id, user); err != nil {
+		return err
+	}
+	custom := copyMetadata(meta.GetCustomMetadata())
+	uploadID := custom[FileMetaUploadID]
+	expectSum := strings.ToLower(custom[FileMetaSHA256])
+	delete(custom, FileMetaUploadID)
+	delete(custom, FileMetaSHA256)
+	resumable := uploadID != ""
+	if !resumable {
+		uploadID = fmt.Sprintf("tmp-%d", time.Now().UnixNano())
+	} else if !uploadIDPattern.MatchString(uploadID) {
+		return status.Errorf(codes.InvalidArgument, "invalid upload id %q", uploadID)
+	}
+
+	key := stagingKey(user, uploadID)
+	release, err := claimUpload(key, uploadID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := os.MkdirAll(f.stagingDir, 0o755); err != nil {
+		return status.Errorf(codes.Internal, "create staging directory: %v", err)
+	}
+	stage := f.stagingPath(key)
+	tmp, err := os.OpenFile(stage, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return status.Errorf(codes.Internal, "open staging file: %v", err)
+	}
+	done := false
+	defer func() {
+		tmp.Close()
+		if done || !resumable {
+			os.Remove(stage)
+		}
+	}()
+	info, err := tmp.Stat()
+	if err != nil {
+		return status.Errorf(codes.Internal, "stat staging file: %v", err)
+	}
+	staged := info.Size()
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return status.Errorf(codes.Aborted, "upload %q incomplete at offset %d", uploadID, staged)
+		}
+		if err != nil {
+			return err
+		}
+		chunk := msg.GetChunk()
+		if chunk == nil {
+			return status.Error(codes.InvalidArgument, "expected file chunk")
+		}
+		off := chunk.GetOffset()
+		if off < 0 || off > staged {
+			return status.Errorf(codes.OutOfRange, "chunk offset %d beyond staged size; expected offset %d", off, staged)
+		}
+		if _, err := tmp.WriteAt(chunk.GetData(), off); err != nil {
+			return status.Errorf(codes.Internal, "write staging file: %v", err)
+		}
+		staged = off + int64(len(chunk.GetData()))
+		if err := tmp.Truncate(staged); err != nil {
+			return status.Errorf(codes.Internal, "truncate staging file: %v", err)
+		}
+		if chunk.GetIsLast() {
+			break
+		}
+	}
+
+	// The upload is complete; discard the staged data whatever happens next.
+	done = true
+	if total := meta.GetTotalSize(); total > 0 && total != staged {
+		return status.Errorf(codes.DataLoss, "received %d bytes, expected %d", staged, total)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(tmp, 0, staged)); err != nil {
+		return status.Errorf(codes.Internal, "checksum staging file: %v", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if expectSum != "" && expectSum != sum {
+		return status.Errorf(codes.DataLoss, "checksum mismatch: got %s, expected %s", sum, expectSum)
+	}
+
+	contentType := meta.GetContentType()
+	if contentType == "" {
+		contentType = contentTypeFor(id)
+	}
+	now := time.Now().UTC()
+	rec := fileRecord{
+		Filename:    path.Base(id),
+		ContentType: contentType,
+		Size:        staged,
+		SHA256:      sum,
+		UserID:      user,
+		CreatedAt:   now,
+		ModifiedAt:  now,
+		Metadata:    custom,
+	}
+	if old, err := f.loadRecord(ctx, sm, id); err == nil && !old.CreatedAt.IsZero() {
+		rec.CreatedAt = old.CreatedAt
+	}
+	if err := sm.Store(ctx, id, io.NewSectionReader(tmp, 0, staged), contentType); err != nil {
+		return storageError(id, err)
+	}
+	if err := f.saveRecord(ctx, sm, id, rec); err != nil {
+		return err
+	}
+	return stream.SendAndClose(&filev1.UploadFileResponse{
+		FileId:   proto.String(id),
+		Filename: proto.String(rec.Filename),
+		Size:     proto.Int64(staged),
+		Success:  proto.Bool(true),
+	})
+}
+
+// DownloadFile streams a file info message followed by chunks covering the
+// requested range. The info metadata carries the SHA-256 of the whole file.
+func (f *FileServiceImpl) DownloadFile(req *filev1.DownloadFileRequest, stream filev1.FileService_DownloadFileServer) error {
+	sm, err := f.backend()
+	if err != nil {
+		return err
+	}
+	id, err := fileKey(req.GetFileId())
+	if err != nil {
+		return err
+	}
+	off, length := req.GetOffset(), req.GetLength()
+	if off < 0 || length < 0 {
+		return status.Error(codes.InvalidArgument, "offset and length must not be negative")
+	}
+	ctx := stream.Context()
+	user, err := fileUser(ctx, "")
+	if err != nil {
+		return err
+	}
+	rec, err := f.loadOwned(ctx, sm, id, user)
+	if err != nil {
+		return err
+	}
+	rc, err := sm.Retrieve(ctx, id)
+	if err != nil {
+		return storageError(id, err)
+	}
+	defer rc.Close()
+
+	info := copyMetadata(rec.Metadata)
+	if rec.SHA256 != "" {
+		info[FileMetaSHA256] = rec.SHA256
+	}
+	if err := stream.Send(&filev1.DownloadFileResponse{Response: &filev1.DownloadFileResponse_FileInfo{FileInfo: &filev1.FileInfo{
+		Filename:    proto.String(rec.Filename),
+		ContentType: proto.String(rec.ContentType),
+		TotalSize:   proto.Int64(rec.Size),
+		Metadata:    info,
+	}}}); err != nil {
+		return err
+	}
+
+	if off > 0 {
+		if s, ok := rc.(io.Seeker); ok {
+			_, err = s.Seek(off, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, rc, off)
+		}
+		if err != nil && err != io.EOF {
+			return status.Errorf(codes.Internal, "seek %q: %v", id, err)
+		}
+	}
+	var r io.Reader = rc
+	if length > 0 {
+		r = io.LimitReader(rc, length)
+	}
+
+	buf := make([]byte, fileChunkSize)
+	next := make([]byte, fileChunkSize)
+	n, err := io.ReadFull(r, buf)
+	for {
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return status.Errorf(codes.Internal, "read %q: %v", id, err)
+		}
+		last := err != nil
+		var m int
+		var nextErr error
+		if !last {
+			// Read ahead so the final chunk can be flagged.
+			m, nextErr = io.ReadFull(r, next)
+			last = m == 0 && nextErr == io.EOF
+		}
+		if err := stream.Send(&filev1.DownloadFileResponse{Response: &filev1.DownloadFileResponse_Chunk{Chunk: &filev1.FileChunk{
+			Data:   append([]byte(nil), buf[:n]...),
+			Offset: proto.Int64(off),
+			IsLast: proto.Bool(last),
+		}}}); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		off += int64(n)
+		buf, next = next, buf
+		n, err = m, nextErr
+	}
+}
+
+// DeleteFile moves a file to the trash, or removes it when permanent is set.
+// Trashed files are purged by CleanupFiles with remove_temporary.
+func (f *FileServiceImpl) DeleteFile(ctx context.Context, req *filev1.DeleteFileRequest) (*filev1.DeleteFileResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.DeleteFileResponse{}, err
+	}
+	user, err := fileUser(ctx, "")
+	if err != nil {
+		return &filev1.DeleteFileResponse{}, err
+	}
+	id, err := fileKey(req.GetFileId())
+	if err != nil {
+		return &filev1.DeleteFileResponse{}, err
+	}
+	rec, err := f.loadOwned(ctx, sm, id, user)
+	if err != nil {
+		return &filev1.DeleteFileResponse{}, err
+	}
+	if !req.GetPermanent() {
+		rec.ModifiedAt = time.Now().UTC()
+		if err := f.copyObject(ctx, sm, id, fileTrashPrefix+id, rec); err != nil {
+			return &filev1.DeleteFileResponse{}, err
+		}
+	}
+	if err := f.removeFile(ctx, sm, id); err != nil {
+		return &filev1.DeleteFileResponse{}, err
+	}
+	return &filev1.DeleteFileResponse{
+		FileId:  proto.String(id),
+		Deleted: proto.Bool(true),
+		Success: proto.Bool(true),
+	}, nil
+}
+
+// GetFileInfo returns the metadata of a stored file. For an ID matching an
+// unfinished resumable upload it reports the number of staged bytes, which
+// is the offset to resume from.
+func (f *FileServiceImpl) GetFileInfo(ctx context.Context, req *filev1.GetFileInfoRequest) (*filev1.GetFileInfoResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.GetFileInfoResponse{}, err
+	}
+	user, err := fileUser(ctx, "")
+	if err != nil {
+		return &filev1.GetFileInfoResponse{}, err
+	}
+	if uploadIDPattern.MatchString(req.GetFileId()) {
+		if info, err := os.Stat(f.stagingPath(stagingKey(user, req.GetFileId()))); err == nil {
+			return &filev1.GetFileInfoResponse{
+				FileId:     proto.String(req.GetFileId()),
+				Size:       proto.Int64(info.Size()),
+				ModifiedAt: proto.String(formatTime(info.ModTime())),
+				Metadata:   map[string]string{FileMetaUploadID: req.GetFileId()},
+				Success:    proto.Bool(true),
+			}, nil
+		}
+	}
+	id, err := fileKey(req.GetFileId())
+	if err != nil {
+		return &filev1.GetFileInfoResponse{}, err
+	}
+	rec, err := f.loadOwned(ctx, sm, id, user)
+	if err != nil {
+		return &filev1.GetFileInfoResponse{}, err
+	}
+	resp := &filev1.GetFileInfoResponse{
+		FileId:      proto.String(id),
+		Filename:    proto.String(rec.Filename),
+		ContentType: proto.String(rec.ContentType),
+		Size:        proto.Int64(rec.Size),
+		CreatedAt:   proto.String(formatTime(rec.CreatedAt)),
+		ModifiedAt:  proto.String(formatTime(rec.ModifiedAt)),
+		UserId:      proto.String(rec.UserID),
+		Success:     proto.Bool(true),
+	}
+	if req.GetIncludeMetadata() {
+		resp.Metadata = copyMetadata(rec.Metadata)
+		if rec.SHA256 != "" {
+			resp.Metadata[FileMetaSHA256] = rec.SHA256
+		}
+	}
+	return resp, nil
+}
+
+// CopyFile copies a file and its metadata to destination_name. The copy is
+// owned by the caller.
+func (f *FileServiceImpl) CopyFile(ctx context.Context, req *filev1.CopyFileRequest) (*filev1.CopyFileResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.CopyFileResponse{}, err
+	}
+	user, err := fileUser(ctx, req.GetUserId())
+	if err != nil {
+		return &filev1.CopyFileResponse{}, err
+	}
+	src, err := fileKey(req.GetSourceFileId())
+	if err != nil {
+		return &filev1.CopyFileResponse{}, err
+	}
+	dst, err := fileKey(req.GetDestinationName())
+	if err != nil {
+		return &filev1.CopyFileResponse{}, err
+	}
+	if src == dst {
+		return &filev1.CopyFileResponse{}, status.Error(codes.InvalidArgument, "destination equals source")
+	}
+	rec, err := f.loadOwned(ctx, sm, src, user)
+	if err != nil {
+		return &filev1.CopyFileResponse{}, err
+	}
+	if err := f.checkWritable(ctx, sm, dst, user); err != nil {
+		return &filev1.CopyFileResponse{}, err
+	}
+	now := time.Now().UTC()
+	rec.Filename = path.Base(dst)
+	rec.CreatedAt, rec.ModifiedAt = now, now
+	rec.Metadata = copyMetadata(rec.Metadata)
+	rec.UserID = user
+	if err := f.copyObject(ctx, sm, src, dst, rec); err != nil {
+		return &filev1.CopyFileResponse{}, err
+	}
+	return &filev1.CopyFileResponse{
+		NewFileId: proto.String(dst),
+		Filename:  proto.String(rec.Filename),
+		Success:   proto.Bool(true),
+	}, nil
+}
+
+// MoveFile renames a file and/or moves it to new_location. Empty fields keep
+// the current name or directory.
+func (f *FileServiceImpl) MoveFile(ctx context.Context, req *filev1.MoveFileRequest) (*filev1.MoveFileResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.MoveFileResponse{}, err
+	}
+	user, err := fileUser(ctx, "")
+	if err != nil {
+		return &filev1.MoveFileResponse{}, err
+	}
+	src, err := fileKey(req.GetFileId())
+	if err != nil {
+		return &filev1.MoveFileResponse{}, err
+	}
+	name, dir := path.Base(src), path.Dir(src)
+	if req.GetNewName() != "" {
+		name = req.GetNewName()
+	}
+	if req.NewLocation != nil {
+		dir = req.GetNewLocation()
+	}
+	dst, err := fileKey(path.Join(dir, name))
+	if err != nil {
+		return &filev1.MoveFileResponse{}, err
+	}
+	rec, err := f.loadOwned(ctx, sm, src, user)
+	if err != nil {
+		return &filev1.MoveFileResponse{}, err
+	}
+	if dst != src {
+		if err := f.checkWritable(ctx, sm, dst, user); err != nil {
+			return &filev1.MoveFileResponse{}, err
+		}
+		rec.Filename = path.Base(dst)
+		rec.ModifiedAt = time.Now().UTC()
+		if err := f.copyObject(ctx, sm, src, dst, rec); err != nil {
+			return &filev1.MoveFileResponse{}, err
+		}
+		if err := f.removeFile(ctx, sm, src); err != nil {
+			return &filev1.MoveFileResponse{}, err
+		}
+	}
+	return &filev1.MoveFileResponse{
+		FileId:      proto.String(dst),
+		NewFilename: proto.String(rec.Filename),
+		Success:     proto.Bool(true),
+	}, nil
+}
+
+// sortFiles orders files by name, size, created_at or modified_at.
+func sortFiles(files []storedFile, by, order string) {
+	less := func(a, b storedFile) bool { return a.id < b.id }
+	switch by {
+	case "size":
+		less = func(a, b storedFile) bool { return a.rec.Size < b.rec.Size }
+	case "created_at", "created":
+		less = func(a, b storedFile) bool { return a.rec.CreatedAt.Before(b.rec.CreatedAt) }
+	case "modified_at", "modified":
+		less = func(a, b storedFile) bool { return a.rec.ModifiedAt.Before(b.rec.ModifiedAt) }
+	}
+	desc := strings.EqualFold(order, "desc")
+	sort.SliceStable(files, func(i, j int) bool {
+		if desc {
+			return less(files[j], files[i])
+		}
+		return less(files[i], files[j])
+	})
+}
+
+// paginate returns the page of files selected by offset and limit and
+// whether more files follow. A limit of zero returns every remaining file.
+func paginate(files []storedFile, offset, limit int32) ([]storedFile, bool) {
+	if offset < 0 {
+		offset = 0
+	}
+	if int(offset) >= len(files) {
+		return nil, false
+	}
+	files = files[offset:]
+	if limit <= 0 || int(limit) >= len(files) {
+		return files, false
+	}
+	return files[:limit], true
+}
+
+// ListFiles lists files under path visible to the caller.
+func (f *FileServiceImpl) ListFiles(ctx context.Context, req *filev1.ListFilesRequest) (*filev1.ListFilesResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.ListFilesResponse{}, err
+	}
+	user, err := fileUser(ctx, req.GetUserId())
+	if err != nil {
+		return &filev1.ListFilesResponse{}, err
+	}
+	prefix := ""
+	if req.GetPath() != "" {
+		if prefix, err = fileKey(req.GetPath()); err != nil {
+			return &filev1.ListFilesResponse{}, err
+		}
+	}
+	all, err := f.listFiles(ctx, sm, prefix)
+	if err != nil {
+		return &filev1.ListFilesResponse{}, err
+	}
+	opts := req.GetOptions()
+	var files []storedFile
+	for _, sf := range all {
+		if visibleTo(sf.rec.UserID, user) && matchesType(sf.id, sf.rec, opts.GetFileTypes()) {
+			files = append(files, sf)
+		}
+	}
+	sortFiles(files, opts.GetSortBy(), opts.GetSortOrder())
+	page, more := paginate(files, req.GetOffset(), req.GetLimit())
+	resp := &filev1.ListFilesResponse{
+		TotalCount: proto.Int32(int32(len(files))),
+		HasMore:    proto.Bool(more),
+		Success:    proto.Bool(true),
+	}
+	for _, sf := range page {
+		resp.Files = append(resp.Files, toFileEntry(sf, opts.GetIncludeMetadata()))
+	}
+	return resp, nil
+}
+
+// UpdateFileMetadata merges metadata into the file's custom metadata, or
+// replaces it when replace_all is set. Empty values delete keys.
+func (f *FileServiceImpl) UpdateFileMetadata(ctx context.Context, req *filev1.UpdateFileMetadataRequest) (*filev1.UpdateFileMetadataResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.UpdateFileMetadataResponse{}, err
+	}
+	user, err := fileUser(ctx, "")
+	if err != nil {
+		return &filev1.UpdateFileMetadataResponse{}, err
+	}
+	id, err := fileKey(req.GetFileId())
+	if err != nil {
+		return &filev1.UpdateFileMetadataResponse{}, err
+	}
+	rec, err := f.loadOwned(ctx, sm, id, user)
+	if err != nil {
+		return &filev1.UpdateFileMetadataResponse{}, err
+	}
+	if req.GetReplaceAll() || rec.Metadata == nil {
+		rec.Metadata = map[string]string{}
+	}
+	for k, v := range req.GetMetadata() {
+		if k == FileMetaSHA256 || k == FileMetaUploadID {
+			continue
+		}
+		if v == "" {
+			delete(rec.Metadata, k)
+		} else {
+			rec.Metadata[k] = v
+		}
+	}
+	rec.ModifiedAt = time.Now().UTC()
+	if err := f.saveRecord(ctx, sm, id, rec); err != nil {
+		return &filev1.UpdateFileMetadataResponse{}, err
+	}
+	return &filev1.UpdateFileMetadataResponse{
+		FileId:          proto.String(id),
+		UpdatedMetadata: copyMetadata(rec.Metadata),
+		Success:         proto.Bool(true),
+	}, nil
+}
+
+// parseDate accepts RFC 3339 timestamps or plain dates.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// SearchFiles finds files whose ID or filename contains query
+// (case-insensitive), that are visible to the caller and that match the
+// search options.
+func (f *FileServiceImpl) SearchFiles(ctx context.Context, req *filev1.SearchFilesRequest) (*filev1.SearchFilesResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.SearchFilesResponse{}, err
+	}
+	user, err := fileUser(ctx, req.GetUserId())
+	if err != nil {
+		return &filev1.SearchFilesResponse{}, err
+	}
+	opts := req.GetOptions()
+	var from, to time.Time
+	if s := opts.GetDateFrom(); s != "" {
+		if from, err = parseDate(s); err != nil {
+			return &filev1.SearchFilesResponse{}, status.Errorf(codes.InvalidArgument, "invalid date_from: %v", err)
+		}
+	}
+	if s := opts.GetDateTo(); s != "" {
+		if to, err = parseDate(s); err != nil {
+			return &filev1.SearchFilesResponse{}, status.Errorf(codes.InvalidArgument, "invalid date_to: %v", err)
+		}
+	}
+	all, err := f.listFiles(ctx, sm, "")
+	if err != nil {
+		return &filev1.SearchFilesResponse{}, err
+	}
+	query := strings.ToLower(req.GetQuery())
+	var files []storedFile
+	for _, sf := range all {
+		rec := sf.rec
+		switch {
+		case !visibleTo(rec.UserID, user),
+			query != "" && !strings.Contains(strings.ToLower(sf.id), query),
+			!matchesType(sf.id, rec, opts.GetFileTypes()),
+			!from.IsZero() && rec.CreatedAt.Before(from),
+			!to.IsZero() && rec.CreatedAt.After(to),
+			opts.GetMinSize() > 0 && rec.Size < opts.GetMinSize(),
+			opts.GetMaxSize() > 0 && rec.Size > opts.GetMaxSize():
+			continue
+		}
+		matched := true
+		for k, v := range opts.GetMetadataFilters() {
+			if rec.Metadata[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			files = append(files, sf)
+		}
+	}
+	sortFiles(files, "", "")
+	page, _ := paginate(files, opts.GetOffset(), opts.GetLimit())
+	resp := &filev1.SearchFilesResponse{
+		TotalCount: proto.Int32(int32(len(files))),
+		Success:    proto.Bool(true),
+	}
+	for _, sf := range page {
+		resp.Files = append(resp.Files, toFileEntry(sf, true))
+	}
+	return resp, nil
+}
+
+// GetStorageInfo reports the space used by files visible to the caller and
+// their distribution by extension. Backends do not expose capacity, so
+// total and available space are left at zero.
+func (f *FileServiceImpl) GetStorageInfo(ctx context.Context, req *filev1.GetStorageInfoRequest) (*filev1.GetStorageInfoResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.GetStorageInfoResponse{}, err
+	}
+	user, err := fileUser(ctx, req.GetUserId())
+	if err != nil {
+		return &filev1.GetStorageInfoResponse{}, err
+	}
+	all, err := f.listFiles(ctx, sm, "")
+	if err != nil {
+		return &filev1.GetStorageInfoResponse{}, err
+	}
+	var used int64
+	var count int32
+	dist := map[string]int64{}
+	for _, sf := range all {
+		if !visibleTo(sf.rec.UserID, user) {
+			continue
+		}
+		used += sf.rec.Size
+		count++
+		ext := strings.TrimPrefix(strings.ToLower(path.Ext(sf.id)), ".")
+		if ext == "" {
+			ext = "other"
+		}
+		dist[ext]++
+	}
+	return &filev1.GetStorageInfoResponse{
+		UsedSpace:            proto.Int64(used),
+		FileCount:            proto.Int32(count),
+		FileTypeDistribution: dist,
+		Success:              proto.Bool(true),
+	}, nil
+}
+
+// CleanupFiles removes files older than days_old, trashed files and stale
+// upload staging data (remove_temporary), and metadata records whose file
+// no longer exists (remove_orphaned). It is an administrative operation
+// covering every user's files; user_id limits days_old to one owner.
+func (f *FileServiceImpl) CleanupFiles(ctx context.Context, req *filev1.CleanupFilesRequest) (*filev1.CleanupFilesResponse, error) {
+	sm, err := f.backend()
+	if err != nil {
+		return &filev1.CleanupFilesResponse{}, err
+	}
+	if _, err := caller(ctx); err != nil {
+		return &filev1.CleanupFilesResponse{}, err
+	}
+	opts := req.GetOptions()
+	var deleted int32
+	var freed int64
+
+	if days := opts.GetDaysOld(); days > 0 {
+		cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+		all, err := f.listFiles(ctx, sm, "")
+		if err != nil {
+			return &filev1.CleanupFilesResponse{}, err
+		}
+		for _, sf := range all {
+			if sf.rec.ModifiedAt.IsZero() || !sf.rec.ModifiedAt.Before(cutoff) ||
+				(req.GetUserId() != "" && sf.rec.UserID != req.GetUserId()) || !matchesType(sf.id, sf.rec, opts.GetFileTypes()) {
+				continue
+			}
+			if err := f.removeFile(ctx, sm, sf.id); err != nil {
+				return &filev1.CleanupFilesResponse{}, err
+			}
+			deleted++
+			freed += sf.rec.Size
+		}
+	}
+
+	if opts.GetRemoveTemporary() {
+		keys, err := sm.List(ctx, fileTrashPrefix)
+		if err != nil {
+			return &filev1.CleanupFilesResponse{}, storageError(fileTrashPrefix, err)
+		}
+		for _, key := range keys {
+			rec, _ := f.loadRecord(ctx, sm, key)
+			if err := f.removeFile(ctx, sm, key); err != nil {
+				return &filev1.CleanupFilesResponse{}, err
+			}
+			deleted++
+			freed += rec.Size
+		}
+		entries, _ := os.ReadDir(f.stagingDir)
+		for _, e := range entries {
+			key := strings.TrimSuffix(e.Name(), ".part")
+			activeUploadsMu.Lock()
+			active := activeUploads[key]
+			activeUploadsMu.Unlock()
+			info, err := e.Info()
+			if active || err != nil || !strings.HasSuffix(e.Name(), ".part") {
+				continue
+			}
+			if os.Remove(filepath.Join(f.stagingDir, e.Name())) == nil {
+				deleted++
+				freed += info.Size()
+			}
+		}
+	}
+
+	if opts.GetRemoveOrphaned() {
+		keys, err := sm.List(ctx, fileMetaPrefix)
+		if err != nil {
+			return &filev1.CleanupFilesResponse{}, storageError(fileMetaPrefix, err)
+		}
+		for _, key := range keys {
+			id := strings.TrimSuffix(strings.TrimPrefix(key, fileMetaPrefix), ".json")
+			ok, err := sm.Exists(ctx, id)
+			if err != nil || ok {
+				continue
+			}
+			if err := sm.Delete(ctx, key); err != nil {
+				return &filev1.CleanupFilesResponse{}, storageError(key, err)
+			}
+			deleted++
+		}
+	}
+
+	return &filev1.CleanupFilesResponse{
+		FilesDeleted: proto.Int32(deleted),
+		SpaceFreed:   proto.Int64(freed),
+		Success:      proto.Bool(true),
+	}, nil
+}
+
+// HealthCheck reports whether the storage backend is reachable.
+func (f *FileServiceImpl) HealthCheck(ctx context.Context, req *filev1.HealthCheckRequest) (*filev1.HealthCheckResponse, error) {
+	if f.storage == nil {
+		return &filev1.HealthCheckResponse{
+			Status:        proto.String("unconfigured"),
+			Message:       proto.String("file storage not configured"),
+			StorageHealth: &filev1.StorageHealth{Healthy: proto.Bool(false)},
+			Success:       proto.Bool(true),
+		}, nil
+	}
+	if _, err := f.storage.Exists(ctx, metaKey("health")); err != nil {
+		return &filev1.HealthCheckResponse{
+			Status:        proto.String("degraded"),
+			Message:       proto.String(err.Error()),
+			StorageHealth: &filev1.StorageHealth{Healthy: proto.Bool(false)},
+			Success:       proto.Bool(true),
+		}, nil
+	}
+	return &filev1.HealthCheckResponse{
+		Status:        proto.String("ok"),
+		StorageHealth: &filev1.StorageHealth{Healthy: proto.Bool(true)},
+		Success:       proto.Bool(true),
+	}, nil
+}
@@ -0,0 +1,368 @@
+// file: pkg/services/file_service_test.go
+// version: 1.1.0
+// guid: 6a1d9e43-2b7f-4c85-a0e6-91f3c8d57b24
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	filev1 "github.com/jdfalk/subtitle-manager/pkg/file/v1"
+	"github.com/jdfalk/subtitle-manager/pkg/storage"
+)
+
+// Test users, named by their user IDs.
+const (
+	alice = "1"
+	bob   = "2"
+)
+
+// testUserKey carries the caller's user ID in test requests.
+const testUserKey = "x-test-user"
+
+// asUser returns a context authenticating calls as user.
+func asUser(user string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), testUserKey, user)
+}
+
+// testCallerOptions stand in for the auth interceptor, authenticating calls
+// as the user named in the testUserKey metadata.
+func testCallerOptions() []grpc.ServerOption {
+	withCaller := func(ctx context.Context) context.Context {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get(testUserKey); len(v) > 0 {
+			if id, err := strconv.ParseInt(v[0], 10, 64); err == nil {
+				return ContextWithCaller(ctx, Caller{UserID: id})
+			}
+		}
+		return ctx
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			return h(withCaller(ctx), req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			return h(srv, &callerStream{ServerStream: ss, ctx: withCaller(ss.Context())})
+		}),
+	}
+}
+
+// startFileServer serves a file service backed by local storage in a
+// temporary directory and returns the client and staging directory.
+func startFileServer(t *testing.T) (filev1.FileServiceClient, string) {
+	t.Helper()
+	sm, err := storage.NewStorageManager(storage.StorageConfig{Provider: "local", LocalPath: t.TempDir()})
+	require.NoError(t, err)
+	staging := t.TempDir()
+
+	lis := bufconn.Listen(bufSize)
+	s := grpc.NewServer(testCallerOptions()...)
+	filev1.RegisterFileServiceServer(s, NewFileServiceWithStorage(sm, staging))
+	go func() { _ = s.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+		lis.Close()
+		sm.Close()
+	})
+	return filev1.NewFileServiceClient(conn), staging
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func metadataMsg(name, user string, size int64, custom map[string]string) *filev1.UploadFileRequest {
+	return &filev1.UploadFileRequest{Request: &filev1.UploadFileRequest_Metadata{Metadata: &filev1.FileMetadata{
+		Filename:       proto.String(name),
+		TotalSize:      proto.Int64(size),
+		UserId:         proto.String(user),
+		CustomMetadata: custom,
+	}}}
+}
+
+func chunkMsg(data []byte, off int64, last bool) *filev1.UploadFileRequest {
+	return &filev1.UploadFileRequest{Request: &filev1.UploadFileRequest_Chunk{Chunk: &filev1.FileChunk{
+		Data:   data,
+		Offset: proto.Int64(off),
+		IsLast: proto.Bool(last),
+	}}}
+}
+
+// upload sends data in chunks of size n and returns the response.
+func upload(t *testing.T, c filev1.FileServiceClient, name, user string, data []byte, n int) (*filev1.UploadFileResponse, error) {
+	t.Helper()
+	stream, err := c.UploadFile(asUser(user))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(metadataMsg(name, user, int64(len(data)), map[string]string{FileMetaSHA256: checksum(data)})))
+	for off := 0; ; off += n {
+		end := min(off+n, len(data))
+		require.NoError(t, stream.Send(chunkMsg(data[off:end], int64(off), end == len(data))))
+		if end == len(data) {
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// download returns the file info and content received for req.
+func download(t *testing.T, c filev1.FileServiceClient, ctx context.Context, req *filev1.DownloadFileRequest) (*filev1.FileInfo, []byte, int) {
+	t.Helper()
+	stream, err := c.DownloadFile(ctx, req)
+	require.NoError(t, err)
+	first, err := stream.Recv()
+	require.NoError(t, err)
+	info := first.GetFileInfo()
+	require.NotNil(t, info)
+	var buf bytes.Buffer
+	chunks := 0
+	for {
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		chunk := msg.GetChunk()
+		require.Equal(t, req.GetOffset()+int64(buf.Len()), chunk.GetOffset())
+		buf.Write(chunk.GetData())
+		chunks++
+		if chunk.GetIsLast() {
+			break
+		}
+	}
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+	return info, buf.Bytes(), chunks
+}
+
+func TestFileService_UploadDownload(t *testing.T) {
+	c, _ := startFileServer(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000) // 160000 bytes, three download chunks
+
+	resp, err := upload(t, c, "movies/film.en.srt", alice, data, 50000)
+	require.NoError(t, err)
+	require.Equal(t, "movies/film.en.srt", resp.GetFileId())
+	require.Equal(t, int64(len(data)), resp.GetSize())
+
+	info, got, chunks := download(t, c, asUser(alice), &filev1.DownloadFileRequest{FileId: proto.String("movies/film.en.srt")})
+	require.Equal(t, data, got)
+	require.Equal(t, 3, chunks)
+	require.Equal(t, checksum(data), info.GetMetadata()[FileMetaSHA256])
+	require.Equal(t, "application/x-subrip", info.GetContentType())
+
+	_, got, _ = download(t, c, asUser(alice), &filev1.DownloadFileRequest{
+		FileId: proto.String("movies/film.en.srt"),
+		Offset: proto.Int64(100),
+		Length: proto.Int64(20),
+	})
+	require.Equal(t, data[100:120], got)
+
+	fi, err := c.GetFileInfo(asUser(alice), &filev1.GetFileInfoRequest{FileId: proto.String("movies/film.en.srt"), IncludeMetadata: proto.Bool(true)})
+	require.NoError(t, err)
+	require.Equal(t, alice, fi.GetUserId())
+	require.Equal(t, int64(len(data)), fi.GetSize())
+	require.Equal(t, checksum(data), fi.GetMetadata()[FileMetaSHA256])
+}
+
+func TestFileService_UploadChecksumMismatch(t *testing.T) {
+	c, staging := startFileServer(t)
+	stream, err := c.UploadFile(asUser(alice))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(metadataMsg("bad.srt", "", 0, map[string]string{FileMetaSHA256: checksum([]byte("other"))})))
+	require.NoError(t, stream.Send(chunkMsg([]byte("data"), 0, true)))
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.DataLoss, status.Code(err))
+
+	_, err = c.GetFileInfo(asUser(alice), &filev1.GetFileInfoRequest{FileId: proto.String("bad.srt")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	entries, _ := os.ReadDir(staging)
+	require.Empty(t, entries)
+}
+
+func TestFileService_ResumableUpload(t *testing.T) {
+	c, _ := startFileServer(t)
+	ctx := asUser(alice)
+	data := []byte("1\n00:00:01,000 --> 00:00:02,000\nresumed upload\n")
+	custom := map[string]string{FileMetaUploadID: "job-42", FileMetaSHA256: checksum(data), "lang": "en"}
+
+	// The first stream ends before the last chunk.
+	stream, err := c.UploadFile(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(metadataMsg("resume.srt", "", int64(len(data)), custom)))
+	require.NoError(t, stream.Send(chunkMsg(data[:20], 0, false)))
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	fi, err := c.GetFileInfo(ctx, &filev1.GetFileInfoRequest{FileId: proto.String("job-42")})
+	require.NoError(t, err)
+	require.Equal(t, int64(20), fi.GetSize())
+
+	// Upload IDs are scoped to the user.
+	_, err = c.GetFileInfo(asUser(bob), &filev1.GetFileInfoRequest{FileId: proto.String("job-42")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// Skipping ahead of the staged data is rejected.
+	stream, err = c.UploadFile(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(metadataMsg("resume.srt", "", int64(len(data)), custom)))
+	require.NoError(t, stream.Send(chunkMsg(data[30:], 30, true)))
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "expected offset 20")
+
+	stream, err = c.UploadFile(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(metadataMsg("resume.srt", "", int64(len(data)), custom)))
+	require.NoError(t, stream.Send(chunkMsg(data[20:], 20, true)))
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), resp.GetSize())
+
+	_, got, _ := download(t, c, ctx, &filev1.DownloadFileRequest{FileId: proto.String("resume.srt")})
+	require.Equal(t, data, got)
+	fi, err = c.GetFileInfo(ctx, &filev1.GetFileInfoRequest{FileId: proto.String("resume.srt"), IncludeMetadata: proto.Bool(true)})
+	require.NoError(t, err)
+	require.Equal(t, "en", fi.GetMetadata()["lang"])
+	require.NotContains(t, fi.GetMetadata(), FileMetaUploadID)
+}
+
+func TestFileService_ManageFiles(t *testing.T) {
+	c, _ := startFileServer(t)
+	ctx := asUser(alice)
+	for _, f := range []struct{ name, user, body string }{
+		{"tv/show.s01e01.en.srt", alice, "episode one"},
+		{"tv/show.s01e02.en.vtt", alice, "episode two, longer"},
+		{"movies/film.de.srt", bob, "film"},
+	} {
+		_, err := upload(t, c, f.name, f.user, []byte(f.body), 4)
+		require.NoError(t, err)
+	}
+
+	list, err := c.ListFiles(ctx, &filev1.ListFilesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), list.GetTotalCount())
+
+	list, err = c.ListFiles(ctx, &filev1.ListFilesRequest{
+		Limit:   proto.Int32(1),
+		Options: &filev1.ListOptions{SortBy: proto.String("size"), SortOrder: proto.String("desc")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), list.GetTotalCount())
+	require.True(t, list.GetHasMore())
+	require.Equal(t, "tv/show.s01e02.en.vtt", list.GetFiles()[0].GetFileId())
+
+	list, err = c.ListFiles(ctx, &filev1.ListFilesRequest{Options: &filev1.ListOptions{FileTypes: []string{"srt"}}})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), list.GetTotalCount())
+
+	// Other users' files are hidden and cannot be overwritten.
+	_, err = c.GetFileInfo(ctx, &filev1.GetFileInfoRequest{FileId: proto.String("movies/film.de.srt")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = c.DeleteFile(ctx, &filev1.DeleteFileRequest{FileId: proto.String("movies/film.de.srt")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = c.ListFiles(ctx, &filev1.ListFilesRequest{UserId: proto.String(bob)})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = upload(t, c, "movies/film.de.srt", alice, []byte("overwrite"), 4)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = c.ListFiles(context.Background(), &filev1.ListFilesRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = asUser(bob)
+	meta, err := c.UpdateFileMetadata(ctx, &filev1.UpdateFileMetadataRequest{
+		FileId:   proto.String("movies/film.de.srt"),
+		Metadata: map[string]string{"language": "de"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "de", meta.GetUpdatedMetadata()["language"])
+
+	found, err := c.SearchFiles(ctx, &filev1.SearchFilesRequest{
+		Query:   proto.String("FILM"),
+		Options: &filev1.SearchOptions{MetadataFilters: map[string]string{"language": "de"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, found.GetFiles(), 1)
+	require.Equal(t, "movies/film.de.srt", found.GetFiles()[0].GetFileId())
+
+	cp, err := c.CopyFile(ctx, &filev1.CopyFileRequest{SourceFileId: proto.String("movies/film.de.srt"), DestinationName: proto.String("movies/copy.de.srt")})
+	require.NoError(t, err)
+	require.Equal(t, "movies/copy.de.srt", cp.GetNewFileId())
+	_, err = c.CopyFile(ctx, &filev1.CopyFileRequest{SourceFileId: proto.String("movies/film.de.srt"), DestinationName: proto.String("tv/show.s01e01.en.srt")})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	mv, err := c.MoveFile(ctx, &filev1.MoveFileRequest{FileId: proto.String("movies/copy.de.srt"), NewLocation: proto.String("archive")})
+	require.NoError(t, err)
+	require.Equal(t, "archive/copy.de.srt", mv.GetFileId())
+	_, got, _ := download(t, c, ctx, &filev1.DownloadFileRequest{FileId: proto.String("archive/copy.de.srt")})
+	require.Equal(t, "film", string(got))
+	_, err = c.GetFileInfo(ctx, &filev1.GetFileInfoRequest{FileId: proto.String("movies/copy.de.srt")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	info, err := c.GetStorageInfo(ctx, &filev1.GetStorageInfoRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), info.GetFileCount())
+	require.Equal(t, int64(2), info.GetFileTypeDistribution()["srt"])
+	require.Equal(t, int64(2*len("film")), info.GetUsedSpace())
+
+	stream, err := c.DownloadFile(ctx, &filev1.DownloadFileRequest{FileId: proto.String("../escape.srt")})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestFileService_DeleteAndCleanup(t *testing.T) {
+	c, staging := startFileServer(t)
+	ctx := asUser(alice)
+	for _, name := range []string{"a.srt", "b.srt"} {
+		_, err := upload(t, c, name, alice, []byte("subtitle "+name), 8)
+		require.NoError(t, err)
+	}
+
+	del, err := c.DeleteFile(ctx, &filev1.DeleteFileRequest{FileId: proto.String("a.srt")})
+	require.NoError(t, err)
+	require.True(t, del.GetDeleted())
+	_, err = c.DeleteFile(ctx, &filev1.DeleteFileRequest{FileId: proto.String("b.srt"), Permanent: proto.Bool(true)})
+	require.NoError(t, err)
+
+	list, err := c.ListFiles(ctx, &filev1.ListFilesRequest{})
+	require.NoError(t, err)
+	require.Zero(t, list.GetTotalCount())
+
+	require.NoError(t, os.WriteFile(filepath.Join(staging, "stale.part"), []byte("xyz"), 0600))
+	res, err := c.CleanupFiles(ctx, &filev1.CleanupFilesRequest{Options: &filev1.CleanupOptions{RemoveTemporary: proto.Bool(true)}})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), res.GetFilesDeleted())
+	require.Equal(t, int64(len("subtitle a.srt")+3), res.GetSpaceFreed())
+	_, err = os.Stat(filepath.Join(staging, "stale.part"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestFileService_Unconfigured(t *testing.T) {
+	svc := &FileServiceImpl{}
+	_, err := svc.ListFiles(context.Background(), &filev1.ListFilesRequest{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	health, err := svc.HealthCheck(context.Background(), &filev1.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.False(t, health.GetStorageHealth().GetHealthy())
+}
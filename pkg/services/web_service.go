@@ -0,0 +1,815 @@
+// file: pkg/services/web_service.go
+// version: 1.4.0
+// guid: 5b2e8c41-7f3a-4d96-b0e5-1c8a9d2f6e73
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astisub"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/security"
+	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
+	webv1 "github.com/jdfalk/subtitle-manager/pkg/web/v1"
+)
+
+// Metadata keys understood by UploadSubtitle.
+const (
+	// SubtitleMetaLanguage is the language code of an uploaded subtitle.
+	SubtitleMetaLanguage = "language"
+	// SubtitleMetaVideo is the media file an uploaded subtitle belongs to.
+	SubtitleMetaVideo = "video"
+)
+
+const (
+	// maxSubtitleSize limits uploaded subtitles, matching the multipart
+	// limit of the HTTP translate endpoint.
+	maxSubtitleSize = 32 << 20
+	// sessionDuration and rememberSessionDuration are the lifetimes of
+	// sessions created by AuthenticateUser.
+	sessionDuration         = 24 * time.Hour
+	rememberSessionDuration = 30 * 24 * time.Hour
+)
+
+// subtitleExtensions lists the file types accepted by UploadSubtitle.
+var subtitleExtensions = map[string]bool{
+	".srt": true, ".vtt": true, ".ass": true, ".ssa": true,
+	".sub": true, ".ttml": true, ".stl": true,
+}
+
+// WebConfig configures the web service. Zero values fall back to the
+// application configuration.
+type WebConfig struct {
+	// DB holds users, sessions and the subtitle history. It is the same
+	// database the HTTP API authenticates against.
+	DB *sql.DB
+	// Queue runs translation jobs. Defaults to queue.GetQueue().
+	Queue *queue.Queue
+	// UploadDir receives uploaded subtitles. Defaults to subtitle_directory,
+	// or a directory under os.TempDir when that is unset.
+	UploadDir string
+}
+
+// webTranslationPayload is the queue payload of a translation submitted
+// through the web service. The gRPC translator address and API keys are
+// read from the configuration when the job runs.
+type webTranslationPayload struct {
+	Input    string `json:"input"`
+	Output   string `json:"output"`
+	Language string `json:"language"`
+	Service  string `json:"service"`
+	Video    string `json:"video,omitempty"`
+}
+
+// translationDB records finished translations in the subtitle history. Like
+// the queue handler it is shared by all web service instances and set by the
+// most recently created one with a database.
+var (
+	translationDBMu sync.RWMutex
+	translationDB   *sql.DB
+)
+
+func init() {
+	queue.Register(queue.JobTypeWebTranslation, func(p webTranslationPayload) string {
+		return fmt.Sprintf("Translate %s to %s (%s)", p.Input, p.Language, p.Output)
+	}, runWebTranslation)
+}
+
+// WebServiceImpl implements the web gRPC service on the same database and
+// translation queue as the HTTP API. File IDs are absolute paths of
+// subtitles recorded in the subtitle or download history; other paths are
+// not addressable. Callers are identified by AuthInterceptor.
+type WebServiceImpl struct {
+	config WebConfig
+}
+
+// NewWebService creates a web service without a database. Only HealthCheck
+// and LogoutUser succeed; other calls fail with FailedPrecondition.
+func NewWebService() *WebServiceImpl {
+	return NewWebServiceWithConfig(WebConfig{})
+}
+
+// NewWebServiceWithConfig creates a web service using cfg.
+func NewWebServiceWithConfig(cfg WebConfig) *WebServiceImpl {
+	if cfg.DB != nil {
+		translationDBMu.Lock()
+		translationDB = cfg.DB
+		translationDBMu.Unlock()
+	}
+	return &WebServiceImpl{config: cfg}
+}
+
+// db returns the database or FailedPrecondition when none is configured.
+func (w *WebServiceImpl) db() (*sql.DB, error) {
+	if w.config.DB == nil {
+		return nil, status.Error(codes.FailedPrecondition, "database not configured")
+	}
+	return w.config.DB, nil
+}
+
+func (w *WebServiceImpl) queue() *queue.Queue {
+	if w.config.Queue != nil {
+		return w.config.Queue
+	}
+	return queue.GetQueue()
+}
+
+func (w *WebServiceImpl) uploadDir() string {
+	if w.config.UploadDir != "" {
+		return w.config.UploadDir
+	}
+	if dir := viper.GetString("subtitle_directory"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "subtitle-manager-subtitles")
+}
+
+// caller returns the authenticated caller or Unauthenticated.
+func caller(ctx context.Context) (Caller, error) {
+	c, ok := CallerFromContext(ctx)
+	if !ok {
+		return Caller{}, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	return c, nil
+}
+
+// Authentication operations
+
+// AuthenticateUser checks the username and password and creates a session.
+// Failed logins are reported in the response rather than as errors so
+// clients can show the message.
+func (w *WebServiceImpl) AuthenticateUser(ctx context.Context, req *webv1.AuthenticateUserRequest) (*webv1.AuthenticateUserResponse, error) {
+	resp := &webv1.AuthenticateUserResponse{}
+	db, err := w.db()
+	if err != nil {
+		return resp, err
+	}
+	if req.GetUsername() == "" || req.GetPassword() == "" {
+		return resp, status.Error(codes.InvalidArgument, "username and password required")
+	}
+	id, err := auth.AuthenticateUser(db, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		resp.SetSuccess(false)
+		resp.SetErrorCode("invalid_credentials")
+		resp.SetErrorMessage("invalid username or password")
+		return resp, nil
+	}
+	duration := sessionDuration
+	if req.GetRememberMe() {
+		duration = rememberSessionDuration
+	}
+	session, err := auth.GenerateSession(db, id, duration)
+	if err != nil {
+		return resp, status.Errorf(codes.Internal, "create session: %v", err)
+	}
+	resp.SetSessionId(session.GetId())
+	resp.SetUserId(strconv.FormatInt(id, 10))
+	resp.SetSuccess(true)
+	return resp, nil
+}
+
+// LogoutUser invalidates the given session, or the caller's session when
+// none is given. With logout_all every session of the caller is removed.
+func (w *WebServiceImpl) LogoutUser(ctx context.Context, req *webv1.LogoutUserRequest) (*webv1.LogoutUserResponse, error) {
+	resp := &webv1.LogoutUserResponse{}
+	c, _ := CallerFromContext(ctx)
+	token := req.GetSessionId()
+	if token == "" {
+		token = c.Session
+	}
+	if w.config.DB != nil {
+		if req.GetLogoutAllSessions() && c.UserID != 0 {
+			if err := auth.InvalidateUserSessions(w.config.DB, c.UserID); err != nil {
+				return resp, status.Errorf(codes.Internal, "invalidate sessions: %v", err)
+			}
+		} else if token != "" {
+			if err := w.invalidateSession(c, token); err != nil {
+				return resp, err
+			}
+		}
+	}
+	resp.SetSuccess(true)
+	return resp, nil
+}
+
+// invalidateSession removes token when it belongs to c. Unknown tokens are
+// ignored like in the HTTP logout handler.
+func (w *WebServiceImpl) invalidateSession(c Caller, token string) error {
+	if token != c.Session {
+		s, err := auth.ValidateSession(w.config.DB, token)
+		if err != nil {
+			return nil
+		}
+		if s.GetUserId() != strconv.FormatInt(c.UserID, 10) {
+			return status.Error(codes.PermissionDenied, "session belongs to another user")
+		}
+	}
+	if err := auth.InvalidateSession(w.config.DB, token); err != nil {
+		return status.Errorf(codes.Internal, "invalidate session: %v", err)
+	}
+	return nil
+}
+
+// User management
+
+// GetUser returns the caller, or the given user for administrators.
+func (w *WebServiceImpl) GetUser(ctx context.Context, req *webv1.GetUserRequest) (*webv1.GetUserResponse, error) {
+	resp := &webv1.GetUserResponse{}
+	db, err := w.db()
+	if err != nil {
+		return resp, err
+	}
+	c, err := caller(ctx)
+	if err != nil {
+		return resp, err
+	}
+	id := strconv.FormatInt(c.UserID, 10)
+	if req.GetUserId() != "" && req.GetUserId() != id {
+		if ok, err := auth.CheckPermission(db, c.UserID, "all"); err != nil || !ok {
+			return resp, status.Error(codes.PermissionDenied, "all permission required to read other users")
+		}
+		id = req.GetUserId()
+	}
+	users, err := auth.ListUsers(db)
+	if err != nil {
+		return resp, status.Errorf(codes.Internal, "list users: %v", err)
+	}
+	for _, u := range users {
+		if u.GetId() == id {
+			resp.SetUserId(u.GetId())
+			resp.SetUsername(u.GetUsername())
+			resp.SetEmail(u.GetEmail())
+			resp.SetSuccess(true)
+			return resp, nil
+		}
+	}
+	return resp, status.Errorf(codes.NotFound, "user %q not found", id)
+}
+
+func (w *WebServiceImpl) UpdateUser(ctx context.Context, req *webv1.UpdateUserRequest) (*webv1.UpdateUserResponse, error) {
+	return &webv1.UpdateUserResponse{}, status.Errorf(codes.Unimplemented, "UpdateUser not implemented")
+}
+
+func (w *WebServiceImpl) UpdateUserPreferences(ctx context.Context, req *webv1.UpdateUserPreferencesRequest) (*webv1.UpdateUserPreferencesResponse, error) {
+	return &webv1.UpdateUserPreferencesResponse{}, status.Errorf(codes.Unimplemented, "UpdateUserPreferences not implemented")
+}
+
+// File operations
+
+// UploadSubtitle stores a subtitle in the upload directory and records it
+// in the subtitle history. The optional "language" and "video" metadata
+// keys are stored with the record.
+func (w *WebServiceImpl) UploadSubtitle(ctx context.Context, req *webv1.UploadSubtitleRequest) (*webv1.UploadSubtitleResponse, error) {
+	resp := &webv1.UploadSubtitleResponse{}
+	if len(req.GetContent()) > maxSubtitleSize {
+		return resp, status.Errorf(codes.InvalidArgument, "subtitle exceeds %d bytes", maxSubtitleSize)
+	}
+	path, err := w.storeSubtitle(req.GetFilename(), bytes.NewReader(req.GetContent()), req.GetMetadata())
+	if err != nil {
+		return resp, err
+	}
+	resp.SetFileId(path)
+	resp.SetFilename(filepath.Base(path))
+	resp.SetSize(int64(len(req.GetContent())))
+	resp.SetSuccess(true)
+	return resp, nil
+}
+
+// storeSubtitle writes r to a new directory below the upload directory and
+// records the file in the subtitle history.
+func (w *WebServiceImpl) storeSubtitle(filename string, r io.Reader, meta map[string]string) (string, error) {
+	db, err := w.db()
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(filepath.Clean("/" + filepath.ToSlash(filename)))
+	if name == "/" || name == "." || !subtitleExtensions[strings.ToLower(filepath.Ext(name))] {
+		return "", status.Errorf(codes.InvalidArgument, "invalid subtitle filename %q", filename)
+	}
+	lang := meta[SubtitleMetaLanguage]
+	if lang != "" {
+		if err := security.ValidateLanguageCode(lang); err != nil {
+			return "", status.Errorf(codes.InvalidArgument, "invalid language: %v", err)
+		}
+	}
+	video := meta[SubtitleMetaVideo]
+	if video != "" {
+		if video, err = security.ValidateAndSanitizePath(video); err != nil {
+			return "", status.Errorf(codes.InvalidArgument, "invalid video path: %v", err)
+		}
+	}
+
+	dir := filepath.Join(w.uploadDir(), uuid.NewString())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", status.Errorf(codes.Internal, "create upload directory: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "create %s: %v", path, err)
+	}
+	n, err := io.Copy(f, io.LimitReader(r, maxSubtitleSize+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > maxSubtitleSize {
+		err = status.Errorf(codes.InvalidArgument, "subtitle exceeds %d bytes", maxSubtitleSize)
+	}
+	if err == nil {
+		if _, perr := astisub.OpenFile(path); perr != nil {
+			err = status.Errorf(codes.InvalidArgument, "parse subtitle: %v", perr)
+		}
+	}
+	if err == nil {
+		if derr := database.InsertSubtitle(db, path, video, lang, "upload", "", false); derr != nil {
+			err = status.Errorf(codes.Internal, "record subtitle: %v", derr)
+		}
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		if _, ok := status.FromError(err); ok {
+			return "", err
+		}
+		return "", status.Errorf(codes.Internal, "write %s: %v", path, err)
+	}
+	return path, nil
+}
+
+// subtitlePath validates a file ID and checks that it is a subtitle known
+// to the history.
+func (w *WebServiceImpl) subtitlePath(id string) (string, error) {
+	db, err := w.db()
+	if err != nil {
+		return "", err
+	}
+	p, err := filePath(id)
+	if err != nil {
+		return "", err
+	}
+	entries, err := w.history(db)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.file == p {
+			return p, nil
+		}
+	}
+	return "", status.Errorf(codes.NotFound, "subtitle %q not found", id)
+}
+
+// DownloadSubtitle returns a subtitle, converted to format when one is given.
+func (w *WebServiceImpl) DownloadSubtitle(ctx context.Context, req *webv1.DownloadSubtitleRequest) (*webv1.DownloadSubtitleResponse, error) {
+	resp := &webv1.DownloadSubtitleResponse{}
+	p, err := w.subtitlePath(req.GetFileId())
+	if err != nil {
+		return resp, err
+	}
+	format := strings.ToLower(strings.TrimPrefix(req.GetFormat(), "."))
+	name := filepath.Base(p)
+	var data []byte
+	switch {
+	case format == "" || "."+format == strings.ToLower(filepath.Ext(p)):
+		data, err = os.ReadFile(p)
+		if err != nil {
+			return resp, status.Errorf(codes.NotFound, "read %s: %v", p, err)
+		}
+	default:
+		target, err := subtitles.ParseFormat(format)
+		if err == nil {
+			data, err = subtitles.Convert(p, target, subtitles.ConvertOptions{})
+		}
+		if err != nil {
+			return resp, status.Errorf(codes.InvalidArgument, "convert %s: %v", p, err)
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + target.Ext()
+	}
+	resp.SetFilename(name)
+	resp.SetContent(data)
+	resp.SetContentType(contentTypeFor(name))
+	resp.SetSuccess(true)
+	return resp, nil
+}
+
+// historyEntry is a subtitle file from the subtitle or download history.
+type historyEntry struct {
+	file     string
+	video    string
+	language string
+	created  time.Time
+}
+
+// history returns the subtitle and download history with one entry per
+// file, newest first.
+func (w *WebServiceImpl) history(db *sql.DB) ([]historyEntry, error) {
+	subs, err := database.ListSubtitles(db)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list subtitles: %v", err)
+	}
+	downloads, err := database.ListDownloads(db)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list downloads: %v", err)
+	}
+	var out []historyEntry
+	for _, s := range subs {
+		out = append(out, historyEntry{file: s.File, video: s.VideoFile, language: s.Language, created: s.CreatedAt})
+	}
+	for _, d := range downloads {
+		out = append(out, historyEntry{file: d.File, video: d.VideoFile, language: d.Language, created: d.CreatedAt})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].created.After(out[j].created) })
+	seen := make(map[string]bool, len(out))
+	uniq := out[:0]
+	for _, e := range out {
+		if e.file == "" || seen[e.file] {
+			continue
+		}
+		seen[e.file] = true
+		uniq = append(uniq, e)
+	}
+	return uniq, nil
+}
+
+// SearchSubtitles searches the subtitle and download history. The query
+// matches case-insensitively against the subtitle and video paths; results
+// are ordered newest first.
+func (w *WebServiceImpl) SearchSubtitles(ctx context.Context, req *webv1.SearchSubtitlesRequest) (*webv1.SearchSubtitlesResponse, error) {
+	resp := &webv1.SearchSubtitlesResponse{}
+	db, err := w.db()
+	if err != nil {
+		return resp, err
+	}
+	if req.GetLimit() < 0 || req.GetOffset() < 0 {
+		return resp, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	entries, err := w.history(db)
+	if err != nil {
+		return resp, err
+	}
+	query := strings.ToLower(req.GetQuery())
+	var matches []historyEntry
+	for _, e := range entries {
+		if req.GetLanguage() != "" && !strings.EqualFold(e.language, req.GetLanguage()) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(e.file), query) && !strings.Contains(strings.ToLower(e.video), query) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	total := len(matches)
+	start := min(int(req.GetOffset()), total)
+	end := total
+	if req.GetLimit() > 0 {
+		end = min(start+int(req.GetLimit()), total)
+	}
+	ids := make([]string, 0, end-start)
+	names := make([]string, 0, end-start)
+	for _, e := range matches[start:end] {
+		ids = append(ids, e.file)
+		names = append(names, filepath.Base(e.file))
+	}
+	resp.SetFileIds(ids)
+	resp.SetFilenames(names)
+	resp.SetTotalCount(int32(total))
+	resp.SetSuccess(true)
+	return resp, nil
+}
+
+// Translation operations
+
+// runWebTranslation translates p.Input to p.Output and records the result
+// in the subtitle history. Both paths must be in the allowed media
+// directories.
+func runWebTranslation(ctx context.Context, p webTranslationPayload) error {
+	in, err := security.ValidateAndSanitizePath(p.Input)
+	if err != nil {
+		return err
+	}
+	out, err := security.ValidateAndSanitizePath(p.Output)
+	if err != nil {
+		return err
+	}
+	if err := subtitles.TranslateFile(in, out, p.Language, p.Service,
+		viper.GetString("google_api_key"), viper.GetString("openai_api_key"), viper.GetString("grpc_addr")); err != nil {
+		return err
+	}
+	translationDBMu.RLock()
+	db := translationDB
+	translationDBMu.RUnlock()
+	if db == nil {
+		return nil
+	}
+	if err := database.InsertSubtitle(db, out, p.Video, p.Language, p.Service, "", false); err != nil {
+		logging.GetLogger("web-service").Warnf("record translation %s: %v", out, err)
+	}
+	return nil
+}
+
+// TranslateSubtitle queues a translation of a known subtitle into the
+// target language. The result is written next to the source as SRT. The
+// "service" option overrides the configured translation service; the gRPC
+// translator address always comes from the server configuration.
+func (w *WebServiceImpl) TranslateSubtitle(ctx context.Context, req *webv1.TranslateSubtitleRequest) (*webv1.TranslateSubtitleResponse, error) {
+	resp := &webv1.TranslateSubtitleResponse{}
+	in, err := w.subtitlePath(req.GetFileId())
+	if err != nil {
+		return resp, err
+	}
+	c, err := caller(ctx)
+	if err != nil {
+		return resp, err
+	}
+	lang := req.GetTargetLanguage()
+	if err := security.ValidateLanguageCode(lang); err != nil {
+		return resp, status.Errorf(codes.InvalidArgument, "invalid target language: %v", err)
+	}
+	opts := req.GetOptions()
+	service := opts["service"]
+	if service == "" {
+		service = viper.GetString("translate_service")
+		if service == "" {
+			service = "google"
+		}
+	}
+	out := siblingPath(in, "."+lang+".srt")
+	if out == in {
+		out = siblingPath(in, ".translated."+lang+".srt")
+	}
+
+	q := w.queue()
+	if !q.IsRunning() {
+		if err := q.Start(); err != nil && !q.IsRunning() {
+			return resp, status.Errorf(codes.Unavailable, "start translation queue: %v", err)
+		}
+	}
+	job, err := queue.NewJob(queue.JobTypeWebTranslation, webTranslationPayload{
+		Input:    in,
+		Output:   out,
+		Language: lang,
+		Service:  service,
+		Video:    w.videoFor(in),
+	})
+	if err != nil {
+		return resp, status.Errorf(codes.Internal, "create translation job: %v", err)
+	}
+	id, err := q.AddWithOptions(job, queue.JobOptions{MaxAttempts: 1, Owner: c.owner()})
+	if err != nil {
+		return resp, status.Errorf(codes.ResourceExhausted, "queue translation: %v", err)
+	}
+	resp.SetJobId(id)
+	resp.SetStatus("queued")
+	resp.SetResultFileId(out)
+	resp.SetSuccess(true)
+	return resp, nil
+}
+
+// videoFor returns the video recorded for the subtitle at path.
+func (w *WebServiceImpl) videoFor(path string) string {
+	entries, err := w.history(w.config.DB)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if e.file == path {
+			return e.video
+		}
+	}
+	return ""
+}
+
+// translation returns the queue record and payload of job id if it is a
+// translation queued by c.
+func (w *WebServiceImpl) translation(c Caller, id string) (*database.QueueJob, webTranslationPayload, error) {
+	notFound := status.Errorf(codes.NotFound, "translation job %q not found", id)
+	rec, err := w.queue().Job(id)
+	if err != nil {
+		return nil, webTranslationPayload{}, status.Errorf(codes.Internal, "load job: %v", err)
+	}
+	if rec == nil || rec.Type != string(queue.JobTypeWebTranslation) || w.queue().Owner(rec) != c.owner() {
+		return nil, webTranslationPayload{}, notFound
+	}
+	job, err := w.queue().Decode(rec)
+	if err != nil {
+		return nil, webTranslationPayload{}, status.Errorf(codes.Internal, "decode job: %v", err)
+	}
+	pj, ok := job.(*queue.PayloadJob)
+	if !ok {
+		return nil, webTranslationPayload{}, notFound
+	}
+	p, ok := pj.Payload.(webTranslationPayload)
+	if !ok {
+		return nil, webTranslationPayload{}, notFound
+	}
+	return rec, p, nil
+}
+
+// GetTranslationStatus reports the state of a translation job. Jobs waiting
+// for a queue worker are reported as "queued".
+func (w *WebServiceImpl) GetTranslationStatus(ctx context.Context, req *webv1.GetTranslationStatusRequest) (*webv1.GetTranslationStatusResponse, error) {
+	resp := &webv1.GetTranslationStatusResponse{}
+	c, err := caller(ctx)
+	if err != nil {
+		return resp, err
+	}
+	rec, p, err := w.translation(c, req.GetJobId())
+	if err != nil {
+		return resp, err
+	}
+	st, progress := jobState(rec)
+	resp.SetJobId(rec.ID)
+	resp.SetStatus(st)
+	resp.SetProgress(progress)
+	if st == "completed" {
+		resp.SetResultFileId(p.Output)
+	}
+	if rec.LastError != "" {
+		resp.SetErrorMessage(rec.LastError)
+	}
+	resp.SetSuccess(resp.GetStatus() != "failed")
+	return resp, nil
+}
+
+// CancelTranslation cancels a queued or running translation job.
+func (w *WebServiceImpl) CancelTranslation(ctx context.Context, req *webv1.CancelTranslationRequest) (*webv1.CancelTranslationResponse, error) {
+	resp := &webv1.CancelTranslationResponse{}
+	c, err := caller(ctx)
+	if err != nil {
+		return resp, err
+	}
+	id := req.GetJobId()
+	if _, _, err := w.translation(c, id); err != nil {
+		return resp, err
+	}
+	cancelled, err := w.queue().Cancel(id)
+	if err != nil {
+		return resp, status.Errorf(codes.Internal, "cancel job: %v", err)
+	}
+	resp.SetJobId(id)
+	resp.SetCancelled(cancelled)
+	resp.SetSuccess(true)
+	return resp, nil
+}
+
+// Streaming operations
+
+// UploadFile receives a subtitle as a metadata message followed by chunks
+// and stores it like UploadSubtitle.
+func (w *WebServiceImpl) UploadFile(stream webv1.WebService_UploadFileServer) error {
+	if _, err := w.db(); err != nil {
+		return err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "receive metadata: %v", err)
+	}
+	meta := first.GetMetadata()
+	if meta == nil {
+		return status.Error(codes.InvalidArgument, "first message must contain file metadata")
+	}
+	if meta.GetTotalSize() > maxSubtitleSize {
+		return status.Errorf(codes.InvalidArgument, "subtitle exceeds %d bytes", maxSubtitleSize)
+	}
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			msg, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if msg.GetMetadata() != nil {
+				pw.CloseWithError(status.Error(codes.InvalidArgument, "unexpected metadata message"))
+				return
+			}
+			if _, err := pw.Write(msg.GetChunk()); err != nil {
+				return
+			}
+		}
+	}()
+	counter := &countingReader{r: pr}
+	path, err := w.storeSubtitle(meta.GetFilename(), counter, nil)
+	// Closing the reader with the error stops the receiving goroutine at its
+	// next write.
+	pr.CloseWithError(err)
+	<-done
+	if err != nil {
+		return err
+	}
+	if total := meta.GetTotalSize(); total > 0 && counter.n != total {
+		w.removeUpload(path)
+		return status.Errorf(codes.DataLoss, "received %d of %d bytes", counter.n, total)
+	}
+	resp := &webv1.UploadFileResponse{}
+	resp.SetFileId(path)
+	resp.SetFilename(filepath.Base(path))
+	resp.SetSize(counter.n)
+	resp.SetSuccess(true)
+	return stream.SendAndClose(resp)
+}
+
+// removeUpload deletes an uploaded subtitle and its history record.
+func (w *WebServiceImpl) removeUpload(path string) {
+	_ = database.DeleteSubtitle(w.config.DB, path)
+	_ = os.RemoveAll(filepath.Dir(path))
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// DownloadFile streams a known subtitle as a metadata message followed by
+// chunks.
+func (w *WebServiceImpl) DownloadFile(req *webv1.DownloadFileRequest, stream webv1.WebService_DownloadFileServer) error {
+	p, err := w.subtitlePath(req.GetFileId())
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "open %s: %v", p, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return status.Errorf(codes.Internal, "stat %s: %v", p, err)
+	}
+	first := &webv1.DownloadFileResponse{}
+	first.SetMetadata(webv1.DownloadFileResponse_FileMetadata_builder{
+		Filename:    proto.String(filepath.Base(p)),
+		ContentType: proto.String(contentTypeFor(p)),
+		TotalSize:   proto.Int64(info.Size()),
+	}.Build())
+	if err := stream.Send(first); err != nil {
+		return err
+	}
+	buf := make([]byte, fileChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			msg := &webv1.DownloadFileResponse{}
+			msg.SetChunk(append([]byte(nil), buf[:n]...))
+			if serr := stream.Send(msg); serr != nil {
+				return serr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "read %s: %v", p, err)
+		}
+	}
+}
+
+// HealthCheck reports that the web service is serving and whether its
+// database is reachable.
+func (w *WebServiceImpl) HealthCheck(ctx context.Context, req *webv1.HealthCheckRequest) (*webv1.HealthCheckResponse, error) {
+	resp := &webv1.HealthCheckResponse{}
+	resp.SetService(webv1.WebService_ServiceDesc.ServiceName)
+	resp.SetTimestamp(time.Now().Unix())
+	resp.SetStatus("ok")
+	if w.config.DB == nil {
+		resp.SetMessage("database not configured")
+	} else if err := w.config.DB.PingContext(ctx); err != nil {
+		resp.SetStatus("degraded")
+		resp.SetMessage(fmt.Sprintf("database unavailable: %v", err))
+	}
+	return resp, nil
+}
@@ -1,17 +1,37 @@
 // file: pkg/services/web_service_test.go
-// version: 1.0.1
+// version: 1.3.1
 // guid: 0b8f0a9e-2c2a-4c3f-9f2e-8ef7d6a5b4c3
 
 package services
 
 import (
 	"context"
+	"database/sql"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	webv1 "github.com/jdfalk/subtitle-manager/pkg/web/v1"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/internal/queuetest"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/testutil"
+	"github.com/jdfalk/subtitle-manager/pkg/translator"
+	webv1 "github.com/jdfalk/subtitle-manager/pkg/web/v1"
 )
 
 func TestWebService_LogoutUser_Success(t *testing.T) {
@@ -29,55 +49,265 @@ func TestWebService_HealthCheck_Success(t *testing.T) {
 	require.NotNil(t, resp)
 }
 
-func TestWebService_UnimplementedMethods(t *testing.T) {
-	t.Parallel()
-	svc := NewWebService()
+// startAuthedWebServer serves a web service on db behind the auth
+// interceptor over bufconn. It creates an admin and a read-only user and
+// returns their API keys.
+func startAuthedWebServer(t *testing.T, db *sql.DB) (webv1.WebServiceClient, string, string) {
+	t.Helper()
+	require.NoError(t, auth.CreateUser(db, "admin", "secret", "admin@example.com", "admin"))
+	require.NoError(t, auth.CreateUser(db, "viewer", "secret", "viewer@example.com", "user"))
+	adminKey, err := auth.GenerateAPIKey(db, 1)
+	require.NoError(t, err)
+	viewerKey, err := auth.GenerateAPIKey(db, 2)
+	require.NoError(t, err)
+
+	q := queue.NewQueue(1)
+	t.Cleanup(func() {
+		if q.IsRunning() {
+			_ = q.Stop()
+		}
+	})
+	svc := NewWebServiceWithConfig(WebConfig{DB: db, Queue: q, UploadDir: t.TempDir()})
+
+	lis := bufconn.Listen(bufSize)
+	s := grpc.NewServer(NewAuthInterceptor(db).ServerOptions()...)
+	webv1.RegisterWebServiceServer(s, svc)
+	go func() { _ = s.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+		lis.Close()
+	})
+	return webv1.NewWebServiceClient(conn), adminKey.GetId(), viewerKey.GetId()
+}
+
+func withAPIKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), MetadataAPIKey, key)
+}
+
+func uploadSRT(t *testing.T, c webv1.WebServiceClient, ctx context.Context, name, lang string) string {
+	t.Helper()
+	req := &webv1.UploadSubtitleRequest{}
+	req.SetFilename(name)
+	req.SetContent([]byte(testSRT))
+	req.SetMetadata(map[string]string{SubtitleMetaLanguage: lang})
+	resp, err := c.UploadSubtitle(ctx, req)
+	require.NoError(t, err)
+	require.True(t, resp.GetSuccess())
+	return resp.GetFileId()
+}
+
+func TestWebService_Authentication(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	defer db.Close()
+	c, _, viewerKey := startAuthedWebServer(t, db)
+
+	_, err := c.GetUser(context.Background(), &webv1.GetUserRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	login := &webv1.AuthenticateUserRequest{}
+	login.SetUsername("admin")
+	login.SetPassword("wrong")
+	resp, err := c.AuthenticateUser(context.Background(), login)
+	require.NoError(t, err)
+	require.False(t, resp.GetSuccess())
+
+	login.SetPassword("secret")
+	resp, err = c.AuthenticateUser(context.Background(), login)
+	require.NoError(t, err)
+	require.True(t, resp.GetSuccess())
+	require.Equal(t, "1", resp.GetUserId())
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), MetadataAuthorization, "Bearer "+resp.GetSessionId())
+	user, err := c.GetUser(ctx, &webv1.GetUserRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "admin", user.GetUsername())
+
+	// Administrators may read other users, other roles only themselves.
+	other := &webv1.GetUserRequest{}
+	other.SetUserId("2")
+	user, err = c.GetUser(ctx, other)
+	require.NoError(t, err)
+	require.Equal(t, "viewer", user.GetUsername())
+	other.SetUserId("1")
+	_, err = c.GetUser(withAPIKey(viewerKey), other)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Uploads need basic permission, which the user role lacks.
+	_, err = c.UploadSubtitle(withAPIKey(viewerKey), &webv1.UploadSubtitleRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = c.LogoutUser(ctx, &webv1.LogoutUserRequest{})
+	require.NoError(t, err)
+	_, err = c.GetUser(ctx, &webv1.GetUserRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestWebService_UploadSearchDownload(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	defer db.Close()
+	c, adminKey, _ := startAuthedWebServer(t, db)
+	ctx := withAPIKey(adminKey)
 
-	tests := []struct {
-		name string
-		call func() error
-	}{
-		{"AuthenticateUser", func() error {
-			_, err := svc.AuthenticateUser(context.Background(), &webv1.AuthenticateUserRequest{})
-			return err
-		}},
-		{"GetUser", func() error { _, err := svc.GetUser(context.Background(), &webv1.GetUserRequest{}); return err }},
-		{"UpdateUser", func() error { _, err := svc.UpdateUser(context.Background(), &webv1.UpdateUserRequest{}); return err }},
-		{"UpdateUserPreferences", func() error {
-			_, err := svc.UpdateUserPreferences(context.Background(), &webv1.UpdateUserPreferencesRequest{})
-			return err
-		}},
-		{"UploadSubtitle", func() error {
-			_, err := svc.UploadSubtitle(context.Background(), &webv1.UploadSubtitleRequest{})
-			return err
-		}},
-		{"DownloadSubtitle", func() error {
-			_, err := svc.DownloadSubtitle(context.Background(), &webv1.DownloadSubtitleRequest{})
-			return err
-		}},
-		{"SearchSubtitles", func() error {
-			_, err := svc.SearchSubtitles(context.Background(), &webv1.SearchSubtitlesRequest{})
-			return err
-		}},
-		{"TranslateSubtitle", func() error {
-			_, err := svc.TranslateSubtitle(context.Background(), &webv1.TranslateSubtitleRequest{})
-			return err
-		}},
-		{"GetTranslationStatus", func() error {
-			_, err := svc.GetTranslationStatus(context.Background(), &webv1.GetTranslationStatusRequest{})
-			return err
-		}},
-		{"CancelTranslation", func() error {
-			_, err := svc.CancelTranslation(context.Background(), &webv1.CancelTranslationRequest{})
-			return err
-		}},
+	id := uploadSRT(t, c, ctx, "Movie.Name.2020.en.srt", "en")
+	uploadSRT(t, c, ctx, "Other.Show.S01E01.fr.srt", "fr")
+
+	bad := &webv1.UploadSubtitleRequest{}
+	bad.SetFilename("notes.txt")
+	bad.SetContent([]byte("x"))
+	_, err := c.UploadSubtitle(ctx, bad)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	search := &webv1.SearchSubtitlesRequest{}
+	search.SetQuery("movie.name")
+	found, err := c.SearchSubtitles(ctx, search)
+	require.NoError(t, err)
+	require.Equal(t, []string{id}, found.GetFileIds())
+	require.Equal(t, int32(1), found.GetTotalCount())
+
+	search.SetQuery("")
+	search.SetLanguage("fr")
+	found, err = c.SearchSubtitles(ctx, search)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Other.Show.S01E01.fr.srt"}, found.GetFilenames())
+
+	search.SetLanguage("")
+	search.SetLimit(1)
+	search.SetOffset(1)
+	found, err = c.SearchSubtitles(ctx, search)
+	require.NoError(t, err)
+	require.Len(t, found.GetFileIds(), 1)
+	require.Equal(t, int32(2), found.GetTotalCount())
+
+	dl := &webv1.DownloadSubtitleRequest{}
+	dl.SetFileId(id)
+	dl.SetFormat("vtt")
+	got, err := c.DownloadSubtitle(ctx, dl)
+	require.NoError(t, err)
+	require.Equal(t, "Movie.Name.2020.en.vtt", got.GetFilename())
+	require.True(t, strings.HasPrefix(string(got.GetContent()), "WEBVTT"))
+
+	// Files outside the history are not addressable.
+	stray := filepath.Join(t.TempDir(), "stray.srt")
+	require.NoError(t, os.WriteFile(stray, []byte(testSRT), 0644))
+	dl.SetFileId(stray)
+	dl.SetFormat("")
+	_, err = c.DownloadSubtitle(ctx, dl)
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestWebService_StreamingFiles(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	defer db.Close()
+	c, adminKey, _ := startAuthedWebServer(t, db)
+	ctx := withAPIKey(adminKey)
+
+	stream, err := c.UploadFile(ctx)
+	require.NoError(t, err)
+	first := &webv1.UploadFileRequest{}
+	first.SetMetadata(webv1.UploadFileRequest_FileMetadata_builder{
+		Filename:  proto.String("streamed.srt"),
+		TotalSize: proto.Int64(int64(len(testSRT))),
+	}.Build())
+	require.NoError(t, stream.Send(first))
+	for _, part := range []string{testSRT[:10], testSRT[10:]} {
+		msg := &webv1.UploadFileRequest{}
+		msg.SetChunk([]byte(part))
+		require.NoError(t, stream.Send(msg))
 	}
+	up, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(testSRT)), up.GetSize())
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			err := tc.call()
-			require.Error(t, err)
-			require.Equal(t, codes.Unimplemented, status.Code(err))
-		})
+	req := &webv1.DownloadFileRequest{}
+	req.SetFileId(up.GetFileId())
+	down, err := c.DownloadFile(ctx, req)
+	require.NoError(t, err)
+	head, err := down.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "streamed.srt", head.GetMetadata().GetFilename())
+	var data []byte
+	for {
+		msg, err := down.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data = append(data, msg.GetChunk()...)
 	}
+	require.Equal(t, testSRT, string(data))
+}
+
+func TestWebService_TranslateSubtitle(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	defer db.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"translations":[{"translatedText":"hola"},{"translatedText":"mundo"}]}}`))
+	}))
+	defer ts.Close()
+	translator.SetGoogleAPIURL(ts.URL)
+	defer translator.SetGoogleAPIURL("https://translation.googleapis.com/language/translate/v2")
+	viper.Set("google_api_key", "test-key")
+	defer viper.Reset()
+
+	c, adminKey, viewerKey := startAuthedWebServer(t, db)
+	ctx := withAPIKey(adminKey)
+	id := uploadSRT(t, c, ctx, "episode.en.srt", "en")
+
+	req := &webv1.TranslateSubtitleRequest{}
+	req.SetFileId(id)
+	req.SetTargetLanguage("es")
+	// Callers cannot point the job at another translator.
+	req.SetOptions(map[string]string{"service": "google", "grpc": "127.0.0.1:1"})
+	resp, err := c.TranslateSubtitle(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "queued", resp.GetStatus())
+
+	get := &webv1.GetTranslationStatusRequest{}
+	get.SetJobId(resp.GetJobId())
+	_, err = c.GetTranslationStatus(withAPIKey(viewerKey), get)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	st := queuetest.WaitWhile(t, func() (string, error) {
+		out, err := c.GetTranslationStatus(ctx, get)
+		return out.GetStatus(), err
+	}, "queued", "running")
+	require.Equal(t, "completed", st)
+	out, err := c.GetTranslationStatus(ctx, get)
+	require.NoError(t, err)
+	require.Equal(t, resp.GetResultFileId(), out.GetResultFileId())
+	data, err := os.ReadFile(out.GetResultFileId())
+	require.NoError(t, err)
+	require.Contains(t, string(data), "hola")
+
+	// The translation is recorded in the history and can be downloaded.
+	dl := &webv1.DownloadSubtitleRequest{}
+	dl.SetFileId(out.GetResultFileId())
+	_, err = c.DownloadSubtitle(ctx, dl)
+	require.NoError(t, err)
+
+	cancel := &webv1.CancelTranslationRequest{}
+	cancel.SetJobId(resp.GetJobId())
+	cr, err := c.CancelTranslation(ctx, cancel)
+	require.NoError(t, err)
+	require.False(t, cr.GetCancelled())
+	cancel.SetJobId("missing")
+	_, err = c.CancelTranslation(ctx, cancel)
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// TestRunWebTranslationRejectsDisallowedOutput verifies queued translations
+// cannot write outside the allowed media directories.
+func TestRunWebTranslationRejectsDisallowedOutput(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "movie.en.srt")
+	require.NoError(t, os.WriteFile(in, []byte(testSRT), 0644))
+	err := runWebTranslation(context.Background(), webTranslationPayload{Input: in, Output: "/etc/cron.d/movie.srt", Language: "es", Service: "google"})
+	require.ErrorContains(t, err, "not in allowed directories")
 }
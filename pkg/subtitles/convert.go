@@ -1,3 +1,7 @@
+// file: pkg/subtitles/convert.go
+// version: 1.1.0
+// guid: e226c0f7-77e6-4c32-adc7-9f620af46239
+
 // Package subtitles provides utilities for subtitle file processing, conversion, and manipulation.
 // It supports various subtitle formats and includes merging, extracting, and translation capabilities.
 //
@@ -7,19 +11,171 @@ package subtitles
 
 import (
 	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/asticode/go-astisub"
 )
 
+// Format identifies a subtitle file format by its canonical extension.
+type Format string
+
+// Supported subtitle formats.
+const (
+	FormatSRT      Format = "srt"
+	FormatASS      Format = "ass"
+	FormatSSA      Format = "ssa"
+	FormatWebVTT   Format = "vtt"
+	FormatTTML     Format = "ttml"
+	FormatSTL      Format = "stl"
+	FormatMicroDVD Format = "sub"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{FormatSRT, FormatASS, FormatSSA, FormatWebVTT, FormatTTML, FormatSTL, FormatMicroDVD}
+
+// ParseFormat returns the format named by s, which may be a format name
+// ("webvtt", "microdvd") or an extension with or without the leading dot.
+func ParseFormat(s string) (Format, error) {
+	switch f := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), ".")); f {
+	case "srt", "subrip":
+		return FormatSRT, nil
+	case "ass":
+		return FormatASS, nil
+	case "ssa":
+		return FormatSSA, nil
+	case "vtt", "webvtt":
+		return FormatWebVTT, nil
+	case "ttml", "dfxp", "xml":
+		return FormatTTML, nil
+	case "stl":
+		return FormatSTL, nil
+	case "sub", "microdvd":
+		return FormatMicroDVD, nil
+	}
+	return "", fmt.Errorf("unsupported subtitle format %q", s)
+}
+
+// Ext returns the file extension of the format including the dot.
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+// ContentType returns the MIME type used when serving the format over HTTP.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatWebVTT:
+		return "text/vtt"
+	case FormatASS, FormatSSA:
+		return "text/x-ssa"
+	case FormatTTML:
+		return "application/ttml+xml"
+	case FormatSTL:
+		return "application/octet-stream"
+	case FormatMicroDVD:
+		return "text/plain"
+	}
+	return "application/x-subrip"
+}
+
+func (f Format) isSSA() bool {
+	return f == FormatASS || f == FormatSSA
+}
+
+// ConvertOptions tunes Convert.
+type ConvertOptions struct {
+	// FPS is the frame rate used to read and write frame based formats such
+	// as MicroDVD. When zero it is probed from VideoPath, then taken from
+	// the frame rate header of the file, and finally DefaultFPS.
+	FPS float64
+	// VideoPath is the media file probed for its frame rate when FPS is zero.
+	VideoPath string
+	// PlainText disables mapping inline styling between ASS/SSA override
+	// tags and SRT/WebVTT markup. Styling is then dropped when converting
+	// between the two families.
+	PlainText bool
+}
+
+// Convert reads the subtitle file at path and returns it encoded as target.
+// The source format is taken from the file extension. A file that already
+// has the target format is returned unchanged so ASS scripts keep fonts,
+// comments and other sections go-astisub does not model.
+func Convert(path string, target Format, opts ConvertOptions) ([]byte, error) {
+	if _, err := ParseFormat(string(target)); err != nil {
+		return nil, err
+	}
+	source, err := ParseFormat(filepath.Ext(path))
+	if err == nil && source == target {
+		return os.ReadFile(path)
+	}
+	sub, err := Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(sub, target, opts)
+}
+
 // ConvertToSRT reads a subtitle file and converts it to SRT format.
 // It returns the resulting SRT bytes.
 func ConvertToSRT(path string) ([]byte, error) {
-	sub, err := astisub.OpenFile(path)
-	if err != nil {
-		return nil, err
+	return Convert(path, FormatSRT, ConvertOptions{})
+}
+
+// Open reads the subtitle file at path. MicroDVD files are read using the
+// frame rate resolved from opts and DFXP files as TTML; other formats are
+// read by go-astisub.
+func Open(path string, opts ConvertOptions) (*astisub.Subtitles, error) {
+	switch f, _ := ParseFormat(filepath.Ext(path)); f {
+	case FormatMicroDVD:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return readMicroDVD(data, opts)
+	case FormatTTML:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return astisub.ReadFromTTML(bytes.NewReader(data))
+	}
+	return astisub.OpenFile(path)
+}
+
+// Encode serialises sub as target. Inline styling is mapped between ASS/SSA
+// override tags and SRT/WebVTT markup unless opts.PlainText is set.
+func Encode(sub *astisub.Subtitles, target Format, opts ConvertOptions) ([]byte, error) {
+	switch ssa := hasSSAStyling(sub); {
+	case target.isSSA():
+		if !ssa {
+			inlineToSSA(sub, !opts.PlainText)
+		}
+		prepareSSA(sub, target)
+	case ssa && !opts.PlainText:
+		ssaToInline(sub)
 	}
+
 	buf := &bytes.Buffer{}
-	if err := sub.WriteToSRT(buf); err != nil {
+	var err error
+	switch target {
+	case FormatSRT:
+		err = sub.WriteToSRT(buf)
+	case FormatASS, FormatSSA:
+		err = sub.WriteToSSA(buf)
+	case FormatWebVTT:
+		err = sub.WriteToWebVTT(buf)
+	case FormatTTML:
+		err = sub.WriteToTTML(buf)
+	case FormatSTL:
+		err = sub.WriteToSTL(buf)
+	case FormatMicroDVD:
+		err = writeMicroDVD(buf, sub, opts)
+	default:
+		return nil, fmt.Errorf("unsupported subtitle format %q", target)
+	}
+	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
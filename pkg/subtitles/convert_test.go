@@ -1,6 +1,17 @@
+// file: pkg/subtitles/convert_test.go
+// version: 1.1.0
+// guid: 0a47e727-6cf8-4c92-b1b6-5c99cbc13c12
+
 package subtitles
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jdfalk/subtitle-manager/pkg/video"
+)
 
 func TestConvertToSRT(t *testing.T) {
 	out, err := ConvertToSRT("../../testdata/simple.srt")
@@ -14,3 +25,122 @@ func TestConvertToSRT(t *testing.T) {
 		t.Fatal("empty output")
 	}
 }
+
+// writeTemp writes data to name in a temporary directory.
+func writeTemp(t *testing.T, name, data string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return p
+}
+
+func convert(t *testing.T, path string, target Format, opts ConvertOptions) string {
+	t.Helper()
+	out, err := Convert(path, target, opts)
+	if err != nil {
+		t.Fatalf("convert to %s: %v", target, err)
+	}
+	return string(out)
+}
+
+func TestParseFormat(t *testing.T) {
+	cases := map[string]Format{
+		"srt": FormatSRT, ".ASS": FormatASS, "webvtt": FormatWebVTT,
+		"vtt": FormatWebVTT, "microdvd": FormatMicroDVD, ".sub": FormatMicroDVD,
+		"dfxp": FormatTTML,
+	}
+	for in, want := range cases {
+		got, err := ParseFormat(in)
+		if err != nil || got != want {
+			t.Fatalf("%q: got %q, %v want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("doc"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestConvertSameFormatKeepsFile(t *testing.T) {
+	in := writeTemp(t, "in.ass", assSample)
+	if got := convert(t, in, FormatASS, ConvertOptions{}); got != assSample {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestConvertASSToWebVTT(t *testing.T) {
+	in := writeTemp(t, "in.ass", strings.Replace(assSample, `{\an8}Hello\Nworld`, `{\an8}Hello {\i1}there{\i0}\N{\c&H00FFFF&}world`, 1))
+	got := convert(t, in, FormatWebVTT, ConvertOptions{})
+	if !strings.Contains(got, "line:10%") {
+		t.Fatalf("alignment missing:\n%s", got)
+	}
+	if !strings.Contains(got, "Hello <i>there</i>\n<c.yellow>world</c>") {
+		t.Fatalf("styling missing:\n%s", got)
+	}
+	if strings.Contains(got, `\i1`) {
+		t.Fatalf("override tags leaked:\n%s", got)
+	}
+
+	srt := convert(t, in, FormatSRT, ConvertOptions{})
+	if !strings.Contains(srt, `{\an8}Hello <i>there</i>`) {
+		t.Fatalf("unexpected srt:\n%s", srt)
+	}
+
+	plain := convert(t, in, FormatSRT, ConvertOptions{PlainText: true})
+	if strings.Contains(plain, "<i>") || !strings.Contains(plain, "there") {
+		t.Fatalf("unexpected plain srt:\n%s", plain)
+	}
+}
+
+func TestConvertWebVTTToASS(t *testing.T) {
+	in := writeTemp(t, "in.vtt", "WEBVTT\n\n00:00:01.000 --> 00:00:02.000 line:0\n<i>Hello</i> world\nagain\n")
+	got := convert(t, in, FormatASS, ConvertOptions{})
+	for _, want := range []string{"ScriptType: v4.00+", "Style: Default,", ",Arial,", `{\an8}{\i1}Hello{\i0} world\nagain`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestConvertMicroDVD(t *testing.T) {
+	in := writeTemp(t, "in.sub", "{1}{1}25\n{25}{50}Hello|{y:i}world\n{75}{100}{Y:b}Bold|lines\n")
+	got := convert(t, in, FormatSRT, ConvertOptions{})
+	for _, want := range []string{"00:00:01,000 --> 00:00:02,000\nHello\n<i>world</i>", "00:00:03,000 --> 00:00:04,000\n<b>Bold</b>\n<b>lines</b>"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q:\n%s", want, got)
+		}
+	}
+
+	// An explicit frame rate overrides the header.
+	got = convert(t, in, FormatSRT, ConvertOptions{FPS: 50})
+	if !strings.Contains(got, "00:00:00,500 --> 00:00:01,000") {
+		t.Fatalf("fps not applied:\n%s", got)
+	}
+
+	srt := writeTemp(t, "in.srt", "1\n00:00:01,000 --> 00:00:02,000\n<i>Hello</i>\nworld\n")
+	got = convert(t, srt, FormatMicroDVD, ConvertOptions{FPS: 25})
+	if got != "{1}{1}25\n{25}{50}{y:i}Hello|world\n" {
+		t.Fatalf("unexpected microdvd:\n%s", got)
+	}
+}
+
+func TestConvertMicroDVDProbesVideo(t *testing.T) {
+	analyzeVideo = func(path string) (*video.VideoInfo, error) {
+		return &video.VideoInfo{FrameRate: 10}, nil
+	}
+	t.Cleanup(func() { analyzeVideo = video.AnalyzeVideo })
+
+	in := writeTemp(t, "in.sub", "{10}{20}Hello\n")
+	got := convert(t, in, FormatSRT, ConvertOptions{VideoPath: "movie.mkv"})
+	if !strings.Contains(got, "00:00:01,000 --> 00:00:02,000") {
+		t.Fatalf("probed fps not applied:\n%s", got)
+	}
+}
+
+func TestConvertRejectsNonMicroDVDSub(t *testing.T) {
+	in := writeTemp(t, "in.sub", "not a subtitle\n")
+	if _, err := Convert(in, FormatSRT, ConvertOptions{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,417 @@
+// file: pkg/subtitles/cue.go
+// version: 1.0.0
+// guid: 6e2d9a47-3b1c-4f85-a7d0-91c4e8b2f653
+
+package subtitles
+
+import (
+	"html"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/asticode/go-astisub"
+)
+
+// Speaker dashes introduce each speaker's line in dialogue cues such as
+// "- Where are you?\n- Home.".
+var speakerDash = regexp.MustCompile(`^\s*[-–—]\s*`)
+
+// styleTag matches the placeholder tags wrapped around styled runs. The
+// pattern tolerates the spacing and case changes translators introduce.
+var styleTag = regexp.MustCompile(`(?i)<\s*(/?)\s*s\s*(\d+)\s*>`)
+
+// runStyle is the styling of a piece of cue text.
+type runStyle struct {
+	inline *astisub.StyleAttributes
+	style  *astisub.Style
+}
+
+func (s runStyle) plain() bool {
+	return s.inline == nil && s.style == nil
+}
+
+func (s runStyle) equal(o runStyle) bool {
+	return s.style == o.style && reflect.DeepEqual(s.inline, o.inline)
+}
+
+// run is a stretch of cue text sharing one style. pre holds empty styled
+// items, such as SSA override blocks, emitted before the text.
+type run struct {
+	style runStyle
+	text  string
+	pre   []astisub.LineItem
+}
+
+// cueSegment is the part of a cue translated as one unit: the whole cue, or
+// a single speaker's line in a dialogue cue.
+type cueSegment struct {
+	original []astisub.Line
+	lines    int
+	voice    string
+	prefix   string
+	lead     []astisub.LineItem
+	trail    []astisub.LineItem
+	runs     []run
+	source   string
+}
+
+// splitCue splits the lines of item into translation segments.
+func splitCue(item *astisub.Item) []*cueSegment {
+	dialogue := len(item.Lines) > 1
+	for _, l := range item.Lines {
+		if !speakerDash.MatchString(l.String()) {
+			dialogue = false
+			break
+		}
+	}
+	if !dialogue {
+		return []*cueSegment{newCueSegment(item.Lines, false)}
+	}
+	segs := make([]*cueSegment, len(item.Lines))
+	for i := range item.Lines {
+		segs[i] = newCueSegment(item.Lines[i:i+1], true)
+	}
+	return segs
+}
+
+// newCueSegment collects the styled runs of lines and builds the source text
+// sent to the translator. When dialogue is set the leading speaker dash is
+// removed and restored after translation.
+func newCueSegment(lines []astisub.Line, dialogue bool) *cueSegment {
+	seg := &cueSegment{original: lines, lines: len(lines)}
+	if len(lines) > 0 {
+		seg.voice = lines[0].VoiceName
+	}
+	var pending []astisub.LineItem
+	findDash := dialogue
+	for li, line := range lines {
+		newLine := true
+		for _, it := range line.Items {
+			text := it.Text
+			if findDash && strings.TrimSpace(text) != "" {
+				findDash = false
+				if m := speakerDash.FindString(text); m != "" {
+					seg.prefix = strings.TrimSpace(m)
+					if strings.HasSuffix(m, " ") {
+						seg.prefix += " "
+					}
+					text = text[len(m):]
+				}
+			}
+			st := runStyle{inline: it.InlineStyle, style: it.Style}
+			if strings.TrimSpace(text) == "" {
+				if !st.plain() {
+					pending = append(pending, astisub.LineItem{InlineStyle: it.InlineStyle, Style: it.Style})
+				}
+				continue
+			}
+			if newLine && li > 0 {
+				text = " " + strings.TrimLeftFunc(text, unicode.IsSpace)
+			}
+			newLine = false
+			if n := len(seg.runs); n > 0 && len(pending) == 0 && seg.runs[n-1].style.equal(st) {
+				seg.runs[n-1].text += text
+				continue
+			}
+			seg.runs = append(seg.runs, run{style: st, text: text, pre: pending})
+			pending = nil
+		}
+	}
+	seg.trail = pending
+	if len(seg.runs) == 0 {
+		return seg
+	}
+	seg.lead = seg.runs[0].pre
+	seg.runs[0].pre = nil
+	for i := range seg.runs {
+		seg.runs[i].text = collapseSpaces(seg.runs[i].text)
+	}
+	seg.runs[0].text = strings.TrimLeftFunc(seg.runs[0].text, unicode.IsSpace)
+	last := len(seg.runs) - 1
+	seg.runs[last].text = strings.TrimRightFunc(seg.runs[last].text, unicode.IsSpace)
+
+	if len(seg.runs) == 1 {
+		seg.source = seg.runs[0].text
+		return seg
+	}
+	var b strings.Builder
+	for i, r := range seg.runs {
+		if r.style.plain() && len(r.pre) == 0 {
+			b.WriteString(r.text)
+			continue
+		}
+		// Keep surrounding spaces outside the tags so translators do not
+		// glue words together.
+		trimmed := strings.TrimSpace(r.text)
+		lead := r.text[:strings.Index(r.text, trimmed)]
+		trail := r.text[len(lead)+len(trimmed):]
+		b.WriteString(lead)
+		b.WriteString("<s" + strconv.Itoa(i) + ">" + trimmed + "</s" + strconv.Itoa(i) + ">")
+		b.WriteString(trail)
+	}
+	seg.source = b.String()
+	return seg
+}
+
+// collapseSpaces replaces runs of whitespace with a single space.
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// piece is translated text with the style of the run it came from.
+type piece struct {
+	run  int
+	text string
+}
+
+// rebuild returns the lines of the segment for the translated source text.
+func (seg *cueSegment) rebuild(translated string) []astisub.Line {
+	if seg.source == "" {
+		return seg.original
+	}
+	pieces := seg.parse(translated)
+	if len(pieces) > 0 && seg.prefix != "" {
+		pieces[0].text = speakerDash.ReplaceAllString(pieces[0].text, "")
+	}
+	lines := seg.wrap(pieces)
+
+	out := make([]astisub.Line, len(lines))
+	usedPre := make(map[int]bool)
+	for i, ps := range lines {
+		l := astisub.Line{VoiceName: seg.voice}
+		if i == 0 {
+			l.Items = append(l.Items, seg.lead...)
+		}
+		for j, p := range ps {
+			text := p.text
+			if i == 0 && j == 0 {
+				text = seg.prefix + text
+			}
+			r := seg.runs[p.run]
+			if !usedPre[p.run] {
+				l.Items = append(l.Items, r.pre...)
+				usedPre[p.run] = true
+			}
+			l.Items = append(l.Items, astisub.LineItem{InlineStyle: r.style.inline, Style: r.style.style, Text: text})
+		}
+		if i == len(lines)-1 {
+			l.Items = append(l.Items, seg.trail...)
+		}
+		out[i] = l
+	}
+	return out
+}
+
+// parse splits translated text into pieces at the placeholder tags. Text
+// outside tags takes the style of the first plain run, or of the longest run
+// when every run is styled, so dropped tags do not lose whole-cue styling.
+func (seg *cueSegment) parse(translated string) []piece {
+	fallback := 0
+	for i, r := range seg.runs {
+		if r.style.plain() {
+			fallback = i
+			break
+		}
+		if len(r.text) > len(seg.runs[fallback].text) {
+			fallback = i
+		}
+	}
+	current := fallback
+	var pieces []piece
+	add := func(text string) {
+		text = html.UnescapeString(text)
+		if text == "" {
+			return
+		}
+		if n := len(pieces); n > 0 && pieces[n-1].run == current {
+			pieces[n-1].text += text
+			return
+		}
+		pieces = append(pieces, piece{run: current, text: text})
+	}
+	pos := 0
+	for _, m := range styleTag.FindAllStringSubmatchIndex(translated, -1) {
+		add(translated[pos:m[0]])
+		pos = m[1]
+		n, err := strconv.Atoi(translated[m[4]:m[5]])
+		if err != nil || n >= len(seg.runs) {
+			continue
+		}
+		if m[3] > m[2] {
+			current = fallback
+		} else {
+			current = n
+		}
+	}
+	add(translated[pos:])
+
+	// Normalise whitespace while keeping the spaces between pieces.
+	for i := range pieces {
+		pieces[i].text = collapseSpaces(pieces[i].text)
+	}
+	for len(pieces) > 0 {
+		pieces[0].text = strings.TrimLeftFunc(pieces[0].text, unicode.IsSpace)
+		if pieces[0].text != "" {
+			break
+		}
+		pieces = pieces[1:]
+	}
+	for len(pieces) > 0 {
+		last := len(pieces) - 1
+		pieces[last].text = strings.TrimRightFunc(pieces[last].text, unicode.IsSpace)
+		if pieces[last].text != "" {
+			break
+		}
+		pieces = pieces[:last]
+	}
+	return pieces
+}
+
+// wrap distributes pieces over the original number of lines, breaking at
+// the spaces closest to even line lengths.
+func (seg *cueSegment) wrap(pieces []piece) [][]piece {
+	var full []rune
+	for _, p := range pieces {
+		full = append(full, []rune(p.text)...)
+	}
+	breaks := lineBreaks(full, seg.lines)
+	if len(breaks) == 0 {
+		return [][]piece{pieces}
+	}
+
+	lines := [][]piece{nil}
+	offset := 0
+	next := 0
+	for _, p := range pieces {
+		text := []rune(p.text)
+		start := 0
+		for next < len(breaks) && breaks[next] < offset+len(text) {
+			cut := breaks[next] - offset
+			if cut > start {
+				lines[len(lines)-1] = append(lines[len(lines)-1], piece{run: p.run, text: string(text[start:cut])})
+			}
+			lines = append(lines, nil)
+			start = cut + 1 // drop the space at the break
+			next++
+		}
+		if start < len(text) {
+			lines[len(lines)-1] = append(lines[len(lines)-1], piece{run: p.run, text: string(text[start:])})
+		}
+		offset += len(text)
+	}
+	for i, ps := range lines {
+		if n := len(ps); n > 0 {
+			ps[n-1].text = strings.TrimRightFunc(ps[n-1].text, unicode.IsSpace)
+		}
+		lines[i] = ps
+	}
+	return lines
+}
+
+// lineBreaks returns the indexes of the spaces in text at which to break it
+// into at most lines lines of similar length.
+func lineBreaks(text []rune, lines int) []int {
+	if lines < 2 {
+		return nil
+	}
+	var spaces []int
+	for i, r := range text {
+		if r == ' ' {
+			spaces = append(spaces, i)
+		}
+	}
+	var breaks []int
+	from := 0
+	for k := 1; k < lines && from < len(spaces); k++ {
+		target := k * len(text) / lines
+		best := -1
+		// Leave spaces for the remaining breaks when there are enough.
+		limit := len(spaces) - (lines - 1 - k)
+		if limit <= from {
+			limit = from + 1
+		}
+		for i := from; i < limit; i++ {
+			if best == -1 || abs(spaces[i]-target) < abs(spaces[best]-target) {
+				best = i
+			}
+		}
+		if best == -1 {
+			break
+		}
+		breaks = append(breaks, spaces[best])
+		from = best + 1
+	}
+	return breaks
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// translateItems translates the cues of items in place. translate receives
+// the unique segment texts and returns their translations in order.
+func translateItems(items []*astisub.Item, translate func(texts []string) ([]string, error)) error {
+	type cue struct {
+		item *astisub.Item
+		segs []*cueSegment
+	}
+	var cues []cue
+	var unique []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		segs := splitCue(item)
+		translatable := false
+		for _, seg := range segs {
+			if seg.source == "" {
+				continue
+			}
+			translatable = true
+			if !seen[seg.source] {
+				seen[seg.source] = true
+				unique = append(unique, seg.source)
+			}
+		}
+		if translatable {
+			cues = append(cues, cue{item: item, segs: segs})
+		}
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+
+	translated, err := translate(unique)
+	if err != nil {
+		return err
+	}
+	results := make(map[string]string, len(unique))
+	for i, text := range unique {
+		results[text] = translated[i]
+	}
+	for _, c := range cues {
+		var lines []astisub.Line
+		for _, seg := range c.segs {
+			lines = append(lines, seg.rebuild(results[seg.source])...)
+		}
+		c.item.Lines = lines
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// file: pkg/subtitles/cue_test.go
+// version: 1.0.0
+// guid: 1f8a3c62-7d4e-4b09-9e25-c6b0d7a41e88
+
+package subtitles
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/translator"
+)
+
+// upper is a fake translator that upper-cases text, including the
+// placeholder tags, and records what it was asked to translate.
+type upper struct {
+	calls []string
+}
+
+func (u *upper) translate(texts []string) ([]string, error) {
+	out := make([]string, len(texts))
+	for i, t := range texts {
+		u.calls = append(u.calls, t)
+		out[i] = strings.ToUpper(t)
+	}
+	return out, nil
+}
+
+func readSRT(t *testing.T, data string) *astisub.Subtitles {
+	t.Helper()
+	sub, err := astisub.ReadFromSRT(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("read srt: %v", err)
+	}
+	return sub
+}
+
+func writeSRT(t *testing.T, sub *astisub.Subtitles) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := sub.WriteToSRT(buf); err != nil {
+		t.Fatalf("write srt: %v", err)
+	}
+	return buf.String()
+}
+
+func TestTranslateItemsKeepsStyleAndLines(t *testing.T) {
+	sub := readSRT(t, "1\n00:00:01,000 --> 00:00:02,000\n<i>Hello there,</i>\nmy old friend\n")
+	u := &upper{}
+	if err := translateItems(sub.Items, u.translate); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	if len(u.calls) != 1 || u.calls[0] != "<s0>Hello there,</s0> my old friend" {
+		t.Fatalf("unexpected source %q", u.calls)
+	}
+	got := writeSRT(t, sub)
+	if !strings.Contains(got, "<i>HELLO THERE,</i>\nMY OLD FRIEND\n") {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestTranslateItemsDialogue(t *testing.T) {
+	sub := readSRT(t, "1\n00:00:01,000 --> 00:00:02,000\n- Where are you?\n- At home.\n")
+	u := &upper{}
+	if err := translateItems(sub.Items, u.translate); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	if strings.Join(u.calls, "|") != "Where are you?|At home." {
+		t.Fatalf("unexpected sources %q", u.calls)
+	}
+	got := writeSRT(t, sub)
+	if !strings.Contains(got, "- WHERE ARE YOU?\n- AT HOME.\n") {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestTranslateItemsDroppedTags(t *testing.T) {
+	sub := readSRT(t, "1\n00:00:01,000 --> 00:00:02,000\n<i>Once upon</i>\n<b>a time</b>\n")
+	strip := func(texts []string) ([]string, error) {
+		out := make([]string, len(texts))
+		for i, text := range texts {
+			out[i] = styleTag.ReplaceAllString(text, "")
+		}
+		return out, nil
+	}
+	if err := translateItems(sub.Items, strip); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	got := writeSRT(t, sub)
+	if !strings.Contains(got, "<i>Once upon</i>\n<i>a time</i>\n") {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestTranslateItemsRewrapsLongerText(t *testing.T) {
+	sub := readSRT(t, "1\n00:00:01,000 --> 00:00:02,000\nI am\nhere\n")
+	longer := func(texts []string) ([]string, error) {
+		return []string{"je suis vraiment ici maintenant"}, nil
+	}
+	if err := translateItems(sub.Items, longer); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	got := writeSRT(t, sub)
+	if !strings.Contains(got, "je suis vraiment\nici maintenant\n") {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestLineBreaks(t *testing.T) {
+	cases := []struct {
+		text  string
+		lines int
+		want  []int
+	}{
+		{"one two three four", 2, []int{7}},
+		{"single", 2, nil},
+		{"a b", 3, []int{1}},
+		{"one two three", 1, nil},
+	}
+	for _, c := range cases {
+		got := lineBreaks([]rune(c.text), c.lines)
+		if len(got) != len(c.want) {
+			t.Fatalf("%q: got %v want %v", c.text, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("%q: got %v want %v", c.text, got, c.want)
+			}
+		}
+	}
+}
+
+const assSample = `[Script Info]
+ScriptType: v4.00+
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Sign,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,2,8,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:01.00,0:00:02.00,Sign,,0,0,0,,{\an8}Hello\Nworld
+`
+
+func TestTranslateFileKeepsASS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		type tr struct {
+			TranslatedText string `json:"translatedText"`
+		}
+		var out []tr
+		for _, q := range r.Form["q"] {
+			out = append(out, tr{TranslatedText: strings.ToUpper(q)})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"translations": out}})
+	}))
+	defer srv.Close()
+	translator.SetGoogleAPIURL(srv.URL)
+	defer translator.SetGoogleAPIURL("https://translation.googleapis.com/language/translate/v2")
+
+	dir := t.TempDir()
+	viper.Set("media_directory", dir)
+	t.Cleanup(viper.Reset)
+	in := filepath.Join(dir, "in.ass")
+	if err := os.WriteFile(in, []byte(assSample), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := filepath.Join(dir, "out.ass")
+	if err := TranslateFile(in, out, "es", "google", "k", "", ""); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	got := string(data)
+	if !strings.Contains(got, "Style: Sign,") {
+		t.Fatalf("styles missing:\n%s", got)
+	}
+	if !strings.Contains(got, `{\an8}HELLO\nWORLD`) {
+		t.Fatalf("unexpected dialogue:\n%s", got)
+	}
+}
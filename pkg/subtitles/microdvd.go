@@ -0,0 +1,219 @@
+// file: pkg/subtitles/microdvd.go
+// version: 1.0.0
+// guid: 2b9e4f17-6c3a-4d82-b5e1-8a7d0c3f9e26
+
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astisub"
+
+	"github.com/jdfalk/subtitle-manager/pkg/video"
+)
+
+// DefaultFPS is the frame rate assumed for MicroDVD files when none is
+// given, probed or declared in the file.
+const DefaultFPS = 23.976
+
+// analyzeVideo probes media files for their frame rate. Tests replace it.
+var analyzeVideo = video.AnalyzeVideo
+
+// microDVDLine matches "{start}{end}text" cues.
+var microDVDLine = regexp.MustCompile(`^\{(\d+)\}\{(\d*)\}(.*)$`)
+
+// microDVDCode matches control codes such as {y:i}, {Y:b} or {c:$0000FF}.
+var microDVDCode = regexp.MustCompile(`\{([a-zA-Z]):([^}]*)\}`)
+
+// frameRate resolves the frame rate for a MicroDVD file whose header
+// declares header frames per second (zero when absent).
+func (o ConvertOptions) frameRate(header float64) (float64, error) {
+	if o.FPS > 0 {
+		return o.FPS, nil
+	}
+	if o.VideoPath != "" {
+		info, err := analyzeVideo(o.VideoPath)
+		if err != nil {
+			return 0, fmt.Errorf("probe frame rate: %w", err)
+		}
+		if info.FrameRate > 0 {
+			return info.FrameRate, nil
+		}
+	}
+	if header > 0 {
+		return header, nil
+	}
+	return DefaultFPS, nil
+}
+
+// microDVDStyle is the formatting applied by MicroDVD control codes.
+type microDVDStyle struct {
+	italic, bold, underline bool
+	color                   string
+}
+
+// apply updates s with the control code name:value.
+func (s *microDVDStyle) apply(name, value string) {
+	switch strings.ToLower(name) {
+	case "y":
+		for _, v := range strings.Split(strings.ToLower(value), ",") {
+			switch strings.TrimSpace(v) {
+			case "i":
+				s.italic = true
+			case "b":
+				s.bold = true
+			case "u":
+				s.underline = true
+			}
+		}
+	case "c":
+		// Colours are written as $BBGGRR.
+		v := strings.TrimPrefix(value, "$")
+		if len(v) == 6 {
+			s.color = "#" + strings.ToLower(v[4:6]+v[2:4]+v[0:2])
+		}
+	}
+}
+
+func (s microDVDStyle) attributes() *astisub.StyleAttributes {
+	if s == (microDVDStyle{}) {
+		return nil
+	}
+	sa := &astisub.StyleAttributes{}
+	setInlineStyle(sa, inlineState{italic: s.italic, bold: s.bold, underline: s.underline, color: s.color})
+	return sa
+}
+
+// readMicroDVD parses MicroDVD cues. A leading {1}{1}fps cue declares the
+// frame rate and is not returned as a subtitle.
+func readMicroDVD(data []byte, opts ConvertOptions) (*astisub.Subtitles, error) {
+	type cue struct {
+		start, end int
+		text       string
+	}
+	var cues []cue
+	var header float64
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\uFEFF"))
+		if line == "" {
+			continue
+		}
+		m := microDVDLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("not a MicroDVD subtitle: %q", line)
+		}
+		start, _ := strconv.Atoi(m[1])
+		end, _ := strconv.Atoi(m[2])
+		if len(cues) == 0 && header == 0 && start <= 1 && end <= 1 {
+			if fps, err := strconv.ParseFloat(strings.TrimSpace(m[3]), 64); err == nil && fps > 0 {
+				header = fps
+				continue
+			}
+		}
+		cues = append(cues, cue{start: start, end: end, text: m[3]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	fps, err := opts.frameRate(header)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := astisub.NewSubtitles()
+	frame := func(n int) time.Duration {
+		return time.Duration(float64(n) / fps * float64(time.Second))
+	}
+	for _, c := range cues {
+		item := &astisub.Item{StartAt: frame(c.start), EndAt: frame(c.end)}
+		var global microDVDStyle
+		for _, raw := range strings.Split(c.text, "|") {
+			local := global
+			for _, m := range microDVDCode.FindAllStringSubmatch(raw, -1) {
+				if m[1] == strings.ToUpper(m[1]) {
+					// Upper-case codes apply to every following line.
+					global.apply(m[1], m[2])
+				}
+				local.apply(m[1], m[2])
+			}
+			text := microDVDCode.ReplaceAllString(raw, "")
+			item.Lines = append(item.Lines, astisub.Line{Items: []astisub.LineItem{{InlineStyle: local.attributes(), Text: text}}})
+		}
+		if c.end == 0 {
+			// Open-ended cues last until the next one or three seconds.
+			item.EndAt = item.StartAt + 3*time.Second
+		}
+		sub.Items = append(sub.Items, item)
+	}
+	for i := 0; i+1 < len(cues); i++ {
+		if cues[i].end == 0 && sub.Items[i+1].StartAt < sub.Items[i].EndAt {
+			sub.Items[i].EndAt = sub.Items[i+1].StartAt
+		}
+	}
+	return sub, nil
+}
+
+// writeMicroDVD writes sub as MicroDVD, declaring the frame rate in a
+// leading {1}{1}fps cue. Lines whose text is entirely italic, bold or
+// underlined get the matching {y:} code; other inline styling is dropped.
+func writeMicroDVD(w io.Writer, sub *astisub.Subtitles, opts ConvertOptions) error {
+	if len(sub.Items) == 0 {
+		return astisub.ErrNoSubtitlesToWrite
+	}
+	fps, err := opts.frameRate(0)
+	if err != nil {
+		return err
+	}
+	frames := func(d time.Duration) int {
+		return int(math.Round(d.Seconds() * fps))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "{1}{1}%s\n", strconv.FormatFloat(fps, 'f', -1, 64))
+	for _, item := range sub.Items {
+		var lines []string
+		for _, l := range item.Lines {
+			var text strings.Builder
+			all := inlineState{italic: true, bold: true, underline: true}
+			seen := false
+			for _, li := range l.Items {
+				text.WriteString(li.Text)
+				if strings.TrimSpace(li.Text) == "" {
+					continue
+				}
+				st := inlineStateOf(li.InlineStyle)
+				all.italic = all.italic && st.italic
+				all.bold = all.bold && st.bold
+				all.underline = all.underline && st.underline
+				seen = true
+			}
+			var codes []string
+			if seen && all.italic {
+				codes = append(codes, "i")
+			}
+			if seen && all.bold {
+				codes = append(codes, "b")
+			}
+			if seen && all.underline {
+				codes = append(codes, "u")
+			}
+			prefix := ""
+			if len(codes) > 0 {
+				prefix = "{y:" + strings.Join(codes, ",") + "}"
+			}
+			lines = append(lines, prefix+strings.ReplaceAll(text.String(), "|", "/"))
+		}
+		fmt.Fprintf(&b, "{%d}{%d}%s\n", frames(item.StartAt), frames(item.EndAt), strings.Join(lines, "|"))
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,430 @@
+// file: pkg/subtitles/stylemap.go
+// version: 1.0.0
+// guid: 9c4e1a83-2f6d-4b57-a0e8-5d3b7c9f1e42
+
+package subtitles
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astikit"
+	"github.com/asticode/go-astisub"
+)
+
+// inlineState is the inline formatting shared by SRT, WebVTT and SSA
+// override tags. color is a lower-case "#rrggbb" value or empty.
+type inlineState struct {
+	italic, bold, underline bool
+	color                   string
+}
+
+// inlineStateOf reads the SRT and WebVTT formatting of sa.
+func inlineStateOf(sa *astisub.StyleAttributes) inlineState {
+	var st inlineState
+	if sa == nil {
+		return st
+	}
+	st.italic = sa.SRTItalics || sa.WebVTTItalics
+	st.bold = sa.SRTBold || sa.WebVTTBold
+	st.underline = sa.SRTUnderline || sa.WebVTTUnderline
+	for _, tag := range sa.WebVTTTags {
+		switch tag.Name {
+		case "i":
+			st.italic = true
+		case "b":
+			st.bold = true
+		case "u":
+			st.underline = true
+		}
+	}
+	switch {
+	case sa.SRTColor != nil:
+		st.color = normalizeColor(*sa.SRTColor)
+	case sa.TTMLColor != nil:
+		st.color = normalizeColor(*sa.TTMLColor)
+	}
+	return st
+}
+
+// setInlineStyle writes st to the SRT and WebVTT fields of sa.
+func setInlineStyle(sa *astisub.StyleAttributes, st inlineState) {
+	sa.SRTItalics, sa.WebVTTItalics = st.italic, st.italic
+	sa.SRTBold, sa.WebVTTBold = st.bold, st.bold
+	sa.SRTUnderline, sa.WebVTTUnderline = st.underline, st.underline
+	sa.WebVTTTags = nil
+	if st.bold {
+		sa.WebVTTTags = append(sa.WebVTTTags, astisub.WebVTTTag{Name: "b"})
+	}
+	if st.italic {
+		sa.WebVTTTags = append(sa.WebVTTTags, astisub.WebVTTTag{Name: "i"})
+	}
+	if st.underline {
+		sa.WebVTTTags = append(sa.WebVTTTags, astisub.WebVTTTag{Name: "u"})
+	}
+	sa.SRTColor, sa.TTMLColor = nil, nil
+	if st.color != "" {
+		sa.SRTColor = astikit.StrPtr(st.color)
+		sa.TTMLColor = astikit.StrPtr(st.color)
+	}
+}
+
+// normalizeColor returns c as "#rrggbb" when it is a hex colour.
+func normalizeColor(c string) string {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if len(c) == 7 && c[0] == '#' {
+		return c
+	}
+	if len(c) == 6 {
+		if _, err := strconv.ParseUint(c, 16, 32); err == nil {
+			return "#" + c
+		}
+	}
+	return c
+}
+
+// hasSSAStyling reports whether sub was read from an SSA/ASS script, in
+// which case its formatting lives in styles and override tags.
+func hasSSAStyling(sub *astisub.Subtitles) bool {
+	if sub.Metadata != nil && sub.Metadata.SSAScriptType != "" {
+		return true
+	}
+	for _, item := range sub.Items {
+		for _, l := range item.Lines {
+			for _, li := range l.Items {
+				if li.InlineStyle != nil && li.InlineStyle.SSAEffect != "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Override tags understood by ssaToInline.
+var (
+	ssaAlignNumpad = regexp.MustCompile(`^an([1-9])$`)
+	ssaAlignLegacy = regexp.MustCompile(`^a(\d{1,2})$`)
+	ssaItalic      = regexp.MustCompile(`^i([01]?)$`)
+	ssaBold        = regexp.MustCompile(`^b(\d*)$`)
+	ssaUnderline   = regexp.MustCompile(`^u([01]?)$`)
+	ssaColor       = regexp.MustCompile(`^1?c(?:&H([0-9a-fA-F]+)&?)?$`)
+)
+
+// ssaState is the formatting of SSA text: the inline formatting plus the
+// numpad alignment of the event.
+type ssaState struct {
+	inlineState
+	align int
+}
+
+// styleState returns the formatting defined by an SSA style.
+func styleState(style *astisub.Style, legacy bool) ssaState {
+	var st ssaState
+	if style == nil || style.InlineStyle == nil {
+		return st
+	}
+	sa := style.InlineStyle
+	st.italic = sa.SSAItalic != nil && *sa.SSAItalic
+	st.bold = sa.SSABold != nil && *sa.SSABold
+	st.underline = sa.SSAUnderline != nil && *sa.SSAUnderline
+	if c := sa.SSAPrimaryColour; c != nil && c.TTMLString() != "ffffff" {
+		st.color = "#" + c.TTMLString()
+	}
+	if sa.SSAAlignment != nil {
+		st.align = *sa.SSAAlignment
+		if legacy {
+			st.align = legacyAlignment(st.align)
+		}
+	}
+	return st
+}
+
+// legacyAlignment converts SSA v4 alignment (1-3 bottom, 5-7 top, 9-11
+// middle) to the numpad layout used by ASS.
+func legacyAlignment(a int) int {
+	switch {
+	case a >= 5 && a <= 7:
+		return a + 2
+	case a >= 9 && a <= 11:
+		return a - 5
+	}
+	return a
+}
+
+// apply updates st with the override block effect, e.g. "{\i1\an8}".
+func (st *ssaState) apply(effect string, base ssaState) {
+	effect = strings.TrimSuffix(strings.TrimPrefix(effect, "{"), "}")
+	for _, tag := range strings.Split(effect, `\`) {
+		tag = strings.TrimSpace(tag)
+		if m := ssaAlignNumpad.FindStringSubmatch(tag); m != nil {
+			st.align, _ = strconv.Atoi(m[1])
+		} else if m := ssaAlignLegacy.FindStringSubmatch(tag); m != nil {
+			a, _ := strconv.Atoi(m[1])
+			st.align = legacyAlignment(a)
+		} else if m := ssaItalic.FindStringSubmatch(tag); m != nil {
+			st.italic = m[1] == "1" || (m[1] == "" && base.italic)
+		} else if m := ssaBold.FindStringSubmatch(tag); m != nil {
+			if m[1] == "" {
+				st.bold = base.bold
+			} else {
+				w, _ := strconv.Atoi(m[1])
+				st.bold = w == 1 || w >= 600
+			}
+		} else if m := ssaUnderline.FindStringSubmatch(tag); m != nil {
+			st.underline = m[1] == "1" || (m[1] == "" && base.underline)
+		} else if m := ssaColor.FindStringSubmatch(tag); m != nil {
+			st.color = base.color
+			if m[1] != "" {
+				st.color = ssaColorToHex(m[1])
+			}
+		} else if strings.HasPrefix(tag, "r") && !strings.HasPrefix(tag, "rnd") {
+			align := st.align
+			*st = base
+			st.align = align
+		}
+	}
+}
+
+// ssaColorToHex converts an SSA "BBGGRR" (optionally "AABBGGRR") hex value
+// to "#rrggbb".
+func ssaColorToHex(v string) string {
+	n, err := strconv.ParseUint(v, 16, 32)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", n&0xff, (n>>8)&0xff, (n>>16)&0xff)
+}
+
+// hexToSSAColor converts "#rrggbb" to an SSA "&HBBGGRR&" value.
+func hexToSSAColor(c string) string {
+	c = strings.TrimPrefix(normalizeColor(c), "#")
+	if len(c) != 6 {
+		return ""
+	}
+	return strings.ToUpper("&H" + c[4:6] + c[2:4] + c[0:2] + "&")
+}
+
+// ssaToInline replaces the SSA styles and override tags of sub with SRT and
+// WebVTT inline formatting. Alignment becomes an SRT {\anN} position and
+// WebVTT line and align cue settings.
+func ssaToInline(sub *astisub.Subtitles) {
+	legacy := sub.Metadata != nil && sub.Metadata.SSAScriptType == "v4.00"
+	for _, item := range sub.Items {
+		base := styleState(item.Style, legacy)
+		cur := base
+		for i, l := range item.Lines {
+			var items []astisub.LineItem
+			var states []inlineState
+			for _, li := range l.Items {
+				if li.InlineStyle != nil && li.InlineStyle.SSAEffect != "" {
+					cur.apply(li.InlineStyle.SSAEffect, base)
+				}
+				text := strings.ReplaceAll(li.Text, `\h`, " ")
+				if text == "" {
+					continue
+				}
+				if n := len(items); n > 0 && states[n-1] == cur.inlineState {
+					items[n-1].Text += text
+					continue
+				}
+				var sa *astisub.StyleAttributes
+				if cur.inlineState != (inlineState{}) {
+					sa = &astisub.StyleAttributes{}
+					setInlineStyle(sa, cur.inlineState)
+				}
+				items = append(items, astisub.LineItem{InlineStyle: sa, Text: text})
+				states = append(states, cur.inlineState)
+			}
+			item.Lines[i].Items = items
+		}
+		setAlignment(item, cur.align)
+	}
+}
+
+// setAlignment positions item at the numpad alignment a. Bottom centre is
+// the default in every format and is left unset.
+func setAlignment(item *astisub.Item, a int) {
+	if a < 1 || a > 9 || a == 2 {
+		return
+	}
+	if len(item.Lines) > 0 && len(item.Lines[0].Items) > 0 {
+		li := &item.Lines[0].Items[0]
+		if li.InlineStyle == nil {
+			li.InlineStyle = &astisub.StyleAttributes{}
+		}
+		li.InlineStyle.SRTPosition = byte(a)
+	}
+	if item.InlineStyle == nil {
+		item.InlineStyle = &astisub.StyleAttributes{}
+	}
+	switch {
+	case a >= 7:
+		item.InlineStyle.WebVTTLine = "10%"
+	case a >= 4:
+		item.InlineStyle.WebVTTLine = "50%"
+	}
+	switch a % 3 {
+	case 1:
+		item.InlineStyle.WebVTTAlign = "left"
+	case 0:
+		item.InlineStyle.WebVTTAlign = "right"
+	}
+}
+
+// alignment returns the numpad alignment of an SRT or WebVTT item, or zero
+// for the default bottom centre.
+func alignment(item *astisub.Item) int {
+	for _, l := range item.Lines {
+		for _, li := range l.Items {
+			if li.InlineStyle != nil && li.InlineStyle.SRTPosition != 0 {
+				return int(li.InlineStyle.SRTPosition)
+			}
+		}
+	}
+	sa := item.InlineStyle
+	if sa == nil && item.Style != nil {
+		sa = item.Style.InlineStyle
+	}
+	if sa == nil || (sa.WebVTTLine == "" && sa.WebVTTAlign == "") {
+		return 0
+	}
+	row := 0 // bottom
+	if line := strings.Split(sa.WebVTTLine, ",")[0]; line != "" {
+		if strings.HasSuffix(line, "%") {
+			if p, err := strconv.ParseFloat(strings.TrimSuffix(line, "%"), 64); err == nil {
+				switch {
+				case p < 34:
+					row = 2
+				case p < 67:
+					row = 1
+				}
+			}
+		} else if n, err := strconv.Atoi(line); err == nil && n >= 0 {
+			row = 2
+		}
+	}
+	col := 2
+	switch sa.WebVTTAlign {
+	case "left", "start":
+		col = 1
+	case "right", "end":
+		col = 3
+	}
+	return row*3 + col
+}
+
+// inlineToSSA rewrites the SRT and WebVTT formatting of sub as SSA override
+// tags. Each line becomes a single line item holding the tagged text. When
+// styled is false the formatting is dropped and only the text is kept.
+func inlineToSSA(sub *astisub.Subtitles, styled bool) {
+	for _, item := range sub.Items {
+		var prev inlineState
+		align := alignment(item)
+		for i, l := range item.Lines {
+			var b strings.Builder
+			if styled && i == 0 && align != 0 && align != 2 {
+				fmt.Fprintf(&b, `{\an%d}`, align)
+			}
+			for _, li := range l.Items {
+				if styled {
+					st := inlineStateOf(li.InlineStyle)
+					b.WriteString(overrideDiff(prev, st))
+					prev = st
+				}
+				b.WriteString(li.Text)
+			}
+			item.Lines[i].Items = []astisub.LineItem{{Text: b.String()}}
+		}
+	}
+}
+
+// overrideDiff returns the override block switching from formatting a to b.
+func overrideDiff(a, b inlineState) string {
+	var tags []string
+	flag := func(name string, from, to bool) {
+		if from == to {
+			return
+		}
+		if to {
+			tags = append(tags, `\`+name+"1")
+		} else {
+			tags = append(tags, `\`+name+"0")
+		}
+	}
+	flag("b", a.bold, b.bold)
+	flag("i", a.italic, b.italic)
+	flag("u", a.underline, b.underline)
+	if a.color != b.color {
+		tags = append(tags, `\c`+hexToSSAColor(b.color))
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(tags, "") + "}"
+}
+
+// prepareSSA sets the script type for target and makes sure every item has
+// an SSA style, adding a default one when sub has none.
+func prepareSSA(sub *astisub.Subtitles, target Format) {
+	if sub.Metadata == nil {
+		sub.Metadata = &astisub.Metadata{}
+	}
+	sub.Metadata.SSAScriptType = "v4.00+"
+	if target == FormatSSA {
+		sub.Metadata.SSAScriptType = "v4.00"
+	}
+
+	ssaStyles := make(map[string]*astisub.Style)
+	for id, s := range sub.Styles {
+		if s.InlineStyle != nil && (s.InlineStyle.SSAFontName != "" || s.InlineStyle.SSAFontSize != nil) {
+			ssaStyles[id] = s
+		}
+	}
+	def, ok := ssaStyles["Default"]
+	if !ok {
+		def = defaultSSAStyle()
+		ssaStyles[def.ID] = def
+	}
+	sub.Styles = ssaStyles
+	for _, item := range sub.Items {
+		if item.Style == nil || ssaStyles[item.Style.ID] != item.Style {
+			item.Style = def
+		}
+	}
+}
+
+// defaultSSAStyle returns the style used for subtitles converted from
+// formats without SSA styles: white 20pt Arial with a black outline at the
+// bottom centre.
+func defaultSSAStyle() *astisub.Style {
+	return &astisub.Style{
+		ID: "Default",
+		InlineStyle: &astisub.StyleAttributes{
+			SSAFontName:        "Arial",
+			SSAFontSize:        astikit.Float64Ptr(20),
+			SSAPrimaryColour:   &astisub.Color{Red: 255, Green: 255, Blue: 255},
+			SSASecondaryColour: &astisub.Color{Red: 255},
+			SSAOutlineColour:   &astisub.Color{},
+			SSABackColour:      &astisub.Color{},
+			SSABold:            astikit.BoolPtr(false),
+			SSAItalic:          astikit.BoolPtr(false),
+			SSAUnderline:       astikit.BoolPtr(false),
+			SSAStrikeout:       astikit.BoolPtr(false),
+			SSAScaleX:          astikit.Float64Ptr(100),
+			SSAScaleY:          astikit.Float64Ptr(100),
+			SSASpacing:         astikit.Float64Ptr(0),
+			SSAAngle:           astikit.Float64Ptr(0),
+			SSABorderStyle:     astikit.IntPtr(1),
+			SSAOutline:         astikit.Float64Ptr(2),
+			SSAShadow:          astikit.Float64Ptr(2),
+			SSAAlignment:       astikit.IntPtr(2),
+			SSAMarginLeft:      astikit.IntPtr(10),
+			SSAMarginRight:     astikit.IntPtr(10),
+			SSAMarginVertical:  astikit.IntPtr(10),
+			SSAEncoding:        astikit.IntPtr(1),
+		},
+	}
+}
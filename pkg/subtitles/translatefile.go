@@ -1,11 +1,10 @@
 // file: pkg/subtitles/translatefile.go
-// version: 1.0.0
+// version: 1.2.0
 // guid: c23af6ff-5b82-431d-9676-86c6c51ad086
 
 package subtitles
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,12 +17,53 @@ import (
 	"github.com/jdfalk/subtitle-manager/pkg/translator"
 )
 
-// TranslateFileToSRT translates the subtitle file at inPath using the
-// specified translation service and writes an SRT file to outPath.
-// googleKey, gptKey and grpcAddr are passed to the underlying provider
-// depending on the service selected. Results are cached in-memory so
-// identical lines are only translated once per invocation.
+// TranslateItems translates the cues of items into lang in place using the
+// specified translation service. Each cue is translated as a whole, with
+// inline styling such as italics and ASS override tags protected by
+// placeholder tags, and line breaks are restored at word boundaries close to
+// the original line lengths. Dialogue cues whose lines start with speaker
+// dashes are translated line by line. Identical cues are only translated
+// once, and the Google service uses its batch API when a key is set.
+func TranslateItems(items []*astisub.Item, lang, service, googleKey, gptKey, grpcAddr string) error {
+	if service == "google" && googleKey != "" {
+		return translateItems(items, func(texts []string) ([]string, error) {
+			out, err := translator.GoogleTranslateBatch(texts, lang, googleKey)
+			if err != nil {
+				return nil, fmt.Errorf("translation failed: %w", err)
+			}
+			return out, nil
+		})
+	}
+	return translateItems(items, func(texts []string) ([]string, error) {
+		out := make([]string, len(texts))
+		for i, text := range texts {
+			t, err := translator.Translate(service, text, lang, googleKey, gptKey, grpcAddr)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = t
+		}
+		return out, nil
+	})
+}
+
+// TranslateFile translates the subtitle file at inPath using the specified
+// translation service and writes the result to outPath. The output format
+// follows the extension of outPath (see Formats), so an
+// ASS input translated to an .ass output keeps its styles; unknown
+// extensions produce SRT. googleKey, gptKey and grpcAddr are passed to the
+// underlying provider depending on the service selected.
+func TranslateFile(inPath, outPath, lang, service, googleKey, gptKey, grpcAddr string) error {
+	return translateFile(inPath, outPath, filepath.Ext(outPath), lang, service, googleKey, gptKey, grpcAddr)
+}
+
+// TranslateFileToSRT translates the subtitle file at inPath like
+// TranslateFile but always writes an SRT file to outPath.
 func TranslateFileToSRT(inPath, outPath, lang, service, googleKey, gptKey, grpcAddr string) error {
+	return translateFile(inPath, outPath, ".srt", lang, service, googleKey, gptKey, grpcAddr)
+}
+
+func translateFile(inPath, outPath, ext, lang, service, googleKey, gptKey, grpcAddr string) error {
 	// Validate and sanitize input paths to prevent path injection attacks
 	validatedInPath, err := security.ValidateAndSanitizePath(inPath)
 	if err != nil {
@@ -35,112 +75,22 @@ func TranslateFileToSRT(inPath, outPath, lang, service, googleKey, gptKey, grpcA
 		return fmt.Errorf("invalid output path: %w", err)
 	}
 
-	sub, err := astisub.OpenFile(validatedInPath)
+	sub, err := Open(validatedInPath, ConvertOptions{})
 	if err != nil {
 		return err
 	}
-
-	// Use batch translation for better performance when available
-	if service == "google" && googleKey != "" {
-		return translateFileToSRTBatch(sub, outPath, lang, googleKey)
-	}
-
-	// Fallback to original implementation for other providers
-	cache := make(map[string]string, len(sub.Items))
-	for _, item := range sub.Items {
-		// Extract just the dialogue text for translation and caching,
-		// avoiding timestamps and sequence numbers that prevent deduplication
-		var dialogueText string
-		if len(item.Lines) > 0 && len(item.Lines[0].Items) > 0 {
-			dialogueText = item.Lines[0].Items[0].Text
-		}
-
-		// Skip empty dialogue
-		if dialogueText == "" {
-			continue
-		}
-
-		// Check cache for existing translation
-		t, ok := cache[dialogueText]
-		if !ok {
-			t, err = translator.Translate(service, dialogueText, lang, googleKey, gptKey, grpcAddr)
-			if err != nil {
-				return err
-			}
-			cache[dialogueText] = t
-		}
-		item.Lines = []astisub.Line{{Items: []astisub.LineItem{{Text: t}}}}
-	}
-	buf := &bytes.Buffer{}
-	if err := sub.WriteToSRT(buf); err != nil {
+	if err := TranslateItems(sub.Items, lang, service, googleKey, gptKey, grpcAddr); err != nil {
 		return err
 	}
-	return os.WriteFile(validatedOutPath, buf.Bytes(), 0644)
-}
-
-// translateFileToSRTBatch uses batch Google Translate API for improved performance.
-// It groups unique texts and translates them in batches to reduce API calls.
-func translateFileToSRTBatch(sub *astisub.Subtitles, outPath, lang, googleKey string) error {
-
-	// Extract unique dialogue texts and their positions
-	textToItems := make(map[string][]*astisub.Item)
-	uniqueTexts := make([]string, 0)
-
-	for _, item := range sub.Items {
-		var dialogueText string
-		if len(item.Lines) > 0 && len(item.Lines[0].Items) > 0 {
-			dialogueText = item.Lines[0].Items[0].Text
-		}
-
-		// Skip empty dialogue
-		if dialogueText == "" {
-			continue
-		}
-
-		// Track which items use this text
-		if _, exists := textToItems[dialogueText]; !exists {
-			uniqueTexts = append(uniqueTexts, dialogueText)
-		}
-		textToItems[dialogueText] = append(textToItems[dialogueText], item)
-	}
-
-	// If no texts to translate, return early
-	if len(uniqueTexts) == 0 {
-		buf := &bytes.Buffer{}
-		if err := sub.WriteToSRT(buf); err != nil {
-			return err
-		}
-		return os.WriteFile(outPath, buf.Bytes(), 0644)
-	}
-
-	// Translate all unique texts using the batch API
-	translatedSlice, err := translator.GoogleTranslateBatch(uniqueTexts, lang, googleKey)
+	format, err := ParseFormat(ext)
 	if err != nil {
-		return fmt.Errorf("translation failed: %w", err)
-	}
-	translations := make(map[string]string, len(uniqueTexts))
-	for i, text := range uniqueTexts {
-		translations[text] = translatedSlice[i]
-	}
-
-	// Apply translations to subtitle items
-	for originalText, items := range textToItems {
-		translatedText, ok := translations[originalText]
-		if !ok {
-			continue
-		}
-
-		for _, item := range items {
-			item.Lines = []astisub.Line{{Items: []astisub.LineItem{{Text: translatedText}}}}
-		}
+		format = FormatSRT
 	}
-
-	// Write result
-	buf := &bytes.Buffer{}
-	if err := sub.WriteToSRT(buf); err != nil {
+	data, err := Encode(sub, format, ConvertOptions{})
+	if err != nil {
 		return err
 	}
-	return os.WriteFile(outPath, buf.Bytes(), 0644)
+	return os.WriteFile(validatedOutPath, data, 0644)
 }
 
 // TranslateFilesToSRT concurrently translates each file in paths using
@@ -158,3 +108,20 @@ func TranslateFilesToSRT(paths []string, lang, service, googleKey, gptKey, grpcA
 	}
 	return p.Wait()
 }
+
+// TranslateFiles concurrently translates each file in paths using
+// TranslateFile. Output files are written next to the inputs in the input
+// format with the language code appended before the extension. The number
+// of worker goroutines is limited by workers.
+func TranslateFiles(paths []string, lang, service, googleKey, gptKey, grpcAddr string, workers int) error {
+	p := pool.New().WithErrors().WithMaxGoroutines(workers)
+	for _, in := range paths {
+		in := in
+		ext := filepath.Ext(in)
+		out := strings.TrimSuffix(in, ext) + "." + lang + ext
+		p.Go(func() error {
+			return TranslateFile(in, out, lang, service, googleKey, gptKey, grpcAddr)
+		})
+	}
+	return p.Wait()
+}
@@ -0,0 +1,412 @@
+// file: pkg/syncer/drift.go
+
+package syncer
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/asticode/go-astisub"
+)
+
+const (
+	// ModeOffset aligns subtitles with a single constant offset. This is the
+	// default when Options.Mode is empty.
+	ModeOffset = "offset"
+	// ModePiecewise aligns every cue against the reference and produces a
+	// piecewise-linear time map that handles framerate drift and cuts.
+	ModePiecewise = "piecewise"
+)
+
+// Defaults used by piecewise alignment when the corresponding Options field
+// is zero.
+const (
+	defaultMatchTolerance = time.Second
+	defaultSplitPenalty   = 4.0
+	defaultMaxSegments    = 8
+	defaultMinSegmentCues = 10
+)
+
+// maxScaleDeviation bounds the scale factor a refined segment may use.
+// Framerate conversions stay well inside this range, so anything beyond it is
+// treated as a bad fit.
+const maxScaleDeviation = 0.15
+
+// framerateRatios lists the scale factors produced by common framerate
+// conversions (PAL speed-up, NTSC pulldown and their inverses).
+var framerateRatios = []float64{
+	1,
+	25 / 23.976, 23.976 / 25,
+	25 / 24.0, 24 / 25.0,
+	24 / 23.976, 23.976 / 24,
+}
+
+// Segment describes one linear piece of a time map. Cues of the original
+// subtitle from FirstCue to LastCue are remapped to Scale*t + Offset.
+type Segment struct {
+	// Start and End delimit the segment on the original subtitle timeline.
+	Start time.Duration
+	End   time.Duration
+	// FirstCue and LastCue are zero-based indices of the cues covered.
+	FirstCue int
+	LastCue  int
+	// Scale is the drift factor; 1 means no drift.
+	Scale float64
+	// Offset is added after scaling.
+	Offset time.Duration
+	// Matched counts cues aligned to a reference cue within tolerance.
+	Matched int
+}
+
+// Map returns t remapped by the segment's linear function.
+func (s Segment) Map(t time.Duration) time.Duration {
+	return time.Duration(s.Scale*float64(t)) + s.Offset
+}
+
+// TimeMap is a piecewise-linear mapping from the original subtitle timeline to
+// the reference timeline. Segments are ordered by Start.
+type TimeMap []Segment
+
+// Apply remaps t using the segment covering it. Times before the first
+// segment use the first segment and times after the last use the last.
+func (m TimeMap) Apply(t time.Duration) time.Duration {
+	if len(m) == 0 {
+		return t
+	}
+	return m[m.index(t)].Map(t)
+}
+
+// index returns the position of the segment responsible for t.
+func (m TimeMap) index(t time.Duration) int {
+	for i := len(m) - 1; i > 0; i-- {
+		if t >= m[i].Start {
+			return i
+		}
+	}
+	return 0
+}
+
+// ApplyTimeMap returns a copy of items with start and end times remapped by m.
+// Both ends of a cue use the segment selected by its start time so durations
+// are scaled consistently across split points.
+func ApplyTimeMap(items []*astisub.Item, m TimeMap) []*astisub.Item {
+	out := make([]*astisub.Item, len(items))
+	for i, it := range items {
+		c := *it
+		if len(m) > 0 {
+			seg := m[m.index(it.StartAt)]
+			c.StartAt = seg.Map(it.StartAt)
+			c.EndAt = seg.Map(it.EndAt)
+		}
+		out[i] = &c
+	}
+	return out
+}
+
+// linearModel maps a time in seconds to scale*t + offset.
+type linearModel struct {
+	scale  float64
+	offset float64
+}
+
+func (m linearModel) at(t float64) float64 {
+	return m.scale*t + m.offset
+}
+
+// AlignPiecewise aligns every cue in target against the cues in ref and
+// returns the resulting time map. Candidate linear models are generated from
+// local windows of the target using the common framerate ratios, every cue is
+// assigned a model with dynamic programming where switching models costs
+// opts.SplitPenalty, and each resulting segment is refined with a least
+// squares fit over its matched cues. A nil map is returned when either input
+// is empty.
+func AlignPiecewise(ref, target []*astisub.Item, opts Options) TimeMap {
+	if len(ref) == 0 || len(target) == 0 {
+		return nil
+	}
+	tol := opts.MatchTolerance
+	if tol <= 0 {
+		tol = defaultMatchTolerance
+	}
+	penalty := opts.SplitPenalty
+	if penalty <= 0 {
+		penalty = defaultSplitPenalty
+	}
+	maxSegments := opts.MaxSegments
+	if maxSegments <= 0 {
+		maxSegments = defaultMaxSegments
+	}
+	minCues := opts.MinSegmentCues
+	if minCues <= 0 {
+		minCues = defaultMinSegmentCues
+	}
+
+	refStarts := make([]float64, len(ref))
+	for i, it := range ref {
+		refStarts[i] = it.StartAt.Seconds()
+	}
+	sort.Float64s(refStarts)
+	tgtStarts := make([]float64, len(target))
+	for i, it := range target {
+		tgtStarts[i] = it.StartAt.Seconds()
+	}
+
+	a := aligner{ref: refStarts, tgt: tgtStarts, tol: tol.Seconds()}
+	models := a.candidates()
+	if len(models) == 0 {
+		off := computeOffset(ref, target)
+		return TimeMap{{
+			Start:   target[0].StartAt,
+			End:     target[len(target)-1].EndAt,
+			LastCue: len(target) - 1,
+			Scale:   1,
+			Offset:  off,
+		}}
+	}
+
+	var runs []run
+	for attempt := 0; attempt < 10; attempt++ {
+		runs = a.segment(models, penalty, minCues)
+		if len(runs) <= maxSegments {
+			break
+		}
+		penalty *= 2
+	}
+
+	tm := make(TimeMap, len(runs))
+	for i, r := range runs {
+		m, matched := a.refine(r)
+		tm[i] = Segment{
+			Start:    target[r.first].StartAt,
+			End:      target[r.last].EndAt,
+			FirstCue: r.first,
+			LastCue:  r.last,
+			Scale:    m.scale,
+			Offset:   time.Duration(m.offset * float64(time.Second)),
+			Matched:  matched,
+		}
+	}
+	return tm
+}
+
+// run is a contiguous range of target cues sharing one model.
+type run struct {
+	first, last int
+	model       linearModel
+}
+
+// aligner holds the sorted reference starts and target starts in seconds.
+type aligner struct {
+	ref []float64
+	tgt []float64
+	tol float64
+}
+
+// nearest returns the reference start closest to x and its distance.
+func (a aligner) nearest(x float64) (float64, float64) {
+	i := sort.SearchFloat64s(a.ref, x)
+	best, bestDist := 0.0, math.Inf(1)
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(a.ref) {
+			continue
+		}
+		if d := math.Abs(a.ref[j] - x); d < bestDist {
+			best, bestDist = a.ref[j], d
+		}
+	}
+	return best, bestDist
+}
+
+// cost returns the normalized mismatch of target cue i under m, capped at 1
+// so cues without a counterpart do not dominate.
+func (a aligner) cost(i int, m linearModel) float64 {
+	_, d := a.nearest(m.at(a.tgt[i]))
+	if d >= a.tol {
+		return 1
+	}
+	return d / a.tol
+}
+
+// candidates proposes linear models by finding the densest offset cluster
+// for each framerate ratio over sliding windows of the target.
+func (a aligner) candidates() []linearModel {
+	const window, step = 20, 10
+	var out []linearModel
+	for start := 0; start < len(a.tgt); start += step {
+		end := start + window
+		if end > len(a.tgt) {
+			end = len(a.tgt)
+		}
+		for _, scale := range framerateRatios {
+			off, votes := a.bestOffset(a.tgt[start:end], scale)
+			if votes < 2 && len(a.tgt) > 1 {
+				continue
+			}
+			out = appendModel(out, linearModel{scale: scale, offset: off}, a.tol/2)
+		}
+		if end == len(a.tgt) {
+			break
+		}
+	}
+	return out
+}
+
+// bestOffset returns the median of the densest cluster of offsets
+// ref - scale*t with width tol, along with the cluster size.
+func (a aligner) bestOffset(tgt []float64, scale float64) (float64, int) {
+	offs := make([]float64, 0, len(tgt)*len(a.ref))
+	for _, t := range tgt {
+		for _, r := range a.ref {
+			offs = append(offs, r-scale*t)
+		}
+	}
+	if len(offs) == 0 {
+		return 0, 0
+	}
+	sort.Float64s(offs)
+	bestLo, bestHi := 0, 0
+	lo := 0
+	for hi := range offs {
+		for offs[hi]-offs[lo] > a.tol {
+			lo++
+		}
+		if hi-lo > bestHi-bestLo {
+			bestLo, bestHi = lo, hi
+		}
+	}
+	return offs[(bestLo+bestHi)/2], bestHi - bestLo + 1
+}
+
+// appendModel adds m unless an equivalent model is already present.
+func appendModel(models []linearModel, m linearModel, eps float64) []linearModel {
+	for _, o := range models {
+		if o.scale == m.scale && math.Abs(o.offset-m.offset) < eps {
+			return models
+		}
+	}
+	return append(models, m)
+}
+
+// segment assigns a model to every target cue using dynamic programming and
+// returns the resulting runs with short runs merged into their neighbours.
+func (a aligner) segment(models []linearModel, penalty float64, minCues int) []run {
+	n, k := len(a.tgt), len(models)
+	back := make([][]int32, n)
+	prev := make([]float64, k)
+	cur := make([]float64, k)
+	for j, m := range models {
+		prev[j] = a.cost(0, m)
+	}
+	for i := 1; i < n; i++ {
+		best := 0
+		for j := range prev {
+			if prev[j] < prev[best] {
+				best = j
+			}
+		}
+		back[i] = make([]int32, k)
+		for j, m := range models {
+			stay, sw := prev[j], prev[best]+penalty
+			if sw < stay {
+				cur[j] = sw
+				back[i][j] = int32(best)
+			} else {
+				cur[j] = stay
+				back[i][j] = int32(j)
+			}
+			cur[j] += a.cost(i, m)
+		}
+		prev, cur = cur, prev
+	}
+
+	path := make([]int, n)
+	last := 0
+	for j := range prev {
+		if prev[j] < prev[last] {
+			last = j
+		}
+	}
+	path[n-1] = last
+	for i := n - 1; i > 0; i-- {
+		path[i-1] = int(back[i][path[i]])
+	}
+
+	var runs []run
+	for i, j := range path {
+		if len(runs) > 0 && runs[len(runs)-1].model == models[j] {
+			runs[len(runs)-1].last = i
+			continue
+		}
+		runs = append(runs, run{first: i, last: i, model: models[j]})
+	}
+	return mergeShortRuns(runs, minCues)
+}
+
+// mergeShortRuns folds runs shorter than minCues into the preceding run, or
+// the following one for a short leading run, until none remain.
+func mergeShortRuns(runs []run, minCues int) []run {
+	for len(runs) > 1 {
+		idx := -1
+		for i, r := range runs {
+			if r.last-r.first+1 < minCues {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			break
+		}
+		if idx == 0 {
+			runs[1].first = runs[0].first
+			runs = runs[1:]
+			continue
+		}
+		runs[idx-1].last = runs[idx].last
+		runs = append(runs[:idx], runs[idx+1:]...)
+		// Neighbours may now share a model after the merge.
+		if idx < len(runs) && runs[idx].model == runs[idx-1].model {
+			runs[idx-1].last = runs[idx].last
+			runs = append(runs[:idx], runs[idx+1:]...)
+		}
+	}
+	return runs
+}
+
+// refine fits a line through the matched cues of r and returns it with the
+// number of matches. The run's model is kept when the fit is degenerate or
+// implies an implausible scale.
+func (a aligner) refine(r run) (linearModel, int) {
+	var xs, ys []float64
+	for i := r.first; i <= r.last; i++ {
+		y, d := a.nearest(r.model.at(a.tgt[i]))
+		if d < a.tol {
+			xs = append(xs, a.tgt[i])
+			ys = append(ys, y)
+		}
+	}
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return r.model, len(xs)
+	}
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+	var cov, vx float64
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+		vx += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if vx < 1e-9 {
+		return linearModel{scale: r.model.scale, offset: my - r.model.scale*mx}, len(xs)
+	}
+	scale := cov / vx
+	if math.Abs(scale-1) > maxScaleDeviation {
+		return r.model, len(xs)
+	}
+	return linearModel{scale: scale, offset: my - scale*mx}, len(xs)
+}
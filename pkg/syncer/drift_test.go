@@ -0,0 +1,161 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astisub"
+
+	"github.com/jdfalk/subtitle-manager/pkg/syncer/mocks"
+)
+
+// makeCues builds n cues with irregular spacing so offsets are unambiguous.
+func makeCues(n int) []*astisub.Item {
+	items := make([]*astisub.Item, n)
+	t := 5 * time.Second
+	for i := range items {
+		items[i] = &astisub.Item{StartAt: t, EndAt: t + 1500*time.Millisecond}
+		t += time.Duration(2000+(i*379)%2300) * time.Millisecond
+	}
+	return items
+}
+
+// mapCues applies fn to the start and end of each cue.
+func mapCues(items []*astisub.Item, fn func(i int, t time.Duration) time.Duration) []*astisub.Item {
+	out := make([]*astisub.Item, len(items))
+	for i, it := range items {
+		out[i] = &astisub.Item{StartAt: fn(i, it.StartAt), EndAt: fn(i, it.EndAt)}
+	}
+	return out
+}
+
+// TestAlignPiecewiseLinearDrift verifies that PAL speed-up plus an offset is
+// detected as a single scaled segment.
+func TestAlignPiecewiseLinearDrift(t *testing.T) {
+	target := makeCues(200)
+	scale := 25 / 23.976
+	ref := mapCues(target, func(_ int, d time.Duration) time.Duration {
+		return time.Duration(scale*float64(d)) + 2*time.Second
+	})
+
+	tm := AlignPiecewise(ref, target, Options{})
+	if len(tm) != 1 {
+		t.Fatalf("expected 1 segment, got %d: %+v", len(tm), tm)
+	}
+	if d := tm[0].Scale - scale; d > 1e-4 || d < -1e-4 {
+		t.Fatalf("expected scale %.5f, got %.5f", scale, tm[0].Scale)
+	}
+	out := ApplyTimeMap(target, tm)
+	for i := range out {
+		if diff := out[i].StartAt - ref[i].StartAt; diff > 20*time.Millisecond || diff < -20*time.Millisecond {
+			t.Fatalf("cue %d misaligned by %v", i, diff)
+		}
+	}
+}
+
+// TestAlignPiecewiseSplit verifies that a discontinuity introduced by a cut is
+// detected as a split point.
+func TestAlignPiecewiseSplit(t *testing.T) {
+	target := makeCues(120)
+	ref := mapCues(target, func(i int, d time.Duration) time.Duration {
+		if i < 60 {
+			return d + time.Second
+		}
+		return d + 8*time.Second
+	})
+
+	tm := AlignPiecewise(ref, target, Options{})
+	if len(tm) != 2 {
+		t.Fatalf("expected 2 segments, got %d: %+v", len(tm), tm)
+	}
+	if tm[1].FirstCue != 60 {
+		t.Fatalf("expected split at cue 60, got %d", tm[1].FirstCue)
+	}
+	out := ApplyTimeMap(target, tm)
+	for i := range out {
+		if diff := out[i].StartAt - ref[i].StartAt; diff > 20*time.Millisecond || diff < -20*time.Millisecond {
+			t.Fatalf("cue %d misaligned by %v", i, diff)
+		}
+	}
+}
+
+// TestAlignPiecewiseMaxSegments verifies the segment limit is honoured.
+func TestAlignPiecewiseMaxSegments(t *testing.T) {
+	target := makeCues(120)
+	ref := mapCues(target, func(i int, d time.Duration) time.Duration {
+		return d + time.Duration(i/30)*5*time.Second
+	})
+
+	tm := AlignPiecewise(ref, target, Options{MaxSegments: 2})
+	if len(tm) > 2 {
+		t.Fatalf("expected at most 2 segments, got %d", len(tm))
+	}
+}
+
+// TestAlignPiecewiseEmpty verifies empty inputs yield an identity map.
+func TestAlignPiecewiseEmpty(t *testing.T) {
+	if tm := AlignPiecewise(nil, makeCues(3), Options{}); tm != nil {
+		t.Fatalf("expected nil map, got %+v", tm)
+	}
+	if got := TimeMap(nil).Apply(time.Second); got != time.Second {
+		t.Fatalf("expected identity, got %v", got)
+	}
+}
+
+// TestSyncWithReportPiecewise verifies Sync uses the piecewise mode and
+// reports the detected segments.
+func TestSyncWithReportPiecewise(t *testing.T) {
+	target := makeCues(80)
+	dir := t.TempDir()
+	subFile := filepath.Join(dir, "test.srt")
+	f, err := os.Create(subFile)
+	if err != nil {
+		t.Fatalf("create temp: %v", err)
+	}
+	withText := make([]*astisub.Item, len(target))
+	for i, it := range target {
+		c := *it
+		c.Lines = []astisub.Line{{Items: []astisub.LineItem{{Text: "line"}}}}
+		withText[i] = &c
+	}
+	if err := (&astisub.Subtitles{Items: withText}).WriteToSRT(f); err != nil {
+		t.Fatalf("write SRT: %v", err)
+	}
+	f.Close()
+
+	ref := mapCues(target, func(i int, d time.Duration) time.Duration {
+		if i < 40 {
+			return d + 500*time.Millisecond
+		}
+		return d + 6*time.Second
+	})
+	mockExtractor := mocks.NewSubtitleExtractor(t)
+	mockExtractor.EXPECT().ExtractTrack("dummy.mkv", 0).Return(ref, nil)
+
+	items, report, err := SyncWithReport("dummy.mkv", subFile, Options{
+		UseEmbedded:       true,
+		Mode:              ModePiecewise,
+		SubtitleExtractor: mockExtractor,
+	})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if report.Mode != ModePiecewise || report.Reference != "embedded:0" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.Segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(report.Segments))
+	}
+	if diff := items[79].StartAt - ref[79].StartAt; diff > 20*time.Millisecond || diff < -20*time.Millisecond {
+		t.Fatalf("last cue misaligned by %v", diff)
+	}
+}
+
+// TestSyncUnknownMode verifies invalid modes are rejected.
+func TestSyncUnknownMode(t *testing.T) {
+	if _, err := Sync("dummy.mkv", "../../testdata/simple.srt", Options{Mode: "bogus"}); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
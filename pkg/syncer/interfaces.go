@@ -3,6 +3,8 @@
 package syncer
 
 import (
+	"time"
+
 	"github.com/asticode/go-astisub"
 )
 
@@ -32,3 +34,17 @@ type SubtitleExtractor interface {
 	//   - error: any error that occurred during extraction
 	ExtractTrack(mediaPath string, track int) ([]*astisub.Item, error)
 }
+
+// SpeechDetector interface defines the contract for offline voice activity
+// detection used by the VAD audio method.
+type SpeechDetector interface {
+	// DetectSpeech analyzes an audio track of the media file.
+	// Parameters:
+	//   - mediaPath: path to the media file
+	//   - track: zero-based index of the audio track to analyze
+	// Returns:
+	//   - []bool: one speech flag per analysis frame
+	//   - time.Duration: duration of each analysis frame
+	//   - error: any error that occurred during decoding or detection
+	DetectSpeech(mediaPath string, track int) ([]bool, time.Duration, error)
+}
@@ -6,6 +6,7 @@ package syncer
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"sort"
 	"time"
@@ -16,7 +17,6 @@ import (
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
 	"github.com/jdfalk/subtitle-manager/pkg/transcriber"
-	"github.com/jdfalk/subtitle-manager/pkg/translator"
 )
 
 // Options controls how the synchronization process behaves.
@@ -29,6 +29,14 @@ type Options struct {
 	AudioTrack int
 	// SubtitleTracks selects embedded subtitle track indices when UseEmbedded is true.
 	SubtitleTracks []int
+	// AudioMethod selects how the audio track is used when UseAudio is
+	// true. AudioMethodWhisper (the default) aligns against a Whisper
+	// transcript; AudioMethodVAD aligns against locally detected speech
+	// and needs no API key or transcription container.
+	AudioMethod string
+	// MaxOffset bounds the offset searched by the VAD audio method. When
+	// zero, 60 seconds is used.
+	MaxOffset time.Duration
 	// WhisperKey provides the API key for audio transcription when
 	// UseAudio is true.
 	WhisperKey string
@@ -67,6 +75,52 @@ type Options struct {
 	// SubtitleExtractor is the service used for extracting embedded subtitles.
 	// If nil, the default subtitles.ExtractSubtitleTrack will be used.
 	SubtitleExtractor SubtitleExtractor
+	// SpeechDetector is the voice activity detector used by the VAD audio
+	// method. If nil, audio.SpeechActivity will be used.
+	SpeechDetector SpeechDetector
+
+	// Mode selects the alignment strategy: ModeOffset (the default) applies
+	// one constant offset, ModePiecewise builds a piecewise-linear time map
+	// that corrects framerate drift and cut-scene edits.
+	Mode string
+	// MatchTolerance is the maximum distance between an aligned cue and a
+	// reference cue for the pair to count as a match in piecewise mode.
+	// When zero, one second is used.
+	MatchTolerance time.Duration
+	// SplitPenalty is the cost of starting a new segment in piecewise mode,
+	// measured in unmatched cues. Higher values yield fewer segments. When
+	// zero, 4 is used.
+	SplitPenalty float64
+	// MaxSegments limits the number of segments produced in piecewise mode.
+	// When zero, 8 is used.
+	MaxSegments int
+	// MinSegmentCues is the minimum number of cues a segment must cover in
+	// piecewise mode. Shorter segments are merged into a neighbour. When
+	// zero, 10 is used.
+	MinSegmentCues int
+}
+
+// Report summarizes how SyncWithReport aligned a subtitle.
+type Report struct {
+	// Mode is the alignment strategy that produced the result.
+	Mode string
+	// Reference names the timing source used for piecewise alignment,
+	// for example "audio" or "embedded:0".
+	Reference string
+	// Offset is the constant offset applied in offset mode.
+	Offset time.Duration
+	// Scale is the framerate correction applied before Offset by the VAD
+	// audio method. It is 1 when no correction was needed.
+	Scale float64
+	// Segments lists the linear pieces detected in piecewise mode.
+	Segments TimeMap
+}
+
+// reference is a timing source collected during synchronization.
+type reference struct {
+	name   string
+	items  []*astisub.Item
+	weight float64
 }
 
 // defaultTranscriber wraps the transcriber.WhisperTranscribe function to implement the Transcriber interface.
@@ -90,17 +144,39 @@ func (d defaultSubtitleExtractor) ExtractTrack(mediaPath string, track int) ([]*
 //
 // The function supports multiple synchronization methods:
 // - Audio transcription via Whisper API for precise timing alignment
+// - Offline voice activity detection correlated against cue timing
 // - Embedded subtitle tracks for reference timing
 // - Weighted combination of both methods for optimal results
 // - Optional translation of synchronized subtitles
 func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
+	items, _, err := SyncWithReport(mediaPath, subPath, opts)
+	return items, err
+}
+
+// SyncWithReport behaves like Sync and additionally returns a Report
+// describing the offset or the piecewise segments that were applied.
+//
+// In piecewise mode the reference with the highest weight is used as the
+// timing source instead of averaging offsets across references.
+func SyncWithReport(mediaPath, subPath string, opts Options) ([]*astisub.Item, *Report, error) {
 	logger := logging.GetLogger("syncer")
 	logger.Infof("starting subtitle sync: %s with %s", subPath, mediaPath)
 
+	switch opts.Mode {
+	case "", ModeOffset, ModePiecewise:
+	default:
+		return nil, nil, fmt.Errorf("unknown sync mode %q", opts.Mode)
+	}
+	switch opts.AudioMethod {
+	case "", AudioMethodWhisper, AudioMethodVAD:
+	default:
+		return nil, nil, fmt.Errorf("unknown audio method %q", opts.AudioMethod)
+	}
+
 	sub, err := astisub.OpenFile(subPath)
 	if err != nil {
 		logger.Errorf("failed to open subtitle file: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 	items := make([]*astisub.Item, len(sub.Items))
 	copy(items, sub.Items)
@@ -114,6 +190,7 @@ func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
 
 	var total time.Duration
 	var applied float64
+	var refs []reference
 
 	// Use injected transcriber or fall back to default
 	transcriber := opts.Transcriber
@@ -127,7 +204,30 @@ func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
 		extractor = defaultSubtitleExtractor{}
 	}
 
-	if opts.UseAudio {
+	// Use injected speech detector or fall back to default
+	detector := opts.SpeechDetector
+	if detector == nil {
+		detector = defaultSpeechDetector{}
+	}
+
+	report := &Report{Mode: ModeOffset, Scale: 1}
+
+	if opts.UseAudio && opts.AudioMethod == AudioMethodVAD {
+		logger.Infof("using voice activity detection (track %d, weight %.1f)", opts.AudioTrack, weight)
+		speech, frame, err := detector.DetectSpeech(mediaPath, opts.AudioTrack)
+		if err != nil {
+			logger.Warnf("voice activity detection failed: %v", err)
+		} else if al, err := alignToSpeech(speech, frame, items, opts.MaxOffset); err != nil {
+			logger.Warnf("speech alignment failed: %v", err)
+		} else {
+			if al.scale != 1 {
+				items = ApplyTimeMap(items, TimeMap{{Scale: al.scale}})
+				report.Scale = al.scale
+			}
+			total += time.Duration(float64(al.offset) * weight)
+			applied += weight
+		}
+	} else if opts.UseAudio {
 		logger.Infof("using audio transcription (track %d, weight %.1f)", opts.AudioTrack, weight)
 		// Extract specific audio track for transcription
 		audioFile, err := audio.ExtractTrack(mediaPath, opts.AudioTrack)
@@ -146,6 +246,7 @@ func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
 				off := computeOffset(refSub.Items, items)
 				total += time.Duration(float64(off) * weight)
 				applied += weight
+				refs = append(refs, reference{name: "audio", items: refSub.Items, weight: weight})
 			}
 		}
 	}
@@ -170,6 +271,7 @@ func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
 					off := computeOffset(refItems, items)
 					total += time.Duration(float64(off) * per)
 					applied += per
+					refs = append(refs, reference{name: fmt.Sprintf("embedded:%d", t), items: refItems, weight: per})
 				}
 			}
 		}
@@ -179,13 +281,36 @@ func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
 	if !opts.UseAudio && !opts.UseEmbedded {
 		refItems, err := extractor.ExtractTrack(mediaPath, 0)
 		if err == nil {
-			offset := computeOffset(refItems, items)
-			items = Shift(items, offset)
+			if opts.Mode == ModePiecewise {
+				refs = append(refs, reference{name: "embedded:0", items: refItems, weight: 1})
+			} else {
+				offset := computeOffset(refItems, items)
+				items = Shift(items, offset)
+				report.Offset = offset
+			}
 		}
-	} else if applied > 0 {
-		// Apply weighted offset if using audio and/or embedded methods
+	} else if applied > 0 && (opts.Mode != ModePiecewise || len(refs) == 0) {
+		// Apply weighted offset if using audio and/or embedded methods.
+		// Piecewise mode needs cue-level references, so speech alignment
+		// alone falls back to the weighted offset.
 		offset := time.Duration(float64(total) / applied)
 		items = Shift(items, offset)
+		report.Offset = offset
+	}
+
+	if opts.Mode == ModePiecewise && len(refs) > 0 {
+		best := refs[0]
+		for _, r := range refs[1:] {
+			if r.weight > best.weight {
+				best = r
+			}
+		}
+		tm := AlignPiecewise(best.items, items, opts)
+		items = ApplyTimeMap(items, tm)
+		report.Mode = ModePiecewise
+		report.Reference = best.name
+		report.Segments = tm
+		logger.Infof("piecewise alignment against %s detected %d segment(s)", best.name, len(tm))
 	}
 
 	// Apply translation if requested (supports both legacy and new translation options)
@@ -196,21 +321,10 @@ func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
 			service = "google"
 		}
 
-		for _, item := range items {
-			for i, line := range item.Lines {
-				for j, lineItem := range line.Items {
-					if lineItem.Text != "" {
-						translated, err := translator.Translate(service, lineItem.Text, opts.TranslateLang,
-							opts.GoogleAPIKey, opts.GPTAPIKey, opts.GRPCAddr)
-						if err == nil {
-							lineItem.Text = translated
-							line.Items[j] = lineItem
-						}
-						// Silently continue on translation errors to avoid breaking sync
-					}
-				}
-				item.Lines[i] = line
-			}
+		// Translation errors are logged but do not break the sync
+		if err := subtitles.TranslateItems(items, opts.TranslateLang, service,
+			opts.GoogleAPIKey, opts.GPTAPIKey, opts.GRPCAddr); err != nil {
+			logger.Warnf("translate subtitles: %v", err)
 		}
 	}
 
@@ -218,10 +332,10 @@ func Sync(mediaPath, subPath string, opts Options) ([]*astisub.Item, error) {
 	if opts.TargetLang != "" {
 		items, err = Translate(items, opts.TargetLang, opts.Service, opts.GoogleKey, opts.GPTKey, opts.GRPCAddr)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
-	return items, nil
+	return items, report, nil
 }
 
 // Shift adjusts each subtitle item by offset and returns the updated slice.
@@ -259,18 +373,17 @@ func computeOffset(ref, target []*astisub.Item) time.Duration {
 
 // Translate converts each subtitle item to lang using the selected service.
 // googleKey, gptKey and grpcAddr are passed to the underlying translator
-// depending on service. The returned slice contains translated items in the
-// same order as the input.
+// depending on service. Cues keep their inline styling and line structure
+// as described for subtitles.TranslateItems. The returned slice contains
+// translated copies of the items in the same order as the input.
 func Translate(items []*astisub.Item, lang, service, googleKey, gptKey, grpcAddr string) ([]*astisub.Item, error) {
 	out := make([]*astisub.Item, len(items))
 	for i, it := range items {
-		t, err := translator.Translate(service, it.String(), lang, googleKey, gptKey, grpcAddr)
-		if err != nil {
-			return nil, err
-		}
 		c := *it
-		c.Lines = []astisub.Line{{Items: []astisub.LineItem{{Text: t}}}}
 		out[i] = &c
 	}
+	if err := subtitles.TranslateItems(out, lang, service, googleKey, gptKey, grpcAddr); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
@@ -0,0 +1,184 @@
+// file: pkg/syncer/vad.go
+
+package syncer
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/asticode/go-astisub"
+
+	"github.com/jdfalk/subtitle-manager/pkg/audio"
+)
+
+const (
+	// AudioMethodWhisper transcribes the audio track with Whisper and aligns
+	// against the transcript. This is the default when Options.AudioMethod
+	// is empty.
+	AudioMethodWhisper = "whisper"
+	// AudioMethodVAD aligns cues against locally detected speech activity
+	// and needs no transcription service.
+	AudioMethodVAD = "vad"
+)
+
+// defaultMaxOffset is the offset search range used by the VAD method when
+// Options.MaxOffset is zero.
+const defaultMaxOffset = 60 * time.Second
+
+// defaultSpeechDetector wraps audio.SpeechActivity to implement the SpeechDetector interface.
+type defaultSpeechDetector struct{}
+
+// DetectSpeech implements the SpeechDetector interface using ffmpeg extraction and the pure Go detector.
+func (d defaultSpeechDetector) DetectSpeech(mediaPath string, track int) ([]bool, time.Duration, error) {
+	return audio.SpeechActivity(mediaPath, track, audio.VADOptions{})
+}
+
+// speechAlignment is the best linear fit of subtitle cues to detected speech.
+type speechAlignment struct {
+	scale  float64
+	offset time.Duration
+	score  float64
+}
+
+// alignToSpeech cross-correlates the speech/non-speech signal with the
+// cue-on/cue-off signal of items for each common framerate ratio and returns
+// the scale and offset with the highest correlation. Offsets are limited to
+// ±maxOffset.
+func alignToSpeech(speech []bool, frame time.Duration, items []*astisub.Item, maxOffset time.Duration) (speechAlignment, error) {
+	if frame <= 0 {
+		return speechAlignment{}, errors.New("invalid frame duration")
+	}
+	hasSpeech := false
+	for _, s := range speech {
+		if s {
+			hasSpeech = true
+			break
+		}
+	}
+	if !hasSpeech {
+		return speechAlignment{}, errors.New("no speech detected")
+	}
+	if len(items) == 0 {
+		return speechAlignment{}, errors.New("no subtitle items to align")
+	}
+	if maxOffset <= 0 {
+		maxOffset = defaultMaxOffset
+	}
+	maxLag := int(maxOffset / frame)
+
+	var maxEnd time.Duration
+	for _, it := range items {
+		if it.EndAt > maxEnd {
+			maxEnd = it.EndAt
+		}
+	}
+	var longest int
+	for _, scale := range framerateRatios {
+		if n := int(time.Duration(scale*float64(maxEnd))/frame) + 1; n > longest {
+			longest = n
+		}
+	}
+	if len(speech) > longest {
+		longest = len(speech)
+	}
+	size := 1
+	for size < longest+maxLag+1 {
+		size <<= 1
+	}
+	roots := fftRoots(size)
+
+	sig := make([]complex128, size)
+	for i, s := range speech {
+		if s {
+			sig[i] = 1
+		} else {
+			sig[i] = -1
+		}
+	}
+	fft(sig, roots, false)
+
+	best := speechAlignment{score: math.Inf(-1)}
+	buf := make([]complex128, size)
+	for _, scale := range framerateRatios {
+		for i := range buf {
+			buf[i] = 0
+		}
+		end := int(time.Duration(scale*float64(maxEnd)) / frame)
+		for i := 0; i <= end; i++ {
+			buf[i] = -1
+		}
+		for _, it := range items {
+			from := int(time.Duration(scale*float64(it.StartAt)) / frame)
+			to := int(time.Duration(scale*float64(it.EndAt)) / frame)
+			for i := max(from, 0); i < to && i < size; i++ {
+				buf[i] = 1
+			}
+		}
+		fft(buf, roots, false)
+		for i := range buf {
+			buf[i] = sig[i] * cmplx.Conj(buf[i])
+		}
+		fft(buf, roots, true)
+
+		for lag := -maxLag; lag <= maxLag; lag++ {
+			idx := lag
+			if idx < 0 {
+				idx += size
+			}
+			if score := real(buf[idx]); score > best.score {
+				best = speechAlignment{scale: scale, offset: time.Duration(lag) * frame, score: score}
+			}
+		}
+	}
+	return best, nil
+}
+
+// fftRoots returns the n/2 complex roots of unity used by fft.
+func fftRoots(n int) []complex128 {
+	roots := make([]complex128, n/2)
+	for i := range roots {
+		ang := 2 * math.Pi * float64(i) / float64(n)
+		roots[i] = complex(math.Cos(ang), math.Sin(ang))
+	}
+	return roots
+}
+
+// fft performs an in-place iterative radix-2 transform of a, whose length
+// must be a power of two matching roots. The inverse transform is scaled by
+// 1/n.
+func fft(a []complex128, roots []complex128, invert bool) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		half := length / 2
+		step := n / length
+		for i := 0; i < n; i += length {
+			for j := 0; j < half; j++ {
+				w := roots[j*step]
+				if invert {
+					w = cmplx.Conj(w)
+				}
+				u, v := a[i+j], a[i+j+half]*w
+				a[i+j] = u + v
+				a[i+j+half] = u - v
+			}
+		}
+	}
+	if invert {
+		scale := complex(1/float64(n), 0)
+		for i := range a {
+			a[i] *= scale
+		}
+	}
+}
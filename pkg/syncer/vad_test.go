@@ -0,0 +1,115 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astisub"
+)
+
+// fakeSpeechDetector returns a fixed speech signal.
+type fakeSpeechDetector struct {
+	speech []bool
+	frame  time.Duration
+}
+
+func (f fakeSpeechDetector) DetectSpeech(string, int) ([]bool, time.Duration, error) {
+	return f.speech, f.frame, nil
+}
+
+// speechFor renders items as a speech signal after applying fn to cue times.
+func speechFor(items []*astisub.Item, frame time.Duration, fn func(time.Duration) time.Duration) []bool {
+	end := fn(items[len(items)-1].EndAt) + 10*time.Second
+	speech := make([]bool, int(end/frame))
+	for _, it := range items {
+		for i := int(fn(it.StartAt) / frame); i < int(fn(it.EndAt)/frame); i++ {
+			speech[i] = true
+		}
+	}
+	return speech
+}
+
+// TestAlignToSpeechOffset verifies a constant delay is recovered.
+func TestAlignToSpeechOffset(t *testing.T) {
+	items := makeCues(60)
+	frame := 10 * time.Millisecond
+	speech := speechFor(items, frame, func(d time.Duration) time.Duration { return d + 3*time.Second })
+
+	al, err := alignToSpeech(speech, frame, items, 0)
+	if err != nil {
+		t.Fatalf("align: %v", err)
+	}
+	if al.scale != 1 {
+		t.Fatalf("expected scale 1, got %v", al.scale)
+	}
+	if al.offset != 3*time.Second {
+		t.Fatalf("expected 3s offset, got %v", al.offset)
+	}
+}
+
+// TestAlignToSpeechFramerate verifies PAL speed-up is detected.
+func TestAlignToSpeechFramerate(t *testing.T) {
+	items := makeCues(300)
+	frame := 10 * time.Millisecond
+	scale := 23.976 / 25
+	speech := speechFor(items, frame, func(d time.Duration) time.Duration {
+		return time.Duration(scale*float64(d)) - time.Second
+	})
+
+	al, err := alignToSpeech(speech, frame, items, 0)
+	if err != nil {
+		t.Fatalf("align: %v", err)
+	}
+	if al.scale != scale {
+		t.Fatalf("expected scale %v, got %v", scale, al.scale)
+	}
+	if d := al.offset + time.Second; d > 20*time.Millisecond || d < -20*time.Millisecond {
+		t.Fatalf("expected -1s offset, got %v", al.offset)
+	}
+}
+
+// TestAlignToSpeechNoSpeech verifies silent audio is reported as an error.
+func TestAlignToSpeechNoSpeech(t *testing.T) {
+	if _, err := alignToSpeech(make([]bool, 100), 10*time.Millisecond, makeCues(3), 0); err == nil {
+		t.Fatal("expected error without speech")
+	}
+}
+
+// TestSyncVAD verifies Sync uses the speech detector instead of Whisper.
+func TestSyncVAD(t *testing.T) {
+	base, err := astisub.OpenFile("../../testdata/simple.srt")
+	if err != nil {
+		t.Fatalf("open base: %v", err)
+	}
+	frame := 10 * time.Millisecond
+	speech := speechFor(base.Items, frame, func(d time.Duration) time.Duration { return d + 2*time.Second })
+
+	dir := t.TempDir()
+	subFile := filepath.Join(dir, "test.srt")
+	f, err := os.Create(subFile)
+	if err != nil {
+		t.Fatalf("create temp: %v", err)
+	}
+	if err := base.WriteToSRT(f); err != nil {
+		t.Fatalf("write SRT: %v", err)
+	}
+	f.Close()
+
+	items, report, err := SyncWithReport("dummy.mkv", subFile, Options{
+		UseAudio:       true,
+		AudioMethod:    AudioMethodVAD,
+		AudioWeight:    1,
+		SpeechDetector: fakeSpeechDetector{speech: speech, frame: frame},
+	})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if report.Offset != 2*time.Second {
+		t.Fatalf("expected 2s offset, got %v", report.Offset)
+	}
+	if items[0].StartAt != base.Items[0].StartAt+2*time.Second {
+		t.Fatalf("unexpected start %v", items[0].StartAt)
+	}
+}
@@ -16,6 +16,9 @@ type Task struct {
 	StartedAt   time.Time `json:"started_at"`
 	CompletedAt time.Time `json:"completed_at"`
 	Error       string    `json:"error"`
+
+	cancel    context.CancelFunc
+	cancelled bool
 }
 
 // TaskSnapshot represents a snapshot of a task without the mutex for safe copying and serialization.
@@ -91,6 +94,13 @@ func (t *Task) setError(err string) {
 	t.Error = err
 }
 
+// isCancelled reports whether the task was cancelled with Cancel.
+func (t *Task) isCancelled() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.cancelled
+}
+
 // setCompletedAt safely sets the completion time of the task.
 func (t *Task) setCompletedAt(completedAt time.Time) {
 	t.mu.Lock()
@@ -104,29 +114,70 @@ var (
 )
 
 // Start launches fn as a goroutine and tracks its progress in the global task map.
-// The returned Task pointer can be polled for updates.
+// The returned Task pointer can be polled for updates. The context passed to fn
+// is cancelled when the task is cancelled with Cancel.
 func Start(ctx context.Context, id string, fn func(context.Context) error) *Task {
+	ctx, cancel := context.WithCancel(ctx)
 	mu.Lock()
-	t := &Task{ID: id, Status: "running", StartedAt: time.Now()}
+	t := &Task{ID: id, Status: "running", StartedAt: time.Now(), cancel: cancel}
 	tasks[id] = t
 	mu.Unlock()
 	broadcast(t.GetSnapshot())
 
 	go func() {
+		defer cancel()
 		err := fn(ctx)
-		if err != nil {
+		switch {
+		case t.isCancelled():
+			t.setStatus("cancelled")
+		case err != nil:
 			t.setStatus("failed")
 			t.setError(err.Error())
-		} else {
+			t.setProgress(100)
+		default:
 			t.setStatus("completed")
+			t.setProgress(100)
 		}
-		t.setProgress(100)
 		t.setCompletedAt(time.Now())
 		broadcast(t.GetSnapshot())
 	}()
 	return t
 }
 
+// Get returns a snapshot of the task with the given id.
+func Get(id string) (TaskSnapshot, bool) {
+	mu.Lock()
+	t, ok := tasks[id]
+	mu.Unlock()
+	if !ok {
+		return TaskSnapshot{}, false
+	}
+	return t.GetSnapshot(), true
+}
+
+// Cancel cancels the context of the running task id and marks it cancelled.
+// It returns false when the task is unknown or has already finished.
+func Cancel(id string) bool {
+	mu.Lock()
+	t, ok := tasks[id]
+	mu.Unlock()
+	if !ok {
+		return false
+	}
+	t.mu.Lock()
+	if t.Status != "running" || t.cancel == nil {
+		t.mu.Unlock()
+		return false
+	}
+	t.cancelled = true
+	t.Status = "cancelled"
+	cancel := t.cancel
+	t.mu.Unlock()
+	cancel()
+	broadcast(t.GetSnapshot())
+	return true
+}
+
 // List returns a copy of all known tasks keyed by ID.
 func List() map[string]*TaskSnapshot {
 	mu.Lock()
@@ -80,3 +80,38 @@ func TestListReturnsCopy(t *testing.T) {
 		t.Fatalf("modifying list result affected original map")
 	}
 }
+
+// TestCancel verifies that Cancel stops a running task and marks it cancelled.
+func TestCancel(t *testing.T) {
+	reset()
+	started := make(chan struct{})
+	task := Start(context.Background(), "c", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	if !Cancel("c") {
+		t.Fatal("expected cancel to succeed")
+	}
+	waitUntilFinished(task)
+	for i := 0; i < 10 && task.GetCompletedAt().IsZero(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	snap, ok := Get("c")
+	if !ok {
+		t.Fatal("task not found")
+	}
+	if snap.Status != "cancelled" {
+		t.Fatalf("expected status cancelled, got %s", snap.Status)
+	}
+	if snap.CompletedAt.IsZero() {
+		t.Fatal("completed time not set")
+	}
+	if Cancel("c") {
+		t.Fatal("expected second cancel to fail")
+	}
+	if Cancel("missing") {
+		t.Fatal("expected cancel of unknown task to fail")
+	}
+}
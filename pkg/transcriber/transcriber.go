@@ -15,6 +15,9 @@ const (
 	MethodOpenAI TranscriptionMethod = "openai"
 	// MethodDocker uses a local Docker container
 	MethodDocker TranscriptionMethod = "docker"
+	// MethodContainer uses the managed Whisper ASR container, falling back
+	// to the OpenAI API when the container is not running
+	MethodContainer TranscriptionMethod = "container"
 )
 
 // whisperModel is the OpenAI model used for transcriptions.
@@ -51,6 +54,14 @@ func TranscribeWithMethod(ctx context.Context, method TranscriptionMethod, path,
 		}
 
 		return transcriber.TranscribeFile(ctx, path)
+	case MethodContainer:
+		container, err := NewWhisperContainer()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create container client: %w", err)
+		}
+		defer container.Close()
+
+		return container.Transcribe(ctx, path, lang, apiKey)
 	default:
 		return nil, fmt.Errorf("unsupported transcription method: %s", method)
 	}
@@ -60,13 +71,18 @@ func TranscribeWithMethod(ctx context.Context, method TranscriptionMethod, path,
 // The language code may be empty to enable auto detection. The API key is
 // required. It returns the SRT subtitle bytes produced by the service.
 func WhisperTranscribe(path, lang, apiKey string) ([]byte, error) {
+	return transcribeAt(context.Background(), baseURL, path, lang, apiKey)
+}
+
+// transcribeAt sends path to the OpenAI-compatible transcription API at url.
+func transcribeAt(ctx context.Context, url, path, lang, apiKey string) ([]byte, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("api key required")
 	}
 	cfg := openai.DefaultConfig(apiKey)
-	cfg.BaseURL = baseURL
+	cfg.BaseURL = url
 	client := openai.NewClientWithConfig(cfg)
-	resp, err := client.CreateTranscription(context.Background(), openai.AudioRequest{
+	resp, err := client.CreateTranscription(ctx, openai.AudioRequest{
 		Model:    whisperModel,
 		FilePath: path,
 		Language: lang,
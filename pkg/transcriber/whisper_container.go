@@ -1,5 +1,5 @@
 // file: pkg/transcriber/whisper_container.go
-// version: 1.0.0
+// version: 1.1.0
 // guid: 123e4567-e89b-12d3-a456-426614174001
 
 package transcriber
@@ -278,19 +278,9 @@ func (w *WhisperContainer) TranscribeFile(ctx context.Context, filePath, languag
 // transcribeWithContainer performs transcription using the local container.
 func (w *WhisperContainer) transcribeWithContainer(ctx context.Context, taskID, filePath, language string) error {
 	tasks.Update(taskID, 20) // Container available
-
-	// For now, delegate to external API until container API is fully implemented
-	// This is where you'd implement the container-specific transcription logic
-	baseURL := fmt.Sprintf("http://localhost:%s/v1", w.config.Port)
-	oldBaseURL := baseURL
-	SetBaseURL(baseURL)
-	defer SetBaseURL(oldBaseURL)
-
 	tasks.Update(taskID, 50) // Starting API call
 
-	// Use existing transcriber function
-	_, err := WhisperTranscribe(filePath, language, "dummy-key-for-container")
-	if err != nil {
+	if _, err := transcribeAt(ctx, w.apiURL(), filePath, language, containerAPIKey); err != nil {
 		return fmt.Errorf("container transcription failed: %w", err)
 	}
 
@@ -318,6 +308,38 @@ func (w *WhisperContainer) transcribeWithExternalAPI(ctx context.Context, taskID
 	return nil
 }
 
+// Transcribe returns the SRT transcription of filePath. The running container
+// is used when available; otherwise the OpenAI API is called with apiKey.
+func (w *WhisperContainer) Transcribe(ctx context.Context, filePath, language, apiKey string) ([]byte, error) {
+	running, err := w.IsContainerRunning(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check container status: %w", err)
+	}
+	if running {
+		data, err := transcribeAt(ctx, w.apiURL(), filePath, language, containerAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("container transcription failed: %w", err)
+		}
+		return data, nil
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not configured and container not available")
+	}
+	data, err := transcribeAt(ctx, baseURL, filePath, language, apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("external API transcription failed: %w", err)
+	}
+	return data, nil
+}
+
+// containerAPIKey is sent to the local container, which does not check it.
+const containerAPIKey = "dummy-key-for-container"
+
+// apiURL returns the OpenAI-compatible endpoint served by the container.
+func (w *WhisperContainer) apiURL() string {
+	return fmt.Sprintf("http://localhost:%s/v1", w.config.Port)
+}
+
 // ValidateModel checks if the provided model is supported.
 func ValidateModel(model string) bool {
 	for _, m := range SupportedModels {
@@ -1,5 +1,5 @@
 // file: pkg/video/video.go
-// version: 1.0.0
+// version: 1.1.0
 // guid: 8a9b0c1d-2e3f-4a5b-6c7d-8e9f0a1b2c3d
 
 // Package video provides video analysis and processing utilities using ffmpeg and ffprobe.
@@ -14,6 +14,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+
+	"golang.org/x/text/language"
 )
 
 var ffprobePath = "ffprobe"
@@ -57,6 +60,15 @@ type ffprobeResult struct {
 		BitRate      string `json:"bit_rate"`
 		SampleRate   string `json:"sample_rate"`
 		Channels     int    `json:"channels"`
+		Tags         struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"tags"`
+		Disposition struct {
+			Default         int `json:"default"`
+			Forced          int `json:"forced"`
+			HearingImpaired int `json:"hearing_impaired"`
+		} `json:"disposition"`
 	} `json:"streams"`
 }
 
@@ -174,6 +186,88 @@ func (vi *VideoInfo) GetAspectRatio() float64 {
 
 // GetSubtitleTracks returns information about subtitle tracks in the video file
 func GetSubtitleTracks(videoPath string) ([]map[string]string, error) {
+	probed, err := ProbeSubtitleTracks(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []map[string]string
+	for _, t := range probed {
+		track := map[string]string{
+			"index": fmt.Sprintf("%d", t.Index),
+			"codec": t.Codec,
+		}
+		if t.Language != "" {
+			track["language"] = t.Language
+		}
+		if t.Title != "" {
+			track["title"] = t.Title
+		}
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+// SubtitleTrack describes a subtitle stream embedded in a video file.
+type SubtitleTrack struct {
+	// Index is the stream index within the container.
+	Index int `json:"index"`
+	// Position is the index among the subtitle streams, as used by
+	// ffmpeg's "0:s:N" stream specifier.
+	Position int    `json:"position"`
+	Codec    string `json:"codec"`
+	// Language is the language tag of the stream, usually an ISO 639-2
+	// code such as "eng".
+	Language        string `json:"language,omitempty"`
+	Title           string `json:"title,omitempty"`
+	Default         bool   `json:"default"`
+	Forced          bool   `json:"forced"`
+	HearingImpaired bool   `json:"hearing_impaired"`
+}
+
+// imageCodecs are the subtitle codecs that store bitmaps rather than text.
+var imageCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"dvb_teletext":      true,
+	"xsub":              true,
+}
+
+// IsText reports whether the track stores text that can be extracted to a
+// subtitle file, as opposed to bitmap subtitles such as PGS or VobSub.
+func (t SubtitleTrack) IsText() bool {
+	return !imageCodecs[t.Codec]
+}
+
+// MatchesLanguage reports whether the track is in lang. Two and three
+// letter codes of the same language match; untagged tracks match nothing.
+func (t SubtitleTrack) MatchesLanguage(lang string) bool {
+	if t.Language == "" || t.Language == "und" {
+		return false
+	}
+	if strings.EqualFold(t.Language, lang) {
+		return true
+	}
+	a, err := language.Parse(t.Language)
+	if err != nil {
+		return false
+	}
+	b, err := language.Parse(lang)
+	if err != nil {
+		return false
+	}
+	ab, _ := a.Base()
+	bb, _ := b.Base()
+	return ab == bb
+}
+
+// ProbeSubtitleTracks returns the subtitle streams of the video file with
+// their language, title and disposition. Tracks are flagged forced or
+// hearing impaired from their disposition or, for containers that lack
+// those flags, from hints such as "Forced" or "SDH" in their title.
+func ProbeSubtitleTracks(videoPath string) ([]SubtitleTrack, error) {
 	cmd := exec.CommandContext(context.Background(), ffprobePath,
 		"-v", "quiet",
 		"-select_streams", "s",
@@ -191,16 +285,34 @@ func GetSubtitleTracks(videoPath string) ([]map[string]string, error) {
 		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
 	}
 
-	var tracks []map[string]string
+	var tracks []SubtitleTrack
 	for _, stream := range result.Streams {
-		if stream.CodecType == "subtitle" {
-			track := map[string]string{
-				"index": fmt.Sprintf("%d", stream.Index),
-				"codec": stream.CodecName,
-			}
-			tracks = append(tracks, track)
+		if stream.CodecType != "subtitle" {
+			continue
 		}
+		words := titleWords(stream.Tags.Title)
+		tracks = append(tracks, SubtitleTrack{
+			Index:           stream.Index,
+			Position:        len(tracks),
+			Codec:           stream.CodecName,
+			Language:        stream.Tags.Language,
+			Title:           stream.Tags.Title,
+			Default:         stream.Disposition.Default == 1,
+			Forced:          stream.Disposition.Forced == 1 || words["forced"],
+			HearingImpaired: stream.Disposition.HearingImpaired == 1 || words["sdh"] || words["hi"] || words["cc"] || strings.Contains(strings.ToLower(stream.Tags.Title), "hearing impaired"),
+		})
 	}
 
 	return tracks, nil
 }
+
+// titleWords returns the lower case words of a track title.
+func titleWords(title string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[w] = true
+	}
+	return words
+}
@@ -1,5 +1,5 @@
 // file: pkg/video/video_test.go
-// version: 1.0.0
+// version: 1.1.0
 // guid: 8a9b0c1d-2e3f-4a5b-6c7d-8e9f0a1b2c3e
 
 package video
@@ -442,6 +442,44 @@ func TestGetSubtitleTracks(t *testing.T) {
 	}
 }
 
+// TestProbeSubtitleTracks tests language, disposition and title hints of
+// embedded subtitle tracks
+func TestProbeSubtitleTracks(t *testing.T) {
+	mockPath := mockFFprobeOutput(t, `{
+		"streams": [
+			{"index": 2, "codec_type": "subtitle", "codec_name": "subrip",
+			 "tags": {"language": "eng", "title": "English"},
+			 "disposition": {"default": 1, "forced": 0, "hearing_impaired": 0}},
+			{"index": 3, "codec_type": "subtitle", "codec_name": "ass",
+			 "tags": {"language": "eng", "title": "English [SDH]"}},
+			{"index": 4, "codec_type": "subtitle", "codec_name": "hdmv_pgs_subtitle",
+			 "tags": {"language": "ger", "title": "Forced"}},
+			{"index": 5, "codec_type": "subtitle", "codec_name": "subrip",
+			 "disposition": {"hearing_impaired": 1}}
+		]
+	}`)
+	originalPath := ffprobePath
+	SetFFprobePath(mockPath)
+	defer SetFFprobePath(originalPath)
+
+	tracks, err := ProbeSubtitleTracks("dummy-path")
+	require.NoError(t, err)
+	assert.Equal(t, []SubtitleTrack{
+		{Index: 2, Position: 0, Codec: "subrip", Language: "eng", Title: "English", Default: true},
+		{Index: 3, Position: 1, Codec: "ass", Language: "eng", Title: "English [SDH]", HearingImpaired: true},
+		{Index: 4, Position: 2, Codec: "hdmv_pgs_subtitle", Language: "ger", Title: "Forced", Forced: true},
+		{Index: 5, Position: 3, Codec: "subrip", HearingImpaired: true},
+	}, tracks)
+
+	assert.True(t, tracks[0].IsText())
+	assert.False(t, tracks[2].IsText())
+	assert.True(t, tracks[0].MatchesLanguage("en"))
+	assert.True(t, tracks[0].MatchesLanguage("eng"))
+	assert.False(t, tracks[0].MatchesLanguage("de"))
+	assert.True(t, tracks[2].MatchesLanguage("de"))
+	assert.False(t, tracks[3].MatchesLanguage("en"), "untagged track")
+}
+
 // TestGetSubtitleTracksErrors tests error conditions in GetSubtitleTracks
 func TestGetSubtitleTracksErrors(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,407 @@
+// file: pkg/watcher/debounce.go
+// version: 1.2.1
+// guid: 4f1c7a92-3e8b-4d56-a0c9-7b2e5d8f1a36
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
+)
+
+// DefaultStablePeriod is how long a video file must keep the same size and
+// modification time before it is processed when watch.stable_period is not
+// configured.
+const DefaultStablePeriod = 30 * time.Second
+
+// moveWindow is how long a renamed or removed path is remembered as the
+// possible source of a file appearing elsewhere, in addition to the stable
+// period the new file waits for.
+const moveWindow = time.Minute
+
+// processWorkers is how many stable files are processed at once, and
+// processBacklog how many may wait for a worker before further files stay
+// pending until the next flush.
+const (
+	processWorkers = 2
+	processBacklog = 64
+)
+
+// tempSuffixes are the extensions download clients use for files that are
+// still being written.
+var tempSuffixes = []string{".part", ".partial", ".!qb", ".!ut", ".crdownload", ".tmp"}
+
+// StablePeriod returns the configured time a file must stay unchanged before
+// it is processed.
+func StablePeriod() time.Duration {
+	if viper.IsSet("watch.stable_period") {
+		return viper.GetDuration("watch.stable_period")
+	}
+	return DefaultStablePeriod
+}
+
+// isTempFile reports whether path names an incomplete download.
+func isTempFile(path string) bool {
+	name := strings.ToLower(path)
+	for _, s := range tempSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileStamp is the size, modification time and inode of a file.
+type fileStamp struct {
+	size  int64
+	mtime time.Time
+	ino   uint64
+}
+
+// sameFile reports whether a and b likely describe the same file content
+// before and after a move: the size matches and so does the inode, or the
+// modification time when the file was copied to another file system.
+func sameFile(a, b fileStamp) bool {
+	if a.size != b.size {
+		return false
+	}
+	if a.ino != 0 && a.ino == b.ino {
+		return true
+	}
+	return a.mtime.Equal(b.mtime)
+}
+
+// stampOf returns the stamp of the regular file at path.
+func stampOf(path string) (fileStamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	if !fi.Mode().IsRegular() {
+		return fileStamp{}, errors.New("not a regular file")
+	}
+	return fileStamp{size: fi.Size(), mtime: fi.ModTime(), ino: inodeOf(fi)}, nil
+}
+
+// pendingFile is a video file waiting to become stable.
+type pendingFile struct {
+	stamp fileStamp
+	since time.Time
+}
+
+// goneFile is a processed file that was renamed or removed, remembered as
+// the possible source of a move.
+type goneFile struct {
+	stamp fileStamp
+	at    time.Time
+}
+
+// debouncer delays processing of video files until their size and
+// modification time have been stable for period. Repeated events for a file
+// are coalesced, and a file is not processed again until it changes. Stable
+// files are handed to a bounded pool of workers so slow provider searches
+// do not hold up the watch loop. A file that appears after a processed file
+// with records in the store disappeared, and has the same size and inode or
+// modification time, is treated as a move: the records and subtitles follow
+// the video instead of a new search being started. Files indexed by earlier
+// library scans are matched the same way by size and modification time, so
+// moves are recognised across restarts and without a removal event.
+//
+// Apart from its workers a debouncer is not safe for concurrent use; the
+// watch loop owns it.
+type debouncer struct {
+	period  time.Duration
+	store   database.SubtitleStore
+	process func(ctx context.Context, path string) error
+	logger  *logrus.Entry
+
+	pending map[string]*pendingFile
+	seen    map[string]fileStamp
+	gone    map[string]goneFile
+	// index caches the file index entries during a flush; indexed reports
+	// whether they were loaded.
+	index   []database.FileIndexEntry
+	indexed bool
+
+	work chan string
+	busy sync.WaitGroup
+	done sync.WaitGroup
+}
+
+// newDebouncer returns a debouncer calling process for every stable file.
+func newDebouncer(period time.Duration, store database.SubtitleStore, logger *logrus.Entry, process func(ctx context.Context, path string) error) *debouncer {
+	return &debouncer{
+		period:  period,
+		store:   store,
+		process: process,
+		logger:  logger,
+		pending: make(map[string]*pendingFile),
+		seen:    make(map[string]fileStamp),
+		gone:    make(map[string]goneFile),
+	}
+}
+
+// start launches the workers processing stable files with ctx.
+func (d *debouncer) start(ctx context.Context) {
+	d.work = make(chan string, processBacklog)
+	for range processWorkers {
+		d.done.Add(1)
+		go func() {
+			defer d.done.Done()
+			for path := range d.work {
+				if ctx.Err() == nil {
+					if err := d.process(ctx, path); err != nil {
+						d.logger.Warnf("process %s: %v", path, err)
+					}
+				}
+				d.busy.Done()
+			}
+		}()
+	}
+}
+
+// idle waits until every dispatched file has been processed.
+func (d *debouncer) idle() {
+	d.busy.Wait()
+}
+
+// stop lets the workers finish their files and waits for them to exit.
+func (d *debouncer) stop() {
+	close(d.work)
+	d.done.Wait()
+}
+
+// interval returns how often pending files should be checked.
+func (d *debouncer) interval() time.Duration {
+	return min(max(d.period/4, 10*time.Millisecond), time.Second)
+}
+
+// observe records a file system event.
+func (d *debouncer) observe(ev fsnotify.Event) {
+	if isTempFile(ev.Name) {
+		return
+	}
+	if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		if _, err := os.Lstat(ev.Name); err != nil {
+			d.forget(ev.Name)
+			return
+		}
+	}
+	if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) != 0 && fileindex.IsVideo(ev.Name) {
+		d.add(ev.Name)
+	}
+}
+
+// add queues path, or restarts its stable period when it changed since it
+// was queued.
+func (d *debouncer) add(path string) {
+	stamp, err := stampOf(path)
+	if err != nil {
+		return
+	}
+	if seen, ok := d.seen[path]; ok && seen == stamp {
+		return
+	}
+	if p, ok := d.pending[path]; ok {
+		if p.stamp != stamp {
+			p.stamp, p.since = stamp, time.Now()
+		}
+		return
+	}
+	d.pending[path] = &pendingFile{stamp: stamp, since: time.Now()}
+}
+
+// forget drops path, and every file below it when it was a directory, after
+// it was renamed or removed. Processed files are remembered as the possible
+// source of a move.
+func (d *debouncer) forget(path string) {
+	now := time.Now()
+	prefix := path + string(filepath.Separator)
+	for p := range d.pending {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(d.pending, p)
+		}
+	}
+	for p, stamp := range d.seen {
+		if p == path || strings.HasPrefix(p, prefix) {
+			d.gone[p] = goneFile{stamp: stamp, at: now}
+			delete(d.seen, p)
+		}
+	}
+}
+
+// flush processes every pending file that has been stable for the period.
+func (d *debouncer) flush(ctx context.Context) {
+	defer func() { d.index, d.indexed = nil, false }()
+	now := time.Now()
+	var ready []string
+	for path, p := range d.pending {
+		stamp, err := stampOf(path)
+		switch {
+		case err != nil:
+			delete(d.pending, path)
+		case stamp != p.stamp:
+			p.stamp, p.since = stamp, now
+		case now.Sub(p.since) >= d.period:
+			ready = append(ready, path)
+		}
+	}
+	sort.Strings(ready)
+	for _, path := range ready {
+		if ctx.Err() != nil || len(d.work) == cap(d.work) {
+			// The workers are behind; the rest waits for the next flush.
+			break
+		}
+		stamp := d.pending[path].stamp
+		d.seen[path] = stamp
+		delete(d.pending, path)
+		d.handle(path, stamp)
+	}
+	for path, g := range d.gone {
+		if now.Sub(g.at) > d.period+moveWindow {
+			delete(d.gone, path)
+		}
+	}
+}
+
+// handle hands a stable file to the workers unless it was moved from a
+// known location.
+func (d *debouncer) handle(path string, stamp fileStamp) {
+	if old := d.movedFrom(path, stamp); old != "" {
+		if err := d.move(old, path); err != nil {
+			d.logger.Warnf("move %s to %s: %v", old, path, err)
+		} else {
+			d.logger.Infof("moved %s to %s", old, path)
+			return
+		}
+	}
+	d.busy.Add(1)
+	d.work <- path
+}
+
+// movedFrom returns the former path of a file with stamp that appeared at
+// path, or an empty string when it is new. A file is considered moved when
+// a recently renamed or removed file had the same size and inode or
+// modification time, or a file index entry has the same size and
+// modification time, and that file no longer exists and has records in the
+// store.
+func (d *debouncer) movedFrom(path string, stamp fileStamp) string {
+	if d.store == nil {
+		return ""
+	}
+	var gone []string
+	for g, f := range d.gone {
+		if g != path && sameFile(f.stamp, stamp) {
+			gone = append(gone, g)
+		}
+	}
+	sort.Strings(gone)
+	for _, old := range append(gone, d.indexedAs(path, stamp)...) {
+		if !d.hasRecords(old) {
+			continue
+		}
+		if _, err := os.Stat(old); errors.Is(err, os.ErrNotExist) {
+			return old
+		}
+	}
+	return ""
+}
+
+// indexedAs returns the paths of file index entries other than path that
+// describe a file with stamp. The modification time is compared to the
+// millisecond as databases store it with varying precision, and entries with
+// a media hash must match the hash of path as well.
+func (d *debouncer) indexedAs(path string, stamp fileStamp) []string {
+	if !d.indexed {
+		entries, err := d.store.ListFileIndexEntries("")
+		if err != nil {
+			d.logger.Debugf("list file index: %v", err)
+		}
+		d.index, d.indexed = entries, true
+	}
+	var paths []string
+	var hash string
+	for _, e := range d.index {
+		if e.Path == path || e.Size != stamp.size || e.ModTime.Sub(stamp.mtime).Abs() >= time.Millisecond {
+			continue
+		}
+		if e.MediaHash != "" {
+			if hash == "" {
+				hash, _ = fileindex.MediaHash(path)
+			}
+			if hash != e.MediaHash {
+				continue
+			}
+		}
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+// hasRecords reports whether the store knows the video at path.
+func (d *debouncer) hasRecords(path string) bool {
+	if item, err := d.store.GetMediaItem(path); err == nil && item != nil {
+		return true
+	}
+	if recs, err := d.store.ListSubtitlesByVideo(path); err == nil && len(recs) > 0 {
+		return true
+	}
+	if recs, err := d.store.ListDownloadsByVideo(path); err == nil && len(recs) > 0 {
+		return true
+	}
+	return false
+}
+
+// move carries the subtitles and records of a video moved from oldPath to
+// newPath over to the new location. Subtitle files left behind next to the
+// old path are moved unless the new location already has them.
+func (d *debouncer) move(oldPath, newPath string) error {
+	var files []string
+	subs, err := d.store.ListSubtitlesByVideo(oldPath)
+	if err != nil {
+		return err
+	}
+	for _, r := range subs {
+		files = append(files, r.File)
+	}
+	downloads, err := d.store.ListDownloadsByVideo(oldPath)
+	if err != nil {
+		return err
+	}
+	for _, r := range downloads {
+		files = append(files, r.File)
+	}
+	for _, file := range files {
+		dest := database.MovedSidecarPath(file, oldPath, newPath)
+		if dest == file {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+		if err := os.Rename(file, dest); err != nil {
+			d.logger.Warnf("move subtitle %s: %v", file, err)
+		}
+	}
+	if err := d.store.MoveMediaPath(oldPath, newPath); err != nil {
+		return err
+	}
+	delete(d.gone, oldPath)
+	return nil
+}
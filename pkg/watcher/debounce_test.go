@@ -0,0 +1,289 @@
+// file: pkg/watcher/debounce_test.go
+// version: 1.2.0
+// guid: 9d3e6b21-7c4a-4f85-b1e0-2a6f8c5d9e47
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+)
+
+// newTestDebouncer returns a running debouncer and a function returning the
+// files it processed once the dispatched work is done.
+func newTestDebouncer(t *testing.T, period time.Duration, store database.SubtitleStore) (*debouncer, func() []string) {
+	var mu sync.Mutex
+	var processed []string
+	d := newDebouncer(period, store, logging.GetLogger("watcher"), func(ctx context.Context, path string) error {
+		mu.Lock()
+		processed = append(processed, path)
+		mu.Unlock()
+		return nil
+	})
+	d.start(context.Background())
+	t.Cleanup(d.stop)
+	return d, func() []string {
+		d.idle()
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), processed...)
+	}
+}
+
+func TestIsTempFile(t *testing.T) {
+	for _, name := range []string{"a.mkv.part", "a.mkv.!qB", "a.mkv.tmp", "a.mkv.crdownload"} {
+		assert.True(t, isTempFile(name), name)
+	}
+	assert.False(t, isTempFile("a.mkv"))
+}
+
+func TestDebouncerWaitsForStableFile(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "video.mkv")
+	require.NoError(t, os.WriteFile(video, []byte("x"), 0644))
+	require.NoError(t, os.WriteFile(video+".part", []byte("x"), 0644))
+
+	d, processed := newTestDebouncer(t, 50*time.Millisecond, nil)
+	d.observe(fsnotify.Event{Name: video + ".part", Op: fsnotify.Create})
+	d.observe(fsnotify.Event{Name: video, Op: fsnotify.Create})
+	d.observe(fsnotify.Event{Name: video, Op: fsnotify.Write})
+	d.flush(context.Background())
+	assert.Empty(t, processed(), "file not stable yet")
+
+	// Growing files restart the stable period.
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, os.WriteFile(video, []byte("xx"), 0644))
+	d.observe(fsnotify.Event{Name: video, Op: fsnotify.Write})
+	time.Sleep(30 * time.Millisecond)
+	d.flush(context.Background())
+	assert.Empty(t, processed(), "file changed within the period")
+
+	time.Sleep(60 * time.Millisecond)
+	d.flush(context.Background())
+	assert.Equal(t, []string{video}, processed())
+
+	// Duplicate events for an unchanged file are dropped.
+	d.observe(fsnotify.Event{Name: video, Op: fsnotify.Write})
+	time.Sleep(60 * time.Millisecond)
+	d.flush(context.Background())
+	assert.Len(t, processed(), 1)
+}
+
+func TestDebouncerDropsVanishedFiles(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "video.mkv")
+	require.NoError(t, os.WriteFile(video, []byte("x"), 0644))
+
+	d, processed := newTestDebouncer(t, 0, nil)
+	d.observe(fsnotify.Event{Name: video, Op: fsnotify.Create})
+	require.NoError(t, os.Remove(video))
+	d.flush(context.Background())
+	assert.Empty(t, processed())
+	assert.Empty(t, d.pending)
+}
+
+func TestDebouncerMapsMovedVideo(t *testing.T) {
+	root := t.TempDir()
+	oldVideo := filepath.Join(root, "downloads", "Show.S01E01.mkv")
+	newVideo := filepath.Join(root, "tv", "Show", "Show.S01E01.mkv")
+	oldSub := filepath.Join(root, "downloads", "Show.S01E01.en.srt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(oldVideo), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Dir(newVideo), 0755))
+	require.NoError(t, os.WriteFile(oldVideo, []byte("video"), 0644))
+	require.NoError(t, os.WriteFile(oldSub, []byte("sub"), 0644))
+
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.InsertDownload(&database.DownloadRecord{File: oldSub, VideoFile: oldVideo, Language: "en", Provider: "test"}))
+
+	d, processed := newTestDebouncer(t, 0, store)
+	d.add(oldVideo)
+	d.flush(context.Background())
+	require.Equal(t, []string{oldVideo}, processed())
+
+	require.NoError(t, os.Rename(oldVideo, newVideo))
+	d.observe(fsnotify.Event{Name: oldVideo, Op: fsnotify.Rename})
+	d.observe(fsnotify.Event{Name: newVideo, Op: fsnotify.Create})
+	d.flush(context.Background())
+
+	assert.Equal(t, []string{oldVideo}, processed(), "moved video must not be searched again")
+	assert.NotContains(t, d.seen, oldVideo)
+	assert.FileExists(t, filepath.Join(root, "tv", "Show", "Show.S01E01.en.srt"))
+	assert.NoFileExists(t, oldSub)
+	downloads, err := store.ListDownloadsByVideo(newVideo)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	assert.Equal(t, filepath.Join(root, "tv", "Show", "Show.S01E01.en.srt"), downloads[0].File)
+
+	// A new file with the same name but different content is not a move.
+	require.NoError(t, os.Remove(newVideo))
+	d.observe(fsnotify.Event{Name: newVideo, Op: fsnotify.Remove})
+	other := filepath.Join(root, "tv", "Other", "Show.S01E01.mkv")
+	require.NoError(t, os.MkdirAll(filepath.Dir(other), 0755))
+	require.NoError(t, os.WriteFile(other, []byte("another video"), 0644))
+	d.observe(fsnotify.Event{Name: other, Op: fsnotify.Create})
+	d.flush(context.Background())
+	assert.Equal(t, []string{oldVideo, other}, processed())
+}
+
+func TestDebouncerMapsMovedDirectory(t *testing.T) {
+	root := t.TempDir()
+	oldDir := filepath.Join(root, "downloads", "Movie (2020)")
+	newDir := filepath.Join(root, "movies", "Movie (2020)")
+	oldVideo := filepath.Join(oldDir, "Movie.mkv")
+	newVideo := filepath.Join(newDir, "Movie.mkv")
+	require.NoError(t, os.MkdirAll(oldDir, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Dir(newDir), 0755))
+	require.NoError(t, os.WriteFile(oldVideo, []byte("video"), 0644))
+
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.InsertMediaItem(&database.MediaItem{Path: oldVideo, Title: "Movie"}))
+
+	d, processed := newTestDebouncer(t, 0, store)
+	d.add(oldVideo)
+	d.flush(context.Background())
+	require.Equal(t, []string{oldVideo}, processed())
+
+	require.NoError(t, os.Rename(oldDir, newDir))
+	d.observe(fsnotify.Event{Name: oldDir, Op: fsnotify.Rename})
+	assert.Empty(t, d.seen, "files below a moved directory are forgotten")
+	d.add(newVideo)
+	d.flush(context.Background())
+
+	assert.Equal(t, []string{oldVideo}, processed())
+	item, err := store.GetMediaItem(newVideo)
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "Movie", item.Title)
+}
+
+// TestDebouncerMatchesMovesByContent verifies a renamed file is recognised by
+// its size and inode even under a different name, and that a file with the
+// old name but other content is not.
+func TestDebouncerMatchesMovesByContent(t *testing.T) {
+	root := t.TempDir()
+	oldVideo := filepath.Join(root, "Movie.2020.1080p.mkv")
+	renamed := filepath.Join(root, "Movie (2020).mkv")
+	require.NoError(t, os.WriteFile(oldVideo, []byte("video"), 0644))
+
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.InsertMediaItem(&database.MediaItem{Path: oldVideo, Title: "Movie"}))
+
+	d, processed := newTestDebouncer(t, 0, store)
+	d.add(oldVideo)
+	d.flush(context.Background())
+
+	require.NoError(t, os.Rename(oldVideo, renamed))
+	d.observe(fsnotify.Event{Name: oldVideo, Op: fsnotify.Rename})
+	d.observe(fsnotify.Event{Name: renamed, Op: fsnotify.Create})
+	d.flush(context.Background())
+	assert.Equal(t, []string{oldVideo}, processed())
+	item, err := store.GetMediaItem(renamed)
+	require.NoError(t, err)
+	require.NotNil(t, item)
+
+	// A different file taking the old name is searched for.
+	require.NoError(t, store.InsertMediaItem(&database.MediaItem{Path: filepath.Join(root, "Other.mkv"), Title: "Other"}))
+	require.NoError(t, os.WriteFile(oldVideo, []byte("a different video"), 0644))
+	d.observe(fsnotify.Event{Name: oldVideo, Op: fsnotify.Create})
+	d.flush(context.Background())
+	assert.Equal(t, []string{oldVideo, oldVideo}, processed())
+}
+
+// TestDebouncerMatchesMovesFromFileIndex verifies a move is recognised from
+// the file index when the watcher never saw the old file, as after a
+// restart.
+func TestDebouncerMatchesMovesFromFileIndex(t *testing.T) {
+	root := t.TempDir()
+	oldVideo := filepath.Join(root, "downloads", "Movie.mkv")
+	newVideo := filepath.Join(root, "movies", "Movie.mkv")
+	require.NoError(t, os.MkdirAll(filepath.Dir(oldVideo), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Dir(newVideo), 0755))
+	require.NoError(t, os.WriteFile(oldVideo, []byte("video"), 0644))
+
+	store, err := database.OpenPebble(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.InsertMediaItem(&database.MediaItem{Path: oldVideo, Title: "Movie"}))
+	info, err := os.Stat(oldVideo)
+	require.NoError(t, err)
+	hash, err := fileindex.MediaHash(oldVideo)
+	require.NoError(t, err)
+	require.NoError(t, store.UpsertFileIndexEntry(&database.FileIndexEntry{Path: oldVideo, Size: info.Size(), ModTime: info.ModTime(), MediaHash: hash}))
+
+	require.NoError(t, os.Rename(oldVideo, newVideo))
+	d, processed := newTestDebouncer(t, 0, store)
+	d.add(newVideo)
+	d.flush(context.Background())
+
+	assert.Empty(t, processed(), "indexed video must not be searched again")
+	item, err := store.GetMediaItem(newVideo)
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "Movie", item.Title)
+
+	// A file of the same size and time but other content is not a move.
+	copied := filepath.Join(root, "movies", "Copy.mkv")
+	require.NoError(t, os.WriteFile(copied, []byte("other"), 0644))
+	require.NoError(t, os.Chtimes(copied, info.ModTime(), info.ModTime()))
+	require.NoError(t, store.UpsertFileIndexEntry(&database.FileIndexEntry{Path: oldVideo, Size: info.Size(), ModTime: info.ModTime(), MediaHash: hash}))
+	require.NoError(t, store.InsertMediaItem(&database.MediaItem{Path: oldVideo, Title: "Movie"}))
+	d.add(copied)
+	d.flush(context.Background())
+	assert.Equal(t, []string{copied}, processed())
+}
+
+// TestDebouncerDispatchIsBounded verifies the watch loop does not block on
+// slow processing and keeps files pending while the backlog is full.
+func TestDebouncerDispatchIsBounded(t *testing.T) {
+	dir := t.TempDir()
+	release := make(chan struct{})
+	d := newDebouncer(0, nil, logging.GetLogger("watcher"), func(ctx context.Context, path string) error {
+		<-release
+		return nil
+	})
+	d.start(context.Background())
+	defer d.stop()
+
+	n := processWorkers + processBacklog + 5
+	for i := range n {
+		path := filepath.Join(dir, fmt.Sprintf("video%03d.mkv", i))
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+		d.add(path)
+	}
+	flushed := make(chan struct{})
+	go func() {
+		// Let the workers pick up their first files before filling the
+		// backlog.
+		d.flush(context.Background())
+		time.Sleep(20 * time.Millisecond)
+		d.flush(context.Background())
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("flush blocked on busy workers")
+	}
+	assert.NotEmpty(t, d.pending, "files beyond the backlog stay pending")
+	close(release)
+	d.idle()
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+// file: pkg/watcher/fs_unix.go
+// version: 1.0.0
+// guid: 1e7b4c93-8d2a-4f60-b5e9-0a3c6d8f2b71
+
+package watcher
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the inode number of fi, or zero when it is unknown.
+func inodeOf(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+// file: pkg/watcher/fs_windows.go
+// version: 1.0.0
+// guid: 8c5f2a17-3b6e-4d94-a0c8-e7d1f4b9263a
+
+package watcher
+
+import "os"
+
+// inodeOf returns zero because file IDs are not part of os.FileInfo on
+// Windows. Moves are reported as a Remove and a Create.
+func inodeOf(fi os.FileInfo) uint64 {
+	return 0
+}
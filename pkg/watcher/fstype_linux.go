@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+// file: pkg/watcher/fstype_linux.go
+// version: 1.0.0
+// guid: d4a9e6b2-7f1c-4358-9b0e-5c2a8f3d6e19
+
+package watcher
+
+import "syscall"
+
+// remoteFilesystems maps the statfs magic numbers of filesystems that do not
+// deliver inotify events for changes made by other clients to their names.
+var remoteFilesystems = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+	0x00c36400: "ceph",
+	0x5346414f: "afs",
+	0x47504653: "gpfs",
+	0x0bd00bd0: "lustre",
+}
+
+// remoteFilesystem returns the name of the network or FUSE filesystem dir is
+// on, or an empty string for local filesystems.
+func remoteFilesystem(dir string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return ""
+	}
+	return remoteFilesystems[uint32(st.Type)]
+}
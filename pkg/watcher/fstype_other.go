@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+// file: pkg/watcher/fstype_other.go
+// version: 1.0.0
+// guid: 2b8d5f3a-9e4c-4a71-8f06-b3c7e1d9a452
+
+package watcher
+
+// remoteFilesystem always returns an empty string because filesystem types
+// are only detected on Linux. Use ModePoll for network mounts elsewhere.
+func remoteFilesystem(dir string) string {
+	return ""
+}
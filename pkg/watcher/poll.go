@@ -0,0 +1,230 @@
+// file: pkg/watcher/poll.go
+// version: 1.0.0
+// guid: 6a2d8e41-5b7c-4f93-9e1a-c3f0b6d7a528
+
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Watch modes selecting how directories are monitored.
+const (
+	// ModeAuto polls directories on network and FUSE filesystems and uses
+	// fsnotify everywhere else.
+	ModeAuto = "auto"
+	// ModeNotify uses fsnotify for every directory.
+	ModeNotify = "fsnotify"
+	// ModePoll polls every directory.
+	ModePoll = "poll"
+)
+
+// DefaultPollInterval is how often polled directories are listed when
+// watch.poll_interval is not configured.
+const DefaultPollInterval = 30 * time.Second
+
+// Mode returns the configured watch mode.
+func Mode() string {
+	if viper.IsSet("watch.mode") {
+		return viper.GetString("watch.mode")
+	}
+	return ModeAuto
+}
+
+// PollInterval returns the configured interval between directory listings
+// in polling mode.
+func PollInterval() time.Duration {
+	if viper.IsSet("watch.poll_interval") {
+		return viper.GetDuration("watch.poll_interval")
+	}
+	return DefaultPollInterval
+}
+
+// dirEntry is what the poller remembers about one file or directory.
+type dirEntry struct {
+	size  int64
+	mtime time.Time
+	inode uint64
+	dir   bool
+}
+
+// poller watches directories by listing them on an interval and sending the
+// differences as fsnotify events. Like fsnotify, it watches the entries of
+// each added directory but not their subdirectories. A file that moved
+// between watched directories is reported as a Rename of the old path
+// followed by a Create of the new one, matched by inode.
+type poller struct {
+	mu     sync.Mutex
+	dirs   map[string]map[string]dirEntry
+	events chan<- fsnotify.Event
+	errors chan<- error
+	done   <-chan struct{}
+}
+
+// newPoller starts a poller listing its directories every interval. Events
+// and errors are sent on the given channels until done is closed.
+func newPoller(interval time.Duration, events chan<- fsnotify.Event, errs chan<- error, done <-chan struct{}) *poller {
+	p := &poller{dirs: make(map[string]map[string]dirEntry), events: events, errors: errs, done: done}
+	go p.run(max(interval, 10*time.Millisecond))
+	return p
+}
+
+// Add starts watching dir. Entries already present produce no events.
+func (p *poller) Add(dir string) error {
+	entries, err := listDir(dir)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.dirs[dir]; !ok {
+		p.dirs[dir] = entries
+	}
+	return nil
+}
+
+// Remove stops watching dir.
+func (p *poller) Remove(dir string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.dirs, dir)
+}
+
+func (p *poller) run(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-tick.C:
+			events, errs := p.scan()
+			for _, err := range errs {
+				select {
+				case p.errors <- err:
+				case <-p.done:
+					return
+				}
+			}
+			for _, ev := range events {
+				select {
+				case p.events <- ev:
+				case <-p.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// scan lists every watched directory and returns the changes since the
+// previous scan. Directories that no longer exist are dropped silently; their
+// removal is reported by the watch on their parent.
+func (p *poller) scan() ([]fsnotify.Event, []error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prev := make(map[string]dirEntry)
+	cur := make(map[string]dirEntry)
+	var errs []error
+	for dir, old := range p.dirs {
+		entries, err := listDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				delete(p.dirs, dir)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("poll %s: %w", dir, err))
+			entries = old
+		}
+		p.dirs[dir] = entries
+		for path, e := range old {
+			prev[path] = e
+		}
+		for path, e := range entries {
+			cur[path] = e
+		}
+	}
+	return diffEntries(prev, cur), errs
+}
+
+// diffEntries returns the events turning prev into cur: a Rename and a
+// Create for an inode that moved to a new path, Remove and Create for other
+// paths that disappeared or appeared, and Write for files whose size or
+// modification time changed.
+func diffEntries(prev, cur map[string]dirEntry) []fsnotify.Event {
+	var removed, added, changed []string
+	for path, e := range prev {
+		n, ok := cur[path]
+		switch {
+		case !ok:
+			removed = append(removed, path)
+		case !e.dir && (n.size != e.size || !n.mtime.Equal(e.mtime)):
+			changed = append(changed, path)
+		}
+	}
+	for path := range cur {
+		if _, ok := prev[path]; !ok {
+			added = append(added, path)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	sort.Strings(changed)
+
+	byInode := make(map[uint64]string)
+	for _, path := range added {
+		if ino := cur[path].inode; ino != 0 {
+			byInode[ino] = path
+		}
+	}
+	renamed := make(map[string]bool)
+	var events []fsnotify.Event
+	for _, path := range removed {
+		e := prev[path]
+		if to, ok := byInode[e.inode]; ok && e.inode != 0 && cur[to].dir == e.dir {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Rename}, fsnotify.Event{Name: to, Op: fsnotify.Create})
+			renamed[to] = true
+			delete(byInode, e.inode)
+			continue
+		}
+		events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+	}
+	for _, path := range added {
+		if !renamed[path] {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		}
+	}
+	for _, path := range changed {
+		events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+	}
+	return events
+}
+
+// listDir returns the entries of dir keyed by path.
+func listDir(dir string) (map[string]dirEntry, error) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]dirEntry, len(des))
+	for _, de := range des {
+		fi, err := de.Info()
+		if err != nil {
+			// Removed between listing and stat.
+			continue
+		}
+		path := filepath.Join(dir, de.Name())
+		entries[path] = dirEntry{size: fi.Size(), mtime: fi.ModTime(), inode: inodeOf(fi), dir: fi.IsDir()}
+	}
+	return entries, nil
+}
@@ -0,0 +1,131 @@
+// file: pkg/watcher/poll_test.go
+// version: 1.0.0
+// guid: 3c9a7e52-1d6b-4f08-a4e3-8b5f2c0d7e96
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	providersmocks "github.com/jdfalk/subtitle-manager/pkg/providers/mocks"
+)
+
+func TestDiffEntries(t *testing.T) {
+	now := time.Now()
+	prev := map[string]dirEntry{
+		"/a/old.mkv":  {size: 1, mtime: now, inode: 1},
+		"/a/gone.mkv": {size: 1, mtime: now, inode: 2},
+		"/a/grow.mkv": {size: 1, mtime: now, inode: 3},
+		"/a/same.mkv": {size: 1, mtime: now, inode: 4},
+		"/a/dir":      {mtime: now, inode: 5, dir: true},
+	}
+	cur := map[string]dirEntry{
+		"/b/new.mkv":  {size: 1, mtime: now, inode: 1},
+		"/a/grow.mkv": {size: 2, mtime: now, inode: 3},
+		"/a/same.mkv": {size: 1, mtime: now, inode: 4},
+		"/a/dir":      {mtime: now.Add(time.Second), inode: 5, dir: true},
+		"/a/add.mkv":  {size: 1, mtime: now, inode: 6},
+	}
+	assert.Equal(t, []fsnotify.Event{
+		{Name: "/a/gone.mkv", Op: fsnotify.Remove},
+		{Name: "/a/old.mkv", Op: fsnotify.Rename},
+		{Name: "/b/new.mkv", Op: fsnotify.Create},
+		{Name: "/a/add.mkv", Op: fsnotify.Create},
+		{Name: "/a/grow.mkv", Op: fsnotify.Write},
+	}, diffEntries(prev, cur))
+}
+
+// nextEvent returns the next event of the watch set or fails after a second.
+func nextEvent(t *testing.T, s *watchSet) fsnotify.Event {
+	t.Helper()
+	select {
+	case ev := <-s.Events:
+		return ev
+	case err := <-s.Errors:
+		t.Fatalf("watch error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+	return fsnotify.Event{}
+}
+
+func TestPollingWatchSetEmitsEvents(t *testing.T) {
+	root := t.TempDir()
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	require.NoError(t, os.Mkdir(a, 0755))
+	require.NoError(t, os.Mkdir(b, 0755))
+	existing := filepath.Join(a, "existing.mkv")
+	require.NoError(t, os.WriteFile(existing, []byte("x"), 0644))
+
+	s, err := newWatchSet(ModePoll, 20*time.Millisecond, logging.GetLogger("watcher"))
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Add(a))
+	require.NoError(t, s.Add(b))
+
+	video := filepath.Join(a, "video.mkv")
+	require.NoError(t, os.WriteFile(video, []byte("x"), 0644))
+	assert.Equal(t, fsnotify.Event{Name: video, Op: fsnotify.Create}, nextEvent(t, s))
+
+	require.NoError(t, os.WriteFile(video, []byte("xx"), 0644))
+	assert.Equal(t, fsnotify.Event{Name: video, Op: fsnotify.Write}, nextEvent(t, s))
+
+	moved := filepath.Join(b, "video.mkv")
+	require.NoError(t, os.Rename(video, moved))
+	assert.Equal(t, fsnotify.Event{Name: video, Op: fsnotify.Rename}, nextEvent(t, s))
+	assert.Equal(t, fsnotify.Event{Name: moved, Op: fsnotify.Create}, nextEvent(t, s))
+
+	require.NoError(t, os.Remove(existing))
+	assert.Equal(t, fsnotify.Event{Name: existing, Op: fsnotify.Remove}, nextEvent(t, s))
+}
+
+func TestNewWatchSetRejectsUnknownMode(t *testing.T) {
+	_, err := newWatchSet("inotify", time.Second, logging.GetLogger("watcher"))
+	assert.Error(t, err)
+}
+
+func TestWatchDirectoryRecursivePolling(t *testing.T) {
+	dir := t.TempDir()
+	viper.Set("media_directory", dir)
+	viper.Set("watch.mode", ModePoll)
+	viper.Set("watch.poll_interval", "20ms")
+	viper.Set("watch.stable_period", "50ms")
+	defer viper.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	m := providersmocks.NewMockProvider(t)
+	m.On("Fetch", mock.Anything, mock.Anything, "en").Return([]byte("sub"), nil)
+	done := make(chan struct{})
+	go func() {
+		if err := WatchDirectoryRecursive(ctx, dir, "en", "test", m, nil); err != context.Canceled {
+			t.Errorf("watch recursive: %v", err)
+		}
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Files in a directory created while watching are picked up once the
+	// directory is polled.
+	subdir := filepath.Join(dir, "Show", "Season 1")
+	require.NoError(t, os.MkdirAll(subdir, 0755))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(subdir, "video.mkv"), []byte("x"), 0644))
+
+	out := filepath.Join(subdir, "video.en.srt")
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(out)
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond, "subtitle not downloaded")
+	cancel()
+	<-done
+}
@@ -4,32 +4,28 @@ import (
 	"context"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/fileindex"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/providers"
 	"github.com/jdfalk/subtitle-manager/pkg/scanner"
 	"github.com/jdfalk/subtitle-manager/pkg/security"
 )
 
-var videoExtensions = []string{".mkv", ".mp4", ".avi", ".mov"}
-
-func isVideoFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	for _, e := range videoExtensions {
-		if ext == e {
-			return true
-		}
-	}
-	return false
-}
-
 // WatchDirectory monitors dir for new video files and downloads subtitles using
 // provider p for the given language. Subtitles are written next to the media
-// file with the language code appended before the extension.
+// file with the language code appended before the extension. Files are
+// processed once their size and modification time have been stable for
+// StablePeriod, and videos moved within the watched tree keep their existing
+// records instead of being searched again.
+//
+// Directories on network and FUSE filesystems, which do not deliver fsnotify
+// events, are polled every PollInterval unless Mode selects fsnotify or
+// polling for every directory.
 func WatchDirectory(ctx context.Context, dir, lang, providerName string, p providers.Provider, store database.SubtitleStore) error {
 	logger := logging.GetLogger("watcher")
 
@@ -38,7 +34,7 @@ func WatchDirectory(ctx context.Context, dir, lang, providerName string, p provi
 		return err
 	}
 
-	w, err := fsnotify.NewWatcher()
+	w, err := newWatchSet(Mode(), PollInterval(), logger)
 	if err != nil {
 		return err
 	}
@@ -48,6 +44,14 @@ func WatchDirectory(ctx context.Context, dir, lang, providerName string, p provi
 		return err
 	}
 
+	d := newDebouncer(StablePeriod(), store, logger, func(ctx context.Context, path string) error {
+		return scanner.ProcessFile(ctx, path, lang, providerName, p, false, store)
+	})
+	d.start(ctx)
+	defer d.stop()
+	tick := time.NewTicker(d.interval())
+	defer tick.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -55,11 +59,9 @@ func WatchDirectory(ctx context.Context, dir, lang, providerName string, p provi
 		case err := <-w.Errors:
 			logger.Warnf("watch error: %v", err)
 		case ev := <-w.Events:
-			if ev.Op&(fsnotify.Create|fsnotify.Rename|fsnotify.Write) != 0 && isVideoFile(ev.Name) {
-				if err := scanner.ProcessFile(ctx, ev.Name, lang, providerName, p, false, store); err != nil {
-					logger.Warnf("process %s: %v", ev.Name, err)
-				}
-			}
+			d.observe(ev)
+		case <-tick.C:
+			d.flush(ctx)
 		}
 	}
 }
@@ -75,7 +77,7 @@ func WatchDirectoryRecursive(ctx context.Context, dir, lang, providerName string
 		return err
 	}
 
-	w, err := fsnotify.NewWatcher()
+	w, err := newWatchSet(Mode(), PollInterval(), logger)
 	if err != nil {
 		return err
 	}
@@ -93,6 +95,14 @@ func WatchDirectoryRecursive(ctx context.Context, dir, lang, providerName string
 		return err
 	}
 
+	d := newDebouncer(StablePeriod(), store, logger, func(ctx context.Context, path string) error {
+		return scanner.ProcessFile(ctx, path, lang, providerName, p, false, store)
+	})
+	d.start(ctx)
+	defer d.stop()
+	tick := time.NewTicker(d.interval())
+	defer tick.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -102,14 +112,24 @@ func WatchDirectoryRecursive(ctx context.Context, dir, lang, providerName string
 		case ev := <-w.Events:
 			if ev.Op&fsnotify.Create != 0 {
 				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
-					_ = w.Add(ev.Name)
-				}
-			}
-			if ev.Op&(fsnotify.Create|fsnotify.Rename|fsnotify.Write) != 0 && isVideoFile(ev.Name) {
-				if err := scanner.ProcessFile(ctx, ev.Name, lang, providerName, p, false, store); err != nil {
-					logger.Warnf("process %s: %v", ev.Name, err)
+					// Directories moved into the tree arrive with their
+					// files, which produce no events of their own.
+					_ = filepath.WalkDir(ev.Name, func(path string, entry os.DirEntry, err error) error {
+						if err != nil {
+							return nil
+						}
+						if entry.IsDir() {
+							_ = w.Add(path)
+						} else if fileindex.IsVideo(path) {
+							d.add(path)
+						}
+						return nil
+					})
 				}
 			}
+			d.observe(ev)
+		case <-tick.C:
+			d.flush(ctx)
 		}
 	}
 }
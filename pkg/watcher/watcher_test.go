@@ -15,6 +15,7 @@ import (
 func TestWatchDirectory(t *testing.T) {
 	dir := t.TempDir()
 	viper.Set("media_directory", dir)
+	viper.Set("watch.stable_period", "50ms")
 	defer viper.Reset()
 	ctx, cancel := context.WithCancel(context.Background())
 	m := providersmocks.NewMockProvider(t)
@@ -53,6 +54,7 @@ func TestWatchDirectoryRecursive(t *testing.T) {
 		t.Fatalf("mkdir: %v", err)
 	}
 	viper.Set("media_directory", dir)
+	viper.Set("watch.stable_period", "50ms")
 	defer viper.Reset()
 	ctx, cancel := context.WithCancel(context.Background())
 	m := providersmocks.NewMockProvider(t)
@@ -0,0 +1,105 @@
+// file: pkg/watcher/watchset.go
+// version: 1.0.0
+// guid: 7f3c1e85-2a9d-4b46-8e07-d5b2a6c4f913
+
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// watchSet watches directories with fsnotify or the poller depending on the
+// watch mode and, in ModeAuto, the filesystem each directory is on. Events
+// from both are delivered on Events and Errors.
+type watchSet struct {
+	Events chan fsnotify.Event
+	Errors chan error
+
+	notify *fsnotify.Watcher
+	poll   *poller
+	logger *logrus.Entry
+	done   chan struct{}
+}
+
+// newWatchSet returns a watchSet for mode. interval is how often polled
+// directories are listed.
+func newWatchSet(mode string, interval time.Duration, logger *logrus.Entry) (*watchSet, error) {
+	switch mode {
+	case ModeAuto, ModeNotify, ModePoll:
+	default:
+		return nil, fmt.Errorf("unknown watch mode %q", mode)
+	}
+	s := &watchSet{
+		Events: make(chan fsnotify.Event),
+		Errors: make(chan error),
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	if mode != ModePoll {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		s.notify = w
+		go s.forward()
+	}
+	if mode != ModeNotify {
+		s.poll = newPoller(interval, s.Events, s.Errors, s.done)
+	}
+	return s, nil
+}
+
+// Add starts watching dir.
+func (s *watchSet) Add(dir string) error {
+	if s.notify == nil {
+		return s.poll.Add(dir)
+	}
+	if s.poll != nil {
+		if fs := remoteFilesystem(dir); fs != "" {
+			s.logger.Debugf("polling %s on %s filesystem", dir, fs)
+			return s.poll.Add(dir)
+		}
+	}
+	return s.notify.Add(dir)
+}
+
+// Close stops watching every directory.
+func (s *watchSet) Close() error {
+	close(s.done)
+	if s.notify != nil {
+		return s.notify.Close()
+	}
+	return nil
+}
+
+// forward passes fsnotify events and errors on until the set is closed.
+func (s *watchSet) forward() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case ev, ok := <-s.notify.Events:
+			if !ok {
+				return
+			}
+			select {
+			case s.Events <- ev:
+			case <-s.done:
+				return
+			}
+		case err, ok := <-s.notify.Errors:
+			if !ok {
+				return
+			}
+			select {
+			case s.Errors <- err:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
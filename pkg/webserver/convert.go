@@ -1,4 +1,7 @@
 // file: pkg/webserver/convert.go
+// version: 1.1.0
+// guid: 5d8c2e71-9a4b-4f36-b0d7-3e6f1a9c8b24
+
 package webserver
 
 import (
@@ -6,13 +9,17 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
 )
 
 // convertHandler handles POST /api/convert requests.
-// It expects a multipart form with a "file" field containing a subtitle file.
-// The subtitle is converted to SRT and returned as a downloadable attachment.
+// It expects a multipart form with a "file" field containing a subtitle file
+// and an optional "format" field naming the target format (srt, ass, ssa,
+// vtt, ttml, stl or sub; default srt). MicroDVD timings use the optional
+// "fps" field. The converted subtitle is returned as a downloadable
+// attachment.
 func convertHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -26,6 +33,23 @@ func convertHandler() http.Handler {
 		}
 		defer f.Close()
 
+		target := subtitles.FormatSRT
+		if v := r.FormValue("format"); v != "" {
+			if target, err = subtitles.ParseFormat(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var opts subtitles.ConvertOptions
+		if v := r.FormValue("fps"); v != "" {
+			fps, err := strconv.ParseFloat(v, 64)
+			if err != nil || fps <= 0 {
+				http.Error(w, "invalid fps", http.StatusBadRequest)
+				return
+			}
+			opts.FPS = fps
+		}
+
 		// Preserve the original file extension for astisub format detection
 		ext := filepath.Ext(hdr.Filename)
 		tmp, err := os.CreateTemp("", "convert-*"+ext)
@@ -39,13 +63,13 @@ func convertHandler() http.Handler {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		data, err := subtitles.ConvertToSRT(tmp.Name())
+		data, err := subtitles.Convert(tmp.Name(), target, opts)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		w.Header().Set("Content-Type", "application/x-subrip")
-		w.Header().Set("Content-Disposition", "attachment; filename=\"converted.srt\"")
+		w.Header().Set("Content-Type", target.ContentType())
+		w.Header().Set("Content-Disposition", "attachment; filename=\"converted"+target.Ext()+"\"")
 		_, _ = w.Write(data)
 	})
 }
@@ -0,0 +1,70 @@
+// file: pkg/webserver/convert_test.go
+// version: 1.0.0
+// guid: 0e7b3f52-4c1d-4a98-8d26-b59c1e7f3a40
+
+package webserver
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// convertRequest builds a multipart /api/convert request for the test SRT
+// file with the given form fields.
+func convertRequest(t *testing.T, fields map[string]string) *http.Request {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/simple.srt")
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	fw, err := mw.CreateFormFile("file", "simple.srt")
+	if err != nil {
+		t.Fatalf("form file: %v", err)
+	}
+	_, _ = fw.Write(data)
+	for k, v := range fields {
+		_ = mw.WriteField(k, v)
+	}
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/convert", buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// TestConvertHandlerFormat verifies that /api/convert honours the format
+// field and rejects unknown formats.
+func TestConvertHandlerFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	convertHandler().ServeHTTP(rr, convertRequest(t, map[string]string{"format": "vtt"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/vtt" {
+		t.Fatalf("content type %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.Contains(cd, "converted.vtt") {
+		t.Fatalf("content disposition %q", cd)
+	}
+	if !strings.HasPrefix(rr.Body.String(), "WEBVTT") {
+		t.Fatalf("unexpected body:\n%s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	convertHandler().ServeHTTP(rr, convertRequest(t, map[string]string{"format": "sub", "fps": "25"}))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Body.String(), "{1}{1}25\n{25}{50}Hello world") {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	convertHandler().ServeHTTP(rr, convertRequest(t, map[string]string{"format": "doc"}))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
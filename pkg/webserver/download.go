@@ -1,5 +1,5 @@
 // file: pkg/webserver/download.go
-// version: 1.0.1
+// version: 1.5.0
 // guid: d4467b2f-6653-4124-ab88-235fce8b0f77
 
 package webserver
@@ -8,11 +8,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"os"
 
+	"github.com/jdfalk/subtitle-manager/pkg/archive"
 	"github.com/jdfalk/subtitle-manager/pkg/database"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/metrics"
 	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
 	"github.com/jdfalk/subtitle-manager/pkg/scanner"
 	"github.com/jdfalk/subtitle-manager/pkg/security"
 	"github.com/sirupsen/logrus"
@@ -22,7 +26,10 @@ import (
 //
 // POST requests expect a JSON body {"provider":"generic","path":"/file.mkv","lang":"en"}.
 // The subtitle is written next to the media file and the resulting path is
-// returned as JSON {"file":"/file.en.srt"}.
+// returned as JSON {"file":"/file.en.srt"}. The search runs as a
+// search_download job on the queue and the request waits for it. An optional
+// "candidate" object returned by /api/search downloads that specific result
+// instead of letting the provider choose.
 //
 // Improvements:
 // - Extensive documentation for handler and logic
@@ -35,6 +42,8 @@ func downloadHandler(db *sql.DB) http.Handler {
 		Provider string `json:"provider"`
 		Path     string `json:"path"`
 		Lang     string `json:"lang"`
+		// Candidate selects a specific search result to download.
+		Candidate *providers.Candidate `json:"candidate,omitempty"`
 	}
 	type resp struct {
 		File string `json:"file"`
@@ -122,8 +131,59 @@ func downloadHandler(db *sql.DB) http.Handler {
 			"provider": name,
 		}).Info("starting download")
 
-		// Process the file using the scanner
-		if err := scanner.ProcessFile(r.Context(), validatedPath, q.Lang, name, p, false, nil); err != nil {
+		if q.Candidate != nil {
+			// Candidates come from the client and are untrusted. They can
+			// only be resolved by the provider that produced them, and
+			// URL-shaped tokens must be public HTTP links for every provider
+			// to prevent SSRF.
+			token := q.Candidate.DownloadToken
+			_, structured := p.(providers.CandidateSearcher)
+			if p == nil || ((isURLToken(token) || !structured) && !isValidURL(token)) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(apiError{Error: "Invalid candidate for provider"})
+				return
+			}
+			out, outErr := security.ValidateSubtitleOutputPath(validatedPath, q.Lang)
+			if outErr != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = json.NewEncoder(w).Encode(apiError{Error: "Failed to construct output path: " + outErr.Error()})
+				return
+			}
+			data, err := providers.DownloadCandidate(r.Context(), p, *q.Candidate)
+			if err == nil {
+				data, _, err = archive.Extract(data, validatedPath)
+			}
+			if err == nil {
+				data, _ = scanner.NormalizeEncoding(data, q.Lang)
+				err = os.WriteFile(out, data, 0644)
+			}
+			if err != nil {
+				logger.WithFields(logrus.Fields{
+					"path":     validatedPath,
+					"provider": name,
+					"error":    err,
+				}).Error("failed to download candidate")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = json.NewEncoder(w).Encode(apiError{Error: "Failed to download subtitle: " + err.Error()})
+				return
+			}
+			// Queued downloads record themselves; candidates are recorded here.
+			if db != nil {
+				score := scanner.NormalizedScore(scanner.CandidateScore(*q.Candidate, validatedPath))
+				if err := database.InsertScoredDownload(db, out, validatedPath, name, q.Lang, score); err != nil {
+					logger.WithFields(logrus.Fields{
+						"file":  out,
+						"path":  validatedPath,
+						"lang":  q.Lang,
+						"error": err,
+					}).Warn("failed to record download")
+				}
+			}
+		} else if err := jobs.Run(r.Context(), queue.JobTypeSearchDownload, jobs.SearchDownloadPayload{Path: validatedPath, Language: q.Lang, Provider: name}); err != nil {
+			// Search and download through the job queue
 			logger.WithFields(logrus.Fields{
 				"path":     validatedPath,
 				"lang":     q.Lang,
@@ -150,18 +210,6 @@ func downloadHandler(db *sql.DB) http.Handler {
 			return
 		}
 
-		// Insert download record into database if available
-		if db != nil {
-			if err := database.InsertDownload(db, out, validatedPath, name, q.Lang); err != nil {
-				logger.WithFields(logrus.Fields{
-					"file":  out,
-					"path":  validatedPath,
-					"lang":  q.Lang,
-					"error": err,
-				}).Warn("failed to record download")
-			}
-		}
-
 		metrics.APIRequests.WithLabelValues("/api/download", "POST", "200").Inc()
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(resp{File: out})
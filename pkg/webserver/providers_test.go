@@ -9,6 +9,8 @@ import (
 	"testing"
 
 	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
 )
 
 // TestProvidersHandlerGet verifies that the handler lists providers
@@ -62,3 +64,58 @@ func TestProvidersHandlerUpdate(t *testing.T) {
 		t.Fatalf("value not written")
 	}
 }
+
+// TestProvidersHandlerRedactsSecrets verifies secrets are never returned and
+// that saving the redacted value keeps the stored secret.
+func TestProvidersHandlerRedactsSecrets(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("providers.generic.config", map[string]any{"api_url": "http://example.com", "password": "secret"})
+
+	req := httptest.NewRequest("GET", "/api/providers", nil)
+	rr := httptest.NewRecorder()
+	providersHandler().ServeHTTP(rr, req)
+	var resp []ProviderInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, p := range resp {
+		if p.Name != "generic" {
+			continue
+		}
+		if p.Config["password"] != settings.Redacted {
+			t.Fatalf("password not redacted: %v", p.Config)
+		}
+		if _, ok := p.Settings.Field(settings.KeyAPIURL); !ok {
+			t.Fatalf("schema missing api_url: %+v", p.Settings)
+		}
+	}
+
+	body := `{"name":"generic","enabled":true,"config":{"api_url":"http://example.com","password":"********"}}`
+	req = httptest.NewRequest("POST", "/api/providers", bytes.NewBufferString(body))
+	rr = httptest.NewRecorder()
+	providersHandler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+	if viper.GetString("providers.generic.config.password") != "secret" {
+		t.Fatalf("stored secret overwritten")
+	}
+}
+
+// TestProvidersHandlerValidates verifies invalid settings are rejected.
+func TestProvidersHandlerValidates(t *testing.T) {
+	defer viper.Reset()
+	for _, body := range []string{
+		`{"name":"generic","enabled":true,"config":{}}`,
+		`{"name":"generic","enabled":false,"config":{"timeout":"soon"}}`,
+		`{"name":"generic","enabled":false,"config":{"unknown":"x"}}`,
+		`{"name":"no-such-provider","enabled":true,"config":{}}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/providers", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		providersHandler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+}
@@ -1,28 +1,19 @@
 // file: pkg/webserver/scan.go
+// version: 1.1.0
+// guid: 8f2d4b6a-1c3e-4a5f-9b7d-0e6c2a8f4d91
+
 package webserver
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/spf13/viper"
-
-	"github.com/jdfalk/subtitle-manager/pkg/database"
-	"github.com/jdfalk/subtitle-manager/pkg/metadata"
-	"github.com/jdfalk/subtitle-manager/pkg/providers"
-	"github.com/jdfalk/subtitle-manager/pkg/radarr"
-	"github.com/jdfalk/subtitle-manager/pkg/scanner"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
 	"github.com/jdfalk/subtitle-manager/pkg/security"
-	"github.com/jdfalk/subtitle-manager/pkg/sonarr"
-	"github.com/jdfalk/subtitle-manager/pkg/tasks"
 )
 
 // scanStatus tracks progress for an active scan.
@@ -49,6 +40,39 @@ var (
 	libStatus = libScanStatus{Files: []string{}}
 )
 
+func init() {
+	jobs.ObserveFiles(func(ev jobs.FileEvent) {
+		switch ev.Type {
+		case queue.JobTypeScan:
+			scanMu.Lock()
+			status.Completed++
+			status.Files = append(status.Files, ev.File)
+			scanMu.Unlock()
+		case queue.JobTypeSync:
+			libMu.Lock()
+			libStatus.Completed++
+			libStatus.Files = append(libStatus.Files, ev.File)
+			libMu.Unlock()
+		}
+	})
+}
+
+// waitForJob polls the queue until job id finishes and then calls done.
+func waitForJob(id string, done func()) {
+	defer done()
+	for {
+		rec, err := queue.GetQueue().Job(id)
+		if err != nil || rec == nil {
+			return
+		}
+		switch rec.Status {
+		case queue.StatusCompleted, queue.StatusCancelled, queue.StatusDead:
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
 func scanHandler() http.Handler {
 	type req struct {
 		Provider  string `json:"provider"`
@@ -98,80 +122,24 @@ func scanHandler() http.Handler {
 		status = scanStatus{Running: true, Files: []string{}}
 		scanMu.Unlock()
 
-		// Start task with proper integration
-		taskID := fmt.Sprintf("scan-%s-%s", q.Directory, q.Lang)
-		task := tasks.Start(r.Context(), taskID, func(ctx context.Context) error {
-			var p providers.Provider
-			var err error
-			if q.Provider != "" {
-				p, err = providers.Get(q.Provider, "")
-				if err != nil {
-					return err
-				}
-			}
-
-			// Count files for progress tracking
-			var fileCount int
-			videoExtensions := []string{".mkv", ".mp4", ".avi", ".mov"}
-			isVideoFile := func(path string) bool {
-				ext := strings.ToLower(filepath.Ext(path))
-				for _, e := range videoExtensions {
-					if ext == e {
-						return true
-					}
-				}
-				return false
-			}
-
-			err = filepath.Walk(q.Directory, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if !info.IsDir() && isVideoFile(path) {
-					fileCount++
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-
-			processed := 0
-			cb := func(f string) {
-				scanMu.Lock()
-				status.Completed++
-				status.Files = append(status.Files, f)
-				scanMu.Unlock()
-
-				processed++
-				if fileCount > 0 {
-					tasks.Update(taskID, (processed*100)/fileCount)
-				}
-			}
-
-			// Open database store for download tracking
-			var store database.SubtitleStore
-			if dbPath := viper.GetString("db_path"); dbPath != "" {
-				backend := viper.GetString("db_backend")
-				if s, err := database.OpenStore(dbPath, backend); err == nil {
-					store = s
-					defer s.Close()
-				}
-			}
-
-			return scanner.ScanDirectoryProgress(ctx, q.Directory, q.Lang, q.Provider, p, false, 2, store, cb)
-		})
-
-		go func() {
-			// Wait for task completion and clean up local status
-			<-time.After(100 * time.Millisecond)
-			for task.GetStatus() == "running" {
-				<-time.After(1 * time.Second)
-			}
+		taskID, err := jobs.Enqueue(queue.JobTypeScan, jobs.ScanPayload{
+			Directory: q.Directory,
+			Language:  q.Lang,
+			Provider:  q.Provider,
+			Workers:   2,
+		}, queue.JobOptions{MaxAttempts: 1, Owner: requestOwner(r)})
+		if err != nil {
 			scanMu.Lock()
 			status.Running = false
 			scanMu.Unlock()
-		}()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		go waitForJob(taskID, func() {
+			scanMu.Lock()
+			status.Running = false
+			scanMu.Unlock()
+		})
 
 		w.WriteHeader(http.StatusAccepted)
 		json.NewEncoder(w).Encode(map[string]string{"task_id": taskID})
@@ -215,55 +183,19 @@ func libraryScanHandler(db *sql.DB) http.Handler {
 		}
 		libStatus = libScanStatus{Running: true, Files: []string{}}
 		libMu.Unlock()
-		go func() {
-			store, err := database.OpenStoreWithConfig()
-			if err != nil {
-				libMu.Lock()
-				libStatus.Running = false
-				libMu.Unlock()
-				return
-			}
-			defer store.Close()
-			cb := func(f string) {
-				libMu.Lock()
-				libStatus.Completed++
-				libStatus.Files = append(libStatus.Files, f)
-				libMu.Unlock()
-			}
-			_ = metadata.ScanLibraryProgress(context.Background(), q.Path, store, cb)
-
-			if viper.GetBool("integrations.radarr.enabled") {
-				host := viper.GetString("integrations.radarr.host")
-				port := viper.GetString("integrations.radarr.port")
-				key := viper.GetString("integrations.radarr.api_key")
-				ssl := viper.GetBool("integrations.radarr.ssl")
-				base := strings.Trim(viper.GetString("integrations.radarr.base_url"), "/")
-				scheme := "http"
-				if ssl {
-					scheme = "https"
-				}
-				url := fmt.Sprintf("%s://%s:%s/%s", scheme, host, port, base)
-				c := radarr.NewClient(url, key)
-				_ = radarr.Sync(context.Background(), c, store)
-			}
-			if viper.GetBool("integrations.sonarr.enabled") {
-				host := viper.GetString("integrations.sonarr.host")
-				port := viper.GetString("integrations.sonarr.port")
-				key := viper.GetString("integrations.sonarr.api_key")
-				ssl := viper.GetBool("integrations.sonarr.ssl")
-				base := strings.Trim(viper.GetString("integrations.sonarr.base_url"), "/")
-				scheme := "http"
-				if ssl {
-					scheme = "https"
-				}
-				url := fmt.Sprintf("%s://%s:%s/%s", scheme, host, port, base)
-				c := sonarr.NewClient(url, key)
-				_ = sonarr.Sync(context.Background(), c, store)
-			}
+		id, err := jobs.Enqueue(queue.JobTypeSync, jobs.SyncPayload{Path: q.Path}, queue.JobOptions{MaxAttempts: 1, Owner: requestOwner(r)})
+		if err != nil {
 			libMu.Lock()
 			libStatus.Running = false
 			libMu.Unlock()
-		}()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		go waitForJob(id, func() {
+			libMu.Lock()
+			libStatus.Running = false
+			libMu.Unlock()
+		})
 		w.WriteHeader(http.StatusAccepted)
 	})
 }
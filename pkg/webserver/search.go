@@ -1,5 +1,5 @@
 // file: pkg/webserver/search.go
-// version: 1.1.1
+// version: 1.2.0
 // guid: 7e49aff0-0057-49b4-b507-1a57a5f8a923
 
 package webserver
@@ -9,6 +9,7 @@ import (
 	"crypto/sha1"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/scoring"
 	"github.com/spf13/viper"
 )
 
@@ -54,6 +56,13 @@ type SearchResult struct {
 	UploadDate  string  `json:"uploadDate,omitempty"`
 	IsHI        bool    `json:"isHI,omitempty"`        // Hearing Impaired
 	FromTrusted bool    `json:"fromTrusted,omitempty"` // From trusted uploader
+	IsForced    bool    `json:"isForced,omitempty"`    // Foreign parts only
+	Format      string  `json:"format,omitempty"`
+	Rating      float64 `json:"rating,omitempty"`
+	HashMatch   bool    `json:"hashMatch,omitempty"` // Matched by file hash
+	// Candidate is the structured provider result. Clients pass it back to
+	// /api/download to fetch this specific subtitle.
+	Candidate *providers.Candidate `json:"candidate,omitempty"`
 }
 
 // SearchResponse represents the complete search response
@@ -146,7 +155,8 @@ func searchCacheKey(req SearchRequest) string {
 	return fmt.Sprintf("%x", sum)
 }
 
-// isValidURL checks if the provided URL is a valid HTTP/HTTPS URL and not localhost or private IP
+// isValidURL checks if the provided URL is a valid HTTP/HTTPS URL and not
+// localhost or a loopback, private, link-local or unspecified IP address.
 func isValidURL(raw string) bool {
 	u, err := url.Parse(raw)
 	if err != nil {
@@ -156,16 +166,25 @@ func isValidURL(raw string) bool {
 		return false
 	}
 	// Prevent SSRF: block localhost and private IPs
-	host := u.Hostname()
-	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+	host := strings.ToLower(u.Hostname())
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
 		return false
 	}
-	if strings.HasPrefix(host, "10.") || strings.HasPrefix(host, "192.168.") || strings.HasPrefix(host, "172.") {
-		return false
+	if ip := net.ParseIP(host); ip != nil {
+		return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+			!ip.IsLinkLocalMulticast() && !ip.IsUnspecified()
 	}
 	return true
 }
 
+// isURLToken reports whether a candidate download token is shaped like a
+// URL, with a scheme or a host, rather than an ID or path only its provider
+// understands.
+func isURLToken(token string) bool {
+	u, err := url.Parse(token)
+	return err != nil || u.Scheme != "" || u.Host != ""
+}
+
 // searchHandler handles manual subtitle search requests
 func searchHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -319,9 +338,11 @@ func handleSearchQuery(w http.ResponseWriter, r *http.Request) {
 
 	// For backward compatibility with existing UI, return simple URL array if single provider
 	if len(providers) == 1 {
-		urls := make([]string, len(scoredResults))
-		for i, result := range scoredResults {
-			urls[i] = result.DownloadURL
+		urls := make([]string, 0, len(scoredResults))
+		for _, result := range scoredResults {
+			if result.DownloadURL != "" {
+				urls = append(urls, result.DownloadURL)
+			}
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(urls)
@@ -361,33 +382,21 @@ func performParallelSearch(ctx context.Context, req SearchRequest) ([]SearchResu
 				return
 			}
 
-			searcher, ok := provider.(providers.Searcher)
-			if !ok {
-				mu.Lock()
-				errs = append(errs, fmt.Errorf("provider %s does not support search", name))
-				mu.Unlock()
-				return
-			}
-
-			urls, err := searcher.Search(ctx, req.MediaPath, req.Language)
+			cands, err := providers.SearchCandidates(ctx, provider, name, req.MediaPath, req.Language)
 			if err != nil {
 				mu.Lock()
-				errs = append(errs, fmt.Errorf("provider %s search error: %w", name, err))
+				if errors.Is(err, providers.ErrSearchUnsupported) {
+					errs = append(errs, fmt.Errorf("provider %s does not support search", name))
+				} else {
+					errs = append(errs, fmt.Errorf("provider %s search error: %w", name, err))
+				}
 				mu.Unlock()
 				return
 			}
 
-			local := make([]SearchResult, len(urls))
-			for i, url := range urls {
-				local[i] = SearchResult{
-					ID:          fmt.Sprintf("%s_%d", name, i),
-					Provider:    name,
-					Name:        extractNameFromURL(url),
-					Language:    req.Language,
-					DownloadURL: url,
-					PreviewURL:  fmt.Sprintf("/api/search/preview?url=%s", url),
-					Score:       0.5,
-				}
+			local := make([]SearchResult, len(cands))
+			for i := range cands {
+				local[i] = searchResultFromCandidate(fmt.Sprintf("%s_%d", name, i), cands[i])
 			}
 
 			mu.Lock()
@@ -400,53 +409,79 @@ func performParallelSearch(ctx context.Context, req SearchRequest) ([]SearchResu
 	return results, errs
 }
 
-// calculateScores assigns relevance scores to search results
-func calculateScores(results []SearchResult, req SearchRequest) []SearchResult {
-	for i := range results {
-		score := 0.5 // Base score
-
-		// Score based on provider reliability
-		switch results[i].Provider {
-		case "opensubtitles":
-			score += 0.3
-		case "subscene":
-			score += 0.2
-		case "addic7ed":
-			score += 0.25
-		default:
-			score += 0.1
-		}
-
-		// Score based on name matching
-		if nameMatchScore := calculateNameMatch(results[i].Name, req.MediaPath); nameMatchScore > 0 {
-			score += nameMatchScore * 0.2
-		}
-
-		// Score based on additional criteria
-		if results[i].FromTrusted {
-			score += 0.1
-		}
-		if results[i].Downloads > 1000 {
-			score += 0.05
-		}
-
-		// Cap score at 1.0
-		if score > 1.0 {
-			score = 1.0
-		}
+// searchResultFromCandidate converts a provider candidate to the API result
+// shape. Candidates whose download token is a URL also get download and
+// preview links for older clients.
+func searchResultFromCandidate(id string, c providers.Candidate) SearchResult {
+	name := c.Release
+	if name == "" {
+		name = c.FileName
+	}
+	if name == "" {
+		name = "Subtitle"
+	}
+	res := SearchResult{
+		ID:          id,
+		Provider:    c.Provider,
+		Name:        name,
+		Language:    c.Language,
+		Score:       0.5,
+		Downloads:   c.Downloads,
+		IsHI:        c.HearingImpaired,
+		FromTrusted: c.Trusted,
+		IsForced:    c.Forced,
+		Format:      c.Format,
+		Rating:      c.Rating,
+		HashMatch:   c.HashMatch,
+		Candidate:   &c,
+	}
+	if !c.UploadDate.IsZero() {
+		res.UploadDate = c.UploadDate.Format(time.RFC3339)
+	}
+	if isValidURL(c.DownloadToken) {
+		res.DownloadURL = c.DownloadToken
+		res.PreviewURL = fmt.Sprintf("/api/search/preview?url=%s", url.QueryEscape(c.DownloadToken))
+	}
+	return res
+}
 
-		results[i].Score = score
+// calculateScores assigns relevance scores to search results using the
+// configured scoring profile and sorts them by score.
+func calculateScores(results []SearchResult, req SearchRequest) []SearchResult {
+	media := scoring.FromMediaPath(req.MediaPath)
+	if req.Season > 0 {
+		media.Season = req.Season
+		media.Episode = req.Episode
+	}
+	if req.ReleaseGroup != "" {
+		media.ReleaseGroup = req.ReleaseGroup
+	}
+	profile := scoring.LoadProfileFromConfig()
+	if err := scoring.ValidateProfile(profile); err != nil {
+		profile = scoring.DefaultProfile()
 	}
 
-	// Sort by score descending
-	for i := 0; i < len(results)-1; i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[i].Score < results[j].Score {
-				results[i], results[j] = results[j], results[i]
+	for i := range results {
+		var sub scoring.Subtitle
+		if results[i].Candidate != nil {
+			sub = scoring.FromCandidate(*results[i].Candidate)
+		} else {
+			sub = scoring.Subtitle{
+				ProviderName:    results[i].Provider,
+				Release:         results[i].Name,
+				HearingImpaired: results[i].IsHI,
+				IsTrusted:       results[i].FromTrusted,
+				DownloadCount:   results[i].Downloads,
 			}
 		}
+		score := scoring.CalculateScore(sub, media, profile)
+		results[i].Score = float64(score.Total) / 100
 	}
 
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
 	return results
 }
 
@@ -1,16 +1,25 @@
 // file: pkg/webserver/search_test.go
-// version: 1.0.0
+// version: 1.1.0
 // guid: d280fb2e-6941-4d64-b4c8-dc0bc3537680
 
 package webserver
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/jdfalk/subtitle-manager/pkg/metrics"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
 )
 
 func TestSearchHandlerMethodValidation(t *testing.T) {
@@ -232,3 +241,86 @@ func TestSearchRateLimit(t *testing.T) {
 		t.Fatalf("expected 429, got %d", rr.Code)
 	}
 }
+
+func TestIsValidURL(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"https://example.com/sub.srt":             true,
+		"http://172.217.1.1/sub.srt":              true,
+		"http://localhost/sub.srt":                false,
+		"http://api.localhost/sub.srt":            false,
+		"http://127.0.0.2/sub.srt":                false,
+		"http://169.254.169.254/latest/meta-data": false,
+		"http://172.16.0.1/sub.srt":               false,
+		"http://[::1]/sub.srt":                    false,
+		"http://[fd00::1]/sub.srt":                false,
+		"http://0.0.0.0/sub.srt":                  false,
+		"file:///etc/passwd":                      false,
+	} {
+		if got := isValidURL(raw); got != want {
+			t.Errorf("isValidURL(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+// tokenProvider is a structured searcher counting its downloads.
+type tokenProvider struct{ downloads *atomic.Int32 }
+
+func (p tokenProvider) Fetch(ctx context.Context, mediaPath, lang string) ([]byte, error) {
+	return nil, os.ErrNotExist
+}
+
+func (p tokenProvider) SearchCandidates(ctx context.Context, mediaPath, lang string) ([]providers.Candidate, error) {
+	return nil, nil
+}
+
+func (p tokenProvider) Download(ctx context.Context, c providers.Candidate) ([]byte, error) {
+	p.downloads.Add(1)
+	return []byte("1\n00:00:01,000 --> 00:00:02,000\nhi\n"), nil
+}
+
+// TestDownloadRejectsInternalCandidateTokens verifies URL-shaped candidate
+// tokens pointing to internal hosts are refused for structured searchers too,
+// while opaque tokens reach the provider.
+func TestDownloadRejectsInternalCandidateTokens(t *testing.T) {
+	if err := metrics.Initialize(); err != nil {
+		t.Fatalf("failed to init metrics: %v", err)
+	}
+	var downloads atomic.Int32
+	providers.RegisterFactory("tokentest", func() providers.Provider { return tokenProvider{downloads: &downloads} })
+	dir := t.TempDir()
+	viper.Set("media_directory", dir)
+	defer viper.Set("media_directory", nil)
+	media := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(media, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(token string) int {
+		body, _ := json.Marshal(map[string]any{
+			"provider":  "tokentest",
+			"path":      media,
+			"lang":      "en",
+			"candidate": providers.Candidate{ID: "1", DownloadToken: token},
+		})
+		rr := httptest.NewRecorder()
+		downloadHandler(nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/download", bytes.NewReader(body)))
+		return rr.Code
+	}
+	for _, token := range []string{"http://169.254.169.254/latest/meta-data/", "http://127.0.0.1:8080/admin", "//10.0.0.1/x", "gopher://example.com/"} {
+		if code := post(token); code != http.StatusBadRequest {
+			t.Errorf("token %q: expected 400, got %d", token, code)
+		}
+	}
+	if n := downloads.Load(); n != 0 {
+		t.Fatalf("provider downloaded %d rejected tokens", n)
+	}
+	if code := post("12345"); code != http.StatusOK {
+		t.Fatalf("opaque token: expected 200, got %d", code)
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Fatalf("expected one download, got %d", n)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "movie.en.srt")); err != nil || !strings.Contains(string(data), "hi") {
+		t.Fatalf("subtitle not written: %q %v", data, err)
+	}
+}
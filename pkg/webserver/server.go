@@ -1,5 +1,5 @@
 // file: pkg/webserver/server.go
-// version: 1.0.0
+// version: 1.5.0
 // guid: a3f02a01-bcb0-4d6e-a572-8138f7a6d720
 
 package webserver
@@ -25,14 +25,18 @@ import (
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	"github.com/jdfalk/subtitle-manager/pkg/events"
 	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/maintenance"
 	"github.com/jdfalk/subtitle-manager/pkg/metrics"
+	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/mediahash"
+	"github.com/jdfalk/subtitle-manager/pkg/providers/settings"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
 	"github.com/jdfalk/subtitle-manager/pkg/radarr"
 	"github.com/jdfalk/subtitle-manager/pkg/security"
 	"github.com/jdfalk/subtitle-manager/pkg/selftest"
 	"github.com/jdfalk/subtitle-manager/pkg/sonarr"
-	"github.com/jdfalk/subtitle-manager/pkg/subtitles"
 	"github.com/jdfalk/subtitle-manager/pkg/updater"
 	"github.com/jdfalk/subtitle-manager/pkg/webhooks"
 	"github.com/jdfalk/subtitle-manager/webui"
@@ -175,7 +179,8 @@ func Handler(db *sql.DB) (http.Handler, error) {
 	mux.Handle(prefix+"/api/errors/recent", authMiddleware(db, "basic", errorRecentHandler()))
 	mux.Handle(prefix+"/api/errors/top", authMiddleware(db, "basic", errorTopHandler()))
 	mux.Handle(prefix+"/api/tasks", authMiddleware(db, "basic", tasksHandler()))
-	mux.Handle(prefix+"/api/tasks/start", authMiddleware(db, "basic", startTaskHandler()))
+	mux.Handle(prefix+"/api/tasks/start", authMiddleware(db, "basic", startTaskHandler(db)))
+	mux.Handle(prefix+"/api/tasks/cancel", authMiddleware(db, "basic", cancelTaskHandler(db)))
 	mux.Handle(prefix+"/ws/tasks", authMiddleware(db, "basic", tasksWebSocketHandler()))
 	mux.Handle(prefix+"/api/providers/status", authMiddleware(db, "basic", providerStatusHandler()))
 	mux.Handle(prefix+"/api/providers/refresh", authMiddleware(db, "basic", providerRefreshHandler()))
@@ -255,11 +260,11 @@ func Handler(db *sql.DB) (http.Handler, error) {
 	return securityHeadersMiddleware(mux), nil
 }
 
-// StartServer starts an HTTP server on the given address serving the embedded UI.
-func StartServer(addr string) error {
+// OpenAuthDatabase opens the SQL database holding users and sessions. With
+// the sqlite backend this is the main database; other backends keep a
+// separate auth.db in db_path.
+func OpenAuthDatabase() (*sql.DB, error) {
 	logger := logging.GetLogger("webserver")
-	logger.Infof("starting web server on %s", addr)
-	// Get database configuration
 	backend := database.GetDatabaseBackend()
 	dbPath := viper.GetString("db_path")
 	logger.Infof("using %s database at %s", backend, dbPath)
@@ -272,7 +277,7 @@ func StartServer(addr string) error {
 	if backend == "sqlite" {
 		fullPath := database.GetDatabasePath()
 		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-			return fmt.Errorf("failed to create database directory: %w", err)
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
 		}
 		db, err = database.Open(fullPath)
 		logger.Infof("opened SQLite database %s", fullPath)
@@ -281,7 +286,7 @@ func StartServer(addr string) error {
 		authDbPath := filepath.Join(dbPath, "auth.db")
 		// Ensure directory exists
 		if err := os.MkdirAll(dbPath, 0755); err != nil {
-			return fmt.Errorf("failed to create database directory: %w", err)
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
 		}
 		db, err = database.Open(authDbPath)
 		logger.Infof("opened auth database %s", authDbPath)
@@ -290,9 +295,20 @@ func StartServer(addr string) error {
 	if err != nil {
 		// Check if this is a SQLite support issue and provide helpful error message
 		if strings.Contains(err.Error(), "SQLite support not available") {
-			return fmt.Errorf("web server requires SQLite for authentication. Please build with: go build -tags sqlite\nOriginal error: %w", err)
+			return nil, fmt.Errorf("web server requires SQLite for authentication. Please build with: go build -tags sqlite\nOriginal error: %w", err)
 		}
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// StartServer starts an HTTP server on the given address serving the embedded UI.
+func StartServer(addr string) error {
+	logger := logging.GetLogger("webserver")
+	logger.Infof("starting web server on %s", addr)
+	db, err := OpenAuthDatabase()
+	if err != nil {
+		return err
 	}
 	defer db.Close()
 	logger.Info("database initialized")
@@ -349,8 +365,14 @@ func StartServer(addr string) error {
 	go maintenance.StartDatabaseCleanup(context.Background(), db,
 		viper.GetString("db_cleanup_frequency"))
 
-	// Start Sonarr/Radarr sync tasks when configured
+	// Back the job queue with the configured store and start Sonarr/Radarr
+	// sync tasks when configured
 	if store, err := database.OpenStoreWithConfig(); err == nil {
+		jobs.SetStore(store)
+		mediahash.SetStore(store)
+		if _, err := queue.StartPersistent(store); err != nil {
+			logger.Warnf("persistent job queue disabled: %v", err)
+		}
 		if viper.GetBool("integrations.radarr.enabled") {
 			host := viper.GetString("integrations.radarr.host")
 			port := viper.GetString("integrations.radarr.port")
@@ -399,7 +421,7 @@ func StartServer(addr string) error {
 	go func() {
 		var store database.SubtitleStore
 		var err error
-		switch backend {
+		switch database.GetDatabaseBackend() {
 		case "pebble":
 			store, err = database.OpenPebble(storePath)
 		case "postgres":
@@ -553,10 +575,12 @@ func configHandler() http.Handler {
 	})
 }
 
-// extractHandler extracts subtitles from the provided media file.
+// extractHandler queues the extraction of the subtitles embedded in a media
+// file.
 //
-// POST requests expect a JSON body `{"path":"/file.mkv"}`. The subtitle items
-// extracted by subtitles.ExtractFromMedia are returned as JSON.
+// POST requests expect a JSON body `{"path":"/file.mkv"}`. The extract job
+// writes the subtitles next to the media file; its ID is returned as
+// `{"task_id":"..."}` for tracking through /api/tasks.
 func extractHandler() http.Handler {
 	type req struct {
 		Path string `json:"path"`
@@ -579,13 +603,14 @@ func extractHandler() http.Handler {
 			return
 		}
 
-		items, err := subtitles.ExtractFromMedia(sanitizedPath)
+		id, err := jobs.Enqueue(queue.JobTypeExtract, jobs.ExtractPayload{Media: sanitizedPath}, queue.JobOptions{MaxAttempts: 1, Owner: requestOwner(r)})
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(items)
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{"task_id": id})
 	})
 }
 
@@ -930,9 +955,21 @@ func providersHandler() http.Handler {
 				http.Error(w, "Invalid request body", http.StatusBadRequest)
 				return
 			}
+			schema, err := providers.Schema(req.Name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 
-			// Update provider configuration in viper
+			// Secrets come back redacted unless the user changed them.
 			configKey := fmt.Sprintf("providers.%s", req.Name)
+			req.Config = schema.Merge(req.Config, viper.GetStringMap(configKey+".config"))
+			if err := schema.Validate(req.Config, req.Enabled); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s settings: %v", req.Name, err), http.StatusBadRequest)
+				return
+			}
+
+			// Update provider configuration in viper
 			providerConfig := map[string]interface{}{
 				"enabled": req.Enabled,
 				"config":  req.Config,
@@ -997,6 +1034,9 @@ type ProviderInfo struct {
 	Enabled     bool                   `json:"enabled"`
 	Config      map[string]interface{} `json:"config"`
 	Type        string                 `json:"type"`
+	// Settings describes the fields of Config so the UI can render and
+	// validate them.
+	Settings settings.Schema `json:"settings"`
 }
 
 type MediaItem struct {
@@ -1034,7 +1074,7 @@ func getAvailableProviders() []ProviderInfo {
 		"xsubs", "yavka", "yifysubtitles", "zimuku",
 	}
 
-	var providers []ProviderInfo
+	var list []ProviderInfo
 	for _, name := range providerNames {
 		configKey := fmt.Sprintf("providers.%s", name)
 		providerConfig := viper.GetStringMap(configKey)
@@ -1055,17 +1095,20 @@ func getAvailableProviders() []ProviderInfo {
 
 		// Always include providers in the list so the UI can configure
 		// them even when no entry exists in the configuration file.
+		// Secret values such as passwords are never returned.
+		schema, _ := providers.Schema(name)
 
-		providers = append(providers, ProviderInfo{
+		list = append(list, ProviderInfo{
 			Name:        name,
 			DisplayName: formatProviderName(name),
 			Enabled:     enabled,
-			Config:      config,
+			Config:      schema.Redact(config),
 			Type:        getProviderType(name),
+			Settings:    schema,
 		})
 	}
 
-	return providers
+	return list
 }
 
 // formatProviderName formats provider names for display
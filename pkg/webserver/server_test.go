@@ -22,6 +22,9 @@ import (
 
 	"github.com/jdfalk/subtitle-manager/pkg/database"
 	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/internal/queuetest"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
 	"github.com/jdfalk/subtitle-manager/pkg/testutil"
 	"github.com/jdfalk/subtitle-manager/pkg/translator"
 
@@ -386,7 +389,8 @@ func TestScanHandlers(t *testing.T) {
 	t.Fatalf("scan did not finish")
 }
 
-// TestExtract verifies that POST /api/extract returns subtitle items.
+// TestExtract verifies that POST /api/extract queues an extract job which
+// writes the subtitles next to the media file.
 func TestExtract(t *testing.T) {
 	skipIfNoSQLite(t)
 	db, err := database.Open(":memory:")
@@ -429,16 +433,19 @@ func TestExtract(t *testing.T) {
 	if err != nil {
 		t.Fatalf("post: %v", err)
 	}
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusAccepted {
 		t.Fatalf("status %d", resp.StatusCode)
 	}
 	defer resp.Body.Close()
-	var items []any
-	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
-		t.Fatalf("failed to decode request body: %v", err)
+	var res struct {
+		TaskID string `json:"task_id"`
 	}
-	if len(items) == 0 {
-		t.Fatalf("no items returned")
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || res.TaskID == "" {
+		t.Fatalf("decode task id: %v %q", err, res.TaskID)
+	}
+	queuetest.WaitForStatus(t, queue.GetQueue(), res.TaskID, queue.StatusCompleted)
+	if _, err := os.Stat(filepath.Join(dir, "dummy.srt")); err != nil {
+		t.Fatalf("subtitle not extracted: %v", err)
 	}
 }
 
@@ -744,6 +751,13 @@ func TestDownload(t *testing.T) {
 	dir := t.TempDir()
 	viper.Set("media_directory", dir)
 	defer viper.Reset()
+	store, err := database.OpenSQLStore(filepath.Join(dir, "jobs.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	jobs.SetStore(store)
+	defer jobs.SetStore(nil)
 	vid := filepath.Join(dir, "video.mkv")
 	os.WriteFile(vid, []byte("x"), 0644)
 
@@ -773,7 +787,7 @@ func TestDownload(t *testing.T) {
 	if _, err := os.Stat(out); err != nil {
 		t.Fatalf("subtitle not written: %v", err)
 	}
-	recs, err := database.ListDownloads(db)
+	recs, err := store.ListDownloads()
 	if err != nil || len(recs) != 1 {
 		t.Fatalf("records %v %d", err, len(recs))
 	}
@@ -1019,7 +1033,7 @@ func TestProviderConfigUpdate(t *testing.T) {
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
-	body := strings.NewReader(`{"name":"generic","enabled":true,"config":{"api_url":"new"}}`)
+	body := strings.NewReader(`{"name":"generic","enabled":true,"config":{"api_url":"http://new.example"}}`)
 	req, _ := http.NewRequest("POST", srv.URL+"/api/providers", body)
 	req.Header.Set("X-API-Key", key)
 	resp, err := srv.Client().Do(req)
@@ -1035,14 +1049,14 @@ func TestProviderConfigUpdate(t *testing.T) {
 		t.Fatalf("config not updated: enabled")
 	}
 	cfg := viper.GetStringMapString("providers.generic.config")
-	if cfg["api_url"] != "new" {
+	if cfg["api_url"] != "http://new.example" {
 		t.Fatalf("config not updated: %v", cfg)
 	}
 	data, err := os.ReadFile(tmp)
 	if err != nil {
 		t.Fatalf("read cfg: %v", err)
 	}
-	if !strings.Contains(string(data), "api_url: new") {
+	if !strings.Contains(string(data), "api_url: http://new.example") {
 		t.Fatalf("file not written")
 	}
 }
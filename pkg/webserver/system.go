@@ -1,11 +1,11 @@
 // file: pkg/webserver/system.go
-// version: 1.0.1
+// version: 1.3.0
 // guid: 37c23ec8-b8b9-4086-be5c-8058fee3fd54
 
 package webserver
 
 import (
-	"context"
+	"database/sql"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -13,13 +13,17 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/jdfalk/subtitle-manager/pkg/backups"
 	"github.com/jdfalk/subtitle-manager/pkg/errors"
+	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
 	"github.com/jdfalk/subtitle-manager/pkg/providers"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
 	"github.com/jdfalk/subtitle-manager/pkg/tasks"
 )
 
@@ -71,16 +75,132 @@ func systemHandler() http.Handler {
 	})
 }
 
-// tasksHandler reports status for background tasks such as scanning.
+// taskInfo is a task snapshot merged with the queue record of the job that
+// runs it. Queue fields are empty for tasks started outside the queue.
+type taskInfo struct {
+	tasks.TaskSnapshot
+	Type        string     `json:"type,omitempty"`
+	Description string     `json:"description,omitempty"`
+	QueueStatus string     `json:"queue_status,omitempty"`
+	Priority    int        `json:"priority"`
+	Attempts    int        `json:"attempts,omitempty"`
+	MaxAttempts int        `json:"max_attempts,omitempty"`
+	RunAt       *time.Time `json:"run_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// tasksHandler reports status for background tasks such as scanning. Pending
+// and running queue jobs are listed even before a worker starts them.
 func tasksHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := make(map[string]*taskInfo)
+		for id, snap := range tasks.List() {
+			out[id] = &taskInfo{TaskSnapshot: *snap}
+		}
+		if recs, err := queue.GetQueue().History(""); err == nil {
+			for _, rec := range recs {
+				info, ok := out[rec.ID]
+				if !ok {
+					if rec.Status != queue.StatusPending && rec.Status != queue.StatusRunning {
+						continue
+					}
+					info = &taskInfo{TaskSnapshot: tasks.TaskSnapshot{ID: rec.ID, Status: rec.Status}}
+					out[rec.ID] = info
+				}
+				runAt := rec.RunAt
+				info.Type = rec.Type
+				info.QueueStatus = rec.Status
+				info.Priority = rec.Priority
+				info.Attempts = rec.Attempts
+				info.MaxAttempts = rec.MaxAttempts
+				info.RunAt = &runAt
+				info.LastError = rec.LastError
+				info.Description = queue.GetQueue().Describe(&rec)
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(tasks.List())
+		_ = json.NewEncoder(w).Encode(out)
 	})
 }
 
-// startTaskHandler launches a named task and returns its initial state.
-func startTaskHandler() http.Handler {
+// userStartableJobs lists the job types any caller with the basic role may
+// queue through /api/tasks/start. Their handlers validate every path against
+// the allowed media directories. Other registered types require the all role.
+var userStartableJobs = map[queue.JobType]bool{
+	queue.JobTypeScan:           true,
+	queue.JobTypeSync:           true,
+	queue.JobTypeTranscribe:     true,
+	queue.JobTypeExtract:        true,
+	queue.JobTypeSearchDownload: true,
+	queue.JobTypeUpgrade:        true,
+}
+
+// requestOwner returns the authenticated user of r formatted as a queue job
+// owner.
+func requestOwner(r *http.Request) string {
+	id, _ := r.Context().Value(userIDContextKey).(int64)
+	return strconv.FormatInt(id, 10)
+}
+
+// hasFullAccess reports whether the authenticated user of r holds the all
+// permission.
+func hasFullAccess(db *sql.DB, r *http.Request) bool {
+	id, _ := r.Context().Value(userIDContextKey).(int64)
+	ok, err := auth.CheckPermission(db, id, "all")
+	return err == nil && ok
+}
+
+// cancelTaskHandler cancels the task or queued job named by the id query
+// parameter. Pending queue jobs are cancelled before they start. Callers
+// without the all permission may only cancel queue jobs they started.
+func cancelTaskHandler(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		q := queue.GetQueue()
+		rec, err := q.Job(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rec == nil {
+			if _, ok := tasks.Get(id); !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+		if (rec == nil || q.Owner(rec) != requestOwner(r)) && !hasFullAccess(db, r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		ok, err := q.Cancel(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			ok = tasks.Cancel(id)
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// startTaskHandler queues a job of the registered type given by the name
+// query parameter. The optional JSON body is the job payload. Types outside
+// userStartableJobs require the all permission. The job ID is returned as
+// {"task_id":"..."}.
+func startTaskHandler(db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -97,20 +217,29 @@ func startTaskHandler() http.Handler {
 			http.Error(w, "Invalid task name", http.StatusBadRequest)
 			return
 		}
-		t := tasks.Start(r.Context(), name, func(ctx context.Context) error {
-			// Simulate a short running task
-			for i := 0; i <= 10; i++ {
-				tasks.Update(name, i*10)
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(100 * time.Millisecond):
-				}
-			}
-			return nil
-		})
+		jobType := queue.JobType(name)
+		if !userStartableJobs[jobType] && !hasFullAccess(db, r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payload, err := queue.ParsePayload(jobType, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := jobs.Enqueue(jobType, payload, queue.JobOptions{Owner: requestOwner(r)})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(t)
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{"task_id": id})
 	})
 }
 
@@ -1,13 +1,19 @@
 package webserver
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	auth "github.com/jdfalk/subtitle-manager/pkg/gcommonauth"
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
 	"github.com/jdfalk/subtitle-manager/pkg/logging"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
 	"github.com/jdfalk/subtitle-manager/pkg/testutil"
 )
 
@@ -245,11 +251,21 @@ func TestTasksEndpoints(t *testing.T) {
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
-	// start a task
-	reqStart, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/tasks/start?name=unit_task", nil)
+	// Unregistered job types are rejected.
+	reqBad, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/tasks/start?name=unit_task", nil)
+	reqBad.Header.Set("X-API-Key", keyObj.GetId())
+	rb, err := srv.Client().Do(reqBad)
+	if err != nil || rb.StatusCode != http.StatusBadRequest {
+		t.Fatalf("start unknown: %v %d", err, rb.StatusCode)
+	}
+	rb.Body.Close()
+
+	// start a scan job
+	payload := fmt.Sprintf(`{"directory":%q,"language":"en"}`, t.TempDir())
+	reqStart, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/tasks/start?name=scan", strings.NewReader(payload))
 	reqStart.Header.Set("X-API-Key", keyObj.GetId())
 	rs, err := srv.Client().Do(reqStart)
-	if err != nil || rs.StatusCode != http.StatusOK {
+	if err != nil || rs.StatusCode != http.StatusAccepted {
 		t.Fatalf("start: %v %d", err, rs.StatusCode)
 	}
 	var startResp map[string]any
@@ -271,6 +287,132 @@ func TestTasksEndpoints(t *testing.T) {
 	}
 }
 
+// asUser returns r as authenticated by authMiddleware for user id.
+func asUser(r *http.Request, id int64) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userIDContextKey, id))
+}
+
+// TestTasksListAndCancelQueuedJob verifies that pending queue jobs are listed
+// with their queue details and can be cancelled through /api/tasks/cancel by
+// their owner or a user with the all permission.
+func TestTasksListAndCancelQueuedJob(t *testing.T) {
+	skipIfNoSQLite(t)
+
+	db := testutil.GetTestDB(t)
+	defer db.Close()
+	if err := auth.CreateUser(db, "admin", "p", "", "admin"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO permissions (role, permission) VALUES ('editor', 'basic')`); err != nil {
+		t.Fatalf("insert permission: %v", err)
+	}
+	if err := auth.CreateUser(db, "editor", "p", "", "editor"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := auth.CreateUser(db, "other", "p", "", "editor"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	q := queue.NewQueue(1)
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+	queue.SetQueue(q)
+
+	add := func(owner string) string {
+		job, err := queue.NewJob(queue.JobTypeUpgrade, jobs.SearchDownloadPayload{Path: "/media/movie.mkv", Language: "en"})
+		if err != nil {
+			t.Fatalf("new job: %v", err)
+		}
+		id, err := q.AddWithOptions(job, queue.JobOptions{Priority: 4, Delay: time.Hour, Owner: owner})
+		if err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		return id
+	}
+	id := add("2")
+
+	rr := httptest.NewRecorder()
+	tasksHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+	var list map[string]taskInfo
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	info, ok := list[id]
+	if !ok {
+		t.Fatalf("queued job %s missing from %v", id, list)
+	}
+	if info.Type != string(queue.JobTypeUpgrade) || info.QueueStatus != queue.StatusPending || info.Priority != 4 {
+		t.Fatalf("unexpected task info %+v", info)
+	}
+	if info.Description != "Upgrade en subtitles for /media/movie.mkv" {
+		t.Fatalf("unexpected description %q", info.Description)
+	}
+
+	cancel := func(method, id string, user int64) int {
+		rr := httptest.NewRecorder()
+		cancelTaskHandler(db).ServeHTTP(rr, asUser(httptest.NewRequest(method, "/api/tasks/cancel?id="+id, nil), user))
+		return rr.Code
+	}
+	if code := cancel(http.MethodGet, id, 2); code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", code)
+	}
+	if code := cancel(http.MethodPost, id, 3); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another user, got %d", code)
+	}
+	if code := cancel(http.MethodPost, id, 2); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if rec, _ := q.Job(id); rec == nil || rec.Status != queue.StatusCancelled {
+		t.Fatalf("job not cancelled: %+v", rec)
+	}
+	if code := cancel(http.MethodPost, add(""), 1); code != http.StatusNoContent {
+		t.Fatalf("expected admin cancel to succeed, got %d", code)
+	}
+	if code := cancel(http.MethodPost, "unknown", 2); code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+}
+
+// TestStartTaskRequiresFullAccess verifies only users with the all
+// permission may start job types outside userStartableJobs.
+func TestStartTaskRequiresFullAccess(t *testing.T) {
+	skipIfNoSQLite(t)
+
+	db := testutil.GetTestDB(t)
+	defer db.Close()
+	if err := auth.CreateUser(db, "admin", "p", "", "admin"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO permissions (role, permission) VALUES ('editor', 'basic')`); err != nil {
+		t.Fatalf("insert permission: %v", err)
+	}
+	if err := auth.CreateUser(db, "editor", "p", "", "editor"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	q := queue.NewQueue(1)
+	if err := q.Start(); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	defer q.Stop()
+	queue.SetQueue(q)
+
+	start := func(name string, user int64) int {
+		rr := httptest.NewRecorder()
+		body := `{"input_path":"/media/a.srt","output_path":"/media/b.srt","language":"es"}`
+		startTaskHandler(db).ServeHTTP(rr, asUser(httptest.NewRequest(http.MethodPost, "/api/tasks/start?name="+name, strings.NewReader(body)), user))
+		return rr.Code
+	}
+	if code := start(string(queue.JobTypeSingleFile), 2); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for basic user, got %d", code)
+	}
+	if code := start(string(queue.JobTypeSingleFile), 1); code == http.StatusForbidden {
+		t.Fatalf("admin was refused")
+	}
+}
+
 // TestBackupsEndpoints verifies backups listing, creation, and restore.
 func TestBackupsEndpoints(t *testing.T) {
 	skipIfNoSQLite(t)
@@ -1,5 +1,5 @@
 // file: pkg/webserver/translate.go
-// version: 1.0.0
+// version: 1.2.0
 // guid: f0523cff-b15b-4527-aa90-0b65326f73f9
 
 package webserver
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 
@@ -21,7 +22,8 @@ import (
 // POST requests must use multipart/form-data containing a "file" part and
 // a "lang" field specifying the target language. Optional fields "service"
 // and "grpc" override the configured translation service and gRPC address.
-// The translated SRT data is returned with content type "application/x-subrip".
+// The translation is returned in the format of the uploaded file, so ASS and
+// WebVTT uploads keep their styling; other formats are returned as SRT.
 func translateHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -75,7 +77,8 @@ func translateHandler() http.Handler {
 			return
 		}
 
-		out, err := os.CreateTemp("", "out-*.srt")
+		ext := outputExt(hdr.Filename)
+		out, err := os.CreateTemp("", "out-*"+ext)
 		if err != nil {
 			metrics.TranslationRequests.WithLabelValues(service, lang, "error").Inc()
 			w.WriteHeader(http.StatusInternalServerError)
@@ -89,7 +92,7 @@ func translateHandler() http.Handler {
 			return
 		}
 
-		if err := subtitles.TranslateFileToSRT(in.Name(), out.Name(), lang, service, gKey, gptKey, grpcAddr); err != nil {
+		if err := subtitles.TranslateFile(in.Name(), out.Name(), lang, service, gKey, gptKey, grpcAddr); err != nil {
 			metrics.TranslationRequests.WithLabelValues(service, lang, "error").Inc()
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -101,7 +104,26 @@ func translateHandler() http.Handler {
 			return
 		}
 		metrics.TranslationRequests.WithLabelValues(service, lang, "success").Inc()
-		w.Header().Set("Content-Type", "application/x-subrip")
+		w.Header().Set("Content-Type", contentType(ext))
 		_, _ = w.Write(data)
 	})
 }
+
+// outputExt returns the extension used for the translation of name. Formats
+// that keep styling are preserved and everything else becomes SRT.
+func outputExt(name string) string {
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".ass", ".ssa", ".vtt":
+		return ext
+	}
+	return ".srt"
+}
+
+// contentType returns the content type for a subtitle extension.
+func contentType(ext string) string {
+	format, err := subtitles.ParseFormat(ext)
+	if err != nil {
+		format = subtitles.FormatSRT
+	}
+	return format.ContentType()
+}
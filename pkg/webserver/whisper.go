@@ -1,5 +1,5 @@
 // file: pkg/webserver/whisper.go
-// version: 1.0.0
+// version: 1.2.0
 // guid: 123e4567-e89b-12d3-a456-426614174002
 
 package webserver
@@ -9,6 +9,9 @@ import (
 	"net/http"
 	"path/filepath"
 
+	"github.com/jdfalk/subtitle-manager/pkg/jobs"
+	"github.com/jdfalk/subtitle-manager/pkg/queue"
+	"github.com/jdfalk/subtitle-manager/pkg/security"
 	"github.com/jdfalk/subtitle-manager/pkg/transcriber"
 )
 
@@ -101,7 +104,10 @@ func whisperContainerStopHandler() http.Handler {
 	})
 }
 
-// whisperTranscribeHandler starts a transcription job.
+// whisperTranscribeHandler queues a transcription job using the Whisper
+// container, which falls back to the OpenAI API when it is not running. The
+// subtitle is written next to the media file and the response carries the
+// queue job ID as task_id.
 func whisperTranscribeHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -139,21 +145,27 @@ func whisperTranscribeHandler() http.Handler {
 			return
 		}
 
-		container, err := transcriber.NewWhisperContainer()
+		media, err := security.ValidateAndSanitizePath(req.FilePath)
 		if err != nil {
-			http.Error(w, "Failed to create container client", http.StatusInternalServerError)
+			http.Error(w, "Invalid file path: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer container.Close()
+		if req.Language != "" {
+			if err := security.ValidateLanguageCode(req.Language); err != nil {
+				http.Error(w, "Invalid language: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
-		task, err := container.TranscribeFile(r.Context(), req.FilePath, req.Language)
+		payload := jobs.TranscribePayload{Media: media, Language: req.Language, Method: transcriber.MethodContainer}
+		id, err := jobs.Enqueue(queue.JobTypeTranscribe, payload, queue.JobOptions{MaxAttempts: 1, Owner: requestOwner(r)})
 		if err != nil {
-			http.Error(w, "Failed to start transcription: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Failed to queue transcription: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		response := map[string]interface{}{
-			"task_id": task.ID,
+			"task_id": id,
 			"status":  "started",
 		}
 
@@ -11,24 +11,40 @@ import {
   Card,
   CardContent,
   Chip,
+  FormControl,
   IconButton,
+  InputLabel,
   LinearProgress,
+  MenuItem,
   Paper,
+  Select,
   Snackbar,
   Typography,
 } from '@mui/material';
 import { useState } from 'react';
 import { apiService } from './services/api.js';
 
+/** Target formats offered by the /api/convert endpoint. */
+const targetFormats = [
+  { value: 'srt', label: 'SRT' },
+  { value: 'vtt', label: 'WebVTT' },
+  { value: 'ass', label: 'ASS' },
+  { value: 'ssa', label: 'SSA' },
+  { value: 'ttml', label: 'TTML' },
+  { value: 'stl', label: 'STL' },
+  { value: 'sub', label: 'MicroDVD' },
+];
+
 /**
  * Convert provides a form to upload a subtitle file which is
- * converted to SRT format via the /api/convert endpoint.
+ * converted to the selected format via the /api/convert endpoint.
  * The resulting file is downloaded by the browser.
  * @param {Object} props - Component props
  * @param {boolean} props.backendAvailable - Whether the backend service is available
  */
 export default function Convert({ backendAvailable = true }) {
   const [file, setFile] = useState(null);
+  const [format, setFormat] = useState('srt');
   const [status, setStatus] = useState('');
   const [converting, setConverting] = useState(false);
   const [snackbarOpen, setSnackbarOpen] = useState(false);
@@ -39,14 +55,14 @@ export default function Convert({ backendAvailable = true }) {
     setStatus('');
 
     try {
-      const response = await apiService.subtitles.convert(file);
+      const response = await apiService.subtitles.convert(file, format);
 
       if (response.ok) {
         const blob = await response.blob();
         const url = window.URL.createObjectURL(blob);
         const a = document.createElement('a');
         a.href = url;
-        a.download = file.name.replace(/\.[^/.]+$/, '.srt');
+        a.download = file.name.replace(/\.[^/.]+$/, `.${format}`);
         a.click();
         window.URL.revokeObjectURL(url);
         setStatus('File converted and downloaded successfully!');
@@ -77,13 +93,14 @@ export default function Convert({ backendAvailable = true }) {
   };
 
   const getSupportedFormats = () => [
+    'SRT',
     'VTT',
     'ASS',
     'SSA',
     'SUB',
-    'SBV',
     'TTML',
     'DFXP',
+    'STL',
   ];
 
   return (
@@ -100,8 +117,8 @@ export default function Convert({ backendAvailable = true }) {
       )}
 
       <Typography variant="body1" color="text.secondary" paragraph>
-        Upload a subtitle file to convert it to SRT format. Supported formats
-        include VTT, ASS, SSA, SUB, SBV, TTML, and DFXP.
+        Upload a subtitle file to convert it to another format. Styling is
+        mapped between ASS/SSA and SRT/WebVTT.
       </Typography>
 
       <Card sx={{ maxWidth: 600, mx: 'auto' }}>
@@ -110,7 +127,7 @@ export default function Convert({ backendAvailable = true }) {
             {!file ? (
               <Box>
                 <input
-                  accept=".vtt,.ass,.ssa,.sub,.sbv,.ttml,.dfxp"
+                  accept=".srt,.vtt,.ass,.ssa,.sub,.ttml,.dfxp,.stl"
                   style={{ display: 'none' }}
                   id="subtitle-file-input"
                   type="file"
@@ -173,6 +190,22 @@ export default function Convert({ backendAvailable = true }) {
                   </Box>
                 </Paper>
 
+                <FormControl fullWidth sx={{ mb: 2 }}>
+                  <InputLabel>Target Format</InputLabel>
+                  <Select
+                    value={format}
+                    label="Target Format"
+                    onChange={e => setFormat(e.target.value)}
+                    disabled={converting}
+                  >
+                    {targetFormats.map(f => (
+                      <MenuItem key={f.value} value={f.value}>
+                        {f.label}
+                      </MenuItem>
+                    ))}
+                  </Select>
+                </FormControl>
+
                 <Button
                   variant="contained"
                   startIcon={converting ? <LinearProgress /> : <ConvertIcon />}
@@ -185,7 +218,9 @@ export default function Convert({ backendAvailable = true }) {
                     ? 'Backend Unavailable'
                     : converting
                       ? 'Converting...'
-                      : 'Convert to SRT'}
+                      : `Convert to ${
+                          targetFormats.find(f => f.value === format).label
+                        }`}
                 </Button>
               </Box>
             )}
@@ -2,7 +2,6 @@ import {
   Archive as ExtractIcon,
   Folder as FolderIcon,
   Movie as MediaIcon,
-  Subtitles as SubtitleIcon,
 } from '@mui/icons-material';
 import {
   Alert,
@@ -10,13 +9,7 @@ import {
   Button,
   Card,
   CardContent,
-  Chip,
   LinearProgress,
-  List,
-  ListItem,
-  ListItemIcon,
-  ListItemText,
-  Paper,
   TextField,
   Typography,
 } from '@mui/material';
@@ -25,8 +18,8 @@ import { apiService } from './services/api.js';
 
 /**
  * Extract provides a simple form to request subtitle extraction for a media file.
- * The path to the media file is POSTed to `/api/extract` and the number of
- * extracted items is displayed.
+ * The path to the media file is POSTed to `/api/extract`, which queues an
+ * extract job, and the ID of the queued task is displayed.
  * @param {Object} props - Component props
  * @param {boolean} props.backendAvailable - Whether the backend service is available
  */
@@ -34,7 +27,6 @@ export default function Extract({ backendAvailable = true }) {
   const [path, setPath] = useState('');
   const [status, setStatus] = useState('');
   const [extracting, setExtracting] = useState(false);
-  const [extractedItems, setExtractedItems] = useState([]);
 
   const doExtract = async () => {
     if (!path.trim()) {
@@ -44,16 +36,14 @@ export default function Extract({ backendAvailable = true }) {
 
     setExtracting(true);
     setStatus('');
-    setExtractedItems([]);
 
     try {
       const response = await apiService.subtitles.extract(path);
 
       if (response.ok) {
-        const items = await response.json();
-        setExtractedItems(items || []);
+        const { task_id: taskId } = await response.json();
         setStatus(
-          `Successfully extracted ${items?.length || 0} subtitle streams`
+          `Extraction queued as task ${taskId}. The subtitles are written next to the media file.`
         );
       } else {
         const errorText = await response.text();
@@ -136,7 +126,7 @@ export default function Extract({ backendAvailable = true }) {
                 align="center"
                 mt={1}
               >
-                Analyzing media file and extracting subtitle streams...
+                Queueing subtitle extraction...
               </Typography>
             </Box>
           )}
@@ -154,57 +144,6 @@ export default function Extract({ backendAvailable = true }) {
             </Alert>
           )}
 
-          {extractedItems.length > 0 && (
-            <Box mt={3}>
-              <Typography variant="h6" gutterBottom>
-                Extracted Subtitle Files
-              </Typography>
-              <Paper variant="outlined">
-                <List>
-                  {extractedItems.map((item, index) => (
-                    <ListItem
-                      key={index}
-                      divider={index < extractedItems.length - 1}
-                    >
-                      <ListItemIcon>
-                        <SubtitleIcon color="primary" />
-                      </ListItemIcon>
-                      <ListItemText
-                        primary={item.filename || `Stream ${index + 1}`}
-                        secondary={
-                          <Box
-                            display="flex"
-                            alignItems="center"
-                            gap={1}
-                            mt={1}
-                          >
-                            <Chip
-                              label={item.language || 'Unknown'}
-                              size="small"
-                              variant="outlined"
-                            />
-                            <Chip
-                              label={item.codec || 'Unknown format'}
-                              size="small"
-                              color="secondary"
-                              variant="outlined"
-                            />
-                            {item.title && (
-                              <Chip
-                                label={item.title}
-                                size="small"
-                                variant="outlined"
-                              />
-                            )}
-                          </Box>
-                        }
-                      />
-                    </ListItem>
-                  ))}
-                </List>
-              </Paper>
-            </Box>
-          )}
         </CardContent>
       </Card>
 
@@ -1,5 +1,5 @@
 // file: webui/src/Settings.jsx
-// version: 1.1.0
+// version: 1.1.1
 // guid: b1c2d3e4-f5a6-4b7c-8d9e-0a1b2c3d4e5f
 
 import {
@@ -214,14 +214,8 @@ export default function Settings({ backendAvailable = true }) {
   const hasRequiredConfig = provider => {
     if (!provider.config) return false;
 
-    const requiredFields = {
-      opensubtitles: ['apiKey'],
-      addic7ed: ['username', 'password'],
-      generic: ['baseUrl'],
-    };
-
-    const required = requiredFields[provider.name] || [];
-    return required.every(field => provider.config[field]);
+    const required = (provider.settings || []).filter(field => field.required);
+    return required.every(field => provider.config[field.key]);
   };
 
   /**
@@ -261,10 +255,10 @@ export default function Settings({ backendAvailable = true }) {
    */
   const handleProviderSave = async provider => {
     try {
-      const response = await fetch(`/api/providers/${provider.name}/config`, {
-        method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify(provider.config),
+      const response = await apiService.post('/api/providers', {
+        name: provider.name,
+        enabled: provider.enabled,
+        config: provider.config,
       });
 
       if (response.ok) {
@@ -278,6 +272,8 @@ export default function Settings({ backendAvailable = true }) {
         setProviderConfigDialog({ open: false, provider: null });
         setStatus(`${formatProviderName(provider.name)} configuration saved`);
         setSnackbarOpen(true);
+      } else {
+        setError(await response.text());
       }
     } catch (error) {
       console.error('Failed to save provider config:', error);
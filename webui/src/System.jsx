@@ -1,4 +1,5 @@
 import {
+  Cancel as CancelIcon,
   Code as CodeIcon,
   ExpandMore as ExpandMoreIcon,
   Assessment as LogIcon,
@@ -84,6 +85,18 @@ export default function System({ backendAvailable = true }) {
     loadSystemData();
   }, []);
 
+  const cancelTask = async taskId => {
+    try {
+      const response = await apiService.tasks.cancel(taskId);
+      if (!response.ok && response.status !== 404) {
+        setError(`Failed to cancel task ${taskId}`);
+      }
+    } catch (error) {
+      console.error('Failed to cancel task:', error);
+    }
+    loadSystemData();
+  };
+
   const formatBytes = bytes => {
     if (!bytes) return 'N/A';
     const sizes = ['Bytes', 'KB', 'MB', 'GB'];
@@ -242,8 +255,36 @@ export default function System({ backendAvailable = true }) {
                           ? taskInfo.progress
                           : null;
 
+                      const description =
+                        taskInfo &&
+                        typeof taskInfo === 'object' &&
+                        typeof taskInfo.description === 'string' &&
+                        taskInfo.description
+                          ? taskInfo.description
+                          : taskId;
+                      const cancellable =
+                        status === 'running' || status === 'pending';
+
                       return (
-                        <ListItem key={taskId} divider sx={{ px: 0 }}>
+                        <ListItem
+                          key={taskId}
+                          divider
+                          sx={{ px: 0 }}
+                          secondaryAction={
+                            cancellable && (
+                              <Tooltip title="Cancel task">
+                                <IconButton
+                                  edge="end"
+                                  aria-label={`cancel ${taskId}`}
+                                  onClick={() => cancelTask(taskId)}
+                                  disabled={!backendAvailable}
+                                >
+                                  <CancelIcon />
+                                </IconButton>
+                              </Tooltip>
+                            )
+                          }
+                        >
                           <ListItemText
                             primary={
                               <Typography
@@ -251,7 +292,7 @@ export default function System({ backendAvailable = true }) {
                                 fontWeight={500}
                                 color="text.primary"
                               >
-                                {taskId}
+                                {description}
                               </Typography>
                             }
                             secondary={
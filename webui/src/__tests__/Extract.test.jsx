@@ -8,7 +8,7 @@ describe('Extract component', () => {
   beforeEach(() => {
     vi.restoreAllMocks();
     global.fetch = vi.fn(() =>
-      Promise.resolve({ ok: true, json: () => Promise.resolve([]) })
+      Promise.resolve({ ok: true, json: () => Promise.resolve({ task_id: 'abc' }) })
     );
   });
 
@@ -21,5 +21,6 @@ describe('Extract component', () => {
     await waitFor(() =>
       expect(fetch).toHaveBeenCalledWith('/api/extract', expect.any(Object))
     );
+    expect(await screen.findByText(/queued as task abc/)).toBeInTheDocument();
   });
 });
@@ -1,5 +1,5 @@
 // file: webui/src/__tests__/ProviderConfigDialog.test.jsx
-// version: 1.0.0
+// version: 1.1.0
 // guid: e2f3a4b5-c6d7-4e8f-9012-3456789abcde
 
 import { render, screen, fireEvent } from '@testing-library/react';
@@ -45,4 +45,36 @@ describe('Provider configuration', () => {
     fireEvent.click(screen.getByText('OpenSubtitles'));
     expect(screen.getByText(/configure provider/i)).toBeInTheDocument();
   });
+
+  test('renders the settings schema and saves only its keys', () => {
+    const onSave = jest.fn();
+    render(
+      <ProviderConfigDialog
+        open
+        provider={{
+          name: 'addic7ed',
+          enabled: true,
+          config: { username: 'alice', user_agent: 'legacy' },
+          settings: [
+            { key: 'username', label: 'Username', type: 'string', required: true },
+            { key: 'password', label: 'Password', type: 'secret' },
+          ],
+        }}
+        onClose={() => {}}
+        onSave={onSave}
+      />
+    );
+    expect(screen.getByLabelText(/username/i)).toHaveValue('alice');
+    fireEvent.change(screen.getByLabelText(/password/i), {
+      target: { value: 'secret' },
+    });
+    fireEvent.click(screen.getByText(/save configuration/i));
+    expect(onSave).toHaveBeenCalledWith(
+      expect.objectContaining({
+        name: 'addic7ed',
+        enabled: true,
+        config: { username: 'alice', password: 'secret' },
+      })
+    );
+  });
 });
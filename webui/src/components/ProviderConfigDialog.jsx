@@ -20,7 +20,26 @@ import { useEffect, useState } from 'react';
 import { apiService } from '../services/api.js';
 
 /**
- * Fixed ProviderConfigDialog with proper provider selection and configuration options
+ * Pick the keys of config declared by the provider's settings schema.
+ * Settings saved by older versions may hold keys the server now rejects.
+ *
+ * @param {Object} config - Stored configuration
+ * @param {Array} schema - Settings fields returned by /api/providers
+ * @returns {Object} Configuration limited to the schema
+ */
+const pickSchemaKeys = (config, schema) => {
+  const out = {};
+  schema.forEach(field => {
+    if (config?.[field.key] !== undefined) {
+      out[field.key] = config[field.key];
+    }
+  });
+  return out;
+};
+
+/**
+ * ProviderConfigDialog renders the settings schema a provider declares and
+ * saves the values it accepts.
  *
  * @param {boolean} open - Whether dialog is open
  * @param {Object} provider - Provider object if editing existing provider
@@ -35,6 +54,7 @@ export default function ProviderConfigDialog({
 }) {
   const [selectedProvider, setSelectedProvider] = useState('');
   const [config, setConfig] = useState({});
+  const [enabled, setEnabled] = useState(true);
   const [availableProviders, setAvailableProviders] = useState([]);
 
   // Load available providers when dialog opens
@@ -43,17 +63,19 @@ export default function ProviderConfigDialog({
 
     if (provider) {
       setSelectedProvider(provider.name);
-      setConfig(provider.config || {});
+      setConfig(pickSchemaKeys(provider.config, provider.settings || []));
+      setEnabled(provider.enabled ?? true);
     } else {
       setSelectedProvider('');
       setConfig({});
+      setEnabled(true);
       loadAvailableProviders();
     }
   }, [open, provider]);
 
   const loadAvailableProviders = async () => {
     try {
-      const response = await apiService.get('/api/providers/available');
+      const response = await apiService.get('/api/providers');
       if (response.ok) {
         const providers = await response.json();
         setAvailableProviders(providers);
@@ -80,83 +102,23 @@ export default function ProviderConfigDialog({
     return displayNames[name] || name.charAt(0).toUpperCase() + name.slice(1);
   };
 
+  /**
+   * Return the settings schema of a provider as reported by the server.
+   */
   const getProviderConfigFields = providerName => {
-    const configs = {
-      opensubtitles: [
-        { key: 'api_key', label: 'API Key', type: 'password', required: true },
-        {
-          key: 'user_agent',
-          label: 'User Agent',
-          type: 'text',
-          required: true,
-        },
-        { key: 'enabled', label: 'Enabled', type: 'boolean', default: true },
-      ],
-      opensubtitlescom: [
-        { key: 'api_key', label: 'API Key', type: 'password', required: true },
-        { key: 'enabled', label: 'Enabled', type: 'boolean', default: true },
-      ],
-      addic7ed: [
-        { key: 'username', label: 'Username', type: 'text', required: true },
-        {
-          key: 'password',
-          label: 'Password',
-          type: 'password',
-          required: true,
-        },
-        { key: 'enabled', label: 'Enabled', type: 'boolean', default: true },
-      ],
-      subscene: [
-        { key: 'enabled', label: 'Enabled', type: 'boolean', default: true },
-        {
-          key: 'timeout',
-          label: 'Timeout (seconds)',
-          type: 'number',
-          default: 30,
-        },
-      ],
-      embedded: [
-        { key: 'enabled', label: 'Enabled', type: 'boolean', default: true },
-        {
-          key: 'extract_mkv',
-          label: 'Extract from MKV',
-          type: 'boolean',
-          default: true,
-        },
-        {
-          key: 'extract_mp4',
-          label: 'Extract from MP4',
-          type: 'boolean',
-          default: true,
-        },
-        {
-          key: 'ffmpeg_path',
-          label: 'FFmpeg Path',
-          type: 'text',
-          placeholder: '/usr/bin/ffmpeg',
-        },
-      ],
-      // Add configurations for other providers
-    };
-
-    return (
-      configs[providerName] || [
-        { key: 'enabled', label: 'Enabled', type: 'boolean', default: true },
-      ]
-    );
+    if (provider && provider.name === providerName) {
+      return provider.settings || [];
+    }
+    const found = availableProviders.find(p => p.name === providerName);
+    return found?.settings || [];
   };
 
   const handleProviderChange = newProvider => {
     setSelectedProvider(newProvider);
-    // Reset config when provider changes
-    const fields = getProviderConfigFields(newProvider);
-    const newConfig = {};
-    fields.forEach(field => {
-      if (field.default !== undefined) {
-        newConfig[field.key] = field.default;
-      }
-    });
-    setConfig(newConfig);
+    // Start from the stored configuration of the chosen provider
+    const found = availableProviders.find(p => p.name === newProvider);
+    setConfig(pickSchemaKeys(found?.config, found?.settings || []));
+    setEnabled(true);
   };
 
   const handleConfigChange = (key, value) => {
@@ -173,10 +135,11 @@ export default function ProviderConfigDialog({
     }
 
     const providerData = {
+      ...(provider || {}),
       name: selectedProvider,
       displayName: getProviderDisplayName(selectedProvider),
-      config: config,
-      enabled: config.enabled !== false,
+      config: pickSchemaKeys(config, getProviderConfigFields(selectedProvider)),
+      enabled,
     };
 
     onSave(providerData);
@@ -184,16 +147,16 @@ export default function ProviderConfigDialog({
   };
 
   const renderConfigField = field => {
-    const value = config[field.key] ?? field.default ?? '';
+    const value = config[field.key] ?? '';
 
     switch (field.type) {
-      case 'boolean':
+      case 'bool':
         return (
           <FormControlLabel
             key={field.key}
             control={
               <Switch
-                checked={!!value}
+                checked={value === true || value === 'true'}
                 onChange={e => handleConfigChange(field.key, e.target.checked)}
               />
             }
@@ -201,34 +164,48 @@ export default function ProviderConfigDialog({
           />
         );
 
-      case 'number':
+      case 'secret':
         return (
           <TextField
             key={field.key}
             fullWidth
             label={field.label}
-            type="number"
+            type="password"
             value={value}
-            onChange={e =>
-              handleConfigChange(field.key, parseInt(e.target.value) || 0)
-            }
+            onChange={e => handleConfigChange(field.key, e.target.value)}
             required={field.required}
-            placeholder={field.placeholder}
+            helperText={field.help}
             sx={{ mb: 2 }}
           />
         );
 
-      case 'password':
+      case 'duration':
         return (
           <TextField
             key={field.key}
             fullWidth
             label={field.label}
-            type="password"
             value={value}
             onChange={e => handleConfigChange(field.key, e.target.value)}
             required={field.required}
-            placeholder={field.placeholder}
+            helperText={field.help}
+            placeholder="30s"
+            sx={{ mb: 2 }}
+          />
+        );
+
+      case 'url':
+        return (
+          <TextField
+            key={field.key}
+            fullWidth
+            label={field.label}
+            type="url"
+            value={value}
+            onChange={e => handleConfigChange(field.key, e.target.value)}
+            required={field.required}
+            helperText={field.help}
+            placeholder="https://"
             sx={{ mb: 2 }}
           />
         );
@@ -242,7 +219,7 @@ export default function ProviderConfigDialog({
             value={value}
             onChange={e => handleConfigChange(field.key, e.target.value)}
             required={field.required}
-            placeholder={field.placeholder}
+            helperText={field.help}
             sx={{ mb: 2 }}
           />
         );
@@ -251,6 +228,8 @@ export default function ProviderConfigDialog({
 
   const isValid = () => {
     if (!selectedProvider) return false;
+    // Required settings only matter for enabled providers
+    if (!enabled) return true;
 
     const fields = getProviderConfigFields(selectedProvider);
     return fields.every(field => {
@@ -309,6 +288,16 @@ export default function ProviderConfigDialog({
               </Alert>
 
               <Box>
+                <FormControlLabel
+                  control={
+                    <Switch
+                      checked={enabled}
+                      onChange={e => setEnabled(e.target.checked)}
+                    />
+                  }
+                  label="Enabled"
+                  sx={{ mb: 1 }}
+                />
                 {getProviderConfigFields(
                   provider?.name || selectedProvider
                 ).map(renderConfigField)}
@@ -348,13 +348,15 @@ export const apiService = {
    */
   subtitles: {
     /**
-     * Convert subtitle file to SRT format
+     * Convert subtitle file to another format
      * @param {File} file - Subtitle file to convert
+     * @param {string} format - Target format (srt, ass, ssa, vtt, ttml, stl or sub)
      * @returns {Promise<Response>} - Converted file response (blob)
      */
-    async convert(file) {
+    async convert(file, format = 'srt') {
       const formData = new FormData();
       formData.append('file', file);
+      formData.append('format', format);
       return apiService.postFormData('/api/convert', formData);
     },
 
@@ -570,13 +572,27 @@ export const apiService = {
     },
 
     /**
-     * Start a task
-     * @param {string} taskName - Name of task to start
-     * @param {Object} params - Task parameters
+     * Queue a job
+     * @param {string} taskName - Job type to queue, such as "scan"
+     * @param {Object} params - Job payload
      * @returns {Promise<Response>} - Start task response
      */
     async start(taskName, params = {}) {
-      return apiService.post('/api/tasks/start', { task: taskName, ...params });
+      return apiService.post(
+        `/api/tasks/start?name=${encodeURIComponent(taskName)}`,
+        params
+      );
+    },
+
+    /**
+     * Cancel a running task or pending queue job
+     * @param {string} taskId - ID of the task to cancel
+     * @returns {Promise<Response>} - Cancel task response
+     */
+    async cancel(taskId) {
+      return apiService.post(
+        `/api/tasks/cancel?id=${encodeURIComponent(taskId)}`
+      );
     },
   },
 